@@ -0,0 +1,118 @@
+// Package attachment sends the large binary parameters as the separate frames of the multipart message.
+//
+// The JSON envelope keeps the reference to the attachment instead of the base64 encoded data:
+//
+//	params := key_value.New().Set("abi", attachment.Ref("abi"))
+//	frames, _ := attachment.Pack(envelope, map[string][]byte{"abi": abiJson})
+//	socket.SendMessage(frames) // or attachment.Send(socket, envelope, attachments)
+//
+// The receiver gets the frames by socket.RecvMessageBytes, and unpacks them.
+// The attachments are not copied, they refer to the received frames.
+//
+//	envelope, attachments, _ := attachment.Unpack(frames)
+//	abiJson, _ := attachment.Get(params, "abi", attachments)
+//
+// The frames are: envelope, name 1, data 1, name 2, data 2, ...
+package attachment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RefPrefix is the prefix of the parameter that refers to the attachment
+const RefPrefix = "attachment:"
+
+// Ref returns the parameter value that refers to the attachment by its name
+func Ref(name string) string {
+	return RefPrefix + name
+}
+
+// Name returns the attachment name from the parameter value.
+// Returns false if the value is not a reference.
+func Name(value interface{}) (string, bool) {
+	str, ok := value.(string)
+	if !ok || !strings.HasPrefix(str, RefPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(str, RefPrefix), true
+}
+
+// Pack returns the frames of the envelope with the attachments.
+// The attachments are ordered by the name, so the same message has the same frames.
+func Pack(envelope []byte, attachments map[string][]byte) ([][]byte, error) {
+	names := make([]string, 0, len(attachments))
+	for name := range attachments {
+		if len(name) == 0 {
+			return nil, fmt.Errorf("empty attachment name")
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	frames := make([][]byte, 0, 1+len(names)*2)
+	frames = append(frames, envelope)
+	for _, name := range names {
+		frames = append(frames, []byte(name), attachments[name])
+	}
+	return frames, nil
+}
+
+// Unpack returns the envelope and the attachments from the frames.
+// The returned slices share the memory with the frames.
+func Unpack(frames [][]byte) ([]byte, map[string][]byte, error) {
+	if len(frames) == 0 {
+		return nil, nil, fmt.Errorf("no frames")
+	}
+	if len(frames)%2 != 1 {
+		return nil, nil, fmt.Errorf("attachment frames must be in name and data pairs, got %d frames", len(frames)-1)
+	}
+
+	attachments := make(map[string][]byte, (len(frames)-1)/2)
+	for i := 1; i < len(frames); i += 2 {
+		name := string(frames[i])
+		if len(name) == 0 {
+			return nil, nil, fmt.Errorf("frame %d has an empty attachment name", i)
+		}
+		if _, ok := attachments[name]; ok {
+			return nil, nil, fmt.Errorf("duplicate '%s' attachment", name)
+		}
+		attachments[name] = frames[i+1]
+	}
+	return frames[0], attachments, nil
+}
+
+// Get returns the attachment referred by the parameter
+func Get(params map[string]interface{}, key string, attachments map[string][]byte) ([]byte, error) {
+	value, ok := params[key]
+	if !ok {
+		return nil, fmt.Errorf("missing '%s' parameter", key)
+	}
+	name, ok := Name(value)
+	if !ok {
+		return nil, fmt.Errorf("'%s' parameter is not an attachment reference", key)
+	}
+	data, ok := attachments[name]
+	if !ok {
+		return nil, fmt.Errorf("'%s' attachment not found", name)
+	}
+	return data, nil
+}
+
+// Sender sends the multipart message, for example *zmq4.Socket
+type Sender interface {
+	SendMessage(parts ...interface{}) (int, error)
+}
+
+// Send the envelope with the attachments as one multipart message
+func Send(sender Sender, envelope []byte, attachments map[string][]byte) error {
+	frames, err := Pack(envelope, attachments)
+	if err != nil {
+		return fmt.Errorf("Pack: %w", err)
+	}
+	if _, err := sender.SendMessage(frames); err != nil {
+		return fmt.Errorf("sender.SendMessage: %w", err)
+	}
+	return nil
+}
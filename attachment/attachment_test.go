@@ -0,0 +1,70 @@
+package attachment
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestAttachmentSuite struct {
+	suite.Suite
+}
+
+type sender struct {
+	parts []interface{}
+}
+
+func (s *sender) SendMessage(parts ...interface{}) (int, error) {
+	s.parts = parts
+	return 0, nil
+}
+
+// Test_10_Pack tests the frames of the attachments
+func (test *TestAttachmentSuite) Test_10_Pack() {
+	s := test.Require
+
+	envelope := []byte(`{"command":"set-abi","parameters":{"abi":"attachment:abi"}}`)
+	attachments := map[string][]byte{"logs": []byte("binary logs"), "abi": []byte(`[{"type":"event"}]`)}
+
+	frames, err := Pack(envelope, attachments)
+	s().NoError(err)
+	s().Len(frames, 5)
+	s().Equal("abi", string(frames[1]))
+	s().Equal("logs", string(frames[3]))
+
+	unpacked, received, err := Unpack(frames)
+	s().NoError(err)
+	s().Equal(envelope, unpacked)
+	s().Equal(attachments, received)
+
+	// no copy
+	frames[2][0] = '{'
+	s().Equal(byte('{'), received["abi"][0])
+
+	params := map[string]interface{}{"abi": Ref("abi"), "id": "a"}
+	data, err := Get(params, "abi", received)
+	s().NoError(err)
+	s().Equal(frames[2], data)
+
+	_, err = Get(params, "id", received)
+	s().Error(err)
+	_, err = Get(params, "missing", received)
+	s().Error(err)
+
+	_, _, err = Unpack(frames[:4])
+	s().Error(err)
+	_, _, err = Unpack([][]byte{envelope, []byte("a"), nil, []byte("a"), nil})
+	s().Error(err)
+
+	out := &sender{}
+	s().NoError(Send(out, envelope, attachments))
+	s().Len(out.parts, 1)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestAttachment(t *testing.T) {
+	suite.Run(t, new(TestAttachmentSuite))
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/ahmetson/os-lib/arg"
 	"github.com/ahmetson/service-lib/flag"
 	"github.com/ahmetson/service-lib/manager"
+	"github.com/ahmetson/service-lib/sandbox"
+	"os"
 )
 
 type Auxiliary struct {
@@ -16,8 +18,12 @@ type Auxiliary struct {
 }
 
 // NewAuxiliary creates a parent with the parent.
-// It requires a parent flag
-func NewAuxiliary() (*Auxiliary, error) {
+// It requires a parent flag.
+// The options are passed to New.
+//
+// The sandbox options declared by the parent for this dependency are applied
+// before the context is started, see setSandbox.
+func NewAuxiliary(opts ...Option) (*Auxiliary, error) {
 	if !arg.FlagExist(flag.ParentFlag) {
 		return nil, fmt.Errorf("missing %s flag", arg.NewFlag(flag.ParentFlag))
 	}
@@ -52,10 +58,40 @@ func NewAuxiliary() (*Auxiliary, error) {
 		return nil, fmt.Errorf("manager.NewClient('parentConfig'): %w", err)
 	}
 
-	independent, err := New()
+	if err := setSandbox(); err != nil {
+		return nil, fmt.Errorf("setSandbox: %w", err)
+	}
+
+	independent, err := New(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("new independent parent: %w", err)
 	}
 
 	return &Auxiliary{Service: independent, ParentManager: parent, ParentConfig: &parentConfig}, nil
 }
+
+// The setSandbox applies the sandbox options of this dependency to the process, see sandbox.Env.
+// The dependency manager spawns the dependency with the environment of the parent,
+// so the options are found by the id of this dependency.
+// The dependency without the id in the flags or environment has no options.
+func setSandbox() error {
+	id := os.Getenv(flag.IdEnv)
+	if arg.FlagExist(flag.IdFlag) {
+		id = arg.FlagValue(flag.IdFlag)
+	}
+	if len(id) == 0 {
+		return nil
+	}
+
+	options, err := sandbox.Parse(os.Getenv(sandbox.Env(id)))
+	if err != nil {
+		return fmt.Errorf("sandbox.Parse('%s'): %w", sandbox.Env(id), err)
+	}
+	if options.IsZero() {
+		return nil
+	}
+	if err := sandbox.Self(options); err != nil {
+		return fmt.Errorf("sandbox.Self: %w", err)
+	}
+	return nil
+}
@@ -13,7 +13,6 @@ import (
 	"github.com/ahmetson/os-lib/arg"
 	"github.com/ahmetson/os-lib/path"
 	"github.com/ahmetson/service-lib/flag"
-	"github.com/pebbe/zmq4"
 	"github.com/stretchr/testify/suite"
 	win "os"
 	"path/filepath"
@@ -99,7 +98,7 @@ func (test *TestAuxiliarySuite) Test_10_NewAuxiliary() {
 	DeleteLastFlags(1)
 
 	// Creating an auxiliary with the valid flags must succeed
-	parentClient := clientConfig.New(test.url+"_parent", test.id+"_parent", 6000, zmq4.REP)
+	parentClient := clientConfig.New(test.url+"_parent", test.id+"_parent", 6000, handlerConfig.SocketType(handlerConfig.SyncReplierType))
 	parentKv, err := key_value.NewFromInterface(parentClient)
 	s().NoError(err)
 	parentStr := parentKv.String()
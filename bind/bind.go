@@ -0,0 +1,69 @@
+// Package bind converts the request parameters into the user structs and back.
+//
+// Instead of reading the parameters one by one in the route function:
+//
+//	type GetLogs struct {
+//		NetworkId string `json:"network_id" validate:"required"`
+//		Limit     uint64 `json:"limit" validate:"min=1,max=1000"`
+//	}
+//
+//	func onGetLogs(req message.RequestInterface) message.ReplyInterface {
+//		params, err := bind.Request[GetLogs](req)
+//		if err != nil {
+//			return req.Fail(err.Error())
+//		}
+//		...
+//		return bind.ReplyOf(req, logs)
+//	}
+//
+// The validation rules are described in schema.Tag.
+package bind
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/service-lib/schema"
+)
+
+// Request converts the route parameters into T and validates it.
+// If the parameters are not valid, then the error lists all violations.
+func Request[T any](req message.RequestInterface) (T, error) {
+	var params T
+	if err := req.RouteParameters().Interface(&params); err != nil {
+		return params, fmt.Errorf("req.RouteParameters().Interface: %w", err)
+	}
+
+	violations, err := schema.ValidateStruct(&params)
+	if err != nil {
+		return params, fmt.Errorf("schema.ValidateStruct: %w", err)
+	}
+	if len(violations) > 0 {
+		return params, violations
+	}
+
+	return params, nil
+}
+
+// ReplyOf returns the successful reply with the parameters converted from v.
+// If v can not be converted, then returns the failed reply.
+func ReplyOf[T any](req message.RequestInterface, v T) message.ReplyInterface {
+	params, err := key_value.NewFromInterface(v)
+	if err != nil {
+		return req.Fail(fmt.Sprintf("key_value.NewFromInterface: %v", err))
+	}
+	return req.Ok(params)
+}
+
+// Reply converts the parameters of the successful reply into T.
+// Use it on the client side.
+func Reply[T any](reply message.ReplyInterface) (T, error) {
+	var params T
+	if !reply.IsOK() {
+		return params, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+	if err := reply.ReplyParameters().Interface(&params); err != nil {
+		return params, fmt.Errorf("reply.ReplyParameters().Interface: %w", err)
+	}
+	return params, nil
+}
@@ -0,0 +1,88 @@
+package bind
+
+import (
+	"errors"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/service-lib/schema"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestBindSuite struct {
+	suite.Suite
+}
+
+type getLogs struct {
+	NetworkId string `json:"network_id" validate:"required"`
+	Limit     uint64 `json:"limit" validate:"min=1,max=1000"`
+}
+
+type logs struct {
+	NetworkId string   `json:"network_id"`
+	Logs      []string `json:"logs"`
+}
+
+func request(parameters key_value.KeyValue) *message.Request {
+	return &message.Request{Command: "get_logs", Parameters: parameters}
+}
+
+// Test_10_Request tests the parameters converted into the struct and validated
+func (test *TestBindSuite) Test_10_Request() {
+	s := test.Require
+
+	req := request(key_value.New().Set("network_id", "1").Set("limit", 10))
+	params, err := Request[getLogs](req)
+	s().NoError(err)
+	s().Equal("1", params.NetworkId)
+	s().Equal(uint64(10), params.Limit)
+
+	// all violations are returned
+	req = request(key_value.New().Set("limit", 1001))
+	_, err = Request[getLogs](req)
+	s().Error(err)
+	var violations schema.Violations
+	s().True(errors.As(err, &violations))
+	s().Len(violations, 2)
+
+	// the parameters of the wrong type are not converted
+	req = request(key_value.New().Set("network_id", 1))
+	_, err = Request[getLogs](req)
+	s().Error(err)
+	s().False(errors.As(err, &violations))
+
+	// the type must be a struct
+	_, err = Request[string](request(key_value.New()))
+	s().Error(err)
+}
+
+// Test_11_Reply tests the struct converted into the reply and back
+func (test *TestBindSuite) Test_11_Reply() {
+	s := test.Require
+
+	req := request(key_value.New())
+	reply := ReplyOf(req, logs{NetworkId: "1", Logs: []string{"a", "b"}})
+	s().True(reply.IsOK())
+
+	converted, err := Reply[logs](reply)
+	s().NoError(err)
+	s().Equal("1", converted.NetworkId)
+	s().Equal([]string{"a", "b"}, converted.Logs)
+
+	// the value that is not an object can not be the parameters
+	reply = ReplyOf(req, "logs")
+	s().False(reply.IsOK())
+
+	// the failed reply is an error
+	_, err = Reply[logs](req.Fail("not found"))
+	s().ErrorContains(err, "not found")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestBind(t *testing.T) {
+	suite.Run(t, new(TestBindSuite))
+}
@@ -0,0 +1,154 @@
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Forbidden is the prefix of the error message when the identity is not authorized for the topic
+const Forbidden = "topic_forbidden"
+
+// KeySize is the length of the z85 encoded CURVE public key
+const KeySize = 40
+
+// ForbiddenError is returned when the identity is not authorized to publish or subscribe to the topic
+type ForbiddenError struct {
+	Identity string
+	Topic    string
+	Action   string // publish or subscribe
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("%s: '%s' can not %s the '%s' topic", Forbidden, e.Identity, e.Action, e.Topic)
+}
+
+// IsForbidden returns true if the error is about the unauthorized topic
+func IsForbidden(err error) bool {
+	var forbidden *ForbiddenError
+	if errors.As(err, &forbidden) {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), Forbidden+":")
+}
+
+// ACL authorizes the CURVE identities to publish and subscribe to the topics.
+//
+// The topics are matched by the prefix, like the ZeroMQ subscriptions:
+// the protected "config" topic covers "config" and "config.update".
+// The topics not covered by any rule are open to all identities.
+// The identities are the z85 encoded CURVE public keys of the services.
+type ACL struct {
+	publishers  map[string][]string // topic => public keys
+	subscribers map[string][]string // topic => public keys
+	mu          sync.RWMutex
+}
+
+// NewACL returns the ACL without the protected topics
+func NewACL() *ACL {
+	return &ACL{
+		publishers:  make(map[string][]string),
+		subscribers: make(map[string][]string),
+	}
+}
+
+func validateKeys(keys []string) error {
+	for _, key := range keys {
+		if len(key) != KeySize {
+			return fmt.Errorf("the '%s' key must be %d characters of z85, not %d", key, KeySize, len(key))
+		}
+	}
+	return nil
+}
+
+func allow(rules map[string][]string, topic string, keys []string) {
+	for _, key := range keys {
+		if !slices.Contains(rules[topic], key) {
+			rules[topic] = append(rules[topic], key)
+		}
+	}
+	if _, ok := rules[topic]; !ok {
+		rules[topic] = []string{}
+	}
+}
+
+// AllowPublish protects the topic, so only the identities are allowed to publish it.
+// Without the keys, no one is allowed to publish it.
+func (acl *ACL) AllowPublish(topic string, keys ...string) error {
+	if err := validateKeys(keys); err != nil {
+		return err
+	}
+
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	allow(acl.publishers, topic, keys)
+	return nil
+}
+
+// AllowSubscribe protects the topic, so only the identities are allowed to subscribe to it.
+// Without the keys, no one is allowed to subscribe to it.
+func (acl *ACL) AllowSubscribe(topic string, keys ...string) error {
+	if err := validateKeys(keys); err != nil {
+		return err
+	}
+
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	allow(acl.subscribers, topic, keys)
+	return nil
+}
+
+// CanPublish returns ForbiddenError if the identity is not allowed to publish the topic.
+// Every protected topic covering the topic must allow the identity.
+// The nil ACL allows everything.
+func (acl *ACL) CanPublish(key string, topic string) error {
+	if acl == nil {
+		return nil
+	}
+
+	acl.mu.RLock()
+	defer acl.mu.RUnlock()
+
+	for protected, keys := range acl.publishers {
+		if strings.HasPrefix(topic, protected) && !slices.Contains(keys, key) {
+			return &ForbiddenError{Identity: key, Topic: topic, Action: "publish"}
+		}
+	}
+	return nil
+}
+
+// CanSubscribe returns ForbiddenError if the identity is not allowed to subscribe to the topic.
+// The subscription receives all topics starting with it,
+// so every protected topic it would receive must allow the identity.
+// The nil ACL allows everything.
+func (acl *ACL) CanSubscribe(key string, subscription string) error {
+	if acl == nil {
+		return nil
+	}
+
+	acl.mu.RLock()
+	defer acl.mu.RUnlock()
+
+	for protected, keys := range acl.subscribers {
+		covered := strings.HasPrefix(subscription, protected) || strings.HasPrefix(protected, subscription)
+		if covered && !slices.Contains(keys, key) {
+			return &ForbiddenError{Identity: key, Topic: protected, Action: "subscribe"}
+		}
+	}
+	return nil
+}
+
+// Subscribers returns the identities allowed to subscribe to the protected topic.
+// Returns false if the topic is not protected.
+// The publisher authorizes the subscriptions by CanSubscribe, use it to list the keys.
+func (acl *ACL) Subscribers(topic string) ([]string, bool) {
+	acl.mu.RLock()
+	defer acl.mu.RUnlock()
+
+	keys, ok := acl.subscribers[topic]
+	return slices.Clone(keys), ok
+}
@@ -0,0 +1,301 @@
+package broadcast
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestBroadcastSuite struct {
+	suite.Suite
+
+	topic string
+}
+
+func (test *TestBroadcastSuite) SetupTest() {
+	test.topic = "block"
+}
+
+// Test_10_Feed tests the sequence numbers and the range of the feed
+func (test *TestBroadcastSuite) Test_10_Feed() {
+	s := test.Require
+
+	feed := NewFeed(3)
+	for i := 0; i < 5; i++ {
+		sequenced, err := feed.Next(test.topic, map[string]interface{}{"index": i})
+		s().NoError(err)
+		s().Equal(uint64(i+1), sequenced.Seq)
+	}
+	last, err := feed.Last(test.topic)
+	s().NoError(err)
+	s().Equal(uint64(5), last)
+	last, err = feed.Last("other")
+	s().NoError(err)
+	s().Zero(last)
+
+	// the first two broadcasts are evicted
+	_, err = feed.Range(test.topic, 1, 5)
+	s().Error(err)
+
+	broadcasts, err := feed.Range(test.topic, 3, 4)
+	s().NoError(err)
+	s().Len(broadcasts, 2)
+	s().Equal(uint64(3), broadcasts[0].Seq)
+	s().Equal(uint64(4), broadcasts[1].Seq)
+
+	// till the last one
+	broadcasts, err = feed.Range(test.topic, 4, 0)
+	s().NoError(err)
+	s().Len(broadcasts, 2)
+
+	// nothing new
+	broadcasts, err = feed.Range(test.topic, 6, 0)
+	s().NoError(err)
+	s().Empty(broadcasts)
+}
+
+// Test_11_Tracker tests the duplicates and the gaps on the subscriber side
+func (test *TestBroadcastSuite) Test_11_Tracker() {
+	s := test.Require
+
+	feed := NewFeed(0)
+	tracker := NewTracker()
+
+	first, err := feed.Next(test.topic, nil)
+	s().NoError(err)
+	accepted, gap := tracker.Accept(first)
+	s().True(accepted)
+	s().Nil(gap)
+
+	// duplicate
+	accepted, gap = tracker.Accept(first)
+	s().False(accepted)
+	s().Nil(gap)
+
+	_, err = feed.Next(test.topic, nil)
+	s().NoError(err)
+	_, err = feed.Next(test.topic, nil)
+	s().NoError(err)
+	fourth, err := feed.Next(test.topic, nil)
+	s().NoError(err)
+
+	accepted, gap = tracker.Accept(fourth)
+	s().False(accepted)
+	s().NotNil(gap)
+	s().Equal(uint64(2), gap.From)
+	s().Equal(uint64(3), gap.To)
+
+	missed, err := feed.Range(gap.Topic, gap.From, gap.To)
+	s().NoError(err)
+	for _, sequenced := range missed {
+		accepted, gap = tracker.Accept(sequenced)
+		s().True(accepted)
+		s().Nil(gap)
+	}
+
+	accepted, gap = tracker.Accept(fourth)
+	s().True(accepted)
+	s().Nil(gap)
+	s().Equal(uint64(4), tracker.Last(test.topic))
+
+	tracker.Skip(test.topic, 10)
+	s().Equal(uint64(10), tracker.Last(test.topic))
+}
+
+// Test_12_Journal tests the persisted broadcasts and the replay
+func (test *TestBroadcastSuite) Test_12_Journal() {
+	s := test.Require
+
+	dir := test.T().TempDir()
+	journal, err := NewJournal(dir, 2)
+	s().NoError(err)
+
+	feed := NewFeed(1)
+	feed.SetJournal(journal)
+	for i := 0; i < 5; i++ {
+		_, err := feed.Next(test.topic, map[string]interface{}{"index": float64(i)})
+		s().NoError(err)
+	}
+
+	// the in-memory history is bounded by 1, but the journal keeps more
+	_, err = feed.Range(test.topic, 4, 5)
+	s().Error(err)
+	broadcasts, err := feed.Replay(test.topic, 4, 0, 0)
+	s().NoError(err)
+	s().Len(broadcasts, 2)
+	s().Equal(float64(3), broadcasts[0].Parameters["index"])
+
+	// the journal was compacted after exceeding twice the retention
+	broadcasts, err = feed.Replay(test.topic, 1, 3, 0)
+	s().NoError(err)
+	s().Empty(broadcasts)
+
+	// the restarted feed continues the sequence numbers
+	journal, err = NewJournal(dir, 2)
+	s().NoError(err)
+	feed = NewFeed(1)
+	feed.SetJournal(journal)
+	last, err := feed.Last(test.topic)
+	s().NoError(err)
+	s().Equal(uint64(5), last)
+	sequenced, err := feed.Next(test.topic, nil)
+	s().NoError(err)
+	s().Equal(uint64(6), sequenced.Seq)
+}
+
+// Test_13_ACL tests the topics authorized by the CURVE identities
+func (test *TestBroadcastSuite) Test_13_ACL() {
+	s := test.Require
+
+	config := strings.Repeat("c", KeySize)
+	main := strings.Repeat("m", KeySize)
+	other := strings.Repeat("o", KeySize)
+
+	acl := NewACL()
+	s().Error(acl.AllowPublish("config", "short"))
+	s().NoError(acl.AllowPublish("config", config))
+	s().NoError(acl.AllowSubscribe("shutdown", main))
+
+	// the protected topic covers the topics starting with it
+	s().NoError(acl.CanPublish(config, "config.update"))
+	s().True(IsForbidden(acl.CanPublish(main, "config.update")))
+	s().NoError(acl.CanPublish(main, test.topic))
+
+	s().NoError(acl.CanSubscribe(main, "shutdown"))
+	s().True(IsForbidden(acl.CanSubscribe(other, "shutdown.now")))
+	// the subscription to all topics includes the protected ones
+	s().True(IsForbidden(acl.CanSubscribe(other, "")))
+	s().NoError(acl.CanSubscribe(other, test.topic))
+
+	keys, ok := acl.Subscribers("shutdown")
+	s().True(ok)
+	s().Equal([]string{main}, keys)
+
+	var empty *ACL
+	s().NoError(empty.CanPublish(other, "config"))
+
+	// the feed refuses the topics of the other publishers
+	feed := NewFeed(0)
+	feed.SetACL(acl, main)
+	_, err := feed.Next("config", map[string]interface{}{})
+	s().True(IsForbidden(err))
+	sequenced, err := feed.Next(test.topic, map[string]interface{}{})
+	s().NoError(err)
+	s().Equal(uint64(1), sequenced.Seq)
+}
+
+// Test_14_JournalIndex tests the replay by the indexed offsets and the limit
+func (test *TestBroadcastSuite) Test_14_JournalIndex() {
+	s := test.Require
+
+	dir := test.T().TempDir()
+	journal, err := NewJournal(dir, 4)
+	s().NoError(err)
+
+	last, err := journal.Last(test.topic)
+	s().NoError(err)
+	s().Zero(last)
+	broadcasts, err := journal.Replay(test.topic, 1, 0, 0)
+	s().NoError(err)
+	s().Empty(broadcasts)
+
+	// the parameters of different sizes shift the offsets
+	for seq := uint64(1); seq <= 6; seq++ {
+		s().NoError(journal.Append(Sequenced{Topic: test.topic, Seq: seq, Parameters: map[string]interface{}{"data": strings.Repeat("x", int(seq))}}))
+	}
+	last, err = journal.Last(test.topic)
+	s().NoError(err)
+	s().Equal(uint64(6), last)
+
+	broadcasts, err = journal.Replay(test.topic, 2, 4, 0)
+	s().NoError(err)
+	s().Len(broadcasts, 3)
+	s().Equal(uint64(2), broadcasts[0].Seq)
+	s().Equal("xxxx", broadcasts[2].Parameters["data"])
+
+	// the limit returns the first broadcasts of the range
+	broadcasts, err = journal.Replay(test.topic, 3, 0, 2)
+	s().NoError(err)
+	s().Len(broadcasts, 2)
+	s().Equal(uint64(3), broadcasts[0].Seq)
+	s().Equal(uint64(4), broadcasts[1].Seq)
+	broadcasts, err = journal.Replay(test.topic, 7, 0, 2)
+	s().NoError(err)
+	s().Empty(broadcasts)
+
+	// the offsets are shifted by the compaction
+	for seq := uint64(7); seq <= 9; seq++ {
+		s().NoError(journal.Append(Sequenced{Topic: test.topic, Seq: seq}))
+	}
+	broadcasts, err = journal.Replay(test.topic, 1, 0, 0)
+	s().NoError(err)
+	s().Len(broadcasts, 4)
+	s().Equal(uint64(6), broadcasts[0].Seq)
+	s().Equal(uint64(9), broadcasts[3].Seq)
+
+	// the file changed by anyone else is indexed again, dropping the partially written line
+	path := journal.path(test.topic)
+	data, err := os.ReadFile(path)
+	s().NoError(err)
+	lines := strings.SplitAfter(string(data), "\n")
+	s().NoError(os.WriteFile(path, []byte(lines[0]+lines[1]+`{"topic":"blo`), 0644))
+	last, err = journal.Last(test.topic)
+	s().NoError(err)
+	s().Equal(uint64(7), last)
+	s().NoError(journal.Append(Sequenced{Topic: test.topic, Seq: 8}))
+	broadcasts, err = journal.Replay(test.topic, 0, 0, 0)
+	s().NoError(err)
+	s().Len(broadcasts, 3)
+	s().Equal(uint64(8), broadcasts[2].Seq)
+
+	// the oversized broadcast is rejected, the topic is still replayed
+	err = journal.Append(Sequenced{Topic: test.topic, Seq: 9, Parameters: map[string]interface{}{"data": strings.Repeat("x", maxLineSize)}})
+	s().ErrorContains(err, "exceeds")
+	s().NoError(journal.Append(Sequenced{Topic: test.topic, Seq: 9}))
+	broadcasts, err = journal.Replay(test.topic, 8, 0, 0)
+	s().NoError(err)
+	s().Len(broadcasts, 2)
+	s().Equal(uint64(9), broadcasts[1].Seq)
+
+	// the feed without the journal limits the range
+	feed := NewFeed(10)
+	for i := 0; i < 5; i++ {
+		_, err := feed.Next(test.topic, nil)
+		s().NoError(err)
+	}
+	broadcasts, err = feed.Replay(test.topic, 2, 0, 2)
+	s().NoError(err)
+	s().Len(broadcasts, 2)
+	s().Equal(uint64(3), broadcasts[1].Seq)
+}
+
+// Test_15_FeedCanSubscribe tests authorizing the catch-up of the protected topics by the feed's ACL
+func (test *TestBroadcastSuite) Test_15_FeedCanSubscribe() {
+	s := test.Require
+
+	feed := NewFeed(1)
+	s().NoError(feed.CanSubscribe("", "config"))
+
+	allowed := strings.Repeat("a", KeySize)
+	acl := NewACL()
+	s().NoError(acl.AllowSubscribe("config", allowed))
+	feed.SetACL(acl, strings.Repeat("p", KeySize))
+
+	s().NoError(feed.CanSubscribe(allowed, "config.update"))
+	s().NoError(feed.CanSubscribe("", test.topic))
+	err := feed.CanSubscribe(strings.Repeat("o", KeySize), "config")
+	s().True(IsForbidden(err))
+
+	// the caller without the identity gets only the open topics
+	s().True(IsForbidden(feed.CanSubscribe("", "config")))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestBroadcast(t *testing.T) {
+	suite.Run(t, new(TestBroadcastSuite))
+}
@@ -0,0 +1,194 @@
+// Package broadcast turns the fire-and-forget broadcasts into a reliable feed.
+//
+// The publisher assigns the sequence number per topic by Feed.Next.
+// The feed keeps the recent broadcasts, so the subscribers that were offline
+// can request the missed broadcasts by a sequence range.
+//
+// The subscriber passes the received broadcasts through the Tracker.
+// The tracker drops the duplicates and detects the missed broadcasts.
+//
+// When the broadcast sockets use the CURVE identities, the ACL authorizes the topics:
+// the feed refuses to sequence the topics its identity may not publish, see Feed.SetACL.
+// The publisher.Publisher sets the ACL of its feed, and doesn't send the topics to the forbidden subscribers.
+package broadcast
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultLimit is the number of the recent broadcasts kept per topic by default
+const DefaultLimit = 1024
+
+// Sequenced is the broadcast with the sequence number
+type Sequenced struct {
+	Topic      string                 `json:"topic"`
+	Seq        uint64                 `json:"seq"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// Feed assigns the sequence numbers and keeps the recent broadcasts per topic
+type Feed struct {
+	limit   int
+	seqs    map[string]uint64
+	history map[string][]Sequenced
+	journal *Journal // persists the broadcasts, optional
+	acl     *ACL     // authorizes the topics of the identity, optional
+	key     string   // the CURVE public key of the publisher
+	mu      sync.RWMutex
+}
+
+// NewFeed returns a feed that keeps the limit of the recent broadcasts per topic.
+// If the limit is 0, then DefaultLimit is used.
+func NewFeed(limit int) *Feed {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return &Feed{
+		limit:   limit,
+		seqs:    make(map[string]uint64),
+		history: make(map[string][]Sequenced),
+	}
+}
+
+// SetJournal persists the broadcasts of the feed in the journal.
+// The sequence numbers of the topics continue from the journal.
+func (feed *Feed) SetJournal(journal *Journal) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	feed.journal = journal
+}
+
+// SetACL authorizes the topics of the feed by the ACL.
+// The key is the CURVE public key of the publisher's broadcast socket.
+func (feed *Feed) SetACL(acl *ACL, key string) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	feed.acl = acl
+	feed.key = key
+}
+
+// CanSubscribe returns ForbiddenError if the ACL of the feed doesn't allow the identity to receive the topic.
+// The catch-up and the replay of the journaled broadcasts are authorized by it,
+// the same way the publisher authorizes the live subscriptions.
+// The feed without the ACL allows everything.
+func (feed *Feed) CanSubscribe(key string, topic string) error {
+	feed.mu.RLock()
+	acl := feed.acl
+	feed.mu.RUnlock()
+
+	return acl.CanSubscribe(key, topic)
+}
+
+// lastSeq returns the last sequence number of the topic.
+// If the topic has no broadcasts in the feed, then it's taken from the journal.
+func (feed *Feed) lastSeq(topic string) (uint64, error) {
+	seq, ok := feed.seqs[topic]
+	if ok || feed.journal == nil {
+		return seq, nil
+	}
+
+	seq, err := feed.journal.Last(topic)
+	if err != nil {
+		return 0, fmt.Errorf("journal.Last('%s'): %w", topic, err)
+	}
+	return seq, nil
+}
+
+// Next assigns the next sequence number of the topic to the broadcast parameters.
+// The returned broadcast is stored in the feed, publish it by the broadcast socket.
+//
+// Returns an error if the feed has a journal and it failed to persist the broadcast,
+// or ForbiddenError if the ACL doesn't allow the publisher the topic.
+func (feed *Feed) Next(topic string, parameters map[string]interface{}) (Sequenced, error) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	if feed.acl != nil {
+		if err := feed.acl.CanPublish(feed.key, topic); err != nil {
+			return Sequenced{}, err
+		}
+	}
+
+	last, err := feed.lastSeq(topic)
+	if err != nil {
+		return Sequenced{}, fmt.Errorf("feed.lastSeq: %w", err)
+	}
+
+	sequenced := Sequenced{Topic: topic, Seq: last + 1, Parameters: parameters}
+	if feed.journal != nil {
+		if err := feed.journal.Append(sequenced); err != nil {
+			return Sequenced{}, fmt.Errorf("journal.Append: %w", err)
+		}
+	}
+	feed.seqs[topic] = sequenced.Seq
+
+	history := append(feed.history[topic], sequenced)
+	if len(history) > feed.limit {
+		history = history[len(history)-feed.limit:]
+	}
+	feed.history[topic] = history
+
+	return sequenced, nil
+}
+
+// Replay returns the broadcasts of the topic from the journal, at most limit if the limit is more than 0.
+// If the feed has no journal, then it's identical to Range.
+func (feed *Feed) Replay(topic string, from uint64, to uint64, limit int) ([]Sequenced, error) {
+	feed.mu.RLock()
+	journal := feed.journal
+	feed.mu.RUnlock()
+
+	if journal != nil {
+		return journal.Replay(topic, from, to, limit)
+	}
+	broadcasts, err := feed.Range(topic, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(broadcasts) > limit {
+		broadcasts = broadcasts[:limit]
+	}
+	return broadcasts, nil
+}
+
+// Last returns the sequence number of the last broadcast in the topic.
+// If the topic has no broadcasts since the start, then it's taken from the journal, see SetJournal.
+//
+// Returns an error if the journal failed to read the topic.
+func (feed *Feed) Last(topic string) (uint64, error) {
+	feed.mu.RLock()
+	defer feed.mu.RUnlock()
+
+	return feed.lastSeq(topic)
+}
+
+// Range returns the broadcasts of the topic with the sequence numbers between from and to inclusive.
+// If the to is 0, then returns till the last broadcast.
+//
+// Returns an error if the broadcasts in the range were evicted from the feed.
+func (feed *Feed) Range(topic string, from uint64, to uint64) ([]Sequenced, error) {
+	feed.mu.RLock()
+	defer feed.mu.RUnlock()
+
+	last := feed.seqs[topic]
+	if to == 0 || to > last {
+		to = last
+	}
+	if from == 0 {
+		from = 1
+	}
+	if from > to {
+		return []Sequenced{}, nil
+	}
+
+	history := feed.history[topic]
+	if len(history) == 0 || history[0].Seq > from || history[len(history)-1].Seq < to {
+		return nil, fmt.Errorf("the broadcasts of '%s' topic from %d are evicted", topic, from)
+	}
+
+	first := history[0].Seq
+	return append([]Sequenced{}, history[from-first:to-first+1]...), nil
+}
@@ -0,0 +1,294 @@
+package broadcast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/service-lib/codec"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DefaultRetention is the number of the broadcasts kept per topic in the journal by default
+const DefaultRetention = 100_000
+
+// maxLineSize is the largest broadcast in the journal, in bytes
+const maxLineSize = 16 * 1024 * 1024
+
+// Journal persists the broadcasts on the disk, one file per topic.
+// The journal keeps the last retention broadcasts per topic.
+//
+// New subscribers replay the journal to rebuild their state without waiting for the live broadcasts.
+//
+// The journal indexes the offsets of the broadcasts in the topic files,
+// so the replay reads only the requested broadcasts.
+// The topic file is indexed once on the first access, and again if it was changed by anyone else,
+// for example, by restoring the snapshot.
+type Journal struct {
+	dir       string
+	retention int
+	indexes   map[string]*index // the offsets of the broadcasts by the topic
+	mu        sync.Mutex
+}
+
+// index of the topic file.
+// The sequence numbers in the file are increasing.
+type index struct {
+	seqs    []uint64
+	offsets []int64 // the offsets of the lines in the file
+	size    int64   // the size of the indexed file
+}
+
+// NewJournal returns the journal that stores the broadcasts in the dir.
+// If the retention is 0, then DefaultRetention is used.
+func NewJournal(dir string, retention int) (*Journal, error) {
+	if len(dir) == 0 {
+		return nil, fmt.Errorf("the 'dir' parameter is empty")
+	}
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll('%s'): %w", dir, err)
+	}
+
+	return &Journal{dir: dir, retention: retention, indexes: make(map[string]*index)}, nil
+}
+
+func (journal *Journal) path(topic string) string {
+	return filepath.Join(journal.dir, url.QueryEscape(topic)+".jsonl")
+}
+
+// index returns the index of the topic file.
+// The file is read only if it's not indexed yet, or its size doesn't match the index.
+func (journal *Journal) index(topic string) (*index, error) {
+	info, err := os.Stat(journal.path(topic))
+	if err != nil {
+		if os.IsNotExist(err) {
+			idx := &index{}
+			journal.indexes[topic] = idx
+			return idx, nil
+		}
+		return nil, fmt.Errorf("os.Stat: %w", err)
+	}
+	if idx, ok := journal.indexes[topic]; ok && idx.size == info.Size() {
+		return idx, nil
+	}
+
+	f, err := os.Open(journal.path(topic))
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	idx := &index{}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reader.ReadBytes: %w", err)
+		}
+		var header struct {
+			Seq uint64 `json:"seq"`
+		}
+		if err := json.Unmarshal(line, &header); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal(line %d): %w", len(idx.seqs)+1, err)
+		}
+		idx.seqs = append(idx.seqs, header.Seq)
+		idx.offsets = append(idx.offsets, idx.size)
+		idx.size += int64(len(line))
+	}
+
+	// the partially written line is dropped, the next broadcast is appended after the last complete line
+	if idx.size < info.Size() {
+		if err := os.Truncate(journal.path(topic), idx.size); err != nil {
+			return nil, fmt.Errorf("os.Truncate: %w", err)
+		}
+	}
+
+	journal.indexes[topic] = idx
+	return idx, nil
+}
+
+// Append writes the broadcast into the journal.
+// The broadcast larger than 16MB encoded is rejected.
+// When the journal of the topic exceeds twice the retention, the old broadcasts are removed.
+func (journal *Journal) Append(sequenced Sequenced) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	line, err := codec.Marshal(sequenced)
+	if err != nil {
+		return fmt.Errorf("codec.Marshal: %w", err)
+	}
+	line = append(line, '\n')
+	// the replay couldn't read the topic past the larger line
+	if len(line) > maxLineSize {
+		return fmt.Errorf("the broadcast of '%s' topic is %d bytes, exceeds %d bytes", sequenced.Topic, len(line), maxLineSize)
+	}
+
+	idx, err := journal.index(sequenced.Topic)
+	if err != nil {
+		return fmt.Errorf("journal.index('%s'): %w", sequenced.Topic, err)
+	}
+
+	f, err := os.OpenFile(journal.path(sequenced.Topic), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+	_, err = f.WriteAt(line, idx.size)
+	closeErr := f.Close()
+	if err != nil {
+		delete(journal.indexes, sequenced.Topic)
+		return fmt.Errorf("f.WriteAt: %w", err)
+	}
+	if closeErr != nil {
+		delete(journal.indexes, sequenced.Topic)
+		return fmt.Errorf("f.Close: %w", closeErr)
+	}
+
+	idx.seqs = append(idx.seqs, sequenced.Seq)
+	idx.offsets = append(idx.offsets, idx.size)
+	idx.size += int64(len(line))
+
+	if len(idx.seqs) > journal.retention*2 {
+		if err := journal.compact(sequenced.Topic, idx); err != nil {
+			return fmt.Errorf("journal.compact('%s'): %w", sequenced.Topic, err)
+		}
+	}
+
+	return nil
+}
+
+// compact keeps only the last retention broadcasts of the topic.
+// The kept lines are copied as they are, starting from their offset.
+func (journal *Journal) compact(topic string, idx *index) error {
+	if len(idx.seqs) <= journal.retention {
+		return nil
+	}
+	first := len(idx.seqs) - journal.retention
+	start := idx.offsets[first]
+
+	filePath := journal.path(topic)
+	src, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("os.Open('%s'): %w", filePath, err)
+	}
+
+	tmpPath := filePath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		_ = src.Close()
+		return fmt.Errorf("os.OpenFile('%s'): %w", tmpPath, err)
+	}
+	_, err = io.Copy(f, io.NewSectionReader(src, start, idx.size-start))
+	// the source is closed before it's replaced
+	_ = src.Close()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("io.Copy: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("f.Close: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("os.Rename('%s'): %w", tmpPath, err)
+	}
+
+	compacted := &index{
+		seqs:    append([]uint64{}, idx.seqs[first:]...),
+		offsets: make([]int64, 0, journal.retention),
+		size:    idx.size - start,
+	}
+	for _, offset := range idx.offsets[first:] {
+		compacted.offsets = append(compacted.offsets, offset-start)
+	}
+	journal.indexes[topic] = compacted
+	return nil
+}
+
+// Last returns the sequence number of the last broadcast of the topic in the journal
+func (journal *Journal) Last(topic string) (uint64, error) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	idx, err := journal.index(topic)
+	if err != nil {
+		return 0, fmt.Errorf("journal.index('%s'): %w", topic, err)
+	}
+	if len(idx.seqs) == 0 {
+		return 0, nil
+	}
+	return idx.seqs[len(idx.seqs)-1], nil
+}
+
+// Replay returns the broadcasts of the topic with the sequence numbers between from and to inclusive.
+// If the to is 0, then returns till the last broadcast.
+// If the limit is more than 0, then at most limit broadcasts are returned,
+// the caller continues from the sequence number after the last returned one.
+//
+// The file is read from the first broadcast in the range.
+func (journal *Journal) Replay(topic string, from uint64, to uint64, limit int) ([]Sequenced, error) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	idx, err := journal.index(topic)
+	if err != nil {
+		return nil, fmt.Errorf("journal.index('%s'): %w", topic, err)
+	}
+
+	first := sort.Search(len(idx.seqs), func(i int) bool {
+		return idx.seqs[i] >= from
+	})
+	last := len(idx.seqs)
+	if to != 0 {
+		last = sort.Search(len(idx.seqs), func(i int) bool {
+			return idx.seqs[i] > to
+		})
+	}
+	if limit > 0 && last-first > limit {
+		last = first + limit
+	}
+	if first >= last {
+		return []Sequenced{}, nil
+	}
+
+	f, err := os.Open(journal.path(topic))
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	start := idx.offsets[first]
+	end := idx.size
+	if last < len(idx.offsets) {
+		end = idx.offsets[last]
+	}
+	scanner := bufio.NewScanner(io.NewSectionReader(f, start, end-start))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	broadcasts := make([]Sequenced, 0, last-first)
+	for scanner.Scan() {
+		var sequenced Sequenced
+		if err := json.Unmarshal(scanner.Bytes(), &sequenced); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal(line %d): %w", first+len(broadcasts)+1, err)
+		}
+		broadcasts = append(broadcasts, sequenced)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner.Err: %w", err)
+	}
+
+	return broadcasts, nil
+}
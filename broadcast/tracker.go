@@ -0,0 +1,64 @@
+package broadcast
+
+import "sync"
+
+// Gap is the range of the missed broadcasts to request from the publisher
+type Gap struct {
+	Topic string
+	From  uint64
+	To    uint64
+}
+
+// Tracker keeps the last received sequence number per topic on the subscriber side
+type Tracker struct {
+	last map[string]uint64
+	mu   sync.Mutex
+}
+
+// NewTracker returns a tracker with no received broadcasts
+func NewTracker() *Tracker {
+	return &Tracker{last: make(map[string]uint64)}
+}
+
+// Accept returns true if the broadcast must be processed.
+// The duplicates and the older broadcasts are not accepted, so each broadcast is processed exactly once.
+//
+// If some broadcasts were missed before this broadcast, then it's not accepted, and the gap is returned.
+// Request the gap by the catch-up command, pass the missed broadcasts into Accept,
+// then pass this broadcast again.
+// If the missed broadcasts can not be fetched, call Skip to continue from this broadcast.
+func (tracker *Tracker) Accept(sequenced Sequenced) (bool, *Gap) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	last, ok := tracker.last[sequenced.Topic]
+	if ok && sequenced.Seq <= last {
+		return false, nil
+	}
+
+	// the first broadcast sets the starting point.
+	if ok && sequenced.Seq > last+1 {
+		return false, &Gap{Topic: sequenced.Topic, From: last + 1, To: sequenced.Seq - 1}
+	}
+	tracker.last[sequenced.Topic] = sequenced.Seq
+
+	return true, nil
+}
+
+// Skip marks the broadcasts of the topic till the seq inclusive as received.
+func (tracker *Tracker) Skip(topic string, seq uint64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if seq > tracker.last[topic] {
+		tracker.last[topic] = seq
+	}
+}
+
+// Last returns the sequence number of the last accepted broadcast in the topic
+func (tracker *Tracker) Last(topic string) uint64 {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	return tracker.last[topic]
+}
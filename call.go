@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/ahmetson/client-lib"
+	clientConfig "github.com/ahmetson/client-lib/config"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/service-lib/fanout"
+	"github.com/ahmetson/service-lib/manager"
+	"github.com/ahmetson/service-lib/replycache"
+	"github.com/ahmetson/service-lib/timestamp"
+	"slices"
+	"time"
+)
+
+// CallOptions of Service.Call
+type CallOptions struct {
+	Timeout  time.Duration // of each attempt
+	Attempts int           // the failed attempt is retried with a new socket
+}
+
+// DefaultCallOptions returns the options used by Service.Call
+func DefaultCallOptions() CallOptions {
+	return CallOptions{Timeout: time.Second * 10, Attempts: 3}
+}
+
+// Call sends the command to the service by its url, and returns the successful reply.
+//
+// If this service has a proxy chain to the target that matches the command,
+// then the request is sent to the first proxy in the chain.
+// Otherwise, the request is sent directly to the handler of the target that has the command.
+//
+// The clients are cached, see CallWith for the timeouts and retries.
+// The parameters identify this service as the source of the request, see quota.Param.
+// The request is stamped with the send time, and the stamped replies estimate the target's clock offset, see ClockOffset.
+func (independent *Service) Call(targetUrl string, command string, parameters key_value.KeyValue) (message.ReplyInterface, error) {
+	return independent.CallWith(targetUrl, command, parameters, DefaultCallOptions())
+}
+
+// CallWith is Call with the custom timeout and attempts.
+func (independent *Service) CallWith(targetUrl string, command string, parameters key_value.KeyValue, options CallOptions) (message.ReplyInterface, error) {
+	if options.Attempts < 1 {
+		options.Attempts = 1
+	}
+
+	sourced := independent.withSource(parameters)
+
+	// the replies with the cache hint are kept, see EnableCallCache
+	cacheKey := ""
+	var cached replycache.Entry[message.ReplyInterface]
+	stale := false
+	if independent.callCache != nil {
+		key, err := replycache.Key(targetUrl+" "+command, parameters.Map())
+		if err != nil {
+			return nil, fmt.Errorf("replycache.Key: %w", err)
+		}
+		if reply, ok := independent.callCache.Get(key); ok {
+			return reply, nil
+		}
+		cacheKey = key
+		cached, stale = independent.callCache.Lookup(key)
+		if stale && len(cached.Hint.Etag) > 0 {
+			sourced.Set(replycache.EtagParam, cached.Hint.Etag)
+		}
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= options.Attempts; attempt++ {
+		c, err := independent.resolveCall(targetUrl, command)
+		if err != nil {
+			return nil, fmt.Errorf("resolveCall('%s', '%s'): %w", targetUrl, command, err)
+		}
+
+		sent := timestamp.Now()
+		req := &message.Request{Command: command, Parameters: sourced.Set(timestamp.SentParam, sent)}
+		var reply message.ReplyInterface
+		lastErr = withTimeout(command, options.Timeout, func() error {
+			var requestErr error
+			reply, requestErr = c.Request(req)
+			return requestErr
+		})
+		if lastErr != nil {
+			// the request-reply socket is broken after the failed request.
+			// the timed out request may be still pending, the client is closed after it returns.
+			independent.dropCallClient(targetUrl, command, c)
+			continue
+		}
+		if !reply.IsOK() {
+			return reply, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+		}
+		if sample, ok := timestamp.NewSample(sent, reply.ReplyParameters().Map()); ok {
+			independent.clocks.Add(targetUrl, sample)
+		}
+		if len(cacheKey) > 0 {
+			hint, hinted := replycache.ParseHint(reply.ReplyParameters().Map())
+			if stale && replycache.NotModified(reply.ReplyParameters().Map()) {
+				independent.callCache.Refresh(cacheKey, hint)
+				return cached.Value, nil
+			}
+			if hinted {
+				independent.callCache.Put(cacheKey, reply, hint)
+			}
+		}
+		return reply, nil
+	}
+
+	return nil, fmt.Errorf("%d attempts failed: %w", options.Attempts, lastErr)
+}
+
+// CallAs sends the command by Service.Call and decodes the reply parameters into the type.
+func CallAs[T any](independent *Service, targetUrl string, command string, parameters key_value.KeyValue) (T, error) {
+	var result T
+	reply, err := independent.Call(targetUrl, command, parameters)
+	if err != nil {
+		return result, err
+	}
+	if err := reply.ReplyParameters().Interface(&result); err != nil {
+		return result, fmt.Errorf("reply.ReplyParameters().Interface: %w", err)
+	}
+	return result, nil
+}
+
+// EnableCallCache keeps the replies of Call that have the cache hint of the target, see replycache.Respond.
+// The kept replies are returned without calling the target until their ttl passes, then revalidated by the etag.
+// The limit is the amount of the kept replies, zero means replycache.Limit.
+func (independent *Service) EnableCallCache(limit int) {
+	independent.callCache = replycache.NewCache[message.ReplyInterface](limit)
+}
+
+// InvalidateCalls removes the kept replies of Call invalidated by the broadcast topic
+func (independent *Service) InvalidateCalls(topic string) {
+	if independent.callCache != nil {
+		independent.callCache.Invalidate(topic)
+	}
+}
+
+// ClockOffset returns how much the clock of the service by the url is ahead of this service's clock.
+// It's estimated by the replies of Call from the routes wrapped by timestamp.Route.
+// Returns false if no stamped reply was received from the service.
+func (independent *Service) ClockOffset(targetUrl string) (time.Duration, bool) {
+	return independent.clocks.Offset(targetUrl)
+}
+
+func callKey(targetUrl, command string) string {
+	return targetUrl + "/" + command
+}
+
+// The resolveCall returns the cached client to the destination of the command, or connects to it.
+// The client is shared by the concurrent calls, see SyncClient.
+func (independent *Service) resolveCall(targetUrl string, command string) (*SyncClient, error) {
+	key := callKey(targetUrl, command)
+
+	independent.callMu.Lock()
+	defer independent.callMu.Unlock()
+
+	if c, ok := independent.callClients[key]; ok {
+		return c, nil
+	}
+
+	serviceUrl, handler, err := independent.proxyDestination(targetUrl, command)
+	if err != nil {
+		return nil, fmt.Errorf("proxyDestination: %w", err)
+	}
+	if handler == nil {
+		serviceUrl = targetUrl
+		handler, err = independent.directDestination(targetUrl, command)
+		if err != nil {
+			return nil, fmt.Errorf("directDestination: %w", err)
+		}
+	}
+
+	socket, err := clientTo(serviceUrl, handler)
+	if err != nil {
+		return nil, fmt.Errorf("clientTo: %w", err)
+	}
+	c := newSyncClient(socket)
+	independent.watchEndpoint("call:"+key, handler.Type, clientConfigTo(serviceUrl, handler).Url())
+	if independent.callClients == nil {
+		independent.callClients = make(map[string]*SyncClient)
+	}
+	independent.callClients[key] = c
+
+	return c, nil
+}
+
+// The clientTo returns the client of the handler
+func clientTo(serviceUrl string, handler *handlerConfig.Handler) (*client.Socket, error) {
+	return client.New(clientConfigTo(serviceUrl, handler))
+}
+
+// The clientConfigTo returns the configuration of the client of the handler
+func clientConfigTo(serviceUrl string, handler *handlerConfig.Handler) *clientConfig.Client {
+	c := clientConfig.New(serviceUrl, handler.Id, handler.Port, handlerConfig.SocketType(handler.Type))
+	c.UrlFunc(clientConfig.Url)
+	return c
+}
+
+// The proxyDestination returns the handler of the first proxy in the chain from this service to the target.
+// Returns nil if no proxy chain matches the command.
+func (independent *Service) proxyDestination(targetUrl string, command string) (string, *handlerConfig.Handler, error) {
+	proxyChains, err := independent.ctx.ProxyClient().ProxyChains()
+	if err != nil {
+		return "", nil, fmt.Errorf("proxyClient.ProxyChains: %w", err)
+	}
+
+	for _, proxyChain := range proxyChains {
+		if len(proxyChain.Proxies) == 0 || !matchCall(proxyChain, independent.url, targetUrl, command) {
+			continue
+		}
+
+		entry := proxyChain.Proxies[0]
+		proxyConf, err := independent.ctx.Config().Service(entry.Id)
+		if err != nil {
+			return "", nil, fmt.Errorf("ctx.Config().Service('%s'): %w", entry.Id, err)
+		}
+		handler, err := proxyConf.HandlerByCategory(entry.Category)
+		if err != nil {
+			return "", nil, fmt.Errorf("proxy('%s').HandlerByCategory('%s'): %w", entry.Id, entry.Category, err)
+		}
+		return entry.Url, handler, nil
+	}
+
+	return "", nil, nil
+}
+
+// The matchCall returns true if the command from the source to the target goes through the proxy chain
+func matchCall(proxyChain *serviceConfig.ProxyChain, sourceUrl string, targetUrl string, command string) bool {
+	if len(proxyChain.Sources) > 0 && !slices.Contains(proxyChain.Sources, sourceUrl) {
+		return false
+	}
+	rule := proxyChain.Destination
+	if rule == nil || !slices.Contains(rule.Urls, targetUrl) || slices.Contains(rule.ExcludedCommands, command) {
+		return false
+	}
+	return !rule.IsRoute() || slices.Contains(rule.Commands, command)
+}
+
+// The directDestination returns the handler of the target that has the command.
+// The target's manager is asked for the commands of its handlers.
+func (independent *Service) directDestination(targetUrl string, command string) (*handlerConfig.Handler, error) {
+	targetConf, err := independent.ctx.Config().ServiceByUrl(targetUrl)
+	if err != nil {
+		return nil, fmt.Errorf("ctx.Config().ServiceByUrl('%s'): %w", targetUrl, err)
+	}
+
+	managerClient, err := manager.NewClientByUrl(independent.ctx, targetUrl)
+	if err != nil {
+		return nil, fmt.Errorf("manager.NewClientByUrl: %w", err)
+	}
+	handlerCommands, err := managerClient.HandlerCommands()
+	_ = managerClient.Socket.Close()
+	if err != nil {
+		return nil, fmt.Errorf("managerClient.HandlerCommands: %w", err)
+	}
+
+	for category, commands := range handlerCommands {
+		if slices.Contains(commands, command) {
+			handler, err := targetConf.HandlerByCategory(category)
+			if err != nil {
+				return nil, fmt.Errorf("target.HandlerByCategory('%s'): %w", category, err)
+			}
+			return handler, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no handler of '%s' has '%s' command", targetUrl, command)
+}
+
+// The dropCallClient removes the failed client, so the next attempt reconnects.
+// If the concurrent call already replaced the client, then the new client is kept.
+// The failed client is closed in the background once its pending request returns.
+func (independent *Service) dropCallClient(targetUrl string, command string, failed *SyncClient) {
+	key := callKey(targetUrl, command)
+
+	independent.callMu.Lock()
+	if c, ok := independent.callClients[key]; ok && c == failed {
+		delete(independent.callClients, key)
+		independent.unwatchEndpoint("call:" + key)
+	}
+	independent.callMu.Unlock()
+
+	go func() {
+		_ = failed.Close()
+	}()
+}
+
+// The closeCallClients closes the clients of Service.Call.
+// Each client is closed after its pending request returned.
+func (independent *Service) closeCallClients() {
+	independent.callMu.Lock()
+	defer independent.callMu.Unlock()
+
+	for key, c := range independent.callClients {
+		if err := c.Close(); err != nil {
+			independent.Logger.Warn("callClient.Close", "destination", key, "error", err)
+		}
+	}
+	independent.callClients = nil
+}
+
+// FanOut sends the command to the services by their urls concurrently, and gathers their replies.
+// Each service has its own result, see fanout.Gather for the deadline and concurrency.
+func (independent *Service) FanOut(targetUrls []string, command string, parameters key_value.KeyValue, options fanout.Options) []fanout.Result[message.ReplyInterface] {
+	callOptions := DefaultCallOptions()
+	if options.Deadline > 0 {
+		callOptions.Timeout = options.Deadline
+		callOptions.Attempts = 1
+	}
+
+	return fanout.Gather(context.Background(), targetUrls, options, func(_ context.Context, targetUrl string) (message.ReplyInterface, error) {
+		return independent.CallWith(targetUrl, command, parameters, callOptions)
+	})
+}
@@ -0,0 +1,148 @@
+// Package capture records the incoming requests and replays them against a new build.
+//
+// The records are kept in the directory, one file per handler category.
+// Replay them at the original pace to reproduce the timing issues,
+// or accelerated to hunt the regressions in the routing and handler logic.
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Record of the incoming request
+type Record struct {
+	Time       time.Time              `json:"time"`
+	Category   string                 `json:"category"`
+	Command    string                 `json:"command"`
+	Source     string                 `json:"source,omitempty"` // the connection id or the service id of the sender
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// Recorder appends the records to the files of their categories
+type Recorder struct {
+	dir   string
+	files map[string]*os.File
+	mu    sync.Mutex
+}
+
+// NewRecorder returns the recorder that writes into the dir
+func NewRecorder(dir string) (*Recorder, error) {
+	if len(dir) == 0 {
+		return nil, fmt.Errorf("the 'dir' parameter is empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll('%s'): %w", dir, err)
+	}
+
+	return &Recorder{dir: dir, files: make(map[string]*os.File)}, nil
+}
+
+func path(dir, category string) string {
+	return filepath.Join(dir, url.QueryEscape(category)+".jsonl")
+}
+
+// Record appends the request.
+// If the time is not set, then the current time is used.
+func (recorder *Recorder) Record(record Record) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	f, ok := recorder.files[record.Category]
+	if !ok {
+		f, err = os.OpenFile(path(recorder.dir, record.Category), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("os.OpenFile: %w", err)
+		}
+		recorder.files[record.Category] = f
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("f.Write: %w", err)
+	}
+
+	return nil
+}
+
+// Close the files of the recorder
+func (recorder *Recorder) Close() error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	for category, f := range recorder.files {
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("files['%s'].Close: %w", category, err)
+		}
+		delete(recorder.files, category)
+	}
+
+	return nil
+}
+
+// Read returns the records of the category in the order they were recorded
+func Read(dir, category string) ([]Record, error) {
+	f, err := os.Open(path(dir, category))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Record{}, nil
+		}
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	records := make([]Record, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal(line %d): %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner.Err: %w", err)
+	}
+
+	return records, nil
+}
+
+// Replay sends the records keeping the intervals between them divided by the speed.
+// The speed 1 is the original pace, 10 is ten times faster.
+// If the speed is 0, then the records are sent without waiting.
+//
+// Stops at the first failed send.
+func Replay(records []Record, speed float64, send func(Record) error) error {
+	if speed < 0 {
+		return fmt.Errorf("the speed must not be negative")
+	}
+
+	for i, record := range records {
+		if i > 0 && speed > 0 {
+			gap := record.Time.Sub(records[i-1].Time)
+			if gap > 0 {
+				time.Sleep(time.Duration(float64(gap) / speed))
+			}
+		}
+		if err := send(record); err != nil {
+			return fmt.Errorf("send(records[%d] '%s'): %w", i, record.Command, err)
+		}
+	}
+
+	return nil
+}
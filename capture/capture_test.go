@@ -0,0 +1,75 @@
+package capture
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestCaptureSuite struct {
+	suite.Suite
+}
+
+// Test_10_Recorder tests the records are kept per category
+func (test *TestCaptureSuite) Test_10_Recorder() {
+	s := test.Require
+
+	dir := test.T().TempDir()
+	recorder, err := NewRecorder(dir)
+	s().NoError(err)
+
+	s().NoError(recorder.Record(Record{Category: "main", Command: "get", Parameters: map[string]interface{}{"key": "a"}}))
+	s().NoError(recorder.Record(Record{Category: "db", Command: "set"}))
+	s().NoError(recorder.Record(Record{Category: "main", Command: "set", Source: "proxy"}))
+	s().NoError(recorder.Close())
+
+	records, err := Read(dir, "main")
+	s().NoError(err)
+	s().Len(records, 2)
+	s().Equal("get", records[0].Command)
+	s().Equal("a", records[0].Parameters["key"])
+	s().Equal("proxy", records[1].Source)
+	s().False(records[0].Time.IsZero())
+
+	records, err = Read(dir, "unknown")
+	s().NoError(err)
+	s().Empty(records)
+}
+
+// Test_11_Replay tests the pace of the replay
+func (test *TestCaptureSuite) Test_11_Replay() {
+	s := test.Require
+
+	start := time.Now()
+	records := []Record{
+		{Time: start, Command: "a"},
+		{Time: start.Add(time.Millisecond * 100), Command: "b"},
+		{Time: start.Add(time.Millisecond * 200), Command: "c"},
+	}
+
+	sent := make([]string, 0, len(records))
+	send := func(record Record) error {
+		sent = append(sent, record.Command)
+		return nil
+	}
+
+	began := time.Now()
+	s().NoError(Replay(records, 1, send))
+	s().GreaterOrEqual(time.Since(began), time.Millisecond*200)
+	s().Equal([]string{"a", "b", "c"}, sent)
+
+	began = time.Now()
+	s().NoError(Replay(records, 10, send))
+	s().Less(time.Since(began), time.Millisecond*150)
+
+	s().Error(Replay(records, -1, send))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestCapture(t *testing.T) {
+	suite.Run(t, new(TestCaptureSuite))
+}
@@ -0,0 +1,129 @@
+// Package chaos injects the faults into the routes, to test the retries and circuit breakers.
+//
+// The faults are injected only in the binaries built with the chaos tag:
+//
+//	go build -tags chaos
+//
+// In the other builds, Enabled is false and Inject does nothing,
+// so the production binaries can not be broken by the manager command.
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Code is the prefix of the injected errors
+const Code = "chaos"
+
+// Config of the faults
+type Config struct {
+	DropPercent  int           `json:"drop_percent"`  // the percent of the messages to drop, from 0 to 100
+	Latency      time.Duration `json:"latency"`       // added before each message
+	FailCommands []string      `json:"fail_commands"` // the commands that always fail
+}
+
+// Validate returns an error if the config is invalid
+func (config Config) Validate() error {
+	if config.DropPercent < 0 || config.DropPercent > 100 {
+		return fmt.Errorf("drop_percent must be between 0 and 100")
+	}
+	if config.Latency < 0 {
+		return fmt.Errorf("latency must not be negative")
+	}
+	return nil
+}
+
+// injector applies the faults of the config
+type injector struct {
+	config Config
+	random *rand.Rand
+	mu     sync.Mutex
+}
+
+func newInjector(seed int64) *injector {
+	return &injector{random: rand.New(rand.NewSource(seed))}
+}
+
+func (i *injector) set(config Config) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.config = config
+}
+
+func (i *injector) get() Config {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.config
+}
+
+// inject sleeps the latency, then returns an error if the message must be dropped or failed
+func (i *injector) inject(command string) error {
+	i.mu.Lock()
+	config := i.config
+	drop := config.DropPercent > 0 && i.random.Intn(100) < config.DropPercent
+	i.mu.Unlock()
+
+	if config.Latency > 0 {
+		time.Sleep(config.Latency)
+	}
+	if slices.Contains(config.FailCommands, command) {
+		return fmt.Errorf("%s: '%s' command failed", Code, command)
+	}
+	if drop {
+		return fmt.Errorf("%s: '%s' message dropped", Code, command)
+	}
+	return nil
+}
+
+var global = newInjector(time.Now().UnixNano())
+
+// Set the faults. Returns an error if the binary is not built with the chaos tag.
+func Set(config Config) error {
+	if !Enabled {
+		return fmt.Errorf("the binary is not built with the chaos tag")
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("config.Validate: %w", err)
+	}
+	global.set(config)
+	return nil
+}
+
+// Get the current faults
+func Get() Config {
+	return global.get()
+}
+
+// Reset removes all faults
+func Reset() {
+	global.set(Config{})
+}
+
+// Inject the faults for the command.
+// Returns the error if the message must be dropped or failed.
+func Inject(command string) error {
+	if !Enabled {
+		return nil
+	}
+	return global.inject(command)
+}
+
+// Route wraps the route function with the faults.
+// The commandOf returns the command of the request, the onFault returns the failed reply.
+func Route[Req any, Rep any](commandOf func(Req) string, onFault func(Req, error) Rep, handle func(Req) Rep) func(Req) Rep {
+	if !Enabled {
+		return handle
+	}
+	return func(req Req) Rep {
+		if err := Inject(commandOf(req)); err != nil {
+			return onFault(req, err)
+		}
+		return handle(req)
+	}
+}
@@ -0,0 +1,57 @@
+package chaos
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestChaosSuite struct {
+	suite.Suite
+}
+
+// Test_10_Inject tests the faults of the injector
+func (test *TestChaosSuite) Test_10_Inject() {
+	s := test.Require
+
+	i := newInjector(1)
+	s().NoError(i.inject("get"))
+
+	i.set(Config{FailCommands: []string{"set"}})
+	s().NoError(i.inject("get"))
+	s().ErrorContains(i.inject("set"), Code)
+
+	i.set(Config{DropPercent: 100})
+	s().Error(i.inject("get"))
+
+	i.set(Config{DropPercent: 50})
+	dropped := 0
+	for n := 0; n < 1000; n++ {
+		if i.inject("get") != nil {
+			dropped++
+		}
+	}
+	s().InDelta(500, dropped, 100)
+
+	i.set(Config{Latency: time.Millisecond * 20})
+	start := time.Now()
+	s().NoError(i.inject("get"))
+	s().GreaterOrEqual(time.Since(start), time.Millisecond*20)
+
+	s().Error(Config{DropPercent: 101}.Validate())
+	s().Error(Config{Latency: -1}.Validate())
+
+	if !Enabled {
+		s().Error(Set(Config{FailCommands: []string{"get"}}))
+		s().NoError(Inject("get"))
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestChaos(t *testing.T) {
+	suite.Run(t, new(TestChaosSuite))
+}
@@ -0,0 +1,6 @@
+//go:build chaos
+
+package chaos
+
+// Enabled is true in the binaries built with the chaos tag
+const Enabled = true
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"fmt"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/handler-lib/manager_client"
+	"github.com/ahmetson/service-lib/errs"
+	"sync"
+	"sync/atomic"
+)
+
+// The clientPool keeps the handler manager clients by their destination.
+// Creating a client per call opens a new socket each time that is never released.
+type clientPool struct {
+	clients map[string]manager_client.Interface
+	mu      sync.Mutex
+}
+
+// The pooledClient is the handler manager client kept in the pool.
+// Closing the handler closes the socket of the client too, so the closed client is never reused.
+type pooledClient struct {
+	manager_client.Interface
+	closed atomic.Bool
+}
+
+// Close the handler and the socket of the client
+func (pooled *pooledClient) Close() error {
+	if err := pooled.Interface.Close(); err != nil {
+		return err
+	}
+	pooled.closed.Store(true)
+	return nil
+}
+
+// handlerClients is shared by the services, proxies and extensions in this process
+var handlerClients = newClientPool()
+
+func newClientPool() *clientPool {
+	return &clientPool{clients: make(map[string]manager_client.Interface)}
+}
+
+// The clientKey identifies the handler manager by the handler configuration
+func clientKey(c *handlerConfig.Handler) string {
+	return fmt.Sprintf("%s/%s/%s/%d", c.Type, c.Category, c.Id, c.Port)
+}
+
+// The get returns the pooled client to the handler manager or creates it.
+// The closed client is replaced by a new one.
+func (pool *clientPool) get(c *handlerConfig.Handler) (manager_client.Interface, error) {
+	if c == nil {
+		return nil, fmt.Errorf("handler configuration is nil")
+	}
+	key := clientKey(c)
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if handlerClient, ok := pool.clients[key]; ok && !closed(handlerClient) {
+		return handlerClient, nil
+	}
+
+	handlerClient, err := manager_client.New(c)
+	if err != nil {
+		return nil, fmt.Errorf("manager_client.New('%s'): %w", key, err)
+	}
+	pooled := &pooledClient{Interface: handlerClient}
+	pool.clients[key] = pooled
+
+	return pooled, nil
+}
+
+// The release removes the client from the pool.
+// The client is not closed, the caller closes it if needed.
+func (pool *clientPool) release(handlerClient manager_client.Interface) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for key, pooled := range pool.clients {
+		if pooled == handlerClient {
+			delete(pool.clients, key)
+		}
+	}
+}
+
+// The has returns true if the client is pooled for the handler configuration.
+// Returns false if the configuration was changed, for example, the handler was reloaded with another port.
+func (pool *clientPool) has(c *handlerConfig.Handler, handlerClient manager_client.Interface) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	return pool.clients[clientKey(c)] == handlerClient
+}
+
+// The closed returns true if the handler of the pooled client is closed
+func closed(handlerClient manager_client.Interface) bool {
+	pooled, ok := handlerClient.(*pooledClient)
+	return ok && pooled.closed.Load()
+}
+
+// The len returns the amount of the pooled clients
+func (pool *clientPool) len() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	return len(pool.clients)
+}
+
+// handlerClient returns the manager client of the handler by its id.
+// The client is created once, then it's reused to start, probe and close the handler.
+// The client of the closed or the reconfigured handler is evicted, and a new one is created.
+func (independent *Service) handlerClient(c *handlerConfig.Handler) (manager_client.Interface, error) {
+	if c == nil {
+		return nil, fmt.Errorf("handler configuration is nil")
+	}
+
+	independent.managerClientsMu.Lock()
+	defer independent.managerClientsMu.Unlock()
+
+	if handlerClient, ok := independent.managerClients[c.Id]; ok {
+		if !closed(handlerClient) && handlerClients.has(c, handlerClient) {
+			return handlerClient, nil
+		}
+		handlerClients.release(handlerClient)
+		delete(independent.managerClients, c.Id)
+	}
+
+	handlerClient, err := handlerClients.get(c)
+	if err != nil {
+		return nil, fmt.Errorf("handlerClients.get: %w", err)
+	}
+	if independent.managerClients == nil {
+		independent.managerClients = make(map[string]manager_client.Interface, 1)
+	}
+	independent.managerClients[c.Id] = handlerClient
+
+	return handlerClient, nil
+}
+
+// evictHandlerClient removes the client of the closed handler from the cache and the pool.
+// The restarted handler gets a new client.
+func (independent *Service) evictHandlerClient(id string) {
+	independent.managerClientsMu.Lock()
+	defer independent.managerClientsMu.Unlock()
+
+	if handlerClient, ok := independent.managerClients[id]; ok {
+		handlerClients.release(handlerClient)
+		delete(independent.managerClients, id)
+	}
+}
+
+// dropHandlerClients removes the cached manager clients of the handlers from the cache and the pool.
+// The handlers are closed by the manager once during the teardown, see manager.Manager.Close.
+// The clients of the handlers that are still running are closed with their handlers.
+func (independent *Service) dropHandlerClients() error {
+	independent.managerClientsMu.Lock()
+	defer independent.managerClientsMu.Unlock()
+
+	var err error
+	for id, handlerClient := range independent.managerClients {
+		handlerClients.release(handlerClient)
+		if !closed(handlerClient) {
+			err = errs.Join(err, errs.Wrap(fmt.Sprintf("handlerClient('%s').Close", id), handlerClient.Close()))
+		}
+	}
+	independent.managerClients = nil
+	return err
+}
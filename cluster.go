@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/message"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DeadTimeout is the duration during which the failed destination instance is skipped by the proxy.
+// After the timeout, the proxy tries the instance again.
+const DeadTimeout = time.Second * 10
+
+// The balancer distributes the requests of the same command among the destination instances.
+// Multiple instances of the destination service register the units with the same command,
+// but with different handler ids.
+type balancer struct {
+	mu         sync.Mutex
+	handlerIds []string
+	next       int
+}
+
+func newBalancer() *balancer {
+	return &balancer{handlerIds: make([]string, 0, 1)}
+}
+
+// add the destination handler into the rotation, if it's not added yet.
+func (b *balancer) add(handlerId string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !slices.Contains(b.handlerIds, handlerId) {
+		b.handlerIds = append(b.handlerIds, handlerId)
+	}
+}
+
+// set replaces the destination handlers in the rotation.
+// The rotation continues from the same position.
+func (b *balancer) set(handlerIds []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlerIds = slices.Clone(handlerIds)
+	if len(b.handlerIds) > 0 {
+		b.next %= len(b.handlerIds)
+	} else {
+		b.next = 0
+	}
+}
+
+// order returns the handler ids starting from the next one in the rotation.
+func (b *balancer) order() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	amount := len(b.handlerIds)
+	ordered := make([]string, amount)
+	for i := 0; i < amount; i++ {
+		ordered[i] = b.handlerIds[(b.next+i)%amount]
+	}
+	if amount > 0 {
+		b.next = (b.next + 1) % amount
+	}
+
+	return ordered
+}
+
+// markFailed marks the destination instance as dead for the DeadTimeout.
+func (wrapper *HandlerWrapper) markFailed() {
+	wrapper.mu.Lock()
+	wrapper.failedAt = time.Now()
+	wrapper.mu.Unlock()
+}
+
+// markAlive clears the failure of the destination instance.
+func (wrapper *HandlerWrapper) markAlive() {
+	wrapper.mu.Lock()
+	wrapper.failedAt = time.Time{}
+	wrapper.mu.Unlock()
+}
+
+// alive returns false if the destination instance failed within the DeadTimeout.
+func (wrapper *HandlerWrapper) alive() bool {
+	wrapper.mu.Lock()
+	defer wrapper.mu.Unlock()
+
+	return wrapper.failedAt.IsZero() || time.Since(wrapper.failedAt) > DeadTimeout
+}
+
+// The balancedRoute forwards the request to the live destination instance.
+// If the instance fails to respond, then the request is forwarded to the next instance.
+// If all instances are dead, then the request is forwarded to the next instance in the rotation anyway.
+//
+// The balancer is looked up by the key on each request, as it's removed when the destination has no units.
+func (proxy *Proxy) balancedRoute(key string, req message.RequestInterface) message.ReplyInterface {
+	proxy.balancersMu.Lock()
+	b, ok := proxy.balancers[key]
+	proxy.balancersMu.Unlock()
+	if !ok {
+		return req.Fail("no destination instances")
+	}
+
+	handlerIds := b.order()
+	if len(handlerIds) == 0 {
+		return req.Fail("no destination instances")
+	}
+
+	var reply message.ReplyInterface
+	tried := 0
+	for _, handlerId := range handlerIds {
+		handlerWrapper, ok := proxy.handlerWrappers[handlerId]
+		if !ok || !handlerWrapper.alive() {
+			continue
+		}
+
+		tried++
+		reply = proxy.routeWrapper(handlerId, req)
+		if handlerWrapper.alive() {
+			return reply
+		}
+	}
+
+	if tried == 0 {
+		return proxy.routeWrapper(handlerIds[0], req)
+	}
+
+	return reply
+}
+
+// The setBalancers sets the destination instances of the balancers by their keys.
+// The balancers of the destinations without the units are removed.
+func (proxy *Proxy) setBalancers(instances map[string][]string) {
+	proxy.balancersMu.Lock()
+	defer proxy.balancersMu.Unlock()
+
+	for key, handlerIds := range instances {
+		b, ok := proxy.balancers[key]
+		if !ok {
+			b = newBalancer()
+			proxy.balancers[key] = b
+		}
+		b.set(handlerIds)
+	}
+	for key := range proxy.balancers {
+		if _, ok := instances[key]; !ok {
+			delete(proxy.balancers, key)
+		}
+	}
+}
+
+// balancerKey returns the key of the balancer for the proxy handler category and command.
+func balancerKey(category string, command string) string {
+	return fmt.Sprintf("%s/%s", category, command)
+}
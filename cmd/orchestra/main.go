@@ -0,0 +1,61 @@
+// The orchestra runs the config engine, the dependency manager and the proxy handler
+// shared by the services of the host, see service.SharedContext.
+//
+//	ORCHESTRA_TOKEN=<token> orchestra [--url=tcp://localhost:4000]
+//
+// The services must prove the same token to attach, see orchestra.Dial.
+// The token protects the attaching only, the parts of the orchestra are reached over their own sockets.
+// It runs until SIGINT or SIGTERM.
+package main
+
+import (
+	"flag"
+	"fmt"
+	context "github.com/ahmetson/dev-lib"
+	"github.com/ahmetson/service-lib/orchestra"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const DefaultUrl = "tcp://localhost:4000"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run serves the orchestra until the process is signalled
+func run(args []string) error {
+	set := flag.NewFlagSet("orchestra", flag.ContinueOnError)
+	url := set.String("url", DefaultUrl, "the tcp url the services attach to")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, err := context.New()
+	if err != nil {
+		return fmt.Errorf("context.New: %w", err)
+	}
+	server, err := orchestra.NewServer(*url, os.Getenv(orchestra.TokenEnv), ctx)
+	if err != nil {
+		_ = ctx.Close()
+		return fmt.Errorf("orchestra.NewServer: %w", err)
+	}
+	if err := server.Start(); err != nil {
+		_ = ctx.Close()
+		return fmt.Errorf("server.Start: %w", err)
+	}
+	fmt.Printf("the orchestra is attachable at %s\n", server.Url())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+
+	if err := server.Close(); err != nil {
+		return fmt.Errorf("server.Close: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// The service-lib is the command line tool of the library.
+//
+//	service-lib new <name> [--module=<module path>] [--dir=<directory>]
+//
+// The new command generates the service, see scaffold.Generate.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ahmetson/service-lib/scaffold"
+	"os"
+)
+
+const usage = "usage: service-lib new <name> [--module=<module path>] [--dir=<directory>]"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command from the arguments, without the binary name
+func run(args []string) error {
+	if len(args) == 0 || args[0] != "new" {
+		return fmt.Errorf(usage)
+	}
+
+	set := flag.NewFlagSet("new", flag.ContinueOnError)
+	module := set.String("module", "", "the go module path of the service, by default the name")
+	dir := set.String("dir", "", "the directory of the service, by default the name")
+	if err := set.Parse(args[1:]); err != nil {
+		return err
+	}
+	// the flags may follow the name
+	if set.NArg() > 0 {
+		name := set.Arg(0)
+		if err := set.Parse(set.Args()[1:]); err != nil {
+			return err
+		}
+		if set.NArg() > 0 {
+			return fmt.Errorf(usage)
+		}
+		return generate(name, *module, *dir)
+	}
+	return fmt.Errorf(usage)
+}
+
+// generate writes the service into the directory
+func generate(name, module, dir string) error {
+	if len(module) == 0 {
+		module = name
+	}
+	if len(dir) == 0 {
+		dir = name
+	}
+
+	written, err := scaffold.Generate(dir, scaffold.Params{Name: name, Module: module})
+	if err != nil {
+		return fmt.Errorf("scaffold.Generate: %w", err)
+	}
+	for _, path := range written {
+		fmt.Println("created", path)
+	}
+	fmt.Printf("\ncd %s && go mod tidy && go test ./...\n", dir)
+	return nil
+}
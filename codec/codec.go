@@ -0,0 +1,129 @@
+// Package codec serializes the messages into JSON with the reused buffers.
+//
+// The json.Marshal allocates the new buffer for each message.
+// Under the heavy push load, for example a broadcast per block, it creates a lot of garbage.
+// The functions of this package take the buffers from the pool and return them after use.
+//
+// Unlike json.Marshal, the HTML characters are not escaped, as the messages are not embedded into HTML.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxPooled is the capacity of the buffer above which it's not returned to the pool,
+// so a single huge message doesn't keep the memory forever.
+const maxPooled = 1 << 20
+
+var buffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooled {
+		return
+	}
+	buffers.Put(buf)
+}
+
+// encode the value into the buffer without the trailing new line added by json.Encoder
+func encode(buf *bytes.Buffer, v interface{}) error {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("encoder.Encode: %w", err)
+	}
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+// Marshal returns the JSON of the value.
+// Only the returned slice is allocated, the intermediate buffer is reused.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := encode(buf, v); err != nil {
+		return nil, err
+	}
+	data := make([]byte, buf.Len())
+	copy(data, buf.Bytes())
+	return data, nil
+}
+
+// Size returns the length of the JSON of the value without allocating it
+func Size(v interface{}) (int, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := encode(buf, v); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+// With passes the JSON of the value to the use function.
+// The data is valid only inside the use function, as the buffer is reused after it.
+func With(v interface{}, use func(data []byte) error) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := encode(buf, v); err != nil {
+		return err
+	}
+	return use(buf.Bytes())
+}
+
+// Encoder writes the values as the stream of JSON lines
+type Encoder struct {
+	w   io.Writer
+	buf *bytes.Buffer
+	mu  sync.Mutex
+}
+
+// NewEncoder returns the streaming encoder into the writer
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, buf: getBuffer()}
+}
+
+// Encode writes the value followed by the new line
+func (encoder *Encoder) Encode(v interface{}) error {
+	encoder.mu.Lock()
+	defer encoder.mu.Unlock()
+
+	if encoder.buf == nil {
+		return fmt.Errorf("encoder is closed")
+	}
+	encoder.buf.Reset()
+	if err := encode(encoder.buf, v); err != nil {
+		return err
+	}
+	encoder.buf.WriteByte('\n')
+	if _, err := encoder.w.Write(encoder.buf.Bytes()); err != nil {
+		return fmt.Errorf("w.Write: %w", err)
+	}
+	return nil
+}
+
+// Close returns the buffer of the encoder to the pool
+func (encoder *Encoder) Close() {
+	encoder.mu.Lock()
+	defer encoder.mu.Unlock()
+
+	if encoder.buf != nil {
+		putBuffer(encoder.buf)
+		encoder.buf = nil
+	}
+}
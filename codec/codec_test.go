@@ -0,0 +1,119 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestCodecSuite struct {
+	suite.Suite
+}
+
+// logs is the typical push message, a block with the logs
+func logs() map[string]interface{} {
+	list := make([]interface{}, 50)
+	for i := range list {
+		list[i] = map[string]interface{}{
+			"address":   "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+			"topics":    []string{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+			"data":      "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000",
+			"block":     18_000_000,
+			"log_index": i,
+		}
+	}
+	return map[string]interface{}{"network_id": "1", "logs": list}
+}
+
+// Test_10_Marshal tests that the pooled encoding matches json.Marshal
+func (test *TestCodecSuite) Test_10_Marshal() {
+	s := test.Require
+
+	value := logs()
+	expected, err := json.Marshal(value)
+	s().NoError(err)
+
+	data, err := Marshal(value)
+	s().NoError(err)
+	s().Equal(expected, data)
+
+	// the returned data is not overwritten by the next encoding
+	_, err = Marshal(map[string]interface{}{"a": 1})
+	s().NoError(err)
+	s().Equal(expected, data)
+
+	size, err := Size(value)
+	s().NoError(err)
+	s().Equal(len(expected), size)
+
+	s().NoError(With(value, func(data []byte) error {
+		s().Equal(expected, data)
+		return nil
+	}))
+
+	_, err = Marshal(make(chan int))
+	s().Error(err)
+
+	var out bytes.Buffer
+	encoder := NewEncoder(&out)
+	s().NoError(encoder.Encode(map[string]interface{}{"a": 1}))
+	s().NoError(encoder.Encode(map[string]interface{}{"b": "<html>"}))
+	encoder.Close()
+	s().Equal("{\"a\":1}\n{\"b\":\"<html>\"}\n", out.String())
+	s().Error(encoder.Encode(1))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestCodec(t *testing.T) {
+	suite.Run(t, new(TestCodecSuite))
+}
+
+func BenchmarkJsonMarshal(b *testing.B) {
+	value := logs()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	value := logs()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSize(b *testing.B) {
+	value := logs()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Size(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	value := logs()
+	var out bytes.Buffer
+	encoder := NewEncoder(&out)
+	defer encoder.Close()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out.Reset()
+		if err := encoder.Encode(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package compat converts the messages between the current format of datatype-lib
+// and the legacy format of blocklords/sds, so both versions communicate during the migration.
+//
+// The current format:
+//
+//	request:   {"command": "...", "parameters": {...}}
+//	reply:     {"status": "OK" | "fail", "message": "...", "parameters": {...}}
+//	broadcast: {"topic": "...", "seq": 1, "parameters": {...}}
+//
+// The legacy format names the fields differently,
+// uses various status values and wraps the broadcast parameters into the reply.
+// The conversions work on the raw JSON, before it's parsed into the message types.
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	OK   = "OK"
+	FAIL = "fail"
+)
+
+// Codec converts the messages by the field aliases
+type Codec struct {
+	aliases map[string]string // legacy field name => current field name
+}
+
+// DefaultAliases returns the field names of the legacy messages
+func DefaultAliases() map[string]string {
+	return map[string]string{
+		"cmd":    "command",
+		"params": "parameters",
+		"args":   "parameters",
+		"msg":    "message",
+		"error":  "message",
+		"state":  "status",
+	}
+}
+
+// New codec with the default aliases
+func New() *Codec {
+	return &Codec{aliases: DefaultAliases()}
+}
+
+// SetAlias adds the legacy field name of the current field
+func (codec *Codec) SetAlias(legacy string, current string) {
+	codec.aliases[legacy] = current
+}
+
+// NormalizeStatus returns OK or FAIL for the legacy status values.
+// The unknown values are returned as FAIL, as the reply can't be trusted.
+func NormalizeStatus(status interface{}) string {
+	switch v := status.(type) {
+	case bool:
+		if v {
+			return OK
+		}
+		return FAIL
+	case string:
+		switch strings.ToLower(v) {
+		case "ok", "success", "succeed", "true":
+			return OK
+		}
+	}
+	return FAIL
+}
+
+// rename the legacy fields into the current fields.
+// If both the legacy and the current field are set, the current field is kept.
+func (codec *Codec) rename(fields map[string]interface{}) {
+	for legacy, current := range codec.aliases {
+		value, ok := fields[legacy]
+		if !ok {
+			continue
+		}
+		delete(fields, legacy)
+		if _, exist := fields[current]; !exist {
+			fields[current] = value
+		}
+	}
+}
+
+// decode the raw message into the fields
+func decode(data []byte) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if fields == nil {
+		return nil, fmt.Errorf("the message is not an object")
+	}
+	return fields, nil
+}
+
+// Request converts the legacy request into the current format
+func (codec *Codec) Request(data []byte) ([]byte, error) {
+	fields, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	codec.rename(fields)
+
+	if _, ok := fields["command"].(string); !ok {
+		return nil, fmt.Errorf("missing 'command' field")
+	}
+	if fields["parameters"] == nil {
+		fields["parameters"] = map[string]interface{}{}
+	}
+
+	return json.Marshal(fields)
+}
+
+// Reply converts the legacy reply into the current format
+func (codec *Codec) Reply(data []byte) ([]byte, error) {
+	fields, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	codec.rename(fields)
+
+	fields["status"] = NormalizeStatus(fields["status"])
+	if fields["parameters"] == nil {
+		fields["parameters"] = map[string]interface{}{}
+	}
+	if _, ok := fields["message"]; !ok {
+		fields["message"] = ""
+	}
+
+	return json.Marshal(fields)
+}
+
+// Broadcast converts the legacy broadcast into the current format.
+// The legacy broadcast keeps the parameters in the "reply" field.
+func (codec *Codec) Broadcast(data []byte) ([]byte, error) {
+	fields, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	codec.rename(fields)
+
+	if reply, ok := fields["reply"].(map[string]interface{}); ok {
+		delete(fields, "reply")
+		codec.rename(reply)
+		if _, exist := fields["parameters"]; !exist {
+			fields["parameters"] = reply["parameters"]
+		}
+	}
+	if fields["parameters"] == nil {
+		fields["parameters"] = map[string]interface{}{}
+	}
+
+	return json.Marshal(fields)
+}
+
+// LegacyReply converts the current reply into the legacy format,
+// to reply to the services not migrated yet.
+func (codec *Codec) LegacyReply(data []byte) ([]byte, error) {
+	fields, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	legacy := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		legacy[name] = value
+	}
+	legacy["status"] = NormalizeStatus(fields["status"])
+	if params, ok := fields["parameters"]; ok {
+		legacy["params"] = params
+		delete(legacy, "parameters")
+	}
+
+	return json.Marshal(legacy)
+}
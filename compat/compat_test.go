@@ -0,0 +1,78 @@
+package compat
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestCompatSuite struct {
+	suite.Suite
+}
+
+func (test *TestCompatSuite) fields(data []byte) map[string]interface{} {
+	var fields map[string]interface{}
+	test.Require().NoError(json.Unmarshal(data, &fields))
+	return fields
+}
+
+// Test_10_Convert tests the conversion of the legacy messages
+func (test *TestCompatSuite) Test_10_Convert() {
+	s := test.Require
+	codec := New()
+
+	// request
+	data, err := codec.Request([]byte(`{"cmd":"get","params":{"id":1}}`))
+	s().NoError(err)
+	fields := test.fields(data)
+	s().Equal("get", fields["command"])
+	s().Equal(map[string]interface{}{"id": float64(1)}, fields["parameters"])
+
+	_, err = codec.Request([]byte(`{"params":{}}`))
+	s().Error(err)
+
+	// reply with the various statuses
+	data, err = codec.Reply([]byte(`{"status":"success","params":{"a":"b"}}`))
+	s().NoError(err)
+	fields = test.fields(data)
+	s().Equal(OK, fields["status"])
+	s().Equal("", fields["message"])
+
+	data, err = codec.Reply([]byte(`{"state":false,"error":"not found"}`))
+	s().NoError(err)
+	fields = test.fields(data)
+	s().Equal(FAIL, fields["status"])
+	s().Equal("not found", fields["message"])
+
+	// broadcast wraps the parameters into the reply
+	data, err = codec.Broadcast([]byte(`{"topic":"logs","reply":{"status":"OK","params":{"n":2}}}`))
+	s().NoError(err)
+	fields = test.fields(data)
+	s().Equal("logs", fields["topic"])
+	s().Equal(map[string]interface{}{"n": float64(2)}, fields["parameters"])
+
+	// custom alias
+	codec.SetAlias("action", "command")
+	data, err = codec.Request([]byte(`{"action":"set"}`))
+	s().NoError(err)
+	s().Equal("set", test.fields(data)["command"])
+
+	// back to the legacy format
+	data, err = codec.LegacyReply([]byte(`{"status":"OK","message":"","parameters":{"a":1}}`))
+	s().NoError(err)
+	fields = test.fields(data)
+	s().Equal(map[string]interface{}{"a": float64(1)}, fields["params"])
+	s().NotContains(fields, "parameters")
+
+	_, err = codec.Reply([]byte(`[1,2]`))
+	s().Error(err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestCompat(t *testing.T) {
+	suite.Run(t, new(TestCompatSuite))
+}
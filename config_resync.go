@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/handler-lib/base"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+)
+
+// ConfigResyncTopic is the topic of the broadcast added into the feed when the service
+// re-syncs its configuration with the restarted config engine, see SetFeed.
+// The parameters are the restored flag and the reloaded handler categories.
+const ConfigResyncTopic = "config-resync"
+
+// The reconnectConfig starts the config engine of the context if it's not running.
+func (independent *Service) reconnectConfig() error {
+	if independent.ctx.IsConfigRunning() {
+		return nil
+	}
+	if err := independent.ctx.StartConfig(); err != nil {
+		return fmt.Errorf("ctx('%s').StartConfig: %w", independent.ctx.Type(), err)
+	}
+	return nil
+}
+
+// The configLost returns true if the config engine is not reachable or has no configuration of this service.
+func (independent *Service) configLost() bool {
+	exist, err := independent.ctx.Config().ServiceExist(independent.id)
+	return err != nil || !exist
+}
+
+// handlerChanged returns true if the configuration that the running handler depends on is changed
+func handlerChanged(running *handlerConfig.Handler, synced *handlerConfig.Handler) bool {
+	return running.Type != synced.Type ||
+		running.Port != synced.Port ||
+		running.InstanceAmount != synced.InstanceAmount
+}
+
+// The reloadHandler closes the handler and starts it with the configuration from the config engine
+func (independent *Service) reloadHandler(handler base.Interface, c *handlerConfig.Handler) error {
+	if err := independent.closeHandlers([]base.Interface{handler}); err != nil {
+		return fmt.Errorf("closeHandlers: %w", err)
+	}
+	handler.SetConfig(c)
+	if err := independent.setHandlerClient(handler); err != nil {
+		return fmt.Errorf("setHandlerClient: %w", err)
+	}
+	if err := independent.startHandler(handler); err != nil {
+		return fmt.Errorf("startHandler: %w", err)
+	}
+	return nil
+}
+
+// The reloadChanged reloads the running handlers whose configuration differs in the service configuration.
+// Returns the keys of the reloaded handlers.
+func (independent *Service) reloadChanged(serviceConf *serviceConfig.Service) ([]string, error) {
+	reloaded := make([]string, 0)
+	for key, raw := range independent.Handlers {
+		handler := raw.(base.Interface)
+		if handler.Config() == nil || independent.skipHandler(handler) {
+			continue
+		}
+		c, err := independent.handlerConfigByKey(serviceConf, key)
+		if err != nil {
+			return reloaded, fmt.Errorf("handlerConfigByKey('%s'): %w", key, err)
+		}
+		if !handlerChanged(handler.Config(), c) {
+			continue
+		}
+		if err := independent.reloadHandler(handler, c); err != nil {
+			return reloaded, fmt.Errorf("reloadHandler('%s'): %w", key, err)
+		}
+		reloaded = append(reloaded, key)
+	}
+	return reloaded, nil
+}
+
+// The resyncConfig reconnects to the restarted config engine and syncs the service configuration.
+//
+// If the engine lost the configuration, then the last synced one is set back,
+// so the running handlers and the manager keep their ports.
+// Otherwise, the handlers whose configuration was changed in the engine are reloaded.
+// Then the proxy units are published again, and the resync is added into the feed.
+func (independent *Service) resyncConfig(synced *serviceConfig.Service) error {
+	if err := independent.reconnectConfig(); err != nil {
+		return fmt.Errorf("reconnectConfig: %w", err)
+	}
+
+	configClient := independent.ctx.Config()
+	exist, err := configClient.ServiceExist(independent.id)
+	if err != nil {
+		return fmt.Errorf("configClient.ServiceExist('%s'): %w", independent.id, err)
+	}
+
+	reloaded := make([]string, 0)
+	if !exist {
+		if synced == nil {
+			return fmt.Errorf("the config engine has no '%s' service, and it was never synced", independent.id)
+		}
+		if err := configClient.SetService(synced); err != nil {
+			return fmt.Errorf("configClient.SetService: %w", err)
+		}
+	} else {
+		serviceConf, err := configClient.Service(independent.id)
+		if err != nil {
+			return fmt.Errorf("configClient.Service('%s'): %w", independent.id, err)
+		}
+		reloaded, err = independent.reloadChanged(serviceConf)
+		if err != nil {
+			return fmt.Errorf("reloadChanged: %w", err)
+		}
+	}
+
+	// the orchestra restarted along with the config engine, so the units are sent in full
+	independent.forgetUnits()
+	if err := independent.setProxyUnits(); err != nil {
+		return fmt.Errorf("setProxyUnits: %w", err)
+	}
+
+	independent.Logger.Info("configuration re-synced with the config engine", "restored", !exist, "reloaded", reloaded)
+	err = independent.broadcast(ConfigResyncTopic, map[string]interface{}{
+		"restored": !exist,
+		"reloaded": reloaded,
+	})
+	if err != nil {
+		return fmt.Errorf("broadcast: %w", err)
+	}
+	return nil
+}
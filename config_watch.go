@@ -0,0 +1,87 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/service-lib/manager"
+	"time"
+)
+
+// ConfigWatchInterval is how often the configuration is checked for the changes
+const ConfigWatchInterval = time.Second * 3
+
+// The configFingerprint returns the hash of everything the proxy units are derived from:
+// the service configuration in the config engine, the proxy chains and the routes of the handlers.
+// The fetched service configuration is returned along with the hash.
+func (independent *Service) configFingerprint() ([32]byte, *serviceConfig.Service, error) {
+	serviceConf, err := independent.ctx.Config().Service(independent.id)
+	if err != nil {
+		return [32]byte{}, nil, fmt.Errorf("ctx.Config().Service('%s'): %w", independent.id, err)
+	}
+	proxyChains, err := independent.ctx.ProxyClient().ProxyChains()
+	if err != nil {
+		return [32]byte{}, serviceConf, fmt.Errorf("proxyClient.ProxyChains: %w", err)
+	}
+
+	data, err := json.Marshal([]interface{}{serviceConf, proxyChains, independent.routeCommands()})
+	if err != nil {
+		return [32]byte{}, serviceConf, fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	return sha256.Sum256(data), serviceConf, nil
+}
+
+// The watchConfig re-publishes the proxy units when the configuration changes,
+// so the new routes and handlers are reachable without restarting the service.
+// If the config engine restarted, then the service re-syncs with it, see resyncConfig.
+// It stops when the manager is closed.
+func (independent *Service) watchConfig() {
+	last, synced, err := independent.configFingerprint()
+	if err != nil {
+		independent.Logger.Warn("configFingerprint", "error", err)
+	}
+	lost := false
+
+	for {
+		time.Sleep(ConfigWatchInterval)
+
+		if independent.manager == nil || !independent.manager.Running() {
+			return
+		}
+
+		if lost || independent.configLost() {
+			lost = true
+			if err := independent.resyncConfig(synced); err != nil {
+				independent.Logger.Warn("resyncConfig", "error", err)
+				continue
+			}
+			lost = false
+		}
+
+		fingerprint, serviceConf, err := independent.configFingerprint()
+		if serviceConf != nil {
+			synced = serviceConf
+		}
+		if err != nil {
+			independent.Logger.Warn("configFingerprint", "error", err)
+			continue
+		}
+		if fingerprint == last {
+			continue
+		}
+
+		independent.Logger.Info("configuration changed, refreshing the proxy units", "id", independent.id)
+		if err := independent.setProxyUnits(); err != nil {
+			independent.Logger.Warn("setProxyUnits", "error", err)
+			continue
+		}
+		last = fingerprint
+
+		// the clients drop the replies of the queries kept by them
+		if err := independent.broadcast(manager.ConfigChangedTopic, map[string]interface{}{"id": independent.id}); err != nil {
+			independent.Logger.Warn("broadcast", "topic", manager.ConfigChangedTopic, "error", err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/service-lib/flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// GracePeriod is the default time given to the service to close after SIGTERM in the container mode.
+const GracePeriod = time.Second * 10
+
+// HealthPath is the url path of the http health endpoint
+const HealthPath = "/health"
+
+// IsContainer returns true if the service runs in the container mode.
+// Set flag.ContainerEnv to "true" to enable it.
+//
+// In the container mode:
+//   - handlers listen on the fixed ports from flag.PortEnv,
+//   - the http health endpoint listens on flag.HealthPortEnv,
+//   - SIGTERM closes the service within flag.GracePeriodEnv.
+func IsContainer() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(flag.ContainerEnv))
+	return enabled
+}
+
+// gracePeriod returns the termination period from the environment or GracePeriod by default.
+func gracePeriod() (time.Duration, error) {
+	raw := os.Getenv(flag.GracePeriodEnv)
+	if len(raw) == 0 {
+		return GracePeriod, nil
+	}
+	period, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("time.ParseDuration('%s'): %w", raw, err)
+	}
+	return period, nil
+}
+
+// setContainerPorts overwrites the ports of the public handlers by the environment variables.
+// The handlers bind to all interfaces, so the fixed ports can be published by the container.
+func (independent *Service) setContainerPorts() error {
+	for category, raw := range independent.Handlers {
+		handler := raw.(base.Interface)
+		if !independent.isPublic(handler) {
+			continue
+		}
+
+		name := flag.PortEnv(category)
+		value := os.Getenv(name)
+		if len(value) == 0 {
+			continue
+		}
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return fmt.Errorf("strconv.ParseUint('%s'='%s'): %w", name, value, err)
+		}
+		handler.Config().Port = uint64(port)
+	}
+
+	return nil
+}
+
+// onHealth replies with 200 if the service is running, otherwise with 503
+func (independent *Service) onHealth(w http.ResponseWriter, _ *http.Request) {
+	running := independent.manager != nil && independent.manager.Running()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !running {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      independent.id,
+		"url":     independent.url,
+		"running": running,
+	})
+}
+
+// startHealth starts the http health endpoint if flag.HealthPortEnv is set.
+func (independent *Service) startHealth() error {
+	value := os.Getenv(flag.HealthPortEnv)
+	if len(value) == 0 {
+		return nil
+	}
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return fmt.Errorf("strconv.ParseUint('%s'='%s'): %w", flag.HealthPortEnv, value, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, independent.onHealth)
+	independent.health = &http.Server{Addr: fmt.Sprintf("0.0.0.0:%d", port), Handler: mux}
+
+	go func(server *http.Server) {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			independent.Logger.Error("health.ListenAndServe", "error", err)
+		}
+	}(independent.health)
+
+	return nil
+}
+
+// closeHealth stops the http health endpoint
+func (independent *Service) closeHealth(timeout time.Duration) error {
+	if independent.health == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := independent.health.Shutdown(ctx); err != nil {
+		return fmt.Errorf("health.Shutdown: %w", err)
+	}
+	independent.health = nil
+	return nil
+}
+
+// trapTermination closes the service on SIGTERM or SIGINT.
+// If the service is not closed within the grace period, the process exits.
+// Returns the function that stops trapping the signals, for example, when the service failed to start.
+func (independent *Service) trapTermination(period time.Duration) func() error {
+	signals := make(chan os.Signal, 1)
+	untrapped := make(chan struct{})
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-signals:
+		case <-untrapped:
+			return
+		}
+		signal.Stop(signals)
+		independent.Logger.Info("termination signal received", "signal", sig.String(), "grace period", period)
+
+		timer := time.AfterFunc(period, func() {
+			independent.Logger.Error("service was not closed within the grace period, exiting", "grace period", period)
+			os.Exit(1)
+		})
+
+		if err := independent.closeHealth(period); err != nil {
+			independent.Logger.Warn("closeHealth", "error", err)
+		}
+		if independent.manager != nil && independent.manager.Running() {
+			if err := independent.manager.Close(); err != nil {
+				independent.Logger.Error("manager.Close", "error", err)
+			}
+		}
+		timer.Stop()
+	}()
+
+	var once sync.Once
+	return func() error {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(untrapped)
+		})
+		return nil
+	}
+}
+
+// startContainer prepares the container mode after the handlers are configured.
+// The signals are not trapped anymore once the stack runs.
+func (independent *Service) startContainer(stack *teardown) error {
+	if !IsContainer() {
+		return nil
+	}
+
+	period, err := gracePeriod()
+	if err != nil {
+		return fmt.Errorf("gracePeriod: %w", err)
+	}
+	if err := independent.setContainerPorts(); err != nil {
+		return fmt.Errorf("setContainerPorts: %w", err)
+	}
+	if err := independent.startHealth(); err != nil {
+		return fmt.Errorf("startHealth: %w", err)
+	}
+	stack.push("untrapTermination", independent.trapTermination(period))
+
+	return nil
+}
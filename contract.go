@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/service-lib/manager"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HandshakeTimeout is how long the extension's manager is waited to describe its commands
+const HandshakeTimeout = time.Second * 5
+
+// Contract is the commands the service requires from the extension
+type Contract struct {
+	Id        string              // the extension id
+	Commands  []string            // the required commands
+	Available map[string][]string // the commands of the extension by the handler category, set by the handshake
+}
+
+// RequireCommands declares the commands the service calls on the extension.
+// On Start, the extension is asked for its commands, and the start fails if any required command is missing.
+func (independent *Service) RequireCommands(id string, commands ...string) {
+	if independent.contracts == nil {
+		independent.contracts = make(map[string]*Contract)
+	}
+	contract, ok := independent.contracts[id]
+	if !ok {
+		contract = &Contract{Id: id}
+		independent.contracts[id] = contract
+	}
+	for _, command := range commands {
+		if !slices.Contains(contract.Commands, command) {
+			contract.Commands = append(contract.Commands, command)
+		}
+	}
+}
+
+// Contracts returns the extension contracts. After Start, they include the commands of the extensions.
+func (independent *Service) Contracts() []*Contract {
+	contracts := make([]*Contract, 0, len(independent.contracts))
+	for _, contract := range independent.contracts {
+		contracts = append(contracts, contract)
+	}
+	sort.Slice(contracts, func(i, j int) bool {
+		return contracts[i].Id < contracts[j].Id
+	})
+	return contracts
+}
+
+// The routeCommands returns the commands of the handlers by their category.
+// The commands of the handlers with the same category are merged.
+// The internal handlers are not included.
+func (independent *Service) routeCommands() map[string][]string {
+	commands := make(map[string][]string, len(independent.Handlers))
+	for key, raw := range independent.Handlers {
+		if independent.isInternal(key) {
+			continue
+		}
+		category := independent.handlerCategory(key)
+		if _, ok := commands[category]; !ok {
+			commands[category] = make([]string, 0)
+		}
+		for _, command := range raw.(base.Interface).RouteCommands() {
+			if !slices.Contains(commands[category], command) {
+				commands[category] = append(commands[category], command)
+			}
+		}
+	}
+	return commands
+}
+
+// The missingCommands returns the required commands that no handler has
+func missingCommands(required []string, available map[string][]string) []string {
+	missing := make([]string, 0)
+	for _, command := range required {
+		found := false
+		for _, commands := range available {
+			if slices.Contains(commands, command) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, command)
+		}
+	}
+	return missing
+}
+
+// The handshake asks each required extension for its commands and verifies the contract.
+// Returns an error listing the failures of all extensions.
+//
+// The failed optional extensions are marked as degraded instead, see SetOptional.
+func (independent *Service) handshake() error {
+	failures := make([]string, 0)
+
+	for _, contract := range independent.Contracts() {
+		err := independent.handshakeWith(contract)
+		if err == nil {
+			continue
+		}
+		if independent.isOptional(contract.Id) {
+			independent.Logger.Warn("optional extension failed, the service is degraded", "id", contract.Id, "error", err)
+			independent.setDegraded(contract.Id, err)
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", contract.Id, err))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("the extensions don't match the contract:\n%s", strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// The handshakeWith asks the extension for its commands.
+// Returns an error with the list of the missing commands.
+func (independent *Service) handshakeWith(contract *Contract) error {
+	managerClient, err := manager.NewClientById(independent.ctx, contract.Id)
+	if err != nil {
+		return fmt.Errorf("manager.NewClientById: %w", err)
+	}
+	var available map[string][]string
+	err = withTimeout("handshake with "+contract.Id, HandshakeTimeout, func() error {
+		var describeErr error
+		available, describeErr = managerClient.HandlerCommands()
+		_ = managerClient.Socket.Close()
+		return describeErr
+	})
+	if err != nil {
+		return fmt.Errorf("managerClient.HandlerCommands: %w", err)
+	}
+	contract.Available = available
+
+	missing := missingCommands(contract.Commands, available)
+	if len(missing) > 0 {
+		return fmt.Errorf("missing commands:\n  - %s", strings.Join(missing, "\n  - "))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/handler-lib/base"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/handler-lib/replier"
+	"github.com/ahmetson/handler-lib/sync_replier"
+	"github.com/ahmetson/service-lib/routemap"
+)
+
+// RouteFunc is the route function registered by the name, see RegisterRoute
+type RouteFunc = func(req message.RequestInterface) message.ReplyInterface
+
+var routeFuncs = routemap.NewRegistry[RouteFunc]()
+
+// RegisterRoute adds the route function used by the handlers declared in the configuration.
+// Register the functions in the init function, before creating the services.
+//
+// The handlers that are in the service configuration, but not set by SetHandler,
+// are created by their type, and routed by the routemap.Env of their category.
+func RegisterRoute(name string, handle RouteFunc) error {
+	if handle == nil {
+		return fmt.Errorf("the '%s' route has no function", name)
+	}
+	if err := routeFuncs.Register(name, handle); err != nil {
+		return fmt.Errorf("routeFuncs.Register: %w", err)
+	}
+	return nil
+}
+
+// newHandler creates the handler of the built-in or the third-party type
+func newHandler(handlerType handlerConfig.HandlerType) (base.Interface, error) {
+	switch handlerType {
+	case handlerConfig.SyncReplierType:
+		return sync_replier.New(), nil
+	case handlerConfig.ReplierType:
+		return replier.New(), nil
+	}
+	if registered, ok := registeredType(handlerType); ok {
+		return registered.New(), nil
+	}
+	return nil, fmt.Errorf("the '%s' type can not be created, register it by RegisterHandlerType", handlerType)
+}
+
+// The declaredRoutes returns the route functions of the category declared in the configuration.
+func (independent *Service) declaredRoutes(category string) (map[string]RouteFunc, error) {
+	declaration, err := independent.ctx.Config().String(routemap.Env(category))
+	if err != nil {
+		return nil, fmt.Errorf("configClient.String('%s'): %w", routemap.Env(category), err)
+	}
+	routes, err := routemap.Parse(declaration)
+	if err != nil {
+		return nil, fmt.Errorf("routemap.Parse('%s'): %w", routemap.Env(category), err)
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("the '%s' handler has no routes, declare them in '%s'", category, routemap.Env(category))
+	}
+	return routeFuncs.Resolve(routes)
+}
+
+// The setDeclaredHandlers sets the handlers that are in the service configuration, but not set in the code.
+// The handlers are created by their type, and routed by the functions declared for their category.
+// The category with multiple handlers in the configuration is set by the handler id, see SetHandlerById.
+//
+// If the configuration doesn't exist, nothing is set.
+func (independent *Service) setDeclaredHandlers() error {
+	configClient := independent.ctx.Config()
+	exist, err := configClient.ServiceExist(independent.id)
+	if err != nil {
+		return fmt.Errorf("configClient.ServiceExist('%s'): %w", independent.id, err)
+	}
+	if !exist {
+		return nil
+	}
+	serviceConf, err := configClient.Service(independent.id)
+	if err != nil {
+		return fmt.Errorf("configClient.Service('%s'): %w", independent.id, err)
+	}
+
+	// the handlers of the category set in the code are never mixed with the declared ones
+	categories := make(map[string]int, len(serviceConf.Handlers))
+	set := make(map[string]bool, len(serviceConf.Handlers))
+	for _, c := range serviceConf.Handlers {
+		categories[c.Category]++
+		set[c.Category] = len(independent.HandlersByCategory(c.Category)) > 0
+	}
+
+	for _, c := range serviceConf.Handlers {
+		key := c.Category
+		if categories[c.Category] > 1 {
+			key = c.Id
+		}
+		if set[c.Category] {
+			continue
+		}
+
+		handler, err := newHandler(c.Type)
+		if err != nil {
+			return fmt.Errorf("newHandler('%s'): %w", key, err)
+		}
+		routes, err := independent.declaredRoutes(c.Category)
+		if err != nil {
+			return fmt.Errorf("declaredRoutes('%s'): %w", c.Category, err)
+		}
+		for command, handle := range routes {
+			if err := handler.Route(command, handle); err != nil {
+				return fmt.Errorf("handler('%s').Route('%s'): %w", key, command, err)
+			}
+		}
+
+		if key == c.Category {
+			independent.SetHandler(key, handler)
+		} else {
+			independent.SetHandlerById(key, c.Category, handler)
+		}
+		independent.Logger.Info("handler set by the configuration", "key", key, "type", c.Type, "routes", len(routes))
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"fmt"
+	clientConfig "github.com/ahmetson/client-lib/config"
+	"github.com/ahmetson/service-lib/limits"
+	"github.com/ahmetson/service-lib/manager"
+	"time"
+)
+
+// RestartTimeout is how long the restarted dependency is waited to reply to the heartbeat
+const RestartTimeout = time.Second * 30
+
+// The depLimits returns the limits of the dependency declared in the configuration, see limits.Env.
+func (independent *Service) depLimits(id string) (limits.Limits, error) {
+	declaration, err := independent.ctx.Config().String(limits.Env(id))
+	if err != nil {
+		return limits.Limits{}, fmt.Errorf("configClient.String('%s'): %w", limits.Env(id), err)
+	}
+	depLimits, err := limits.Parse(declaration)
+	if err != nil {
+		return limits.Limits{}, fmt.Errorf("limits.Parse('%s'): %w", limits.Env(id), err)
+	}
+	return depLimits, nil
+}
+
+// The limitDep applies the declared limits to the started dependency.
+// The pid is requested from the manager of the dependency.
+// If the enforcer is set, the process is tracked to report the violations.
+//
+// The dependency without the limits is skipped.
+func (independent *Service) limitDep(id string, managerConf *clientConfig.Client) error {
+	depLimits, err := independent.depLimits(id)
+	if err != nil {
+		return err
+	}
+	if depLimits.IsZero() {
+		return nil
+	}
+
+	managerClient, err := manager.NewClient(managerConf)
+	if err != nil {
+		return fmt.Errorf("manager.NewClient: %w", err)
+	}
+	pid, err := managerClient.Pid()
+	_ = managerClient.Socket.Close()
+	if err != nil {
+		return fmt.Errorf("managerClient.Pid: %w", err)
+	}
+
+	if err := limits.Apply(id, pid, depLimits); err != nil {
+		return fmt.Errorf("limits.Apply('%s', %d): %w", id, pid, err)
+	}
+	if independent.enforcer != nil {
+		independent.enforcer.Track(id, pid, depLimits)
+	}
+	independent.Logger.Info("dependency limited", "id", id, "pid", pid, "limits", depLimits)
+	return nil
+}
+
+// The limitProxies applies the declared limits to the started proxies of this service.
+// The extensions are limited when they are connected for the first time, see resolveDep.
+func (independent *Service) limitProxies() error {
+	serviceConf, err := independent.ctx.Config().Service(independent.id)
+	if err != nil {
+		return fmt.Errorf("configClient.Service('%s'): %w", independent.id, err)
+	}
+
+	for _, source := range serviceConf.Sources {
+		for _, proxy := range source.Proxies {
+			if proxy.Manager == nil {
+				continue
+			}
+			proxy.Manager.UrlFunc(clientConfig.Url)
+			if err := independent.limitDep(proxy.Id, proxy.Manager); err != nil {
+				return fmt.Errorf("limitDep('%s'): %w", proxy.Id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// The restartDep starts the dependency killed by the enforcer again, see limits.Enforcer.SetRestart.
+// The dependency is run by the dependency manager of the context with this service as the parent.
+// Then the limits are applied to the new process, and it's tracked by the new pid.
+func (independent *Service) restartDep(id string) error {
+	depConf, err := independent.ctx.Config().Service(id)
+	if err != nil {
+		return fmt.Errorf("configClient.Service('%s'): %w", id, err)
+	}
+	if depConf.Manager == nil {
+		return fmt.Errorf("the '%s' dependency has no manager", id)
+	}
+	depConf.Manager.UrlFunc(clientConfig.Url)
+
+	serviceConf, err := independent.ctx.Config().Service(independent.id)
+	if err != nil {
+		return fmt.Errorf("configClient.Service('%s'): %w", independent.id, err)
+	}
+	serviceConf.Manager.UrlFunc(clientConfig.Url)
+
+	// the killed process may reply until it exits
+	deadline := time.Now().Add(DuplicateTimeout)
+	for running(depConf.Manager) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	if err := independent.ctx.DepClient().Run(depConf.Url, id, serviceConf.Manager); err != nil {
+		return fmt.Errorf("depClient.Run('%s', '%s'): %w", depConf.Url, id, err)
+	}
+
+	deadline = time.Now().Add(RestartTimeout)
+	for !running(depConf.Manager) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the '%s' dependency is not running after %v", id, RestartTimeout)
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	if err := independent.limitDep(id, depConf.Manager); err != nil {
+		return fmt.Errorf("limitDep('%s'): %w", id, err)
+	}
+	independent.Logger.Info("dependency restarted", "id", id)
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package deprecation keeps the versions and the deprecation metadata of the routes.
+//
+// The deprecated route replies as usual, but each request is reported as a warning.
+// After the sunset date, the route is rejected with the SunsetError.
+// The sunset dates are configured centrally in the Registry, so they can be moved without changing the handlers.
+package deprecation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Code is the prefix of the sunset errors
+const Code = "route_sunset"
+
+// Info is the version and deprecation metadata of the route
+type Info struct {
+	Category    string    `json:"category"`
+	Command     string    `json:"command"`
+	Version     string    `json:"version,omitempty"`
+	Deprecated  bool      `json:"deprecated,omitempty"`
+	Sunset      time.Time `json:"sunset,omitempty"`      // the route is rejected after this time, if it's not zero
+	Replacement string    `json:"replacement,omitempty"` // the command to use instead
+}
+
+// Validate returns an error if the info is invalid
+func (info Info) Validate() error {
+	if len(info.Category) == 0 {
+		return fmt.Errorf("category is empty")
+	}
+	if len(info.Command) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+	if !info.Sunset.IsZero() && !info.Deprecated {
+		return fmt.Errorf("'%s' has a sunset date, but it's not deprecated", info.Command)
+	}
+	return nil
+}
+
+// Warning returns the message about the deprecated route.
+// Returns an empty string if the route is not deprecated.
+func (info Info) Warning() string {
+	if !info.Deprecated {
+		return ""
+	}
+	warning := fmt.Sprintf("'%s' command is deprecated", info.Command)
+	if !info.Sunset.IsZero() {
+		warning += fmt.Sprintf(", it will be removed at %s", info.Sunset.Format(time.RFC3339))
+	}
+	if len(info.Replacement) > 0 {
+		warning += fmt.Sprintf(", use '%s' instead", info.Replacement)
+	}
+	return warning
+}
+
+// SunsetError is returned for the requests to the route after its sunset date
+type SunsetError struct {
+	Info Info
+}
+
+func (e *SunsetError) Error() string {
+	message := fmt.Sprintf("%s: '%s' command was removed at %s", Code, e.Info.Command, e.Info.Sunset.Format(time.RFC3339))
+	if len(e.Info.Replacement) > 0 {
+		message += fmt.Sprintf(", use '%s' instead", e.Info.Replacement)
+	}
+	return message
+}
+
+// IsSunset returns true if the error is returned for the route after its sunset date
+func IsSunset(err error) bool {
+	var sunsetErr *SunsetError
+	return errors.As(err, &sunsetErr)
+}
+
+// Registry keeps the metadata of the routes by the category and command
+type Registry struct {
+	routes map[string]Info
+	mu     sync.RWMutex
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{routes: make(map[string]Info)}
+}
+
+func key(category, command string) string {
+	return category + "/" + command
+}
+
+// Set the metadata of the route. The previous metadata is overwritten.
+func (r *Registry) Set(info Info) error {
+	if err := info.Validate(); err != nil {
+		return fmt.Errorf("info.Validate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[key(info.Category, info.Command)] = info
+	return nil
+}
+
+// SetSunset deprecates the route and sets its sunset date.
+// If the route is not registered, it's added without a version.
+func (r *Registry) SetSunset(category, command string, sunset time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.routes[key(category, command)]
+	if !ok {
+		info = Info{Category: category, Command: command}
+	}
+	info.Deprecated = true
+	info.Sunset = sunset
+	r.routes[key(category, command)] = info
+}
+
+// Get the metadata of the route
+func (r *Registry) Get(category, command string) (Info, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info, ok := r.routes[key(category, command)]
+	return info, ok
+}
+
+// Routes returns the metadata of all routes sorted by the category and command
+func (r *Registry) Routes() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	routes := make([]Info, 0, len(r.routes))
+	for _, info := range r.routes {
+		routes = append(routes, info)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return key(routes[i].Category, routes[i].Command) < key(routes[j].Category, routes[j].Command)
+	})
+	return routes
+}
+
+// Check the route at the given time.
+// Returns the warning if the route is deprecated, or the SunsetError if it's past the sunset date.
+// The routes without the metadata are always allowed.
+func (r *Registry) Check(category, command string, now time.Time) (string, error) {
+	info, ok := r.Get(category, command)
+	if !ok {
+		return "", nil
+	}
+	if !info.Sunset.IsZero() && !now.Before(info.Sunset) {
+		return "", &SunsetError{Info: info}
+	}
+	return info.Warning(), nil
+}
+
+// Route wraps the route function of the category with the deprecation check.
+// The onWarning is called for the requests to the deprecated route, the onSunset returns the failed reply.
+func Route[Req any, Rep any](registry *Registry, category string, commandOf func(Req) string,
+	onWarning func(Req, string), onSunset func(Req, error) Rep, handle func(Req) Rep) func(Req) Rep {
+	return func(req Req) Rep {
+		warning, err := registry.Check(category, commandOf(req), time.Now())
+		if err != nil {
+			return onSunset(req, err)
+		}
+		if len(warning) > 0 && onWarning != nil {
+			onWarning(req, warning)
+		}
+		return handle(req)
+	}
+}
@@ -0,0 +1,85 @@
+package deprecation
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestDeprecationSuite struct {
+	suite.Suite
+}
+
+// Test_10_Registry tests the versions and the sunset of the routes
+func (test *TestDeprecationSuite) Test_10_Registry() {
+	s := test.Require
+
+	registry := NewRegistry()
+	s().Error(registry.Set(Info{Command: "get"}))
+	s().Error(registry.Set(Info{Category: "main"}))
+	s().Error(registry.Set(Info{Category: "main", Command: "get", Sunset: time.Now()}))
+
+	s().NoError(registry.Set(Info{Category: "main", Command: "get_v2", Version: "2"}))
+	s().NoError(registry.Set(Info{Category: "main", Command: "get", Version: "1", Deprecated: true, Replacement: "get_v2"}))
+
+	// the unknown and current routes have no warning
+	warning, err := registry.Check("main", "set", time.Now())
+	s().NoError(err)
+	s().Empty(warning)
+	warning, err = registry.Check("main", "get_v2", time.Now())
+	s().NoError(err)
+	s().Empty(warning)
+
+	// the deprecated route is allowed with a warning
+	warning, err = registry.Check("main", "get", time.Now())
+	s().NoError(err)
+	s().Contains(warning, "get_v2")
+
+	// after the sunset, the route is rejected
+	sunset := time.Now().Add(time.Hour)
+	registry.SetSunset("main", "get", sunset)
+	_, err = registry.Check("main", "get", time.Now())
+	s().NoError(err)
+	_, err = registry.Check("main", "get", sunset)
+	s().True(IsSunset(err))
+
+	routes := registry.Routes()
+	s().Len(routes, 2)
+	s().Equal("get", routes[0].Command)
+	s().Equal("1", routes[0].Version)
+}
+
+// Test_11_Route tests the wrapped route function
+func (test *TestDeprecationSuite) Test_11_Route() {
+	s := test.Require
+
+	registry := NewRegistry()
+	registry.SetSunset("main", "old", time.Now().Add(-time.Minute))
+	s().NoError(registry.Set(Info{Category: "main", Command: "get", Deprecated: true}))
+
+	warnings := make([]string, 0)
+	handle := Route(registry, "main", func(command string) string {
+		return command
+	}, func(_ string, warning string) {
+		warnings = append(warnings, warning)
+	}, func(_ string, err error) string {
+		return err.Error()
+	}, func(command string) string {
+		return "ok"
+	})
+
+	s().Equal("ok", handle("set"))
+	s().Empty(warnings)
+	s().Equal("ok", handle("get"))
+	s().Len(warnings, 1)
+	s().Contains(handle("old"), Code)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestDeprecation(t *testing.T) {
+	suite.Run(t, new(TestDeprecationSuite))
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/client-lib"
+	clientConfig "github.com/ahmetson/client-lib/config"
+	"github.com/ahmetson/datatype-lib/message"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+)
+
+// Deps are the clients of the extensions by their ids.
+// The clients are shared by the concurrently dispatched routes, see SyncClient.
+type Deps map[string]*SyncClient
+
+// DepRoute is the route function that receives the clients of the extensions it depends on
+type DepRoute = func(req message.RequestInterface, deps Deps) message.ReplyInterface
+
+// RouteDeps adds the route into the handlers of the category.
+// The route declares the extension ids it depends on,
+// and receives their connected clients at the dispatch time.
+//
+// The extensions are health-checked when they are connected for the first time.
+// If any extension is not running, then the request fails without calling the route.
+// The route depending on a degraded optional extension replies with Unavailable.
+func (independent *Service) RouteDeps(category string, command string, depIds []string, handle DepRoute) error {
+	handlers := independent.HandlersByCategory(category)
+	if len(handlers) == 0 {
+		return fmt.Errorf("the '%s' handler is not set", category)
+	}
+
+	route := func(req message.RequestInterface) message.ReplyInterface {
+		if err := independent.unavailable(depIds); err != nil {
+			return req.Fail(err.Error())
+		}
+		deps, err := independent.resolveDeps(depIds)
+		if err != nil {
+			return req.Fail(fmt.Sprintf("resolveDeps: %v", err))
+		}
+		return handle(req, deps)
+	}
+	for _, handler := range handlers {
+		if err := handler.Route(command, route); err != nil {
+			return fmt.Errorf("handler('%s').Route('%s'): %w", category, command, err)
+		}
+	}
+
+	return nil
+}
+
+// The resolveDeps returns the clients of the extensions
+func (independent *Service) resolveDeps(ids []string) (Deps, error) {
+	deps := make(Deps, len(ids))
+	for _, id := range ids {
+		depClient, err := independent.resolveDep(id)
+		if err != nil {
+			return nil, fmt.Errorf("resolveDep('%s'): %w", id, err)
+		}
+		deps[id] = depClient
+	}
+	return deps, nil
+}
+
+// The resolveDep returns the cached client of the extension, or connects to it.
+// The client connects to the first handler of the extension.
+// The declared limits are applied to the extension when it's connected, see limitDep.
+func (independent *Service) resolveDep(id string) (*SyncClient, error) {
+	independent.depsMu.Lock()
+	defer independent.depsMu.Unlock()
+
+	if depClient, ok := independent.deps[id]; ok {
+		return depClient, nil
+	}
+
+	serviceConf, err := independent.ctx.Config().Service(id)
+	if err != nil {
+		return nil, fmt.Errorf("ctx.Config().Service: %w", err)
+	}
+	if serviceConf.Manager != nil {
+		serviceConf.Manager.UrlFunc(clientConfig.Url)
+		if !running(serviceConf.Manager) {
+			return nil, fmt.Errorf("the extension is not running")
+		}
+		if err := independent.limitDep(id, serviceConf.Manager); err != nil {
+			return nil, fmt.Errorf("limitDep: %w", err)
+		}
+	}
+	if len(serviceConf.Handlers) == 0 {
+		return nil, fmt.Errorf("the extension has no handlers")
+	}
+
+	h := serviceConf.Handlers[0]
+	depConfig := clientConfig.New(serviceConf.Url, h.Id, h.Port, handlerConfig.SocketType(h.Type))
+	depConfig.UrlFunc(clientConfig.Url)
+	socket, err := client.New(depConfig)
+	if err != nil {
+		return nil, fmt.Errorf("client.New: %w", err)
+	}
+	depClient := newSyncClient(socket)
+	independent.watchEndpoint("dep:"+id, h.Type, depConfig.Url())
+
+	if independent.deps == nil {
+		independent.deps = make(Deps)
+	}
+	independent.deps[id] = depClient
+	return depClient, nil
+}
+
+// The closeDeps closes the clients of the extensions in the shutdown order.
+// Each client is closed after its pending request returned.
+func (independent *Service) closeDeps() {
+	independent.depsMu.Lock()
+	defer independent.depsMu.Unlock()
+
+	ids := make([]string, 0, len(independent.deps))
+	for id := range independent.deps {
+		ids = append(ids, id)
+	}
+	for _, id := range independent.shutdownOrder(ids) {
+		if err := independent.deps[id].Close(); err != nil {
+			independent.Logger.Warn("depClient.Close", "id", id, "error", err)
+		}
+	}
+	independent.deps = nil
+}
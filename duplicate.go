@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	clientConfig "github.com/ahmetson/client-lib/config"
+	"github.com/ahmetson/os-lib/arg"
+	"github.com/ahmetson/service-lib/flag"
+	"github.com/ahmetson/service-lib/manager"
+	"time"
+)
+
+// DuplicateTimeout is how long the manager of the running duplicate is waited to reply or to close
+const DuplicateTimeout = time.Second * 2
+
+// The running returns true if the manager of the configuration replies to the heartbeat.
+func running(managerConfig *clientConfig.Client) bool {
+	managerClient, err := manager.NewClient(managerConfig)
+	if err != nil {
+		return false
+	}
+
+	heartbeat := make(chan error, 1)
+	go func() {
+		heartbeat <- managerClient.Heartbeat()
+		_ = managerClient.Socket.Close()
+	}()
+
+	select {
+	case err := <-heartbeat:
+		return err == nil
+	case <-time.After(DuplicateTimeout):
+		return false
+	}
+}
+
+// The checkDuplicate returns an error if another process runs the service with the same id.
+// Two processes would fight over the same ports and proxy units.
+//
+// With flag.TakeoverFlag, the running process is closed instead.
+// With flag.UpgradeFlag, the running process is replaced without the gap, see prepareUpgrade.
+func (independent *Service) checkDuplicate() error {
+	configClient := independent.ctx.Config()
+	exist, err := configClient.ServiceExist(independent.id)
+	if err != nil {
+		return fmt.Errorf("configClient.ServiceExist('%s'): %w", independent.id, err)
+	}
+	if !exist {
+		return nil
+	}
+
+	serviceConf, err := configClient.Service(independent.id)
+	if err != nil {
+		return fmt.Errorf("configClient.Service('%s'): %w", independent.id, err)
+	}
+	if serviceConf.Manager == nil {
+		return nil
+	}
+	serviceConf.Manager.UrlFunc(clientConfig.Url)
+	if !running(serviceConf.Manager) {
+		return nil
+	}
+
+	if arg.FlagExist(flag.UpgradeFlag) {
+		if err := independent.prepareUpgrade(serviceConf.Manager); err != nil {
+			return fmt.Errorf("prepareUpgrade: %w", err)
+		}
+		return nil
+	}
+	if !arg.FlagExist(flag.TakeoverFlag) {
+		return fmt.Errorf("the '%s' service is running in another process, close it or start with --%s or --%s flag",
+			independent.id, flag.TakeoverFlag, flag.UpgradeFlag)
+	}
+
+	independent.Logger.Warn("taking over the running service", "id", independent.id)
+	managerClient, err := manager.NewClient(serviceConf.Manager)
+	if err != nil {
+		return fmt.Errorf("manager.NewClient: %w", err)
+	}
+	err = managerClient.Close()
+	_ = managerClient.Socket.Close()
+	if err != nil {
+		return fmt.Errorf("managerClient.Close: %w", err)
+	}
+
+	// the running service drains the proxies before closing
+	deadline := time.Now().Add(manager.DrainPeriod + DuplicateTimeout)
+	for running(serviceConf.Manager) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the '%s' service in another process is not closed", independent.id)
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	return nil
+}
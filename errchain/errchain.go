@@ -0,0 +1,88 @@
+// Package errchain tracks where in the chain of the services the request failed.
+//
+// When the request traverses the proxies and the extensions, each hop adds itself to the chain
+// of the failed reply.
+// The originating client parses the error message of the failed reply and sees the hops.
+//
+// The chain is encoded in the error message, so it passes through the services
+// that don't know about the chain as a plain error message.
+package errchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Prefix marks the error message with the encoded chain
+const Prefix = "error_chain:"
+
+// Hop is the service that the failed reply passed.
+// The Error is empty if the service only forwarded the failed reply.
+type Hop struct {
+	ServiceId string `json:"service_id"`
+	Command   string `json:"command"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Chain is the list of the hops starting from the service where the failure occurred.
+// The chain implements the error interface.
+type Chain []Hop
+
+// Parse returns the chain encoded in the error message.
+// If the message is a plain error message, then the chain has one hop with the unknown service.
+func Parse(errMessage string) Chain {
+	if strings.HasPrefix(errMessage, Prefix) {
+		var chain Chain
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(errMessage, Prefix)), &chain); err == nil {
+			return chain
+		}
+	}
+
+	return Chain{{Error: errMessage}}
+}
+
+// New returns the chain with the failure in the service
+func New(serviceId string, command string, err error) Chain {
+	return Chain{{ServiceId: serviceId, Command: command, Error: err.Error()}}
+}
+
+// Forward adds the service that forwards the failed reply received from the next hop.
+// The errMessage is the error message of the received reply.
+func Forward(errMessage string, serviceId string, command string) Chain {
+	return append(Parse(errMessage), Hop{ServiceId: serviceId, Command: command})
+}
+
+// Origin returns the hop where the failure occurred
+func (chain Chain) Origin() Hop {
+	if len(chain) == 0 {
+		return Hop{}
+	}
+	return chain[0]
+}
+
+// Encode returns the error message to pass to the failed reply
+func (chain Chain) Encode() string {
+	data, err := json.Marshal(chain)
+	if err != nil {
+		return chain.Error()
+	}
+	return Prefix + string(data)
+}
+
+// Error returns the human-readable chain from the origin to the last hop
+func (chain Chain) Error() string {
+	hops := make([]string, len(chain))
+	for i, hop := range chain {
+		serviceId := hop.ServiceId
+		if len(serviceId) == 0 {
+			serviceId = "unknown"
+		}
+		if len(hop.Error) == 0 {
+			hops[i] = fmt.Sprintf("via %s(%s)", serviceId, hop.Command)
+		} else {
+			hops[i] = fmt.Sprintf("%s(%s): %s", serviceId, hop.Command, hop.Error)
+		}
+	}
+	return strings.Join(hops, " <- ")
+}
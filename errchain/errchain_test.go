@@ -0,0 +1,40 @@
+package errchain
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestErrChainSuite struct {
+	suite.Suite
+}
+
+// Test_10_Forward tests the chain passed through the proxies
+func (test *TestErrChainSuite) Test_10_Forward() {
+	s := test.Require
+
+	// the destination doesn't know about the chain
+	chain := Forward("not found", "proxy_1", "get_logs")
+	s().Len(chain, 2)
+	s().Equal("not found", chain.Origin().Error)
+
+	chain = Forward(chain.Encode(), "proxy_2", "get_logs")
+	s().Len(chain, 3)
+	s().Equal("proxy_2", chain[2].ServiceId)
+	s().Equal("unknown(): not found <- via proxy_1(get_logs) <- via proxy_2(get_logs)", chain.Error())
+
+	chain = New("service_1", "get_logs", fmt.Errorf("database is down"))
+	parsed := Parse(chain.Encode())
+	s().Equal(chain, parsed)
+	s().Equal("service_1", parsed.Origin().ServiceId)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestErrChain(t *testing.T) {
+	suite.Run(t, new(TestErrChainSuite))
+}
@@ -0,0 +1,69 @@
+// Package errs aggregates the errors of the independent steps, such as closing the started phases.
+//
+// Nesting the errors by fmt.Errorf("%v: %w") keeps only the last error reachable.
+// The Multi keeps all errors reachable by errors.Is and errors.As:
+//
+//	err := errs.Join(startErr, errs.Wrap("ctx.Close", ctx.Close()))
+//	errors.Is(err, startErr) // true
+package errs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Separator of the errors in the Multi message
+const Separator = "; "
+
+// Multi is the list of the errors in the order they occurred.
+// The message is the messages of the errors joined by the Separator.
+type Multi []error
+
+// Error returns the messages of the errors in the order they occurred
+func (multi Multi) Error() string {
+	messages := make([]string, len(multi))
+	for i, err := range multi {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, Separator)
+}
+
+// Unwrap returns the errors for errors.Is and errors.As
+func (multi Multi) Unwrap() []error {
+	return multi
+}
+
+// Join returns the errors as the Multi.
+// The nil errors are skipped, and the nested Multi errors are flattened.
+// Returns nil if there is no error, and the error itself if there is one error.
+func Join(errs ...error) error {
+	multi := make(Multi, 0, len(errs))
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if nested, ok := err.(Multi); ok {
+			multi = append(multi, nested...)
+			continue
+		}
+		multi = append(multi, err)
+	}
+
+	switch len(multi) {
+	case 0:
+		return nil
+	case 1:
+		return multi[0]
+	default:
+		return multi
+	}
+}
+
+// Wrap adds the step to the error message.
+// Returns nil if the error is nil, so the result can be passed to Join directly.
+func Wrap(step string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", step, err)
+}
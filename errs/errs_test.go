@@ -0,0 +1,54 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestErrsSuite struct {
+	suite.Suite
+}
+
+// Test_10_Join tests aggregating the errors
+func (test *TestErrsSuite) Test_10_Join() {
+	s := test.Require
+
+	s().NoError(Join())
+	s().NoError(Join(nil, nil))
+	s().NoError(Wrap("ctx.Close", nil))
+
+	startErr := fmt.Errorf("start failed")
+	s().Equal(startErr, Join(nil, startErr))
+
+	closeErr := Wrap("ctx.Close", os.ErrClosed)
+	err := Join(startErr, nil, closeErr)
+	s().Equal("start failed; ctx.Close: file already closed", err.Error())
+	s().ErrorIs(err, startErr)
+	s().ErrorIs(err, os.ErrClosed)
+
+	// the nested errors are flattened
+	hookErr := fmt.Errorf("hook failed")
+	err = Join(err, hookErr)
+	var multi Multi
+	s().True(errors.As(err, &multi))
+	s().Len(multi, 3)
+	s().Equal("start failed; ctx.Close: file already closed; hook failed", err.Error())
+
+	// the wrapped multi error is reachable too
+	var pathErr *os.PathError
+	err = Wrap("teardown", Join(startErr, &os.PathError{Op: "open", Path: "app.yml", Err: os.ErrNotExist}))
+	s().True(errors.As(err, &pathErr))
+	s().ErrorIs(err, os.ErrNotExist)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestErrs(t *testing.T) {
+	suite.Run(t, new(TestErrsSuite))
+}
@@ -0,0 +1,71 @@
+//go:build examples
+
+// The broadcast example publishes the sequenced broadcasts by the publisher and receives them by the subscriber.
+// The subscriber passes the broadcasts through the tracker to process each broadcast once,
+// and to detect the missed broadcasts.
+package main
+
+import (
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/publisher"
+	"github.com/ahmetson/service-lib/subscriber"
+	"log"
+	"time"
+)
+
+const (
+	url   = "tcp://127.0.0.1:6100"
+	topic = "prices"
+)
+
+// receive processes the broadcast once, the gaps are skipped in the example.
+// The service's subscribers request the gaps by manager.Client.CatchUp.
+func receive(tracker *broadcast.Tracker, sequenced broadcast.Sequenced) bool {
+	accepted, gap := tracker.Accept(sequenced)
+	if gap != nil {
+		log.Printf("missed %s broadcasts %d-%d", gap.Topic, gap.From, gap.To)
+		tracker.Skip(gap.Topic, gap.To)
+		accepted, _ = tracker.Accept(sequenced)
+	}
+	return accepted
+}
+
+func main() {
+	pub, err := publisher.New(url, broadcast.NewFeed(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := pub.Start(); err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		_ = pub.Close()
+	}()
+
+	sub, err := subscriber.New(url, topic)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := sub.Start(); err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		_ = sub.Close()
+	}()
+	// the late subscribers miss the broadcasts, let it connect
+	time.Sleep(time.Millisecond * 200)
+
+	for i := 0; i < 3; i++ {
+		if _, err := pub.Publish(topic, map[string]interface{}{"price": 100 + i}); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	tracker := broadcast.NewTracker()
+	for i := 0; i < 3; i++ {
+		sequenced := <-sub.Broadcasts()
+		if receive(tracker, sequenced) {
+			log.Printf("%s #%d: %v", sequenced.Topic, sequenced.Seq, sequenced.Parameters)
+		}
+	}
+}
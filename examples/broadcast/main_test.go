@@ -0,0 +1,33 @@
+//go:build examples
+
+package main
+
+import (
+	"github.com/ahmetson/service-lib/broadcast"
+	"testing"
+)
+
+// TestReceive is the smoke test of the gap detection, the sockets are not created
+func TestReceive(t *testing.T) {
+	feed := broadcast.NewFeed(0)
+	var sent []broadcast.Sequenced
+	for i := 0; i < 3; i++ {
+		sequenced, err := feed.Next(topic, map[string]interface{}{"price": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, sequenced)
+	}
+
+	tracker := broadcast.NewTracker()
+	if !receive(tracker, sent[0]) {
+		t.Fatal("the first broadcast is not accepted")
+	}
+	// the second broadcast is missed
+	if !receive(tracker, sent[2]) {
+		t.Fatal("the broadcast after the gap is not accepted")
+	}
+	if receive(tracker, sent[2]) {
+		t.Fatal("the duplicate is accepted")
+	}
+}
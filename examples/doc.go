@@ -0,0 +1,12 @@
+// Package examples is the gallery of the runnable programs using the library:
+//
+//   - proxychain puts the auth and rate limit proxies in front of the handler.
+//   - extension routes the command depending on the extension's client.
+//   - managercommand adds a subcommand to the service binary, calling the manager of the running service.
+//   - broadcast publishes the sequenced broadcasts and receives them with the gap detection.
+//
+// The examples are gated by the examples build tag:
+//
+//	go run -tags examples ./examples/proxychain --id=proxychain-example --url=github.com/ahmetson/service-lib/examples/proxychain
+//	go test -tags examples ./examples/...
+package examples
@@ -0,0 +1,68 @@
+//go:build examples
+
+// The extension example routes the "user" command, which reads the user from the database extension.
+// The extension is started by the orchestra, and its client is passed to the route.
+package main
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/handler-lib/replier"
+	service "github.com/ahmetson/service-lib"
+	"log"
+)
+
+const (
+	databaseId  = "database"
+	databaseUrl = "github.com/ahmetson/database-extension"
+)
+
+// onUser requests the user from the database extension
+func onUser(req message.RequestInterface, deps service.Deps) message.ReplyInterface {
+	name, err := req.RouteParameters().StringValue("name")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('name'): %v", err))
+	}
+
+	reply, err := deps[databaseId].Request(&message.Request{
+		Command:    "get",
+		Parameters: key_value.New().Set("name", name),
+	})
+	if err != nil {
+		return req.Fail(fmt.Sprintf("database.Request: %v", err))
+	}
+	if !reply.IsOK() {
+		return req.Fail(reply.ErrorMessage())
+	}
+
+	return req.Ok(reply.ReplyParameters())
+}
+
+// setup adds the handler with the route depending on the extension
+func setup(independent *service.Service) error {
+	independent.SetHandler("main", replier.New())
+	independent.RequireExtension(databaseId, databaseUrl)
+	independent.RequireCommands(databaseId, "get")
+
+	if err := independent.RouteDeps("main", "user", []string{databaseId}, onUser); err != nil {
+		return fmt.Errorf("RouteDeps: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	independent, err := service.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := setup(independent); err != nil {
+		log.Fatal(err)
+	}
+
+	wg, err := independent.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,27 @@
+//go:build examples
+
+package main
+
+import (
+	"github.com/ahmetson/service-lib/servicetest"
+	"testing"
+)
+
+// TestSetup is the smoke test of the route and the contract, the extension is not started
+func TestSetup(t *testing.T) {
+	orchestra, err := servicetest.New("extension-example", "github.com/ahmetson/service-lib/examples/extension")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = orchestra.Close()
+	}()
+
+	if err := setup(orchestra.Service); err != nil {
+		t.Fatal(err)
+	}
+	contracts := orchestra.Service.Contracts()
+	if len(contracts) != 1 || contracts[0].Id != databaseId {
+		t.Fatalf("expected the contract of the '%s' extension, got %v", databaseId, contracts)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build examples
+
+// The managercommand example adds the "handlers" subcommand to the service binary.
+// The subcommand asks the manager of the running service for the commands of its handlers:
+//
+//	managercommand handlers --manager-port=<port>
+//
+// Without the subcommand, the service is started.
+package main
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/handler-lib/replier"
+	service "github.com/ahmetson/service-lib"
+	"github.com/ahmetson/service-lib/flag"
+	"github.com/ahmetson/service-lib/manager"
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+// handlers prints the commands of the running service by the handler category
+func handlers(c *manager.Client, out io.Writer, _ []string) error {
+	commands, err := c.HandlerCommands()
+	if err != nil {
+		return fmt.Errorf("c.HandlerCommands: %w", err)
+	}
+
+	categories := make([]string, 0, len(commands))
+	for category := range commands {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		if _, err := fmt.Fprintf(out, "%s: %v\n", category, commands[category]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run() error {
+	independent, err := service.New()
+	if err != nil {
+		return fmt.Errorf("service.New: %w", err)
+	}
+
+	handler := replier.New()
+	err = handler.Route("hello", func(req message.RequestInterface) message.ReplyInterface {
+		return req.Ok(key_value.New().Set("message", "hello"))
+	})
+	if err != nil {
+		return fmt.Errorf("handler.Route: %w", err)
+	}
+	independent.SetHandler("main", handler)
+
+	wg, err := independent.Start()
+	if err != nil {
+		return fmt.Errorf("independent.Start: %w", err)
+	}
+	wg.Wait()
+	return nil
+}
+
+// newCli returns the command line interface with the custom subcommand
+func newCli() (*flag.Cli, error) {
+	cli := flag.NewCli(run)
+	err := cli.Add(flag.Command{
+		Name:        "handlers",
+		Description: "print the commands of the running service by the handler",
+		Run:         handlers,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cli.Add: %w", err)
+	}
+	return cli, nil
+}
+
+func main() {
+	cli, err := newCli()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cli.Execute(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
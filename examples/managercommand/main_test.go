@@ -0,0 +1,26 @@
+//go:build examples
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestCli is the smoke test of the custom subcommand in the help, the service is not started
+func TestCli(t *testing.T) {
+	cli, err := newCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cli.SetOutput(&out)
+	if err := cli.Execute([]string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "handlers") {
+		t.Fatalf("the help has no 'handlers' subcommand:\n%s", out.String())
+	}
+}
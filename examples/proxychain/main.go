@@ -0,0 +1,66 @@
+//go:build examples
+
+// The proxychain example puts the auth and rate limit proxies in front of the "main" handler.
+// The proxies are downloaded and started by the orchestra on Start.
+package main
+
+import (
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/handler-lib/replier"
+	service "github.com/ahmetson/service-lib"
+	"github.com/ahmetson/service-lib/proxychain"
+	"github.com/ahmetson/service-lib/rule"
+	"log"
+)
+
+const (
+	authProxy      = "github.com/ahmetson/auth-proxy"
+	rateLimitProxy = "github.com/ahmetson/rate-limit-proxy"
+)
+
+// chain returns the proxy chain of the "hello" command of the service
+func chain(url string) (*serviceConfig.ProxyChain, error) {
+	destination, err := rule.New().Url(url).Categories("main").Commands("hello").Build()
+	if err != nil {
+		return nil, fmt.Errorf("rule.Build: %w", err)
+	}
+
+	return proxychain.New().
+		WithAuth(authProxy).
+		WithRateLimit(rateLimitProxy).
+		To(destination)
+}
+
+func onHello(req message.RequestInterface) message.ReplyInterface {
+	return req.Ok(key_value.New().Set("message", "hello through the proxies"))
+}
+
+func main() {
+	independent, err := service.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	handler := replier.New()
+	if err := handler.Route("hello", onHello); err != nil {
+		log.Fatal(err)
+	}
+	independent.SetHandler("main", handler)
+
+	proxyChain, err := chain(independent.Url())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := independent.SetProxyChain(proxyChain); err != nil {
+		log.Fatal(err)
+	}
+
+	wg, err := independent.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	wg.Wait()
+}
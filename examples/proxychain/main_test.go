@@ -0,0 +1,22 @@
+//go:build examples
+
+package main
+
+import (
+	"github.com/ahmetson/service-lib/proxychain"
+	"testing"
+)
+
+// TestChain is the smoke test of the proxy chain, the proxies are not downloaded
+func TestChain(t *testing.T) {
+	proxyChain, err := chain("github.com/ahmetson/service-lib/examples/proxychain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proxyChain.Proxies) != 2 {
+		t.Fatalf("expected 2 proxies, got %d", len(proxyChain.Proxies))
+	}
+	if proxyChain.Proxies[0].Category != proxychain.Auth {
+		t.Fatalf("expected the auth proxy first, got '%s'", proxyChain.Proxies[0].Category)
+	}
+}
@@ -0,0 +1,116 @@
+// Package fanout sends the same request to multiple destinations concurrently and gathers the replies.
+//
+// Each destination has its own result, so one failed destination doesn't fail the others.
+// The destinations that didn't reply before the deadline get the DeadlineError.
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Result of the request to the destination
+type Result[Rep any] struct {
+	Destination string
+	Reply       Rep
+	Err         error
+	Duration    time.Duration
+}
+
+// Options of the scatter-gather
+type Options struct {
+	Deadline    time.Duration // for all destinations, 0 means no deadline
+	Concurrency int           // the maximum parallel requests, 0 means all at once
+}
+
+// DeadlineError is the result of the destination that didn't reply in time
+var DeadlineError = errors.New("deadline exceeded")
+
+// Gather sends the request to each destination, and returns the results in the order of the destinations.
+// With the limited concurrency, the requests are sent in the order of the destinations.
+// The send must return when the context is done, otherwise its result is dropped after the deadline.
+func Gather[Rep any](ctx context.Context, destinations []string, options Options, send func(ctx context.Context, destination string) (Rep, error)) []Result[Rep] {
+	if options.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Deadline)
+		defer cancel()
+	}
+	concurrency := options.Concurrency
+	if concurrency <= 0 || concurrency > len(destinations) {
+		concurrency = len(destinations)
+	}
+
+	results := make([]Result[Rep], len(destinations))
+	finished := make([]bool, len(destinations))
+	gathered := false
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+dispatch:
+	for i := range destinations {
+		// the slots are taken in the order of the destinations,
+		// so the destinations that didn't start before the deadline are the last ones
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			start := time.Now()
+			reply, err := send(ctx, destinations[i])
+
+			mu.Lock()
+			defer mu.Unlock()
+			// the late replies are dropped
+			if !gathered {
+				results[i] = Result[Rep]{Destination: destinations[i], Reply: reply, Err: err, Duration: time.Since(start)}
+				finished[i] = true
+			}
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	gathered = true
+	for i := range results {
+		if finished[i] {
+			continue
+		}
+		results[i] = Result[Rep]{Destination: destinations[i], Err: DeadlineError}
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			results[i].Err = ctx.Err()
+		}
+	}
+	return results
+}
+
+// Errors returns the failed results joined into one error, or nil if all destinations replied
+func Errors[Rep any](results []Result[Rep]) error {
+	var failed []error
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", result.Destination, result.Err))
+		}
+	}
+	return errors.Join(failed...)
+}
@@ -0,0 +1,99 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestFanoutSuite struct {
+	suite.Suite
+}
+
+// Test_10_Gather tests the results per destination
+func (test *TestFanoutSuite) Test_10_Gather() {
+	s := test.Require
+
+	destinations := []string{"a", "b", "slow", "c"}
+	results := Gather(context.Background(), destinations, Options{Deadline: time.Millisecond * 200},
+		func(ctx context.Context, destination string) (string, error) {
+			switch destination {
+			case "b":
+				return "", fmt.Errorf("failed")
+			case "slow":
+				select {
+				case <-ctx.Done():
+					return "", ctx.Err()
+				case <-time.After(time.Second):
+					return "late", nil
+				}
+			}
+			return "reply " + destination, nil
+		})
+
+	s().Len(results, 4)
+	s().Equal("a", results[0].Destination)
+	s().Equal("reply a", results[0].Reply)
+	s().NoError(results[0].Err)
+	s().ErrorContains(results[1].Err, "failed")
+	s().Error(results[2].Err)
+	s().Equal("reply c", results[3].Reply)
+
+	err := Errors(results)
+	s().ErrorContains(err, "b: failed")
+	s().ErrorContains(err, "slow:")
+	s().NoError(Errors(results[:1]))
+}
+
+// Test_11_Concurrency tests the limit of the parallel requests
+func (test *TestFanoutSuite) Test_11_Concurrency() {
+	s := test.Require
+
+	var running, maxRunning atomic.Int32
+	destinations := []string{"a", "b", "c", "d", "e", "f"}
+	results := Gather(context.Background(), destinations, Options{Concurrency: 2},
+		func(ctx context.Context, destination string) (int, error) {
+			current := running.Add(1)
+			for {
+				old := maxRunning.Load()
+				if current <= old || maxRunning.CompareAndSwap(old, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond * 10)
+			running.Add(-1)
+			return len(destination), nil
+		})
+
+	s().NoError(Errors(results))
+	s().LessOrEqual(maxRunning.Load(), int32(2))
+
+	// the deadline is reported for the destinations that didn't start
+	results = Gather(context.Background(), destinations, Options{Concurrency: 1, Deadline: time.Millisecond * 15},
+		func(ctx context.Context, destination string) (int, error) {
+			time.Sleep(time.Millisecond * 10)
+			return 1, nil
+		})
+	s().ErrorIs(results[len(results)-1].Err, DeadlineError)
+
+	// the requests are sent in the order of the destinations
+	var sent []string
+	Gather(context.Background(), destinations, Options{Concurrency: 1},
+		func(ctx context.Context, destination string) (int, error) {
+			sent = append(sent, destination)
+			return 1, nil
+		})
+	s().Equal(destinations, sent)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFanout(t *testing.T) {
+	suite.Run(t, new(TestFanoutSuite))
+}
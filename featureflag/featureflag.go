@@ -0,0 +1,131 @@
+// Package featureflag keeps the feature flags toggled while the service is running.
+//
+// The flag that was never set falls back to the environment variable, see Env.
+// It lets the orchestra set the flags for all services at once:
+//
+//	FLAG_NEW_CHECKOUT=true
+//
+// The flags set at runtime override the environment, and notify the watchers.
+package featureflag
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EnvPrefix is the prefix of the environment variables with the default flags
+const EnvPrefix = "FLAG_"
+
+// Validate returns an error if the flag name can not be used.
+func Validate(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("flag name is empty")
+	}
+	if strings.ContainsAny(name, " \t\n=") {
+		return fmt.Errorf("flag '%s' must not have the spaces or '='", name)
+	}
+	return nil
+}
+
+// Env returns the name of the environment variable with the default value of the flag.
+// The dashes and dots in the name are replaced by underscores.
+func Env(name string) string {
+	return EnvPrefix + strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(name))
+}
+
+// Watcher is called with the new value of the flag
+type Watcher = func(enabled bool)
+
+// Registry keeps the flags set at runtime and their watchers
+type Registry struct {
+	flags    map[string]bool
+	watchers map[string][]Watcher
+	changed  []func(name string, enabled bool)
+	mu       sync.RWMutex
+}
+
+// NewRegistry returns the registry without the flags
+func NewRegistry() *Registry {
+	return &Registry{
+		flags:    make(map[string]bool),
+		watchers: make(map[string][]Watcher),
+	}
+}
+
+// Enabled returns the value of the flag.
+// The flag that was never set is read from the environment, then it's disabled by default.
+// The nil registry reads the environment only.
+func (registry *Registry) Enabled(name string) bool {
+	if registry != nil {
+		registry.mu.RLock()
+		enabled, ok := registry.flags[name]
+		registry.mu.RUnlock()
+		if ok {
+			return enabled
+		}
+	}
+
+	enabled, err := strconv.ParseBool(os.Getenv(Env(name)))
+	return err == nil && enabled
+}
+
+// Set the flag.
+// If the value changed, then the watchers of the flag are called.
+// Returns true if the value changed.
+func (registry *Registry) Set(name string, enabled bool) (bool, error) {
+	if err := Validate(name); err != nil {
+		return false, err
+	}
+
+	previous := registry.Enabled(name)
+
+	registry.mu.Lock()
+	registry.flags[name] = enabled
+	watchers := append([]Watcher{}, registry.watchers[name]...)
+	changed := append([]func(string, bool){}, registry.changed...)
+	registry.mu.Unlock()
+
+	if previous == enabled {
+		return false, nil
+	}
+	for _, watcher := range watchers {
+		watcher(enabled)
+	}
+	for _, onChange := range changed {
+		onChange(name, enabled)
+	}
+	return true, nil
+}
+
+// Watch calls the watcher every time the flag changes
+func (registry *Registry) Watch(name string, watcher Watcher) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.watchers[name] = append(registry.watchers[name], watcher)
+}
+
+// OnChange calls the function every time any flag changes
+func (registry *Registry) OnChange(onChange func(name string, enabled bool)) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.changed = append(registry.changed, onChange)
+}
+
+// All returns the flags set at runtime.
+// The nil registry has no flags.
+func (registry *Registry) All() map[string]bool {
+	if registry == nil {
+		return map[string]bool{}
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return maps.Clone(registry.flags)
+}
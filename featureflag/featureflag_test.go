@@ -0,0 +1,80 @@
+package featureflag
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestFeatureFlagSuite struct {
+	suite.Suite
+}
+
+// Test_10_Env tests the defaults from the environment
+func (test *TestFeatureFlagSuite) Test_10_Env() {
+	s := test.Require
+
+	s().Equal("FLAG_NEW_CHECKOUT", Env("new-checkout"))
+	s().Error(Validate(""))
+	s().Error(Validate("new checkout"))
+
+	test.T().Setenv(Env("new-checkout"), "true")
+	registry := NewRegistry()
+	s().True(registry.Enabled("new-checkout"))
+	s().False(registry.Enabled("old-checkout"))
+
+	// the nil registry reads the environment
+	var empty *Registry
+	s().True(empty.Enabled("new-checkout"))
+	s().Empty(empty.All())
+
+	// runtime overrides the environment
+	changed, err := registry.Set("new-checkout", false)
+	s().NoError(err)
+	s().True(changed)
+	s().False(registry.Enabled("new-checkout"))
+}
+
+// Test_11_Watch tests the watchers called on the change only
+func (test *TestFeatureFlagSuite) Test_11_Watch() {
+	s := test.Require
+
+	registry := NewRegistry()
+	values := make([]bool, 0)
+	registry.Watch("beta", func(enabled bool) {
+		values = append(values, enabled)
+	})
+	names := make([]string, 0)
+	registry.OnChange(func(name string, _ bool) {
+		names = append(names, name)
+	})
+
+	changed, err := registry.Set("beta", true)
+	s().NoError(err)
+	s().True(changed)
+
+	// the same value is not a change
+	changed, err = registry.Set("beta", true)
+	s().NoError(err)
+	s().False(changed)
+
+	_, err = registry.Set("beta", false)
+	s().NoError(err)
+	_, err = registry.Set("alpha", true)
+	s().NoError(err)
+
+	s().Equal([]bool{true, false}, values)
+	s().Equal([]string{"beta", "beta", "alpha"}, names)
+	s().Equal(map[string]bool{"beta": false, "alpha": true}, registry.All())
+
+	_, err = registry.Set("", true)
+	s().Error(err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFeatureFlag(t *testing.T) {
+	suite.Run(t, new(TestFeatureFlagSuite))
+}
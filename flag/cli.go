@@ -0,0 +1,294 @@
+package flag
+
+import (
+	"encoding/json"
+	"fmt"
+	clientConfig "github.com/ahmetson/client-lib/config"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/service-lib/manager"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ManagerPortFlag is the port of the running service's manager, required by the subcommands except run
+const ManagerPortFlag = "manager-port"
+
+// RunFunc starts the service, see Cli.Execute
+type RunFunc = func() error
+
+// CommandFunc runs the subcommand against the running service.
+// The args are the positional arguments after the subcommand name.
+type CommandFunc = func(c *manager.Client, out io.Writer, args []string) error
+
+// Command is the subcommand of the service binary
+type Command struct {
+	Name        string // one or more words, for example "config print"
+	Description string
+	Run         CommandFunc
+}
+
+// Cli is the command line interface of the service binary.
+//
+//	func main() {
+//		cli := flag.NewCli(func() error { ... start the service ... })
+//		if err := cli.Execute(os.Args[1:]); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// Without the subcommand, the service is started.
+// The built-in subcommands are run, status, stop, config print, proxy list, call, snapshot and restore.
+// The services add their own by Add.
+type Cli struct {
+	name     string // the binary name in the help and completion
+	run      RunFunc
+	commands []Command
+	flags    []Flag
+	out      io.Writer
+}
+
+// NewCli returns the command line interface with the built-in subcommands
+func NewCli(run RunFunc) *Cli {
+	cli := &Cli{
+		name:     filepath.Base(os.Args[0]),
+		run:      run,
+		commands: make([]Command, 0),
+		flags:    builtinFlags(),
+		out:      os.Stdout,
+	}
+
+	_ = cli.Add(Command{Name: "status", Description: "print the state of the running service", Run: status})
+	_ = cli.Add(Command{Name: "stop", Description: "close the running service", Run: stop})
+	_ = cli.Add(Command{Name: "config print", Description: "print the configuration of the running service", Run: configPrint})
+	_ = cli.Add(Command{Name: "proxy list", Description: "print the proxies of the running service", Run: proxyList})
+	_ = cli.Add(Command{Name: "call", Description: "send the manager command: call <command> [key=value...]", Run: call})
+	_ = cli.Add(Command{Name: "snapshot", Description: "write the state into the tarball on the service's host: snapshot <path>", Run: snapshot})
+	_ = cli.Add(Command{Name: "restore", Description: "restore the state from the tarball on the service's host: restore <path>", Run: restore})
+
+	return cli
+}
+
+// SetOutput sets the writer of the subcommands, by default os.Stdout
+func (cli *Cli) SetOutput(out io.Writer) {
+	cli.out = out
+}
+
+// Add the custom subcommand
+func (cli *Cli) Add(command Command) error {
+	if len(command.Name) == 0 || command.Run == nil {
+		return fmt.Errorf("the command must have a name and a run function")
+	}
+	if command.Name == "run" || command.Name == "help" || command.Name == "completion" {
+		return fmt.Errorf("'%s' is reserved", command.Name)
+	}
+	for _, added := range cli.commands {
+		if added.Name == command.Name {
+			return fmt.Errorf("the '%s' command added already", command.Name)
+		}
+	}
+	cli.commands = append(cli.commands, command)
+	return nil
+}
+
+// positional returns the arguments that are not flags
+func positional(args []string) []string {
+	words := make([]string, 0, len(args))
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		words = append(words, arg)
+	}
+	return words
+}
+
+// flagValue returns the value of --name=value from the arguments
+func flagValue(args []string, name string) (string, bool) {
+	prefix := "--" + name + "="
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			return strings.TrimPrefix(arg, prefix), true
+		}
+	}
+	return "", false
+}
+
+// match returns the subcommand by the positional arguments, and the rest of the arguments.
+// The longest name matches, so "config print" wins over "config".
+func (cli *Cli) match(words []string) (*Command, []string) {
+	var matched *Command
+	matchedLen := 0
+	for i := range cli.commands {
+		name := strings.Fields(cli.commands[i].Name)
+		if len(name) > len(words) || len(name) <= matchedLen {
+			continue
+		}
+		if strings.Join(words[:len(name)], " ") == cli.commands[i].Name {
+			matched = &cli.commands[i]
+			matchedLen = len(name)
+		}
+	}
+	if matched == nil {
+		return nil, nil
+	}
+	return matched, words[matchedLen:]
+}
+
+// The managerClient connects to the manager of the running service by the flags
+func managerClient(args []string) (*manager.Client, error) {
+	url, ok := flagValue(args, UrlFlag)
+	if !ok {
+		url = os.Getenv(UrlEnv)
+	}
+	rawPort, ok := flagValue(args, ManagerPortFlag)
+	if !ok {
+		return nil, fmt.Errorf("missing --%s flag", ManagerPortFlag)
+	}
+	port, err := strconv.ParseUint(rawPort, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("strconv.ParseUint('%s'): %w", rawPort, err)
+	}
+
+	socketType := handlerConfig.SocketType(handlerConfig.SyncReplierType)
+	c := clientConfig.New(url, ManagerName(url), port, socketType)
+	c.UrlFunc(clientConfig.Url)
+
+	managerClient, err := manager.NewClient(c)
+	if err != nil {
+		return nil, fmt.Errorf("manager.NewClient: %w", err)
+	}
+	return managerClient, nil
+}
+
+// Execute the subcommand from the arguments, without the binary name.
+// Without the subcommand, or with the run subcommand, the service is started.
+func (cli *Cli) Execute(args []string) error {
+	words := positional(args)
+	if hasHelp(args) || (len(words) > 0 && words[0] == "help") {
+		return cli.Help(cli.out, cli.managerCommands(args))
+	}
+	if len(words) > 0 && words[0] == "completion" {
+		shell := "bash"
+		if len(words) > 1 {
+			shell = words[1]
+		}
+		return cli.Completion(cli.out, shell, cli.managerCommands(args))
+	}
+	if len(words) == 0 || words[0] == "run" {
+		return cli.run()
+	}
+
+	command, rest := cli.match(words)
+	if command == nil {
+		return fmt.Errorf("unknown command '%s'", strings.Join(words, " "))
+	}
+
+	c, err := managerClient(args)
+	if err != nil {
+		return fmt.Errorf("managerClient: %w", err)
+	}
+	defer func() {
+		_ = c.Socket.Close()
+	}()
+
+	if err := command.Run(c, cli.out, rest); err != nil {
+		return fmt.Errorf("%s: %w", command.Name, err)
+	}
+	return nil
+}
+
+// printJson writes the value as the indented JSON
+func printJson(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("encoder.Encode: %w", err)
+	}
+	return nil
+}
+
+func status(c *manager.Client, out io.Writer, _ []string) error {
+	params, err := c.Status()
+	if err != nil {
+		return fmt.Errorf("c.Status: %w", err)
+	}
+	return printJson(out, params)
+}
+
+func stop(c *manager.Client, out io.Writer, _ []string) error {
+	if err := c.Close(); err != nil {
+		return fmt.Errorf("c.Close: %w", err)
+	}
+	_, err := fmt.Fprintln(out, "stopped")
+	return err
+}
+
+func configPrint(c *manager.Client, out io.Writer, _ []string) error {
+	serviceConf, err := c.Config()
+	if err != nil {
+		return fmt.Errorf("c.Config: %w", err)
+	}
+	return printJson(out, serviceConf)
+}
+
+func proxyList(c *manager.Client, out io.Writer, _ []string) error {
+	serviceConf, err := c.Config()
+	if err != nil {
+		return fmt.Errorf("c.Config: %w", err)
+	}
+	for _, source := range serviceConf.Sources {
+		for _, proxy := range source.Proxies {
+			if _, err := fmt.Fprintf(out, "%s\t%s\t%s\n", proxy.Id, proxy.Url, proxy.Category); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func snapshot(c *manager.Client, out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the tarball path")
+	}
+	if err := c.Snapshot(args[0]); err != nil {
+		return fmt.Errorf("c.Snapshot: %w", err)
+	}
+	_, err := fmt.Fprintln(out, "snapshot written to", args[0])
+	return err
+}
+
+func restore(c *manager.Client, out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the tarball path")
+	}
+	if err := c.Restore(args[0]); err != nil {
+		return fmt.Errorf("c.Restore: %w", err)
+	}
+	_, err := fmt.Fprintln(out, "restored from", args[0])
+	return err
+}
+
+// call sends the command with the key=value parameters
+func call(c *manager.Client, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing the command name")
+	}
+	parameters := key_value.New()
+	for _, pair := range args[1:] {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("'%s' parameter is not key=value", pair)
+		}
+		parameters.Set(key, value)
+	}
+
+	params, err := c.Call(args[0], parameters)
+	if err != nil {
+		return fmt.Errorf("c.Call('%s'): %w", args[0], err)
+	}
+	return printJson(out, params)
+}
@@ -0,0 +1,141 @@
+package flag
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ahmetson/service-lib/manager"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestCliSuite struct {
+	suite.Suite
+
+	cli *Cli
+	ran int
+	out *bytes.Buffer
+}
+
+func (test *TestCliSuite) SetupTest() {
+	test.ran = 0
+	test.out = &bytes.Buffer{}
+	test.cli = NewCli(func() error {
+		test.ran++
+		return nil
+	})
+	test.cli.SetOutput(test.out)
+}
+
+func noop(_ *manager.Client, _ io.Writer, _ []string) error {
+	return nil
+}
+
+// Test_10_Add tests the validation of the custom subcommands
+func (test *TestCliSuite) Test_10_Add() {
+	s := test.Require
+
+	s().Error(test.cli.Add(Command{Name: "", Run: noop}))
+	s().Error(test.cli.Add(Command{Name: "custom"}))
+
+	// the reserved and the built-in names are rejected
+	s().Error(test.cli.Add(Command{Name: "run", Run: noop}))
+	s().Error(test.cli.Add(Command{Name: "help", Run: noop}))
+	s().Error(test.cli.Add(Command{Name: "completion", Run: noop}))
+	s().Error(test.cli.Add(Command{Name: "status", Run: noop}))
+
+	s().NoError(test.cli.Add(Command{Name: "custom", Run: noop}))
+	s().Error(test.cli.Add(Command{Name: "custom", Run: noop}))
+}
+
+// Test_11_arguments tests the separation of the positional arguments and the flags
+func (test *TestCliSuite) Test_11_arguments() {
+	s := test.Require
+
+	args := []string{"config", "--manager-port=4000", "print", "-h", "--url=github.com/ahmetson/service"}
+	s().Equal([]string{"config", "print"}, positional(args))
+	s().Empty(positional(nil))
+
+	value, ok := flagValue(args, ManagerPortFlag)
+	s().True(ok)
+	s().Equal("4000", value)
+	value, ok = flagValue(args, UrlFlag)
+	s().True(ok)
+	s().Equal("github.com/ahmetson/service", value)
+
+	// the flag without the value is not matched
+	_, ok = flagValue([]string{"--manager-port"}, ManagerPortFlag)
+	s().False(ok)
+	_, ok = flagValue(args, IdFlag)
+	s().False(ok)
+}
+
+// Test_12_match tests that the longest subcommand name matches
+func (test *TestCliSuite) Test_12_match() {
+	s := test.Require
+
+	s().NoError(test.cli.Add(Command{Name: "config", Run: noop}))
+
+	command, rest := test.cli.match([]string{"config", "print", "extra"})
+	s().NotNil(command)
+	s().Equal("config print", command.Name)
+	s().Equal([]string{"extra"}, rest)
+
+	command, rest = test.cli.match([]string{"config", "show"})
+	s().NotNil(command)
+	s().Equal("config", command.Name)
+	s().Equal([]string{"show"}, rest)
+
+	command, rest = test.cli.match([]string{"call", "heartbeat", "key=value"})
+	s().NotNil(command)
+	s().Equal("call", command.Name)
+	s().Equal([]string{"heartbeat", "key=value"}, rest)
+
+	command, _ = test.cli.match([]string{"unknown"})
+	s().Nil(command)
+	command, _ = test.cli.match(nil)
+	s().Nil(command)
+}
+
+// Test_13_Execute tests the start of the service and the subcommand errors before connecting to the manager
+func (test *TestCliSuite) Test_13_Execute() {
+	s := test.Require
+
+	// without the subcommand, or with the run, the service is started
+	s().NoError(test.cli.Execute(nil))
+	s().NoError(test.cli.Execute([]string{"--id=service_1"}))
+	s().NoError(test.cli.Execute([]string{"run"}))
+	s().Equal(3, test.ran)
+
+	failed := NewCli(func() error {
+		return fmt.Errorf("failed")
+	})
+	s().ErrorContains(failed.Execute(nil), "failed")
+
+	s().ErrorContains(test.cli.Execute([]string{"unknown"}), "unknown command 'unknown'")
+
+	// the subcommands require the manager port
+	s().ErrorContains(test.cli.Execute([]string{"status"}), ManagerPortFlag)
+	s().Error(test.cli.Execute([]string{"status", "--manager-port=port"}))
+	s().Equal(3, test.ran)
+}
+
+// Test_14_call tests the parameters of the call subcommand
+func (test *TestCliSuite) Test_14_call() {
+	s := test.Require
+
+	s().ErrorContains(call(nil, test.out, nil), "missing the command name")
+	s().ErrorContains(call(nil, test.out, []string{"heartbeat", "invalid"}), "not key=value")
+	s().ErrorContains(snapshot(nil, test.out, nil), "expected the tarball path")
+	s().ErrorContains(restore(nil, test.out, []string{"a", "b"}), "expected the tarball path")
+	s().Empty(test.out.String())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestCli(t *testing.T) {
+	suite.Run(t, new(TestCliSuite))
+}
@@ -4,7 +4,31 @@ const (
 	IdFlag     = "id"
 	UrlFlag    = "url"
 	ParentFlag = "parent"
+	// NamespaceFlag is the tenant of the service, see the namespace package
+	NamespaceFlag = "namespace"
+	// TakeoverFlag closes the running process with the same service id, instead of failing the start
+	TakeoverFlag = "takeover"
+	// UpgradeFlag starts alongside the running process with the same service id, and takes over its state and proxy units.
+	// The running process drains and closes after the new one serves.
+	UpgradeFlag = "upgrade"
 
 	IdEnv  = "SERVICE_ID"
 	UrlEnv = "SERVICE_URL"
+	// NamespaceEnv is the tenant of the service.
+	// The proxies and extensions started by the service inherit it.
+	NamespaceEnv = "SERVICE_NAMESPACE"
+
+	// ContainerEnv enables the container mode if it's set to "true"
+	ContainerEnv = "SERVICE_CONTAINER"
+	// PortEnvPrefix is the prefix of the fixed handler port, followed by the upper-cased category.
+	// Use PortEnv to get the name.
+	PortEnvPrefix = "SERVICE_PORT_"
+	// HealthPortEnv is the port of the http health endpoint in the container mode
+	HealthPortEnv = "SERVICE_HEALTH_PORT"
+	// ReadyFileEnv is the file where the ready line is written when the service started
+	ReadyFileEnv = "SERVICE_READY_FILE"
+	// NotifySocketEnv is the systemd notify socket, notified when the service started
+	NotifySocketEnv = "NOTIFY_SOCKET"
+	// GracePeriodEnv is the graceful termination period in the container mode, for example "10s"
+	GracePeriodEnv = "SERVICE_GRACE_PERIOD"
 )
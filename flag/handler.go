@@ -0,0 +1,85 @@
+package flag
+
+import (
+	"fmt"
+	"github.com/ahmetson/service-lib/restart"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HandlerFlagPrefix is the prefix of the per-handler overrides:
+	//
+	//	--handler.<category>.port=<port>
+	//	--handler.<category>.instances=<amount>
+	//	--handler.<category>.restart=<policy>
+	//
+	// The handlers set by their id are overwritten by the id instead of the category.
+	HandlerFlagPrefix = "handler."
+	PortField         = "port"
+	InstancesField    = "instances"
+	RestartField      = "restart"
+)
+
+// Override is the handler configuration set by the flags.
+// The zero fields are not overwritten.
+type Override struct {
+	Port      uint64
+	Instances uint64
+	Restart   restart.Policy // the empty mode is not set
+}
+
+// HandlerOverrides returns the per-handler overrides by the category.
+// The arguments that don't start with HandlerFlagPrefix are skipped.
+func HandlerOverrides(args []string) (map[string]Override, error) {
+	overrides := make(map[string]Override)
+
+	for _, raw := range args {
+		name := strings.TrimLeft(raw, "-")
+		if name == raw || !strings.HasPrefix(name, HandlerFlagPrefix) {
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimPrefix(name, HandlerFlagPrefix), "=")
+		if !found {
+			return nil, fmt.Errorf("'%s' has no value", raw)
+		}
+		// the category may have the dots, the field is after the last one
+		dot := strings.LastIndex(key, ".")
+		if dot < 1 {
+			return nil, fmt.Errorf("'%s' has no category", raw)
+		}
+		category, field := key[:dot], key[dot+1:]
+
+		override := overrides[category]
+		switch field {
+		case PortField:
+			port, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("strconv.ParseUint('%s'): %w", raw, err)
+			}
+			override.Port = port
+		case InstancesField:
+			instances, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("strconv.ParseUint('%s'): %w", raw, err)
+			}
+			if instances == 0 {
+				return nil, fmt.Errorf("'%s' must be at least 1", raw)
+			}
+			override.Instances = instances
+		case RestartField:
+			policy, err := restart.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("restart.Parse('%s'): %w", raw, err)
+			}
+			override.Restart = policy
+		default:
+			return nil, fmt.Errorf("'%s' has unknown field '%s', expected '%s', '%s' or '%s'", raw, field,
+				PortField, InstancesField, RestartField)
+		}
+		overrides[category] = override
+	}
+
+	return overrides, nil
+}
@@ -0,0 +1,71 @@
+package flag
+
+import (
+	"github.com/ahmetson/service-lib/restart"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestHandlerSuite struct {
+	suite.Suite
+}
+
+// Test_10_HandlerOverrides tests the parsing of the per-handler flags
+func (test *TestHandlerSuite) Test_10_HandlerOverrides() {
+	s := test.Require
+
+	overrides, err := HandlerOverrides([]string{
+		"--id=service_1",
+		"handler.main.port=6000", // not a flag
+		"--handler.main.port=6100",
+		"--handler.main.instances=3",
+		"--handler.db.shard.1.restart=on-failure:2",
+	})
+	s().NoError(err)
+	s().Len(overrides, 2)
+	s().Equal(uint64(6100), overrides["main"].Port)
+	s().Equal(uint64(3), overrides["main"].Instances)
+	s().Empty(overrides["main"].Restart.Mode)
+
+	// the category may have the dots
+	s().Equal(restart.OnFailure, overrides["db.shard.1"].Restart.Mode)
+	s().Equal(2, overrides["db.shard.1"].Restart.MaxRetries)
+	s().Zero(overrides["db.shard.1"].Port)
+
+	// the last flag of the field wins
+	overrides, err = HandlerOverrides([]string{"--handler.main.port=6100", "--handler.main.port=6200"})
+	s().NoError(err)
+	s().Equal(uint64(6200), overrides["main"].Port)
+
+	overrides, err = HandlerOverrides(nil)
+	s().NoError(err)
+	s().Empty(overrides)
+}
+
+// Test_11_invalidOverrides tests the rejected per-handler flags
+func (test *TestHandlerSuite) Test_11_invalidOverrides() {
+	s := test.Require
+
+	invalid := []string{
+		"--handler.main.port",
+		"--handler.port=6100",
+		"--handler.main.port=port",
+		"--handler.main.port=70000",
+		"--handler.main.instances=0",
+		"--handler.main.restart=sometimes",
+		"--handler.main.host=localhost",
+	}
+	for _, raw := range invalid {
+		_, err := HandlerOverrides([]string{raw})
+		s().Error(err, raw)
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestHandler(t *testing.T) {
+	suite.Run(t, new(TestHandlerSuite))
+}
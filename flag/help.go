@@ -0,0 +1,163 @@
+package flag
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Flag is the command line flag shown in the help and completion
+type Flag struct {
+	Name  string
+	Usage string
+}
+
+// builtinFlags returns the flags read by the service and the command line interface
+func builtinFlags() []Flag {
+	return []Flag{
+		{Name: IdFlag, Usage: fmt.Sprintf("the unique id of the service, or %s environment variable", IdEnv)},
+		{Name: UrlFlag, Usage: fmt.Sprintf("the url of the service, or %s environment variable", UrlEnv)},
+		{Name: NamespaceFlag, Usage: fmt.Sprintf("the tenant of the service, or %s environment variable", NamespaceEnv)},
+		{Name: TakeoverFlag, Usage: "closes the running process with the same id, instead of failing the start"},
+		{Name: UpgradeFlag, Usage: "starts alongside the running process with the same id, then the old process drains and closes"},
+		{Name: ParentFlag, Usage: "the parent's manager configuration, set for the proxies and extensions"},
+		{Name: ManagerPortFlag, Usage: "the manager port of the running service, required by the subcommands"},
+		{Name: HandlerFlagPrefix + "<category>." + PortField, Usage: "overwrites the port of the handler"},
+		{Name: HandlerFlagPrefix + "<category>." + InstancesField, Usage: "overwrites the instance amount of the handler"},
+		{Name: HandlerFlagPrefix + "<category>." + RestartField, Usage: "the restart policy of the handler: never, on-failure[:max retries] or always"},
+	}
+}
+
+// AddFlag declares the custom flag of the service for the help and completion
+func (cli *Cli) AddFlag(name string, usage string) {
+	cli.flags = append(cli.flags, Flag{Name: name, Usage: usage})
+}
+
+// hasHelp returns true if the help flag is in the arguments
+func hasHelp(args []string) bool {
+	return slices.Contains(args, "--help") || slices.Contains(args, "-h")
+}
+
+// managerCommands returns the commands of the running service's manager.
+// If the service is not running, or the manager port is not given, then returns nil.
+func (cli *Cli) managerCommands(args []string) []string {
+	if _, ok := flagValue(args, ManagerPortFlag); !ok {
+		return nil
+	}
+	c, err := managerClient(args)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		_ = c.Socket.Close()
+	}()
+
+	commands, err := c.Commands()
+	if err != nil {
+		return nil
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// Help writes the usage of the binary.
+// The manager commands are listed if given, see Cli.Execute with --manager-port flag.
+func (cli *Cli) Help(out io.Writer, managerCommands []string) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Usage: %s [command] [flags]\n\nCommands:\n", cli.name)
+	fmt.Fprintf(&b, "  %-16s %s\n", "run", "start the service, the default command")
+	for _, command := range cli.commands {
+		fmt.Fprintf(&b, "  %-16s %s\n", command.Name, command.Description)
+	}
+	fmt.Fprintf(&b, "  %-16s %s\n", "completion", "print the completion script: completion bash|zsh")
+	fmt.Fprintf(&b, "  %-16s %s\n", "help", "print this help")
+
+	b.WriteString("\nFlags:\n")
+	for _, f := range cli.flags {
+		fmt.Fprintf(&b, "  --%-14s %s\n", f.Name, f.Usage)
+	}
+
+	if len(managerCommands) > 0 {
+		b.WriteString("\nManager commands, send by call:\n")
+		for _, command := range managerCommands {
+			fmt.Fprintf(&b, "  %s\n", command)
+		}
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// completionWords returns the words completed after the words of the prefix.
+// For example, "config" is completed by "print".
+func (cli *Cli) completionWords(prefix []string, managerCommands []string) []string {
+	words := make([]string, 0)
+	if len(prefix) == 1 && prefix[0] == "call" {
+		return managerCommands
+	}
+
+	for _, command := range cli.commands {
+		name := strings.Fields(command.Name)
+		if len(name) <= len(prefix) || !slices.Equal(name[:len(prefix)], prefix) {
+			continue
+		}
+		if !slices.Contains(words, name[len(prefix)]) {
+			words = append(words, name[len(prefix)])
+		}
+	}
+	if len(prefix) == 0 {
+		words = append(words, "run", "completion", "help")
+		for _, f := range cli.flags {
+			words = append(words, "--"+f.Name+"=")
+		}
+	}
+	return words
+}
+
+// Completion writes the completion script of the shell.
+// The zsh uses the bash completion through bashcompinit.
+//
+//	source <(service completion bash --manager-port=4000)
+func (cli *Cli) Completion(out io.Writer, shell string, managerCommands []string) error {
+	if shell != "bash" && shell != "zsh" {
+		return fmt.Errorf("unsupported '%s' shell, use bash or zsh", shell)
+	}
+	funcName := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(cli.name) + "_complete"
+
+	var b strings.Builder
+	if shell == "zsh" {
+		b.WriteString("autoload -U +X bashcompinit && bashcompinit\n")
+	}
+	fmt.Fprintf(&b, "%s() {\n", funcName)
+	b.WriteString("  local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("  if [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(&b, "    COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(cli.completionWords(nil, managerCommands), " "))
+	b.WriteString("    return\n  fi\n")
+	b.WriteString("  case \"${COMP_WORDS[1]}\" in\n")
+
+	firstWords := make([]string, 0)
+	for _, command := range append(cli.commands, Command{Name: "completion"}) {
+		first := strings.Fields(command.Name)[0]
+		if slices.Contains(firstWords, first) {
+			continue
+		}
+		firstWords = append(firstWords, first)
+
+		words := cli.completionWords([]string{first}, managerCommands)
+		if first == "completion" {
+			words = []string{"bash", "zsh"}
+		}
+		if len(words) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "    %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", first, strings.Join(words, " "))
+	}
+	b.WriteString("  esac\n}\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", funcName, cli.name)
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
@@ -0,0 +1,106 @@
+package flag
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestHelpSuite struct {
+	suite.Suite
+
+	cli *Cli
+}
+
+func (test *TestHelpSuite) SetupTest() {
+	test.cli = NewCli(func() error { return nil })
+	test.cli.name = "service-1.bin"
+}
+
+// Test_10_Help tests the usage listing the subcommands, the flags and the manager commands
+func (test *TestHelpSuite) Test_10_Help() {
+	s := test.Require
+
+	s().True(hasHelp([]string{"status", "--help"}))
+	s().True(hasHelp([]string{"-h"}))
+	s().False(hasHelp([]string{"help"}))
+
+	s().NoError(test.cli.Add(Command{Name: "db migrate", Description: "migrate the database", Run: noop}))
+	test.cli.AddFlag("db-url", "the database url")
+
+	out := &bytes.Buffer{}
+	s().NoError(test.cli.Help(out, nil))
+	help := out.String()
+	s().True(strings.HasPrefix(help, "Usage: service-1.bin [command] [flags]\n"))
+	s().Contains(help, "  run ")
+	s().Contains(help, "  config print ")
+	s().Contains(help, "  db migrate       migrate the database\n")
+	s().Contains(help, "  --"+IdFlag+" ")
+	s().Contains(help, "  --"+ManagerPortFlag+" ")
+	s().Contains(help, "  --db-url         the database url\n")
+	s().NotContains(help, "Manager commands")
+
+	// the subcommands are listed in the order of adding
+	s().Less(strings.Index(help, "  status "), strings.Index(help, "  db migrate "))
+
+	out.Reset()
+	s().NoError(test.cli.Help(out, []string{"heartbeat", "status"}))
+	s().Contains(out.String(), "\nManager commands, send by call:\n  heartbeat\n  status\n")
+
+	// without the manager port, the manager is not requested
+	s().Nil(test.cli.managerCommands([]string{"help"}))
+}
+
+// Test_11_completionWords tests the words completed after the prefix
+func (test *TestHelpSuite) Test_11_completionWords() {
+	s := test.Require
+
+	words := test.cli.completionWords(nil, nil)
+	s().Contains(words, "status")
+	s().Contains(words, "config")
+	s().Contains(words, "run")
+	s().Contains(words, "completion")
+	s().Contains(words, "--"+UrlFlag+"=")
+	s().NotContains(words, "print")
+
+	s().Equal([]string{"print"}, test.cli.completionWords([]string{"config"}, nil))
+	s().Equal([]string{"list"}, test.cli.completionWords([]string{"proxy"}, nil))
+	s().Empty(test.cli.completionWords([]string{"status"}, nil))
+
+	// the call is completed by the manager commands
+	s().Equal([]string{"heartbeat"}, test.cli.completionWords([]string{"call"}, []string{"heartbeat"}))
+}
+
+// Test_12_Completion tests the completion scripts
+func (test *TestHelpSuite) Test_12_Completion() {
+	s := test.Require
+
+	out := &bytes.Buffer{}
+	s().Error(test.cli.Completion(out, "fish", nil))
+	s().Empty(out.String())
+
+	s().NoError(test.cli.Completion(out, "bash", []string{"heartbeat"}))
+	script := out.String()
+	s().NotContains(script, "bashcompinit")
+	s().Contains(script, "_service_1_bin_complete() {\n")
+	s().Contains(script, "    config) COMPREPLY=($(compgen -W \"print\" -- \"$cur\")) ;;\n")
+	s().Contains(script, "    call) COMPREPLY=($(compgen -W \"heartbeat\" -- \"$cur\")) ;;\n")
+	s().Contains(script, "    completion) COMPREPLY=($(compgen -W \"bash zsh\" -- \"$cur\")) ;;\n")
+	s().NotContains(script, "    status)")
+	s().True(strings.HasSuffix(script, "complete -F _service_1_bin_complete service-1.bin\n"))
+
+	out.Reset()
+	s().NoError(test.cli.Completion(out, "zsh", nil))
+	s().True(strings.HasPrefix(out.String(), "autoload -U +X bashcompinit && bashcompinit\n"))
+	s().NotContains(out.String(), "    call)")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestHelp(t *testing.T) {
+	suite.Run(t, new(TestHelpSuite))
+}
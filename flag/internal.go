@@ -1,8 +1,24 @@
 package flag
 
-import "github.com/ahmetson/handler-lib/config"
+import (
+	"github.com/ahmetson/handler-lib/config"
+	"strings"
+)
 
 func ManagerName(url string) string {
 	fileName := config.UrlToFileName(url)
 	return "manager." + fileName
 }
+
+// LeaderLockName returns the file name of the leader lock shared by the instances of the service.
+func LeaderLockName(url string) string {
+	fileName := config.UrlToFileName(url)
+	return "leader." + fileName + ".lock"
+}
+
+// PortEnv returns the name of the environment variable with the fixed port of the handler.
+// The dashes and dots in the category are replaced by underscores.
+func PortEnv(category string) string {
+	name := strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(category))
+	return PortEnvPrefix + name
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/service-lib/featureflag"
+)
+
+// FlagChangedTopic is the topic of the broadcast added into the feed when the feature flag changes, see SetFeed.
+const FlagChangedTopic = "flag_changed"
+
+// Disabled is the prefix of the replies of the routes gated by the disabled feature flag
+const Disabled = "disabled"
+
+// Flag returns true if the feature flag is enabled.
+// The flag is toggled at runtime by the manager.Flag command, or by SetFlag.
+// Until then, it's read from the environment, see featureflag.Env.
+func (independent *Service) Flag(name string) bool {
+	return independent.flags.Enabled(name)
+}
+
+// SetFlag toggles the feature flag of this service.
+// The watchers set by OnFlag are called if the value changed.
+func (independent *Service) SetFlag(name string, enabled bool) error {
+	if _, err := independent.flags.Set(name, enabled); err != nil {
+		return fmt.Errorf("flags.Set('%s'): %w", name, err)
+	}
+	return nil
+}
+
+// OnFlag calls the watcher every time the feature flag changes
+func (independent *Service) OnFlag(name string, watcher featureflag.Watcher) {
+	independent.flags.Watch(name, watcher)
+}
+
+// RouteFlag adds the route into the handlers of the category, gated by the feature flag.
+// While the flag is disabled, the route replies with Disabled without calling the handle.
+func (independent *Service) RouteFlag(category string, command string, name string, handle func(message.RequestInterface) message.ReplyInterface) error {
+	if err := featureflag.Validate(name); err != nil {
+		return fmt.Errorf("featureflag.Validate: %w", err)
+	}
+	handlers := independent.HandlersByCategory(category)
+	if len(handlers) == 0 {
+		return fmt.Errorf("the '%s' handler is not set", category)
+	}
+
+	route := func(req message.RequestInterface) message.ReplyInterface {
+		if !independent.Flag(name) {
+			return req.Fail(fmt.Sprintf("%s: the '%s' feature flag is disabled", Disabled, name))
+		}
+		return handle(req)
+	}
+	for _, handler := range handlers {
+		if err := handler.Route(command, route); err != nil {
+			return fmt.Errorf("handler('%s').Route('%s'): %w", category, command, err)
+		}
+	}
+
+	return nil
+}
+
+// The broadcastFlag logs the changed feature flag and broadcasts it
+func (independent *Service) broadcastFlag(name string, enabled bool) {
+	independent.Logger.Info("feature flag changed", "flag", name, "enabled", enabled)
+	if err := independent.broadcast(FlagChangedTopic, map[string]interface{}{"name": name, "enabled": enabled}); err != nil {
+		independent.Logger.Warn("broadcast", "topic", FlagChangedTopic, "error", err)
+	}
+}
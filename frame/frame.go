@@ -0,0 +1,179 @@
+// Package frame validates the JSON frames received from the untrusted peers before they are parsed.
+//
+// The json.Unmarshal allocates as much as the frame asks for, and silently keeps the last of the duplicate keys.
+// Validate walks the tokens of the frame without building the values, and rejects:
+//   - the frames larger than Limits.Size,
+//   - the values nested deeper than Limits.Depth,
+//   - more than Limits.Elements of the keys and values,
+//   - the duplicate keys of the same object, as the parsers disagree on which one wins,
+//   - the trailing data after the value.
+//
+// Parse validates the frames, then calls the parser, for example message.ParseRequest:
+//
+//	req, err := frame.Parse(frames, frame.DefaultLimits(), message.ParseRequest)
+//
+// The rejected frames have the Code in the error, so the clients distinguish them from the other failures.
+package frame
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Code is the prefix of the error message of the rejected frames
+const Code = "malformed_frame"
+
+const (
+	DefaultSize     = 4 << 20 // 4 MiB
+	DefaultDepth    = 32
+	DefaultElements = 1 << 16
+)
+
+// Limits of the frame.
+// Zero means no limit.
+type Limits struct {
+	Size     int `json:"size,omitempty" yaml:"size,omitempty"`         // in bytes of all frames
+	Depth    int `json:"depth,omitempty" yaml:"depth,omitempty"`       // of the nested objects and arrays
+	Elements int `json:"elements,omitempty" yaml:"elements,omitempty"` // the keys and values in total
+}
+
+// DefaultLimits returns the default limits
+func DefaultLimits() Limits {
+	return Limits{Size: DefaultSize, Depth: DefaultDepth, Elements: DefaultElements}
+}
+
+// MalformedError is returned for the rejected frames
+type MalformedError struct {
+	Reason string
+}
+
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("%s: %s", Code, e.Reason)
+}
+
+// IsMalformed returns true if the error or the error message of the failed reply is about the rejected frame
+func IsMalformed(err error) bool {
+	var malformed *MalformedError
+	if errors.As(err, &malformed) {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), Code+":")
+}
+
+func malformed(format string, args ...interface{}) error {
+	return &MalformedError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// the object or array being walked
+type scope struct {
+	object    bool
+	expectKey bool
+	keys      map[string]struct{}
+}
+
+// Validate returns MalformedError if the data is not a single JSON value within the limits.
+func Validate(data []byte, limits Limits) error {
+	if limits.Size > 0 && len(data) > limits.Size {
+		return malformed("%d bytes exceed %d bytes", len(data), limits.Size)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	stack := make([]*scope, 0, 8)
+	values := 0
+	elements := 0
+
+	// the value is complete, the parent object expects the next key
+	done := func() {
+		if len(stack) == 0 {
+			values++
+			return
+		}
+		if top := stack[len(stack)-1]; top.object {
+			top.expectKey = true
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return malformed("%v", err)
+		}
+		if values > 0 {
+			return malformed("trailing data after the value")
+		}
+
+		elements++
+		if limits.Elements > 0 && elements > limits.Elements {
+			return malformed("more than %d elements", limits.Elements)
+		}
+
+		delim, isDelim := token.(json.Delim)
+		switch {
+		case isDelim && (delim == '{' || delim == '['):
+			if limits.Depth > 0 && len(stack) >= limits.Depth {
+				return malformed("nested deeper than %d", limits.Depth)
+			}
+			if len(stack) > 0 && stack[len(stack)-1].object {
+				stack[len(stack)-1].expectKey = false
+			}
+			opened := &scope{object: delim == '{', expectKey: delim == '{'}
+			if opened.object {
+				opened.keys = make(map[string]struct{})
+			}
+			stack = append(stack, opened)
+		case isDelim:
+			stack = stack[:len(stack)-1]
+			done()
+		default:
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				if top.object && top.expectKey {
+					key := token.(string)
+					if _, ok := top.keys[key]; ok {
+						return malformed("duplicate key '%s'", key)
+					}
+					top.keys[key] = struct{}{}
+					top.expectKey = false
+					continue
+				}
+			}
+			done()
+		}
+	}
+
+	if values == 0 {
+		return malformed("no value")
+	}
+	return nil
+}
+
+// ValidateFrames validates the JSON joined from the frames, as the multipart messages are parsed.
+// The size limit applies to the frames in total.
+func ValidateFrames(frames []string, limits Limits) error {
+	size := 0
+	for _, part := range frames {
+		size += len(part)
+	}
+	if limits.Size > 0 && size > limits.Size {
+		return malformed("%d bytes exceed %d bytes", size, limits.Size)
+	}
+	return Validate([]byte(strings.Join(frames, "")), limits)
+}
+
+// Parse validates the frames, then passes them to the parse function.
+func Parse[T any](frames []string, limits Limits, parse func([]string) (T, error)) (T, error) {
+	if err := ValidateFrames(frames, limits); err != nil {
+		var empty T
+		return empty, err
+	}
+	return parse(frames)
+}
@@ -0,0 +1,132 @@
+package frame
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestFrameSuite struct {
+	suite.Suite
+}
+
+// Test_10_Validate tests the rejected frames
+func (test *TestFrameSuite) Test_10_Validate() {
+	s := test.Require
+
+	limits := Limits{Size: 128, Depth: 4, Elements: 16}
+
+	s().NoError(Validate([]byte(`{"command":"get","parameters":{"ids":[1,2,{"a":"b"}]}}`), limits))
+	s().NoError(Validate([]byte(`[{"a":1},{"a":2}]`), limits))
+	s().NoError(Validate([]byte(` "text" `), limits))
+
+	s().True(IsMalformed(Validate([]byte(`{"a":1,"a":2}`), limits)))
+	s().True(IsMalformed(Validate([]byte(`{"a":{"b":1},"a":2}`), limits)))
+	s().True(IsMalformed(Validate([]byte(`[[[[[1]]]]]`), limits)))
+	s().True(IsMalformed(Validate([]byte(strings.Repeat("1,", 100)), limits)))
+	s().True(IsMalformed(Validate([]byte(`[`+strings.Repeat("1,", 20)+`1]`), limits)))
+	s().True(IsMalformed(Validate([]byte(`{"a":1}{"b":2}`), limits)))
+	s().True(IsMalformed(Validate([]byte(`{"a":`), limits)))
+	s().True(IsMalformed(Validate([]byte(``), limits)))
+	s().True(IsMalformed(Validate([]byte(`"`+strings.Repeat("x", 200)+`"`), limits)))
+
+	// the same keys in the different objects are allowed
+	s().NoError(Validate([]byte(`{"a":{"a":1},"b":{"a":1}}`), limits))
+
+	err := Validate([]byte(`{"a":1,"a":2}`), limits)
+	s().True(IsMalformed(fmt.Errorf("reply error message: %s", err.Error())))
+
+	// no limits
+	s().NoError(Validate([]byte(strings.Repeat("[", 100)+strings.Repeat("]", 100)), Limits{}))
+}
+
+// Test_11_Parse tests the parser called with the valid frames only
+func (test *TestFrameSuite) Test_11_Parse() {
+	s := test.Require
+
+	called := 0
+	parse := func(frames []string) (map[string]interface{}, error) {
+		called++
+		var parsed map[string]interface{}
+		err := json.Unmarshal([]byte(strings.Join(frames, "")), &parsed)
+		return parsed, err
+	}
+
+	parsed, err := Parse([]string{`{"command":`, `"get"}`}, DefaultLimits(), parse)
+	s().NoError(err)
+	s().Equal("get", parsed["command"])
+
+	_, err = Parse([]string{`{"command":"get",`, `"command":"set"}`}, DefaultLimits(), parse)
+	s().True(IsMalformed(err))
+	s().Equal(1, called)
+
+	s().True(IsMalformed(ValidateFrames([]string{"[1]", "   "}, Limits{Size: 4})))
+}
+
+// FuzzValidate checks that the accepted frames are the valid JSON within the limits,
+// and that no frame panics the validation.
+func FuzzValidate(f *testing.F) {
+	seeds := []string{
+		`{"command":"get","parameters":{}}`,
+		`{"a":1,"a":2}`,
+		`[[[[[[[[]]]]]]]]`,
+		`{"a":[1,2,{"b":null}]}`,
+		`"\u0000"`,
+		`{"a":1}{}`,
+		`{`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	limits := Limits{Size: 1 << 12, Depth: 4, Elements: 64}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := Validate(data, limits); err != nil {
+			if !IsMalformed(err) {
+				t.Fatalf("not a MalformedError: %v", err)
+			}
+			return
+		}
+		if !json.Valid(data) {
+			t.Fatalf("accepted the invalid JSON: %q", data)
+		}
+		if depth := maxDepth(data); depth > limits.Depth {
+			t.Fatalf("accepted the depth %d: %q", depth, data)
+		}
+	})
+}
+
+// The maxDepth returns the nesting of the valid JSON
+func maxDepth(data []byte) int {
+	depth, deepest, inString, escaped := 0, 0, false, false
+	for _, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+			if depth > deepest {
+				deepest = depth
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return deepest
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFrame(t *testing.T) {
+	suite.Run(t, new(TestFrameSuite))
+}
@@ -22,6 +22,7 @@ require (
 	github.com/ahmetson/os-lib v0.0.0-20230908110839-83535270d872
 	github.com/pebbe/zmq4 v1.2.10
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/sys v0.12.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -84,7 +85,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/crypto v0.13.0 // indirect
 	golang.org/x/mod v0.12.0 // indirect
-	golang.org/x/sys v0.12.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	golang.org/x/tools v0.13.0 // indirect
 )
@@ -0,0 +1,191 @@
+package ha
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileLock is the Elector based on the lock file.
+// The instances must run on the same machine, sharing the same directory.
+//
+// The lock file keeps the id of the leader.
+// The leader prolongs the lock on each campaign.
+// If the lock wasn't prolonged within ttl, then followers consider the lock as stale.
+//
+// The campaigns of all instances are serialized by the OS lock of the guard file next to the lock file,
+// so only one follower takes over the stale lock.
+// The lock file is replaced by renaming, so it never has the partially written id.
+type FileLock struct {
+	id     string
+	path   string
+	ttl    time.Duration
+	leader bool
+	mu     sync.Mutex
+}
+
+// GuardSuffix is appended to the lock path to name the guard file.
+// The guard file is never removed, as the instances may be waiting for its OS lock.
+const GuardSuffix = ".guard"
+
+// NewFileLock returns a file lock elector for the service instance.
+// All instances of the same service must use the same lock path.
+// Use flag.LeaderLockName to derive the file name from the service url.
+func NewFileLock(lockPath string, id string, ttl time.Duration) (*FileLock, error) {
+	if len(lockPath) == 0 || len(id) == 0 {
+		return nil, fmt.Errorf("the 'lockPath' and 'id' parameters are required")
+	}
+	if ttl <= Interval {
+		return nil, fmt.Errorf("the ttl must be greater than the campaign interval %v", Interval)
+	}
+
+	return &FileLock{
+		id:   id,
+		path: lockPath,
+		ttl:  ttl,
+	}, nil
+}
+
+// guard takes the OS lock of the guard file, waiting for the other instances to release it.
+// Release it by calling the returned function.
+func (lock *FileLock) guard() (func(), error) {
+	guardPath := lock.path + GuardSuffix
+	f, err := os.OpenFile(guardPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile('%s'): %w", guardPath, err)
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("lockFile('%s'): %w", guardPath, err)
+	}
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, nil
+}
+
+// holder returns the id of the leader written in the lock file.
+// If the lock file is stale or doesn't exist, then returns an empty string.
+// Call it under the guard.
+func (lock *FileLock) holder() (string, error) {
+	info, err := os.Stat(lock.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("os.Stat('%s'): %w", lock.path, err)
+	}
+	if time.Since(info.ModTime()) > lock.ttl {
+		return "", nil
+	}
+
+	content, err := os.ReadFile(lock.path)
+	if err != nil {
+		return "", fmt.Errorf("os.ReadFile('%s'): %w", lock.path, err)
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// take writes the id of this instance into the lock file, replacing the stale one.
+// The id is written into the temporary file, then renamed to the lock file.
+// Call it under the guard.
+func (lock *FileLock) take() error {
+	tmpPath := fmt.Sprintf("%s.%s.tmp", lock.path, lock.id)
+	if err := os.WriteFile(tmpPath, []byte(lock.id), 0644); err != nil {
+		return fmt.Errorf("os.WriteFile('%s'): %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, lock.path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("os.Rename('%s'): %w", tmpPath, err)
+	}
+	return nil
+}
+
+// Campaign takes the lock file if it doesn't exist or it's stale.
+// If the lock file is owned by this instance, then it's prolonged.
+func (lock *FileLock) Campaign() (bool, error) {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+
+	release, err := lock.guard()
+	if err != nil {
+		lock.leader = false
+		return false, fmt.Errorf("lock.guard: %w", err)
+	}
+	defer release()
+
+	holder, err := lock.holder()
+	if err != nil {
+		return false, fmt.Errorf("lock.holder: %w", err)
+	}
+
+	if holder == lock.id {
+		now := time.Now()
+		if err := os.Chtimes(lock.path, now, now); err != nil {
+			lock.leader = false
+			return false, fmt.Errorf("os.Chtimes('%s'): %w", lock.path, err)
+		}
+		lock.leader = true
+		return true, nil
+	}
+
+	if len(holder) > 0 {
+		lock.leader = false
+		return false, nil
+	}
+
+	if err := lock.take(); err != nil {
+		lock.leader = false
+		return false, fmt.Errorf("lock.take: %w", err)
+	}
+
+	// the other instances waited for the guard, so no one replaced the lock file
+	holder, err = lock.holder()
+	if err != nil {
+		lock.leader = false
+		return false, fmt.Errorf("lock.holder: %w", err)
+	}
+	lock.leader = holder == lock.id
+	return lock.leader, nil
+}
+
+// Resign removes the lock file if it's owned by this instance.
+func (lock *FileLock) Resign() error {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+
+	if !lock.leader {
+		return nil
+	}
+	lock.leader = false
+
+	release, err := lock.guard()
+	if err != nil {
+		return fmt.Errorf("lock.guard: %w", err)
+	}
+	defer release()
+
+	holder, err := lock.holder()
+	if err != nil {
+		return fmt.Errorf("lock.holder: %w", err)
+	}
+	if holder != lock.id {
+		return nil
+	}
+
+	if err := os.Remove(lock.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("os.Remove('%s'): %w", lock.path, err)
+	}
+	return nil
+}
+
+// IsLeader returns the state of the last campaign
+func (lock *FileLock) IsLeader() bool {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+
+	return lock.leader
+}
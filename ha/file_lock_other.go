@@ -0,0 +1,19 @@
+//go:build plan9 || js || wasip1
+
+package ha
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// lockFile is not supported on this platform
+func lockFile(_ *os.File) error {
+	return fmt.Errorf("the file locks are not supported on %s", runtime.GOOS)
+}
+
+// unlockFile is not supported on this platform
+func unlockFile(_ *os.File) error {
+	return fmt.Errorf("the file locks are not supported on %s", runtime.GOOS)
+}
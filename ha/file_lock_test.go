@@ -0,0 +1,137 @@
+package ha
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestFileLockSuite struct {
+	suite.Suite
+
+	lockPath string
+}
+
+func (test *TestFileLockSuite) SetupTest() {
+	test.lockPath = filepath.Join(test.T().TempDir(), "leader.test.lock")
+}
+
+// Test_10_Campaign tests that only one instance becomes a leader
+func (test *TestFileLockSuite) Test_10_Campaign() {
+	s := test.Require
+
+	_, err := NewFileLock(test.lockPath, "service_1", Interval)
+	s().Error(err)
+
+	first, err := NewFileLock(test.lockPath, "service_1", Interval*3)
+	s().NoError(err)
+	second, err := NewFileLock(test.lockPath, "service_2", Interval*3)
+	s().NoError(err)
+
+	leader, err := first.Campaign()
+	s().NoError(err)
+	s().True(leader)
+	s().True(first.IsLeader())
+
+	leader, err = second.Campaign()
+	s().NoError(err)
+	s().False(leader)
+	s().False(second.IsLeader())
+
+	// prolong the leadership
+	leader, err = first.Campaign()
+	s().NoError(err)
+	s().True(leader)
+
+	// after resigning, the follower takes the leadership
+	s().NoError(first.Resign())
+	s().False(first.IsLeader())
+
+	leader, err = second.Campaign()
+	s().NoError(err)
+	s().True(leader)
+
+	s().NoError(second.Resign())
+}
+
+// Test_11_StaleLock tests that the lock not prolonged within ttl is taken by the follower
+func (test *TestFileLockSuite) Test_11_StaleLock() {
+	s := test.Require
+
+	first, err := NewFileLock(test.lockPath, "service_1", Interval*3)
+	s().NoError(err)
+	second, err := NewFileLock(test.lockPath, "service_2", Interval*3)
+	s().NoError(err)
+
+	leader, err := first.Campaign()
+	s().NoError(err)
+	s().True(leader)
+
+	// imitate the leader that stopped prolonging
+	past := time.Now().Add(-Interval * 4)
+	s().NoError(os.Chtimes(test.lockPath, past, past))
+
+	leader, err = second.Campaign()
+	s().NoError(err)
+	s().True(leader)
+
+	// the old leader finds out that it lost the leadership
+	leader, err = first.Campaign()
+	s().NoError(err)
+	s().False(leader)
+}
+
+// Test_12_ConcurrentTakeover tests that only one follower takes over the stale lock
+func (test *TestFileLockSuite) Test_12_ConcurrentTakeover() {
+	s := test.Require
+
+	first, err := NewFileLock(test.lockPath, "service_0", Interval*3)
+	s().NoError(err)
+	leader, err := first.Campaign()
+	s().NoError(err)
+	s().True(leader)
+
+	for round := 0; round < 10; round++ {
+		past := time.Now().Add(-Interval * 4)
+		s().NoError(os.Chtimes(test.lockPath, past, past))
+
+		followers := make([]*FileLock, 8)
+		for i := range followers {
+			followers[i], err = NewFileLock(test.lockPath, fmt.Sprintf("service_%d_%d", round, i+1), Interval*3)
+			s().NoError(err)
+		}
+
+		var leaders atomic.Int32
+		var wg sync.WaitGroup
+		wg.Add(len(followers))
+		for _, follower := range followers {
+			go func(follower *FileLock) {
+				defer wg.Done()
+				if leader, err := follower.Campaign(); err == nil && leader {
+					leaders.Add(1)
+				}
+			}(follower)
+		}
+		wg.Wait()
+		s().Equal(int32(1), leaders.Load())
+	}
+
+	// the lock file has the whole id of the leader
+	content, err := os.ReadFile(test.lockPath)
+	s().NoError(err)
+	s().Contains(string(content), "service_9_")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFileLock(t *testing.T) {
+	suite.Run(t, new(TestFileLockSuite))
+}
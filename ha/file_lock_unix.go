@@ -0,0 +1,23 @@
+//go:build !windows && !plan9 && !js && !wasip1
+
+package ha
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// lockFile takes the exclusive OS lock of the file, waiting until it's released by the others
+func lockFile(f *os.File) error {
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the OS lock of the file
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,18 @@
+package ha
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+// lockFile takes the exclusive OS lock of the file, waiting until it's released by the others
+func lockFile(f *os.File) error {
+	overlapped := new(windows.Overlapped)
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, overlapped)
+}
+
+// unlockFile releases the OS lock of the file
+func unlockFile(f *os.File) error {
+	overlapped := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, overlapped)
+}
@@ -0,0 +1,22 @@
+// Package ha keeps the redundant instances of the same service.
+// Two instances of the service have the same url, but different ids.
+// Only one of them, called a leader, serves the public handlers.
+// The other instances, called followers, stay warm to replace the leader.
+package ha
+
+import "time"
+
+// Interval between the campaigns by default
+const Interval = time.Second
+
+// Elector elects the leader among the instances of the same service.
+type Elector interface {
+	// Campaign tries to become a leader.
+	// If the instance is already a leader, then it prolongs the leadership.
+	// Returns true if this instance is a leader.
+	Campaign() (bool, error)
+	// Resign gives up the leadership, so the followers can take it.
+	Resign() error
+	// IsLeader returns the last leadership state without campaigning.
+	IsLeader() bool
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	context "github.com/ahmetson/dev-lib"
+	"github.com/ahmetson/handler-lib/base"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"sync"
+)
+
+// HandlerType describes the third-party handler implementation.
+//
+// The config engine generates the configuration of the Base type,
+// so the custom handler gets the port and id as any built-in handler.
+// Then the type of the configuration is replaced by the Name.
+type HandlerType struct {
+	Name handlerConfig.HandlerType // the unique type name, must not be a built-in type
+	Base handlerConfig.HandlerType // the built-in type with the same socket, for example handlerConfig.ReplierType
+	New  func() base.Interface     // creates the handler, used by the proxies to mirror the destination handlers
+}
+
+var (
+	handlerTypes   = make(map[handlerConfig.HandlerType]HandlerType)
+	handlerTypesMu sync.RWMutex
+)
+
+// RegisterHandlerType adds the third-party handler type.
+// Register the types in the init function, before creating the services or proxies.
+func RegisterHandlerType(handlerType HandlerType) error {
+	if len(handlerType.Name) == 0 {
+		return fmt.Errorf("empty type name")
+	}
+	if len(handlerType.Base) == 0 {
+		return fmt.Errorf("the '%s' type has no base type", handlerType.Name)
+	}
+	if handlerType.New == nil {
+		return fmt.Errorf("the '%s' type has no New function", handlerType.Name)
+	}
+
+	handlerTypesMu.Lock()
+	defer handlerTypesMu.Unlock()
+
+	if _, ok := handlerTypes[handlerType.Name]; ok {
+		return fmt.Errorf("the '%s' type registered already", handlerType.Name)
+	}
+	if _, ok := handlerTypes[handlerType.Base]; ok {
+		return fmt.Errorf("the base type '%s' must be a built-in type", handlerType.Base)
+	}
+	handlerTypes[handlerType.Name] = handlerType
+
+	return nil
+}
+
+// registeredType returns the third-party handler type by its name
+func registeredType(name handlerConfig.HandlerType) (HandlerType, bool) {
+	handlerTypesMu.RLock()
+	defer handlerTypesMu.RUnlock()
+
+	handlerType, ok := handlerTypes[name]
+	return handlerType, ok
+}
+
+// registeredTypes returns all third-party handler types
+func registeredTypes() []HandlerType {
+	handlerTypesMu.RLock()
+	defer handlerTypesMu.RUnlock()
+
+	types := make([]HandlerType, 0, len(handlerTypes))
+	for _, handlerType := range handlerTypes {
+		types = append(types, handlerType)
+	}
+	return types
+}
+
+// baseType returns the built-in type of the handler type.
+// The built-in types are returned as is.
+func baseType(name handlerConfig.HandlerType) handlerConfig.HandlerType {
+	if handlerType, ok := registeredType(name); ok {
+		return handlerType.Base
+	}
+	return name
+}
+
+// generateHandler generates the handler configuration by the config engine.
+// The third-party types are generated as their base type.
+// The internal handlers are generated with the inproc endpoint, see Internal.
+func generateHandler(ctx context.Interface, handlerType handlerConfig.HandlerType, category string, internal bool) (*handlerConfig.Handler, error) {
+	generated, err := ctx.Config().GenerateHandler(baseType(handlerType), category, internal)
+	if err != nil {
+		return nil, fmt.Errorf("configClient.GenerateHandler('%s', '%s', internal: %v): %w", baseType(handlerType), category, internal, err)
+	}
+	generated.Type = handlerType
+
+	return generated, nil
+}
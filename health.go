@@ -0,0 +1,98 @@
+package service
+
+import (
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/handler-lib/base"
+	"time"
+)
+
+// HealthInterval is the period of checking the handlers to publish or withdraw their proxy units
+const HealthInterval = time.Second * 2
+
+// The probe returns true if the handler manager confirms that the handler is serving.
+// The lazy handlers not started yet are serving, as the proxy starts them on the first request.
+// The other handlers that are not started by this instance are not probed.
+func (independent *Service) probe(handler base.Interface) bool {
+	if handler.Config() == nil {
+		return false
+	}
+	if independent.isLazy(handler) {
+		return independent.elector == nil || independent.elector.IsLeader() || !independent.isPublic(handler)
+	}
+	if independent.skipHandler(handler) {
+		return false
+	}
+
+	handlerClient, err := independent.handlerClient(handler.Config())
+	if err != nil {
+		return false
+	}
+	_, err = handlerClient.Config()
+	return err == nil
+}
+
+// refreshServing probes all handlers.
+// Returns true if any handler changed its health since the last probe.
+func (independent *Service) refreshServing() bool {
+	serving := make(map[string]bool, len(independent.Handlers))
+	for _, raw := range independent.Handlers {
+		handler := raw.(base.Interface)
+		if independent.probe(handler) {
+			serving[handler.Config().Id] = true
+		}
+	}
+
+	independent.servingMu.Lock()
+	defer independent.servingMu.Unlock()
+
+	changed := len(serving) != len(independent.serving)
+	if !changed {
+		for id := range serving {
+			if !independent.serving[id] {
+				changed = true
+				break
+			}
+		}
+	}
+	independent.serving = serving
+
+	return changed
+}
+
+// servingUnits returns the units of the handlers confirmed as serving by the last probe.
+// The proxies never route to the handlers that are not listening, except the lazy handlers they start.
+func (independent *Service) servingUnits(units []*serviceConfig.Unit) []*serviceConfig.Unit {
+	independent.servingMu.Lock()
+	defer independent.servingMu.Unlock()
+
+	filtered := make([]*serviceConfig.Unit, 0, len(units))
+	for _, unit := range units {
+		if independent.serving[unit.HandlerId] {
+			filtered = append(filtered, unit)
+		}
+	}
+
+	return filtered
+}
+
+// The watchServing re-publishes the proxy units when the handler health changes.
+// The failed handlers are restarted by their restart policies, see SetRestartPolicy.
+// The units of the unhealthy handlers are withdrawn, and published again when the handler recovers.
+// It stops when the manager is closed.
+func (independent *Service) watchServing() {
+	for {
+		time.Sleep(HealthInterval)
+
+		if independent.manager == nil || !independent.manager.Running() {
+			return
+		}
+		changed := independent.refreshServing()
+		independent.supervise()
+		if !changed {
+			continue
+		}
+		if err := independent.setProxyUnits(); err != nil {
+			independent.Logger.Warn("setProxyUnits", "error", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package idempotency prevents applying the retried mutations twice.
+//
+// The client sets the unique key in the request.
+// The retried request has the same key.
+// The server keeps the replies by the key, and returns the kept reply for the retried request
+// instead of applying the mutation again.
+package idempotency
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// Param is the request parameter with the idempotency key
+	Param = "idempotency_key"
+	// TTL is the default duration to keep the replies
+	TTL = time.Minute * 10
+	// Limit is the default amount of the kept replies
+	Limit = 10_000
+)
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// Cache keeps the replies by the idempotency keys
+type Cache[V any] struct {
+	ttl     time.Duration
+	limit   int
+	entries map[string]entry[V]
+	order   []string // the keys in the insertion order to evict the oldest
+	mu      sync.Mutex
+}
+
+// NewCache returns the cache that keeps the limit of the replies for the ttl.
+// Zero parameters are replaced by TTL and Limit.
+func NewCache[V any](ttl time.Duration, limit int) *Cache[V] {
+	if ttl <= 0 {
+		ttl = TTL
+	}
+	if limit <= 0 {
+		limit = Limit
+	}
+	return &Cache[V]{
+		ttl:     ttl,
+		limit:   limit,
+		entries: make(map[string]entry[V]),
+		order:   make([]string, 0),
+	}
+}
+
+// Get returns the kept reply by the key
+func (cache *Cache[V]) Get(key string) (V, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	e, ok := cache.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		var empty V
+		return empty, false
+	}
+	return e.value, true
+}
+
+// Set keeps the reply by the key.
+// The expired and the oldest replies are evicted.
+func (cache *Cache[V]) Set(key string, value V) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := cache.entries[key]; !ok {
+		cache.order = append(cache.order, key)
+	}
+	cache.entries[key] = entry[V]{value: value, expires: now.Add(cache.ttl)}
+
+	// the order is the insertion order, so the expired entries are at the beginning
+	evict := 0
+	for _, oldKey := range cache.order {
+		e, ok := cache.entries[oldKey]
+		if ok && now.Before(e.expires) && len(cache.order)-evict <= cache.limit {
+			break
+		}
+		delete(cache.entries, oldKey)
+		evict++
+	}
+	cache.order = cache.order[evict:]
+}
+
+// Len returns the amount of the kept replies
+func (cache *Cache[V]) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return len(cache.entries)
+}
+
+// Route wraps the route function.
+// If the request has an idempotency key, and the reply is kept, then returns the kept reply.
+// Otherwise, calls the route function and keeps the reply if it's successful.
+//
+// The keyOf returns the idempotency key of the request, or an empty string if there is no key.
+func Route[Req any, Rep any](cache *Cache[Rep], keyOf func(Req) string, isOk func(Rep) bool, handle func(Req) Rep) func(Req) Rep {
+	return func(req Req) Rep {
+		key := keyOf(req)
+		if len(key) == 0 {
+			return handle(req)
+		}
+		if reply, ok := cache.Get(key); ok {
+			return reply
+		}
+
+		reply := handle(req)
+		if isOk(reply) {
+			cache.Set(key, reply)
+		}
+		return reply
+	}
+}
+
+// NewKey returns a random idempotency key
+func NewKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
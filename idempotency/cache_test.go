@@ -0,0 +1,72 @@
+package idempotency
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestCacheSuite struct {
+	suite.Suite
+}
+
+// Test_10_Route tests that the retried mutation is applied once
+func (test *TestCacheSuite) Test_10_Route() {
+	s := test.Require
+
+	applied := 0
+	cache := NewCache[string](0, 0)
+	handle := Route(cache, func(req string) string {
+		return req
+	}, func(reply string) bool {
+		return reply != "failed"
+	}, func(req string) string {
+		applied++
+		return fmt.Sprintf("applied %d", applied)
+	})
+
+	key, err := NewKey()
+	s().NoError(err)
+	s().Len(key, 32)
+
+	s().Equal("applied 1", handle(key))
+	s().Equal("applied 1", handle(key))
+	s().Equal(1, applied)
+
+	// no key, no deduplication
+	s().Equal("applied 2", handle(""))
+	s().Equal("applied 3", handle(""))
+}
+
+// Test_11_Evict tests the limit and the ttl of the cache
+func (test *TestCacheSuite) Test_11_Evict() {
+	s := test.Require
+
+	cache := NewCache[int](time.Millisecond*50, 2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	s().Equal(2, cache.Len())
+	_, ok := cache.Get("a")
+	s().False(ok)
+	value, ok := cache.Get("c")
+	s().True(ok)
+	s().Equal(3, value)
+
+	time.Sleep(time.Millisecond * 60)
+	_, ok = cache.Get("c")
+	s().False(ok)
+
+	cache.Set("d", 4)
+	s().Equal(1, cache.Len())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestCache(t *testing.T) {
+	suite.Run(t, new(TestCacheSuite))
+}
@@ -0,0 +1,279 @@
+// Package ingest consumes the messages from the message brokers, such as Kafka or NATS,
+// and routes them to the handlers as requests.
+//
+// The package has no broker drivers, to keep the service free from their dependencies.
+// Wrap the driver of the broker into the Broker interface:
+//
+//	broker := NewKafkaBroker(reader, writer) // implements ingest.Broker
+//	handlerClient, _ := client.New(handlerConfig) // the client of the handler
+//
+//	adapter := ingest.New(broker, handlerClient)
+//	adapter.SetCommand("orders", "create-order")
+//	adapter.SetReplyTopic("orders", "orders-created")
+//	adapter.Start("orders")
+//
+// The requests go through the handler, so the routing and the middleware of the handler are applied.
+//
+// The message is acknowledged once the handler replied and the reply is published,
+// so the broker redelivers the messages lost by the failed handler.
+// The messages that can't be converted into the request are acknowledged, as redelivering never fixes them.
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/service-lib/sizelimit"
+	"sync"
+	"time"
+)
+
+// CommandHeader is the message header with the command name.
+// If the header is not set, then the command is defined by the SetCommand.
+const CommandHeader = "command"
+
+// RetryInterval is the pause after the failed receive
+const RetryInterval = time.Second
+
+// Message received from the broker or published to the broker
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte // JSON encoded parameters
+	Headers map[string]string
+}
+
+// Broker is the message broker driver.
+type Broker interface {
+	// Subscribe to the topics. Called once before Receive.
+	Subscribe(topics ...string) error
+	// Receive blocks until the next message. After Close, it must return an error.
+	Receive() (Message, error)
+	// Ack confirms that the message was processed
+	Ack(msg Message) error
+	// Nack returns the message to the broker to be redelivered later
+	Nack(msg Message) error
+	// Publish the message
+	Publish(msg Message) error
+	// Close the connection to the broker
+	Close() error
+}
+
+// Requester sends the request to the handler, for example *client.Socket
+type Requester interface {
+	Request(req message.RequestInterface) (message.ReplyInterface, error)
+}
+
+// Reply is published back to the broker
+type Reply struct {
+	Ok         bool                   `json:"ok"`
+	Message    string                 `json:"message,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Adapter routes the broker messages to the handler
+type Adapter struct {
+	broker      Broker
+	requester   Requester
+	commands    map[string]string // command by topic
+	replyTopics map[string]string // reply topic by topic
+	errors      chan error
+	sizeLimit   int // of the message value, the larger messages are not parsed
+	running     bool
+	mu          sync.Mutex
+}
+
+// New adapter between the broker and the handler
+func New(broker Broker, requester Requester) *Adapter {
+	return &Adapter{
+		broker:      broker,
+		requester:   requester,
+		commands:    make(map[string]string),
+		replyTopics: make(map[string]string),
+		errors:      make(chan error, 16),
+		sizeLimit:   sizelimit.DefaultRequest,
+	}
+}
+
+// SetSizeLimit sets the limit of the message value in bytes.
+// The larger messages are rejected before they are parsed, and acknowledged.
+// Zero means no limit. By default, sizelimit.DefaultRequest.
+func (adapter *Adapter) SetSizeLimit(limit int) {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	adapter.sizeLimit = limit
+}
+
+// SetCommand sets the command of the requests converted from the messages of the topic.
+func (adapter *Adapter) SetCommand(topic string, command string) {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	adapter.commands[topic] = command
+}
+
+// SetReplyTopic publishes the replies of the requests from the topic to the reply topic.
+// Without the reply topic, the replies are dropped.
+func (adapter *Adapter) SetReplyTopic(topic string, replyTopic string) {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	adapter.replyTopics[topic] = replyTopic
+}
+
+// Errors returns the errors that didn't stop the adapter, for example invalid messages.
+// If the channel is full, the errors are dropped.
+func (adapter *Adapter) Errors() <-chan error {
+	return adapter.errors
+}
+
+// Running returns true if the adapter consumes the messages
+func (adapter *Adapter) Running() bool {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	return adapter.running
+}
+
+func (adapter *Adapter) report(err error) {
+	select {
+	case adapter.errors <- err:
+	default:
+	}
+}
+
+// Request converts the message into the request.
+// The command is taken from the CommandHeader, or from the command of the topic.
+func (adapter *Adapter) Request(msg Message) (*message.Request, error) {
+	command := msg.Headers[CommandHeader]
+	if len(command) == 0 {
+		adapter.mu.Lock()
+		command = adapter.commands[msg.Topic]
+		adapter.mu.Unlock()
+	}
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no command for '%s' topic", msg.Topic)
+	}
+
+	adapter.mu.Lock()
+	limit := adapter.sizeLimit
+	adapter.mu.Unlock()
+	if err := sizelimit.Check("request", msg.Value, limit); err != nil {
+		return nil, err
+	}
+
+	parameters := key_value.New()
+	if len(msg.Value) > 0 {
+		if err := json.Unmarshal(msg.Value, &parameters); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+
+	return &message.Request{
+		Command:    command,
+		Parameters: parameters,
+	}, nil
+}
+
+// The handle routes the request of the message and publishes the reply
+func (adapter *Adapter) handle(msg Message, req *message.Request) error {
+	reply, err := adapter.requester.Request(req)
+	if err != nil {
+		return fmt.Errorf("requester.Request('%s'): %w", req.Command, err)
+	}
+
+	adapter.mu.Lock()
+	replyTopic := adapter.replyTopics[msg.Topic]
+	adapter.mu.Unlock()
+	if len(replyTopic) == 0 {
+		return nil
+	}
+
+	published := Reply{Ok: reply.IsOK(), Message: reply.ErrorMessage(), Parameters: reply.ReplyParameters()}
+	value, err := json.Marshal(published)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := adapter.broker.Publish(Message{Topic: replyTopic, Key: msg.Key, Value: value}); err != nil {
+		return fmt.Errorf("broker.Publish('%s'): %w", replyTopic, err)
+	}
+
+	return nil
+}
+
+// Start consuming the topics in the background.
+// The messages are acknowledged after the handler replied, even if the reply is failed, see consume.
+func (adapter *Adapter) Start(topics ...string) error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if adapter.running {
+		return fmt.Errorf("already running")
+	}
+	if err := adapter.broker.Subscribe(topics...); err != nil {
+		return fmt.Errorf("broker.Subscribe: %w", err)
+	}
+	adapter.running = true
+
+	go func() {
+		for {
+			msg, err := adapter.broker.Receive()
+			if err != nil {
+				if adapter.Running() {
+					adapter.report(fmt.Errorf("broker.Receive: %w", err))
+					time.Sleep(RetryInterval)
+					continue
+				}
+				return
+			}
+
+			adapter.consume(msg)
+		}
+	}()
+
+	return nil
+}
+
+// The consume handles the message, then acknowledges it.
+// The invalid messages are acknowledged too, to not block the topic.
+// If the handler didn't reply, or the reply was not published, the message is returned to the broker.
+func (adapter *Adapter) consume(msg Message) {
+	req, err := adapter.Request(msg)
+	if err != nil {
+		adapter.report(fmt.Errorf("adapter.Request(topic='%s'): %w", msg.Topic, err))
+		if err := adapter.broker.Ack(msg); err != nil {
+			adapter.report(fmt.Errorf("broker.Ack(topic='%s'): %w", msg.Topic, err))
+		}
+		return
+	}
+
+	if err := adapter.handle(msg, req); err != nil {
+		adapter.report(fmt.Errorf("handle(topic='%s'): %w", msg.Topic, err))
+		if err := adapter.broker.Nack(msg); err != nil {
+			adapter.report(fmt.Errorf("broker.Nack(topic='%s'): %w", msg.Topic, err))
+		}
+		return
+	}
+
+	if err := adapter.broker.Ack(msg); err != nil {
+		adapter.report(fmt.Errorf("broker.Ack(topic='%s'): %w", msg.Topic, err))
+	}
+}
+
+// Close stops consuming the messages and closes the broker
+func (adapter *Adapter) Close() error {
+	adapter.mu.Lock()
+	if !adapter.running {
+		adapter.mu.Unlock()
+		return fmt.Errorf("not running")
+	}
+	adapter.running = false
+	adapter.mu.Unlock()
+
+	if err := adapter.broker.Close(); err != nil {
+		return fmt.Errorf("broker.Close: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/service-lib/sizelimit"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker delivers the queued messages and records the acknowledgements
+type fakeBroker struct {
+	messages  chan Message
+	closed    chan struct{}
+	acked     []Message
+	nacked    []Message
+	published []Message
+	failOn    string // the topic failed to publish
+	mu        sync.Mutex
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{messages: make(chan Message, 16), closed: make(chan struct{})}
+}
+
+func (broker *fakeBroker) Subscribe(...string) error {
+	return nil
+}
+
+func (broker *fakeBroker) Receive() (Message, error) {
+	select {
+	case msg := <-broker.messages:
+		return msg, nil
+	case <-broker.closed:
+		return Message{}, fmt.Errorf("closed")
+	}
+}
+
+func (broker *fakeBroker) Ack(msg Message) error {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	broker.acked = append(broker.acked, msg)
+	return nil
+}
+
+func (broker *fakeBroker) Nack(msg Message) error {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	broker.nacked = append(broker.nacked, msg)
+	return nil
+}
+
+func (broker *fakeBroker) Publish(msg Message) error {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if msg.Topic == broker.failOn {
+		return fmt.Errorf("publish failed")
+	}
+	broker.published = append(broker.published, msg)
+	return nil
+}
+
+func (broker *fakeBroker) Close() error {
+	close(broker.closed)
+	return nil
+}
+
+// counts returns the number of the acknowledged, returned and published messages
+func (broker *fakeBroker) counts() (int, int, int) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	return len(broker.acked), len(broker.nacked), len(broker.published)
+}
+
+// fakeRequester replies to the requests, or fails if the command is "unreachable"
+type fakeRequester struct{}
+
+func (requester *fakeRequester) Request(req message.RequestInterface) (message.ReplyInterface, error) {
+	switch req.CommandName() {
+	case "unreachable":
+		return nil, fmt.Errorf("the handler is unreachable")
+	case "invalid":
+		return req.Fail("invalid order"), nil
+	default:
+		return req.Ok(key_value.New().Set("id", 1)), nil
+	}
+}
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestIngestSuite struct {
+	suite.Suite
+
+	broker  *fakeBroker
+	adapter *Adapter
+}
+
+func (test *TestIngestSuite) SetupTest() {
+	test.broker = newFakeBroker()
+	test.adapter = New(test.broker, &fakeRequester{})
+	test.adapter.SetCommand("orders", "create-order")
+	test.adapter.SetReplyTopic("orders", "orders-created")
+}
+
+// Test_10_Request tests the conversion of the messages into the requests
+func (test *TestIngestSuite) Test_10_Request() {
+	s := test.Require
+
+	req, err := test.adapter.Request(Message{Topic: "orders", Value: []byte(`{"amount":2}`)})
+	s().NoError(err)
+	s().Equal("create-order", req.Command)
+
+	req, err = test.adapter.Request(Message{Topic: "orders", Headers: map[string]string{CommandHeader: "cancel-order"}})
+	s().NoError(err)
+	s().Equal("cancel-order", req.Command)
+
+	_, err = test.adapter.Request(Message{Topic: "unknown"})
+	s().Error(err)
+	_, err = test.adapter.Request(Message{Topic: "orders", Value: []byte("not json")})
+	s().Error(err)
+}
+
+// Test_11_Ack tests that the message is acknowledged only after the reply is published
+func (test *TestIngestSuite) Test_11_Ack() {
+	s := test.Require
+
+	s().NoError(test.adapter.Start("orders"))
+	s().Error(test.adapter.Start("orders"))
+
+	// the replied message is acknowledged after the reply is published
+	test.broker.messages <- Message{Topic: "orders", Value: []byte(`{"amount":2}`)}
+	s().Eventually(func() bool {
+		acked, _, published := test.broker.counts()
+		return acked == 1 && published == 1
+	}, time.Second, time.Millisecond)
+
+	var reply Reply
+	test.broker.mu.Lock()
+	value := test.broker.published[0].Value
+	test.broker.mu.Unlock()
+	s().NoError(json.Unmarshal(value, &reply))
+	s().True(reply.Ok)
+
+	// the failed reply is the handler's answer, the message is acknowledged
+	test.broker.messages <- Message{Topic: "orders", Headers: map[string]string{CommandHeader: "invalid"}}
+	s().Eventually(func() bool {
+		acked, _, published := test.broker.counts()
+		return acked == 2 && published == 2
+	}, time.Second, time.Millisecond)
+
+	// the message without the command is never valid, it's acknowledged to not block the topic
+	test.broker.messages <- Message{Topic: "unknown"}
+	s().Eventually(func() bool {
+		acked, _, _ := test.broker.counts()
+		return acked == 3
+	}, time.Second, time.Millisecond)
+	s().Error(<-test.adapter.Errors())
+
+	_, nacked, _ := test.broker.counts()
+	s().Zero(nacked)
+
+	s().NoError(test.adapter.Close())
+	s().Error(test.adapter.Close())
+}
+
+// Test_12_Nack tests that the message is returned to the broker if it's not handled
+func (test *TestIngestSuite) Test_12_Nack() {
+	s := test.Require
+
+	s().NoError(test.adapter.Start("orders"))
+
+	// the handler didn't reply
+	test.broker.messages <- Message{Topic: "orders", Headers: map[string]string{CommandHeader: "unreachable"}}
+	s().Eventually(func() bool {
+		_, nacked, _ := test.broker.counts()
+		return nacked == 1
+	}, time.Second, time.Millisecond)
+	s().Error(<-test.adapter.Errors())
+
+	// the reply was not published
+	test.broker.mu.Lock()
+	test.broker.failOn = "orders-created"
+	test.broker.mu.Unlock()
+	test.broker.messages <- Message{Topic: "orders", Value: []byte(`{"amount":2}`)}
+	s().Eventually(func() bool {
+		_, nacked, _ := test.broker.counts()
+		return nacked == 2
+	}, time.Second, time.Millisecond)
+	s().Error(<-test.adapter.Errors())
+
+	acked, _, published := test.broker.counts()
+	s().Zero(acked)
+	s().Zero(published)
+
+	s().NoError(test.adapter.Close())
+}
+
+// Test_13_SizeLimit tests that the oversize message is rejected before it's parsed
+func (test *TestIngestSuite) Test_13_SizeLimit() {
+	s := test.Require
+
+	test.adapter.SetSizeLimit(8)
+	_, err := test.adapter.Request(Message{Topic: "orders", Value: []byte(`{"amount":20000}`)})
+	s().True(sizelimit.IsTooLarge(err))
+
+	// the invalid JSON within the limit is parsed
+	_, err = test.adapter.Request(Message{Topic: "orders", Value: []byte("not json")})
+	s().Error(err)
+	s().False(sizelimit.IsTooLarge(err))
+
+	test.adapter.SetSizeLimit(0)
+	_, err = test.adapter.Request(Message{Topic: "orders", Value: []byte(`{"amount":20000}`)})
+	s().NoError(err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestIngest(t *testing.T) {
+	suite.Run(t, new(TestIngestSuite))
+}
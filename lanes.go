@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/message"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/service-lib/errs"
+	"github.com/ahmetson/service-lib/priority"
+)
+
+// SetLanes processes the requests of the category's routes added by RoutePriority in the lanes.
+// The service starts the lanes, and closes them when the service stops.
+//
+// Returns an error if the category has the sync replier, as it handles one request at a time,
+// and nothing waits in the lanes to be reordered.
+func (independent *Service) SetLanes(category string, lanes *priority.Lanes) error {
+	handlers := independent.HandlersByCategory(category)
+	if len(handlers) == 0 {
+		return fmt.Errorf("the '%s' handler is not set", category)
+	}
+	for _, handler := range handlers {
+		if handler.Type() == handlerConfig.SyncReplierType {
+			return fmt.Errorf("the '%s' handler is %s, it has no concurrent requests to reorder", category, handler.Type())
+		}
+	}
+
+	if independent.lanes == nil {
+		independent.lanes = make(map[string]*priority.Lanes)
+	}
+	independent.lanes[category] = lanes
+	return nil
+}
+
+// RoutePriority adds the route into the handlers of the category, executed in the lanes set by SetLanes.
+// The lane is decided by the priority.Param of the request, or by the command if the request has none.
+// If the request can not be queued, it replies with the error without calling the handle.
+func (independent *Service) RoutePriority(category string, command string, handle func(message.RequestInterface) message.ReplyInterface) error {
+	lanes, ok := independent.lanes[category]
+	if !ok {
+		return fmt.Errorf("the '%s' handler has no lanes, call SetLanes", category)
+	}
+
+	isHigh := func(req message.RequestInterface) (bool, error) {
+		value, _ := req.RouteParameters().StringValue(priority.Param)
+		return lanes.Decide(command, value)
+	}
+	route := func(req message.RequestInterface) message.ReplyInterface {
+		high, err := isHigh(req)
+		if err != nil {
+			return req.Fail(fmt.Sprintf("lanes.Decide: %v", err))
+		}
+		var reply message.ReplyInterface
+		if err := lanes.Do(high, func() { reply = handle(req) }); err != nil {
+			return req.Fail(fmt.Sprintf("lanes.Do: %v", err))
+		}
+		return reply
+	}
+	for _, handler := range independent.HandlersByCategory(category) {
+		if err := handler.Route(command, route); err != nil {
+			return fmt.Errorf("handler('%s').Route('%s'): %w", category, command, err)
+		}
+	}
+
+	return nil
+}
+
+// The startLanes starts the lanes that are not running
+func (independent *Service) startLanes() error {
+	for category, lanes := range independent.lanes {
+		if lanes.Running() {
+			continue
+		}
+		if err := lanes.Start(); err != nil {
+			return fmt.Errorf("lanes('%s').Start: %w", category, err)
+		}
+	}
+	return nil
+}
+
+// The closeLanes closes the running lanes
+func (independent *Service) closeLanes() error {
+	var err error
+	for category, lanes := range independent.lanes {
+		if lanes.Running() {
+			err = errs.Join(err, errs.Wrap(fmt.Sprintf("lanes('%s').Close", category), lanes.Close()))
+		}
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/handler-lib/base"
+)
+
+// SetLazy marks the handlers of the categories to start on the first matched request, instead of the Start.
+// The proxy signals the manager by manager.StartHandler command before forwarding the first request.
+//
+// Use it for the rarely used handlers to reduce the footprint of the service.
+// Without a proxy, start the lazy handler by manager.Client.StartHandler.
+func (independent *Service) SetLazy(categories ...string) {
+	independent.lazyMu.Lock()
+	defer independent.lazyMu.Unlock()
+
+	if independent.lazy == nil {
+		independent.lazy = make(map[string]bool, len(categories))
+	}
+	for _, category := range categories {
+		independent.lazy[category] = true
+	}
+}
+
+// isLazy returns true if the handler is lazy and not started yet
+func (independent *Service) isLazy(handler base.Interface) bool {
+	if handler.Config() == nil {
+		return false
+	}
+
+	independent.lazyMu.Lock()
+	defer independent.lazyMu.Unlock()
+
+	return independent.lazy[handler.Config().Category]
+}
+
+// startLazyHandler starts the lazy handlers of the category.
+// If the handlers are started already, then nothing happens.
+//
+// It's invoked by the manager.StartHandler command.
+func (independent *Service) startLazyHandler(category string) error {
+	independent.lazyMu.Lock()
+	defer independent.lazyMu.Unlock()
+
+	handlers := independent.HandlersByCategory(category)
+	if len(handlers) == 0 {
+		return fmt.Errorf("handler of '%s' category not found", category)
+	}
+	if !independent.lazy[category] {
+		return nil
+	}
+
+	for _, handler := range handlers {
+		if independent.elector != nil && !independent.elector.IsLeader() && independent.isPublic(handler) {
+			return fmt.Errorf("the public handler of '%s' category is served by the leader", category)
+		}
+	}
+	for _, handler := range handlers {
+		if err := independent.setHandlerClient(handler); err != nil {
+			return fmt.Errorf("setHandlerClient('%s'): %w", category, err)
+		}
+		if err := independent.startHandler(handler); err != nil {
+			return fmt.Errorf("startHandler('%s'): %w", category, err)
+		}
+	}
+	delete(independent.lazy, category)
+
+	independent.Logger.Info("lazy handler started", "category", category, "amount", len(handlers))
+	return nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/service-lib/ha"
+	"time"
+)
+
+// SetElector enables the leader election among the instances of this service.
+// The instances must have the same url, but different ids.
+//
+// Only the leader starts the public handlers.
+// The followers start the internal handlers and stay warm.
+// When the follower becomes a leader, it starts the public handlers.
+func (independent *Service) SetElector(elector ha.Elector) {
+	independent.elector = elector
+}
+
+// isPublic returns true if the handler is accessible from outside.
+// The handlers set with the Internal option are not public, whatever their endpoint is.
+// The handler is found in the Handlers by its configuration id.
+func (independent *Service) isPublic(handler base.Interface) bool {
+	if handler.Config() == nil {
+		return false
+	}
+	for key, raw := range independent.Handlers {
+		c := raw.(base.Interface).Config()
+		if c != nil && c.Id == handler.Config().Id {
+			return !independent.isInternal(key)
+		}
+	}
+	return true
+}
+
+// The campaign elects this instance as a leader or a follower.
+// Call it before starting the handlers.
+func (independent *Service) campaign() error {
+	if independent.elector == nil {
+		return nil
+	}
+
+	leader, err := independent.elector.Campaign()
+	if err != nil {
+		return fmt.Errorf("elector.Campaign: %w", err)
+	}
+	if !leader {
+		independent.Logger.Info("another instance is a leader, the public handlers will start upon the leadership", "id", independent.id)
+	}
+
+	return nil
+}
+
+// skipHandler returns true if the handler must not be started by this instance yet.
+// The lazy handlers are started on the first request.
+func (independent *Service) skipHandler(handler base.Interface) bool {
+	if independent.isLazy(handler) {
+		return true
+	}
+	return independent.elector != nil && !independent.elector.IsLeader() && independent.isPublic(handler)
+}
+
+// The keepCampaign prolongs the leadership or waits for it in the background.
+// It stops when the manager is closed.
+func (independent *Service) keepCampaign() {
+	for {
+		time.Sleep(ha.Interval)
+
+		if independent.manager == nil || !independent.manager.Running() {
+			if err := independent.elector.Resign(); err != nil {
+				independent.Logger.Warn("elector.Resign", "error", err)
+			}
+			return
+		}
+
+		wasLeader := independent.elector.IsLeader()
+		leader, err := independent.elector.Campaign()
+		if err != nil {
+			independent.Logger.Warn("elector.Campaign", "error", err)
+			continue
+		}
+
+		if leader && !wasLeader {
+			independent.Logger.Info("became a leader, starting the public handlers", "id", independent.id)
+			if err := independent.startPublicHandlers(); err != nil {
+				independent.Logger.Error("startPublicHandlers", "error", err)
+			}
+		} else if !leader && wasLeader {
+			independent.Logger.Warn("lost the leadership, closing the public handlers", "id", independent.id)
+			if err := independent.closePublicHandlers(); err != nil {
+				independent.Logger.Error("closePublicHandlers", "error", err)
+			}
+		}
+	}
+}
+
+// startPublicHandlers starts the handlers skipped while this instance was a follower.
+func (independent *Service) startPublicHandlers() error {
+	for category, raw := range independent.Handlers {
+		handler := raw.(base.Interface)
+		if !independent.isPublic(handler) || independent.isLazy(handler) {
+			continue
+		}
+
+		if err := independent.setHandlerClient(handler); err != nil {
+			return fmt.Errorf("setHandlerClient('%s'): %w", category, err)
+		}
+		if err := independent.startHandler(handler); err != nil {
+			return fmt.Errorf("startHandler('%s'): %w", category, err)
+		}
+	}
+
+	return nil
+}
+
+// closePublicHandlers closes the public handlers, so that only a leader serves them.
+func (independent *Service) closePublicHandlers() error {
+	handlers := make([]base.Interface, 0, len(independent.Handlers))
+	for _, raw := range independent.Handlers {
+		handler := raw.(base.Interface)
+		if independent.isPublic(handler) {
+			handlers = append(handlers, handler)
+		}
+	}
+
+	return independent.closeHandlers(handlers)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/service-lib/errs"
+)
+
+// Stage of the service lifecycle at which the hooks are run
+type Stage string
+
+const (
+	BeforeStart Stage = "before-start" // after the configuration is set, before the orchestra starts
+	AfterStart  Stage = "after-start"  // after the handlers, the manager and the proxies started
+	BeforeStop  Stage = "before-stop"  // before the manager closes the handlers and dependencies
+	AfterStop   Stage = "after-stop"   // after everything is closed, before the service is released
+)
+
+// OnError defines what happens if the hook fails
+type OnError int
+
+const (
+	// Abort stops the lifecycle transition. The failed start closes the service,
+	// the failed stop keeps it running. The remaining hooks of the stage are not run.
+	Abort OnError = iota
+	// Warn logs the error and continues
+	Warn
+)
+
+type hook struct {
+	name    string
+	run     func() error
+	onError OnError
+}
+
+// OnBeforeStart adds the hook run at the beginning of Start, for example, to run the migrations.
+func (independent *Service) OnBeforeStart(name string, run func() error, onError OnError) {
+	independent.addHook(BeforeStart, name, run, onError)
+}
+
+// OnAfterStart adds the hook run when the service is ready, for example, to warm the caches.
+func (independent *Service) OnAfterStart(name string, run func() error, onError OnError) {
+	independent.addHook(AfterStart, name, run, onError)
+}
+
+// OnBeforeStop adds the hook run when the service is closing, while the handlers still serve.
+func (independent *Service) OnBeforeStop(name string, run func() error, onError OnError) {
+	independent.addHook(BeforeStop, name, run, onError)
+}
+
+// OnAfterStop adds the hook run when the handlers and dependencies are closed, for example, to flush the buffers.
+func (independent *Service) OnAfterStop(name string, run func() error, onError OnError) {
+	independent.addHook(AfterStop, name, run, onError)
+}
+
+func (independent *Service) addHook(stage Stage, name string, run func() error, onError OnError) {
+	if independent.hooks == nil {
+		independent.hooks = make(map[Stage][]hook)
+	}
+	independent.hooks[stage] = append(independent.hooks[stage], hook{name: name, run: run, onError: onError})
+}
+
+// The runHooks runs the hooks of the stage in the order they were added.
+// Returns the error of the first aborting hook.
+func (independent *Service) runHooks(stage Stage) error {
+	for _, h := range independent.hooks[stage] {
+		err := h.run()
+		if err == nil {
+			continue
+		}
+		if h.onError == Abort {
+			return fmt.Errorf("%s hook '%s': %w", stage, h.name, err)
+		}
+		independent.Logger.Warn("hook failed", "stage", stage, "hook", h.name, "error", err)
+	}
+
+	return nil
+}
+
+// The stopHooks returns the functions called by the manager before and after closing the service.
+// The resources are closed after the hooks, see closeResources.
+func (independent *Service) stopHooks() (func() error, func() error) {
+	return func() error {
+			return independent.runHooks(BeforeStop)
+		}, func() error {
+			return errs.Join(independent.runHooks(AfterStop), independent.closeResources())
+		}
+}
+
+// The closeResources closes the clients of the extensions and Service.Call, the taps, the enforcer,
+// the publisher, the lanes and the monitor.
+// The failed resource doesn't stop closing the rest.
+func (independent *Service) closeResources() error {
+	independent.closeDeps()
+	independent.closeCallClients()
+	err := errs.Wrap("dropHandlerClients", independent.dropHandlerClients())
+	if independent.taps != nil {
+		err = errs.Join(err, errs.Wrap("taps.Close", independent.taps.Close()))
+	}
+	if independent.enforcer != nil && independent.enforcer.Running() {
+		err = errs.Join(err, errs.Wrap("enforcer.Close", independent.enforcer.Close()))
+	}
+	if independent.publisher != nil && independent.publisher.Running() {
+		err = errs.Join(err, errs.Wrap("publisher.Close", independent.publisher.Close()))
+	}
+	err = errs.Join(err, errs.Wrap("closeLanes", independent.closeLanes()))
+	err = errs.Join(err, errs.Wrap("closeMonitor", independent.closeMonitor()))
+	return err
+}
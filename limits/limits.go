@@ -0,0 +1,355 @@
+// Package limits sets the resource ceilings of the dependency processes.
+//
+// The Limits are set per dependency in the service configuration, see Env:
+//
+//	SERVICE_LIMITS_DATABASE=cpu=0.5,memory=268435456,fds=1024,restart=true
+//
+// The service applies them when the dependency is started, by the pid returned from the dependency manager.
+// Then the process is tracked in the Enforcer to report the violations.
+// The limits are released by Release when the process exits.
+//
+// On Linux the limits are applied by cgroups v2 and rlimit, on Windows by the job objects.
+// On other platforms, Apply returns an error, and the violations are not measured.
+package limits
+
+import (
+	"fmt"
+	"github.com/ahmetson/service-lib/errs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvPrefix is the prefix of the dependency limits in the configuration, followed by the upper-cased dependency id.
+// Use Env to get the name.
+const EnvPrefix = "SERVICE_LIMITS_"
+
+// The fields of the limits declaration, see Parse
+const (
+	CpuField     = "cpu"
+	MemoryField  = "memory"
+	FdsField     = "fds"
+	RestartField = "restart"
+)
+
+// Interval between the checks of the tracked processes by default
+const Interval = time.Second * 5
+
+// Limits are the resource ceilings of the process.
+// Zero value means no limit.
+type Limits struct {
+	Cpu    float64 `json:"cpu,omitempty" yaml:"cpu,omitempty"`       // amount of the cores, for example 0.5
+	Memory uint64  `json:"memory,omitempty" yaml:"memory,omitempty"` // in bytes
+	Fds    uint64  `json:"fds,omitempty" yaml:"fds,omitempty"`       // the open file descriptors
+	// Restart the process if it violates the limits.
+	// The process is killed and started again by the restart function of the enforcer, see Enforcer.SetRestart.
+	Restart bool `json:"restart,omitempty" yaml:"restart,omitempty"`
+}
+
+// IsZero returns true if no limits are set
+func (limits Limits) IsZero() bool {
+	return limits.Cpu == 0 && limits.Memory == 0 && limits.Fds == 0
+}
+
+// Env returns the name of the configuration with the limits of the dependency.
+// The dashes and dots in the id are replaced by underscores.
+func Env(id string) string {
+	return EnvPrefix + strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(id))
+}
+
+// Parse returns the limits from the declaration of the comma-separated fields, for example:
+//
+//	cpu=0.5,memory=268435456,fds=1024,restart=true
+//
+// The memory is in bytes. The empty declaration has no limits.
+func Parse(declaration string) (Limits, error) {
+	var limits Limits
+	for _, field := range strings.Split(declaration, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		name, value, found := strings.Cut(field, "=")
+		if !found {
+			return Limits{}, fmt.Errorf("'%s' has no value", field)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		var err error
+		switch name {
+		case CpuField:
+			limits.Cpu, err = strconv.ParseFloat(value, 64)
+			if err == nil && limits.Cpu < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case MemoryField:
+			limits.Memory, err = strconv.ParseUint(value, 10, 64)
+		case FdsField:
+			limits.Fds, err = strconv.ParseUint(value, 10, 64)
+		case RestartField:
+			limits.Restart, err = strconv.ParseBool(value)
+		default:
+			return Limits{}, fmt.Errorf("'%s' has unknown field, expected '%s', '%s', '%s' or '%s'", field,
+				CpuField, MemoryField, FdsField, RestartField)
+		}
+		if err != nil {
+			return Limits{}, fmt.Errorf("'%s': %w", field, err)
+		}
+	}
+	return limits, nil
+}
+
+// Usage is the resources consumed by the process
+type Usage struct {
+	Memory uint64 `json:"memory"`
+	Fds    uint64 `json:"fds"`
+}
+
+// Violation of the limits by the tracked process
+type Violation struct {
+	Id       string    `json:"id"`
+	Pid      int       `json:"pid"`
+	Resource string    `json:"resource"`
+	Limit    uint64    `json:"limit"`
+	Used     uint64    `json:"used"`
+	Time     time.Time `json:"time"`
+	Killed   bool      `json:"killed"`
+	// Restarted is true if the killed process was started again.
+	// Otherwise, RestartError is the error of the restart, if the enforcer has the restart function.
+	Restarted    bool   `json:"restarted"`
+	RestartError string `json:"restart_error,omitempty"`
+}
+
+// Check returns the violations of the limits by the process
+func Check(id string, pid int, limits Limits) ([]Violation, error) {
+	usage, err := Measure(pid)
+	if err != nil {
+		return nil, fmt.Errorf("Measure(%d): %w", pid, err)
+	}
+
+	now := time.Now()
+	violations := make([]Violation, 0, 2)
+	if limits.Memory > 0 && usage.Memory > limits.Memory {
+		violations = append(violations, Violation{Id: id, Pid: pid, Resource: "memory", Limit: limits.Memory, Used: usage.Memory, Time: now})
+	}
+	if limits.Fds > 0 && usage.Fds > limits.Fds {
+		violations = append(violations, Violation{Id: id, Pid: pid, Resource: "fds", Limit: limits.Fds, Used: usage.Fds, Time: now})
+	}
+
+	return violations, nil
+}
+
+type tracked struct {
+	pid    int
+	limits Limits
+	killed bool // the process is checked until it exits, then its limits are released
+}
+
+// Enforcer checks the tracked processes periodically and keeps the recent violations
+type Enforcer struct {
+	processes   map[string]tracked
+	violations  []Violation
+	onViolation func(Violation)
+	restart     func(id string) error
+	stop        chan struct{}
+	running     bool
+	mu          sync.Mutex
+}
+
+// maxViolations is the amount of the recent violations kept by the enforcer
+const maxViolations = 100
+
+// closeWait is the time given to the tracked processes to exit when the enforcer is closed
+const closeWait = time.Second
+
+// NewEnforcer returns the enforcer.
+// The onViolation is called for each violation, it's optional.
+// Use it to restart the killed dependency.
+func NewEnforcer(onViolation func(Violation)) *Enforcer {
+	return &Enforcer{
+		processes:   make(map[string]tracked),
+		violations:  make([]Violation, 0),
+		onViolation: onViolation,
+	}
+}
+
+// SetRestart sets the function that starts the killed dependency again.
+// The restarted dependency must be tracked again by its new pid.
+func (enforcer *Enforcer) SetRestart(restart func(id string) error) {
+	enforcer.mu.Lock()
+	defer enforcer.mu.Unlock()
+
+	enforcer.restart = restart
+}
+
+// Track starts checking the process of the dependency
+func (enforcer *Enforcer) Track(id string, pid int, limits Limits) {
+	enforcer.mu.Lock()
+	defer enforcer.mu.Unlock()
+
+	enforcer.processes[id] = tracked{pid: pid, limits: limits}
+}
+
+// Untrack stops checking the process of the dependency
+func (enforcer *Enforcer) Untrack(id string) {
+	enforcer.mu.Lock()
+	defer enforcer.mu.Unlock()
+
+	delete(enforcer.processes, id)
+}
+
+// Violations returns the recent violations
+func (enforcer *Enforcer) Violations() []Violation {
+	enforcer.mu.Lock()
+	defer enforcer.mu.Unlock()
+
+	return append([]Violation{}, enforcer.violations...)
+}
+
+// The release untracks the exited process and releases its limits.
+// The process tracked again by another pid keeps the limits, as they are shared by the id.
+func (enforcer *Enforcer) release(id string, pid int) error {
+	enforcer.mu.Lock()
+	process, ok := enforcer.processes[id]
+	if !ok || process.pid != pid {
+		enforcer.mu.Unlock()
+		return nil
+	}
+	delete(enforcer.processes, id)
+	enforcer.mu.Unlock()
+
+	if err := Release(id); err != nil {
+		return fmt.Errorf("Release('%s'): %w", id, err)
+	}
+	return nil
+}
+
+// The check measures all tracked processes.
+// The processes that can not be measured, for example exited, are untracked and their limits are released.
+// The killed processes are started again by the restart function.
+func (enforcer *Enforcer) check() {
+	enforcer.mu.Lock()
+	processes := make(map[string]tracked, len(enforcer.processes))
+	for id, process := range enforcer.processes {
+		processes[id] = process
+	}
+	restart := enforcer.restart
+	enforcer.mu.Unlock()
+
+	for id, process := range processes {
+		violations, err := Check(id, process.pid, process.limits)
+		if err != nil {
+			_ = enforcer.release(id, process.pid)
+			continue
+		}
+		if len(violations) == 0 || process.killed {
+			continue
+		}
+
+		if process.limits.Restart {
+			if p, err := os.FindProcess(process.pid); err == nil && p.Kill() == nil {
+				enforcer.mu.Lock()
+				process.killed = true
+				enforcer.processes[id] = process
+				enforcer.mu.Unlock()
+
+				var restartErr error
+				if restart != nil {
+					restartErr = restart(id)
+				}
+				for i := range violations {
+					violations[i].Killed = true
+					violations[i].Restarted = restart != nil && restartErr == nil
+					if restartErr != nil {
+						violations[i].RestartError = restartErr.Error()
+					}
+				}
+			}
+		}
+
+		enforcer.mu.Lock()
+		enforcer.violations = append(enforcer.violations, violations...)
+		if len(enforcer.violations) > maxViolations {
+			enforcer.violations = enforcer.violations[len(enforcer.violations)-maxViolations:]
+		}
+		enforcer.mu.Unlock()
+
+		if enforcer.onViolation != nil {
+			for _, violation := range violations {
+				enforcer.onViolation(violation)
+			}
+		}
+	}
+}
+
+// Start checking the tracked processes every interval in the background
+func (enforcer *Enforcer) Start(interval time.Duration) error {
+	enforcer.mu.Lock()
+	defer enforcer.mu.Unlock()
+
+	if enforcer.running {
+		return fmt.Errorf("already running")
+	}
+	enforcer.running = true
+	enforcer.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				enforcer.check()
+			}
+		}
+	}(enforcer.stop)
+
+	return nil
+}
+
+// Running returns true if the enforcer checks the processes
+func (enforcer *Enforcer) Running() bool {
+	enforcer.mu.Lock()
+	defer enforcer.mu.Unlock()
+
+	return enforcer.running
+}
+
+// Close stops checking the processes and releases the limits of the exited processes.
+// The processes are given closeWait to exit.
+// The running processes keep their limits, for example the proxies kept for the new instance on the handoff.
+func (enforcer *Enforcer) Close() error {
+	enforcer.mu.Lock()
+	if !enforcer.running {
+		enforcer.mu.Unlock()
+		return fmt.Errorf("not running")
+	}
+	close(enforcer.stop)
+	enforcer.running = false
+	processes := make(map[string]tracked, len(enforcer.processes))
+	for id, process := range enforcer.processes {
+		processes[id] = process
+	}
+	enforcer.mu.Unlock()
+
+	var releaseErr error
+	deadline := time.Now().Add(closeWait)
+	for id, process := range processes {
+		for {
+			if _, err := Measure(process.pid); err != nil {
+				releaseErr = errs.Join(releaseErr, enforcer.release(id, process.pid))
+				break
+			}
+			if time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(time.Millisecond * 50)
+		}
+	}
+
+	return releaseErr
+}
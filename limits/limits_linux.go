@@ -0,0 +1,113 @@
+package limits
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CgroupRoot is the mount point of the cgroups v2
+const CgroupRoot = "/sys/fs/cgroup"
+
+// cpuPeriod is the cgroup cpu period in microseconds
+const cpuPeriod = 100_000
+
+// Apply sets the limits to the process.
+// The cpu and memory limits are set by a cgroup named after the id.
+// The file descriptors are limited by rlimit.
+func Apply(id string, pid int, limits Limits) error {
+	if limits.Fds > 0 {
+		rLimit := &unix.Rlimit{Cur: limits.Fds, Max: limits.Fds}
+		if err := unix.Prlimit(pid, unix.RLIMIT_NOFILE, rLimit, nil); err != nil {
+			return fmt.Errorf("unix.Prlimit(%d, RLIMIT_NOFILE): %w", pid, err)
+		}
+	}
+
+	if limits.Cpu == 0 && limits.Memory == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(CgroupRoot, "sds."+id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll('%s'): %w", dir, err)
+	}
+	if limits.Memory > 0 {
+		if err := writeCgroup(dir, "memory.max", strconv.FormatUint(limits.Memory, 10)); err != nil {
+			return err
+		}
+	}
+	if limits.Cpu > 0 {
+		quota := int64(limits.Cpu * cpuPeriod)
+		if err := writeCgroup(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			return err
+		}
+	}
+	if err := writeCgroup(dir, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Release removes the cgroup of the id.
+// The cgroup must have no processes, the missing cgroup is skipped.
+func Release(id string) error {
+	dir := filepath.Join(CgroupRoot, "sds."+id)
+	if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("os.Remove('%s'): %w", dir, err)
+	}
+	return nil
+}
+
+func writeCgroup(dir string, name string, value string) error {
+	filePath := filepath.Join(dir, name)
+	if err := os.WriteFile(filePath, []byte(value), 0644); err != nil {
+		return fmt.Errorf("os.WriteFile('%s'): %w", filePath, err)
+	}
+	return nil
+}
+
+// Measure returns the memory and the file descriptors used by the process
+func Measure(pid int) (Usage, error) {
+	var usage Usage
+
+	statusPath := fmt.Sprintf("/proc/%d/status", pid)
+	f, err := os.Open(statusPath)
+	if err != nil {
+		return usage, fmt.Errorf("os.Open('%s'): %w", statusPath, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			break
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return usage, fmt.Errorf("strconv.ParseUint('%s'): %w", fields[1], err)
+		}
+		usage.Memory = kb * 1024
+		break
+	}
+
+	fdPath := fmt.Sprintf("/proc/%d/fd", pid)
+	fds, err := os.ReadDir(fdPath)
+	if err != nil {
+		return usage, fmt.Errorf("os.ReadDir('%s'): %w", fdPath, err)
+	}
+	usage.Fds = uint64(len(fds))
+
+	return usage, nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux && !windows
+
+package limits
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Apply is not supported on this platform
+func Apply(_ string, _ int, _ Limits) error {
+	return fmt.Errorf("resource limits are not supported on %s", runtime.GOOS)
+}
+
+// Release has nothing to release on this platform
+func Release(_ string) error {
+	return nil
+}
+
+// Measure is not supported on this platform
+func Measure(_ int) (Usage, error) {
+	return Usage{}, fmt.Errorf("resource usage is not supported on %s", runtime.GOOS)
+}
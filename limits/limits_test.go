@@ -0,0 +1,118 @@
+package limits
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestLimitsSuite struct {
+	suite.Suite
+}
+
+// Test_10_Check tests the violations of this process
+func (test *TestLimitsSuite) Test_10_Check() {
+	s := test.Require
+
+	if runtime.GOOS != "linux" {
+		test.T().Skip("resource usage is measured on linux only")
+	}
+
+	pid := os.Getpid()
+	usage, err := Measure(pid)
+	s().NoError(err)
+	s().NotZero(usage.Memory)
+	s().NotZero(usage.Fds)
+
+	violations, err := Check("test", pid, Limits{})
+	s().NoError(err)
+	s().Empty(violations)
+
+	violations, err = Check("test", pid, Limits{Memory: 1, Fds: 1})
+	s().NoError(err)
+	s().Len(violations, 2)
+	s().Equal("memory", violations[0].Resource)
+	s().Equal("fds", violations[1].Resource)
+
+	enforcer := NewEnforcer(nil)
+	enforcer.Track("test", pid, Limits{Memory: 1})
+	enforcer.check()
+	s().Len(enforcer.Violations(), 1)
+	s().False(enforcer.Violations()[0].Killed)
+}
+
+// Test_11_Parse tests the limits declared in the configuration
+func (test *TestLimitsSuite) Test_11_Parse() {
+	s := test.Require
+
+	s().Equal("SERVICE_LIMITS_MY_DB", Env("my-db"))
+
+	limits, err := Parse("cpu=0.5, memory=268435456,fds=1024,restart=true")
+	s().NoError(err)
+	s().Equal(Limits{Cpu: 0.5, Memory: 268435456, Fds: 1024, Restart: true}, limits)
+
+	limits, err = Parse("")
+	s().NoError(err)
+	s().True(limits.IsZero())
+
+	_, err = Parse("cpu")
+	s().Error(err)
+	_, err = Parse("cpu=-1")
+	s().Error(err)
+	_, err = Parse("memory=256MB")
+	s().Error(err)
+	_, err = Parse("disk=1")
+	s().Error(err)
+}
+
+// Test_12_Restart tests the restart of the killed process and the release of the exited process
+func (test *TestLimitsSuite) Test_12_Restart() {
+	s := test.Require
+
+	if runtime.GOOS != "linux" {
+		test.T().Skip("resource usage is measured on linux only")
+	}
+
+	cmd := exec.Command("sleep", "30")
+	s().NoError(cmd.Start())
+
+	restarted := make([]string, 0)
+	enforcer := NewEnforcer(nil)
+	enforcer.SetRestart(func(id string) error {
+		restarted = append(restarted, id)
+		return nil
+	})
+	enforcer.Track("test", cmd.Process.Pid, Limits{Memory: 1, Restart: true})
+	enforcer.check()
+
+	s().Equal([]string{"test"}, restarted)
+	s().Len(enforcer.Violations(), 1)
+	s().True(enforcer.Violations()[0].Killed)
+	s().True(enforcer.Violations()[0].Restarted)
+	s().Empty(enforcer.Violations()[0].RestartError)
+
+	// the killed process is tracked until it exits
+	s().Len(enforcer.processes, 1)
+	s().Error(cmd.Wait())
+	enforcer.check()
+	s().Empty(enforcer.processes)
+	s().Len(restarted, 1)
+
+	// the process tracked again by another pid is not released by the exited one
+	enforcer.Track("test", os.Getpid(), Limits{})
+	s().NoError(enforcer.release("test", cmd.Process.Pid))
+	s().Len(enforcer.processes, 1)
+
+	s().NoError(Release("missing"))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestLimits(t *testing.T) {
+	suite.Run(t, new(TestLimitsSuite))
+}
@@ -0,0 +1,170 @@
+package limits
+
+import (
+	"fmt"
+	"golang.org/x/sys/windows"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// The job object classes and flags that are not defined in the windows package
+const (
+	jobObjectCpuRateControlEnable  = 0x1
+	jobObjectCpuRateControlHardCap = 0x4
+)
+
+// jobObjectCpuRateControlInformation is JOBOBJECT_CPU_RATE_CONTROL_INFORMATION
+// with the CpuRate member of the union
+type jobObjectCpuRateControlInformation struct {
+	ControlFlags uint32
+	CpuRate      uint32
+}
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS of the psapi
+type processMemoryCounters struct {
+	Cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+var (
+	modPsapi                  = windows.NewLazySystemDLL("psapi.dll")
+	modKernel32               = windows.NewLazySystemDLL("kernel32.dll")
+	procGetProcessMemoryInfo  = modPsapi.NewProc("GetProcessMemoryInfo")
+	procGetProcessHandleCount = modKernel32.NewProc("GetProcessHandleCount")
+	processQueryAccess        = uint32(windows.PROCESS_QUERY_LIMITED_INFORMATION | windows.PROCESS_VM_READ)
+	processAssignAccess       = uint32(windows.PROCESS_SET_QUOTA | windows.PROCESS_TERMINATE)
+
+	// the job objects by the id, closed by Release
+	jobs   = make(map[string]windows.Handle)
+	jobsMu sync.Mutex
+)
+
+// Apply sets the limits to the process.
+// The cpu and memory limits are set by a job object named after the id.
+// Windows has no file descriptor limit, the exceeded Fds are only reported by the Enforcer.
+//
+// The job object is kept open until Release, closing it would release the limits.
+// The job object of the id is shared by the restarted process.
+func Apply(id string, pid int, limits Limits) error {
+	if limits.Cpu == 0 && limits.Memory == 0 {
+		return nil
+	}
+
+	name, err := windows.UTF16PtrFromString("sds." + id)
+	if err != nil {
+		return fmt.Errorf("windows.UTF16PtrFromString('%s'): %w", id, err)
+	}
+	job, err := windows.CreateJobObject(nil, name)
+	if err != nil {
+		return fmt.Errorf("windows.CreateJobObject('sds.%s'): %w", id, err)
+	}
+
+	if limits.Memory > 0 {
+		info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+		info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+		info.ProcessMemoryLimit = uintptr(limits.Memory)
+		_, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+			uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+		if err != nil {
+			_ = windows.CloseHandle(job)
+			return fmt.Errorf("windows.SetInformationJobObject(memory): %w", err)
+		}
+	}
+	if limits.Cpu > 0 {
+		// the rate is the percent of all processors multiplied by 100
+		rate := uint32(limits.Cpu / float64(runtime.NumCPU()) * 10_000)
+		if rate == 0 {
+			rate = 1
+		} else if rate > 10_000 {
+			rate = 10_000
+		}
+		info := jobObjectCpuRateControlInformation{
+			ControlFlags: jobObjectCpuRateControlEnable | jobObjectCpuRateControlHardCap,
+			CpuRate:      rate,
+		}
+		_, err := windows.SetInformationJobObject(job, windows.JobObjectCpuRateControlInformation,
+			uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+		if err != nil {
+			_ = windows.CloseHandle(job)
+			return fmt.Errorf("windows.SetInformationJobObject(cpu): %w", err)
+		}
+	}
+
+	process, err := windows.OpenProcess(processAssignAccess, false, uint32(pid))
+	if err != nil {
+		_ = windows.CloseHandle(job)
+		return fmt.Errorf("windows.OpenProcess(%d): %w", pid, err)
+	}
+	defer func() {
+		_ = windows.CloseHandle(process)
+	}()
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		_ = windows.CloseHandle(job)
+		return fmt.Errorf("windows.AssignProcessToJobObject(%d): %w", pid, err)
+	}
+
+	jobsMu.Lock()
+	if opened, ok := jobs[id]; ok {
+		_ = windows.CloseHandle(opened)
+	}
+	jobs[id] = job
+	jobsMu.Unlock()
+
+	return nil
+}
+
+// Release closes the job object of the id
+func Release(id string) error {
+	jobsMu.Lock()
+	job, ok := jobs[id]
+	delete(jobs, id)
+	jobsMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	if err := windows.CloseHandle(job); err != nil {
+		return fmt.Errorf("windows.CloseHandle('sds.%s'): %w", id, err)
+	}
+	return nil
+}
+
+// Measure returns the memory and the handles used by the process.
+// The handles are reported as the file descriptors.
+func Measure(pid int) (Usage, error) {
+	var usage Usage
+
+	process, err := windows.OpenProcess(processQueryAccess, false, uint32(pid))
+	if err != nil {
+		return usage, fmt.Errorf("windows.OpenProcess(%d): %w", pid, err)
+	}
+	defer func() {
+		_ = windows.CloseHandle(process)
+	}()
+
+	counters := processMemoryCounters{}
+	counters.Cb = uint32(unsafe.Sizeof(counters))
+	ok, _, err := procGetProcessMemoryInfo.Call(uintptr(process), uintptr(unsafe.Pointer(&counters)), uintptr(counters.Cb))
+	if ok == 0 {
+		return usage, fmt.Errorf("GetProcessMemoryInfo(%d): %w", pid, err)
+	}
+	usage.Memory = uint64(counters.WorkingSetSize)
+
+	var handles uint32
+	ok, _, err = procGetProcessHandleCount.Call(uintptr(process), uintptr(unsafe.Pointer(&handles)))
+	if ok == 0 {
+		return usage, fmt.Errorf("GetProcessHandleCount(%d): %w", pid, err)
+	}
+	usage.Fds = uint64(handles)
+
+	return usage, nil
+}
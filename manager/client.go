@@ -7,7 +7,16 @@ import (
 	serviceConfig "github.com/ahmetson/config-lib/service"
 	"github.com/ahmetson/datatype-lib/data_type/key_value"
 	"github.com/ahmetson/datatype-lib/message"
+	context "github.com/ahmetson/dev-lib"
 	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/chaos"
+	"github.com/ahmetson/service-lib/deprecation"
+	"github.com/ahmetson/service-lib/idempotency"
+	"github.com/ahmetson/service-lib/quota"
+	"github.com/ahmetson/service-lib/replycache"
+	"github.com/ahmetson/service-lib/tap"
+	"time"
 )
 
 //
@@ -16,6 +25,7 @@ import (
 
 type Client struct {
 	*client.Socket
+	cache *replycache.Cache[key_value.KeyValue] // the replies of the queries, see EnableCache
 }
 
 // NewClient returns a manager client based on the configuration
@@ -25,7 +35,103 @@ func NewClient(c *clientConfig.Client) (*Client, error) {
 		return nil, fmt.Errorf("client.New: %w", err)
 	}
 
-	return &Client{socket}, nil
+	return &Client{Socket: socket}, nil
+}
+
+// NewClientByUrl returns the client of the service manager found in the config engine by the service url.
+// If no service has the url, then the url is tried as the service id.
+func NewClientByUrl(ctx context.Interface, url string) (*Client, error) {
+	configClient := ctx.Config()
+	serviceConf, err := configClient.ServiceByUrl(url)
+	if err != nil {
+		serviceConf, err = configClient.Service(url)
+		if err != nil {
+			return nil, fmt.Errorf("ctx.Config().ServiceByUrl('%s'): %w", url, err)
+		}
+	}
+
+	return newClientByConfig(serviceConf)
+}
+
+// NewClientById returns the client of the service manager found in the config engine by the service id.
+func NewClientById(ctx context.Interface, id string) (*Client, error) {
+	serviceConf, err := ctx.Config().Service(id)
+	if err != nil {
+		return nil, fmt.Errorf("ctx.Config().Service('%s'): %w", id, err)
+	}
+
+	return newClientByConfig(serviceConf)
+}
+
+// newClientByConfig returns the client of the manager in the service configuration
+func newClientByConfig(serviceConf *serviceConfig.Service) (*Client, error) {
+	if serviceConf.Manager == nil {
+		return nil, fmt.Errorf("service('%s'): Manager field is nil", serviceConf.Id)
+	}
+	serviceConf.Manager.UrlFunc(clientConfig.Url)
+
+	return NewClient(serviceConf.Manager)
+}
+
+// EnableCache keeps the replies of the queries, such as Units and HandlersByCategory, for the ttl given by the manager.
+// The kept replies are revalidated by their etag, and removed by Invalidate.
+// The limit is the amount of the kept replies, zero means replycache.Limit.
+func (c *Client) EnableCache(limit int) {
+	c.cache = replycache.NewCache[key_value.KeyValue](limit)
+}
+
+// Invalidate removes the kept replies invalidated by the broadcast topic, for example ConfigChangedTopic.
+func (c *Client) Invalidate(topic string) {
+	if c.cache != nil {
+		c.cache.Invalidate(topic)
+	}
+}
+
+// The cachedRequest sends the query, or returns its kept reply parameters.
+// Without the cache, it only sends the query.
+func (c *Client) cachedRequest(req *message.Request) (key_value.KeyValue, error) {
+	if c.cache == nil {
+		return c.queryRequest(req)
+	}
+
+	key, err := replycache.Key(req.Command, req.Parameters.Map())
+	if err != nil {
+		return nil, fmt.Errorf("replycache.Key: %w", err)
+	}
+	if params, ok := c.cache.Get(key); ok {
+		return params, nil
+	}
+
+	entry, stale := c.cache.Lookup(key)
+	if stale && len(entry.Hint.Etag) > 0 {
+		req.Parameters = req.Parameters.Set(replycache.EtagParam, entry.Hint.Etag)
+	}
+	params, err := c.queryRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hint, hinted := replycache.ParseHint(params.Map())
+	if replycache.NotModified(params.Map()) {
+		c.cache.Refresh(key, hint)
+		return entry.Value, nil
+	}
+	if hinted {
+		c.cache.Put(key, params, hint)
+	}
+	return params, nil
+}
+
+// The queryRequest sends the query, and returns the parameters of the successful reply
+func (c *Client) queryRequest(req *message.Request) (key_value.KeyValue, error) {
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+	return reply.ReplyParameters(), nil
 }
 
 // Heartbeat sends a command to the parent to make sure that it's live
@@ -93,20 +199,18 @@ func (c *Client) ProxyChainsByLastProxy(proxyId string) ([]*serviceConfig.ProxyC
 }
 
 // The Units method returns the destination units by a rule.
+// The reply is kept if the cache is enabled, see EnableCache.
 func (c *Client) Units(rule *serviceConfig.Rule) ([]*serviceConfig.Unit, error) {
 	req := &message.Request{
 		Command:    Units,
 		Parameters: key_value.New().Set("rule", rule),
 	}
-	reply, err := c.Request(req)
+	params, err := c.cachedRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("c.Request: %w", err)
-	}
-	if !reply.IsOK() {
-		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+		return nil, fmt.Errorf("c.cachedRequest: %w", err)
 	}
 
-	rawUnits, err := reply.ReplyParameters().NestedListValue("units")
+	rawUnits, err := params.NestedListValue("units")
 	if err != nil {
 		return nil, fmt.Errorf("reply.ReplyParameters().NestedKeyValueList('proxy_chains'): %w", err)
 	}
@@ -125,7 +229,8 @@ func (c *Client) Units(rule *serviceConfig.Rule) ([]*serviceConfig.Unit, error)
 	return units, nil
 }
 
-// The HandlersByCategory returns the list of handlers filtered by the category
+// The HandlersByCategory returns the list of handlers filtered by the category.
+// The reply is kept if the cache is enabled, see EnableCache.
 func (c *Client) HandlersByCategory(category string) ([]*handlerConfig.Handler, error) {
 	if len(category) == 0 {
 		return nil, fmt.Errorf("the 'category' parameter can not be empty")
@@ -135,15 +240,12 @@ func (c *Client) HandlersByCategory(category string) ([]*handlerConfig.Handler,
 		Command:    HandlersByCategory,
 		Parameters: key_value.New().Set("category", category),
 	}
-	reply, err := c.Request(req)
+	params, err := c.cachedRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("c.Request: %w", err)
-	}
-	if !reply.IsOK() {
-		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+		return nil, fmt.Errorf("c.cachedRequest: %w", err)
 	}
 
-	rawConfigs, err := reply.ReplyParameters().NestedListValue("handler_configs")
+	rawConfigs, err := params.NestedListValue("handler_configs")
 	if err != nil {
 		return nil, fmt.Errorf("reply.ReplyParameters().NestedKeyValueList('handler_configs'): %w", err)
 	}
@@ -195,11 +297,541 @@ func (c *Client) HandlersByRule(rule *serviceConfig.Rule) ([]*handlerConfig.Hand
 	return configs, nil
 }
 
-// The ProxyConfigSet method tells to the parent that proxy configuration set
+// The ProxyConfigSet method tells to the parent that proxy configuration set.
+//
+// The request has an idempotency key, so the parent applies it once even if the request is retried.
 func (c *Client) ProxyConfigSet(rule *serviceConfig.Rule, serviceSource *serviceConfig.SourceService) error {
+	key, err := idempotency.NewKey()
+	if err != nil {
+		return fmt.Errorf("idempotency.NewKey: %w", err)
+	}
+
+	req := &message.Request{
+		Command: ProxyConfigSet,
+		Parameters: key_value.New().
+			Set("rule", rule).
+			Set("source_service", serviceSource).
+			Set(idempotency.Param, key),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	return nil
+}
+
+// The Leader method returns true if the service instance is a leader.
+// The service without a leader election is always a leader.
+func (c *Client) Leader() (bool, error) {
+	req := &message.Request{
+		Command:    Leadership,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return false, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return false, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	leader, err := reply.ReplyParameters().BoolValue("leader")
+	if err != nil {
+		return false, fmt.Errorf("reply.ReplyParameters().BoolValue('leader'): %w", err)
+	}
+
+	return leader, nil
+}
+
+// The CatchUp method returns the missed broadcasts of the topic by the sequence range.
+// If the 'to' is 0, then returns till the last broadcast.
+func (c *Client) CatchUp(topic string, from uint64, to uint64) ([]broadcast.Sequenced, error) {
+	return c.broadcasts(CatchUp, key_value.New().Set("topic", topic).Set("from", from).Set("to", to))
+}
+
+// The Replay method returns the broadcasts of the topic persisted in the journal of the service.
+// If the 'to' is 0, then returns till the last broadcast.
+// At most 'limit' broadcasts are returned, if the limit is 0, then ReplayLimit.
+// Call it again from the sequence number after the last returned broadcast to get the rest.
+func (c *Client) Replay(topic string, from uint64, to uint64, limit uint64) ([]broadcast.Sequenced, error) {
+	params := key_value.New().Set("topic", topic).Set("from", from).Set("to", to)
+	if limit > 0 {
+		params.Set("limit", limit)
+	}
+	return c.broadcasts(Replay, params)
+}
+
+// The broadcasts method requests the broadcasts by the sequence range with the given command.
+func (c *Client) broadcasts(command string, params key_value.KeyValue) ([]broadcast.Sequenced, error) {
+	req := &message.Request{
+		Command:    command,
+		Parameters: params,
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	rawBroadcasts, err := reply.ReplyParameters().NestedListValue("broadcasts")
+	if err != nil {
+		return nil, fmt.Errorf("reply.ReplyParameters().NestedListValue('broadcasts'): %w", err)
+	}
+
+	broadcasts := make([]broadcast.Sequenced, len(rawBroadcasts))
+	for i, rawBroadcast := range rawBroadcasts {
+		err = rawBroadcast.Interface(&broadcasts[i])
+		if err != nil {
+			return nil, fmt.Errorf("rawBroadcasts[%d].Interface: %w", i, err)
+		}
+	}
+
+	return broadcasts, nil
+}
+
+// The Status method returns the state of the service.
+func (c *Client) Status() (key_value.KeyValue, error) {
+	req := &message.Request{
+		Command:    Status,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	return reply.ReplyParameters(), nil
+}
+
+// Pid returns the process id of the service, see Status
+func (c *Client) Pid() (int, error) {
+	status, err := c.Status()
+	if err != nil {
+		return 0, fmt.Errorf("c.Status: %w", err)
+	}
+	pid, err := status.Uint64Value("pid")
+	if err != nil {
+		return 0, fmt.Errorf("status.Uint64Value('pid'): %w", err)
+	}
+	return int(pid), nil
+}
+
+// ShuttingDown notifies the proxy or extension that the parent service is closing.
+// The parent force-closes it after the drain period.
+func (c *Client) ShuttingDown(parentId string) error {
+	req := &message.Request{
+		Command:    ShuttingDown,
+		Parameters: key_value.New().Set("parent_id", parentId),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	return nil
+}
+
+// StartHandler starts the lazy handler of the category.
+// If the handler is running already, then nothing happens.
+func (c *Client) StartHandler(category string) error {
+	req := &message.Request{
+		Command:    StartHandler,
+		Parameters: key_value.New().Set("category", category),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	return nil
+}
+
+// The Handoff method tells the old instance of the service that the upgraded one took over.
+// The old instance stops updating the proxy units, drains and closes, keeping the proxies.
+func (c *Client) Handoff() error {
+	req := &message.Request{
+		Command:    Handoff,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	return nil
+}
+
+// The SetFlag method toggles the feature flag of the service.
+// Returns the flags set at runtime.
+func (c *Client) SetFlag(name string, enabled bool) (map[string]bool, error) {
+	req := &message.Request{
+		Command:    Flag,
+		Parameters: key_value.New().Set("name", name).Set("enabled", enabled),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	raw, err := reply.ReplyParameters().NestedValue("flags")
+	if err != nil {
+		return nil, fmt.Errorf("reply.ReplyParameters().NestedValue('flags'): %w", err)
+	}
+	flags := make(map[string]bool)
+	if err := raw.Interface(&flags); err != nil {
+		return nil, fmt.Errorf("raw.Interface: %w", err)
+	}
+	return flags, nil
+}
+
+// The Usage method returns the requests and bytes received from the source services, and their quotas.
+func (c *Client) Usage() ([]quota.Usage, error) {
+	req := &message.Request{
+		Command:    Usage,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	var usage []quota.Usage
+	raw, err := reply.ReplyParameters().NestedListValue("usage")
+	if err != nil {
+		return nil, fmt.Errorf("reply.ReplyParameters().NestedListValue('usage'): %w", err)
+	}
+	for _, kv := range raw {
+		var sourceUsage quota.Usage
+		if err := kv.Interface(&sourceUsage); err != nil {
+			return nil, fmt.Errorf("kv.Interface: %w", err)
+		}
+		usage = append(usage, sourceUsage)
+	}
+	return usage, nil
+}
+
+// The Snapshot method writes the state of the service into the tarball at the path on the service's host.
+func (c *Client) Snapshot(path string) error {
+	return c.withPath(Snapshot, path)
+}
+
+// The Restore method restores the state of the service from the tarball at the path on the service's host.
+// The handlers whose configuration is changed by the snapshot are restarted.
+func (c *Client) Restore(path string) error {
+	return c.withPath(Restore, path)
+}
+
+func (c *Client) withPath(command string, path string) error {
+	req := &message.Request{
+		Command:    command,
+		Parameters: key_value.New().Set("path", path),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	return nil
+}
+
+// The Config method returns the configuration of the service.
+func (c *Client) Config() (*serviceConfig.Service, error) {
+	req := &message.Request{
+		Command:    Config,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	raw, err := reply.ReplyParameters().NestedValue("service")
+	if err != nil {
+		return nil, fmt.Errorf("reply.ReplyParameters().NestedValue('service'): %w", err)
+	}
+	var serviceConf serviceConfig.Service
+	if err := raw.Interface(&serviceConf); err != nil {
+		return nil, fmt.Errorf("raw.Interface: %w", err)
+	}
+
+	return &serviceConf, nil
+}
+
+// The Commands method returns the commands of the manager.
+func (c *Client) Commands() ([]string, error) {
+	req := &message.Request{
+		Command:    Commands,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	rawCommands, ok := reply.ReplyParameters()["commands"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("reply.ReplyParameters()['commands'] is not a list")
+	}
+	commands := make([]string, len(rawCommands))
+	for i, raw := range rawCommands {
+		commands[i], ok = raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("reply.ReplyParameters()['commands'][%d] is not a string", i)
+		}
+	}
+
+	return commands, nil
+}
+
+// The Describe method returns the metadata of the versioned routes.
+func (c *Client) Describe() ([]deprecation.Info, error) {
+	req := &message.Request{
+		Command:    Describe,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	rawRoutes, err := reply.ReplyParameters().NestedListValue("routes")
+	if err != nil {
+		return nil, fmt.Errorf("reply.ReplyParameters().NestedListValue('routes'): %w", err)
+	}
+
+	routes := make([]deprecation.Info, len(rawRoutes))
+	for i, rawRoute := range rawRoutes {
+		if err := rawRoute.Interface(&routes[i]); err != nil {
+			return nil, fmt.Errorf("rawRoutes[%d].Interface: %w", i, err)
+		}
+	}
+
+	return routes, nil
+}
+
+// The HandlerCommands method returns the commands of the handlers by their category.
+func (c *Client) HandlerCommands() (map[string][]string, error) {
+	req := &message.Request{
+		Command:    Describe,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	commands, err := stringListMap(reply.ReplyParameters(), "commands")
+	if err != nil {
+		return nil, fmt.Errorf("stringListMap: %w", err)
+	}
+
+	return commands, nil
+}
+
+// The Routes method returns the commands registered in the running handler of the category.
+// The Missing commands are configured by the route rules, but the handler doesn't have them.
+func (c *Client) Routes(category string) (*RouteList, error) {
+	req := &message.Request{
+		Command:    Routes,
+		Parameters: key_value.New().Set("category", category),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	raw, err := reply.ReplyParameters().NestedValue("routes")
+	if err != nil {
+		return nil, fmt.Errorf("reply.ReplyParameters().NestedValue('routes'): %w", err)
+	}
+	var routes RouteList
+	if err := raw.Interface(&routes); err != nil {
+		return nil, fmt.Errorf("raw.Interface: %w", err)
+	}
+	return &routes, nil
+}
+
+// The Tags method returns the tags of the handlers by their category.
+// The rules reference the tags by tag.Ref instead of the categories.
+func (c *Client) Tags() (map[string][]string, error) {
+	req := &message.Request{
+		Command:    Describe,
+		Parameters: key_value.New(),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	tags, err := stringListMap(reply.ReplyParameters(), "tags")
+	if err != nil {
+		return nil, fmt.Errorf("stringListMap: %w", err)
+	}
+
+	return tags, nil
+}
+
+// The stringListMap returns the parameter of the string lists by the key
+func stringListMap(params key_value.KeyValue, name string) (map[string][]string, error) {
+	rawLists, ok := params[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("reply.ReplyParameters()['%s'] is not a map", name)
+	}
+	lists := make(map[string][]string, len(rawLists))
+	for key, rawList := range rawLists {
+		list, ok := rawList.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("reply.ReplyParameters()['%s']['%s'] is not a list", name, key)
+		}
+		lists[key] = make([]string, len(list))
+		for i, raw := range list {
+			lists[key][i], ok = raw.(string)
+			if !ok {
+				return nil, fmt.Errorf("reply.ReplyParameters()['%s']['%s'][%d] is not a string", name, key, i)
+			}
+		}
+	}
+
+	return lists, nil
+}
+
+// The ExplainRoute method returns how each proxy chain treats the command of the handler category.
+func (c *Client) ExplainRoute(command string, category string) ([]Explanation, error) {
+	req := &message.Request{
+		Command:    ExplainRoute,
+		Parameters: key_value.New().Set("command", command).Set("category", category),
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	rawExplanations, err := reply.ReplyParameters().NestedListValue("explanations")
+	if err != nil {
+		return nil, fmt.Errorf("reply.ReplyParameters().NestedListValue('explanations'): %w", err)
+	}
+
+	explanations := make([]Explanation, len(rawExplanations))
+	for i, rawExplanation := range rawExplanations {
+		if err := rawExplanation.Interface(&explanations[i]); err != nil {
+			return nil, fmt.Errorf("rawExplanations[%d].Interface: %w", i, err)
+		}
+	}
+
+	return explanations, nil
+}
+
+// The Call method sends the command with the parameters, and returns the reply parameters.
+// Use it for the custom commands of the manager.
+func (c *Client) Call(command string, parameters key_value.KeyValue) (key_value.KeyValue, error) {
+	req := &message.Request{
+		Command:    command,
+		Parameters: parameters,
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	return reply.ReplyParameters(), nil
+}
+
+// The Tap method attaches the tap forwarding the copies of the category's requests and replies
+// to the endpoint for the duration. The zero duration detaches the tap.
+// Only the proxies forward the taps.
+func (c *Client) Tap(category string, endpoint string, duration time.Duration) ([]tap.Tap, error) {
+	params, err := key_value.NewFromInterface(tap.Params{Category: category, Endpoint: endpoint, Duration: duration})
+	if err != nil {
+		return nil, fmt.Errorf("key_value.NewFromInterface: %w", err)
+	}
+	req := &message.Request{
+		Command:    Tap,
+		Parameters: params,
+	}
+	reply, err := c.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("c.Request: %w", err)
+	}
+	if !reply.IsOK() {
+		return nil, fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+	}
+
+	var taps []tap.Tap
+	raw, err := reply.ReplyParameters().NestedListValue("taps")
+	if err != nil {
+		return nil, fmt.Errorf("reply.ReplyParameters().NestedListValue('taps'): %w", err)
+	}
+	for _, kv := range raw {
+		var attached tap.Tap
+		if err := kv.Interface(&attached); err != nil {
+			return nil, fmt.Errorf("kv.Interface: %w", err)
+		}
+		taps = append(taps, attached)
+	}
+	return taps, nil
+}
+
+// The Chaos method sets the faults injected into the routes of the service.
+// The service must be built with the chaos tag.
+func (c *Client) Chaos(config chaos.Config) error {
+	params, err := key_value.NewFromInterface(config)
+	if err != nil {
+		return fmt.Errorf("key_value.NewFromInterface: %w", err)
+	}
 	req := &message.Request{
-		Command:    ProxyConfigSet,
-		Parameters: key_value.New().Set("rule", rule).Set("source_service", serviceSource),
+		Command:    Chaos,
+		Parameters: params,
 	}
 	reply, err := c.Request(req)
 	if err != nil {
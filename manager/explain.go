@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"slices"
+)
+
+// Explanation of how the proxy chain treats the request
+type Explanation struct {
+	Sources     []string              `json:"sources"`
+	Proxies     []string              `json:"proxies"` // the proxy ids in the order the request passes them
+	Destination *serviceConfig.Rule   `json:"destination"`
+	Matched     bool                  `json:"matched"`
+	Reasons     []string              `json:"reasons"` // why the rule was skipped, or how it matched
+	Units       []*serviceConfig.Unit `json:"units"`   // the units the request resolves to
+}
+
+// The explain returns how the rule treats the command of the category sent to this service.
+// The commands are the route commands of the handlers by their category.
+func (m *Manager) explain(rule *serviceConfig.Rule, command string, category string,
+	handlers []*handlerConfig.Handler, commands map[string][]string) (bool, []string, []*serviceConfig.Unit) {
+	reasons := make([]string, 0)
+	units := make([]*serviceConfig.Unit, 0)
+
+	if !slices.Contains(rule.Urls, m.serviceUrl) {
+		return false, append(reasons, fmt.Sprintf("the rule urls %v don't include this service '%s'", rule.Urls, m.serviceUrl)), units
+	}
+	if slices.Contains(rule.ExcludedCommands, command) {
+		return false, append(reasons, fmt.Sprintf("'%s' command is excluded by the rule", command)), units
+	}
+
+	switch {
+	case rule.IsRoute():
+		if !m.tags.MatchAny(rule.Categories, category) {
+			return false, append(reasons, fmt.Sprintf("the route rule categories %v don't include '%s' or its tags %v", rule.Categories, category, m.tags.Tags(category))), units
+		}
+		if !slices.Contains(rule.Commands, command) {
+			return false, append(reasons, fmt.Sprintf("the route rule commands %v don't include '%s'", rule.Commands, command)), units
+		}
+		reasons = append(reasons, "matched by the route rule")
+	case rule.IsHandler():
+		if !m.tags.MatchAny(rule.Categories, category) {
+			return false, append(reasons, fmt.Sprintf("the handler rule categories %v don't include '%s' or its tags %v", rule.Categories, category, m.tags.Tags(category))), units
+		}
+		reasons = append(reasons, "matched by the handler rule")
+	default:
+		reasons = append(reasons, "matched by the service rule")
+	}
+
+	if !slices.Contains(commands[category], command) {
+		return false, append(reasons, fmt.Sprintf("the '%s' handler has no '%s' command", category, command)), units
+	}
+	for _, handler := range handlerConfig.ByCategory(handlers, category) {
+		units = append(units, &serviceConfig.Unit{
+			ServiceId: m.serviceId,
+			HandlerId: handler.Id,
+			Command:   command,
+		})
+	}
+	if len(units) == 0 {
+		return false, append(reasons, fmt.Sprintf("no running handler of '%s' category", category)), units
+	}
+
+	return true, reasons, units
+}
+
+// onExplainRoute reports which proxy chains match the hypothetical request,
+// which units it resolves to, and why the other proxy chains were skipped.
+func (m *Manager) onExplainRoute(req message.RequestInterface) message.ReplyInterface {
+	command, err := req.RouteParameters().StringValue("command")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('command'): %v", err))
+	}
+	category, err := req.RouteParameters().StringValue("category")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('category'): %v", err))
+	}
+
+	handlers, err := m.handlers()
+	if err != nil {
+		return req.Fail(fmt.Sprintf("m.handlers: %v", err))
+	}
+	commands := make(map[string][]string)
+	if m.routeCommands != nil {
+		commands = m.routeCommands()
+	}
+
+	proxyChains, err := m.ctx.ProxyClient().ProxyChains()
+	if err != nil {
+		return req.Fail(fmt.Sprintf("proxyClient.ProxyChains: %v", err))
+	}
+
+	explanations := make([]Explanation, len(proxyChains))
+	for i, proxyChain := range proxyChains {
+		proxies := make([]string, len(proxyChain.Proxies))
+		for j, proxy := range proxyChain.Proxies {
+			proxies[j] = proxy.Id
+		}
+		explanations[i] = Explanation{
+			Sources:     proxyChain.Sources,
+			Proxies:     proxies,
+			Destination: proxyChain.Destination,
+		}
+		explanations[i].Matched, explanations[i].Reasons, explanations[i].Units = m.explain(proxyChain.Destination, command, category, handlers, commands)
+	}
+
+	params := key_value.New().Set("explanations", explanations)
+	return req.Ok(params)
+}
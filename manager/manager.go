@@ -12,7 +12,27 @@ import (
 	handlerConfig "github.com/ahmetson/handler-lib/config"
 	"github.com/ahmetson/handler-lib/manager_client"
 	syncReplier "github.com/ahmetson/handler-lib/sync_replier"
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/chaos"
+	"github.com/ahmetson/service-lib/deprecation"
+	"github.com/ahmetson/service-lib/errs"
+	"github.com/ahmetson/service-lib/featureflag"
+	"github.com/ahmetson/service-lib/ha"
+	"github.com/ahmetson/service-lib/idempotency"
+	"github.com/ahmetson/service-lib/limits"
+	"github.com/ahmetson/service-lib/monitor"
+	"github.com/ahmetson/service-lib/namespace"
+	"github.com/ahmetson/service-lib/quota"
+	"github.com/ahmetson/service-lib/replycache"
+	"github.com/ahmetson/service-lib/schema"
+	"github.com/ahmetson/service-lib/slo"
+	"github.com/ahmetson/service-lib/tag"
+	"github.com/ahmetson/service-lib/tap"
+	"math"
+	"os"
+	"slices"
 	"sync"
+	"time"
 )
 
 const (
@@ -24,6 +44,56 @@ const (
 	HandlersByCategory  = "handlers-by-category" // returns the handler configurations by their category
 	HandlersByRule      = "handlers-by-rule"     // returns the handler configurations filtered by serviceConfig.Rule
 	ProxyConfigSet      = "proxy-config-set"     // proxy calls this route when there configuration was set
+	Leadership          = "leadership"           // returns the leadership state of this instance
+	CatchUp             = "catch-up"             // returns the missed broadcasts by the sequence range
+	Replay              = "replay"               // returns the broadcasts from the journal by the sequence range
+	Status              = "status"               // returns the state of the service and the socket metrics
+	ShuttingDown        = "shutting-down"        // the parent notifies that it's closing, stop forwarding to it
+	StartHandler        = "start-handler"        // starts the lazy handler by its category
+	Config              = "config"               // returns the configuration of the service
+	Commands            = "commands"             // returns the commands of the manager, including the custom ones
+	Chaos               = "chaos"                // sets the injected faults, only in the binaries built with the chaos tag
+	Describe            = "describe"             // returns the handlers with the versions and deprecations of their routes
+	ExplainRoute        = "explain-route"        // explains which proxy chains and units match the command of the category
+	Tap                 = "tap"                  // attaches the tap forwarding the copies of the category's requests and replies
+	Snapshot            = "snapshot"             // writes the state of the service into the tarball at the path
+	Restore             = "restore"              // restores the state of the service from the tarball at the path
+	Handoff             = "handoff"              // the upgraded instance took over, drain and close keeping the proxies
+	Flag                = "flag"                 // toggles the feature flag of the service
+	Usage               = "usage"                // returns the requests and bytes by the source services
+	Routes              = "routes"               // returns the commands registered in the running handler of the category
+)
+
+// CacheTtl is how long the clients keep the replies of the queries, see replycache
+const CacheTtl = time.Second * 10
+
+// ConfigChangedTopic is the topic of the broadcast when the configuration of the service changed.
+// It invalidates the replies of the queries kept by the clients.
+const ConfigChangedTopic = "config-changed"
+
+// DrainPeriod is the time given to the proxies and extensions to drain before the manager force-closes them
+const DrainPeriod = time.Second * 5
+
+// StatusTimeout is the time given to the handlers to report their parts in the Status.
+// The slower handlers are reported as unreachable.
+const StatusTimeout = time.Second
+
+// rangeSchema is the parameters of the broadcast range requests
+var rangeSchema = schema.New(
+	schema.Required("topic", schema.String),
+	schema.Required("from", schema.Number).Range(0, math.MaxUint64),
+	schema.Required("to", schema.Number).Range(0, math.MaxUint64),
+)
+
+// ReplayLimit is the most broadcasts returned by one replay request
+const ReplayLimit = 1000
+
+// replaySchema is the parameters of the replay requests
+var replaySchema = schema.New(
+	schema.Required("topic", schema.String),
+	schema.Required("from", schema.Number).Range(0, math.MaxUint64),
+	schema.Required("to", schema.Number).Range(0, math.MaxUint64),
+	schema.Optional("limit", schema.Number).Range(1, ReplayLimit),
 )
 
 // The Manager keeps all necessary parameters of the service.
@@ -38,6 +108,35 @@ type Manager struct {
 	blocker         **sync.WaitGroup // block the service
 	running         bool
 	config          *clientConfig.Client
+	elector         ha.Elector
+	feed            *broadcast.Feed
+	monitor         *monitor.Monitor
+	enforcer        *limits.Enforcer
+	replies         *idempotency.Cache[message.ReplyInterface] // the replies of the mutating commands by idempotency key
+	drainPeriod     time.Duration
+	draining        bool
+	onShuttingDown  []func()
+	parentsDraining map[string]bool // the parents that announced the shutdown
+	onParentDrain   []func(parentId string)
+	handlerStarter  func(category string) error // starts the lazy handlers
+	snapshot        func(path string) error     // writes the state of the service into the tarball
+	restore         func(path string) error     // restores the state of the service from the tarball
+	routes          *deprecation.Registry       // the versions and deprecations of the routes
+	acl             *namespace.ACL              // the namespaces allowed to connect to this service
+	tags            *tag.Registry               // the tags of the handler categories targeted by the rules
+	taps            *tap.Registry               // the taps forwarded by the proxies, optional
+	slo             *slo.Tracker                // the objectives of the routes observed by the proxies, optional
+	flags           *featureflag.Registry       // the feature flags toggled by the flag command, optional
+	quotas          *quota.Accountant           // the usage of the source services, optional
+	internalIds     []string                    // the ids of the handlers hidden from the other services
+	beforeClose     func() error                // the service hooks run before closing
+	afterClose      func() error                // the service hooks run after closing
+	routeCommands   func() map[string][]string  // the commands of the handlers by their category
+	degraded        func() map[string]string    // the failed optional extensions
+	priorities      map[string]int              // the shutdown priorities by the handler, proxy or extension id
+	polling         map[string]bool             // the handlers polled for the status, that didn't reply yet
+	pollingMu       sync.Mutex
+	mu              sync.RWMutex
 }
 
 // New service with the parameters.
@@ -63,6 +162,12 @@ func New(ctx context.Interface, serviceId string, blocker **sync.WaitGroup) (*Ma
 		deps:            make([]*clientConfig.Client, 0),
 		blocker:         blocker,
 		config:          returnedConfig.Manager,
+		replies:         idempotency.NewCache[message.ReplyInterface](0, 0),
+		drainPeriod:     DrainPeriod,
+		onShuttingDown:  make([]func(), 0),
+		parentsDraining: make(map[string]bool),
+		onParentDrain:   make([]func(parentId string), 0),
+		polling:         make(map[string]bool),
 	}
 
 	managerConfig := HandlerConfig(returnedConfig.Manager)
@@ -82,61 +187,349 @@ func New(ctx context.Interface, serviceId string, blocker **sync.WaitGroup) (*Ma
 // It closes this manager.
 //
 // It closes all proxies.
+// Before closing, the proxies and extensions are notified by ShuttingDown command,
+// and given the drain period to stop forwarding the requests to this service.
+//
+// The proxies and handlers are notified and closed in the order of their shutdown priorities,
+// see SetShutdownPriorities.
+//
+// If the before-stop hook fails, the service is not closed.
+// Otherwise, the failed step doesn't stop the rest, the errors are aggregated by errs.Join.
 func (m *Manager) Close() error {
+	if m.beforeClose != nil {
+		if err := m.beforeClose(); err != nil {
+			return fmt.Errorf("beforeClose: %w", err)
+		}
+	}
+	return m.close(false, true)
+}
+
+// Rollback closes the service that failed to start.
+// Unlike the Close, the stop hooks are not called, as the service never started.
+func (m *Manager) Rollback() error {
+	return m.close(false, false)
+}
+
+// The close closes the service.
+// On the handoff, the proxies and the context are kept for the new instance of the service,
+// and the draining handlers are given the drain period to finish the requests.
+// The after-stop hook is called if the hooks is true.
+// The blocker is released even if any step failed.
+func (m *Manager) close(handoff bool, hooks bool) error {
+	var closeErr error
+	if handoff {
+		m.setDraining()
+		time.Sleep(m.drainPeriod)
+	} else {
+		closeErr = m.closeProxies()
+	}
+
+	// closing all handlers in the shutdown order
+	handlerManagers := slices.Clone(m.handlerManagers)
+	slices.SortStableFunc(handlerManagers, func(a, b manager_client.Interface) int {
+		return m.priority(a.Id()) - m.priority(b.Id())
+	})
+	for _, h := range handlerManagers {
+		closeErr = errs.Join(closeErr, errs.Wrap(fmt.Sprintf("handlerManagers('%s').Close", h.Id()), h.Close()))
+	}
+	m.handlerManagers = make([]manager_client.Interface, 0)
+
+	if !handoff {
+		closeErr = errs.Join(closeErr, errs.Wrap("ctx.Close", m.ctx.Close()))
+	}
+
+	managerConfig := HandlerConfig(m.config)
+	handlerManager, err := manager_client.New(managerConfig)
+	if err != nil {
+		closeErr = errs.Join(closeErr, fmt.Errorf("manager_client.New: %w", err))
+	} else {
+		closeErr = errs.Join(closeErr, errs.Wrap("handler.Close", handlerManager.Close()))
+	}
+
+	m.running = false
+	if hooks && m.afterClose != nil {
+		closeErr = errs.Join(closeErr, errs.Wrap("afterClose", m.afterClose()))
+	}
+	m.releaseBlocker()
+
+	return closeErr
+}
+
+// The closeProxies notifies the proxies and extensions that the service is shutting down,
+// waits the drain period, then closes the proxies in the shutdown order.
+// The failed proxy doesn't stop closing the rest.
+func (m *Manager) closeProxies() error {
 	serviceConf, err := m.ctx.Config().Service(m.serviceId)
 	if err != nil {
 		return fmt.Errorf("m.ctx.Config().Service(id='%s'): %w", m.serviceId, err)
 	}
+
+	m.setDraining()
+	if notified := m.notifyShuttingDown(serviceConf); notified > 0 {
+		time.Sleep(m.drainPeriod)
+	}
+
+	proxies := make([]*serviceConfig.Proxy, 0)
+	for ruleIndex := range serviceConf.Sources {
+		proxies = append(proxies, serviceConf.Sources[ruleIndex].Proxies...)
+	}
+	slices.SortStableFunc(proxies, func(a, b *serviceConfig.Proxy) int {
+		return m.priority(a.Id) - m.priority(b.Id)
+	})
+
 	depManager := m.ctx.DepClient()
+	var closeErr error
+	for _, proxy := range proxies {
+		proxy.Manager.UrlFunc(clientConfig.Url)
+		if err := depManager.CloseDep(proxy.Manager); err != nil {
+			closeErr = errs.Join(closeErr, fmt.Errorf("depManager.CloseDep(proxy = %v): %w", *proxy, err))
+		}
+	}
+	return closeErr
+}
+
+// releaseBlocker lets the service exit.
+// The blocker is released once, the next close doesn't release it again.
+func (m *Manager) releaseBlocker() {
+	if m.blocker != nil && *m.blocker != nil {
+		fmt.Printf("blocker done!\n")
+		(*m.blocker).Done()
+		*m.blocker = nil
+	} else {
+		fmt.Printf("blocker is nil\n")
+	}
+}
+
+func (m *Manager) Running() bool {
+	return m.running
+}
+
+// setDraining marks the manager as shutting down and calls the shutting down callbacks once.
+func (m *Manager) setDraining() {
+	m.mu.Lock()
+	if m.draining {
+		m.mu.Unlock()
+		return
+	}
+	m.draining = true
+	callbacks := m.onShuttingDown
+	m.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback()
+	}
+}
+
+// Draining returns true if the service is shutting down.
+// The parents shutting down don't drain the service, see ParentDraining.
+func (m *Manager) Draining() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.draining
+}
+
+// OnShuttingDown adds the callback invoked when the service starts shutting down.
+func (m *Manager) OnShuttingDown(callback func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onShuttingDown = append(m.onShuttingDown, callback)
+}
+
+// setParentDraining marks the parent as shutting down and calls the parent callbacks once per announcement.
+func (m *Manager) setParentDraining(parentId string) {
+	m.mu.Lock()
+	if m.parentsDraining[parentId] {
+		m.mu.Unlock()
+		return
+	}
+	m.parentsDraining[parentId] = true
+	callbacks := m.onParentDrain
+	m.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(parentId)
+	}
+}
+
+// ParentDraining returns true if the parent announced the shutdown, and didn't reconnect yet.
+func (m *Manager) ParentDraining(parentId string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.parentsDraining[parentId]
+}
+
+// ResetParent clears the shutdown of the parent, once it's reachable again.
+// The next shutdown of the parent calls the parent callbacks again.
+func (m *Manager) ResetParent(parentId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.parentsDraining, parentId)
+}
+
+// OnParentShuttingDown adds the callback invoked when the parent starts shutting down.
+// For example, the proxy stops forwarding the requests to the destination until the parent is reachable again.
+func (m *Manager) OnParentShuttingDown(callback func(parentId string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onParentDrain = append(m.onParentDrain, callback)
+}
+
+// SetDrainPeriod sets the time given to the proxies and extensions to drain.
+// Default is DrainPeriod.
+func (m *Manager) SetDrainPeriod(period time.Duration) {
+	m.drainPeriod = period
+}
+
+// The notifyShuttingDown sends ShuttingDown command to the proxies and extensions in the shutdown order.
+// The unreachable parts are skipped, as they will be force-closed anyway.
+//
+// Returns the amount of the notified parts.
+func (m *Manager) notifyShuttingDown(serviceConf *serviceConfig.Service) int {
+	configs := make([]*clientConfig.Client, 0, len(m.deps))
 	for ruleIndex := range serviceConf.Sources {
 		for i := range serviceConf.Sources[ruleIndex].Proxies {
 			proxy := serviceConf.Sources[ruleIndex].Proxies[i]
 			proxy.Manager.UrlFunc(clientConfig.Url)
-			err := depManager.CloseDep(proxy.Manager)
-			if err != nil {
-				return fmt.Errorf("depManager.CloseDep(serviceConf.Sources[%d].Proxies[%d] = %v): %w",
-					ruleIndex, i, *proxy, err)
-			}
+			configs = append(configs, proxy.Manager)
 		}
 	}
+	configs = append(configs, m.deps...)
+	slices.SortStableFunc(configs, func(a, b *clientConfig.Client) int {
+		return m.priority(a.Id) - m.priority(b.Id)
+	})
 
-	// closing all handlers
-	for _, h := range m.handlerManagers {
-		err := h.Close()
+	notified := 0
+	for _, c := range configs {
+		depClient, err := NewClient(c)
 		if err != nil {
-			return fmt.Errorf("handlerManagers('%s').Close: %v", h.Id(), err)
+			continue
 		}
+		if err := depClient.ShuttingDown(m.serviceId); err == nil {
+			notified++
+		}
+		_ = depClient.Socket.Close()
 	}
-	m.handlerManagers = make([]manager_client.Interface, 0)
 
-	err = m.ctx.Close()
+	return notified
+}
+
+// onShuttingDown received from the parent that is closing.
+// The service stops using the parent, while the parent force-closes it after the drain period.
+// Only the parent is marked as draining, the service keeps serving its other parents.
+func (m *Manager) onShuttingDown(req message.RequestInterface) message.ReplyInterface {
+	parentId, err := req.RouteParameters().StringValue("parent_id")
 	if err != nil {
-		return fmt.Errorf("ctx.Close: %w", err)
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('parent_id'): %v", err))
 	}
+	m.setParentDraining(parentId)
 
-	managerConfig := HandlerConfig(m.config)
-	handlerManager, err := manager_client.New(managerConfig)
+	return req.Ok(key_value.New())
+}
+
+// SetHandlerStarter sets the function that starts the lazy handler by its category.
+func (m *Manager) SetHandlerStarter(starter func(category string) error) {
+	m.handlerStarter = starter
+}
+
+// onStartHandler starts the lazy handler.
+// The proxy calls it before forwarding the first request to the handler.
+// If the handler is running already, then nothing happens.
+func (m *Manager) onStartHandler(req message.RequestInterface) message.ReplyInterface {
+	if m.handlerStarter == nil {
+		return req.Fail("no lazy handlers")
+	}
+
+	category, err := req.RouteParameters().StringValue("category")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.Parameters.StringValue('category'): %v", err))
+	}
+
+	if err := m.handlerStarter(category); err != nil {
+		return req.Fail(fmt.Sprintf("handlerStarter('%s'): %v", category, err))
+	}
+
+	return req.Ok(key_value.New())
+}
+
+// onFlag sets the feature flag, and returns the flags set at runtime
+func (m *Manager) onFlag(req message.RequestInterface) message.ReplyInterface {
+	if m.flags == nil {
+		return req.Fail("the service has no feature flags")
+	}
+
+	name, err := req.RouteParameters().StringValue("name")
 	if err != nil {
-		return fmt.Errorf("manager_client.New: %w", err)
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('name'): %v", err))
 	}
-	err = handlerManager.Close()
+	enabled, err := req.RouteParameters().BoolValue("enabled")
 	if err != nil {
-		return fmt.Errorf("handler.Close: %w", err)
+		return req.Fail(fmt.Sprintf("req.RouteParameters().BoolValue('enabled'): %v", err))
 	}
 
-	m.running = false
-	if m.blocker != nil && *m.blocker != nil {
-		fmt.Printf("blocker done!\n")
-		(*m.blocker).Done()
-	} else {
-		fmt.Printf("blocker is nil\n")
+	if _, err := m.flags.Set(name, enabled); err != nil {
+		return req.Fail(fmt.Sprintf("flags.Set('%s'): %v", name, err))
 	}
 
-	return nil
+	return req.Ok(key_value.New().Set("flags", m.flags.All()))
 }
 
-func (m *Manager) Running() bool {
-	return m.running
+// onUsage returns the usage and quotas of the source services
+func (m *Manager) onUsage(req message.RequestInterface) message.ReplyInterface {
+	return req.Ok(key_value.New().Set("usage", m.quotas.Usage()))
+}
+
+// onSnapshot writes the state of the service into the tarball at the path on the service's host
+func (m *Manager) onSnapshot(req message.RequestInterface) message.ReplyInterface {
+	if m.snapshot == nil {
+		return req.Fail("the service doesn't support the snapshots")
+	}
+
+	path, err := req.RouteParameters().StringValue("path")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('path'): %v", err))
+	}
+	if err := m.snapshot(path); err != nil {
+		return req.Fail(fmt.Sprintf("snapshot('%s'): %v", path, err))
+	}
+
+	return req.Ok(key_value.New())
+}
+
+// onRestore restores the state of the service from the tarball at the path on the service's host
+func (m *Manager) onRestore(req message.RequestInterface) message.ReplyInterface {
+	if m.restore == nil {
+		return req.Fail("the service doesn't support the snapshots")
+	}
+
+	path, err := req.RouteParameters().StringValue("path")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('path'): %v", err))
+	}
+	if err := m.restore(path); err != nil {
+		return req.Fail(fmt.Sprintf("restore('%s'): %v", path, err))
+	}
+
+	return req.Ok(key_value.New())
+}
+
+// onHandoff received from the new instance of the service that took over during the upgrade.
+// The service stops updating the proxy units at once, and closes in the background after the drain period.
+// The proxies and the context are kept, as they serve the new instance.
+func (m *Manager) onHandoff(req message.RequestInterface) message.ReplyInterface {
+	m.setDraining()
+	go func() {
+		if m.beforeClose != nil && m.beforeClose() != nil {
+			return
+		}
+		_ = m.close(true, true)
+	}()
+
+	return req.Ok(key_value.New())
 }
 
 // onClose received a close signal for this service
@@ -149,6 +542,35 @@ func (m *Manager) onClose(req message.RequestInterface) message.ReplyInterface {
 	return req.Ok(key_value.New())
 }
 
+// idempotencyKey returns the idempotency key of the request, or an empty string if it's not set.
+func idempotencyKey(req message.RequestInterface) string {
+	key, err := req.RouteParameters().StringValue(idempotency.Param)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// The idempotent wraps the mutating route.
+// The retried request with the same idempotency key is applied once, and receives the kept reply.
+func (m *Manager) idempotent(handle func(message.RequestInterface) message.ReplyInterface) func(message.RequestInterface) message.ReplyInterface {
+	return idempotency.Route(m.replies, idempotencyKey, func(reply message.ReplyInterface) bool {
+		return reply.IsOK()
+	}, handle)
+}
+
+// The validated wraps the route, so it's called only if the route parameters match the schema.
+// Otherwise, fails with all violations, kept in the schema.ViolationsParam of the reply too.
+func validated(routeSchema *schema.Schema, handle func(message.RequestInterface) message.ReplyInterface) func(message.RequestInterface) message.ReplyInterface {
+	return schema.Route(routeSchema, func(req message.RequestInterface) map[string]interface{} {
+		return req.RouteParameters().Map()
+	}, func(req message.RequestInterface, violations schema.Violations) message.ReplyInterface {
+		reply := req.Fail(violations.Error())
+		reply.ReplyParameters().Set(schema.ViolationsParam, violations)
+		return reply
+	}, handle)
+}
+
 // onHeartbeat simple handler to check that service is alive
 func (m *Manager) onHeartbeat(req message.RequestInterface) message.ReplyInterface {
 	return req.Ok(key_value.New())
@@ -193,7 +615,10 @@ func (m *Manager) onUnits(req message.RequestInterface) message.ReplyInterface {
 		return req.Fail(fmt.Sprintf("proxyClient.Units: %v", err))
 	}
 
-	params := key_value.New().Set("units", units)
+	params, err := replycache.Respond(req.RouteParameters().Map(), key_value.New().Set("units", units).Map(), CacheTtl, ConfigChangedTopic)
+	if err != nil {
+		return req.Fail(fmt.Sprintf("replycache.Respond: %v", err))
+	}
 	return req.Ok(params)
 }
 
@@ -225,6 +650,10 @@ func (m *Manager) onProxyConfigSet(req message.RequestInterface) message.ReplyIn
 	}
 
 	proxyId := sourceService.Id
+	if m.acl != nil && !m.acl.Allowed(proxyId, m.serviceId) {
+		return req.Fail(fmt.Sprintf("the '%s' namespace is not allowed to connect to the '%s' namespace",
+			namespace.Of(proxyId), namespace.Of(m.serviceId)))
+	}
 
 	proxyClient := m.ctx.ProxyClient()
 	proxyChains, err := proxyClient.ProxyChainsByLastId(proxyId)
@@ -255,9 +684,10 @@ func (m *Manager) onProxyConfigSet(req message.RequestInterface) message.ReplyIn
 	return req.Ok(params)
 }
 
-// The handlers return the handler configurations
+// The handlers return the handler configurations.
+// The internal handlers are not returned, see SetInternalHandlers.
 func (m *Manager) handlers() ([]*handlerConfig.Handler, error) {
-	handlerConfigs := make([]*handlerConfig.Handler, len(m.handlerManagers))
+	handlerConfigs := make([]*handlerConfig.Handler, 0, len(m.handlerManagers))
 
 	for i := range m.handlerManagers {
 		handlerManager := m.handlerManagers[i]
@@ -265,8 +695,11 @@ func (m *Manager) handlers() ([]*handlerConfig.Handler, error) {
 		if err != nil {
 			return nil, fmt.Errorf("m.handlerManagers[%d]: %w", i, err)
 		}
+		if slices.Contains(m.internalIds, c.Id) {
+			continue
+		}
 
-		handlerConfigs[i] = c
+		handlerConfigs = append(handlerConfigs, c)
 	}
 
 	return handlerConfigs, nil
@@ -288,6 +721,93 @@ func (m *Manager) onHandlers(req message.RequestInterface) message.ReplyInterfac
 	return req.Ok(params)
 }
 
+// onConfig returns the configuration of this service from the config engine.
+func (m *Manager) onConfig(req message.RequestInterface) message.ReplyInterface {
+	serviceConf, err := m.ctx.Config().Service(m.serviceId)
+	if err != nil {
+		return req.Fail(fmt.Sprintf("m.ctx.Config().Service(id='%s'): %v", m.serviceId, err))
+	}
+
+	params := key_value.New().Set("service", serviceConf)
+	return req.Ok(params)
+}
+
+// onCommands returns the commands routed by this manager.
+// The command line interface uses them for the help and completion.
+func (m *Manager) onCommands(req message.RequestInterface) message.ReplyInterface {
+	params := key_value.New().Set("commands", m.RouteCommands())
+	return req.Ok(params)
+}
+
+// onDescribe returns the handler configurations, the commands and the tags of the handlers by their category
+// and the metadata of the versioned routes.
+// The clients use it to find the deprecated routes and their replacements.
+// The parent services use it to verify the extension contract, see Client.HandlerCommands.
+func (m *Manager) onDescribe(req message.RequestInterface) message.ReplyInterface {
+	handlerConfigs, err := m.handlers()
+	if err != nil {
+		return req.Fail(fmt.Sprintf("m.handlers: %v", err))
+	}
+
+	routes := make([]deprecation.Info, 0)
+	if m.routes != nil {
+		routes = m.routes.Routes()
+	}
+
+	commands := make(map[string][]string)
+	if m.routeCommands != nil {
+		commands = m.routeCommands()
+	}
+
+	tags := make(map[string][]string)
+	if m.tags != nil {
+		tags = m.tags.All()
+	}
+
+	params := key_value.New().
+		Set("handler_configs", handlerConfigs).
+		Set("commands", commands).
+		Set("tags", tags).
+		Set("routes", routes)
+	return req.Ok(params)
+}
+
+// onChaos sets the faults injected into the routes.
+// The empty parameters remove the faults.
+func (m *Manager) onChaos(req message.RequestInterface) message.ReplyInterface {
+	var config chaos.Config
+	if err := req.RouteParameters().Interface(&config); err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().Interface: %v", err))
+	}
+	if err := chaos.Set(config); err != nil {
+		return req.Fail(fmt.Sprintf("chaos.Set: %v", err))
+	}
+
+	return req.Ok(key_value.New())
+}
+
+// onTap attaches the tap to the handler category for the duration, or detaches it if the duration is 0.
+// Returns the attached taps.
+func (m *Manager) onTap(req message.RequestInterface) message.ReplyInterface {
+	if m.taps == nil {
+		return req.Fail("the service doesn't forward the taps")
+	}
+
+	var params tap.Params
+	if err := req.RouteParameters().Interface(&params); err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().Interface: %v", err))
+	}
+	if params.Duration == 0 {
+		if err := m.taps.Detach(params.Category, params.Endpoint); err != nil {
+			return req.Fail(fmt.Sprintf("taps.Detach: %v", err))
+		}
+	} else if _, err := m.taps.Attach(params.Category, params.Endpoint, params.Duration); err != nil {
+		return req.Fail(fmt.Sprintf("taps.Attach: %v", err))
+	}
+
+	return req.Ok(key_value.New().Set("taps", m.taps.Taps()))
+}
+
 // onHandlersByCategory returns configuration of the handlers in this service.
 //
 // If this service is a destination, then the proxy will call this function.
@@ -307,7 +827,10 @@ func (m *Manager) onHandlersByCategory(req message.RequestInterface) message.Rep
 
 	filteredConfigs := handlerConfig.ByCategory(handlerConfigs, category)
 
-	params := key_value.New().Set("handler_configs", filteredConfigs)
+	params, err := replycache.Respond(req.RouteParameters().Map(), key_value.New().Set("handler_configs", filteredConfigs).Map(), CacheTtl, ConfigChangedTopic)
+	if err != nil {
+		return req.Fail(fmt.Sprintf("replycache.Respond: %v", err))
+	}
 	return req.Ok(params)
 }
 
@@ -338,16 +861,225 @@ func (m *Manager) onHandlersByRule(req message.RequestInterface) message.ReplyIn
 		return req.Ok(params)
 	}
 
+	// the rule categories may reference the tags, see tag.Ref
 	filteredConfigs := make([]*handlerConfig.Handler, 0, len(handlerConfigs))
-	for i := range rule.Categories {
-		category := rule.Categories[i]
-		filteredConfigs = append(filteredConfigs, handlerConfig.ByCategory(handlerConfigs, category)...)
+	for _, c := range handlerConfigs {
+		if m.tags.MatchAny(rule.Categories, c.Category) {
+			filteredConfigs = append(filteredConfigs, c)
+		}
 	}
 
 	params := key_value.New().Set("handler_configs", filteredConfigs)
 	return req.Ok(params)
 }
 
+// onLeadership returns the leadership state of this service instance.
+// If the leader election is not enabled, then the instance is always a leader.
+func (m *Manager) onLeadership(req message.RequestInterface) message.ReplyInterface {
+	params := key_value.New()
+	if m.elector == nil {
+		params.Set("elected", false).Set("leader", true)
+	} else {
+		params.Set("elected", true).Set("leader", m.elector.IsLeader())
+	}
+
+	return req.Ok(params)
+}
+
+// onCatchUp returns the broadcasts of the topic by the sequence range.
+// The subscribers that were offline call it to receive the missed broadcasts.
+// The protected topics are returned only if the caller's CURVE identity may subscribe to them, see broadcast.ACL.
+func (m *Manager) onCatchUp(req message.RequestInterface) message.ReplyInterface {
+	if m.feed == nil {
+		return req.Fail("the service has no broadcast feed")
+	}
+
+	topic, err := req.RouteParameters().StringValue("topic")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('topic'): %v", err))
+	}
+	// the journaled broadcasts of the protected topic are returned only to the allowed subscribers
+	if err := m.feed.CanSubscribe(req.PublicKey(), topic); err != nil {
+		return req.Fail(fmt.Sprintf("feed.CanSubscribe: %v", err))
+	}
+	from, err := req.RouteParameters().Uint64Value("from")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().Uint64Value('from'): %v", err))
+	}
+	to, err := req.RouteParameters().Uint64Value("to")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().Uint64Value('to'): %v", err))
+	}
+
+	broadcasts, err := m.feed.Range(topic, from, to)
+	if err != nil {
+		return req.Fail(fmt.Sprintf("feed.Range('%s', %d, %d): %v", topic, from, to, err))
+	}
+
+	params := key_value.New().Set("broadcasts", broadcasts)
+	return req.Ok(params)
+}
+
+// onReplay returns the persisted broadcasts of the topic by the sequence range.
+// The new subscribers call it to rebuild their state.
+// At most 'limit' broadcasts are returned, ReplayLimit by default,
+// the subscriber requests the rest from the sequence number after the last one.
+// The protected topics are authorized like in onCatchUp.
+func (m *Manager) onReplay(req message.RequestInterface) message.ReplyInterface {
+	if m.feed == nil {
+		return req.Fail("the service has no broadcast feed")
+	}
+
+	topic, err := req.RouteParameters().StringValue("topic")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('topic'): %v", err))
+	}
+	// the journaled broadcasts of the protected topic are returned only to the allowed subscribers
+	if err := m.feed.CanSubscribe(req.PublicKey(), topic); err != nil {
+		return req.Fail(fmt.Sprintf("feed.CanSubscribe: %v", err))
+	}
+	from, err := req.RouteParameters().Uint64Value("from")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().Uint64Value('from'): %v", err))
+	}
+	to, err := req.RouteParameters().Uint64Value("to")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().Uint64Value('to'): %v", err))
+	}
+
+	limit := uint64(ReplayLimit)
+	if req.RouteParameters().Exist("limit") {
+		limit, err = req.RouteParameters().Uint64Value("limit")
+		if err != nil {
+			return req.Fail(fmt.Sprintf("req.RouteParameters().Uint64Value('limit'): %v", err))
+		}
+	}
+
+	broadcasts, err := m.feed.Replay(topic, from, to, int(limit))
+	if err != nil {
+		return req.Fail(fmt.Sprintf("feed.Replay('%s', %d, %d, %d): %v", topic, from, to, limit, err))
+	}
+
+	params := key_value.New().Set("broadcasts", broadcasts)
+	return req.Ok(params)
+}
+
+// The handlerStatuses polls the handler managers for the states of their parts,
+// such as the frontend and the instance manager.
+// The handlers are polled in parallel, the handler not replied within the StatusTimeout is reported as unreachable.
+// The handler that didn't reply to the previous poll yet is not polled again.
+// The unreachable handler is reported with the error instead of failing the whole status.
+func (m *Manager) handlerStatuses() map[string]interface{} {
+	m.mu.RLock()
+	handlerManagers := make([]manager_client.Interface, len(m.handlerManagers))
+	copy(handlerManagers, m.handlerManagers)
+	m.mu.RUnlock()
+
+	type polled struct {
+		id     string
+		status map[string]interface{}
+	}
+	results := make(chan polled, len(handlerManagers))
+	for _, handlerManager := range handlerManagers {
+		go func(handlerManager manager_client.Interface) {
+			results <- polled{id: handlerManager.Id(), status: m.handlerStatus(handlerManager)}
+		}(handlerManager)
+	}
+
+	statuses := make(map[string]interface{}, len(handlerManagers))
+	deadline := time.After(StatusTimeout)
+	for range handlerManagers {
+		select {
+		case result := <-results:
+			statuses[result.id] = result.status
+		case <-deadline:
+			for _, handlerManager := range handlerManagers {
+				if _, ok := statuses[handlerManager.Id()]; !ok {
+					statuses[handlerManager.Id()] = unreachable(fmt.Errorf("no reply within %s", StatusTimeout))
+				}
+			}
+			return statuses
+		}
+	}
+
+	return statuses
+}
+
+// The handlerStatus polls the handler manager for the states of its parts.
+// If the previous poll of the handler is in progress, the handler is reported as unreachable without polling.
+func (m *Manager) handlerStatus(handlerManager manager_client.Interface) map[string]interface{} {
+	id := handlerManager.Id()
+	m.pollingMu.Lock()
+	if m.polling[id] {
+		m.pollingMu.Unlock()
+		return unreachable(fmt.Errorf("the previous poll is in progress"))
+	}
+	m.polling[id] = true
+	m.pollingMu.Unlock()
+
+	parts, states, err := handlerManager.Parts()
+
+	m.pollingMu.Lock()
+	delete(m.polling, id)
+	m.pollingMu.Unlock()
+
+	if err != nil {
+		return unreachable(err)
+	}
+	partStates := make(map[string]string, len(parts))
+	for i := range parts {
+		if i < len(states) {
+			partStates[parts[i]] = states[i]
+		}
+	}
+	return map[string]interface{}{
+		"reachable": true,
+		"parts":     partStates,
+	}
+}
+
+// unreachable returns the status of the handler that didn't reply
+func unreachable(err error) map[string]interface{} {
+	return map[string]interface{}{
+		"reachable": false,
+		"error":     err.Error(),
+	}
+}
+
+// onStatus returns the state of the service.
+// The pid lets the parent apply the resource limits to this process.
+// The part states of each handler are included by their id.
+// The socket metrics are included if the monitor is set.
+// The resource violations of the dependencies are included if the enforcer is set.
+// The failed optional extensions are included as the degraded state.
+func (m *Manager) onStatus(req message.RequestInterface) message.ReplyInterface {
+	params := key_value.New().
+		Set("id", m.serviceId).
+		Set("url", m.serviceUrl).
+		Set("running", m.running).
+		Set("pid", os.Getpid()).
+		Set("handlers", m.handlerStatuses())
+
+	if m.monitor != nil {
+		params.Set("sockets", m.monitor.Metrics())
+	}
+	if m.enforcer != nil {
+		params.Set("resource_violations", m.enforcer.Violations())
+	}
+	if m.degraded != nil {
+		degraded := m.degraded()
+		params.Set("degraded", len(degraded) > 0).Set("degraded_extensions", degraded)
+	}
+	if m.slo != nil {
+		params.Set("slo", m.slo.Status())
+	}
+	if m.flags != nil {
+		params.Set("flags", m.flags.All())
+	}
+
+	return req.Ok(params)
+}
+
 // HandlerConfig converts the client into the handler configuration
 func HandlerConfig(client *clientConfig.Client) *handlerConfig.Handler {
 	return &handlerConfig.Handler{
@@ -359,8 +1091,128 @@ func HandlerConfig(client *clientConfig.Client) *handlerConfig.Handler {
 	}
 }
 
+// SetHandlerManagers adds the clients of the handlers closed by the manager.
+// The clients of the handlers added already are skipped, so each handler is closed once.
 func (m *Manager) SetHandlerManagers(clients []manager_client.Interface) {
-	m.handlerManagers = append(m.handlerManagers, clients...)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, client := range clients {
+		if slices.ContainsFunc(m.handlerManagers, func(added manager_client.Interface) bool {
+			return added.Id() == client.Id()
+		}) {
+			continue
+		}
+		m.handlerManagers = append(m.handlerManagers, client)
+	}
+}
+
+// RemoveHandlerManager removes the client of the handler closed outside the manager.
+// If the handler starts again, its client is added by SetHandlerManagers.
+func (m *Manager) RemoveHandlerManager(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlerManagers = slices.DeleteFunc(m.handlerManagers, func(client manager_client.Interface) bool {
+		return client.Id() == id
+	})
+}
+
+// SetElector sets the leader elector to expose the leadership state.
+func (m *Manager) SetElector(elector ha.Elector) {
+	m.elector = elector
+}
+
+// SetFeed sets the broadcast feed to serve the catch-up requests.
+func (m *Manager) SetFeed(feed *broadcast.Feed) {
+	m.feed = feed
+}
+
+// SetMonitor sets the socket monitor to expose the socket metrics by the Status command.
+func (m *Manager) SetMonitor(socketMonitor *monitor.Monitor) {
+	m.monitor = socketMonitor
+}
+
+// SetEnforcer sets the resource limits enforcer to expose the violations by the Status command.
+func (m *Manager) SetEnforcer(enforcer *limits.Enforcer) {
+	m.enforcer = enforcer
+}
+
+// SetRoutes sets the registry of the versioned routes to expose them by the Describe command.
+func (m *Manager) SetRoutes(routes *deprecation.Registry) {
+	m.routes = routes
+}
+
+// SetACL sets the namespaces allowed to set this service as the proxy destination.
+func (m *Manager) SetACL(acl *namespace.ACL) {
+	m.acl = acl
+}
+
+// SetInternalHandlers sets the ids of the internal handlers.
+// They are never returned to the other services, neither their configurations nor their commands.
+func (m *Manager) SetInternalHandlers(ids []string) {
+	m.internalIds = ids
+}
+
+// SetShutdownPriorities sets the shutdown priorities by the handler, proxy or extension id.
+// The lower priority stops first, the default priority is 0.
+// The parts with the same priority stop in the order they were added.
+func (m *Manager) SetShutdownPriorities(priorities map[string]int) {
+	m.priorities = priorities
+}
+
+// The priority returns the shutdown priority of the id
+func (m *Manager) priority(id string) int {
+	return m.priorities[id]
+}
+
+// SetSnapshots sets the functions taking and restoring the snapshots of the service state
+func (m *Manager) SetSnapshots(snapshot func(path string) error, restore func(path string) error) {
+	m.snapshot = snapshot
+	m.restore = restore
+}
+
+// SetSlo sets the objectives of the routes returned by the Status command
+func (m *Manager) SetSlo(tracker *slo.Tracker) {
+	m.slo = tracker
+}
+
+// SetFlags sets the feature flags toggled by the flag command and returned by the Status command
+func (m *Manager) SetFlags(flags *featureflag.Registry) {
+	m.flags = flags
+}
+
+// SetQuotas sets the usage of the source services returned by the usage command
+func (m *Manager) SetQuotas(quotas *quota.Accountant) {
+	m.quotas = quotas
+}
+
+// SetTaps sets the taps attached by the tap command
+func (m *Manager) SetTaps(taps *tap.Registry) {
+	m.taps = taps
+}
+
+// SetTags sets the tags of the handler categories.
+// The rules referencing the tags are resolved by them, and the tags are returned by the Describe command.
+func (m *Manager) SetTags(tags *tag.Registry) {
+	m.tags = tags
+}
+
+// SetDegraded sets the function returning the failed optional extensions to expose them by the Status command.
+func (m *Manager) SetDegraded(degraded func() map[string]string) {
+	m.degraded = degraded
+}
+
+// SetRouteCommands sets the function returning the commands of the handlers by their category.
+func (m *Manager) SetRouteCommands(routeCommands func() map[string][]string) {
+	m.routeCommands = routeCommands
+}
+
+// SetStopHooks sets the functions called before and after closing the service.
+// The before hook can abort the closing by returning an error.
+func (m *Manager) SetStopHooks(before func() error, after func() error) {
+	m.beforeClose = before
+	m.afterClose = after
 }
 
 func (m *Manager) SetDeps(configs []*clientConfig.Client) {
@@ -392,10 +1244,84 @@ func (m *Manager) Start() error {
 	if err := m.Route(HandlersByRule, m.onHandlersByRule); err != nil {
 		return fmt.Errorf(`handler.Route("%s"): %w`, HandlersByRule, err)
 	}
-	if err := m.Route(ProxyConfigSet, m.onProxyConfigSet); err != nil {
+	if err := m.Route(ProxyConfigSet, m.idempotent(m.onProxyConfigSet)); err != nil {
 		return fmt.Errorf(`handler.Route("%s"): %w`, ProxyConfigSet, err)
 	}
 
+	if err := m.Route(Leadership, m.onLeadership); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Leadership, err)
+	}
+
+	if err := m.Route(CatchUp, validated(rangeSchema, m.onCatchUp)); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, CatchUp, err)
+	}
+
+	if err := m.Route(Replay, validated(replaySchema, m.onReplay)); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Replay, err)
+	}
+
+	if err := m.Route(Status, m.onStatus); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Status, err)
+	}
+
+	if err := m.Route(ShuttingDown, m.onShuttingDown); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, ShuttingDown, err)
+	}
+
+	if err := m.Route(StartHandler, m.onStartHandler); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, StartHandler, err)
+	}
+
+	if err := m.Route(Config, m.onConfig); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Config, err)
+	}
+
+	if err := m.Route(Commands, m.onCommands); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Commands, err)
+	}
+
+	if err := m.Route(Describe, m.onDescribe); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Describe, err)
+	}
+
+	if err := m.Route(ExplainRoute, m.onExplainRoute); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, ExplainRoute, err)
+	}
+
+	if err := m.Route(Tap, m.onTap); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Tap, err)
+	}
+
+	if err := m.Route(Routes, m.onRoutes); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Routes, err)
+	}
+
+	if err := m.Route(Usage, m.onUsage); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Usage, err)
+	}
+
+	if err := m.Route(Flag, m.onFlag); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Flag, err)
+	}
+
+	if err := m.Route(Handoff, m.onHandoff); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Handoff, err)
+	}
+
+	if err := m.Route(Snapshot, m.onSnapshot); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Snapshot, err)
+	}
+
+	if err := m.Route(Restore, m.onRestore); err != nil {
+		return fmt.Errorf(`handler.Route("%s"): %w`, Restore, err)
+	}
+
+	if chaos.Enabled {
+		if err := m.Route(Chaos, m.onChaos); err != nil {
+			return fmt.Errorf(`handler.Route("%s"): %w`, Chaos, err)
+		}
+	}
+
 	if err := m.Interface.Start(); err != nil {
 		return fmt.Errorf("handler.Start: %w", err)
 	}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"slices"
+)
+
+// RouteList is the routes of the running handler compared to the configuration
+type RouteList struct {
+	Category   string   `json:"category"`
+	Commands   []string `json:"commands"`   // registered in the running handler
+	Configured []string `json:"configured"` // referenced by the route rules of the proxy chains
+	Missing    []string `json:"missing"`    // configured, but not registered in the running handler
+}
+
+// The configuredCommands returns the commands of the route rules targeting the category of this service
+func (m *Manager) configuredCommands(proxyChains []*serviceConfig.ProxyChain, category string) []string {
+	configured := make([]string, 0)
+	for _, proxyChain := range proxyChains {
+		rule := proxyChain.Destination
+		if rule == nil || !rule.IsRoute() || !slices.Contains(rule.Urls, m.serviceUrl) {
+			continue
+		}
+		if !m.tags.MatchAny(rule.Categories, category) {
+			continue
+		}
+		for _, command := range rule.Commands {
+			if !slices.Contains(rule.ExcludedCommands, command) && !slices.Contains(configured, command) {
+				configured = append(configured, command)
+			}
+		}
+	}
+	slices.Sort(configured)
+	return configured
+}
+
+// onRoutes returns the commands registered in the running handler of the category.
+// The commands configured by the route rules, but not registered, are the drift between the configuration and the code.
+func (m *Manager) onRoutes(req message.RequestInterface) message.ReplyInterface {
+	category, err := req.RouteParameters().StringValue("category")
+	if err != nil {
+		return req.Fail(fmt.Sprintf("req.RouteParameters().StringValue('category'): %v", err))
+	}
+	if m.routeCommands == nil {
+		return req.Fail("the service doesn't expose the routes")
+	}
+
+	commands, ok := m.routeCommands()[category]
+	if !ok {
+		return req.Fail(fmt.Sprintf("no '%s' handler", category))
+	}
+	commands = slices.Clone(commands)
+	slices.Sort(commands)
+
+	proxyChains, err := m.ctx.ProxyClient().ProxyChains()
+	if err != nil {
+		return req.Fail(fmt.Sprintf("proxyClient.ProxyChains: %v", err))
+	}
+
+	routes := RouteList{
+		Category:   category,
+		Commands:   commands,
+		Configured: m.configuredCommands(proxyChains, category),
+		Missing:    make([]string, 0),
+	}
+	for _, command := range routes.Configured {
+		if !slices.Contains(commands, command) {
+			routes.Missing = append(routes.Missing, command)
+		}
+	}
+
+	return req.Ok(key_value.New().Set("routes", routes))
+}
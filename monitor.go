@@ -0,0 +1,66 @@
+package service
+
+import (
+	"fmt"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/service-lib/transport"
+)
+
+// probeKind returns the kind of the probe socket compatible with the handler type.
+// Returns false if the handler can't be probed.
+func probeKind(handlerType handlerConfig.HandlerType) (transport.Kind, bool) {
+	switch handlerType {
+	case handlerConfig.SyncReplierType, handlerConfig.ReplierType:
+		return transport.Req, true
+	case handlerConfig.PublisherType:
+		return transport.Sub, true
+	default:
+		return "", false
+	}
+}
+
+// The watchEndpoint tracks the connection health of the handler at the url by the monitor, see SetMonitor.
+// Without the monitor, it does nothing.
+// The failure is logged, as the service runs without the monitoring.
+func (independent *Service) watchEndpoint(name string, handlerType handlerConfig.HandlerType, url string) {
+	if independent.monitor == nil {
+		return
+	}
+	kind, ok := probeKind(handlerType)
+	if !ok {
+		return
+	}
+	if err := independent.monitor.WatchEndpoint(name, kind, url); err != nil {
+		independent.Logger.Warn("monitor.WatchEndpoint", "name", name, "url", url, "error", err)
+	}
+}
+
+// The unwatchEndpoint stops tracking the endpoint of the closed client
+func (independent *Service) unwatchEndpoint(name string) {
+	if independent.monitor == nil {
+		return
+	}
+	_ = independent.monitor.Unwatch(name)
+}
+
+// The watchHandlers tracks the endpoints of the started handlers
+func (independent *Service) watchHandlers() {
+	if independent.monitor == nil {
+		return
+	}
+	for _, handler := range independent.startedHandlers() {
+		c := handler.Config()
+		independent.watchEndpoint("handler:"+c.Category, c.Type, handlerConfig.ExternalUrl(c.Id, c.Port))
+	}
+}
+
+// The closeMonitor closes the running monitor
+func (independent *Service) closeMonitor() error {
+	if independent.monitor == nil || !independent.monitor.Running() {
+		return nil
+	}
+	if err := independent.monitor.Close(); err != nil {
+		return fmt.Errorf("monitor.Close: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// Package monitor tracks the connection health of the sockets.
+//
+// The Monitor listens to the socket events (connected, disconnected, retried),
+// writes them as structured log entries and counts them per socket.
+// The sockets must be transport.Monitorable, and created by the monitor's transport.
+// The sockets of the other libraries, such as the handlers and their clients, are not reachable,
+// so their endpoints are watched by the probe sockets connected to them, see WatchEndpoint.
+// The metrics are surfaced by the manager's Status command.
+package monitor
+
+import (
+	"fmt"
+	"github.com/ahmetson/log-lib"
+	"github.com/ahmetson/service-lib/reactor"
+	"github.com/ahmetson/service-lib/transport"
+	"sync"
+	"time"
+)
+
+// Metric is the connection health of a socket
+type Metric struct {
+	Connected    uint64    `json:"connected"`
+	Disconnected uint64    `json:"disconnected"`
+	Retried      uint64    `json:"retried"`
+	Accepted     uint64    `json:"accepted"`
+	Failed       uint64    `json:"failed"` // bind, accept or close failures
+	LastEvent    string    `json:"last_event"`
+	LastAddress  string    `json:"last_address"`
+	LastTime     time.Time `json:"last_time"`
+}
+
+// Monitor reads the events of the watched sockets
+type Monitor struct {
+	logger    *log.Logger
+	transport transport.Transport
+	reactor   *reactor.Reactor
+	metrics   map[string]*Metric
+	watchers  map[string]transport.Socket // the event sockets by the socket name
+	probes    map[string]transport.Socket // the sockets connected by WatchEndpoint by the name
+	mu        sync.Mutex
+}
+
+// New returns a monitor that writes the events into the logger.
+// The logger is optional.
+// If the transport is nil, then transport.Default is used.
+func New(logger *log.Logger, t transport.Transport) *Monitor {
+	if t == nil {
+		t = transport.Default()
+	}
+	return &Monitor{
+		logger:    logger,
+		transport: t,
+		reactor:   reactor.New(t),
+		metrics:   make(map[string]*Metric),
+		watchers:  make(map[string]transport.Socket),
+		probes:    make(map[string]transport.Socket),
+	}
+}
+
+// Watch starts tracking the events of the socket under the name.
+// If the socket with the same name is watched already, then it's replaced.
+// Use it for the sockets that are re-created on reconnection.
+func (m *Monitor) Watch(name string, socket transport.Socket) error {
+	monitorable, ok := socket.(transport.Monitorable)
+	if !ok {
+		return fmt.Errorf("the '%s' socket is not monitorable", name)
+	}
+
+	m.mu.Lock()
+	old, replaced := m.watchers[name]
+	if _, ok := m.metrics[name]; !ok {
+		m.metrics[name] = &Metric{}
+	}
+	m.mu.Unlock()
+
+	if replaced {
+		if err := m.unwatch(name, old); err != nil {
+			return fmt.Errorf("m.unwatch('%s'): %w", name, err)
+		}
+	}
+
+	events, err := monitorable.Monitor()
+	if err != nil {
+		return fmt.Errorf("socket.Monitor: %w", err)
+	}
+
+	err = m.reactor.AddSocket(events, func(events transport.Socket) error {
+		return m.onEvent(name, events)
+	})
+	if err != nil {
+		_ = events.Close()
+		return fmt.Errorf("reactor.AddSocket: %w", err)
+	}
+
+	m.mu.Lock()
+	m.watchers[name] = events
+	m.mu.Unlock()
+
+	return nil
+}
+
+// WatchEndpoint connects the probe socket of the kind to the url, and tracks its events under the name.
+// The probe never sends, it only tells if the endpoint is reachable.
+// The kind must be compatible with the socket bound to the url, for example transport.Req for the repliers.
+// If the endpoint with the same name is watched already, then it's replaced.
+func (m *Monitor) WatchEndpoint(name string, kind transport.Kind, url string) error {
+	probe, err := m.transport.Dial(kind, url)
+	if err != nil {
+		return fmt.Errorf("transport.Dial('%s'): %w", url, err)
+	}
+	if err := m.Watch(name, probe); err != nil {
+		_ = probe.Close()
+		return fmt.Errorf("m.Watch('%s'): %w", name, err)
+	}
+
+	m.mu.Lock()
+	old, replaced := m.probes[name]
+	m.probes[name] = probe
+	m.mu.Unlock()
+
+	if replaced {
+		_ = old.Close()
+	}
+	return nil
+}
+
+// Unwatch stops tracking the events of the socket or the endpoint.
+// The probe of the endpoint is closed, the watched socket is not.
+// The metrics of the name are kept.
+func (m *Monitor) Unwatch(name string) error {
+	m.mu.Lock()
+	events, watched := m.watchers[name]
+	probe, probed := m.probes[name]
+	delete(m.probes, name)
+	m.mu.Unlock()
+
+	if !watched {
+		return fmt.Errorf("the '%s' socket is not watched", name)
+	}
+	if err := m.unwatch(name, events); err != nil {
+		return fmt.Errorf("m.unwatch: %w", err)
+	}
+	if probed {
+		if err := probe.Close(); err != nil {
+			return fmt.Errorf("probe.Close: %w", err)
+		}
+	}
+	return nil
+}
+
+// unwatch stops receiving the events by the event socket
+func (m *Monitor) unwatch(name string, events transport.Socket) error {
+	if err := m.reactor.RemoveSocket(events); err != nil {
+		return fmt.Errorf("reactor.RemoveSocket: %w", err)
+	}
+	if err := events.Close(); err != nil {
+		return fmt.Errorf("events.Close: %w", err)
+	}
+
+	m.mu.Lock()
+	delete(m.watchers, name)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// The onEvent updates the metric of the socket by the received event
+func (m *Monitor) onEvent(name string, events transport.Socket) error {
+	frames, err := events.Recv()
+	if err == nil && len(frames) != 2 {
+		err = fmt.Errorf("the event has %d frames, expected [event, address]", len(frames))
+	}
+	if err != nil {
+		if m.logger != nil {
+			m.logger.Warn("monitor failed to receive the event", "socket", name, "error", err)
+		}
+		return nil
+	}
+
+	event, address := string(frames[0]), string(frames[1])
+
+	m.mu.Lock()
+	metric := m.metrics[name]
+	switch event {
+	case transport.Connected:
+		metric.Connected++
+	case transport.Disconnected:
+		metric.Disconnected++
+	case transport.Retried:
+		metric.Retried++
+	case transport.Accepted:
+		metric.Accepted++
+	case transport.Failed:
+		metric.Failed++
+	}
+	metric.LastEvent = event
+	metric.LastAddress = address
+	metric.LastTime = time.Now()
+	m.mu.Unlock()
+
+	if m.logger == nil {
+		return nil
+	}
+	switch event {
+	case transport.Disconnected, transport.Retried:
+		m.logger.Warn("socket event", "socket", name, "event", event, "address", address)
+	case transport.Failed:
+		m.logger.Error("socket event", "socket", name, "event", event, "address", address)
+	default:
+		m.logger.Info("socket event", "socket", name, "event", event, "address", address)
+	}
+
+	return nil
+}
+
+// Metrics returns the copy of the metrics by the socket name
+func (m *Monitor) Metrics() map[string]Metric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := make(map[string]Metric, len(m.metrics))
+	for name, metric := range m.metrics {
+		metrics[name] = *metric
+	}
+	return metrics
+}
+
+// Start reading the events in the background
+func (m *Monitor) Start() error {
+	if _, err := m.reactor.Run(); err != nil {
+		return fmt.Errorf("reactor.Run: %w", err)
+	}
+	return nil
+}
+
+// Running returns true if the monitor reads the events
+func (m *Monitor) Running() bool {
+	return m.reactor.Running()
+}
+
+// Close stops reading the events and closes the event sockets and the probes
+func (m *Monitor) Close() error {
+	if err := m.reactor.Close(); err != nil {
+		return fmt.Errorf("reactor.Close: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, events := range m.watchers {
+		if err := events.Close(); err != nil {
+			return fmt.Errorf("watchers['%s'].Close: %w", name, err)
+		}
+		delete(m.watchers, name)
+	}
+	for name, probe := range m.probes {
+		if err := probe.Close(); err != nil {
+			return fmt.Errorf("probes['%s'].Close: %w", name, err)
+		}
+		delete(m.probes, name)
+	}
+
+	return nil
+}
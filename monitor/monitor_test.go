@@ -0,0 +1,114 @@
+package monitor
+
+import (
+	"github.com/ahmetson/service-lib/transport"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestMonitorSuite struct {
+	suite.Suite
+
+	transport transport.Transport
+	monitor   *Monitor
+}
+
+func (test *TestMonitorSuite) SetupTest() {
+	s := test.Require
+
+	t, err := transport.Get("mem")
+	s().NoError(err)
+	test.transport = t
+	test.monitor = New(nil, t)
+	s().NoError(test.monitor.Start())
+	s().True(test.monitor.Running())
+}
+
+func (test *TestMonitorSuite) TearDownTest() {
+	if test.monitor.Running() {
+		test.Require().NoError(test.monitor.Close())
+	}
+}
+
+// metric returns the metric of the socket
+func (test *TestMonitorSuite) metric(name string) Metric {
+	return test.monitor.Metrics()[name]
+}
+
+// Test_10_Watch tests the events of the watched socket
+func (test *TestMonitorSuite) Test_10_Watch() {
+	s := test.Require
+
+	bound, err := test.transport.Bind(transport.Rep, "mem://monitor_watch")
+	s().NoError(err)
+	s().NoError(test.monitor.Watch("handler", bound))
+
+	// the socket without the events
+	s().Error(test.monitor.Watch("unknown", struct{ transport.Socket }{}))
+
+	client, err := test.transport.Dial(transport.Req, "mem://monitor_watch")
+	s().NoError(err)
+	s().NoError(test.monitor.Watch("client", client))
+
+	s().Eventually(func() bool {
+		return test.metric("handler").Accepted == 1 && test.metric("client").Connected == 1
+	}, time.Second, time.Millisecond)
+	s().Equal(transport.Connected, test.metric("client").LastEvent)
+	s().Equal("mem://monitor_watch", test.metric("client").LastAddress)
+
+	s().NoError(client.Close())
+	s().Eventually(func() bool {
+		return test.metric("handler").Disconnected == 1
+	}, time.Second, time.Millisecond)
+
+	// the watched socket is not closed by unwatching
+	s().NoError(test.monitor.Unwatch("handler"))
+	s().Error(test.monitor.Unwatch("handler"))
+	s().Equal(uint64(1), test.metric("handler").Accepted)
+	s().NoError(bound.Close())
+}
+
+// Test_11_WatchEndpoint tests the events of the endpoint reached by the probe
+func (test *TestMonitorSuite) Test_11_WatchEndpoint() {
+	s := test.Require
+
+	s().Error(test.monitor.WatchEndpoint("handler", transport.Req, "mem://monitor_endpoint"))
+
+	bound, err := test.transport.Bind(transport.Rep, "mem://monitor_endpoint")
+	s().NoError(err)
+
+	s().NoError(test.monitor.WatchEndpoint("handler", transport.Req, "mem://monitor_endpoint"))
+	s().Eventually(func() bool {
+		return test.metric("handler").Connected == 1
+	}, time.Second, time.Millisecond)
+
+	// the endpoint that went down is reported by the probe
+	s().NoError(bound.Close())
+	s().Eventually(func() bool {
+		return test.metric("handler").Disconnected == 1
+	}, time.Second, time.Millisecond)
+	s().Equal(transport.Disconnected, test.metric("handler").LastEvent)
+
+	// the probe is replaced when the endpoint is back
+	bound, err = test.transport.Bind(transport.Rep, "mem://monitor_endpoint")
+	s().NoError(err)
+	s().NoError(test.monitor.WatchEndpoint("handler", transport.Req, "mem://monitor_endpoint"))
+	s().Eventually(func() bool {
+		return test.metric("handler").Connected == 2
+	}, time.Second, time.Millisecond)
+
+	// closing the monitor closes the probe
+	s().NoError(test.monitor.Close())
+	s().False(test.monitor.Running())
+	s().NoError(bound.Close())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestMonitor(t *testing.T) {
+	suite.Run(t, new(TestMonitorSuite))
+}
@@ -0,0 +1,106 @@
+// Package namespace isolates the tenants sharing one orchestra.
+//
+// The namespace is a suffix of the service id and url, so the configurations
+// and the proxy rules of the tenants never collide:
+//
+//	main#tenant-a
+//	github.com/ahmetson/web#tenant-a
+//
+// The Separator never appears in the module paths, so the urls with the versions,
+// like github.com/ahmetson/web@v1.2.0, are not mistaken for the qualified names.
+//
+// The services of different namespaces can not be connected unless the ACL allows it.
+package namespace
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Separator between the name and the namespace.
+// The module paths and the versions never have it, unlike "@" of module@version.
+const Separator = "#"
+
+// Default namespace is empty, the names are not qualified
+const Default = ""
+
+// Validate returns an error if the namespace can not be used.
+func Validate(namespace string) error {
+	if strings.Contains(namespace, Separator) {
+		return fmt.Errorf("namespace '%s' must not contain '%s'", namespace, Separator)
+	}
+	if strings.TrimSpace(namespace) != namespace {
+		return fmt.Errorf("namespace '%s' must not have the spaces around", namespace)
+	}
+	return nil
+}
+
+// Qualify adds the namespace to the name.
+// The name is returned as is for the Default namespace, or if it's qualified already.
+func Qualify(namespace, name string) string {
+	if namespace == Default || Of(name) == namespace {
+		return name
+	}
+	return name + Separator + namespace
+}
+
+// Split returns the name and the namespace of the qualified name.
+func Split(qualified string) (string, string) {
+	i := strings.LastIndex(qualified, Separator)
+	if i == -1 {
+		return qualified, Default
+	}
+	return qualified[:i], qualified[i+len(Separator):]
+}
+
+// Of returns the namespace of the qualified name
+func Of(qualified string) string {
+	_, namespace := Split(qualified)
+	return namespace
+}
+
+// ACL keeps the namespaces allowed to connect to the other namespaces.
+// The services of the same namespace are always allowed.
+type ACL struct {
+	allowed map[string]map[string]bool // the target namespace => source namespaces
+	mu      sync.RWMutex
+}
+
+// NewACL returns the ACL that denies the connections between the namespaces
+func NewACL() *ACL {
+	return &ACL{allowed: make(map[string]map[string]bool)}
+}
+
+// Allow the services of the source namespace to connect to the services of the target namespace
+func (acl *ACL) Allow(source, target string) {
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	if acl.allowed[target] == nil {
+		acl.allowed[target] = make(map[string]bool)
+	}
+	acl.allowed[target][source] = true
+}
+
+// Revoke the access given by Allow
+func (acl *ACL) Revoke(source, target string) {
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	delete(acl.allowed[target], source)
+}
+
+// Allowed returns true if the source service can connect to the target service.
+// The arguments are the qualified ids or urls.
+func (acl *ACL) Allowed(source, target string) bool {
+	sourceNamespace, targetNamespace := Of(source), Of(target)
+	if sourceNamespace == targetNamespace {
+		return true
+	}
+
+	acl.mu.RLock()
+	defer acl.mu.RUnlock()
+
+	return acl.allowed[targetNamespace][sourceNamespace]
+}
@@ -0,0 +1,66 @@
+package namespace
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestNamespaceSuite struct {
+	suite.Suite
+}
+
+// Test_10_Qualify tests the qualified names
+func (test *TestNamespaceSuite) Test_10_Qualify() {
+	s := test.Require
+
+	s().NoError(Validate("tenant-a"))
+	s().NoError(Validate(Default))
+	s().Error(Validate("tenant#a"))
+	s().Error(Validate(" tenant"))
+
+	s().Equal("main", Qualify(Default, "main"))
+	s().Equal("main#tenant-a", Qualify("tenant-a", "main"))
+	s().Equal("main#tenant-a", Qualify("tenant-a", "main#tenant-a"))
+
+	name, namespace := Split("github.com/ahmetson/web#tenant-a")
+	s().Equal("github.com/ahmetson/web", name)
+	s().Equal("tenant-a", namespace)
+
+	// the version of the module is not the namespace
+	name, namespace = Split("github.com/ahmetson/web@v1.2.0")
+	s().Equal("github.com/ahmetson/web@v1.2.0", name)
+	s().Equal(Default, namespace)
+	s().Equal("github.com/ahmetson/web@v1.2.0#tenant-a", Qualify("tenant-a", "github.com/ahmetson/web@v1.2.0"))
+
+	name, namespace = Split("main")
+	s().Equal("main", name)
+	s().Equal(Default, namespace)
+}
+
+// Test_11_ACL tests the connections between the namespaces
+func (test *TestNamespaceSuite) Test_11_ACL() {
+	s := test.Require
+
+	acl := NewACL()
+	s().True(acl.Allowed("proxy#a", "main#a"))
+	s().True(acl.Allowed("proxy", "main"))
+	s().False(acl.Allowed("proxy#b", "main#a"))
+	s().False(acl.Allowed("proxy", "main#a"))
+
+	acl.Allow("b", "a")
+	s().True(acl.Allowed("proxy#b", "main#a"))
+	// the access is one-directional
+	s().False(acl.Allowed("proxy#a", "main#b"))
+
+	acl.Revoke("b", "a")
+	s().False(acl.Allowed("proxy#b", "main#a"))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestNamespace(t *testing.T) {
+	suite.Run(t, new(TestNamespaceSuite))
+}
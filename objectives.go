@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/service-lib/slo"
+	"time"
+)
+
+// SloViolationTopic is the topic of the broadcast added into the feed
+// when the route starts violating its objective, see SetFeed.
+const SloViolationTopic = "slo_violation"
+
+// SloInterval is how often the objectives of the routes are evaluated
+const SloInterval = time.Second * 10
+
+// SetSlo sets the latency and error-rate objective of the route.
+// The proxies observe the requests they forward to the destination routes.
+// The manager's Status command returns the objectives in the slo section,
+// and the violations are broadcast under the SloViolationTopic.
+func (independent *Service) SetSlo(objective slo.Objective) error {
+	if independent.objectives == nil {
+		independent.objectives = slo.NewTracker()
+	}
+	if err := independent.objectives.Set(objective); err != nil {
+		return fmt.Errorf("objectives.Set: %w", err)
+	}
+	return nil
+}
+
+// The watchSlo evaluates the objectives every SloInterval.
+// The routes that started violating their objectives are logged and added into the feed.
+// It stops when the manager is closed.
+func (independent *Service) watchSlo() {
+	if independent.objectives == nil {
+		return
+	}
+
+	for {
+		time.Sleep(SloInterval)
+
+		if independent.manager == nil || !independent.manager.Running() {
+			return
+		}
+
+		for _, violation := range independent.objectives.Evaluate() {
+			independent.Logger.Warn("slo violation", "route", violation.Objective.Route, "reasons", violation.Reasons)
+			if independent.feed == nil {
+				continue
+			}
+			params, err := key_value.NewFromInterface(violation)
+			if err != nil {
+				independent.Logger.Warn("key_value.NewFromInterface", "error", err)
+				continue
+			}
+			if err := independent.broadcast(SloViolationTopic, params.Map()); err != nil {
+				independent.Logger.Warn("broadcast", "topic", SloViolationTopic, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// Unavailable is the prefix of the replies of the routes depending on a degraded extension
+const Unavailable = "unavailable"
+
+// DegradedRetryInterval is how often the degraded extensions are retried
+const DegradedRetryInterval = time.Second * 5
+
+// SetOptional marks the extensions as optional.
+// If the optional extension fails the handshake, the service starts in the degraded state:
+// the routes depending on it reply with Unavailable, while the extension is retried in the background.
+func (independent *Service) SetOptional(ids ...string) {
+	independent.degradedMu.Lock()
+	defer independent.degradedMu.Unlock()
+
+	if independent.optional == nil {
+		independent.optional = make(map[string]bool, len(ids))
+	}
+	for _, id := range ids {
+		independent.optional[id] = true
+	}
+}
+
+// isOptional returns true if the extension is marked by SetOptional
+func (independent *Service) isOptional(id string) bool {
+	independent.degradedMu.Lock()
+	defer independent.degradedMu.Unlock()
+
+	return independent.optional[id]
+}
+
+// setDegraded marks the optional extension as failed, or recovered if the err is nil
+func (independent *Service) setDegraded(id string, err error) {
+	independent.degradedMu.Lock()
+	defer independent.degradedMu.Unlock()
+
+	if err == nil {
+		delete(independent.degraded, id)
+		return
+	}
+	if independent.degraded == nil {
+		independent.degraded = make(map[string]error)
+	}
+	independent.degraded[id] = err
+}
+
+// Degraded returns the failed optional extensions with their errors.
+// The service is degraded if it's not empty.
+func (independent *Service) Degraded() map[string]string {
+	independent.degradedMu.Lock()
+	defer independent.degradedMu.Unlock()
+
+	degraded := make(map[string]string, len(independent.degraded))
+	for id, err := range independent.degraded {
+		degraded[id] = err.Error()
+	}
+	return degraded
+}
+
+// The unavailable returns an error if any of the extensions is degraded
+func (independent *Service) unavailable(ids []string) error {
+	independent.degradedMu.Lock()
+	defer independent.degradedMu.Unlock()
+
+	for _, id := range ids {
+		if err, ok := independent.degraded[id]; ok {
+			return fmt.Errorf("%s: the '%s' extension is degraded: %v", Unavailable, id, err)
+		}
+	}
+	return nil
+}
+
+// The retryDegraded repeats the handshake with the degraded extensions until they recover.
+// The extensions with the restart policy are retried by it, see SetRestartPolicy.
+// It stops when the manager is closed.
+func (independent *Service) retryDegraded() {
+	for {
+		time.Sleep(DegradedRetryInterval)
+
+		if independent.manager == nil || !independent.manager.Running() {
+			return
+		}
+
+		now := time.Now()
+		for id := range independent.Degraded() {
+			tracker := independent.tracker(id)
+			if tracker != nil {
+				if !tracker.Due(now) {
+					continue
+				}
+				tracker.Restarted(now)
+			}
+
+			err := independent.handshakeWith(independent.contracts[id])
+			independent.setDegraded(id, err)
+			if err == nil {
+				independent.Logger.Info("optional extension recovered", "id", id)
+				if tracker != nil {
+					tracker.Reset()
+				}
+			}
+		}
+	}
+}
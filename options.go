@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	context "github.com/ahmetson/dev-lib"
+)
+
+// Option of the service created by New, NewAuxiliary or NewProxy
+type Option func(*options)
+
+// options of the New
+type options struct {
+	ctx context.Interface
+	id  string
+	url string
+}
+
+// WithContext sets the context of the service, instead of creating it by context.New.
+// Use it to supply the custom orchestrator, for example, a remote orchestra or a mock.
+//
+// The config engine of the context is started by New if it's not running.
+// The service closes the context when it's closed.
+func WithContext(ctx context.Interface) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithId sets the id of the service, instead of flag.IdFlag or flag.IdEnv.
+// Use it to create the services in the tests without changing os.Args.
+func WithId(id string) Option {
+	return func(o *options) {
+		o.id = id
+	}
+}
+
+// WithUrl sets the url of the service, instead of flag.UrlFlag or flag.UrlEnv.
+func WithUrl(url string) Option {
+	return func(o *options) {
+		o.url = url
+	}
+}
+
+// newOptions applies the options
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// newContext returns the context set by WithContext, or creates it.
+// The config engine of the returned context is running.
+func newContext(o *options) (context.Interface, error) {
+	ctx := o.ctx
+	if ctx == nil {
+		created, err := context.New()
+		if err != nil {
+			return nil, fmt.Errorf("context.New: %w", err)
+		}
+		ctx = created
+	}
+
+	if !ctx.IsConfigRunning() {
+		if err := ctx.StartConfig(); err != nil {
+			return nil, fmt.Errorf("ctx('%s').StartConfig: %w", ctx.Type(), err)
+		}
+	}
+	return ctx, nil
+}
@@ -0,0 +1,120 @@
+package orchestra
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client is the service attached to the orchestra
+type Client struct {
+	conn *conn
+	mu   sync.Mutex
+}
+
+// Dial connects to the orchestra at the tcp url, and proves the token on behalf of the service.
+// The service stays attached until the client is closed.
+func Dial(url string, token string, service string) (*Client, error) {
+	addr, err := address(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := validToken(token); err != nil {
+		return nil, err
+	}
+	if len(service) == 0 {
+		return nil, fmt.Errorf("the 'service' parameter is empty")
+	}
+
+	netConn, err := net.DialTimeout("tcp", addr, Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("net.Dial('%s'): %w", addr, err)
+	}
+	c := newConn(netConn)
+
+	if err := handshake(c, token, service); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return &Client{conn: c}, nil
+}
+
+// handshake answers the challenge of the server
+func handshake(c *conn, token string, service string) error {
+	var received challenge
+	if err := c.read(&received, time.Now().Add(Timeout)); err != nil {
+		return fmt.Errorf("read(challenge): %w", err)
+	}
+	if len(received.Challenge) != ChallengeSize*2 {
+		return fmt.Errorf("the challenge of the server is invalid")
+	}
+	if err := c.write(proof{Service: service, Proof: sign(token, received.Challenge, service)}); err != nil {
+		return fmt.Errorf("write(proof): %w", err)
+	}
+
+	var accepted reply
+	if err := c.read(&accepted, time.Now().Add(Timeout)); err != nil {
+		return fmt.Errorf("read(reply): %w", err)
+	}
+	if len(accepted.Error) > 0 {
+		return fmt.Errorf("the orchestra refused: %s", accepted.Error)
+	}
+	return nil
+}
+
+// hmacEqual compares the hex encoded proofs in the constant time
+func hmacEqual(expected string, actual string) bool {
+	return hmac.Equal([]byte(expected), []byte(actual))
+}
+
+// The request sends the command, and returns the reply of the server
+func (client *Client) request(command string) (reply, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.conn == nil {
+		return reply{}, fmt.Errorf("closed")
+	}
+	if err := client.conn.write(request{Command: command}); err != nil {
+		return reply{}, fmt.Errorf("write('%s'): %w", command, err)
+	}
+	var received reply
+	if err := client.conn.read(&received, time.Now().Add(Timeout)); err != nil {
+		return reply{}, fmt.Errorf("read('%s'): %w", command, err)
+	}
+	if len(received.Error) > 0 {
+		return reply{}, fmt.Errorf("the orchestra: %s", received.Error)
+	}
+	return received, nil
+}
+
+// Status returns the running parts of the orchestra
+func (client *Client) Status() (Status, error) {
+	received, err := client.request(StatusCommand)
+	if err != nil {
+		return Status{}, err
+	}
+	if received.Status == nil {
+		return Status{}, fmt.Errorf("the orchestra replied no status")
+	}
+	return *received.Status, nil
+}
+
+// Close detaches the service from the orchestra.
+// The orchestra keeps running for the other services.
+func (client *Client) Close() error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.conn == nil {
+		return fmt.Errorf("closed")
+	}
+	err := client.conn.Close()
+	client.conn = nil
+	if err != nil {
+		return fmt.Errorf("conn.Close: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package orchestra shares one orchestra between the services of the host.
+//
+// The orchestrator process runs the config engine, the dependency manager and the proxy handler,
+// and accepts the services over TCP by the Server, see cmd/orchestra.
+// The services attach to it by the Client, instead of spawning the orchestra of their own:
+//
+//	ORCHESTRA_TOKEN=<token> orchestra --url=tcp://localhost:4000
+//
+//	client, err := orchestra.Dial("tcp://localhost:4000", os.Getenv(orchestra.TokenEnv), serviceId)
+//	status, err := client.Status()
+//
+// The connection is authenticated by the shared token.
+// The server sends the random challenge, and the client proves the token by HMAC-SHA256 of the challenge,
+// so the token is never sent over the network.
+// The service stays attached while the connection is open, see Server.Attached.
+//
+// The connection only attaches the service and serves the Status of the orchestra's parts.
+// The requests to the config engine, the dependency manager and the proxy handler are not routed over it.
+// They are sent by the context of the service over the sockets of the parts,
+// so the parts must be reachable by the services, and the token doesn't protect them.
+package orchestra
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	TokenEnv      = "ORCHESTRA_TOKEN" // the environment variable with the shared token
+	MinTokenSize  = 16                // the tokens shorter than it are refused
+	ChallengeSize = 32
+	Timeout       = time.Second * 10 // the timeout of the handshake and each request
+	MaxLineSize   = 64 << 10         // the longer lines are refused before they are parsed
+)
+
+const (
+	StatusCommand = "status"
+)
+
+// Backend is the orchestra served by the Server.
+// The context of dev-lib satisfies it.
+type Backend interface {
+	StartConfig() error
+	StartDepManager() error
+	StartProxyHandler() error
+	IsConfigRunning() bool
+	IsDepManagerRunning() bool
+	IsProxyHandlerRunning() bool
+	Close() error
+}
+
+// Status of the orchestra's parts
+type Status struct {
+	Config       bool `json:"config"`
+	DepManager   bool `json:"dep_manager"`
+	ProxyHandler bool `json:"proxy_handler"`
+}
+
+// challenge sent by the server on the connection
+type challenge struct {
+	Challenge string `json:"challenge"`
+}
+
+// proof of the token sent by the client in response to the challenge
+type proof struct {
+	Service string `json:"service"`
+	Proof   string `json:"proof"`
+}
+
+// request of the attached client
+type request struct {
+	Command string `json:"command"`
+}
+
+// reply of the server to the proof or the request
+type reply struct {
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+// address returns the host:port of the tcp url
+func address(url string) (string, error) {
+	if !strings.HasPrefix(url, "tcp://") {
+		return "", fmt.Errorf("the '%s' url is not tcp://<host>:<port>", url)
+	}
+	return strings.TrimPrefix(url, "tcp://"), nil
+}
+
+// validToken returns an error if the token is too short to authenticate
+func validToken(token string) error {
+	if len(token) < MinTokenSize {
+		return fmt.Errorf("the token must be at least %d bytes, set %s", MinTokenSize, TokenEnv)
+	}
+	return nil
+}
+
+// newChallenge returns the random hex encoded challenge
+func newChallenge() (string, error) {
+	nonce := make([]byte, ChallengeSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// sign returns the proof of the token for the challenge and the service
+func sign(token string, challenge string, service string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(challenge))
+	mac.Write([]byte{0})
+	mac.Write([]byte(service))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// conn is the connection exchanging the JSON lines
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{Conn: c, reader: bufio.NewReader(c)}
+}
+
+// readLine reads the line of at most MaxLineSize bytes.
+// The line is read by the chunks of the reader's buffer, so the longer line is never kept in memory.
+func (c *conn) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := c.reader.ReadSlice('\n')
+		if len(line)+len(chunk) > MaxLineSize {
+			return nil, fmt.Errorf("the line exceeds %d bytes", MaxLineSize)
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			return line, nil
+		}
+		if err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+}
+
+// write the message as the JSON line within the Timeout
+func (c *conn) write(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := c.SetWriteDeadline(time.Now().Add(Timeout)); err != nil {
+		return fmt.Errorf("conn.SetWriteDeadline: %w", err)
+	}
+	if _, err := c.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("conn.Write: %w", err)
+	}
+	return nil
+}
+
+// read the JSON line into the message.
+// If the deadline is zero, waits until the line arrives or the connection is closed.
+// The line longer than MaxLineSize is refused before it's parsed.
+func (c *conn) read(message interface{}, deadline time.Time) error {
+	if err := c.SetReadDeadline(deadline); err != nil {
+		return fmt.Errorf("conn.SetReadDeadline: %w", err)
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("conn.Read: %w", err)
+	}
+	if err := json.Unmarshal(line, message); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package orchestra
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend is the orchestra with the parts started by the flags
+type fakeBackend struct {
+	config       bool
+	depManager   bool
+	proxyHandler bool
+	closed       bool
+	failOn       string // the part that fails to start
+	mu           sync.Mutex
+}
+
+func (backend *fakeBackend) start(part string, running *bool) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if backend.failOn == part {
+		return fmt.Errorf("%s failed", part)
+	}
+	*running = true
+	return nil
+}
+
+func (backend *fakeBackend) StartConfig() error {
+	return backend.start("config", &backend.config)
+}
+
+func (backend *fakeBackend) StartDepManager() error {
+	return backend.start("dep_manager", &backend.depManager)
+}
+
+func (backend *fakeBackend) StartProxyHandler() error {
+	return backend.start("proxy_handler", &backend.proxyHandler)
+}
+
+func (backend *fakeBackend) IsConfigRunning() bool {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return backend.config
+}
+
+func (backend *fakeBackend) IsDepManagerRunning() bool {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return backend.depManager
+}
+
+func (backend *fakeBackend) IsProxyHandlerRunning() bool {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return backend.proxyHandler
+}
+
+func (backend *fakeBackend) Close() error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	backend.config, backend.depManager, backend.proxyHandler = false, false, false
+	backend.closed = true
+	return nil
+}
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestOrchestraSuite struct {
+	suite.Suite
+
+	token   string
+	backend *fakeBackend
+	server  *Server
+}
+
+func (test *TestOrchestraSuite) SetupTest() {
+	s := test.Require
+
+	test.token = strings.Repeat("t", MinTokenSize)
+	test.backend = &fakeBackend{}
+	server, err := NewServer("tcp://127.0.0.1:0", test.token, test.backend)
+	s().NoError(err)
+	s().NoError(server.Start())
+	test.server = server
+}
+
+func (test *TestOrchestraSuite) TearDownTest() {
+	_ = test.server.Close()
+}
+
+// Test_10_NewServer tests the invalid parameters and the parts started by the server
+func (test *TestOrchestraSuite) Test_10_NewServer() {
+	s := test.Require
+
+	_, err := NewServer("localhost:0", test.token, &fakeBackend{})
+	s().Error(err)
+	_, err = NewServer("tcp://127.0.0.1:0", "short", &fakeBackend{})
+	s().Error(err)
+	_, err = NewServer("tcp://127.0.0.1:0", test.token, nil)
+	s().Error(err)
+
+	// the server started all parts of the orchestra
+	s().True(test.backend.IsConfigRunning())
+	s().True(test.backend.IsDepManagerRunning())
+	s().True(test.backend.IsProxyHandlerRunning())
+	s().Error(test.server.Start())
+
+	// the part that failed to start fails the server
+	failing, err := NewServer("tcp://127.0.0.1:0", test.token, &fakeBackend{failOn: "dep_manager"})
+	s().NoError(err)
+	s().Error(failing.Start())
+	s().Error(failing.Close())
+}
+
+// Test_11_Authenticate tests that only the clients with the token are attached
+func (test *TestOrchestraSuite) Test_11_Authenticate() {
+	s := test.Require
+
+	url := test.server.Url()
+	_, err := Dial(url, strings.Repeat("x", MinTokenSize), "service_1")
+	s().Error(err)
+	_, err = Dial(url, "short", "service_1")
+	s().Error(err)
+	_, err = Dial(url, test.token, "")
+	s().Error(err)
+	_, err = Dial("127.0.0.1:0", test.token, "service_1")
+	s().Error(err)
+	s().Zero(test.server.Attached("service_1"))
+
+	// the proof is bound to the service
+	s().NotEqual(sign(test.token, "challenge", "service_1"), sign(test.token, "challenge", "service_2"))
+
+	client, err := Dial(url, test.token, "service_1")
+	s().NoError(err)
+	s().Equal(1, test.server.Attached("service_1"))
+
+	second, err := Dial(url, test.token, "service_1")
+	s().NoError(err)
+	s().Equal(2, test.server.Attached("service_1"))
+
+	// closing the client detaches the service
+	s().NoError(second.Close())
+	s().Error(second.Close())
+	s().Eventually(func() bool { return test.server.Attached("service_1") == 1 }, time.Second, time.Millisecond)
+	s().NoError(client.Close())
+	s().Eventually(func() bool { return test.server.Attached("service_1") == 0 }, time.Second, time.Millisecond)
+}
+
+// Test_12_Status tests the status of the orchestra's parts
+func (test *TestOrchestraSuite) Test_12_Status() {
+	s := test.Require
+
+	client, err := Dial(test.server.Url(), test.token, "service_1")
+	s().NoError(err)
+
+	status, err := client.Status()
+	s().NoError(err)
+	s().Equal(Status{Config: true, DepManager: true, ProxyHandler: true}, status)
+
+	test.backend.mu.Lock()
+	test.backend.proxyHandler = false
+	test.backend.mu.Unlock()
+	status, err = client.Status()
+	s().NoError(err)
+	s().False(status.ProxyHandler)
+
+	_, err = client.request("unknown")
+	s().Error(err)
+
+	// closing the server disconnects the services and closes the orchestra
+	s().NoError(test.server.Close())
+	s().True(test.backend.closed)
+	_, err = client.Status()
+	s().Error(err)
+	s().NoError(client.Close())
+	_, err = client.Status()
+	s().Error(err)
+}
+
+// Test_13_MaxLineSize tests that the line longer than MaxLineSize is refused before it's parsed
+func (test *TestOrchestraSuite) Test_13_MaxLineSize() {
+	s := test.Require
+
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	go func() {
+		_, _ = client.Write([]byte(strings.Repeat("x", MaxLineSize+1) + "\n"))
+	}()
+
+	var message map[string]interface{}
+	err := newConn(server).read(&message, time.Now().Add(Timeout))
+	s().ErrorContains(err, "exceeds")
+	_ = server.Close()
+
+	// the line within the limit is longer than the buffer of the reader
+	client, server = net.Pipe()
+	defer func() { _ = client.Close() }()
+	go func() {
+		_, _ = client.Write([]byte(`{"padding":"` + strings.Repeat("x", MaxLineSize/2) + `"}` + "\n"))
+	}()
+	err = newConn(server).read(&message, time.Now().Add(Timeout))
+	s().NoError(err)
+	s().Len(message["padding"], MaxLineSize/2)
+	_ = server.Close()
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestOrchestra(t *testing.T) {
+	suite.Run(t, new(TestOrchestraSuite))
+}
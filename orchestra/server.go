@@ -0,0 +1,235 @@
+package orchestra
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Server serves the orchestra to the services of the host
+type Server struct {
+	url      string
+	token    string
+	backend  Backend
+	listener net.Listener
+	attached map[string]int // the number of the connections by the service
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+}
+
+// NewServer returns the server of the backend listening the tcp url when started.
+// The clients must prove the token.
+func NewServer(url string, token string, backend Backend) (*Server, error) {
+	if _, err := address(url); err != nil {
+		return nil, err
+	}
+	if err := validToken(token); err != nil {
+		return nil, err
+	}
+	if backend == nil {
+		return nil, fmt.Errorf("the 'backend' parameter is nil")
+	}
+
+	return &Server{
+		url:      url,
+		token:    token,
+		backend:  backend,
+		attached: make(map[string]int),
+		conns:    make(map[net.Conn]struct{}),
+	}, nil
+}
+
+// Start starts the parts of the backend that are not running, then listens for the services.
+// If listening fails, the backend is kept running, the caller closes it.
+func (server *Server) Start() error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.listener != nil {
+		return fmt.Errorf("already running")
+	}
+
+	if !server.backend.IsConfigRunning() {
+		if err := server.backend.StartConfig(); err != nil {
+			return fmt.Errorf("backend.StartConfig: %w", err)
+		}
+	}
+	if !server.backend.IsDepManagerRunning() {
+		if err := server.backend.StartDepManager(); err != nil {
+			return fmt.Errorf("backend.StartDepManager: %w", err)
+		}
+	}
+	if !server.backend.IsProxyHandlerRunning() {
+		if err := server.backend.StartProxyHandler(); err != nil {
+			return fmt.Errorf("backend.StartProxyHandler: %w", err)
+		}
+	}
+
+	addr, _ := address(server.url)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("net.Listen('%s'): %w", addr, err)
+	}
+	server.listener = listener
+
+	server.wg.Add(1)
+	go server.accept(listener)
+
+	return nil
+}
+
+// Url returns the url of the server.
+// If the server listens the port 0, then the url has the chosen port after Start.
+func (server *Server) Url() string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.listener != nil {
+		return "tcp://" + server.listener.Addr().String()
+	}
+	return server.url
+}
+
+// Attached returns the number of the connections of the service
+func (server *Server) Attached(service string) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.attached[service]
+}
+
+// The accept serves each connection in its own goroutine until the listener is closed
+func (server *Server) accept(listener net.Listener) {
+	defer server.wg.Done()
+
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		server.mu.Lock()
+		// accepted while closing, Close doesn't see it
+		if server.listener == nil {
+			server.mu.Unlock()
+			_ = c.Close()
+			return
+		}
+		server.conns[c] = struct{}{}
+		server.mu.Unlock()
+
+		server.wg.Add(1)
+		go server.serve(c)
+	}
+}
+
+// The serve authenticates the connection, then replies to its requests until it's closed
+func (server *Server) serve(netConn net.Conn) {
+	defer server.wg.Done()
+	defer func() {
+		_ = netConn.Close()
+		server.mu.Lock()
+		delete(server.conns, netConn)
+		server.mu.Unlock()
+	}()
+
+	c := newConn(netConn)
+	service, err := server.authenticate(c)
+	if err != nil {
+		_ = c.write(reply{Error: err.Error()})
+		return
+	}
+	if err := c.write(reply{}); err != nil {
+		return
+	}
+
+	server.mu.Lock()
+	server.attached[service]++
+	server.mu.Unlock()
+	defer server.detach(service)
+
+	for {
+		var req request
+		if err := c.read(&req, time.Time{}); err != nil {
+			return
+		}
+		if err := c.write(server.handle(req)); err != nil {
+			return
+		}
+	}
+}
+
+// The authenticate challenges the client, and returns the service proved the token
+func (server *Server) authenticate(c *conn) (string, error) {
+	nonce, err := newChallenge()
+	if err != nil {
+		return "", fmt.Errorf("newChallenge: %w", err)
+	}
+	if err := c.write(challenge{Challenge: nonce}); err != nil {
+		return "", fmt.Errorf("write(challenge): %w", err)
+	}
+
+	var received proof
+	if err := c.read(&received, time.Now().Add(Timeout)); err != nil {
+		return "", fmt.Errorf("read(proof): %w", err)
+	}
+	if len(received.Service) == 0 {
+		return "", fmt.Errorf("the service is not set")
+	}
+	if !hmacEqual(sign(server.token, nonce, received.Service), received.Proof) {
+		return "", fmt.Errorf("the token is invalid")
+	}
+	return received.Service, nil
+}
+
+// The detach forgets the closed connection of the service
+func (server *Server) detach(service string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.attached[service]--
+	if server.attached[service] <= 0 {
+		delete(server.attached, service)
+	}
+}
+
+// The handle replies to the request
+func (server *Server) handle(req request) reply {
+	switch req.Command {
+	case StatusCommand:
+		return reply{Status: &Status{
+			Config:       server.backend.IsConfigRunning(),
+			DepManager:   server.backend.IsDepManagerRunning(),
+			ProxyHandler: server.backend.IsProxyHandlerRunning(),
+		}}
+	default:
+		return reply{Error: fmt.Sprintf("unknown '%s' command", req.Command)}
+	}
+}
+
+// Close stops listening, disconnects the services, then closes the backend
+func (server *Server) Close() error {
+	server.mu.Lock()
+	if server.listener == nil {
+		server.mu.Unlock()
+		return fmt.Errorf("not running")
+	}
+	err := server.listener.Close()
+	server.listener = nil
+	for c := range server.conns {
+		_ = c.Close()
+	}
+	server.mu.Unlock()
+
+	server.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("listener.Close: %w", err)
+	}
+	if err := server.backend.Close(); err != nil {
+		return fmt.Errorf("backend.Close: %w", err)
+	}
+	return nil
+}
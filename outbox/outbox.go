@@ -0,0 +1,314 @@
+// Package outbox keeps the outgoing messages on the disk until the consumer acknowledges them.
+//
+// The PUSH sockets lose the messages if the peer is down.
+// Put the message into the outbox first, then deliver it by Outbox.Deliver or Outbox.Run.
+// The message is removed from the disk only when the consumer acknowledges it.
+// Thus, the outbox guarantees at-least-once delivery.
+// The message is synced to the disk before Put returns, so it survives the power loss too.
+//
+// The last sequence number is kept in the seqFile, so the acknowledged sequence numbers are not reused
+// after the restart with the empty outbox.
+package outbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const extension = ".msg"
+
+// tmpExtension is appended to the message file while it's written
+const tmpExtension = ".tmp"
+
+// seqFile keeps the sequence number of the last put message
+const seqFile = "last.seq"
+
+// Entry is the message stored in the outbox
+type Entry struct {
+	Seq  uint64 // the order of the message in the outbox
+	Data []byte
+}
+
+// SendFunc delivers the entry to the consumer.
+// It must return nil only after the consumer acknowledged the entry.
+// For example, after receiving the successful reply from the replier.
+type SendFunc = func(entry Entry) error
+
+// Outbox is the disk-backed queue of the messages
+type Outbox struct {
+	dir string
+	seq uint64
+	mu  sync.Mutex
+}
+
+// New returns the outbox that stores the messages in the dir.
+// The pending messages left from the previous run are kept.
+// The temporary files of the messages interrupted by the crash are removed, as they were never put.
+// The sequence continues from the last put message, even if all messages were acknowledged.
+func New(dir string) (*Outbox, error) {
+	if len(dir) == 0 {
+		return nil, fmt.Errorf("the 'dir' parameter is empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll('%s'): %w", dir, err)
+	}
+
+	outbox := &Outbox{dir: dir}
+	if err := outbox.sweep(); err != nil {
+		return nil, fmt.Errorf("outbox.sweep: %w", err)
+	}
+	seqs, err := outbox.seqs()
+	if err != nil {
+		return nil, fmt.Errorf("outbox.seqs: %w", err)
+	}
+	if len(seqs) > 0 {
+		outbox.seq = seqs[len(seqs)-1]
+	}
+	// the message could be put without persisting its sequence number, if the process crashed between them
+	lastSeq, err := outbox.lastSeq()
+	if err != nil {
+		return nil, fmt.Errorf("outbox.lastSeq: %w", err)
+	}
+	if lastSeq > outbox.seq {
+		outbox.seq = lastSeq
+	}
+
+	return outbox, nil
+}
+
+// lastSeq returns the persisted sequence number of the last put message, zero if it's not persisted yet
+func (outbox *Outbox) lastSeq() (uint64, error) {
+	filePath := filepath.Join(outbox.dir, seqFile)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("os.ReadFile('%s'): %w", filePath, err)
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("strconv.ParseUint('%s'): %w", filePath, err)
+	}
+	return seq, nil
+}
+
+// setLastSeq persists the sequence number of the last put message.
+// The directory is not synced, see Put.
+func (outbox *Outbox) setLastSeq(seq uint64) error {
+	filePath := filepath.Join(outbox.dir, seqFile)
+	tmpPath := filePath + tmpExtension
+	if err := writeFile(tmpPath, []byte(strconv.FormatUint(seq, 10))); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writeFile('%s'): %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("os.Rename('%s'): %w", tmpPath, err)
+	}
+	return nil
+}
+
+func (outbox *Outbox) path(seq uint64) string {
+	return filepath.Join(outbox.dir, fmt.Sprintf("%020d%s", seq, extension))
+}
+
+// sweep removes the temporary files left by the interrupted Put
+func (outbox *Outbox) sweep() error {
+	files, err := os.ReadDir(outbox.dir)
+	if err != nil {
+		return fmt.Errorf("os.ReadDir('%s'): %w", outbox.dir, err)
+	}
+	for _, file := range files {
+		if file.IsDir() || (!strings.HasSuffix(file.Name(), extension+tmpExtension) && file.Name() != seqFile+tmpExtension) {
+			continue
+		}
+		filePath := filepath.Join(outbox.dir, file.Name())
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("os.Remove('%s'): %w", filePath, err)
+		}
+	}
+	return nil
+}
+
+// writeFile writes the data into the file and syncs it to the disk
+func writeFile(filePath string, data []byte) error {
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("f.Write: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("f.Sync: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("f.Close: %w", err)
+	}
+	return nil
+}
+
+// syncDir syncs the directory entries, so the renamed file is on the disk.
+// Windows can't sync the directories, the renaming is journaled by the file system.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("f.Sync: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("f.Close: %w", err)
+	}
+	return nil
+}
+
+// seqs returns the sequence numbers of the stored messages in ascending order
+func (outbox *Outbox) seqs() ([]uint64, error) {
+	files, err := os.ReadDir(outbox.dir)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadDir('%s'): %w", outbox.dir, err)
+	}
+
+	seqs := make([]uint64, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, extension) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, extension), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool {
+		return seqs[i] < seqs[j]
+	})
+
+	return seqs, nil
+}
+
+// Put stores the message on the disk.
+// The message file, its sequence number and the directory are synced before returning.
+// Returns the sequence number of the message.
+func (outbox *Outbox) Put(data []byte) (uint64, error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	seq := outbox.seq + 1
+	filePath := outbox.path(seq)
+	tmpPath := filePath + tmpExtension
+
+	// write into the temporary file first, so the partially written message is never delivered
+	if err := writeFile(tmpPath, data); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, fmt.Errorf("writeFile('%s'): %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, fmt.Errorf("os.Rename('%s'): %w", tmpPath, err)
+	}
+	// the message is put even if persisting the sequence or syncing the directory fails,
+	// the sequence is not reused while the message is in the outbox.
+	outbox.seq = seq
+	if err := outbox.setLastSeq(seq); err != nil {
+		return seq, fmt.Errorf("outbox.setLastSeq: %w", err)
+	}
+	if err := syncDir(outbox.dir); err != nil {
+		return seq, fmt.Errorf("syncDir('%s'): %w", outbox.dir, err)
+	}
+
+	return seq, nil
+}
+
+// Pending returns the messages that were not acknowledged yet in the order they were put.
+func (outbox *Outbox) Pending() ([]Entry, error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	seqs, err := outbox.seqs()
+	if err != nil {
+		return nil, fmt.Errorf("outbox.seqs: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(seqs))
+	for _, seq := range seqs {
+		data, err := os.ReadFile(outbox.path(seq))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("os.ReadFile(seq=%d): %w", seq, err)
+		}
+		entries = append(entries, Entry{Seq: seq, Data: data})
+	}
+
+	return entries, nil
+}
+
+// Ack removes the acknowledged message from the disk
+func (outbox *Outbox) Ack(seq uint64) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	if err := os.Remove(outbox.path(seq)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("os.Remove(seq=%d): %w", seq, err)
+	}
+	return nil
+}
+
+// Deliver sends the pending messages in order.
+// It stops on the first failed delivery to keep the order.
+// Returns the number of the delivered messages.
+func (outbox *Outbox) Deliver(send SendFunc) (int, error) {
+	entries, err := outbox.Pending()
+	if err != nil {
+		return 0, fmt.Errorf("outbox.Pending: %w", err)
+	}
+
+	for i, entry := range entries {
+		if err := send(entry); err != nil {
+			return i, fmt.Errorf("send(seq=%d): %w", entry.Seq, err)
+		}
+		if err := outbox.Ack(entry.Seq); err != nil {
+			return i, fmt.Errorf("outbox.Ack(seq=%d): %w", entry.Seq, err)
+		}
+	}
+
+	return len(entries), nil
+}
+
+// Run delivers the pending messages every interval until the stop channel is closed.
+// The failed deliveries are retried on the next interval.
+// The errors are passed to onErr, if it's not nil.
+func (outbox *Outbox) Run(send SendFunc, interval time.Duration, stop <-chan struct{}, onErr func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := outbox.Deliver(send); err != nil && onErr != nil {
+				onErr(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package outbox
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestOutboxSuite struct {
+	suite.Suite
+
+	dir string
+}
+
+func (test *TestOutboxSuite) SetupTest() {
+	test.dir = test.T().TempDir()
+}
+
+// Test_10_Deliver tests that the messages are kept until acknowledged
+func (test *TestOutboxSuite) Test_10_Deliver() {
+	s := test.Require
+
+	outbox, err := New(test.dir)
+	s().NoError(err)
+
+	for i := 0; i < 3; i++ {
+		_, err := outbox.Put([]byte(fmt.Sprintf("message_%d", i)))
+		s().NoError(err)
+	}
+
+	// the consumer is down
+	delivered, err := outbox.Deliver(func(entry Entry) error {
+		return fmt.Errorf("peer is down")
+	})
+	s().Error(err)
+	s().Zero(delivered)
+
+	// the consumer acknowledges only the first message
+	delivered, err = outbox.Deliver(func(entry Entry) error {
+		if entry.Seq > 1 {
+			return fmt.Errorf("not acknowledged")
+		}
+		return nil
+	})
+	s().Error(err)
+	s().Equal(1, delivered)
+
+	// the outbox restored from the disk keeps the pending messages in order
+	outbox, err = New(test.dir)
+	s().NoError(err)
+	entries, err := outbox.Pending()
+	s().NoError(err)
+	s().Len(entries, 2)
+	s().Equal("message_1", string(entries[0].Data))
+	s().Equal("message_2", string(entries[1].Data))
+
+	seq, err := outbox.Put([]byte("message_3"))
+	s().NoError(err)
+	s().Equal(uint64(4), seq)
+
+	received := make([]string, 0, 3)
+	delivered, err = outbox.Deliver(func(entry Entry) error {
+		received = append(received, string(entry.Data))
+		return nil
+	})
+	s().NoError(err)
+	s().Equal(3, delivered)
+	s().Equal([]string{"message_1", "message_2", "message_3"}, received)
+
+	entries, err = outbox.Pending()
+	s().NoError(err)
+	s().Empty(entries)
+}
+
+// Test_11_Sweep tests that the messages interrupted by the crash are removed
+func (test *TestOutboxSuite) Test_11_Sweep() {
+	s := test.Require
+
+	outbox, err := New(test.dir)
+	s().NoError(err)
+	seq, err := outbox.Put([]byte("message_0"))
+	s().NoError(err)
+
+	// imitate the crash while writing the next message
+	orphan := outbox.path(seq+1) + tmpExtension
+	s().NoError(os.WriteFile(orphan, []byte("mess"), 0644))
+	foreign := filepath.Join(test.dir, "notes.tmp")
+	s().NoError(os.WriteFile(foreign, []byte("not a message"), 0644))
+
+	outbox, err = New(test.dir)
+	s().NoError(err)
+	_, err = os.Stat(orphan)
+	s().True(os.IsNotExist(err))
+	_, err = os.Stat(foreign)
+	s().NoError(err)
+
+	// the sequence continues after the put messages only
+	entries, err := outbox.Pending()
+	s().NoError(err)
+	s().Len(entries, 1)
+	seq, err = outbox.Put([]byte("message_1"))
+	s().NoError(err)
+	s().Equal(uint64(2), seq)
+
+	// no temporary files are left by Put, only the messages, the last sequence and the foreign file
+	files, err := os.ReadDir(test.dir)
+	s().NoError(err)
+	s().Len(files, 4)
+}
+
+// Test_12_Seq tests that the sequence is not reused after the restart with the empty outbox
+func (test *TestOutboxSuite) Test_12_Seq() {
+	s := test.Require
+
+	outbox, err := New(test.dir)
+	s().NoError(err)
+	for i := 0; i < 2; i++ {
+		_, err := outbox.Put([]byte(fmt.Sprintf("message_%d", i)))
+		s().NoError(err)
+	}
+	delivered, err := outbox.Deliver(func(entry Entry) error {
+		return nil
+	})
+	s().NoError(err)
+	s().Equal(2, delivered)
+
+	outbox, err = New(test.dir)
+	s().NoError(err)
+	seq, err := outbox.Put([]byte("message_2"))
+	s().NoError(err)
+	s().Equal(uint64(3), seq)
+
+	// the crash after putting the message, before persisting its sequence
+	s().NoError(os.WriteFile(filepath.Join(test.dir, seqFile), []byte("1"), 0644))
+	outbox, err = New(test.dir)
+	s().NoError(err)
+	seq, err = outbox.Put([]byte("message_3"))
+	s().NoError(err)
+	s().Equal(uint64(4), seq)
+
+	s().NoError(os.WriteFile(filepath.Join(test.dir, seqFile), []byte("invalid"), 0644))
+	_, err = New(test.dir)
+	s().Error(err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestOutbox(t *testing.T) {
+	suite.Run(t, new(TestOutboxSuite))
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/service-lib/flag"
+	"os"
+)
+
+// setOverrides parses the per-handler configuration flags, see flag.HandlerOverrides.
+// The overrides of the handlers that are not set in this service are rejected to catch the typos.
+func (independent *Service) setOverrides() error {
+	overrides, err := flag.HandlerOverrides(os.Args[1:])
+	if err != nil {
+		return fmt.Errorf("flag.HandlerOverrides: %w", err)
+	}
+
+	for category, override := range overrides {
+		if _, ok := independent.Handlers[category]; !ok {
+			return fmt.Errorf("the '%s' handler in the flags is not set", category)
+		}
+		if independent.isInternal(category) && override.Port != 0 {
+			return fmt.Errorf("the '%s' handler is internal, the port can not be set", category)
+		}
+	}
+
+	independent.overrides = overrides
+	for category, override := range overrides {
+		if len(override.Restart.Mode) > 0 {
+			independent.SetRestartPolicy(category, override.Restart)
+		}
+	}
+	return nil
+}
+
+// applyOverride overwrites the handler configuration by the flags.
+// Returns true if the configuration was changed.
+func (independent *Service) applyOverride(category string, c *handlerConfig.Handler) bool {
+	override, ok := independent.overrides[category]
+	if !ok {
+		return false
+	}
+
+	changed := false
+	if override.Port != 0 && c.Port != override.Port {
+		c.Port = override.Port
+		changed = true
+	}
+	if override.Instances != 0 && c.InstanceAmount != override.Instances {
+		c.InstanceAmount = override.Instances
+		changed = true
+	}
+
+	return changed
+}
@@ -0,0 +1,98 @@
+// Package payload samples the request and reply payloads for the logs.
+//
+// Only 1 in N messages is logged, and the fields matching the redaction patterns are hidden,
+// so the secrets never reach the logs whatever handler sends them.
+package payload
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted replaces the values of the redacted fields
+const Redacted = "[redacted]"
+
+// DefaultPatterns are the field names that are always redacted
+var DefaultPatterns = []string{"*secret", "*private_key", "*password", "*token"}
+
+// Redactor hides the values of the fields matching the patterns.
+// The patterns use the path.Match syntax, and are matched case-insensitively.
+type Redactor struct {
+	patterns []string
+}
+
+// NewRedactor returns the redactor with the DefaultPatterns and the given patterns
+func NewRedactor(patterns ...string) (*Redactor, error) {
+	given := append(append([]string{}, DefaultPatterns...), patterns...)
+	all := make([]string, 0, len(given))
+	for _, pattern := range given {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("path.Match('%s'): %w", pattern, err)
+		}
+		all = append(all, pattern)
+	}
+
+	return &Redactor{patterns: all}, nil
+}
+
+func (redactor *Redactor) match(field string) bool {
+	field = strings.ToLower(field)
+	for _, pattern := range redactor.patterns {
+		if matched, _ := path.Match(pattern, field); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns the copy of the parameters with the matched fields redacted at any depth.
+// The parameters are not changed.
+func (redactor *Redactor) Redact(parameters map[string]interface{}) map[string]interface{} {
+	return redactor.redact(parameters).(map[string]interface{})
+}
+
+func (redactor *Redactor) redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for field, nested := range v {
+			if redactor.match(field) {
+				redacted[field] = Redacted
+			} else {
+				redacted[field] = redactor.redact(nested)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i := range v {
+			redacted[i] = redactor.redact(v[i])
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// Sampler selects 1 in N messages
+type Sampler struct {
+	every uint64
+	count atomic.Uint64
+}
+
+// NewSampler returns the sampler of 1 in every messages.
+// If every is 0, then no message is sampled.
+func NewSampler(every uint64) *Sampler {
+	return &Sampler{every: every}
+}
+
+// Sample returns true if the message must be logged
+func (sampler *Sampler) Sample() bool {
+	if sampler.every == 0 {
+		return false
+	}
+	return sampler.count.Add(1)%sampler.every == 0
+}
@@ -0,0 +1,72 @@
+package payload
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestPayloadSuite struct {
+	suite.Suite
+}
+
+// Test_10_Redact tests the fields are hidden at any depth
+func (test *TestPayloadSuite) Test_10_Redact() {
+	s := test.Require
+
+	_, err := NewRedactor("[")
+	s().Error(err)
+
+	redactor, err := NewRedactor("*_key")
+	s().NoError(err)
+
+	parameters := map[string]interface{}{
+		"id":           "a",
+		"curve_secret": "abc",
+		"Private_Key":  "def",
+		"api_key":      "ghi",
+		"nested": map[string]interface{}{
+			"password": "jkl",
+			"amount":   1,
+		},
+		"list": []interface{}{map[string]interface{}{"auth_token": "mno"}},
+	}
+
+	redacted := redactor.Redact(parameters)
+	s().Equal("a", redacted["id"])
+	s().Equal(Redacted, redacted["curve_secret"])
+	s().Equal(Redacted, redacted["Private_Key"])
+	s().Equal(Redacted, redacted["api_key"])
+	s().Equal(Redacted, redacted["nested"].(map[string]interface{})["password"])
+	s().Equal(1, redacted["nested"].(map[string]interface{})["amount"])
+	s().Equal(Redacted, redacted["list"].([]interface{})[0].(map[string]interface{})["auth_token"])
+
+	// the original parameters are not changed
+	s().Equal("abc", parameters["curve_secret"])
+	s().Equal("jkl", parameters["nested"].(map[string]interface{})["password"])
+}
+
+// Test_11_Sample tests 1 in N sampling
+func (test *TestPayloadSuite) Test_11_Sample() {
+	s := test.Require
+
+	sampled := 0
+	sampler := NewSampler(10)
+	for i := 0; i < 100; i++ {
+		if sampler.Sample() {
+			sampled++
+		}
+	}
+	s().Equal(10, sampled)
+
+	s().False(NewSampler(0).Sample())
+	s().True(NewSampler(1).Sample())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestPayload(t *testing.T) {
+	suite.Run(t, new(TestPayloadSuite))
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/service-lib/flag"
+	"net"
+	"os"
+	"strconv"
+)
+
+// DefaultPortRetries is the amount of the new ports requested for the handler,
+// if its generated port is bound by another process.
+const DefaultPortRetries = 3
+
+// SetPortRetries sets the amount of the new ports requested for the handler, if its port is bound.
+// Zero disables the retries, then Start fails on the bound port.
+// Call it before Start.
+func (independent *Service) SetPortRetries(retries int) {
+	independent.portRetries = retries
+}
+
+// portFree returns true if the tcp port can be bound on all interfaces
+func portFree(port uint64) bool {
+	listener, err := net.Listen("tcp", ":"+strconv.FormatUint(port, 10))
+	if err != nil {
+		return false
+	}
+	_ = listener.Close()
+	return true
+}
+
+// freePort returns the tcp port chosen by the system
+func freePort() (uint64, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, fmt.Errorf("net.Listen: %w", err)
+	}
+	port := uint64(listener.Addr().(*net.TCPAddr).Port)
+	if err := listener.Close(); err != nil {
+		return 0, fmt.Errorf("listener.Close: %w", err)
+	}
+	return port, nil
+}
+
+// fixedPort returns true if the port of the handler is set by the user, see flag.HandlerOverrides and flag.PortEnv.
+// The fixed ports are never replaced.
+func (independent *Service) fixedPort(key string) bool {
+	if override, ok := independent.overrides[key]; ok && override.Port != 0 {
+		return true
+	}
+	return IsContainer() && len(os.Getenv(flag.PortEnv(key))) > 0
+}
+
+// resolvePorts replaces the bound ports of the handlers by the new ports from the config engine.
+// Each handler gets up to the port retries new ports, then the start fails.
+// The new ports are saved in the service configuration.
+func (independent *Service) resolvePorts() error {
+	for key, raw := range independent.Handlers {
+		handler := raw.(base.Interface)
+		c := handler.Config()
+		if c == nil || c.Port == 0 || portFree(c.Port) {
+			continue
+		}
+		if independent.fixedPort(key) {
+			return fmt.Errorf("the '%s' handler's %d port is bound, and it's set by the user", key, c.Port)
+		}
+
+		bound := c.Port
+		for attempt := 0; ; attempt++ {
+			if attempt == independent.portRetries {
+				return fmt.Errorf("the '%s' handler's %d port is bound, no free port after %d retries", key, bound, independent.portRetries)
+			}
+
+			generated, err := independent.generateHandlerByKey(key, handler)
+			if err != nil {
+				return fmt.Errorf("generateHandlerByKey('%s'): %w", key, err)
+			}
+			if generated.Port != c.Port && portFree(generated.Port) {
+				c.Port = generated.Port
+				break
+			}
+		}
+
+		serviceConf, err := independent.ctx.Config().Service(independent.id)
+		if err != nil {
+			return fmt.Errorf("ctx.Config().Service('%s'): %w", independent.id, err)
+		}
+		serviceConf.SetHandler(c)
+		if err := independent.ctx.Config().SetService(serviceConf); err != nil {
+			return fmt.Errorf("ctx.Config().SetService: %w", err)
+		}
+
+		independent.Logger.Warn("the handler port is bound, using the new port", "handler", key, "bound", bound, "port", c.Port)
+	}
+
+	return nil
+}
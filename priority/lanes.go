@@ -0,0 +1,221 @@
+// Package priority processes the requests in two lanes.
+//
+// The requests in the high-priority lane are processed first.
+// So the management traffic, such as heartbeats, is not starved when the handler is busy with the bulk work.
+//
+// The lanes reorder the requests only if the handler receives them concurrently, like the replier.
+// The sync replier handles one request at a time, so nothing waits in the lanes.
+//
+// The priority of the request is its Param. The request without it takes the priority of its command:
+//
+//	lanes := priority.New(4)
+//	lanes.SetHigh("heartbeat")
+//	service.SetLanes("main", lanes)
+//	service.RoutePriority("main", "heartbeat", onHeartbeat)
+//	service.RoutePriority("main", "get_logs", onGetLogs)
+//
+// Without the service, wrap the route functions by Route or RouteBy:
+//
+//	handler.Route("heartbeat", priority.Route(lanes, "heartbeat", onHeartbeat, onErr))
+package priority
+
+import (
+	"fmt"
+	"sync"
+)
+
+// QueueSize is the default amount of the requests waiting in each lane
+const QueueSize = 1024
+
+const (
+	// Param is the request parameter with the priority of the request, High or Low
+	Param = "priority"
+	// High priority requests are processed before the waiting Low ones
+	High = "high"
+	// Low priority requests are processed when no High request waits
+	Low = "low"
+)
+
+type job struct {
+	run  func()
+	done chan struct{}
+}
+
+// Lanes runs the jobs by the workers, draining the high-priority lane first
+type Lanes struct {
+	high     chan job
+	low      chan job
+	commands map[string]bool // the high-priority routes
+	workers  int
+	stop     chan struct{}
+	running  bool
+	mu       sync.RWMutex
+}
+
+// New returns the lanes processed by the given amount of the workers.
+func New(workers int) *Lanes {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Lanes{
+		high:     make(chan job, QueueSize),
+		low:      make(chan job, QueueSize),
+		commands: make(map[string]bool),
+		workers:  workers,
+	}
+}
+
+// SetHigh marks the routes as high-priority
+func (lanes *Lanes) SetHigh(commands ...string) {
+	lanes.mu.Lock()
+	defer lanes.mu.Unlock()
+
+	for _, command := range commands {
+		lanes.commands[command] = true
+	}
+}
+
+// IsHigh returns true if the route is high-priority
+func (lanes *Lanes) IsHigh(command string) bool {
+	lanes.mu.RLock()
+	defer lanes.mu.RUnlock()
+
+	return lanes.commands[command]
+}
+
+// Decide returns true if the request goes into the high-priority lane.
+// The priority of the request, see Param, overrides the priority of the command.
+// If the priority is empty, then the command decides, see SetHigh.
+func (lanes *Lanes) Decide(command string, priority string) (bool, error) {
+	switch priority {
+	case "":
+		return lanes.IsHigh(command), nil
+	case High:
+		return true, nil
+	case Low:
+		return false, nil
+	}
+	return false, fmt.Errorf("the '%s' priority is not '%s' or '%s'", priority, High, Low)
+}
+
+// Running returns true if the workers are running
+func (lanes *Lanes) Running() bool {
+	lanes.mu.RLock()
+	defer lanes.mu.RUnlock()
+
+	return lanes.running
+}
+
+// Start the workers in the background
+func (lanes *Lanes) Start() error {
+	lanes.mu.Lock()
+	defer lanes.mu.Unlock()
+
+	if lanes.running {
+		return fmt.Errorf("already running")
+	}
+	lanes.stop = make(chan struct{})
+	lanes.running = true
+
+	for i := 0; i < lanes.workers; i++ {
+		go lanes.work(lanes.stop)
+	}
+
+	return nil
+}
+
+// Close stops the workers.
+// The waiting jobs are not executed.
+func (lanes *Lanes) Close() error {
+	lanes.mu.Lock()
+	defer lanes.mu.Unlock()
+
+	if !lanes.running {
+		return fmt.Errorf("not running")
+	}
+	close(lanes.stop)
+	lanes.running = false
+
+	return nil
+}
+
+// The work executes the jobs.
+// The low-priority job is taken only if the high-priority lane is empty.
+func (lanes *Lanes) work(stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case j := <-lanes.high:
+			j.run()
+			close(j.done)
+			continue
+		default:
+		}
+
+		select {
+		case <-stop:
+			return
+		case j := <-lanes.high:
+			j.run()
+			close(j.done)
+		case j := <-lanes.low:
+			j.run()
+			close(j.done)
+		}
+	}
+}
+
+// Do puts the job into the lane and waits until it's executed.
+// Returns an error if the lanes are not running or the lane is full.
+func (lanes *Lanes) Do(high bool, run func()) error {
+	lanes.mu.RLock()
+	running := lanes.running
+	stop := lanes.stop
+	lanes.mu.RUnlock()
+	if !running {
+		return fmt.Errorf("lanes are not running")
+	}
+
+	j := job{run: run, done: make(chan struct{})}
+	lane := lanes.low
+	if high {
+		lane = lanes.high
+	}
+
+	select {
+	case lane <- j:
+	default:
+		return fmt.Errorf("lane is full")
+	}
+
+	select {
+	case <-j.done:
+		return nil
+	case <-stop:
+		return fmt.Errorf("lanes closed")
+	}
+}
+
+// Route wraps the route function, so it's executed in the lane of the command.
+// The onErr returns the reply when the request can not be queued.
+func Route[Req any, Rep any](lanes *Lanes, command string, handle func(Req) Rep, onErr func(Req, error) Rep) func(Req) Rep {
+	return RouteBy(lanes, func(Req) bool {
+		return lanes.IsHigh(command)
+	}, handle, onErr)
+}
+
+// RouteBy wraps the route function, so it's executed in the lane decided per request by isHigh.
+// The onErr returns the reply when the request can not be queued.
+func RouteBy[Req any, Rep any](lanes *Lanes, isHigh func(Req) bool, handle func(Req) Rep, onErr func(Req, error) Rep) func(Req) Rep {
+	return func(req Req) Rep {
+		var reply Rep
+		err := lanes.Do(isHigh(req), func() {
+			reply = handle(req)
+		})
+		if err != nil {
+			return onErr(req, err)
+		}
+		return reply
+	}
+}
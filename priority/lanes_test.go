@@ -0,0 +1,119 @@
+package priority
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestLanesSuite struct {
+	suite.Suite
+}
+
+// Test_10_Route tests that the high-priority requests are processed first
+func (test *TestLanesSuite) Test_10_Route() {
+	s := test.Require
+
+	lanes := New(1)
+	lanes.SetHigh("heartbeat")
+	s().True(lanes.IsHigh("heartbeat"))
+	s().False(lanes.IsHigh("bulk"))
+
+	s().Error(lanes.Do(false, func() {}))
+
+	var order []string
+	var mu sync.Mutex
+	block := make(chan struct{})
+
+	handle := func(req string) string {
+		if req == "blocker" {
+			<-block
+		}
+		mu.Lock()
+		order = append(order, req)
+		mu.Unlock()
+		return req
+	}
+	onErr := func(req string, err error) string {
+		return fmt.Sprintf("%s: %v", req, err)
+	}
+	bulk := Route(lanes, "bulk", handle, onErr)
+	heartbeat := Route(lanes, "heartbeat", handle, onErr)
+
+	s().NoError(lanes.Start())
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	// occupy the only worker
+	go func() {
+		defer wg.Done()
+		s().Equal("blocker", bulk("blocker"))
+	}()
+	time.Sleep(time.Millisecond * 50)
+
+	go func() {
+		defer wg.Done()
+		bulk("bulk_1")
+	}()
+	go func() {
+		defer wg.Done()
+		bulk("bulk_2")
+	}()
+	time.Sleep(time.Millisecond * 50)
+	go func() {
+		defer wg.Done()
+		heartbeat("heartbeat")
+	}()
+	time.Sleep(time.Millisecond * 50)
+
+	close(block)
+	wg.Wait()
+
+	s().Equal("blocker", order[0])
+	s().Equal("heartbeat", order[1])
+
+	s().NoError(lanes.Close())
+	s().Error(lanes.Close())
+	s().Contains(bulk("late"), "not running")
+}
+
+// Test_11_Decide tests the per-request priority overriding the command's priority
+func (test *TestLanesSuite) Test_11_Decide() {
+	s := test.Require
+
+	lanes := New(1)
+	lanes.SetHigh("heartbeat")
+
+	high, err := lanes.Decide("heartbeat", "")
+	s().NoError(err)
+	s().True(high)
+	high, err = lanes.Decide("bulk", "")
+	s().NoError(err)
+	s().False(high)
+
+	high, err = lanes.Decide("bulk", High)
+	s().NoError(err)
+	s().True(high)
+	high, err = lanes.Decide("heartbeat", Low)
+	s().NoError(err)
+	s().False(high)
+
+	_, err = lanes.Decide("bulk", "urgent")
+	s().Error(err)
+
+	s().False(lanes.Running())
+	s().NoError(lanes.Start())
+	s().True(lanes.Running())
+	s().NoError(lanes.Close())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestLanes(t *testing.T) {
+	suite.Run(t, new(TestLanesSuite))
+}
@@ -11,8 +11,19 @@ import (
 	handlerConfig "github.com/ahmetson/handler-lib/config"
 	"github.com/ahmetson/handler-lib/replier"
 	"github.com/ahmetson/handler-lib/sync_replier"
+	"github.com/ahmetson/service-lib/capture"
+	"github.com/ahmetson/service-lib/chaos"
+	"github.com/ahmetson/service-lib/codec"
+	"github.com/ahmetson/service-lib/errchain"
+	"github.com/ahmetson/service-lib/errs"
+	"github.com/ahmetson/service-lib/manager"
+	"github.com/ahmetson/service-lib/payload"
+	"github.com/ahmetson/service-lib/sizelimit"
+	"github.com/ahmetson/service-lib/tap"
 	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type RequestHandleFunc = func(handlerId string, req message.RequestInterface) (message.RequestInterface, error)
@@ -26,22 +37,34 @@ type Proxy struct {
 	onRequest       RequestHandleFunc
 	onReply         ReplyHandleFunc
 	handlerWrappers map[string]*HandlerWrapper
+	balancers       map[string]*balancer                                // the destination instances by the proxy handler category and command
+	balancersMu     sync.Mutex                                          // the balancers are set by routeHandlers while the routes read them
+	routed          map[string]bool                                     // the commands routed by the proxy handler key and command
+	sizeLimits      sizelimit.Limits                                    // the oversize requests and replies are not forwarded
+	recorder        *capture.Recorder                                   // records the forwarded requests, optional
+	sampler         *payload.Sampler                                    // selects the logged payloads, optional
+	redactor        *payload.Redactor                                   // hides the secrets in the logged payloads
 	handlers        map[handlerConfig.HandlerType]func() base.Interface // todo add support of the trigger
+	parentDraining  atomic.Bool                                         // the parent announced the shutdown, and didn't reconnect yet
 }
 
 type HandlerWrapper struct {
 	destConfig *handlerConfig.Handler
 	destClient *client.Socket
+	mu         sync.Mutex
+	failedAt   time.Time // when the destination instance failed to respond, zero if alive
+	started    bool      // the destination handler was asked to start, in case it's lazy
 }
 
 // NewProxy proxy parent returned
-func NewProxy() (*Proxy, error) {
-	auxiliary, err := NewAuxiliary()
+func NewProxy(opts ...Option) (*Proxy, error) {
+	auxiliary, err := NewAuxiliary(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("parent.NewAuxiliary: %w", err)
 	}
 
 	auxiliary.Type = service.ProxyType
+	auxiliary.taps = tap.NewRegistry(nil)
 
 	handlers := make(map[handlerConfig.HandlerType]func() base.Interface, 0)
 	handlers[handlerConfig.SyncReplierType] = func() base.Interface {
@@ -50,6 +73,9 @@ func NewProxy() (*Proxy, error) {
 	handlers[handlerConfig.ReplierType] = func() base.Interface {
 		return replier.New()
 	}
+	for _, handlerType := range registeredTypes() {
+		handlers[handlerType.Name] = handlerType.New
+	}
 
 	return &Proxy{
 		auxiliary,
@@ -58,16 +84,52 @@ func NewProxy() (*Proxy, error) {
 		nil,
 		nil,
 		make(map[string]*HandlerWrapper),
+		make(map[string]*balancer),
+		sync.Mutex{},
+		make(map[string]bool),
+		sizelimit.DefaultLimits(),
+		nil,
+		nil,
+		nil,
 		handlers,
+		atomic.Bool{},
 	}, nil
 }
 
+// The fail returns the failed reply with the error chain started at this proxy.
+func (proxy *Proxy) fail(req message.RequestInterface, err error) message.ReplyInterface {
+	return req.Fail(errchain.New(proxy.id, req.CommandName(), err).Encode())
+}
+
+// The forwardFail returns the failed reply received from the destination with this proxy added to the error chain.
+func (proxy *Proxy) forwardFail(req message.RequestInterface, reply message.ReplyInterface) message.ReplyInterface {
+	return req.Fail(errchain.Forward(reply.ErrorMessage(), proxy.id, req.CommandName()).Encode())
+}
+
 // The routeWrapper is the proxy route that's invoked for all proxy units.
 // The route wrapper calls user functions for the requests or replies.
+//
+// The failed replies have the error chain, so the client knows which hop failed.
+//
+// Once the destination announced the shutdown, the requests are not forwarded until it's reachable again.
 func (proxy *Proxy) routeWrapper(handlerId string, req message.RequestInterface) message.ReplyInterface {
+	if proxy.parentDraining.Load() || (proxy.manager != nil && proxy.manager.Draining()) {
+		return proxy.fail(req, fmt.Errorf("destination is shutting down"))
+	}
+
 	handlerWrapper, ok := proxy.handlerWrappers[handlerId]
 	if !ok {
-		return req.Fail(fmt.Sprintf("internal error, proxy.handlerWrappers[%s] not found", handlerId))
+		return proxy.fail(req, fmt.Errorf("internal error, proxy.handlerWrappers[%s] not found", handlerId))
+	}
+	proxy.startDestination(handlerWrapper)
+	if err := chaos.Inject(req.CommandName()); err != nil {
+		return proxy.fail(req, err)
+	}
+	proxy.capture(handlerWrapper, req)
+	proxy.taps.Forward(tap.Request, handlerWrapper.destConfig.Category, req.CommandName(), req.RouteParameters().Map())
+	sampled := proxy.sampler != nil && proxy.sampler.Sample()
+	if sampled {
+		proxy.logPayload("request", handlerId, req.CommandName(), req.RouteParameters().Map())
 	}
 
 	var nextReq message.RequestInterface
@@ -75,25 +137,52 @@ func (proxy *Proxy) routeWrapper(handlerId string, req message.RequestInterface)
 		parsedReq, err := proxy.onRequest(handlerId, req)
 		// check failed
 		if err != nil {
-			return req.Fail(fmt.Sprintf("onRequest(%s): %v", handlerId, err))
+			return proxy.fail(req, fmt.Errorf("onRequest(%s): %w", handlerId, err))
 		}
 		nextReq = parsedReq
 		nextReq.SetConId(req.ConId())
 	} else {
 		nextReq = req
 	}
-	if !handlerConfig.CanReply(handlerWrapper.destConfig.Type) {
-		err := handlerWrapper.destClient.Submit(nextReq)
+	// the request is encoded once, the size is checked on the data that is sent
+	raw, err := encodeRequest(nextReq)
+	if err != nil {
+		return proxy.fail(nextReq, fmt.Errorf("encodeRequest: %w", err))
+	}
+	if err := sizelimit.CheckSize("request", len(raw), proxy.sizeLimits.Request); err != nil {
+		return proxy.fail(nextReq, err)
+	}
+	if !handlerConfig.CanReply(baseType(handlerWrapper.destConfig.Type)) {
+		err := handlerWrapper.destClient.RawSubmit(raw)
 		if err != nil {
-			return nextReq.Fail(fmt.Sprintf("handler %s not replieable, submit failed as for req %v: %v", handlerId, nextReq, err))
+			handlerWrapper.markFailed()
+			return proxy.fail(nextReq, fmt.Errorf("handler %s not replieable, submit failed as for req %v: %w", handlerId, nextReq, err))
 		}
+		handlerWrapper.markAlive()
 		return nextReq.Ok(key_value.New())
 	}
-	reply, err := handlerWrapper.destClient.Request(nextReq)
+	rawReply, err := handlerWrapper.destClient.RawRequest(raw)
+	if err != nil {
+		handlerWrapper.markFailed()
+		return proxy.fail(nextReq, fmt.Errorf("handlerWrapper.destClient(handlerId='%s', req=%v): %w", handlerId, nextReq, err))
+	}
+	handlerWrapper.markAlive()
+	if err := sizelimit.CheckSize("reply", rawSize(rawReply), proxy.sizeLimits.Reply); err != nil {
+		return proxy.fail(nextReq, err)
+	}
+	reply, err := message.NewRep(rawReply)
 	if err != nil {
-		return nextReq.Fail(fmt.Sprintf("handlerWrapper.destClient(handlerId='%s', req=%v): %v", handlerId, nextReq, err))
+		return proxy.fail(nextReq, fmt.Errorf("message.NewRep: %w", err))
+	}
+	nextReq.SyncTrace(reply)
+	proxy.taps.Forward(tap.Reply, handlerWrapper.destConfig.Category, nextReq.CommandName(), reply.ReplyParameters().Map())
+	if sampled {
+		proxy.logPayload("reply", handlerId, nextReq.CommandName(), reply.ReplyParameters().Map())
 	}
 	if proxy.onReply == nil {
+		if !reply.IsOK() {
+			return proxy.forwardFail(nextReq, reply)
+		}
 		reply.SetConId(req.ConId())
 		return reply
 	}
@@ -101,7 +190,7 @@ func (proxy *Proxy) routeWrapper(handlerId string, req message.RequestInterface)
 	parsedReply, err := proxy.onReply(handlerId, nextReq, reply)
 	// check failed
 	if err != nil {
-		return nextReq.Fail(fmt.Sprintf("onReply(handlerId='%s', 'request'='%v', reply='%v'): %v", handlerId,
+		return proxy.fail(nextReq, fmt.Errorf("onReply(handlerId='%s', 'request'='%v', reply='%v'): %w", handlerId,
 			req, reply, err))
 	}
 	parsedReply.SetConId(nextReq.ConId())
@@ -109,6 +198,77 @@ func (proxy *Proxy) routeWrapper(handlerId string, req message.RequestInterface)
 	return parsedReply
 }
 
+// The encodeRequest returns the JSON of the forwarded request.
+// The default requests are encoded by the pooled codec, the raw requests keep their own encoding.
+func encodeRequest(req message.RequestInterface) (string, error) {
+	defaultReq, ok := req.(*message.Request)
+	if !ok {
+		data, err := req.Bytes()
+		if err != nil {
+			return "", fmt.Errorf("req.Bytes: %w", err)
+		}
+		return string(data), nil
+	}
+
+	if err := message.ValidCommand(defaultReq.Command); err != nil {
+		return "", fmt.Errorf("message.ValidCommand: %w", err)
+	}
+	data, err := codec.Marshal(defaultReq)
+	if err != nil {
+		return "", fmt.Errorf("codec.Marshal: %w", err)
+	}
+	return string(data), nil
+}
+
+// The rawSize returns the size of the message received in the frames
+func rawSize(frames []string) int {
+	size := 0
+	for _, frame := range frames {
+		size += len(frame)
+	}
+	return size
+}
+
+// The capture records the request for the replay, if the capture mode is enabled.
+func (proxy *Proxy) capture(handlerWrapper *HandlerWrapper, req message.RequestInterface) {
+	if proxy.recorder == nil {
+		return
+	}
+	record := capture.Record{
+		Category:   handlerWrapper.destConfig.Category,
+		Command:    req.CommandName(),
+		Source:     req.ConId(),
+		Parameters: req.RouteParameters().Map(),
+	}
+	if err := proxy.recorder.Record(record); err != nil {
+		proxy.Logger.Warn("recorder.Record", "command", record.Command, "error", err)
+	}
+}
+
+// The logPayload writes the redacted payload into the log.
+func (proxy *Proxy) logPayload(kind string, handlerId string, command string, parameters map[string]interface{}) {
+	proxy.Logger.Info("payload", "kind", kind, "handler", handlerId, "command", command,
+		"parameters", proxy.redactor.Redact(parameters))
+}
+
+// The startDestination asks the parent to start the destination handler before the first request.
+// The lazy handlers are started by the parent on demand, the other handlers are running already.
+//
+// The failure is not returned, as the destination may be not lazy, or not managed by the parent.
+func (proxy *Proxy) startDestination(handlerWrapper *HandlerWrapper) {
+	handlerWrapper.mu.Lock()
+	defer handlerWrapper.mu.Unlock()
+
+	if handlerWrapper.started || proxy.ParentManager == nil {
+		return
+	}
+	handlerWrapper.started = true
+
+	if err := proxy.ParentManager.StartHandler(handlerWrapper.destConfig.Category); err != nil {
+		proxy.Logger.Warn("ParentManager.StartHandler", "category", handlerWrapper.destConfig.Category, "error", err)
+	}
+}
+
 func (proxy *Proxy) SetHandlerDefiner(handlerType handlerConfig.HandlerType, definer func() base.Interface) {
 	proxy.handlers[handlerType] = definer
 }
@@ -116,6 +276,36 @@ func (proxy *Proxy) SetHandlerDefiner(handlerType handlerConfig.HandlerType, def
 // SetHandler is disabled as the proxy returns them from the parent
 func (proxy *Proxy) SetHandler(_ string, _ base.Interface) {}
 
+// SetSizeLimits sets the limits of the forwarded requests and replies.
+// By default, sizelimit.DefaultLimits.
+func (proxy *Proxy) SetSizeLimits(limits sizelimit.Limits) {
+	proxy.sizeLimits = limits
+}
+
+// SetCapture enables the capture mode.
+// The forwarded requests are recorded into the dir per destination category.
+// Replay them by capture.Replay.
+func (proxy *Proxy) SetCapture(dir string) error {
+	recorder, err := capture.NewRecorder(dir)
+	if err != nil {
+		return fmt.Errorf("capture.NewRecorder: %w", err)
+	}
+	proxy.recorder = recorder
+	return nil
+}
+
+// SetPayloadLog logs 1 in every forwarded requests and their replies.
+// The fields matching payload.DefaultPatterns and the given patterns are redacted.
+func (proxy *Proxy) SetPayloadLog(every uint64, patterns ...string) error {
+	redactor, err := payload.NewRedactor(patterns...)
+	if err != nil {
+		return fmt.Errorf("payload.NewRedactor: %w", err)
+	}
+	proxy.redactor = redactor
+	proxy.sampler = payload.NewSampler(every)
+	return nil
+}
+
 // SetRequestHandler sets the requests function defined by the user.
 func (proxy *Proxy) SetRequestHandler(onRequest RequestHandleFunc) error {
 	if proxy.onRequest != nil {
@@ -136,7 +326,16 @@ func (proxy *Proxy) SetReplyHandler(onReply ReplyHandleFunc) error {
 
 // Todo maybe to call routeHandlers after setting the config?
 // So that handlers will have their own generated id?
+//
+// If multiple instances of the destination have the units with the same command,
+// then the command is routed once by each proxy handler, and the requests are balanced among the instances.
+// The latency and result of the requests are observed by the objectives of the destination routes, see SetSlo.
+//
+// The units are all units of the destinations.
+// The balancers of the commands missing in the units are removed, so their routes fail.
 func (proxy *Proxy) routeHandlers(units []*service.Unit) error {
+	instances := make(map[string][]string, len(units))
+
 	// Set up the route for each handler
 	for _, unitRef := range units {
 		// todo make sure if unit is changed, then unitRef is called by reference
@@ -148,20 +347,36 @@ func (proxy *Proxy) routeHandlers(units []*service.Unit) error {
 				"handlers", proxy.Handlers)
 			continue
 		}
-		category := proxy.id + handlerWrapper.destConfig.Category
-		handler, ok := proxy.Handlers[category].(base.Interface)
+		handlerKey := proxy.handlerKey(handlerWrapper.destConfig)
+		handler, ok := proxy.Handlers[handlerKey].(base.Interface)
 		if !ok {
-			return fmt.Errorf(fmt.Sprintf("unit handler by category not found, category=%s, wrappers amount=%d, handler id='%s'",
-				category, len(proxy.handlerWrappers), unit.HandlerId))
+			return fmt.Errorf(fmt.Sprintf("unit handler by key not found, key=%s, wrappers amount=%d, handler id='%s'",
+				handlerKey, len(proxy.handlerWrappers), unit.HandlerId))
+		}
+
+		key := balancerKey(proxy.id+handlerWrapper.destConfig.Category, unit.Command)
+		if !slices.Contains(instances[key], unit.HandlerId) {
+			instances[key] = append(instances[key], unit.HandlerId)
 		}
 
+		routedKey := balancerKey(handlerKey, unit.Command)
+		if proxy.routed[routedKey] {
+			continue
+		}
+		proxy.routed[routedKey] = true
+
+		destCategory := handlerWrapper.destConfig.Category
 		err := handler.Route(unit.Command, func(request message.RequestInterface) message.ReplyInterface {
-			return proxy.routeWrapper(unit.HandlerId, request)
+			started := time.Now()
+			reply := proxy.balancedRoute(key, request)
+			proxy.objectives.Observe(destCategory, request.CommandName(), time.Since(started), !reply.IsOK())
+			return reply
 		})
 		if err != nil {
 			return fmt.Errorf("handler.Route(unit=%v): %w", unit, err)
 		}
 	}
+	proxy.setBalancers(instances)
 
 	return nil
 }
@@ -181,10 +396,14 @@ func (proxy *Proxy) setProxyUnits() error {
 
 	parentClient := proxy.ParentManager
 
-	// set the proxy destination units for each rule
+	// the units of all rules are fetched from the parent, then set together, see setAllUnits.
+	// The parent's client is one socket, so the units are fetched one by one.
+	rules := make([]*service.Rule, 0, len(proxyChains)+1)
+	allRules := make([]*service.Rule, 0, len(proxyChains)+1)
 	for _, proxyChain := range proxyChains {
 		// the last proxy in the list is removed as its this parent
 		rule := proxyChain.Destination
+		allRules = append(allRules, rule)
 
 		// For proxy chains set specifically for this proxy, then simply get the proxies
 		if slices.Contains(rule.Urls, proxy.url) {
@@ -194,36 +413,35 @@ func (proxy *Proxy) setProxyUnits() error {
 			}
 			continue
 		}
-
-		units, err := parentClient.Units(rule)
-		if err != nil {
-			return fmt.Errorf("destClient.Units('%v'): %w", rule, err)
-		}
-		if err := proxyClient.SetUnits(rule, units); err != nil {
-			return fmt.Errorf("proxyClient.SetUnits('%v'): %w", rule, err)
-		}
-
-		err = proxy.routeHandlers(units)
-		if err != nil {
-			return fmt.Errorf("proxy.routeHandlers(rule='%v' from proxy chain=%v): %w", rule, proxyChain, err)
-		}
+		rules = append(rules, rule)
 	}
-
 	if proxy.rule != nil {
-		rule := proxy.rule
+		rules = append(rules, proxy.rule)
+		allRules = append(allRules, proxy.rule)
+	}
+	if err := proxy.withdrawStaleUnits(allRules); err != nil {
+		return fmt.Errorf("proxy.withdrawStaleUnits: %w", err)
+	}
 
-		units, err := parentClient.Units(rule)
+	units := make([][]*service.Unit, len(rules))
+	for i, rule := range rules {
+		ruleUnits, err := parentClient.Units(rule)
 		if err != nil {
 			return fmt.Errorf("destClient.Units('%v'): %w", rule, err)
 		}
-		if err := proxyClient.SetUnits(rule, units); err != nil {
-			return fmt.Errorf("proxyClient.SetUnits('%v'): %w", rule, err)
-		}
+		units[i] = ruleUnits
+	}
+	if err := proxy.setAllUnits(rules, units); err != nil {
+		return fmt.Errorf("proxy.setAllUnits: %w", err)
+	}
 
-		err = proxy.routeHandlers(units)
-		if err != nil {
-			return fmt.Errorf("proxy.routeHandlers(rule='%v' from proxy.rule): %w", rule, err)
-		}
+	// the balancers are set by the units of all rules, so the units of one rule don't remove the others.
+	allUnits := make([]*service.Unit, 0, len(units))
+	for i := range rules {
+		allUnits = append(allUnits, units[i]...)
+	}
+	if err := proxy.routeHandlers(allUnits); err != nil {
+		return fmt.Errorf("proxy.routeHandlers: %w", err)
 	}
 
 	return nil
@@ -302,6 +520,7 @@ func (proxy *Proxy) destination() (*service.Rule, error) {
 // Proxy supports:
 //   - replier
 //   - sync_replier
+//   - the types registered by RegisterHandlerType
 func (proxy *Proxy) lintHandlers() error {
 	destination, err := proxy.destination()
 	if err != nil {
@@ -315,7 +534,7 @@ func (proxy *Proxy) lintHandlers() error {
 	if len(handlerConfigs) == 0 {
 		return fmt.Errorf("proxy.ParentManager.HandlersByRule(rule='%v', parentId='%s'): no handler configs", destination, proxy.id)
 	}
-	slices.CompactFunc(handlerConfigs, func(x, y *handlerConfig.Handler) bool {
+	handlerConfigs = slices.CompactFunc(handlerConfigs, func(x, y *handlerConfig.Handler) bool {
 		return x.Id == y.Id
 	})
 
@@ -329,12 +548,11 @@ func (proxy *Proxy) lintHandlers() error {
 			definer = unknown
 		}
 		h := definer()
-		// todo use the proxy category; when generating a proxy parentId,
-		// it needs to over-write the generateConfig method of the parent to set a new parentId.
-		proxy.Auxiliary.SetHandler(proxy.id+handlerConfigs[i].Category, h)
+		// each destination handler has its own proxy handler, even if they share the category.
+		proxy.Auxiliary.SetHandlerById(proxy.handlerKey(handlerConfigs[i]), proxy.id+handlerConfigs[i].Category, h)
 
 		// could lead to unexpected behavior if there are multiple urls
-		parentZmqType := handlerConfig.SocketType(handlerConfigs[i].Type)
+		parentZmqType := handlerConfig.SocketType(baseType(handlerConfigs[i].Type))
 		parentClientConf := clientConfig.New(destination.Urls[0], handlerConfigs[i].Id, handlerConfigs[i].Port, parentZmqType)
 		parentClientConf.UrlFunc(clientConfig.Url)
 		parentClient, err := client.New(parentClientConf)
@@ -352,6 +570,37 @@ func (proxy *Proxy) lintHandlers() error {
 	return nil
 }
 
+// The awaitParent pauses the forwarding while the parent is shutting down.
+// After the drain period, the parent is checked by the heartbeat until it's reachable again, for example, restarted.
+// Then the forwarding resumes.
+// It stops when the manager is closed.
+func (proxy *Proxy) awaitParent(parentId string) {
+	proxy.parentDraining.Store(true)
+
+	go func() {
+		time.Sleep(manager.DrainPeriod)
+		for proxy.manager != nil && proxy.manager.Running() {
+			parentManager, err := manager.NewClient(proxy.ParentConfig)
+			if err == nil {
+				err = parentManager.Heartbeat()
+				_ = parentManager.Socket.Close()
+			}
+			if err == nil {
+				proxy.manager.ResetParent(parentId)
+				proxy.parentDraining.Store(false)
+				proxy.Logger.Info("parent reconnected, forwarding resumed", "parent", parentId)
+				return
+			}
+			time.Sleep(HealthInterval)
+		}
+	}()
+}
+
+// handlerKey returns the key of the proxy handler in the Handlers by the destination handler
+func (proxy *Proxy) handlerKey(destConfig *handlerConfig.Handler) string {
+	return proxy.id + destConfig.Id
+}
+
 // Start the proxy.
 //
 // Proxy can start without the parent.
@@ -362,20 +611,14 @@ func (proxy *Proxy) Start() (*sync.WaitGroup, error) {
 	if !proxy.ctx.IsDepManagerRunning() {
 		if err := proxy.ctx.StartDepManager(); err != nil {
 			err = fmt.Errorf("ctx.StartDepManager: %w", err)
-			if closeErr := proxy.ctx.Close(); closeErr != nil {
-				return nil, fmt.Errorf("%v: cleanout context: %w", err, closeErr)
-			}
-			return nil, err
+			return nil, errs.Join(err, errs.Wrap("cleanout context", proxy.ctx.Close()))
 		}
 	}
 
 	if !proxy.ctx.IsProxyHandlerRunning() {
 		if err := proxy.ctx.StartProxyHandler(); err != nil {
 			err = fmt.Errorf("ctx.StartProxyHandler: %w", err)
-			if closeErr := proxy.ctx.Close(); closeErr != nil {
-				return nil, fmt.Errorf("%v: cleanout context: %w", err, closeErr)
-			}
-			return nil, err
+			return nil, errs.Join(err, errs.Wrap("cleanout context", proxy.ctx.Close()))
 		}
 	}
 
@@ -407,6 +650,7 @@ func (proxy *Proxy) Start() (*sync.WaitGroup, error) {
 	if err != nil {
 		return nil, fmt.Errorf("proxy.Auxiliary.Start: %w", err)
 	}
+	proxy.manager.OnParentShuttingDown(proxy.awaitParent)
 
 	// send to the parent info that it was set.
 	rule, _ := proxy.destination()
@@ -1310,7 +1310,7 @@ func (test *TestProxySuite) Test_17_Proxy_routeHandlers() {
 
 	s().NotEmpty(proxy.Handlers)
 	s().NotEmpty(proxy.handlerWrappers)
-	raw, ok := proxy.Handlers[test.id+test.categories[0]]
+	raw, ok := proxy.Handlers[test.id+test.handlerConfigs[0].Id]
 	s().True(ok)
 	handler := raw.(base.Interface)
 	s().NotEmpty(handler.RouteCommands())
@@ -1328,7 +1328,7 @@ func (test *TestProxySuite) Test_17_Proxy_routeHandlers() {
 	err = proxy.startHandlers()
 	s().NoError(err)
 
-	raw, ok = proxy.Handlers[test.id+test.categories[0]]
+	raw, ok = proxy.Handlers[test.id+test.handlerConfigs[0].Id]
 	s().True(ok)
 	handler = raw.(base.Interface)
 	s().NotEmpty(handler.RouteCommands())
@@ -1340,7 +1340,7 @@ func (test *TestProxySuite) Test_17_Proxy_routeHandlers() {
 	client1, err := client.New(clientConf1)
 	s().NoError(err)
 
-	raw, ok = proxy.Handlers[test.id+test.categories[1]]
+	raw, ok = proxy.Handlers[test.id+test.handlerConfigs[1].Id]
 	s().True(ok)
 	handler = raw.(base.Interface)
 	s().NotEmpty(handler.RouteCommands())
@@ -1351,7 +1351,7 @@ func (test *TestProxySuite) Test_17_Proxy_routeHandlers() {
 	//req to router doesn't work, test it
 	s().NoError(err)
 
-	raw, ok = proxy.Handlers[test.id+test.categories[2]]
+	raw, ok = proxy.Handlers[test.id+test.handlerConfigs[2].Id]
 	s().True(ok)
 	handler = raw.(base.Interface)
 	s().NotEmpty(handler.RouteCommands())
@@ -1486,6 +1486,69 @@ func (test *TestProxySuite) Test_17_Proxy_routeHandlers() {
 	time.Sleep(time.Millisecond * 100)
 }
 
+// Test_18_Proxy_balancer tests the rotation of the destination instances
+func (test *TestProxySuite) Test_18_Proxy_balancer() {
+	s := test.Require
+
+	b := newBalancer()
+	s().Empty(b.order())
+
+	b.add("instance_1")
+	b.add("instance_2")
+	b.add("instance_1") // duplicate is ignored
+
+	s().Equal([]string{"instance_1", "instance_2"}, b.order())
+	s().Equal([]string{"instance_2", "instance_1"}, b.order())
+	s().Equal([]string{"instance_1", "instance_2"}, b.order())
+
+	// the failed instance is dead until the DeadTimeout passes
+	wrapper := &HandlerWrapper{}
+	s().True(wrapper.alive())
+	wrapper.markFailed()
+	s().False(wrapper.alive())
+	wrapper.markAlive()
+	s().True(wrapper.alive())
+}
+
+// Test_19_Proxy_setBalancers tests that the balancers of the destinations without the units are removed
+func (test *TestProxySuite) Test_19_Proxy_setBalancers() {
+	s := test.Require
+
+	b := newBalancer()
+	b.set([]string{"instance_1", "instance_2", "instance_3"})
+	s().Equal([]string{"instance_1", "instance_2", "instance_3"}, b.order())
+	s().Equal([]string{"instance_2", "instance_3", "instance_1"}, b.order())
+	s().Equal([]string{"instance_3", "instance_1", "instance_2"}, b.order())
+
+	// the rotation continues within the remaining instances
+	b.set([]string{"instance_1", "instance_2"})
+	s().Equal([]string{"instance_1", "instance_2"}, b.order())
+
+	proxy := &Proxy{balancers: make(map[string]*balancer)}
+	proxy.setBalancers(map[string][]string{
+		balancerKey("main", "hello"): {"instance_1"},
+		balancerKey("main", "bye"):   {"instance_1", "instance_2"},
+	})
+	s().Len(proxy.balancers, 2)
+	hello := proxy.balancers[balancerKey("main", "hello")]
+
+	// the kept balancer is updated, the missing one is removed
+	proxy.setBalancers(map[string][]string{
+		balancerKey("main", "hello"): {"instance_2"},
+	})
+	s().Len(proxy.balancers, 1)
+	s().Same(hello, proxy.balancers[balancerKey("main", "hello")])
+	s().Equal([]string{"instance_2"}, hello.order())
+
+	req := &message.Request{Command: "bye", Parameters: key_value.New()}
+	reply := proxy.balancedRoute(balancerKey("main", "bye"), req)
+	s().False(reply.IsOK())
+	s().Contains(reply.ErrorMessage(), "no destination instances")
+
+	proxy.setBalancers(map[string][]string{})
+	s().Empty(proxy.balancers)
+}
+
 func TestProxy(t *testing.T) {
 	suite.Run(t, new(TestProxySuite))
 }
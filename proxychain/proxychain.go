@@ -0,0 +1,139 @@
+// Package proxychain builds the validated proxy chains from the presets.
+//
+// Instead of the variadic Service.SetProxyChain parameters:
+//
+//	chain, err := proxychain.New().
+//		WithAuth("github.com/ahmetson/auth-proxy").
+//		WithRateLimit("github.com/ahmetson/rate-limit-proxy").
+//		To(serviceConfig.NewServiceDestination(url))
+//	err = independent.SetProxyChain(chain)
+//
+// The proxies are in the order the request passes them.
+package proxychain
+
+import (
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"path"
+)
+
+// The categories of the preset proxies
+const (
+	Auth      = "auth"
+	RateLimit = "rate-limit"
+	Fanout    = "fanout"
+	Tap       = "tap"
+)
+
+// Builder of the proxy chain
+type Builder struct {
+	sources []string
+	proxies []*serviceConfig.Proxy
+	err     error
+}
+
+// New returns an empty builder
+func New() *Builder {
+	return &Builder{proxies: make([]*serviceConfig.Proxy, 0)}
+}
+
+// From limits the chain to the requests from the source services by their urls.
+// By default, the chain applies to all sources.
+func (builder *Builder) From(sourceUrls ...string) *Builder {
+	builder.sources = append(builder.sources, sourceUrls...)
+	return builder
+}
+
+// With adds the proxy of the category by its url.
+// The proxy id is derived from the category and the url.
+func (builder *Builder) With(category string, url string) *Builder {
+	if builder.err != nil {
+		return builder
+	}
+	if len(category) == 0 || len(url) == 0 {
+		builder.err = fmt.Errorf("the proxy category and url are required")
+		return builder
+	}
+
+	id := category + "." + path.Base(url)
+	for _, proxy := range builder.proxies {
+		if proxy.Id == id {
+			builder.err = fmt.Errorf("the '%s' proxy is added twice", id)
+			return builder
+		}
+	}
+
+	builder.proxies = append(builder.proxies, &serviceConfig.Proxy{
+		Local:    &serviceConfig.Local{},
+		Id:       id,
+		Url:      url,
+		Category: category,
+	})
+	return builder
+}
+
+// WithAuth adds the proxy that authenticates the requests
+func (builder *Builder) WithAuth(url string) *Builder {
+	return builder.With(Auth, url)
+}
+
+// WithRateLimit adds the proxy that limits the rate of the requests
+func (builder *Builder) WithRateLimit(url string) *Builder {
+	return builder.With(RateLimit, url)
+}
+
+// WithFanout adds the proxy that sends the request to multiple destinations
+func (builder *Builder) WithFanout(url string) *Builder {
+	return builder.With(Fanout, url)
+}
+
+// WithTap adds the proxy that logs the requests and replies without changing them
+func (builder *Builder) WithTap(url string) *Builder {
+	return builder.With(Tap, url)
+}
+
+// To returns the validated proxy chain to the destination.
+// If the destination has no urls, then Service.SetProxyChain sets the url of the service.
+func (builder *Builder) To(destination *serviceConfig.Rule) (*serviceConfig.ProxyChain, error) {
+	if builder.err != nil {
+		return nil, builder.err
+	}
+	if len(builder.proxies) == 0 {
+		return nil, fmt.Errorf("no proxies, add them by With")
+	}
+	if destination == nil {
+		return nil, fmt.Errorf("the destination is nil")
+	}
+
+	var params []interface{}
+	if len(builder.sources) > 0 {
+		params = append(params, builder.sources)
+	}
+	params = append(params, builder.proxies, destination)
+
+	proxyChain, err := serviceConfig.NewProxyChain(params...)
+	if err != nil {
+		return nil, fmt.Errorf("serviceConfig.NewProxyChain: %w", err)
+	}
+	return proxyChain, nil
+}
+
+// WithAuth returns the chain of the authentication proxy to the destination
+func WithAuth(url string, destination *serviceConfig.Rule) (*serviceConfig.ProxyChain, error) {
+	return New().WithAuth(url).To(destination)
+}
+
+// WithRateLimit returns the chain of the rate limiting proxy to the destination
+func WithRateLimit(url string, destination *serviceConfig.Rule) (*serviceConfig.ProxyChain, error) {
+	return New().WithRateLimit(url).To(destination)
+}
+
+// WithFanout returns the chain of the fan-out proxy to the destination
+func WithFanout(url string, destination *serviceConfig.Rule) (*serviceConfig.ProxyChain, error) {
+	return New().WithFanout(url).To(destination)
+}
+
+// WithTap returns the chain of the logging tap proxy to the destination
+func WithTap(url string, destination *serviceConfig.Rule) (*serviceConfig.ProxyChain, error) {
+	return New().WithTap(url).To(destination)
+}
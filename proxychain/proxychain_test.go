@@ -0,0 +1,127 @@
+package proxychain
+
+import (
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestProxyChainSuite struct {
+	suite.Suite
+
+	destination *serviceConfig.Rule
+}
+
+func (test *TestProxyChainSuite) SetupTest() {
+	test.destination = serviceConfig.NewServiceDestination("main")
+}
+
+// preset tests the chain of the single proxy returned by the preset
+func (test *TestProxyChainSuite) preset(build func(string, *serviceConfig.Rule) (*serviceConfig.ProxyChain, error), category string) {
+	s := test.Require
+
+	proxyChain, err := build("github.com/ahmetson/"+category+"-proxy", test.destination)
+	s().NoError(err)
+	s().Len(proxyChain.Proxies, 1)
+	s().Equal(category, proxyChain.Proxies[0].Category)
+	s().Equal(category+"."+category+"-proxy", proxyChain.Proxies[0].Id)
+	s().Equal("github.com/ahmetson/"+category+"-proxy", proxyChain.Proxies[0].Url)
+	s().NotNil(proxyChain.Proxies[0].Local)
+	s().Empty(proxyChain.Sources)
+	s().Equal(test.destination, proxyChain.Destination)
+
+	// the preset's parameters are validated too
+	_, err = build("", test.destination)
+	s().Error(err)
+	_, err = build("github.com/ahmetson/"+category+"-proxy", nil)
+	s().Error(err)
+}
+
+// Test_10_WithAuth tests the chain of the authentication proxy
+func (test *TestProxyChainSuite) Test_10_WithAuth() {
+	test.preset(WithAuth, Auth)
+}
+
+// Test_11_WithRateLimit tests the chain of the rate limiting proxy
+func (test *TestProxyChainSuite) Test_11_WithRateLimit() {
+	test.preset(WithRateLimit, RateLimit)
+}
+
+// Test_12_WithFanout tests the chain of the fan-out proxy
+func (test *TestProxyChainSuite) Test_12_WithFanout() {
+	test.preset(WithFanout, Fanout)
+}
+
+// Test_13_WithTap tests the chain of the logging tap proxy
+func (test *TestProxyChainSuite) Test_13_WithTap() {
+	test.preset(WithTap, Tap)
+}
+
+// Test_14_Builder tests the proxies kept in the order they were added, and the sources
+func (test *TestProxyChainSuite) Test_14_Builder() {
+	s := test.Require
+
+	proxyChain, err := New().
+		From("web", "mobile").
+		WithAuth("github.com/ahmetson/auth-proxy").
+		WithRateLimit("github.com/ahmetson/rate-limit-proxy").
+		With("cache", "github.com/ahmetson/cache-proxy").
+		To(test.destination)
+	s().NoError(err)
+	s().Equal([]string{"web", "mobile"}, proxyChain.Sources)
+	s().Len(proxyChain.Proxies, 3)
+	s().Equal(Auth, proxyChain.Proxies[0].Category)
+	s().Equal(RateLimit, proxyChain.Proxies[1].Category)
+	s().Equal("cache.cache-proxy", proxyChain.Proxies[2].Id)
+
+	// the same url in the different categories are different proxies
+	proxyChain, err = New().
+		WithAuth("github.com/ahmetson/proxy").
+		WithTap("github.com/ahmetson/proxy").
+		To(test.destination)
+	s().NoError(err)
+	s().Len(proxyChain.Proxies, 2)
+}
+
+// Test_15_Invalid tests rejecting the invalid chains
+func (test *TestProxyChainSuite) Test_15_Invalid() {
+	s := test.Require
+
+	// no proxies
+	_, err := New().To(test.destination)
+	s().Error(err)
+	_, err = New().From("web").To(test.destination)
+	s().Error(err)
+
+	// no category or url
+	_, err = New().With("", "github.com/ahmetson/auth-proxy").To(test.destination)
+	s().Error(err)
+	_, err = New().With(Auth, "").To(test.destination)
+	s().Error(err)
+
+	// the same proxy twice
+	_, err = New().
+		WithAuth("github.com/ahmetson/auth-proxy").
+		WithAuth("github.com/other/auth-proxy").
+		To(test.destination)
+	s().ErrorContains(err, "twice")
+
+	// no destination
+	_, err = New().WithAuth("github.com/ahmetson/auth-proxy").To(nil)
+	s().Error(err)
+
+	// the first error is kept, the later proxies are ignored
+	builder := New().With(Auth, "").WithTap("github.com/ahmetson/tap-proxy")
+	s().Empty(builder.proxies)
+	_, err = builder.To(test.destination)
+	s().ErrorContains(err, "required")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestProxyChain(t *testing.T) {
+	suite.Run(t, new(TestProxyChainSuite))
+}
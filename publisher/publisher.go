@@ -0,0 +1,227 @@
+// Package publisher sends the sequenced broadcasts to the subscribers.
+//
+// The Publisher wraps the Pub socket of the transport, see SetTransport.
+// The broadcasts are sequenced by the broadcast.Feed, then sent as [topic, JSON] frames,
+// so the subscriber.Subscriber parses them and requests the missed ones from the feed.
+// The HeartbeatTopic is published periodically to keep the subscribers alive when there are no broadcasts.
+//
+// With the CURVE keys, the publisher enforces the broadcast.ACL, see SetCurve and SetACL.
+// The ZAP handler authenticates the subscribers by their public keys,
+// and the subscriptions are authorized by ACL.CanSubscribe:
+// the subscriber never receives the protected topics it may not subscribe to.
+// The subscriptions are authorized when they are made, restart the publisher after changing the ACL.
+package publisher
+
+import (
+	"fmt"
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/codec"
+	"github.com/ahmetson/service-lib/subscriber"
+	"github.com/ahmetson/service-lib/transport"
+	"sync"
+	"time"
+)
+
+// HeartbeatInterval is the default interval of the heartbeats, a quarter of the subscriber's liveness
+const HeartbeatInterval = subscriber.Liveness / 4
+
+// Publisher sends the broadcasts of the feed
+type Publisher struct {
+	url       string
+	feed      *broadcast.Feed
+	transport transport.Transport
+	heartbeat time.Duration
+	curve     *transport.Curve // the CURVE keys of the publisher, optional
+	acl       *broadcast.ACL   // authorizes the topics of the CURVE identities, optional
+	socket    transport.Socket
+	stop      chan struct{}
+	done      chan struct{}
+	mu        sync.Mutex
+}
+
+// New returns a publisher bound to the url when started.
+// The broadcasts are sequenced by the feed.
+func New(url string, feed *broadcast.Feed) (*Publisher, error) {
+	if len(url) == 0 {
+		return nil, fmt.Errorf("the 'url' parameter is empty")
+	}
+	if feed == nil {
+		return nil, fmt.Errorf("the 'feed' parameter is nil")
+	}
+
+	return &Publisher{
+		url:       url,
+		feed:      feed,
+		heartbeat: HeartbeatInterval,
+	}, nil
+}
+
+// SetTransport sets the transport of the socket.
+// By default, it's transport.Default.
+// Call it before Start.
+func (pub *Publisher) SetTransport(t transport.Transport) {
+	pub.transport = t
+}
+
+// SetHeartbeat sets the interval of the heartbeats.
+// It must be less than the liveness of the subscribers.
+// Call it before Start.
+func (pub *Publisher) SetHeartbeat(interval time.Duration) {
+	pub.heartbeat = interval
+}
+
+// SetCurve binds the socket by the CURVE security mechanism.
+// The subscribers must connect with the publicKey as the server key.
+// Call it before Start.
+func (pub *Publisher) SetCurve(publicKey, secretKey string) {
+	pub.curve = &transport.Curve{PublicKey: publicKey, SecretKey: secretKey}
+}
+
+// SetACL authorizes the topics by the CURVE identities.
+// The feed refuses the topics the publisher's key may not publish,
+// and the subscribers receive only the topics their keys may subscribe to.
+// Start fails if the ACL is set without SetCurve, as the subscribers can't be identified.
+// Call it before Start.
+func (pub *Publisher) SetACL(acl *broadcast.ACL) {
+	pub.acl = acl
+}
+
+// Feed returns the feed sequencing the broadcasts
+func (pub *Publisher) Feed() *broadcast.Feed {
+	return pub.feed
+}
+
+// Url returns the endpoint of the socket.
+// If the publisher is bound to the port 0, then the url has the chosen port after Start.
+func (pub *Publisher) Url() string {
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+
+	if pub.socket != nil {
+		return pub.socket.Endpoint()
+	}
+	return pub.url
+}
+
+// Running returns true if the publisher's socket is bound
+func (pub *Publisher) Running() bool {
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+
+	return pub.socket != nil
+}
+
+// The subscriptionFilter allows the subscription if the ACL allows the identity the topic
+func (pub *Publisher) subscriptionFilter(identity string, topic string) bool {
+	return pub.acl.CanSubscribe(identity, topic) == nil
+}
+
+// Start binds the socket and sends the heartbeats in the background
+func (pub *Publisher) Start() error {
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+
+	if pub.socket != nil {
+		return fmt.Errorf("already running")
+	}
+	if pub.acl != nil && pub.curve == nil {
+		return fmt.Errorf("the ACL requires the CURVE keys to identify the subscribers, call SetCurve")
+	}
+	if pub.transport == nil {
+		pub.transport = transport.Default()
+	}
+
+	var opts []transport.Option
+	if pub.curve != nil {
+		opts = append(opts, transport.WithCurve(*pub.curve))
+		if pub.acl != nil {
+			opts = append(opts, transport.WithSubscriptionFilter(pub.subscriptionFilter))
+			pub.feed.SetACL(pub.acl, pub.curve.PublicKey)
+		}
+	}
+	socket, err := pub.transport.Bind(transport.Pub, pub.url, opts...)
+	if err != nil {
+		return fmt.Errorf("transport.Bind('%s'): %w", pub.url, err)
+	}
+
+	pub.socket = socket
+	pub.stop = make(chan struct{})
+	pub.done = make(chan struct{})
+	go pub.heartbeats(pub.stop, pub.done)
+
+	return nil
+}
+
+// The heartbeats sends the HeartbeatTopic every interval until stopped
+func (pub *Publisher) heartbeats(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(pub.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_ = pub.send([][]byte{[]byte(subscriber.HeartbeatTopic), []byte("{}")})
+		}
+	}
+}
+
+// send the frames by the socket.
+// The socket is used by one goroutine at a time.
+func (pub *Publisher) send(frames [][]byte) error {
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+
+	if pub.socket == nil {
+		return fmt.Errorf("not running")
+	}
+	return pub.socket.Send(frames)
+}
+
+// Publish sequences the broadcast by the feed, then sends it to the subscribers.
+// Returns ForbiddenError if the ACL doesn't allow the publisher the topic.
+func (pub *Publisher) Publish(topic string, parameters map[string]interface{}) (broadcast.Sequenced, error) {
+	if !pub.Running() {
+		return broadcast.Sequenced{}, fmt.Errorf("not running")
+	}
+	sequenced, err := pub.feed.Next(topic, parameters)
+	if err != nil {
+		return broadcast.Sequenced{}, fmt.Errorf("feed.Next('%s'): %w", topic, err)
+	}
+	payload, err := codec.Marshal(sequenced)
+	if err != nil {
+		return broadcast.Sequenced{}, fmt.Errorf("codec.Marshal: %w", err)
+	}
+	// the sequenced broadcast is in the feed, the subscribers catch it up if sending fails
+	if err := pub.send([][]byte{[]byte(topic), payload}); err != nil {
+		return sequenced, fmt.Errorf("socket.Send: %w", err)
+	}
+	return sequenced, nil
+}
+
+// Close stops the heartbeats and closes the socket
+func (pub *Publisher) Close() error {
+	pub.mu.Lock()
+	if pub.socket == nil {
+		pub.mu.Unlock()
+		return fmt.Errorf("not running")
+	}
+	stop, done := pub.stop, pub.done
+	pub.mu.Unlock()
+
+	close(stop)
+	<-done
+
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+
+	err := pub.socket.Close()
+	pub.socket = nil
+	if err != nil {
+		return fmt.Errorf("socket.Close: %w", err)
+	}
+	return nil
+}
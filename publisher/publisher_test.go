@@ -0,0 +1,121 @@
+package publisher
+
+import (
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/subscriber"
+	"github.com/ahmetson/service-lib/transport"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestPublisherSuite struct {
+	suite.Suite
+	transport transport.Transport
+}
+
+func (test *TestPublisherSuite) SetupTest() {
+	t, err := transport.Get("mem")
+	test.Require().NoError(err)
+	test.transport = t
+}
+
+// Test_10_Publish tests the broadcasts received by the subscriber
+func (test *TestPublisherSuite) Test_10_Publish() {
+	s := test.Require
+
+	_, err := New("", broadcast.NewFeed(0))
+	s().Error(err)
+	_, err = New("mem://publisher", nil)
+	s().Error(err)
+
+	feed := broadcast.NewFeed(0)
+	pub, err := New("mem://publisher", feed)
+	s().NoError(err)
+	pub.SetTransport(test.transport)
+	pub.SetHeartbeat(time.Millisecond * 10)
+
+	// not running
+	_, err = pub.Publish("prices", map[string]interface{}{"price": 1})
+	s().Error(err)
+	s().Error(pub.Close())
+
+	s().NoError(pub.Start())
+	s().True(pub.Running())
+	s().Error(pub.Start())
+
+	sub, err := subscriber.New(pub.Url(), "prices")
+	s().NoError(err)
+	sub.SetTransport(test.transport)
+	sub.SetLiveness(time.Millisecond * 100)
+	s().NoError(sub.Start())
+
+	// the broadcasts sent before the subscriber is accepted are caught up from the feed
+	var received broadcast.Sequenced
+	s().Eventually(func() bool {
+		if _, err := pub.Publish("prices", map[string]interface{}{"price": 1}); err != nil {
+			return false
+		}
+		select {
+		case received = <-sub.Broadcasts():
+			return true
+		case <-time.After(time.Millisecond * 20):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	s().Equal("prices", received.Topic)
+	last, err := feed.Last("prices")
+	s().NoError(err)
+	s().Equal(last, received.Seq)
+
+	// the heartbeats keep the subscriber connected without the broadcasts
+	time.Sleep(time.Millisecond * 200)
+	sequenced, err := pub.Publish("prices", map[string]interface{}{"price": 2})
+	s().NoError(err)
+	select {
+	case received = <-sub.Broadcasts():
+		s().Equal(sequenced.Seq, received.Seq)
+	case <-time.After(time.Second):
+		s().Fail("the broadcast after the heartbeats is not received")
+	}
+
+	s().NoError(sub.Close())
+	s().NoError(pub.Close())
+	s().False(pub.Running())
+}
+
+// Test_11_ACL tests that the ACL is not started without the CURVE keys
+func (test *TestPublisherSuite) Test_11_ACL() {
+	s := test.Require
+
+	key := strings.Repeat("a", broadcast.KeySize)
+	acl := broadcast.NewACL()
+	s().NoError(acl.AllowSubscribe("config", key))
+
+	pub, err := New("mem://publisher_acl", broadcast.NewFeed(0))
+	s().NoError(err)
+	pub.SetTransport(test.transport)
+	pub.SetACL(acl)
+	s().Error(pub.Start())
+
+	// the mem transport has no CURVE, the ACL can't be enforced
+	pub.SetCurve(key, key)
+	s().Error(pub.Start())
+	s().False(pub.Running())
+
+	// the subscription filter built from the ACL
+	s().True(pub.subscriptionFilter(key, "config"))
+	s().True(pub.subscriptionFilter(strings.Repeat("b", broadcast.KeySize), "prices"))
+	s().False(pub.subscriptionFilter(strings.Repeat("b", broadcast.KeySize), "config.update"))
+	s().False(pub.subscriptionFilter(strings.Repeat("b", broadcast.KeySize), ""))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestPublisher(t *testing.T) {
+	suite.Run(t, new(TestPublisherSuite))
+}
@@ -0,0 +1,214 @@
+// Package quota accounts the requests and bytes received from each source service,
+// and enforces the optional daily and monthly quotas.
+//
+// The source service is identified by the Param of the request, set by Service.Call.
+// The requests without it are accounted as Anonymous.
+//
+// The periods are calendar days and months in UTC.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Param is the request parameter with the id of the source service
+	Param = "source_id"
+	// Anonymous is the source of the requests without the Param
+	Anonymous = "anonymous"
+	// Code is the prefix of the error message when the quota is exceeded
+	Code = "quota_exceeded"
+)
+
+// Limits of the period.
+// Zero value means no limit.
+type Limits struct {
+	Requests uint64 `json:"requests,omitempty" yaml:"requests,omitempty"`
+	Bytes    uint64 `json:"bytes,omitempty" yaml:"bytes,omitempty"`
+}
+
+// Quota of the source service
+type Quota struct {
+	Daily   Limits `json:"daily,omitempty" yaml:"daily,omitempty"`
+	Monthly Limits `json:"monthly,omitempty" yaml:"monthly,omitempty"`
+}
+
+// Counter of the requests and their bytes in the period
+type Counter struct {
+	Requests uint64 `json:"requests"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// Usage of the source service in the current day and month
+type Usage struct {
+	Source  string  `json:"source"`
+	Daily   Counter `json:"daily"`
+	Monthly Counter `json:"monthly"`
+	Quota   Quota   `json:"quota"`
+}
+
+// ExceededError is returned for the request that would exceed the quota
+type ExceededError struct {
+	Source   string
+	Period   string // daily or monthly
+	Resource string // requests or bytes
+	Limit    uint64
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s: the '%s' source exceeds the %s %s limit of %d", Code, e.Source, e.Period, e.Resource, e.Limit)
+}
+
+// IsExceeded returns true if the error or the error message of the failed reply is about the exceeded quota
+func IsExceeded(err error) bool {
+	var exceeded *ExceededError
+	if errors.As(err, &exceeded) {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), Code+":")
+}
+
+// The check returns ExceededError if the counter with the request exceeds the limits
+func (limits Limits) check(source string, period string, counter Counter, bytes uint64) error {
+	if limits.Requests > 0 && counter.Requests+1 > limits.Requests {
+		return &ExceededError{Source: source, Period: period, Resource: "requests", Limit: limits.Requests}
+	}
+	if limits.Bytes > 0 && counter.Bytes+bytes > limits.Bytes {
+		return &ExceededError{Source: source, Period: period, Resource: "bytes", Limit: limits.Bytes}
+	}
+	return nil
+}
+
+type usage struct {
+	day     string
+	month   string
+	daily   Counter
+	monthly Counter
+}
+
+// Accountant counts the usage of the source services, and enforces their quotas
+type Accountant struct {
+	quotas   map[string]Quota
+	fallback Quota
+	usage    map[string]*usage
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+// NewAccountant returns the accountant without the quotas
+func NewAccountant() *Accountant {
+	return &Accountant{
+		quotas: make(map[string]Quota),
+		usage:  make(map[string]*usage),
+		now:    time.Now,
+	}
+}
+
+// Set the quota of the source service
+func (accountant *Accountant) Set(source string, quota Quota) {
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	accountant.quotas[source] = quota
+}
+
+// SetDefault sets the quota of the source services without their own quota
+func (accountant *Accountant) SetDefault(quota Quota) {
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	accountant.fallback = quota
+}
+
+// The quota returns the quota of the source
+func (accountant *Accountant) quota(source string) Quota {
+	if quota, ok := accountant.quotas[source]; ok {
+		return quota
+	}
+	return accountant.fallback
+}
+
+// Add the request of the source.
+// If the request would exceed the quota, then it's not counted and ExceededError is returned.
+// The nil accountant accepts every request.
+func (accountant *Accountant) Add(source string, bytes uint64) error {
+	if accountant == nil {
+		return nil
+	}
+	if len(source) == 0 {
+		source = Anonymous
+	}
+
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	current := accountant.current(source)
+	quota := accountant.quota(source)
+	if err := quota.Daily.check(source, "daily", current.daily, bytes); err != nil {
+		return err
+	}
+	if err := quota.Monthly.check(source, "monthly", current.monthly, bytes); err != nil {
+		return err
+	}
+
+	current.daily.Requests++
+	current.daily.Bytes += bytes
+	current.monthly.Requests++
+	current.monthly.Bytes += bytes
+	return nil
+}
+
+// The current returns the usage of the source, reset if the period passed
+func (accountant *Accountant) current(source string) *usage {
+	now := accountant.now().UTC()
+	day, month := now.Format(time.DateOnly), now.Format("2006-01")
+
+	current, ok := accountant.usage[source]
+	if !ok {
+		current = &usage{day: day, month: month}
+		accountant.usage[source] = current
+	}
+	if current.day != day {
+		current.day = day
+		current.daily = Counter{}
+	}
+	if current.month != month {
+		current.month = month
+		current.monthly = Counter{}
+	}
+	return current
+}
+
+// Usage returns the usage of the source services sorted by their id.
+// The nil accountant has no usage.
+func (accountant *Accountant) Usage() []Usage {
+	if accountant == nil {
+		return []Usage{}
+	}
+
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+
+	sources := make([]string, 0, len(accountant.usage))
+	for source := range accountant.usage {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+
+	usages := make([]Usage, 0, len(sources))
+	for _, source := range sources {
+		current := accountant.current(source)
+		usages = append(usages, Usage{
+			Source:  source,
+			Daily:   current.daily,
+			Monthly: current.monthly,
+			Quota:   accountant.quota(source),
+		})
+	}
+	return usages
+}
@@ -0,0 +1,79 @@
+package quota
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestQuotaSuite struct {
+	suite.Suite
+}
+
+// Test_10_Add tests the daily and monthly quotas
+func (test *TestQuotaSuite) Test_10_Add() {
+	s := test.Require
+
+	accountant := NewAccountant()
+	now := time.Date(2024, 1, 30, 10, 0, 0, 0, time.UTC)
+	accountant.now = func() time.Time { return now }
+
+	accountant.Set("tenant-a", Quota{Daily: Limits{Requests: 2}, Monthly: Limits{Bytes: 100}})
+
+	s().NoError(accountant.Add("tenant-a", 10))
+	s().NoError(accountant.Add("tenant-a", 10))
+	err := accountant.Add("tenant-a", 10)
+	s().True(IsExceeded(err))
+	s().True(IsExceeded(fmt.Errorf("reply error message: %s", err.Error())))
+
+	// no quota for the other sources
+	for i := 0; i < 5; i++ {
+		s().NoError(accountant.Add("tenant-b", 1000))
+	}
+	s().NoError(accountant.Add("", 1))
+
+	// the next day resets the daily quota, but not the monthly
+	now = now.Add(time.Hour * 14)
+	s().NoError(accountant.Add("tenant-a", 70))
+	s().True(IsExceeded(accountant.Add("tenant-a", 20)))
+
+	// the next month resets the monthly quota
+	now = now.AddDate(0, 1, 0)
+	s().NoError(accountant.Add("tenant-a", 90))
+
+	usage := accountant.Usage()
+	s().Len(usage, 3)
+	s().Equal(Anonymous, usage[0].Source)
+	s().Equal("tenant-a", usage[1].Source)
+	s().Equal(Counter{Requests: 1, Bytes: 90}, usage[1].Monthly)
+	s().Equal(uint64(2), usage[1].Quota.Daily.Requests)
+	s().Equal(Counter{}, usage[2].Daily)
+}
+
+// Test_11_Default tests the default quota
+func (test *TestQuotaSuite) Test_11_Default() {
+	s := test.Require
+
+	accountant := NewAccountant()
+	accountant.SetDefault(Quota{Daily: Limits{Requests: 1}})
+	accountant.Set("admin", Quota{})
+
+	s().NoError(accountant.Add("tenant", 1))
+	s().Error(accountant.Add("tenant", 1))
+	s().NoError(accountant.Add("admin", 1))
+	s().NoError(accountant.Add("admin", 1))
+
+	var empty *Accountant
+	s().NoError(empty.Add("tenant", 1))
+	s().Empty(empty.Usage())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestQuota(t *testing.T) {
+	suite.Run(t, new(TestQuotaSuite))
+}
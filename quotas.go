@@ -0,0 +1,60 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/service-lib/quota"
+)
+
+// SetQuota sets the daily and monthly quota of the source service.
+// The quota is enforced on the routes added by RouteQuota, see quota.Quota for the config fields.
+func (independent *Service) SetQuota(source string, q quota.Quota) {
+	independent.quotas.Set(source, q)
+}
+
+// SetDefaultQuota sets the quota of the source services without their own quota
+func (independent *Service) SetDefaultQuota(q quota.Quota) {
+	independent.quotas.SetDefault(q)
+}
+
+// RouteQuota adds the route into the handlers of the category, accounted by the source service.
+// The usage is shared by all routes added by RouteQuota, and returned by the manager.Usage command.
+// The request exceeding the quota of its source replies with quota.Code without calling the handle.
+func (independent *Service) RouteQuota(category string, command string, handle func(message.RequestInterface) message.ReplyInterface) error {
+	handlers := independent.HandlersByCategory(category)
+	if len(handlers) == 0 {
+		return fmt.Errorf("the '%s' handler is not set", category)
+	}
+
+	route := func(req message.RequestInterface) message.ReplyInterface {
+		source, _ := req.RouteParameters().StringValue(quota.Param)
+		data, err := json.Marshal(req.RouteParameters().Map())
+		if err != nil {
+			return req.Fail(fmt.Sprintf("json.Marshal: %v", err))
+		}
+		if err := independent.quotas.Add(source, uint64(len(data))); err != nil {
+			return req.Fail(err.Error())
+		}
+		return handle(req)
+	}
+	for _, handler := range handlers {
+		if err := handler.Route(command, route); err != nil {
+			return fmt.Errorf("handler('%s').Route('%s'): %w", category, command, err)
+		}
+	}
+
+	return nil
+}
+
+// The withSource returns the copy of the parameters identifying this service as the source, see quota.Param
+func (independent *Service) withSource(parameters key_value.KeyValue) key_value.KeyValue {
+	sourced := key_value.New()
+	if parameters != nil {
+		for key, value := range parameters.Map() {
+			sourced.Set(key, value)
+		}
+	}
+	return sourced.Set(quota.Param, independent.id)
+}
@@ -0,0 +1,284 @@
+// Package reactor is the event loop over multiple sockets.
+//
+// Instead of writing the poll loops with the manual alarm arithmetic,
+// register the sockets with the callbacks and the timers in the Reactor.
+// Then run the reactor in the background and close it when it's not needed.
+//
+// The sockets are polled by the transport that created them, see transport.Transport.
+package reactor
+
+import (
+	"fmt"
+	"github.com/ahmetson/service-lib/transport"
+	"sync"
+	"time"
+)
+
+// PollInterval is the default maximum time to wait for the socket events.
+// The timers and the close signal are checked at least once per interval.
+const PollInterval = time.Millisecond * 100
+
+// SocketHandler is called when the socket has an incoming message.
+// If it returns an error, the reactor stops.
+type SocketHandler = func(socket transport.Socket) error
+
+// TimerHandler is called when the timer fires.
+// If it returns an error, the reactor stops.
+type TimerHandler = func() error
+
+type timer struct {
+	id       int
+	interval time.Duration
+	next     time.Time
+	handler  TimerHandler
+}
+
+// Reactor polls the sockets and dispatches the socket events and timers
+type Reactor struct {
+	transport    transport.Transport
+	sockets      map[transport.Socket]SocketHandler
+	polled       []transport.Socket // the sockets in the order they were added
+	timers       []*timer
+	lastTimerId  int
+	pollInterval time.Duration
+	stop         chan struct{}
+	done         chan error
+	running      bool
+	mu           sync.Mutex
+}
+
+// New returns an empty reactor polling the sockets of the transport.
+// If the transport is nil, then transport.Default is used.
+func New(t transport.Transport) *Reactor {
+	if t == nil {
+		t = transport.Default()
+	}
+	return &Reactor{
+		transport:    t,
+		sockets:      make(map[transport.Socket]SocketHandler),
+		polled:       make([]transport.Socket, 0),
+		timers:       make([]*timer, 0),
+		pollInterval: PollInterval,
+	}
+}
+
+// SetPollInterval sets the maximum time to wait for the socket events.
+// The timers can't fire more often than the poll interval.
+func (r *Reactor) SetPollInterval(interval time.Duration) {
+	r.mu.Lock()
+	r.pollInterval = interval
+	r.mu.Unlock()
+}
+
+// AddSocket registers the socket with the handler called on incoming messages.
+// It's safe to call while the reactor is running, the socket is polled from the next iteration.
+// The socket must be created by the reactor's transport.
+// The reactor doesn't close the sockets, the owner of the socket must close it.
+func (r *Reactor) AddSocket(socket transport.Socket, handler SocketHandler) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sockets[socket]; ok {
+		return fmt.Errorf("socket already added")
+	}
+	r.sockets[socket] = handler
+	r.polled = append(r.polled, socket)
+
+	return nil
+}
+
+// RemoveSocket unregisters the socket.
+// If the socket is polled at the moment, its handler is not called anymore.
+func (r *Reactor) RemoveSocket(socket transport.Socket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sockets[socket]; !ok {
+		return fmt.Errorf("socket not added")
+	}
+	delete(r.sockets, socket)
+	for i := range r.polled {
+		if r.polled[i] == socket {
+			r.polled = append(r.polled[:i], r.polled[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// AddTimer registers the handler called every interval.
+// Returns the timer id to remove it.
+func (r *Reactor) AddTimer(interval time.Duration, handler TimerHandler) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastTimerId++
+	r.timers = append(r.timers, &timer{
+		id:       r.lastTimerId,
+		interval: interval,
+		next:     time.Now().Add(interval),
+		handler:  handler,
+	})
+
+	return r.lastTimerId
+}
+
+// RemoveTimer unregisters the timer by its id
+func (r *Reactor) RemoveTimer(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.timers {
+		if r.timers[i].id == id {
+			r.timers = append(r.timers[:i], r.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+// The fireTimers calls the handlers of the due timers
+func (r *Reactor) fireTimers() error {
+	r.mu.Lock()
+	due := make([]*timer, 0, len(r.timers))
+	now := time.Now()
+	for _, t := range r.timers {
+		if !now.Before(t.next) {
+			t.next = now.Add(t.interval)
+			due = append(due, t)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, t := range due {
+		if err := t.handler(); err != nil {
+			return fmt.Errorf("timer(id=%d): %w", t.id, err)
+		}
+	}
+
+	return nil
+}
+
+// pollTimeout returns the time to wait for the socket events until the nearest timer
+func (r *Reactor) pollTimeout() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timeout := r.pollInterval
+	now := time.Now()
+	for _, t := range r.timers {
+		left := t.next.Sub(now)
+		if left < 0 {
+			left = 0
+		}
+		if left < timeout {
+			timeout = left
+		}
+	}
+
+	return timeout
+}
+
+// The dispatch waits for the socket events and calls the socket handlers.
+//
+// The sockets are copied before polling, so the sockets can be added or removed from other goroutines.
+// The handlers are called without the lock, so they can add or remove the sockets too.
+func (r *Reactor) dispatch() error {
+	timeout := r.pollTimeout()
+
+	r.mu.Lock()
+	sockets := make([]transport.Socket, len(r.polled))
+	copy(sockets, r.polled)
+	r.mu.Unlock()
+
+	readable, err := r.transport.Poll(sockets, timeout)
+	if err != nil {
+		return fmt.Errorf("transport.Poll: %w", err)
+	}
+
+	for _, socket := range readable {
+		r.mu.Lock()
+		handler, ok := r.sockets[socket]
+		r.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if err := handler(socket); err != nil {
+			return fmt.Errorf("socket handler: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Run the event loop in the background.
+// The returned channel receives the error that stopped the reactor, or nil if it was closed.
+func (r *Reactor) Run() (<-chan error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return nil, fmt.Errorf("already running")
+	}
+	r.running = true
+	r.stop = make(chan struct{})
+	r.done = make(chan error, 1)
+
+	go r.loop(r.stop, r.done)
+
+	return r.done, nil
+}
+
+func (r *Reactor) loop(stop chan struct{}, done chan error) {
+	var err error
+
+	for {
+		select {
+		case <-stop:
+			done <- nil
+			return
+		default:
+		}
+
+		if err = r.dispatch(); err != nil {
+			break
+		}
+		if err = r.fireTimers(); err != nil {
+			break
+		}
+	}
+
+	r.mu.Lock()
+	r.running = false
+	r.mu.Unlock()
+
+	done <- err
+}
+
+// Running returns true if the event loop is running
+func (r *Reactor) Running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.running
+}
+
+// Close stops the event loop and waits until it's stopped.
+func (r *Reactor) Close() error {
+	r.mu.Lock()
+	if !r.running {
+		r.mu.Unlock()
+		return fmt.Errorf("not running")
+	}
+	r.running = false
+	stop := r.stop
+	done := r.done
+	r.mu.Unlock()
+
+	close(stop)
+	if err := <-done; err != nil {
+		return fmt.Errorf("reactor stopped with error: %w", err)
+	}
+
+	return nil
+}
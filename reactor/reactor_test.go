@@ -0,0 +1,209 @@
+package reactor
+
+import (
+	"fmt"
+	"github.com/ahmetson/service-lib/transport"
+	"github.com/stretchr/testify/suite"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestReactorSuite struct {
+	suite.Suite
+
+	transport transport.Transport
+	reactor   *Reactor
+}
+
+func (test *TestReactorSuite) SetupTest() {
+	t, err := transport.Get("mem")
+	test.Require().NoError(err)
+	test.transport = t
+	test.reactor = New(t)
+	test.reactor.SetPollInterval(time.Millisecond * 10)
+}
+
+func (test *TestReactorSuite) TearDownTest() {
+	if test.reactor.Running() {
+		test.Require().NoError(test.reactor.Close())
+	}
+}
+
+// echo returns the socket handler that replies with the received frames
+func echo(received *atomic.Int32) SocketHandler {
+	return func(socket transport.Socket) error {
+		frames, err := socket.Recv()
+		if err != nil {
+			return err
+		}
+		received.Add(1)
+		return socket.Send(frames)
+	}
+}
+
+// request sends the frame by the client and returns the reply
+func (test *TestReactorSuite) request(client transport.Socket, frame string) string {
+	s := test.Require
+
+	s().NoError(client.Send([][]byte{[]byte(frame)}))
+	reply := make(chan [][]byte, 1)
+	go func() {
+		frames, err := client.Recv()
+		if err == nil {
+			reply <- frames
+		}
+	}()
+	select {
+	case frames := <-reply:
+		return string(frames[0])
+	case <-time.After(time.Second):
+		s().Fail("no reply to " + frame)
+		return ""
+	}
+}
+
+// Test_10_Timer tests the timers fired by the interval, and the timer stopping the reactor
+func (test *TestReactorSuite) Test_10_Timer() {
+	s := test.Require
+
+	var fired atomic.Int32
+	id := test.reactor.AddTimer(time.Millisecond*5, func() error {
+		fired.Add(1)
+		return nil
+	})
+
+	_, err := test.reactor.Run()
+	s().NoError(err)
+	s().True(test.reactor.Running())
+	_, err = test.reactor.Run()
+	s().Error(err)
+
+	s().Eventually(func() bool { return fired.Load() >= 3 }, time.Second, time.Millisecond)
+
+	// the removed timer is not fired
+	test.reactor.RemoveTimer(id)
+	time.Sleep(time.Millisecond * 30)
+	stopped := fired.Load()
+	time.Sleep(time.Millisecond * 30)
+	s().Equal(stopped, fired.Load())
+
+	s().NoError(test.reactor.Close())
+	s().False(test.reactor.Running())
+	s().Error(test.reactor.Close())
+
+	// the failed timer stops the reactor with its error
+	test.reactor.AddTimer(time.Millisecond, func() error {
+		return fmt.Errorf("timer failed")
+	})
+	done, err := test.reactor.Run()
+	s().NoError(err)
+	select {
+	case err := <-done:
+		s().ErrorContains(err, "timer failed")
+	case <-time.After(time.Second):
+		s().Fail("the failed timer didn't stop the reactor")
+	}
+	s().False(test.reactor.Running())
+}
+
+// Test_11_Socket tests the socket handler called on the incoming messages
+func (test *TestReactorSuite) Test_11_Socket() {
+	s := test.Require
+
+	server, err := test.transport.Bind(transport.Rep, "mem://reactor_socket")
+	s().NoError(err)
+	defer func() { _ = server.Close() }()
+	client, err := test.transport.Dial(transport.Req, "mem://reactor_socket")
+	s().NoError(err)
+	defer func() { _ = client.Close() }()
+
+	var received atomic.Int32
+	s().NoError(test.reactor.AddSocket(server, echo(&received)))
+	s().Error(test.reactor.AddSocket(server, echo(&received)))
+
+	_, err = test.reactor.Run()
+	s().NoError(err)
+
+	s().Equal("hello", test.request(client, "hello"))
+	s().Equal("world", test.request(client, "world"))
+	s().Equal(int32(2), received.Load())
+
+	// the failed handler stops the reactor with its error
+	s().NoError(test.reactor.Close())
+	s().NoError(test.reactor.RemoveSocket(server))
+	s().Error(test.reactor.RemoveSocket(server))
+	s().NoError(test.reactor.AddSocket(server, func(socket transport.Socket) error {
+		_, _ = socket.Recv()
+		return fmt.Errorf("handler failed")
+	}))
+	done, err := test.reactor.Run()
+	s().NoError(err)
+	s().NoError(client.Send([][]byte{[]byte("fail")}))
+	select {
+	case err := <-done:
+		s().ErrorContains(err, "handler failed")
+	case <-time.After(time.Second):
+		s().Fail("the failed handler didn't stop the reactor")
+	}
+}
+
+// Test_12_Running tests adding and removing the sockets while the reactor is running
+func (test *TestReactorSuite) Test_12_Running() {
+	s := test.Require
+
+	_, err := test.reactor.Run()
+	s().NoError(err)
+
+	first, err := test.transport.Bind(transport.Rep, "mem://reactor_first")
+	s().NoError(err)
+	defer func() { _ = first.Close() }()
+	second, err := test.transport.Bind(transport.Rep, "mem://reactor_second")
+	s().NoError(err)
+	defer func() { _ = second.Close() }()
+	firstClient, err := test.transport.Dial(transport.Req, "mem://reactor_first")
+	s().NoError(err)
+	defer func() { _ = firstClient.Close() }()
+	secondClient, err := test.transport.Dial(transport.Req, "mem://reactor_second")
+	s().NoError(err)
+	defer func() { _ = secondClient.Close() }()
+
+	// the socket added to the running reactor is polled
+	var firstReceived, secondReceived atomic.Int32
+	s().NoError(test.reactor.AddSocket(first, echo(&firstReceived)))
+	s().Equal("first", test.request(firstClient, "first"))
+
+	// the handler adds the other socket
+	s().NoError(test.reactor.RemoveSocket(first))
+	s().NoError(test.reactor.AddSocket(first, func(socket transport.Socket) error {
+		if err := test.reactor.AddSocket(second, echo(&secondReceived)); err != nil {
+			return err
+		}
+		return echo(&firstReceived)(socket)
+	}))
+	s().Equal("add", test.request(firstClient, "add"))
+	s().Equal("second", test.request(secondClient, "second"))
+	s().Equal(int32(1), secondReceived.Load())
+
+	// the removed socket is not polled anymore
+	s().NoError(test.reactor.RemoveSocket(second))
+	s().NoError(secondClient.Send([][]byte{[]byte("removed")}))
+	time.Sleep(time.Millisecond * 50)
+	s().Equal(int32(1), secondReceived.Load())
+	s().True(test.reactor.Running())
+
+	// the removed socket keeps the message for the next owner
+	s().NoError(test.reactor.AddSocket(second, echo(&secondReceived)))
+	s().Eventually(func() bool { return secondReceived.Load() == 2 }, time.Second, time.Millisecond)
+
+	s().NoError(test.reactor.Close())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestReactor(t *testing.T) {
+	suite.Run(t, new(TestReactorSuite))
+}
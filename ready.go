@@ -0,0 +1,113 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/service-lib/flag"
+	"net"
+	"os"
+)
+
+// ReadyEvent is the event field of the ready line
+const ReadyEvent = "ready"
+
+// Ready is the line printed to stdout when the service started.
+// The supervisors and test harnesses parse it to detect the readiness.
+type Ready struct {
+	Event    string            `json:"event"`
+	Id       string            `json:"id"`
+	Url      string            `json:"url"`
+	Manager  string            `json:"manager"`
+	Handlers map[string]string `json:"handlers"` // the endpoints by the handler category, or id if set by SetHandlerById
+	Pid      int               `json:"pid"`
+}
+
+// The endpoint returns the address of the port, or the inproc address of the id
+func endpoint(id string, port uint64) string {
+	if port == 0 {
+		return "inproc://" + id
+	}
+	return fmt.Sprintf("tcp://localhost:%d", port)
+}
+
+// The ready returns the endpoints of the started service
+func (independent *Service) ready() (*Ready, error) {
+	serviceConf, err := independent.ctx.Config().Service(independent.id)
+	if err != nil {
+		return nil, fmt.Errorf("ctx.Config().Service('%s'): %w", independent.id, err)
+	}
+
+	handlers := make(map[string]string, len(independent.Handlers))
+	for category, raw := range independent.Handlers {
+		if independent.isInternal(category) {
+			continue
+		}
+		c := raw.(base.Interface).Config()
+		handlers[category] = endpoint(c.Id, c.Port)
+	}
+
+	return &Ready{
+		Event:    ReadyEvent,
+		Id:       independent.id,
+		Url:      independent.url,
+		Manager:  endpoint(serviceConf.Manager.Id, serviceConf.Manager.Port),
+		Handlers: handlers,
+		Pid:      os.Getpid(),
+	}, nil
+}
+
+// The signalReady prints the Ready line to stdout.
+// If flag.ReadyFileEnv is set, the line is written into the file as well.
+// If flag.NotifySocketEnv is set, the systemd is notified.
+func (independent *Service) signalReady() error {
+	ready, err := independent.ready()
+	if err != nil {
+		return fmt.Errorf("ready: %w", err)
+	}
+	line, err := json.Marshal(ready)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := os.Stdout.Write(line); err != nil {
+		return fmt.Errorf("os.Stdout.Write: %w", err)
+	}
+
+	if readyFile := os.Getenv(flag.ReadyFileEnv); len(readyFile) > 0 {
+		// written by renaming, so the readers never see a partial file
+		tmpFile := readyFile + ".tmp"
+		if err := os.WriteFile(tmpFile, line, 0644); err != nil {
+			return fmt.Errorf("os.WriteFile('%s'): %w", tmpFile, err)
+		}
+		if err := os.Rename(tmpFile, readyFile); err != nil {
+			return fmt.Errorf("os.Rename('%s'): %w", tmpFile, err)
+		}
+	}
+
+	if socket := os.Getenv(flag.NotifySocketEnv); len(socket) > 0 {
+		if err := notify(socket, "READY=1"); err != nil {
+			return fmt.Errorf("notify('%s'): %w", socket, err)
+		}
+	}
+
+	return nil
+}
+
+// The notify sends the state to the systemd notify socket
+func notify(socket string, state string) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("net.DialUnix: %w", err)
+	}
+	_, err = conn.Write([]byte(state))
+	closeErr := conn.Close()
+	if err != nil {
+		return fmt.Errorf("conn.Write: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("conn.Close: %w", closeErr)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+// Package replycache keeps the replies of the idempotent queries on the client side.
+//
+// The server opts in per reply by the Hint under the HintParam: how long the reply is fresh,
+// its etag, and the broadcast topics that invalidate it. See Respond.
+//
+// When the kept reply expires, the client sends its etag under the EtagParam.
+// If the reply didn't change, then the server replies with NotModifiedParam only,
+// and the client keeps using the reply for another ttl.
+package replycache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// HintParam is the reply parameter with the Hint
+	HintParam = "cache"
+	// EtagParam is the request parameter with the etag of the expired reply kept by the client
+	EtagParam = "if_none_match"
+	// NotModifiedParam is the reply parameter, true if the reply matches the etag of the request
+	NotModifiedParam = "not_modified"
+	// Limit is the default amount of the kept replies
+	Limit = 1000
+)
+
+// Hint of the server how the client caches the reply
+type Hint struct {
+	Ttl    time.Duration `json:"ttl"`
+	Etag   string        `json:"etag"`
+	Topics []string      `json:"topics,omitempty"` // the broadcasts invalidating the reply
+}
+
+// Etag returns the hash of the JSON of the value
+func Etag(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// Key returns the cache key of the query.
+// The parameters are encoded with the sorted keys, so the same query has the same key.
+func Key(command string, parameters map[string]interface{}) (string, error) {
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+	return command + " " + string(data), nil
+}
+
+// ParseHint returns the hint of the reply parameters.
+// Returns false if the server didn't set it.
+func ParseHint(parameters map[string]interface{}) (Hint, bool) {
+	raw, ok := parameters[HintParam]
+	if !ok {
+		return Hint{}, false
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return Hint{}, false
+	}
+	var hint Hint
+	if err := json.Unmarshal(data, &hint); err != nil {
+		return Hint{}, false
+	}
+	return hint, true
+}
+
+// NotModified returns true if the server replied that the kept reply didn't change
+func NotModified(parameters map[string]interface{}) bool {
+	notModified, ok := parameters[NotModifiedParam].(bool)
+	return ok && notModified
+}
+
+// Respond returns the reply parameters with the Hint.
+// If the request has the etag of the same reply, then only the NotModifiedParam and the Hint are returned.
+func Respond(request map[string]interface{}, reply map[string]interface{}, ttl time.Duration, topics ...string) (map[string]interface{}, error) {
+	etag, err := Etag(reply)
+	if err != nil {
+		return nil, fmt.Errorf("Etag: %w", err)
+	}
+	hint := Hint{Ttl: ttl, Etag: etag, Topics: topics}
+
+	if requested, ok := request[EtagParam].(string); ok && requested == etag {
+		return map[string]interface{}{NotModifiedParam: true, HintParam: hint}, nil
+	}
+	reply[HintParam] = hint
+	return reply, nil
+}
+
+// Entry is the kept reply
+type Entry[V any] struct {
+	Value   V
+	Hint    Hint
+	Expires time.Time
+}
+
+// Fresh returns true if the reply is used without asking the server
+func (entry Entry[V]) Fresh(now time.Time) bool {
+	return now.Before(entry.Expires)
+}
+
+// Cache keeps the replies by the query keys
+type Cache[V any] struct {
+	limit   int
+	entries map[string]Entry[V]
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewCache returns the cache that keeps the limit of the replies.
+// Zero limit is replaced by Limit.
+func NewCache[V any](limit int) *Cache[V] {
+	if limit <= 0 {
+		limit = Limit
+	}
+	return &Cache[V]{limit: limit, entries: make(map[string]Entry[V]), now: time.Now}
+}
+
+// Get returns the fresh reply by the key
+func (cache *Cache[V]) Get(key string) (V, bool) {
+	entry, ok := cache.Lookup(key)
+	if !ok || !entry.Fresh(cache.now()) {
+		var empty V
+		return empty, false
+	}
+	return entry.Value, true
+}
+
+// Lookup returns the kept reply by the key, even if it expired.
+// The expired reply is revalidated by its etag.
+// The nil cache keeps nothing.
+func (cache *Cache[V]) Lookup(key string) (Entry[V], bool) {
+	if cache == nil {
+		return Entry[V]{}, false
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, ok := cache.entries[key]
+	return entry, ok
+}
+
+// Put keeps the reply by the hint.
+// The reply without the ttl is not kept.
+// If the cache is full, then the reply expiring first is evicted.
+func (cache *Cache[V]) Put(key string, value V, hint Hint) {
+	if hint.Ttl <= 0 {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if _, ok := cache.entries[key]; !ok && len(cache.entries) >= cache.limit {
+		evict := ""
+		for oldKey, entry := range cache.entries {
+			if len(evict) == 0 || entry.Expires.Before(cache.entries[evict].Expires) {
+				evict = oldKey
+			}
+		}
+		delete(cache.entries, evict)
+	}
+	cache.entries[key] = Entry[V]{Value: value, Hint: hint, Expires: cache.now().Add(hint.Ttl)}
+}
+
+// Refresh extends the kept reply that the server confirmed as not modified
+func (cache *Cache[V]) Refresh(key string, hint Hint) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, ok := cache.entries[key]
+	if !ok {
+		return
+	}
+	entry.Hint = hint
+	entry.Expires = cache.now().Add(hint.Ttl)
+	cache.entries[key] = entry
+}
+
+// Invalidate removes the replies invalidated by the broadcast topic.
+// Returns the amount of the removed replies.
+func (cache *Cache[V]) Invalidate(topic string) int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	removed := 0
+	for key, entry := range cache.entries {
+		if slices.Contains(entry.Hint.Topics, topic) {
+			delete(cache.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Clear removes all replies
+func (cache *Cache[V]) Clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries = make(map[string]Entry[V])
+}
+
+// Len returns the amount of the kept replies
+func (cache *Cache[V]) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return len(cache.entries)
+}
@@ -0,0 +1,110 @@
+package replycache
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestReplyCacheSuite struct {
+	suite.Suite
+}
+
+// Test_10_Respond tests the hints and the revalidation by the etag
+func (test *TestReplyCacheSuite) Test_10_Respond() {
+	s := test.Require
+
+	reply, err := Respond(map[string]interface{}{}, map[string]interface{}{"units": []string{"a"}}, time.Second, "config")
+	s().NoError(err)
+
+	// the hint passes through json as the messages do
+	data, err := json.Marshal(reply)
+	s().NoError(err)
+	var decoded map[string]interface{}
+	s().NoError(json.Unmarshal(data, &decoded))
+
+	hint, ok := ParseHint(decoded)
+	s().True(ok)
+	s().Equal(time.Second, hint.Ttl)
+	s().Equal([]string{"config"}, hint.Topics)
+	s().False(NotModified(decoded))
+
+	// the same reply is not modified
+	reply, err = Respond(map[string]interface{}{EtagParam: hint.Etag}, map[string]interface{}{"units": []string{"a"}}, time.Second)
+	s().NoError(err)
+	s().True(NotModified(reply))
+	s().NotContains(reply, "units")
+
+	// the changed reply is sent again
+	reply, err = Respond(map[string]interface{}{EtagParam: hint.Etag}, map[string]interface{}{"units": []string{"b"}}, time.Second)
+	s().NoError(err)
+	s().False(NotModified(reply))
+	s().Contains(reply, "units")
+
+	_, ok = ParseHint(map[string]interface{}{})
+	s().False(ok)
+
+	first, err := Key("units", map[string]interface{}{"b": 1, "a": 2})
+	s().NoError(err)
+	second, err := Key("units", map[string]interface{}{"a": 2, "b": 1})
+	s().NoError(err)
+	s().Equal(first, second)
+}
+
+// Test_11_Cache tests the expiration, the invalidation and the eviction
+func (test *TestReplyCacheSuite) Test_11_Cache() {
+	s := test.Require
+
+	cache := NewCache[string](2)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Put("no-ttl", "value", Hint{})
+	s().Zero(cache.Len())
+
+	cache.Put("units", "a", Hint{Ttl: time.Second, Etag: "etag-a", Topics: []string{"config"}})
+	cache.Put("handlers", "b", Hint{Ttl: time.Minute})
+	value, ok := cache.Get("units")
+	s().True(ok)
+	s().Equal("a", value)
+
+	// expired replies are kept for the revalidation
+	now = now.Add(time.Second * 2)
+	_, ok = cache.Get("units")
+	s().False(ok)
+	entry, ok := cache.Lookup("units")
+	s().True(ok)
+	s().Equal("etag-a", entry.Hint.Etag)
+
+	cache.Refresh("units", entry.Hint)
+	_, ok = cache.Get("units")
+	s().True(ok)
+
+	s().Equal(1, cache.Invalidate("config"))
+	_, ok = cache.Lookup("units")
+	s().False(ok)
+
+	// the full cache evicts the reply expiring first
+	cache.Put("units", "a", Hint{Ttl: time.Second})
+	cache.Put("tags", "c", Hint{Ttl: time.Hour})
+	s().Equal(2, cache.Len())
+	_, ok = cache.Lookup("units")
+	s().False(ok)
+
+	cache.Clear()
+	s().Zero(cache.Len())
+
+	var empty *Cache[string]
+	_, ok = empty.Get("units")
+	s().False(ok)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestReplyCache(t *testing.T) {
+	suite.Run(t, new(TestReplyCacheSuite))
+}
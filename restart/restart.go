@@ -0,0 +1,156 @@
+// Package restart defines the restart policies of the service components,
+// mirroring the systemd and docker restart semantics:
+//
+//	never          the failed component stays down
+//	on-failure:3   restarted up to 3 times, each time waiting twice longer
+//	always         restarted until it recovers, the wait is capped by MaxBackoff
+package restart
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode of the restart policy
+type Mode string
+
+const (
+	Never     Mode = "never"
+	OnFailure Mode = "on-failure"
+	Always    Mode = "always"
+)
+
+const (
+	// Backoff is the wait before the first restart by default
+	Backoff = time.Second
+	// MaxBackoff caps the doubled wait between the restarts by default
+	MaxBackoff = time.Minute
+)
+
+// Policy of restarting the component
+type Policy struct {
+	Mode       Mode          `json:"mode"`
+	MaxRetries int           `json:"max_retries,omitempty"` // only for OnFailure, 0 means unlimited
+	Backoff    time.Duration `json:"backoff,omitempty"`
+	MaxBackoff time.Duration `json:"max_backoff,omitempty"`
+}
+
+// Parse the policy from the string, such as "on-failure:3"
+func Parse(raw string) (Policy, error) {
+	mode, retries, hasRetries := strings.Cut(raw, ":")
+	policy := Policy{Mode: Mode(mode), Backoff: Backoff, MaxBackoff: MaxBackoff}
+
+	switch policy.Mode {
+	case Never, Always:
+		if hasRetries {
+			return Policy{}, fmt.Errorf("'%s' policy has no max retries", mode)
+		}
+	case OnFailure:
+		if hasRetries {
+			maxRetries, err := strconv.Atoi(retries)
+			if err != nil {
+				return Policy{}, fmt.Errorf("strconv.Atoi('%s'): %w", retries, err)
+			}
+			if maxRetries < 0 {
+				return Policy{}, fmt.Errorf("max retries must not be negative")
+			}
+			policy.MaxRetries = maxRetries
+		}
+	default:
+		return Policy{}, fmt.Errorf("unknown '%s' policy, expected '%s', '%s' or '%s'", mode, Never, OnFailure, Always)
+	}
+
+	return policy, nil
+}
+
+// String returns the policy in the format accepted by Parse
+func (policy Policy) String() string {
+	if policy.Mode == OnFailure && policy.MaxRetries > 0 {
+		return fmt.Sprintf("%s:%d", policy.Mode, policy.MaxRetries)
+	}
+	return string(policy.Mode)
+}
+
+// Tracker counts the restarts of the component by its policy
+type Tracker struct {
+	policy   Policy
+	attempts int
+	next     time.Time // the earliest time of the next restart
+	mu       sync.Mutex
+}
+
+// NewTracker returns the tracker of the component
+func NewTracker(policy Policy) *Tracker {
+	if policy.Backoff <= 0 {
+		policy.Backoff = Backoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = MaxBackoff
+	}
+	return &Tracker{policy: policy}
+}
+
+// Exhausted returns true if the component must not be restarted anymore
+func (tracker *Tracker) Exhausted() bool {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	return tracker.exhausted()
+}
+
+func (tracker *Tracker) exhausted() bool {
+	switch tracker.policy.Mode {
+	case Always:
+		return false
+	case OnFailure:
+		return tracker.policy.MaxRetries > 0 && tracker.attempts >= tracker.policy.MaxRetries
+	default:
+		return true
+	}
+}
+
+// Due returns true if the failed component must be restarted now.
+// Call Restarted after the attempt.
+func (tracker *Tracker) Due(now time.Time) bool {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	return !tracker.exhausted() && !now.Before(tracker.next)
+}
+
+// Restarted records the restart attempt, and schedules the next one after the doubled backoff.
+func (tracker *Tracker) Restarted(now time.Time) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	wait := tracker.policy.Backoff
+	for i := 0; i < tracker.attempts && wait < tracker.policy.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > tracker.policy.MaxBackoff {
+		wait = tracker.policy.MaxBackoff
+	}
+
+	tracker.attempts++
+	tracker.next = now.Add(wait)
+}
+
+// Attempts returns the amount of the restarts since the last Reset
+func (tracker *Tracker) Attempts() int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	return tracker.attempts
+}
+
+// Reset is called when the component is healthy again
+func (tracker *Tracker) Reset() {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.attempts = 0
+	tracker.next = time.Time{}
+}
@@ -0,0 +1,74 @@
+package restart
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestRestartSuite struct {
+	suite.Suite
+}
+
+// Test_10_Parse tests the policy strings
+func (test *TestRestartSuite) Test_10_Parse() {
+	s := test.Require
+
+	policy, err := Parse("on-failure:3")
+	s().NoError(err)
+	s().Equal(OnFailure, policy.Mode)
+	s().Equal(3, policy.MaxRetries)
+	s().Equal("on-failure:3", policy.String())
+
+	policy, err = Parse("always")
+	s().NoError(err)
+	s().Equal(Always, policy.Mode)
+
+	_, err = Parse("never:1")
+	s().Error(err)
+	_, err = Parse("on-failure:x")
+	s().Error(err)
+	_, err = Parse("sometimes")
+	s().Error(err)
+}
+
+// Test_11_Tracker tests the retries and the backoff
+func (test *TestRestartSuite) Test_11_Tracker() {
+	s := test.Require
+
+	now := time.Now()
+	s().False(NewTracker(Policy{Mode: Never}).Due(now))
+
+	tracker := NewTracker(Policy{Mode: OnFailure, MaxRetries: 2, Backoff: time.Second})
+	s().True(tracker.Due(now))
+	tracker.Restarted(now)
+	s().False(tracker.Due(now))
+	s().True(tracker.Due(now.Add(time.Second)))
+
+	// the backoff is doubled
+	now = now.Add(time.Second)
+	tracker.Restarted(now)
+	s().True(tracker.Exhausted())
+	s().False(tracker.Due(now.Add(time.Hour)))
+
+	tracker.Reset()
+	s().True(tracker.Due(now))
+
+	// the always policy is never exhausted, the backoff is capped
+	tracker = NewTracker(Policy{Mode: Always, Backoff: time.Second, MaxBackoff: time.Second * 4})
+	for i := 0; i < 10; i++ {
+		tracker.Restarted(now)
+	}
+	s().Equal(10, tracker.Attempts())
+	s().False(tracker.Exhausted())
+	s().True(tracker.Due(now.Add(time.Second * 4)))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestRestart(t *testing.T) {
+	suite.Run(t, new(TestRestartSuite))
+}
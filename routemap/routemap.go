@@ -0,0 +1,140 @@
+// Package routemap maps the commands of the handlers declared in the configuration to the registered functions.
+//
+// The service registers the route functions by the name in the init function.
+// Then the configuration declares the handler routes by the category:
+//
+//	SERVICE_ROUTES_MAIN=ping:echo,hello:greet,echo
+//
+// Each route is the command and the function name separated by ':'.
+// The route without the function name calls the function named as the command.
+package routemap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// EnvPrefix is the prefix of the routes declaration, followed by the upper-cased category.
+	// Use Env to get the name.
+	EnvPrefix = "SERVICE_ROUTES_"
+	// Separator of the routes in the declaration
+	Separator = ","
+	// FuncSeparator separates the command and the function name in the route
+	FuncSeparator = ":"
+)
+
+// Validate returns an error if the command or the function name can not be used.
+func Validate(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("name is empty")
+	}
+	if strings.ContainsAny(name, " \t\n"+Separator+FuncSeparator) {
+		return fmt.Errorf("'%s' must not have the spaces, '%s' or '%s'", name, Separator, FuncSeparator)
+	}
+	return nil
+}
+
+// Env returns the name of the configuration with the routes of the handler category.
+// The dashes and dots in the category are replaced by underscores.
+func Env(category string) string {
+	return EnvPrefix + strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(category))
+}
+
+// Parse returns the function names by the command from the routes declaration.
+// Returns an error if the command is declared twice.
+func Parse(declaration string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, route := range strings.Split(declaration, Separator) {
+		route = strings.TrimSpace(route)
+		if len(route) == 0 {
+			continue
+		}
+		command, name, found := strings.Cut(route, FuncSeparator)
+		command, name = strings.TrimSpace(command), strings.TrimSpace(name)
+		if !found {
+			name = command
+		}
+		if err := Validate(command); err != nil {
+			return nil, fmt.Errorf("route '%s' command: %w", route, err)
+		}
+		if err := Validate(name); err != nil {
+			return nil, fmt.Errorf("route '%s' function: %w", route, err)
+		}
+		if _, ok := routes[command]; ok {
+			return nil, fmt.Errorf("the '%s' command declared twice", command)
+		}
+		routes[command] = name
+	}
+	return routes, nil
+}
+
+// Registry keeps the route functions by the name
+type Registry[F any] struct {
+	funcs map[string]F
+	mu    sync.RWMutex
+}
+
+// NewRegistry returns the registry without the functions
+func NewRegistry[F any]() *Registry[F] {
+	return &Registry[F]{funcs: make(map[string]F)}
+}
+
+// Register the function by the name.
+// Returns an error if the name is invalid or registered already.
+func (registry *Registry[F]) Register(name string, fn F) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.funcs[name]; ok {
+		return fmt.Errorf("the '%s' function registered already", name)
+	}
+	registry.funcs[name] = fn
+	return nil
+}
+
+// Lookup returns the function by the name.
+// Returns false if the function is not registered.
+func (registry *Registry[F]) Lookup(name string) (F, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	fn, ok := registry.funcs[name]
+	return fn, ok
+}
+
+// Resolve returns the functions by the command of the declared routes.
+// Returns an error if any function is not registered, then none is returned.
+func (registry *Registry[F]) Resolve(routes map[string]string) (map[string]F, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	resolved := make(map[string]F, len(routes))
+	for command, name := range routes {
+		fn, ok := registry.funcs[name]
+		if !ok {
+			return nil, fmt.Errorf("the '%s' command routes to the '%s' function that is not registered", command, name)
+		}
+		resolved[command] = fn
+	}
+	return resolved, nil
+}
+
+// Names returns the sorted names of the registered functions
+func (registry *Registry[F]) Names() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	names := make([]string, 0, len(registry.funcs))
+	for name := range registry.funcs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
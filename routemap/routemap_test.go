@@ -0,0 +1,74 @@
+package routemap
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestRouteMapSuite struct {
+	suite.Suite
+}
+
+// Test_10_Parse tests the routes declaration
+func (test *TestRouteMapSuite) Test_10_Parse() {
+	s := test.Require
+
+	s().Equal("SERVICE_ROUTES_MAIN_API", Env("main-api"))
+
+	routes, err := Parse("ping:echo, hello : greet,echo,")
+	s().NoError(err)
+	s().Equal(map[string]string{"ping": "echo", "hello": "greet", "echo": "echo"}, routes)
+
+	routes, err = Parse("")
+	s().NoError(err)
+	s().Empty(routes)
+
+	// the command declared twice
+	_, err = Parse("ping:echo,ping:greet")
+	s().Error(err)
+	// no command or function
+	_, err = Parse(":echo")
+	s().Error(err)
+	_, err = Parse("ping:")
+	s().Error(err)
+	_, err = Parse("ping:echo:greet")
+	s().Error(err)
+}
+
+// Test_11_Registry tests the functions resolved by the declared routes
+func (test *TestRouteMapSuite) Test_11_Registry() {
+	s := test.Require
+
+	registry := NewRegistry[func() string]()
+	s().NoError(registry.Register("echo", func() string { return "echo" }))
+	s().NoError(registry.Register("greet", func() string { return "hello" }))
+	s().Error(registry.Register("echo", func() string { return "" }))
+	s().Error(registry.Register("with space", func() string { return "" }))
+	s().Equal([]string{"echo", "greet"}, registry.Names())
+
+	fn, ok := registry.Lookup("greet")
+	s().True(ok)
+	s().Equal("hello", fn())
+	_, ok = registry.Lookup("missing")
+	s().False(ok)
+
+	resolved, err := registry.Resolve(map[string]string{"ping": "echo", "hello": "greet"})
+	s().NoError(err)
+	s().Len(resolved, 2)
+	s().Equal("echo", resolved["ping"]())
+	s().Equal("hello", resolved["hello"]())
+
+	// none is returned if any function is not registered
+	resolved, err = registry.Resolve(map[string]string{"ping": "echo", "bye": "missing"})
+	s().Error(err)
+	s().Nil(resolved)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestRouteMap(t *testing.T) {
+	suite.Run(t, new(TestRouteMapSuite))
+}
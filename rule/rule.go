@@ -0,0 +1,138 @@
+// Package rule builds the validated serviceConfig.Rule:
+//
+//	r, err := rule.New().Url(url).Categories("api").Commands("get_logs").ExcludeCommands("close").Build()
+//
+// The handlers are targeted by their tags instead of the categories:
+//
+//	r, err := rule.New().Url(url).Tags(tag.Public).Build()
+//
+// The rule type is derived from the fields:
+// the url only is the service rule, with the categories it's the handler rule,
+// with the commands it's the route rule.
+package rule
+
+import (
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/service-lib/tag"
+	"slices"
+	"strings"
+)
+
+// Builder of the rule
+type Builder struct {
+	urls             []string
+	categories       []string
+	tags             []string
+	commands         []string
+	excludedCommands []string
+}
+
+// New returns an empty builder
+func New() *Builder {
+	return &Builder{}
+}
+
+// Url adds the destination service urls
+func (builder *Builder) Url(urls ...string) *Builder {
+	builder.urls = append(builder.urls, urls...)
+	return builder
+}
+
+// Categories adds the destination handler categories
+func (builder *Builder) Categories(categories ...string) *Builder {
+	builder.categories = append(builder.categories, categories...)
+	return builder
+}
+
+// Tags adds the references of the destination handler tags as the categories, see tag.Ref
+func (builder *Builder) Tags(tags ...string) *Builder {
+	for _, t := range tags {
+		builder.tags = append(builder.tags, t)
+		builder.categories = append(builder.categories, tag.Ref(t))
+	}
+	return builder
+}
+
+// Commands adds the destination commands
+func (builder *Builder) Commands(commands ...string) *Builder {
+	builder.commands = append(builder.commands, commands...)
+	return builder
+}
+
+// ExcludeCommands adds the commands that are not routed
+func (builder *Builder) ExcludeCommands(commands ...string) *Builder {
+	builder.excludedCommands = append(builder.excludedCommands, commands...)
+	return builder
+}
+
+// validateList returns an error if the list has the empty or duplicate values
+func validateList(name string, values []string) error {
+	for i, value := range values {
+		if len(strings.TrimSpace(value)) == 0 {
+			return fmt.Errorf("%s[%d] is empty", name, i)
+		}
+		if slices.Index(values, value) != i {
+			return fmt.Errorf("%s has '%s' twice", name, value)
+		}
+	}
+	return nil
+}
+
+// Build returns the rule, or all problems that would make the rule match nothing.
+func (builder *Builder) Build() (*serviceConfig.Rule, error) {
+	problems := make([]string, 0)
+
+	if len(builder.urls) == 0 {
+		problems = append(problems, "no url, call Url")
+	}
+	for name, values := range map[string][]string{
+		"urls":              builder.urls,
+		"categories":        builder.categories,
+		"commands":          builder.commands,
+		"excluded commands": builder.excludedCommands,
+	} {
+		if err := validateList(name, values); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, t := range builder.tags {
+		if err := tag.Validate(t); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(builder.commands) > 0 && len(builder.categories) == 0 {
+		problems = append(problems, "the commands are set without the categories, call Categories or Tags")
+	}
+	for _, command := range builder.excludedCommands {
+		if slices.Contains(builder.commands, command) {
+			problems = append(problems, fmt.Sprintf("'%s' command is both routed and excluded", command))
+		}
+	}
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return nil, fmt.Errorf("invalid rule: %s", strings.Join(problems, "; "))
+	}
+
+	r := &serviceConfig.Rule{
+		Urls:             builder.urls,
+		Categories:       builder.categories,
+		Commands:         builder.commands,
+		ExcludedCommands: builder.excludedCommands,
+	}
+	if r.Categories == nil {
+		r.Categories = []string{}
+	}
+	if r.Commands == nil {
+		r.Commands = []string{}
+	}
+	if r.ExcludedCommands == nil {
+		r.ExcludedCommands = []string{}
+	}
+	if !r.IsValid() {
+		return nil, fmt.Errorf("invalid rule: serviceConfig.Rule.IsValid is false")
+	}
+
+	return r, nil
+}
@@ -0,0 +1,87 @@
+package sandbox
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"syscall"
+	"unsafe"
+)
+
+// The landlock access rights that apply to the files, the rest apply to the directories only
+const landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+	unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+
+// landlockAccess returns the file system access rights handled by the landlock of the abi version
+func landlockAccess(abi int) uint64 {
+	access := uint64(unix.LANDLOCK_ACCESS_FS_MAKE_SYM<<1) - 1
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	return access
+}
+
+// restrictPaths denies all threads of this process the access to the files outside the paths by the landlock.
+// The restriction can't be undone, and it's inherited by the spawned processes.
+func restrictPaths(paths []string) error {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("the landlock is not supported by the kernel: %w", errno)
+	}
+	access := landlockAccess(int(abi))
+
+	attr := unix.LandlockRulesetAttr{Access_fs: access}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock_create_ruleset: %w", errno)
+	}
+	defer func() {
+		_ = unix.Close(int(fd))
+	}()
+
+	for _, path := range paths {
+		if err := allowPath(int(fd), path, access); err != nil {
+			return fmt.Errorf("allowPath('%s'): %w", path, err)
+		}
+	}
+
+	// the go runtime can't restrict all threads of the process that uses cgo
+	if _, _, errno := syscall.AllThreadsSyscall(unix.SYS_PRCTL, unix.PR_SET_NO_NEW_PRIVS, 1, 0); errno == unix.ENOTSUP {
+		return fmt.Errorf("the paths require the binary built with CGO_ENABLED=0: %w", errno)
+	} else if errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_restrict_self: %w", errno)
+	}
+	return nil
+}
+
+// allowPath adds the rule to the ruleset, allowing the access beneath the path
+func allowPath(rulesetFd int, path string, access uint64) error {
+	pathFd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("unix.Open: %w", err)
+	}
+	defer func() {
+		_ = unix.Close(pathFd)
+	}()
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(pathFd, &stat); err != nil {
+		return fmt.Errorf("unix.Fstat: %w", err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileAccess
+	}
+
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(pathFd)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock_add_rule: %w", errno)
+	}
+	return nil
+}
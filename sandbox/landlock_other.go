@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// restrictPaths is not supported on this platform
+func restrictPaths(_ []string) error {
+	return fmt.Errorf("paths option is not supported on %s", runtime.GOOS)
+}
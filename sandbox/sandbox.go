@@ -0,0 +1,193 @@
+// Package sandbox restricts the dependency and extension processes.
+//
+// The Options are set per dependency in the environment of the parent service, see Env:
+//
+//	SERVICE_SANDBOX_DATABASE={"dir":"/srv/db","env":["PATH"],"user":"nobody"}
+//
+// The dependency manager spawns the dependency with the environment of the parent.
+// The dependency applies its options to itself by Self, before it starts the context.
+// The command spawned by this process is restricted by Apply before starting it.
+//
+// The Paths are enforced by Self on Linux with the landlock (kernel 5.13 or later),
+// in the binary built with CGO_ENABLED=0. Otherwise, Self rejects them.
+// Apply only checks the binary and the working directory against the Paths,
+// the spawned process is restricted to them if it applies Self.
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the dependency options in the environment, followed by the upper-cased dependency id.
+// Use Env to get the name.
+const EnvPrefix = "SERVICE_SANDBOX_"
+
+// Env returns the name of the environment variable with the options of the dependency.
+// The dashes and dots in the id are replaced by underscores.
+func Env(id string) string {
+	return EnvPrefix + strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(id))
+}
+
+// Parse returns the options from the JSON declaration.
+// The empty declaration has no options.
+func Parse(declaration string) (Options, error) {
+	var options Options
+	if len(strings.TrimSpace(declaration)) == 0 {
+		return options, nil
+	}
+	if err := json.Unmarshal([]byte(declaration), &options); err != nil {
+		return Options{}, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return options, nil
+}
+
+// Options of the dependency process
+type Options struct {
+	Dir   string   `json:"dir,omitempty" yaml:"dir,omitempty"`     // working directory
+	Env   []string `json:"env,omitempty" yaml:"env,omitempty"`     // names of the environment variables passed to the process
+	User  string   `json:"user,omitempty" yaml:"user,omitempty"`   // user name or uid to run the process as
+	Group string   `json:"group,omitempty" yaml:"group,omitempty"` // group name or gid to run the process as
+	// Paths the process is allowed to access, the rest of the file system is denied by Self.
+	// The binary and the working directory must be in the paths.
+	// If Root is set, the paths are relative to the root.
+	Paths []string `json:"paths,omitempty" yaml:"paths,omitempty"`
+	// Root directory the process is jailed in (chroot), requires privileges
+	Root string `json:"root,omitempty" yaml:"root,omitempty"`
+}
+
+// IsZero returns true if no options are set
+func (options Options) IsZero() bool {
+	return options.Dir == "" && len(options.Env) == 0 && options.User == "" && options.Group == "" &&
+		len(options.Paths) == 0 && options.Root == ""
+}
+
+// FilterEnv returns the environment variables from the env whose names are in the whitelist
+func FilterEnv(env []string, whitelist []string) []string {
+	allowed := make(map[string]struct{}, len(whitelist))
+	for _, name := range whitelist {
+		allowed[name] = struct{}{}
+	}
+
+	filtered := make([]string, 0, len(whitelist))
+	for _, pair := range env {
+		name, _, _ := strings.Cut(pair, "=")
+		if _, ok := allowed[name]; ok {
+			filtered = append(filtered, pair)
+		}
+	}
+	return filtered
+}
+
+// Allowed returns true if the path is inside one of the allowed paths.
+// If no paths are given, any path is allowed.
+func Allowed(path string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+
+	path = filepath.Clean(path)
+	for _, allowed := range paths {
+		rel, err := filepath.Rel(filepath.Clean(allowed), path)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the options to the command that is not started yet
+func Apply(cmd *exec.Cmd, options Options) error {
+	if cmd.Process != nil {
+		return fmt.Errorf("the process already started")
+	}
+
+	if len(options.Paths) > 0 {
+		if !Allowed(cmd.Path, options.Paths) {
+			return fmt.Errorf("binary '%s' is not in the allowed paths", cmd.Path)
+		}
+		if options.Dir != "" && !Allowed(options.Dir, options.Paths) {
+			return fmt.Errorf("working directory '%s' is not in the allowed paths", options.Dir)
+		}
+	}
+
+	if options.Dir != "" {
+		cmd.Dir = options.Dir
+	}
+
+	if len(options.Env) > 0 {
+		env := cmd.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		cmd.Env = FilterEnv(env, options.Env)
+	}
+
+	if err := applySys(cmd, options); err != nil {
+		return fmt.Errorf("applySys: %w", err)
+	}
+
+	return nil
+}
+
+// Self applies the options to this process.
+// The process is jailed in the root first, then it changes the working directory,
+// removes the environment variables that are not whitelisted, and drops the privileges.
+// At last, the access to the files outside the paths is denied.
+//
+// Call it before opening any sockets or files, as they are kept after the jail.
+func Self(options Options) error {
+	if len(options.Paths) > 0 {
+		// the binary is outside the jail, its path can't be checked against the jailed paths
+		if options.Root == "" {
+			binary, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("os.Executable: %w", err)
+			}
+			if !Allowed(binary, options.Paths) {
+				return fmt.Errorf("binary '%s' is not in the allowed paths", binary)
+			}
+		}
+		if options.Dir != "" && !Allowed(options.Dir, options.Paths) {
+			return fmt.Errorf("working directory '%s' is not in the allowed paths", options.Dir)
+		}
+	}
+
+	if options.Root != "" {
+		if err := chroot(options.Root); err != nil {
+			return fmt.Errorf("chroot('%s'): %w", options.Root, err)
+		}
+	}
+	if options.Dir != "" {
+		if err := os.Chdir(options.Dir); err != nil {
+			return fmt.Errorf("os.Chdir('%s'): %w", options.Dir, err)
+		}
+	}
+	if len(options.Env) > 0 {
+		whitelisted := FilterEnv(os.Environ(), options.Env)
+		os.Clearenv()
+		for _, pair := range whitelisted {
+			name, value, _ := strings.Cut(pair, "=")
+			if err := os.Setenv(name, value); err != nil {
+				return fmt.Errorf("os.Setenv('%s'): %w", name, err)
+			}
+		}
+	}
+
+	if err := dropPrivileges(options); err != nil {
+		return fmt.Errorf("dropPrivileges: %w", err)
+	}
+	if len(options.Paths) > 0 {
+		if err := restrictPaths(options.Paths); err != nil {
+			return fmt.Errorf("restrictPaths: %w", err)
+		}
+	}
+	return nil
+}
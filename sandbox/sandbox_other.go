@@ -0,0 +1,30 @@
+//go:build !(linux || darwin || freebsd)
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// applySys is not supported on this platform
+func applySys(_ *exec.Cmd, options Options) error {
+	if options.User != "" || options.Group != "" || options.Root != "" {
+		return fmt.Errorf("user, group and root options are not supported on %s", runtime.GOOS)
+	}
+	return nil
+}
+
+// chroot is not supported on this platform
+func chroot(_ string) error {
+	return fmt.Errorf("root option is not supported on %s", runtime.GOOS)
+}
+
+// dropPrivileges is not supported on this platform
+func dropPrivileges(options Options) error {
+	if options.User != "" || options.Group != "" {
+		return fmt.Errorf("user and group options are not supported on %s", runtime.GOOS)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package sandbox
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestSandboxSuite struct {
+	suite.Suite
+}
+
+// Test_10_Apply tests the working directory, environment and paths
+func (test *TestSandboxSuite) Test_10_Apply() {
+	s := test.Require
+
+	s().True(Allowed("/opt/deps/bin/service", []string{"/opt/deps"}))
+	s().True(Allowed("/opt/deps", []string{"/opt/deps"}))
+	s().False(Allowed("/opt/deps-other/service", []string{"/opt/deps"}))
+	s().False(Allowed("/opt/deps/../etc", []string{"/opt/deps"}))
+	s().True(Allowed("/anything", nil))
+
+	env := FilterEnv([]string{"HOME=/root", "SERVICE_ID=a", "SECRET=b"}, []string{"SERVICE_ID", "HOME"})
+	s().Equal([]string{"HOME=/root", "SERVICE_ID=a"}, env)
+
+	cmd := &exec.Cmd{Path: "/opt/deps/service", Env: []string{"SERVICE_ID=a", "SECRET=b"}}
+	err := Apply(cmd, Options{Dir: "/opt/deps/data", Env: []string{"SERVICE_ID"}, Paths: []string{"/opt/deps"}})
+	s().NoError(err)
+	s().Equal("/opt/deps/data", cmd.Dir)
+	s().Equal([]string{"SERVICE_ID=a"}, cmd.Env)
+
+	// working directory outside of the paths
+	cmd = &exec.Cmd{Path: "/opt/deps/service"}
+	s().Error(Apply(cmd, Options{Dir: "/tmp", Paths: []string{"/opt/deps"}}))
+
+	// binary outside of the paths
+	cmd = &exec.Cmd{Path: "/usr/bin/service"}
+	s().Error(Apply(cmd, Options{Paths: []string{"/opt/deps"}}))
+}
+
+// Test_11_Self tests the options declared for the dependency and applied to this process
+func (test *TestSandboxSuite) Test_11_Self() {
+	s := test.Require
+
+	s().Equal("SERVICE_SANDBOX_MY_DB", Env("my-db"))
+
+	options, err := Parse("")
+	s().NoError(err)
+	s().True(options.IsZero())
+	_, err = Parse("dir=/tmp")
+	s().Error(err)
+
+	dir := test.T().TempDir()
+	options, err = Parse(`{"dir":"` + dir + `","env":["SANDBOX_KEEP"]}`)
+	s().NoError(err)
+	s().Equal(Options{Dir: dir, Env: []string{"SANDBOX_KEEP"}}, options)
+
+	// restore this process after the test
+	cwd, err := os.Getwd()
+	s().NoError(err)
+	environ := os.Environ()
+	defer func() {
+		s().NoError(os.Chdir(cwd))
+		os.Clearenv()
+		for _, pair := range environ {
+			name, value, _ := strings.Cut(pair, "=")
+			s().NoError(os.Setenv(name, value))
+		}
+	}()
+	s().NoError(os.Setenv("SANDBOX_KEEP", "1"))
+	s().NoError(os.Setenv("SANDBOX_DROP", "1"))
+
+	// the working directory outside the paths is rejected before any change
+	s().Error(Self(Options{Dir: dir, Env: []string{"SANDBOX_KEEP"}, Paths: []string{"/nonexistent"}}))
+	s().Equal("1", os.Getenv("SANDBOX_DROP"))
+
+	s().NoError(Self(options))
+	wd, err := os.Getwd()
+	s().NoError(err)
+	expected, err := filepath.EvalSymlinks(dir)
+	s().NoError(err)
+	actual, err := filepath.EvalSymlinks(wd)
+	s().NoError(err)
+	s().Equal(expected, actual)
+	s().Equal("1", os.Getenv("SANDBOX_KEEP"))
+	s().Empty(os.Getenv("SANDBOX_DROP"))
+}
+
+// pathsChildEnv runs Test_12_Paths as the restricted child process, with the allowed directory
+const pathsChildEnv = "SANDBOX_TEST_PATHS"
+
+// Test_12_Paths tests that the files outside the paths are denied after Self.
+// The restriction can't be undone, so it's applied in the child process.
+func (test *TestSandboxSuite) Test_12_Paths() {
+	s := test.Require
+
+	if dir := os.Getenv(pathsChildEnv); dir != "" {
+		if err := Self(Options{Paths: []string{dir, os.Args[0]}}); err != nil {
+			test.T().Skip(err.Error())
+		}
+		_, err := os.ReadFile(filepath.Join(dir, "allowed"))
+		s().NoError(err)
+		_, err = os.ReadDir(filepath.Dir(dir))
+		s().ErrorIs(err, os.ErrPermission)
+		return
+	}
+
+	dir := test.T().TempDir()
+	s().NoError(os.WriteFile(filepath.Join(dir, "allowed"), []byte("1"), 0600))
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestSandbox/Test_12_Paths", "-test.v")
+	cmd.Env = append(os.Environ(), pathsChildEnv+"="+dir)
+	out, err := cmd.CombinedOutput()
+	s().NoError(err, string(out))
+	if strings.Contains(string(out), "--- SKIP") {
+		test.T().Skip("the paths are not enforced on this platform")
+	}
+	s().Contains(string(out), "--- PASS: TestSandbox/Test_12_Paths")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSandbox(t *testing.T) {
+	suite.Run(t, new(TestSandboxSuite))
+}
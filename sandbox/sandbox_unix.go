@@ -0,0 +1,116 @@
+//go:build linux || darwin || freebsd
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// applySys sets the user, group and the root of the process
+func applySys(cmd *exec.Cmd, options Options) error {
+	if options.User == "" && options.Group == "" && options.Root == "" {
+		return nil
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	if options.Root != "" {
+		cmd.SysProcAttr.Chroot = options.Root
+	}
+
+	if options.User == "" && options.Group == "" {
+		return nil
+	}
+
+	credential := &syscall.Credential{}
+	if options.User != "" {
+		u, err := lookupUser(options.User)
+		if err != nil {
+			return fmt.Errorf("lookupUser('%s'): %w", options.User, err)
+		}
+		uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+		credential.Uid = uint32(uid)
+		credential.Gid = uint32(gid)
+	}
+	if options.Group != "" {
+		g, err := lookupGroup(options.Group)
+		if err != nil {
+			return fmt.Errorf("lookupGroup('%s'): %w", options.Group, err)
+		}
+		gid, _ := strconv.ParseUint(g.Gid, 10, 32)
+		credential.Gid = uint32(gid)
+	}
+	cmd.SysProcAttr.Credential = credential
+
+	return nil
+}
+
+// chroot jails this process in the root, and changes the working directory to it
+func chroot(root string) error {
+	if err := syscall.Chroot(root); err != nil {
+		return fmt.Errorf("syscall.Chroot: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return fmt.Errorf("os.Chdir('/'): %w", err)
+	}
+	return nil
+}
+
+// dropPrivileges sets the group and the user of this process.
+// The group is set first, as the user may have no right to change it.
+func dropPrivileges(options Options) error {
+	uid, gid := -1, -1
+	if options.User != "" {
+		u, err := lookupUser(options.User)
+		if err != nil {
+			return fmt.Errorf("lookupUser('%s'): %w", options.User, err)
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+		gid, _ = strconv.Atoi(u.Gid)
+	}
+	if options.Group != "" {
+		g, err := lookupGroup(options.Group)
+		if err != nil {
+			return fmt.Errorf("lookupGroup('%s'): %w", options.Group, err)
+		}
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+
+	if gid >= 0 {
+		if err := syscall.Setgroups([]int{}); err != nil {
+			return fmt.Errorf("syscall.Setgroups: %w", err)
+		}
+		if err := syscall.Setgid(gid); err != nil {
+			return fmt.Errorf("syscall.Setgid(%d): %w", gid, err)
+		}
+	}
+	if uid >= 0 {
+		if err := syscall.Setuid(uid); err != nil {
+			return fmt.Errorf("syscall.Setuid(%d): %w", uid, err)
+		}
+	}
+	return nil
+}
+
+// lookupUser by the name or by the uid
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return user.LookupId(name)
+	}
+	return user.Lookup(name)
+}
+
+// lookupGroup by the name or by the gid
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return user.LookupGroupId(name)
+	}
+	return user.LookupGroup(name)
+}
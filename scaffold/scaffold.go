@@ -0,0 +1,111 @@
+// Package scaffold generates the new service from the templates:
+//
+//	service-lib new greeter --module=github.com/ahmetson/greeter
+//
+// The generated service has the main.go with the command line interface,
+// a handler with the sample route, the tests on the servicetest harness and a Dockerfile.
+package scaffold
+
+import (
+	"embed"
+	"fmt"
+	"github.com/ahmetson/service-lib/flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Category of the sample handler
+const Category = "main"
+
+// namePattern is the valid service name, it's also the binary name
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Params of the generated service
+type Params struct {
+	Name   string // the binary name and the service id
+	Module string // the go module path, it's the service url
+}
+
+// data of the templates
+type data struct {
+	Params
+	Category     string
+	IdEnv        string
+	UrlEnv       string
+	ContainerEnv string
+	PortEnv      string
+}
+
+// files are the generated file names by their templates
+var files = map[string]string{
+	"main.go.tmpl":      "main.go",
+	"handler.go.tmpl":   "handler.go",
+	"main_test.go.tmpl": "main_test.go",
+	"go.mod.tmpl":       "go.mod",
+	"Dockerfile.tmpl":   "Dockerfile",
+}
+
+// Validate returns an error if the service can not be generated with the parameters
+func (params Params) Validate() error {
+	if !namePattern.MatchString(params.Name) {
+		return fmt.Errorf("name '%s' must be lowercase letters, digits and dashes, starting with a letter", params.Name)
+	}
+	if len(params.Module) == 0 || strings.ContainsAny(params.Module, " \t\n") {
+		return fmt.Errorf("module '%s' is not a valid module path", params.Module)
+	}
+	return nil
+}
+
+// Generate writes the service files into the directory.
+// The directory is created if it doesn't exist. The existing files are never overwritten.
+func Generate(dir string, params Params) ([]string, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return nil, fmt.Errorf("'%s' exists already", filepath.Join(dir, name))
+		}
+	}
+
+	parsed, err := template.ParseFS(templates, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("template.ParseFS: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll('%s'): %w", dir, err)
+	}
+
+	d := data{
+		Params:       params,
+		Category:     Category,
+		IdEnv:        flag.IdEnv,
+		UrlEnv:       flag.UrlEnv,
+		ContainerEnv: flag.ContainerEnv,
+		PortEnv:      flag.PortEnv(Category),
+	}
+	written := make([]string, 0, len(files))
+	for tmpl, name := range files {
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return written, fmt.Errorf("os.OpenFile('%s'): %w", path, err)
+		}
+		err = parsed.ExecuteTemplate(f, tmpl, d)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return written, fmt.Errorf("template('%s'): %w", tmpl, err)
+		}
+		written = append(written, path)
+	}
+
+	return written, nil
+}
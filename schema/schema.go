@@ -0,0 +1,251 @@
+// Package schema validates the request parameters before the route function runs.
+//
+// Declare the schema per route:
+//
+//	getLogs := schema.New(
+//		schema.Required("network_id", schema.String),
+//		schema.Required("block_from", schema.Number).Range(0, math.MaxUint64),
+//		schema.Optional("limit", schema.Number).Range(1, 1000),
+//	)
+//
+// All violations are collected in a single InvalidParam reply.
+// The reply keeps the violations in the ViolationsParam parameter, see FromParameters.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InvalidParam is the prefix of the error message when the parameters are not valid
+const InvalidParam = "invalid_param"
+
+// ViolationsParam is the reply parameter with the violations
+const ViolationsParam = "violations"
+
+// The rules violated by the parameters
+const (
+	RequiredRule = "required" // the parameter is missing, or zero in the struct
+	TypeRule     = "type"     // the parameter has another type
+	RangeRule    = "range"    // the number or the length is out of the range
+	MinRule      = "min"      // the number or the length is less than the min tag
+	MaxRule      = "max"      // the number or the length is more than the max tag
+)
+
+// Type of the parameter value
+type Type string
+
+const (
+	Any    Type = "any"
+	String Type = "string"
+	Number Type = "number"
+	Bool   Type = "bool"
+	Object Type = "object"
+	List   Type = "list"
+)
+
+// Field is the declaration of the parameter
+type Field struct {
+	Name     string
+	Type     Type
+	Required bool
+	min      *float64
+	max      *float64
+}
+
+// Required declares the parameter that must be set
+func Required(name string, fieldType Type) Field {
+	return Field{Name: name, Type: fieldType, Required: true}
+}
+
+// Optional declares the parameter that may be omitted
+func Optional(name string, fieldType Type) Field {
+	return Field{Name: name, Type: fieldType}
+}
+
+// Range limits the number parameter by min and max inclusive.
+// For the strings and lists, the length is limited.
+func (field Field) Range(min float64, max float64) Field {
+	field.min = &min
+	field.max = &max
+	return field
+}
+
+// Violation is the rule that the parameter doesn't pass
+type Violation struct {
+	Field  string `json:"field"`
+	Rule   string `json:"rule"`
+	Detail string `json:"detail,omitempty"` // the value compared with the rule
+}
+
+// Violations is the list of the violated parameters
+type Violations []Violation
+
+// Error returns all violations in one line prefixed by InvalidParam
+func (violations Violations) Error() string {
+	reasons := make([]string, len(violations))
+	for i, violation := range violations {
+		if len(violation.Detail) == 0 {
+			reasons[i] = fmt.Sprintf("%s: %s", violation.Field, violation.Rule)
+			continue
+		}
+		reasons[i] = fmt.Sprintf("%s: %s: %s", violation.Field, violation.Rule, violation.Detail)
+	}
+	return fmt.Sprintf("%s: %s", InvalidParam, strings.Join(reasons, "; "))
+}
+
+// FromParameters returns the violations from the ViolationsParam of the reply parameters.
+// Returns nil if the parameters have no violations.
+func FromParameters(params map[string]interface{}) (Violations, error) {
+	raw, ok := params[ViolationsParam]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal('%s'): %w", ViolationsParam, err)
+	}
+	var violations Violations
+	if err := json.Unmarshal(encoded, &violations); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal('%s'): %w", ViolationsParam, err)
+	}
+	return violations, nil
+}
+
+// Schema is the list of the parameters of the route
+type Schema struct {
+	fields []Field
+}
+
+// New schema with the declared parameters
+func New(fields ...Field) *Schema {
+	return &Schema{fields: fields}
+}
+
+// number converts the numeric value into float64
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// size returns the value to compare with the range
+func size(fieldType Type, value interface{}) (float64, bool) {
+	switch fieldType {
+	case Number:
+		return number(value)
+	case String:
+		return float64(len(value.(string))), true
+	case List:
+		return float64(len(value.([]interface{}))), true
+	}
+	return 0, false
+}
+
+// matches returns true if the value has the type
+func matches(fieldType Type, value interface{}) bool {
+	switch fieldType {
+	case Any:
+		return true
+	case String:
+		_, ok := value.(string)
+		return ok
+	case Number:
+		_, ok := number(value)
+		return ok
+	case Bool:
+		_, ok := value.(bool)
+		return ok
+	case Object:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case List:
+		_, ok := value.([]interface{})
+		return ok
+	}
+	return false
+}
+
+// Validate returns all violations of the parameters.
+// Returns nil if the parameters are valid.
+func (schema *Schema) Validate(params map[string]interface{}) Violations {
+	var violations Violations
+
+	for _, field := range schema.fields {
+		value, ok := params[field.Name]
+		if !ok || value == nil {
+			if field.Required {
+				violations = append(violations, Violation{Field: field.Name, Rule: RequiredRule})
+			}
+			continue
+		}
+
+		if !matches(field.Type, value) {
+			violations = append(violations, Violation{
+				Field:  field.Name,
+				Rule:   TypeRule,
+				Detail: fmt.Sprintf("expected %s, got %T", field.Type, value),
+			})
+			continue
+		}
+
+		if field.min == nil {
+			continue
+		}
+		compared, ok := size(field.Type, value)
+		if !ok {
+			continue
+		}
+		if compared < *field.min || compared > *field.max {
+			violations = append(violations, Violation{
+				Field:  field.Name,
+				Rule:   RangeRule,
+				Detail: fmt.Sprintf("%v is out of [%v, %v]", compared, *field.min, *field.max),
+			})
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Field < violations[j].Field
+	})
+
+	return violations
+}
+
+// Route wraps the route function, so it's called only with the valid parameters.
+// The paramsOf returns the parameters of the request.
+// The onInvalid returns the reply with all violations.
+func Route[Req any, Rep any](schema *Schema, paramsOf func(Req) map[string]interface{}, onInvalid func(Req, Violations) Rep, handle func(Req) Rep) func(Req) Rep {
+	return func(req Req) Rep {
+		if violations := schema.Validate(paramsOf(req)); len(violations) > 0 {
+			return onInvalid(req, violations)
+		}
+		return handle(req)
+	}
+}
@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestSchemaSuite struct {
+	suite.Suite
+
+	schema *Schema
+}
+
+func (test *TestSchemaSuite) SetupTest() {
+	test.schema = New(
+		Required("topic", String).Range(1, 64),
+		Required("from", Number).Range(0, 1000),
+		Optional("to", Number),
+		Optional("verbose", Bool),
+	)
+}
+
+// Test_10_Validate tests that all violations are collected
+func (test *TestSchemaSuite) Test_10_Validate() {
+	s := test.Require
+
+	violations := test.schema.Validate(map[string]interface{}{
+		"topic": "block",
+		"from":  float64(1),
+		"to":    uint64(10),
+	})
+	s().Empty(violations)
+
+	violations = test.schema.Validate(map[string]interface{}{
+		"from":    float64(1001),
+		"to":      "10",
+		"verbose": true,
+	})
+	s().Len(violations, 3)
+	s().Equal(Violation{Field: "from", Rule: RangeRule, Detail: "1001 is out of [0, 1000]"}, violations[0])
+	s().Equal(Violation{Field: "to", Rule: TypeRule, Detail: "expected number, got string"}, violations[1])
+	s().Equal(Violation{Field: "topic", Rule: RequiredRule}, violations[2])
+	s().Contains(violations.Error(), InvalidParam)
+	s().Contains(violations.Error(), "topic: required")
+
+	// the violations are restored from the reply parameters
+	params := map[string]interface{}{ViolationsParam: violations}
+	restored, err := FromParameters(params)
+	s().NoError(err)
+	s().Equal(violations, restored)
+	restored, err = FromParameters(map[string]interface{}{})
+	s().NoError(err)
+	s().Nil(restored)
+	_, err = FromParameters(map[string]interface{}{ViolationsParam: "invalid"})
+	s().Error(err)
+
+	// the length of the string is out of range
+	violations = test.schema.Validate(map[string]interface{}{
+		"topic": "",
+		"from":  0,
+	})
+	s().Len(violations, 1)
+	s().Equal("topic", violations[0].Field)
+}
+
+// Test_11_Route tests that the route function is not called with the invalid parameters
+func (test *TestSchemaSuite) Test_11_Route() {
+	s := test.Require
+
+	called := false
+	handle := Route(test.schema, func(req map[string]interface{}) map[string]interface{} {
+		return req
+	}, func(req map[string]interface{}, violations Violations) string {
+		return violations.Error()
+	}, func(req map[string]interface{}) string {
+		called = true
+		return "ok"
+	})
+
+	s().Contains(handle(map[string]interface{}{}), InvalidParam)
+	s().False(called)
+
+	s().Equal("ok", handle(map[string]interface{}{"topic": "block", "from": 1}))
+	s().True(called)
+}
+
+// Test_12_ValidateStruct tests the validation rules in the struct tags
+func (test *TestSchemaSuite) Test_12_ValidateStruct() {
+	s := test.Require
+
+	type getLogs struct {
+		NetworkId string   `json:"network_id" validate:"required"`
+		Limit     uint64   `json:"limit" validate:"min=1,max=1000"`
+		Topics    []string `validate:"max=2"`
+		Ignored   string
+	}
+
+	violations, err := ValidateStruct(&getLogs{NetworkId: "1", Limit: 10})
+	s().NoError(err)
+	s().Empty(violations)
+
+	violations, err = ValidateStruct(getLogs{Limit: 1001, Topics: []string{"a", "b", "c"}})
+	s().NoError(err)
+	s().Len(violations, 3)
+	s().Equal(Violation{Field: "network_id", Rule: RequiredRule}, violations[0])
+	s().Equal(Violation{Field: "limit", Rule: MaxRule, Detail: "1001 is beyond 1000"}, violations[1])
+	s().Equal("Topics", violations[2].Field)
+	s().Equal(MaxRule, violations[2].Rule)
+
+	_, err = ValidateStruct(1)
+	s().Error(err)
+
+	type unknownRule struct {
+		Id string `validate:"email"`
+	}
+	_, err = ValidateStruct(unknownRule{})
+	s().Error(err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSchema(t *testing.T) {
+	suite.Run(t, new(TestSchemaSuite))
+}
@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Tag is the struct field tag with the validation rules.
+//
+//	type GetLogs struct {
+//		NetworkId string `json:"network_id" validate:"required"`
+//		Limit     uint64 `json:"limit" validate:"min=1,max=1000"`
+//	}
+//
+// The 'required' rule fails on the zero value.
+// The 'min' and 'max' rules limit the numbers, and the length of the strings, slices and maps.
+const Tag = "validate"
+
+// fieldName returns the name of the field as it's in the parameters
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if len(name) == 0 || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// measure returns the number or the length of the value
+func measure(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len()), true
+	}
+	return 0, false
+}
+
+// ValidateStruct returns the violations of the validation rules set in the struct field tags.
+// The v must be a struct or a pointer to the struct.
+func ValidateStruct(v interface{}) (Violations, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, fmt.Errorf("nil pointer")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %s", value.Kind())
+	}
+
+	var violations Violations
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		rules := field.Tag.Get(Tag)
+		if len(rules) == 0 || !field.IsExported() {
+			continue
+		}
+
+		name := fieldName(field)
+		fieldValue := value.Field(i)
+		for _, rule := range strings.Split(rules, ",") {
+			rule = strings.TrimSpace(rule)
+			if rule == RequiredRule {
+				if fieldValue.IsZero() {
+					violations = append(violations, Violation{Field: name, Rule: RequiredRule})
+				}
+				continue
+			}
+
+			key, limitStr, ok := strings.Cut(rule, "=")
+			if !ok || (key != MinRule && key != MaxRule) {
+				return nil, fmt.Errorf("field '%s': unknown rule '%s'", field.Name, rule)
+			}
+			limit, err := strconv.ParseFloat(limitStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s': strconv.ParseFloat('%s'): %w", field.Name, limitStr, err)
+			}
+			measured, ok := measure(fieldValue)
+			if !ok {
+				return nil, fmt.Errorf("field '%s': the '%s' rule is not supported by %s", field.Name, key, fieldValue.Kind())
+			}
+			if (key == MinRule && measured < limit) || (key == MaxRule && measured > limit) {
+				violations = append(violations, Violation{
+					Field:  name,
+					Rule:   key,
+					Detail: fmt.Sprintf("%v is beyond %v", measured, limit),
+				})
+			}
+		}
+	}
+
+	return violations, nil
+}
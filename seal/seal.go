@@ -0,0 +1,154 @@
+// Package seal encrypts the files at rest.
+//
+// If the KeyEnv environment variable is set, the files written by WriteFile are encrypted by AES-256-GCM,
+// and ReadFile transparently decrypts them. The plain files are read as is, so the encryption can be enabled
+// on the existing files.
+//
+// The service seals its snapshots, as they embed the effective configuration with the CURVE secret keys.
+//
+// The configuration files themselves are not sealed.
+// They are written and read by github.com/ahmetson/config-lib, which doesn't use this package.
+// Protect them by the file permissions, or keep the secret keys out of them.
+//
+// The key is 32 bytes encoded in base64:
+//
+//	export SERVICE_CONFIG_KEY=$(head -c 32 /dev/urandom | base64)
+package seal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+)
+
+// KeyEnv is the environment variable with the base64 encoded key
+const KeyEnv = "SERVICE_CONFIG_KEY"
+
+// KeySize is the size of the AES-256 key
+const KeySize = 32
+
+// Header is the prefix of the encrypted data
+var Header = []byte("sds-sealed:v1\n")
+
+// Key returns the key from the environment.
+// Returns false if the encryption is not enabled.
+func Key() ([]byte, bool, error) {
+	encoded := os.Getenv(KeyEnv)
+	if len(encoded) == 0 {
+		return nil, false, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false, fmt.Errorf("base64.DecodeString('%s'): %w", KeyEnv, err)
+	}
+	if len(key) != KeySize {
+		return nil, false, fmt.Errorf("'%s' must be %d bytes, not %d", KeyEnv, KeySize, len(key))
+	}
+	return key, true, nil
+}
+
+// IsSealed returns true if the data is encrypted by Seal
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, Header)
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher.NewGCM: %w", err)
+	}
+	return aead, nil
+}
+
+// Seal encrypts the data. The result is the Header, followed by the nonce and the cipher text.
+func Seal(key, data []byte) ([]byte, error) {
+	aead, err := newAead(key)
+	if err != nil {
+		return nil, fmt.Errorf("newAead: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("rand.Read: %w", err)
+	}
+
+	sealed := make([]byte, 0, len(Header)+len(nonce)+len(data)+aead.Overhead())
+	sealed = append(sealed, Header...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, data, Header), nil
+}
+
+// Open decrypts the data encrypted by Seal
+func Open(key, sealed []byte) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, fmt.Errorf("the data is not sealed")
+	}
+	aead, err := newAead(key)
+	if err != nil {
+		return nil, fmt.Errorf("newAead: %w", err)
+	}
+
+	body := sealed[len(Header):]
+	if len(body) < aead.NonceSize() {
+		return nil, fmt.Errorf("the sealed data is truncated")
+	}
+	nonce, cipherText := body[:aead.NonceSize()], body[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, cipherText, Header)
+	if err != nil {
+		return nil, fmt.Errorf("aead.Open: %w", err)
+	}
+	return data, nil
+}
+
+// WriteFile writes the data encrypted if the key is set in the environment.
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	key, enabled, err := Key()
+	if err != nil {
+		return fmt.Errorf("Key: %w", err)
+	}
+	if enabled {
+		data, err = Seal(key, data)
+		if err != nil {
+			return fmt.Errorf("Seal: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(name, data, perm); err != nil {
+		return fmt.Errorf("os.WriteFile('%s'): %w", name, err)
+	}
+	return nil
+}
+
+// ReadFile reads the file, decrypting it if it's sealed.
+// The sealed file requires the key in the environment.
+func ReadFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile('%s'): %w", name, err)
+	}
+	if !IsSealed(data) {
+		return data, nil
+	}
+
+	key, enabled, err := Key()
+	if err != nil {
+		return nil, fmt.Errorf("Key: %w", err)
+	}
+	if !enabled {
+		return nil, fmt.Errorf("'%s' is sealed, set the key in '%s'", name, KeyEnv)
+	}
+	data, err = Open(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("Open('%s'): %w", name, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,87 @@
+package seal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestSealSuite struct {
+	suite.Suite
+}
+
+// Test_10_Seal tests the encryption and decryption
+func (test *TestSealSuite) Test_10_Seal() {
+	s := test.Require
+
+	key := bytes.Repeat([]byte{1}, KeySize)
+	data := []byte("secret_key: abc")
+
+	sealed, err := Seal(key, data)
+	s().NoError(err)
+	s().True(IsSealed(sealed))
+	s().NotContains(string(sealed), "abc")
+
+	opened, err := Open(key, sealed)
+	s().NoError(err)
+	s().Equal(data, opened)
+
+	// the wrong key and the modified data are rejected
+	_, err = Open(bytes.Repeat([]byte{2}, KeySize), sealed)
+	s().Error(err)
+	sealed[len(sealed)-1] ^= 1
+	_, err = Open(key, sealed)
+	s().Error(err)
+	_, err = Open(key, data)
+	s().Error(err)
+}
+
+// Test_11_File tests the transparent encryption of the files
+func (test *TestSealSuite) Test_11_File() {
+	s := test.Require
+
+	name := filepath.Join(test.T().TempDir(), "app.yml")
+	data := []byte("services: []")
+
+	// without the key, the file is plain
+	test.T().Setenv(KeyEnv, "")
+	s().NoError(WriteFile(name, data, 0600))
+	raw, err := os.ReadFile(name)
+	s().NoError(err)
+	s().Equal(data, raw)
+
+	test.T().Setenv(KeyEnv, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize)))
+	// the plain file is still readable
+	read, err := ReadFile(name)
+	s().NoError(err)
+	s().Equal(data, read)
+
+	s().NoError(WriteFile(name, data, 0600))
+	raw, err = os.ReadFile(name)
+	s().NoError(err)
+	s().True(IsSealed(raw))
+	read, err = ReadFile(name)
+	s().NoError(err)
+	s().Equal(data, read)
+
+	// the sealed file requires the key
+	test.T().Setenv(KeyEnv, "")
+	_, err = ReadFile(name)
+	s().ErrorContains(err, KeyEnv)
+
+	test.T().Setenv(KeyEnv, base64.StdEncoding.EncodeToString([]byte("short")))
+	_, _, err = Key()
+	s().Error(err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSeal(t *testing.T) {
+	suite.Run(t, new(TestSealSuite))
+}
@@ -12,14 +12,33 @@ import (
 	clientConfig "github.com/ahmetson/client-lib/config"
 	serviceConfig "github.com/ahmetson/config-lib/service"
 	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
 	context "github.com/ahmetson/dev-lib"
 	"github.com/ahmetson/handler-lib/base"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
 	"github.com/ahmetson/handler-lib/manager_client"
 	"github.com/ahmetson/log-lib"
 	"github.com/ahmetson/os-lib/arg"
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/deprecation"
+	"github.com/ahmetson/service-lib/errs"
+	"github.com/ahmetson/service-lib/featureflag"
 	"github.com/ahmetson/service-lib/flag"
+	"github.com/ahmetson/service-lib/ha"
+	"github.com/ahmetson/service-lib/limits"
 	"github.com/ahmetson/service-lib/manager"
-	"slices"
+	"github.com/ahmetson/service-lib/monitor"
+	"github.com/ahmetson/service-lib/namespace"
+	"github.com/ahmetson/service-lib/priority"
+	"github.com/ahmetson/service-lib/publisher"
+	"github.com/ahmetson/service-lib/quota"
+	"github.com/ahmetson/service-lib/replycache"
+	"github.com/ahmetson/service-lib/restart"
+	"github.com/ahmetson/service-lib/slo"
+	"github.com/ahmetson/service-lib/tag"
+	"github.com/ahmetson/service-lib/tap"
+	"github.com/ahmetson/service-lib/timestamp"
+	"net/http"
 	"sync"
 )
 
@@ -33,65 +52,102 @@ type Service struct {
 	id                 string
 	url                string
 	blocker            *sync.WaitGroup
-	manager            *manager.Manager // manage this service from other parts
+	manager            *manager.Manager           // manage this service from other parts
+	elector            ha.Elector                 // elects the leader among the instances, optional
+	feed               *broadcast.Feed            // the sequenced broadcasts served by the catch-up command, optional
+	publisher          *publisher.Publisher       // sends the broadcasts of the feed to the subscribers, optional
+	lanes              map[string]*priority.Lanes // the lanes of the handler categories, see SetLanes
+	monitor            *monitor.Monitor           // tracks the connection health of the sockets, optional
+	enforcer           *limits.Enforcer           // reports the resource violations of the dependencies, optional
+	health             *http.Server               // the health endpoint in the container mode
+	lazy               map[string]bool            // the categories of the handlers started on the first request
+	lazyMu             sync.Mutex
+	serving            map[string]bool // the ids of the handlers confirmed as serving, only their units are published
+	servingMu          sync.Mutex
+	timeouts           Timeouts
+	overrides          map[string]flag.Override // the handler configurations set by the flags
+	routes             *deprecation.Registry    // the versions and deprecations of the routes
+	namespace          string                   // the tenant of the service, the id and url are qualified by it
+	acl                *namespace.ACL           // the namespaces allowed to connect to this service
+	hooks              map[Stage][]hook         // the lifecycle hooks in the order of adding
+	deps               Deps                     // the clients of the extensions used by RouteDeps
+	depsMu             sync.Mutex
+	contracts          map[string]*Contract // the commands required from the extensions by their id
+	optional           map[string]bool      // the extensions that may fail without failing the service
+	degraded           map[string]error     // the failed optional extensions
+	degradedMu         sync.Mutex
+	restarts           map[string]*restart.Tracker // the restart policies by the handler category or extension id
+	restartsMu         sync.Mutex
+	callClients        map[string]*SyncClient // the clients of Service.Call by the target url and command
+	callMu             sync.Mutex
+	managerClients     map[string]manager_client.Interface // the handler manager clients by the handler id
+	managerClientsMu   sync.Mutex
+	handlerIds         map[string]string                         // the categories of the handlers set by their id, see SetHandlerById
+	internal           map[string]bool                           // the keys of the internal handlers, see Internal
+	portRetries        int                                       // the new ports requested for the handler with the bound port
+	tags               *tag.Registry                             // the tags of the handler categories, see SetTags
+	taps               *tap.Registry                             // the taps attached by the manager, forwarded by the proxy
+	priorities         map[string]int                            // the shutdown priorities, see SetShutdownPriority
+	objectives         *slo.Tracker                              // the objectives of the routes, see SetSlo
+	flags              *featureflag.Registry                     // the feature flags toggled at runtime, see Flag
+	quotas             *quota.Accountant                         // the usage of the source services, see RouteQuota
+	clocks             *timestamp.Estimator                      // the clock offsets of the called services, see ClockOffset
+	callCache          *replycache.Cache[message.ReplyInterface] // the kept replies of Call, see EnableCallCache
+	held               map[string]heldRule                       // the units held by the proxy handler by the rule key, see setAllUnits
+	heldMu             sync.Mutex
+	stateDirs          map[string]string    // the directories of the state in the snapshots, see SetStateDir
+	upgradeFrom        *clientConfig.Client // the manager of the old instance replaced by the upgrade
+	down               map[string]error     // the crashed orchestra components, see watchdog
+	downMu             sync.Mutex
 }
 
 // New service.
-// The url and id could be passed as flag.IdFlag, flag.UrlFlag.
+// The url and id could be passed by WithId, WithUrl or as flag.IdFlag, flag.UrlFlag.
 // Or url and id could be passed as environment variable flag.IdEnv, flag.UrlEnv.
 //
 // It will also create the context internally and start it.
-func New() (*Service, error) {
-	var id, url string
+// The context could be passed by WithContext.
+func New(opts ...Option) (*Service, error) {
+	o := newOptions(opts)
+	id, url := o.id, o.url
 
 	// let's validate the parameters of the service
-	if arg.FlagExist(flag.IdFlag) {
+	if len(id) == 0 && arg.FlagExist(flag.IdFlag) {
 		id = arg.FlagValue(flag.IdFlag)
 	}
-	if arg.FlagExist(flag.UrlFlag) {
+	if len(url) == 0 && arg.FlagExist(flag.UrlFlag) {
 		url = arg.FlagValue(flag.UrlFlag)
 	}
 
 	// Start the context
-	ctx, err := context.New()
+	ctx, err := newContext(o)
 	if err != nil {
-		return nil, fmt.Errorf("context.New: %w", err)
-	}
-	err = ctx.StartConfig()
-	if err != nil {
-		return nil, fmt.Errorf("ctx('%s').StartConfig: %w", ctx.Type(), err)
+		return nil, fmt.Errorf("newContext: %w", err)
 	}
 
 	independent := &Service{
-		ctx:      ctx,
-		Handlers: key_value.New(),
-		url:      url,
-		id:       id,
-		Type:     serviceConfig.IndependentType,
-		blocker:  nil,
-	}
-
-	logger, err := log.New(id, true)
-	if err != nil {
-		err = fmt.Errorf("log.New(%s): %w", id, err)
-
-		if closeErr := ctx.Close(); closeErr != nil {
-			return nil, fmt.Errorf("%v: ctx.Close: %w", err, closeErr)
-		}
-
-		return nil, err
+		ctx:         ctx,
+		Handlers:    key_value.New(),
+		url:         url,
+		id:          id,
+		Type:        serviceConfig.IndependentType,
+		blocker:     nil,
+		timeouts:    DefaultTimeouts(),
+		portRetries: DefaultPortRetries,
+		routes:      deprecation.NewRegistry(),
+		tags:        tag.NewRegistry(),
+		flags:       featureflag.NewRegistry(),
+		quotas:      quota.NewAccountant(),
+		clocks:      timestamp.NewEstimator(timestamp.DefaultSamples),
+		acl:         namespace.NewACL(),
 	}
-	independent.Logger = logger
 
 	if len(id) == 0 {
 		configClient := ctx.Config()
 		id, err = configClient.String(flag.IdEnv)
 		if err != nil {
 			err = fmt.Errorf("configClient.String('%s'): %w", flag.IdEnv, err)
-			if closeErr := ctx.Close(); closeErr != nil {
-				return nil, fmt.Errorf("%v: ctx.Close: %w", err, closeErr)
-			}
-			return nil, err
+			return nil, errs.Join(err, errs.Wrap("ctx.Close", ctx.Close()))
 		}
 	}
 	if len(url) == 0 {
@@ -99,38 +155,217 @@ func New() (*Service, error) {
 		url, err = configClient.String(flag.UrlEnv)
 		if err != nil {
 			err = fmt.Errorf("configClient.String('%s'): %w", flag.UrlEnv, err)
-			if closeErr := ctx.Close(); closeErr != nil {
-				return nil, fmt.Errorf("%v: ctx.Close: %w", err, closeErr)
-			}
-			return nil, err
+			return nil, errs.Join(err, errs.Wrap("ctx.Close", ctx.Close()))
 		}
 	}
 
 	if len(id) == 0 {
 		err = fmt.Errorf("service can not identify itself. Either use %s flag or %s environment variable", flag.IdFlag, flag.IdEnv)
-		if closeErr := ctx.Close(); closeErr != nil {
-			return nil, fmt.Errorf("%v: ctx.Close: %w", err, closeErr)
-		}
-		return nil, err
+		return nil, errs.Join(err, errs.Wrap("ctx.Close", ctx.Close()))
 	}
 	if len(url) == 0 {
 		err = fmt.Errorf("service can not identify it's class. Either use %s flag or %s environment variable", flag.UrlFlag, flag.UrlEnv)
-		if closeErr := ctx.Close(); closeErr != nil {
-			return nil, fmt.Errorf("%v: ctx.Close: %w", err, closeErr)
-		}
-		return nil, err
+		return nil, errs.Join(err, errs.Wrap("ctx.Close", ctx.Close()))
+	}
+
+	if err = independent.setNamespace(id, url); err != nil {
+		err = fmt.Errorf("setNamespace: %w", err)
+		return nil, errs.Join(err, errs.Wrap("ctx.Close", ctx.Close()))
 	}
 
+	// the logger is named by the qualified id, so the logs of the tenants are distinguished
+	logger, err := log.New(independent.id, true)
+	if err != nil {
+		err = fmt.Errorf("log.New(%s): %w", independent.id, err)
+
+		return nil, errs.Join(err, errs.Wrap("ctx.Close", ctx.Close()))
+	}
+	independent.Logger = logger
+	independent.flags.OnChange(independent.broadcastFlag)
+
 	return independent, nil
 }
 
-// SetHandler of category
-//
-// Todo change to keep the handlers by their id.
-func (independent *Service) SetHandler(category string, controller base.Interface) {
+// HandlerOption of the handler set by SetHandler or SetHandlerById
+type HandlerOption int
+
+const (
+	// Public handler is reachable by the other services. It's the default.
+	Public HandlerOption = iota
+	// Internal handler is reachable within this service only.
+	// It has the inproc endpoint, and it's never published as the proxy units or by the manager.
+	Internal
+)
+
+// SetHandler of category.
+// The category is the key of the handler in the Handlers.
+// To set multiple handlers of the same category, use SetHandlerById.
+func (independent *Service) SetHandler(category string, controller base.Interface, options ...HandlerOption) {
+	independent.setHandlerOptions(category, options)
 	independent.Handlers.Set(category, controller)
 }
 
+// setHandlerOptions applies the options of the handler by its key in the Handlers
+func (independent *Service) setHandlerOptions(key string, options []HandlerOption) {
+	if independent.internal == nil {
+		independent.internal = make(map[string]bool, 1)
+	}
+	delete(independent.internal, key)
+	for _, option := range options {
+		independent.internal[key] = option == Internal
+	}
+}
+
+// isInternal returns true if the handler by its key in the Handlers is internal
+func (independent *Service) isInternal(key string) bool {
+	return independent.internal[key]
+}
+
+// internalIds returns the configuration ids of the internal handlers.
+// Call it after the configuration is set.
+func (independent *Service) internalIds() []string {
+	ids := make([]string, 0, len(independent.internal))
+	for key, raw := range independent.Handlers {
+		handler := raw.(base.Interface)
+		if independent.isInternal(key) && handler.Config() != nil {
+			ids = append(ids, handler.Config().Id)
+		}
+	}
+	return ids
+}
+
+// SetHandlerById sets the handler of the category by its id.
+// The id is the key of the handler in the Handlers, and the id of the generated handler configuration.
+//
+// Use it to run multiple handlers of the same category,
+// for example, two "database" handlers pointing to the different shards.
+func (independent *Service) SetHandlerById(id string, category string, controller base.Interface, options ...HandlerOption) {
+	if independent.handlerIds == nil {
+		independent.handlerIds = make(map[string]string, 1)
+	}
+	independent.handlerIds[id] = category
+	independent.setHandlerOptions(id, options)
+	independent.Handlers.Set(id, controller)
+}
+
+// SetTags tags the handlers of the category, for example, tag.Public or tag.Admin.
+// The rules target the tagged handlers by tag.Ref instead of enumerating the categories.
+// The tags are returned by the manager.Describe command.
+func (independent *Service) SetTags(category string, tags ...string) error {
+	if err := independent.tags.Set(category, tags...); err != nil {
+		return fmt.Errorf("tags.Set('%s'): %w", category, err)
+	}
+	return nil
+}
+
+// handlerCategory returns the category of the handler by its key in the Handlers
+func (independent *Service) handlerCategory(key string) string {
+	if category, ok := independent.handlerIds[key]; ok {
+		return category
+	}
+	return key
+}
+
+// HandlersByCategory returns the handlers of the category.
+// The handlers are set by SetHandler or SetHandlerById.
+func (independent *Service) HandlersByCategory(category string) []base.Interface {
+	handlers := make([]base.Interface, 0, 1)
+	for key, raw := range independent.Handlers {
+		if independent.handlerCategory(key) == category {
+			handlers = append(handlers, raw.(base.Interface))
+		}
+	}
+	return handlers
+}
+
+// generateHandlerByKey generates the configuration of the handler by its key in the Handlers.
+// The handlers set by the id keep their id in the configuration.
+func (independent *Service) generateHandlerByKey(key string, handler base.Interface) (*handlerConfig.Handler, error) {
+	generated, err := generateHandler(independent.ctx, handler.Type(), independent.handlerCategory(key), independent.isInternal(key))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := independent.handlerIds[key]; ok {
+		generated.Id = key
+	}
+	return generated, nil
+}
+
+// handlerConfigByKey returns the configuration of the handler by its key in the Handlers from the service configuration.
+// The handlers set by the id are found by their id, others by their category.
+// The handlers set by the id are skipped in the category lookup, even if they share the category.
+func (independent *Service) handlerConfigByKey(serviceConf *serviceConfig.Service, key string) (*handlerConfig.Handler, error) {
+	if _, ok := independent.handlerIds[key]; ok {
+		for _, c := range serviceConf.Handlers {
+			if c.Id == key {
+				return c, nil
+			}
+		}
+		return nil, fmt.Errorf("handler of '%s' id not found", key)
+	}
+
+	for _, c := range serviceConf.Handlers {
+		if _, byId := independent.handlerIds[c.Id]; c.Category == key && !byId {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("handler of '%s' category not found", key)
+}
+
+// SetFeed sets the broadcast feed.
+// The subscribers request the missed broadcasts from the feed through the manager's catch-up command.
+func (independent *Service) SetFeed(feed *broadcast.Feed) {
+	independent.feed = feed
+}
+
+// SetPublisher sets the publisher of the service's broadcasts.
+// The publisher's feed replaces the one set by SetFeed.
+// The service starts the publisher if it's not running, and closes it when the service stops.
+func (independent *Service) SetPublisher(broadcastPublisher *publisher.Publisher) {
+	independent.publisher = broadcastPublisher
+	independent.feed = broadcastPublisher.Feed()
+}
+
+// The broadcast adds the broadcast into the feed.
+// If the publisher is running, it's sent to the subscribers as well.
+// Without the feed, nothing is broadcast.
+func (independent *Service) broadcast(topic string, parameters map[string]interface{}) error {
+	if independent.publisher != nil && independent.publisher.Running() {
+		if _, err := independent.publisher.Publish(topic, parameters); err != nil {
+			return fmt.Errorf("publisher.Publish('%s'): %w", topic, err)
+		}
+		return nil
+	}
+	if independent.feed == nil {
+		return nil
+	}
+	if _, err := independent.feed.Next(topic, parameters); err != nil {
+		return fmt.Errorf("feed.Next('%s'): %w", topic, err)
+	}
+	return nil
+}
+
+// SetMonitor sets the socket monitor.
+// The socket metrics are returned by the manager's Status command.
+// The endpoints of the handlers, the extensions and Service.Call are watched by the probes of the monitor,
+// so the monitor must use the transport of the handlers, for example, transport/zmq.
+// The service starts the monitor if it's not running, and closes it when the service stops.
+func (independent *Service) SetMonitor(socketMonitor *monitor.Monitor) {
+	independent.monitor = socketMonitor
+}
+
+// SetEnforcer sets the resource limits enforcer of the dependencies.
+// The proxies and extensions with the limits declared in the configuration are tracked in the enforcer, see limits.Env.
+// The violations are returned by the manager's Status command.
+// The dependencies killed for the violations are restarted by the service.
+// The service starts the enforcer if it's not running.
+func (independent *Service) SetEnforcer(enforcer *limits.Enforcer) {
+	independent.enforcer = enforcer
+	if enforcer != nil {
+		enforcer.SetRestart(independent.restartDep)
+	}
+}
+
 // Url returns the url of the service source code
 func (independent *Service) Url() string {
 	return independent.url
@@ -239,12 +474,13 @@ func (independent *Service) generateConfig() (*serviceConfig.Service, error) {
 	generatedConfig.Manager.UrlFunc(clientConfig.Url)
 
 	// Get all handlers and add them into the service
-	for category, raw := range independent.Handlers {
+	for key, raw := range independent.Handlers {
 		handler := raw.(base.Interface)
-		generatedHandler, err := configClient.GenerateHandler(handler.Type(), category, false)
+		generatedHandler, err := independent.generateHandlerByKey(key, handler)
 		if err != nil {
-			return nil, fmt.Errorf("configClient.GenerateHandler('%s', '%s', internal: false): %w", handler.Type(), category, err)
+			return nil, fmt.Errorf("generateHandlerByKey('%s'): %w", key, err)
 		}
+		independent.applyOverride(key, generatedHandler)
 
 		handler.SetConfig(generatedHandler)
 
@@ -277,15 +513,16 @@ func (independent *Service) lintConfig() error {
 		independent.Type = returnedService.Type
 	}
 
-	for category, raw := range independent.Handlers {
+	for key, raw := range independent.Handlers {
 		handler := raw.(base.Interface)
 
-		returnedHandler, err := returnedService.HandlerByCategory(category)
+		returnedHandler, err := independent.handlerConfigByKey(returnedService, key)
 		if err != nil {
-			generatedHandler, err := configClient.GenerateHandler(handler.Type(), category, false)
+			generatedHandler, err := independent.generateHandlerByKey(key, handler)
 			if err != nil {
-				return fmt.Errorf("configClient.GenerateHandler('%s', '%s', internal: false): %w", handler.Type(), category, err)
+				return fmt.Errorf("generateHandlerByKey('%s'): %w", key, err)
 			}
+			independent.applyOverride(key, generatedHandler)
 
 			handler.SetConfig(generatedHandler)
 
@@ -294,6 +531,15 @@ func (independent *Service) lintConfig() error {
 				return fmt.Errorf("configClient.SetService('returned'): %w", err)
 			}
 		} else {
+			if independent.isInternal(key) && returnedHandler.Port != 0 {
+				return fmt.Errorf("the internal '%s' handler has the %d port, the internal handlers are inproc only", key, returnedHandler.Port)
+			}
+			if independent.applyOverride(key, returnedHandler) {
+				returnedService.SetHandler(returnedHandler)
+				if err := configClient.SetService(returnedService); err != nil {
+					return fmt.Errorf("configClient.SetService('overridden'): %w", err)
+				}
+			}
 			handler.SetConfig(returnedHandler)
 		}
 	}
@@ -305,8 +551,14 @@ func (independent *Service) lintConfig() error {
 // If the configuration doesn't exist, generates the service and handler.
 // The returned configuration from the context is linted into service and handler.
 //
+// The handler configurations are overwritten by the flags, see flag.HandlerOverrides.
+//
 // Important node. This method doesn't set the proxies or extensions.
 func (independent *Service) setConfig() error {
+	if err := independent.setOverrides(); err != nil {
+		return fmt.Errorf("setOverrides: %w", err)
+	}
+
 	configClient := independent.ctx.Config()
 
 	// prepare the configuration
@@ -331,33 +583,45 @@ func (independent *Service) setConfig() error {
 	return nil
 }
 
+// setProxyUnitsBy publishes the units matching the rule.
+// Only the units of the serving handlers are published, see refreshServing.
 func (independent *Service) setProxyUnitsBy(dest *serviceConfig.Rule) error {
-	proxyClient := independent.ctx.ProxyClient()
+	if !publishable(dest) {
+		return nil
+	}
 
-	if dest.IsRoute() {
-		units := independent.unitsByRouteRule(dest)
-		if err := proxyClient.SetUnits(dest, units); err != nil {
-			return fmt.Errorf("proxyClient.SetUnits: %w", err)
-		}
-	} else if dest.IsHandler() {
-		units := independent.unitsByHandlerRule(dest)
-		if err := proxyClient.SetUnits(dest, units); err != nil {
-			return fmt.Errorf("proxyClient.SetUnits: %w", err)
-		}
-	} else if dest.IsService() {
-		units := independent.unitsByServiceRule(dest)
-		if err := proxyClient.SetUnits(dest, units); err != nil {
-			return fmt.Errorf("proxyClient.SetUnits: %w", err)
-		}
+	rules := []*serviceConfig.Rule{dest}
+	if err := independent.setAllUnits(rules, independent.resolveAllUnits(rules)); err != nil {
+		return fmt.Errorf("setAllUnits: %w", err)
 	}
 
 	return nil
 }
 
+// UnitsFor returns the units this service publishes for the rule.
+// Returns an error if the rule matches no units, instead of publishing the empty list silently.
+// Use it to check the rules built by the rule package.
+func (independent *Service) UnitsFor(dest *serviceConfig.Rule) ([]*serviceConfig.Unit, error) {
+	var units []*serviceConfig.Unit
+	if publishable(dest) {
+		units = independent.unitsByRule(dest)
+	}
+
+	if len(units) == 0 {
+		return nil, fmt.Errorf("no units matched the rule (categories=%v, commands=%v, excluded=%v)",
+			dest.Categories, dest.Commands, dest.ExcludedCommands)
+	}
+	return units, nil
+}
+
 // The setProxyUnits gets the list of proxy chains for this service.
-// Then, it creates a proxy units.
+// Then, it creates a proxy units, and sends the changed units of all chains, see setAllUnits.
 // Todo if the extension is sending a ready command, then update the command list.
 func (independent *Service) setProxyUnits() error {
+	if err := independent.proxyUpdatesPaused(); err != nil {
+		return err
+	}
+
 	proxyClient := independent.ctx.ProxyClient()
 	proxyChains, err := proxyClient.ProxyChains()
 	if err != nil {
@@ -365,112 +629,20 @@ func (independent *Service) setProxyUnits() error {
 	}
 
 	// set the proxy destination units for each rule
+	rules := make([]*serviceConfig.Rule, 0, len(proxyChains))
 	for _, proxyChain := range proxyChains {
-		dest := proxyChain.Destination
-		if err := independent.setProxyUnitsBy(dest); err != nil {
-			return fmt.Errorf("independent.setProxyUnitsBy(rule='%v'): %w", dest, err)
-		}
-	}
-
-	return nil
-}
-
-// unitsByRouteRule returns the list of units for the route rule
-func (independent *Service) unitsByRouteRule(rule *serviceConfig.Rule) []*serviceConfig.Unit {
-	units := make([]*serviceConfig.Unit, 0, len(rule.Commands)*len(rule.Categories))
-
-	if len(independent.Handlers) == 0 {
-		return units
-	}
-
-	for _, raw := range independent.Handlers {
-		handlerInterface := raw.(base.Interface)
-		hConfig := handlerInterface.Config()
-
-		if !slices.Contains(rule.Categories, hConfig.Category) {
-			continue
-		}
-
-		for _, command := range rule.Commands {
-			if slices.Contains(rule.ExcludedCommands, command) {
-				continue
-			}
-
-			if !handlerInterface.IsRouteExist(command) {
-				continue
-			}
-
-			unit := &serviceConfig.Unit{
-				ServiceId: independent.id,
-				HandlerId: hConfig.Id,
-				Command:   command,
-			}
-
-			units = append(units, unit)
+		if publishable(proxyChain.Destination) {
+			rules = append(rules, proxyChain.Destination)
 		}
 	}
-
-	return units
-}
-
-// unitsByHandlerRule returns the list of units for the handler rule
-func (independent *Service) unitsByHandlerRule(rule *serviceConfig.Rule) []*serviceConfig.Unit {
-	units := make([]*serviceConfig.Unit, 0, len(rule.Categories))
-
-	for _, raw := range independent.Handlers {
-		handlerInterface := raw.(base.Interface)
-		hConfig := handlerInterface.Config()
-
-		if !slices.Contains(rule.Categories, hConfig.Category) {
-			continue
-		}
-
-		commands := handlerInterface.RouteCommands()
-
-		for _, command := range commands {
-			if slices.Contains(rule.ExcludedCommands, command) {
-				continue
-			}
-
-			unit := &serviceConfig.Unit{
-				ServiceId: independent.id,
-				HandlerId: hConfig.Id,
-				Command:   command,
-			}
-
-			units = append(units, unit)
-		}
+	if err := independent.withdrawStaleUnits(rules); err != nil {
+		return fmt.Errorf("independent.withdrawStaleUnits: %w", err)
 	}
-
-	return units
-}
-
-// unitsByServiceRule returns the list of units for the service rule
-func (independent *Service) unitsByServiceRule(rule *serviceConfig.Rule) []*serviceConfig.Unit {
-	units := make([]*serviceConfig.Unit, 0, len(rule.Categories))
-
-	for _, raw := range independent.Handlers {
-		handlerInterface := raw.(base.Interface)
-		hConfig := handlerInterface.Config()
-
-		commands := handlerInterface.RouteCommands()
-
-		for _, command := range commands {
-			if slices.Contains(rule.ExcludedCommands, command) {
-				continue
-			}
-
-			unit := &serviceConfig.Unit{
-				ServiceId: independent.id,
-				HandlerId: hConfig.Id,
-				Command:   command,
-			}
-
-			units = append(units, unit)
-		}
+	if err := independent.setAllUnits(rules, independent.resolveAllUnits(rules)); err != nil {
+		return fmt.Errorf("independent.setAllUnits: %w", err)
 	}
 
-	return units
+	return nil
 }
 
 // newManager creates a manager.Manager and assigns it to manager, otherwise manager is nil.
@@ -494,11 +666,12 @@ func (independent *Service) newManager() error {
 	return nil
 }
 
-// setHandlerClient creates a handler manager clients and sets them into the service manager.
+// setHandlerClient sets the cached handler manager client into the service manager.
+// The manager closes the handler during the teardown.
 func (independent *Service) setHandlerClient(c base.Interface) error {
-	handlerClient, err := manager_client.New(c.Config())
+	handlerClient, err := independent.handlerClient(c.Config())
 	if err != nil {
-		return fmt.Errorf("manager_client.New('%s'): %w", c.Config().Category, err)
+		return fmt.Errorf("handlerClient('%s'): %w", c.Config().Category, err)
 	}
 	independent.manager.SetHandlerManagers([]manager_client.Interface{handlerClient})
 
@@ -519,60 +692,90 @@ func (independent *Service) startHandler(handler base.Interface) error {
 	return nil
 }
 
+// startHandlers starts the handlers in parallel, each within the Timeouts.Handler.
+// If any handler fails, then the started handlers are closed.
+// The handlers timed out are closed once their abandoned start finishes, see closeLateHandler.
 func (independent *Service) startHandlers() error {
-	var err error
-	startedAmount := 0
-
+	handlers := make([]base.Interface, 0, len(independent.Handlers))
 	for category, raw := range independent.Handlers {
 		handler := raw.(base.Interface)
 		if handler.Config() == nil {
 			return fmt.Errorf("handler of %s category not set, please call SetConfig of handler", category)
 		}
-		if err = independent.setHandlerClient(handler); err != nil {
-			err = fmt.Errorf("setHandlerClient('%s'): %w", category, err)
-			goto exitStartHandler
+		if independent.skipHandler(handler) {
+			continue
 		}
+		handlers = append(handlers, handler)
+	}
 
-		if err = independent.startHandler(handler); err != nil {
-			err = fmt.Errorf("startHandler: %w", err)
-			goto exitStartHandler
-		}
-		startedAmount++
+	startErrs := make([]error, len(handlers))
+	var wg sync.WaitGroup
+	for i := range handlers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			handler := handlers[i]
+			category := handler.Config().Category
+			startErrs[i] = withTimeoutThen("handler "+category, independent.timeouts.Handler, func() error {
+				if err := independent.setHandlerClient(handler); err != nil {
+					return fmt.Errorf("setHandlerClient('%s'): %w", category, err)
+				}
+				if err := independent.startHandler(handler); err != nil {
+					return fmt.Errorf("startHandler: %w", err)
+				}
+				return nil
+			}, func(err error) {
+				independent.closeLateHandler(handler, err)
+			})
+		}(i)
 	}
+	wg.Wait()
 
-exitStartHandler:
+	started := make([]base.Interface, 0, len(handlers))
+	for i := range handlers {
+		if startErrs[i] == nil {
+			started = append(started, handlers[i])
+		}
+	}
+	err := errs.Join(startErrs...)
 	if err == nil {
 		return nil
 	}
 
-	if startedAmount == 0 {
-		return err
-	}
-	return independent.closeHandlers(startedAmount)
+	return errs.Join(err, errs.Wrap("closeHandlers", independent.closeHandlers(started)))
 }
 
-func (independent *Service) closeHandlers(startedAmount int) error {
-	var err error
-
-	if startedAmount == 0 {
-		return err
+// closeLateHandler closes the handler that started after its timeout.
+// The start was abandoned, so the handler is not served, but it keeps its port and sockets until closed.
+func (independent *Service) closeLateHandler(handler base.Interface, startErr error) {
+	category := handler.Config().Category
+	if startErr != nil {
+		independent.Logger.Warn("the timed out handler failed to start", "category", category, "error", startErr)
+		return
 	}
+	if err := independent.closeHandlers([]base.Interface{handler}); err != nil {
+		independent.Logger.Error("closeHandlers(timed out)", "category", category, "error", err)
+		return
+	}
+	independent.Logger.Warn("the timed out handler started late, closed", "category", category)
+}
 
-	for category, raw := range independent.Handlers {
-		handler := raw.(base.Interface)
-		handlerClient, newErr := manager_client.New(handler.Config())
-
-		if newErr != nil {
-			return fmt.Errorf("%v: manager_client.New('%s'): %w", err, category, newErr)
-		} else {
-			if closeErr := handlerClient.Close(); closeErr != nil {
-				return fmt.Errorf("%v: handlerClient('%s').Close: %w", err, category, closeErr)
-			}
+// closeHandlers closes the given handlers by their manager clients.
+// The closed handlers are removed from the service manager, so the teardown doesn't close them again.
+func (independent *Service) closeHandlers(handlers []base.Interface) error {
+	for _, handler := range handlers {
+		category := handler.Config().Category
+		handlerClient, err := independent.handlerClient(handler.Config())
+		if err != nil {
+			return fmt.Errorf("handlerClient('%s'): %w", category, err)
 		}
-
-		startedAmount--
-		if startedAmount == 0 {
-			break
+		if err := handlerClient.Close(); err != nil {
+			return fmt.Errorf("handlerClient('%s').Close: %w", category, err)
+		}
+		independent.evictHandlerClient(handler.Config().Id)
+		if independent.manager != nil {
+			independent.manager.RemoveHandlerManager(handler.Config().Id)
 		}
 	}
 
@@ -581,92 +784,192 @@ func (independent *Service) closeHandlers(startedAmount int) error {
 
 // Start the service.
 //
-// Requires at least one handler.
+// Requires at least one handler, set in the code or declared in the configuration, see RegisterRoute.
+// If any phase fails, then the started phases are closed in the reverse order.
 func (independent *Service) Start() (*sync.WaitGroup, error) {
-	var err error
+	stack := &teardown{}
+	stack.push("ctx.Close", independent.ctx.Close)
 
+	if err := independent.start(stack); err != nil {
+		return independent.blocker, errs.Join(err, errs.Wrap("teardown", stack.run()))
+	}
+
+	independent.blocker = &sync.WaitGroup{}
+	independent.blocker.Add(1)
+
+	if independent.elector != nil {
+		go independent.keepCampaign()
+	}
+	go independent.watchServing()
+	go independent.watchConfig()
+	go independent.retryDegraded()
+	go independent.watchdog()
+	go independent.watchSlo()
+
+	return independent.blocker, nil
+}
+
+// The start runs the phases of the Start.
+// The cleanup of each started phase is pushed into the stack.
+func (independent *Service) start(stack *teardown) error {
+	if err := independent.setDeclaredHandlers(); err != nil {
+		return fmt.Errorf("setDeclaredHandlers: %w", err)
+	}
 	if len(independent.Handlers) == 0 {
-		err = fmt.Errorf("no Handlers. call service.SetHandler")
-		goto errOccurred
+		return fmt.Errorf("no Handlers. call service.SetHandler or declare them in the configuration")
 	}
 
-	if err = independent.setConfig(); err != nil {
-		err = fmt.Errorf("setConfig: %w", err)
-		goto errOccurred
+	if err := independent.checkDuplicate(); err != nil {
+		return fmt.Errorf("checkDuplicate: %w", err)
 	}
 
-	independent.ctx.SetService(independent.id, independent.url)
-	if !independent.ctx.IsDepManagerRunning() {
-		if err = independent.ctx.StartDepManager(); err != nil {
-			err = fmt.Errorf("ctx.StartDepManager: %w", err)
-			goto errOccurred
-		}
+	if err := independent.setConfig(); err != nil {
+		return fmt.Errorf("setConfig: %w", err)
 	}
-	if !independent.ctx.IsProxyHandlerRunning() {
-		if err = independent.ctx.StartProxyHandler(); err != nil {
-			err = fmt.Errorf("ctx.StartProxyHandler: %w", err)
-			goto errOccurred
+
+	if err := independent.startContainer(stack); err != nil {
+		return fmt.Errorf("startContainer: %w", err)
+	}
+	stack.push("closeHealth", func() error {
+		return independent.closeHealth(GracePeriod)
+	})
+
+	if err := independent.resolvePorts(); err != nil {
+		return fmt.Errorf("resolvePorts: %w", err)
+	}
+	if independent.upgradeFrom != nil {
+		if err := independent.resolveManagerPort(); err != nil {
+			return fmt.Errorf("resolveManagerPort: %w", err)
 		}
 	}
 
-	if err = independent.newManager(); err != nil {
-		err = fmt.Errorf("newManager: %w", err)
-		goto errOccurred
+	if err := independent.runHooks(BeforeStart); err != nil {
+		return fmt.Errorf("runHooks: %w", err)
+	}
+
+	independent.ctx.SetService(independent.id, independent.url)
+	if err := independent.startOrchestra(); err != nil {
+		return fmt.Errorf("startOrchestra: %w", err)
+	}
+
+	if err := independent.newManager(); err != nil {
+		return fmt.Errorf("newManager: %w", err)
 	}
 
 	// get the proxies from the proxy chain for this service.
 	// must be called before starting handlers, as routing of the handlers maybe set by proxy units.
-	if err = independent.setProxyUnits(); err != nil {
-		err = fmt.Errorf("independent.setProxyUnits: %w", err)
-		goto errOccurred
+	if err := independent.setProxyUnits(); err != nil {
+		return fmt.Errorf("independent.setProxyUnits: %w", err)
+	}
+
+	if err := independent.campaign(); err != nil {
+		return fmt.Errorf("independent.campaign: %w", err)
+	}
+	independent.manager.SetElector(independent.elector)
+	independent.manager.SetFeed(independent.feed)
+	independent.manager.SetMonitor(independent.monitor)
+	if independent.monitor != nil && !independent.monitor.Running() {
+		if err := independent.monitor.Start(); err != nil {
+			return fmt.Errorf("monitor.Start: %w", err)
+		}
+		stack.push("closeMonitor", independent.closeMonitor)
+	}
+	independent.manager.SetEnforcer(independent.enforcer)
+	independent.manager.SetHandlerStarter(independent.startLazyHandler)
+	independent.manager.SetRoutes(independent.routes)
+	independent.manager.SetACL(independent.acl)
+	independent.manager.SetTags(independent.tags)
+	independent.manager.SetTaps(independent.taps)
+	independent.manager.SetSlo(independent.objectives)
+	independent.manager.SetFlags(independent.flags)
+	independent.manager.SetQuotas(independent.quotas)
+	independent.manager.SetSnapshots(independent.takeSnapshot, independent.restoreSnapshot)
+	independent.manager.SetShutdownPriorities(independent.shutdownPriorities())
+	independent.manager.SetInternalHandlers(independent.internalIds())
+	independent.manager.SetStopHooks(independent.stopHooks())
+	independent.manager.SetRouteCommands(independent.routeCommands)
+	independent.manager.SetDegraded(independent.Degraded)
+	if independent.enforcer != nil && !independent.enforcer.Running() {
+		if err := independent.enforcer.Start(limits.Interval); err != nil {
+			return fmt.Errorf("enforcer.Start: %w", err)
+		}
+		stack.push("enforcer.Close", independent.enforcer.Close)
+	}
+	if independent.publisher != nil && !independent.publisher.Running() {
+		if err := independent.publisher.Start(); err != nil {
+			return fmt.Errorf("publisher.Start: %w", err)
+		}
+		stack.push("publisher.Close", independent.publisher.Close)
+	}
+	if err := independent.startLanes(); err != nil {
+		return fmt.Errorf("startLanes: %w", err)
+	}
+	stack.push("closeLanes", independent.closeLanes)
+
+	// the failed handlers are closed by the startHandlers itself
+	if err := independent.startHandlers(); err != nil {
+		return err
 	}
+	stack.push("closeHandlers", func() error {
+		return independent.closeHandlers(independent.startedHandlers())
+	})
+	independent.watchHandlers()
 
-	err = independent.startHandlers()
-	if err != nil {
-		goto errOccurred
+	// the units were withdrawn until the handlers are serving.
+	independent.refreshServing()
+	if independent.upgradeFrom != nil {
+		if err := independent.requestHandoff(); err != nil {
+			return fmt.Errorf("requestHandoff: %w", err)
+		}
+	}
+	if err := independent.setProxyUnits(); err != nil {
+		return fmt.Errorf("independent.setProxyUnits(serving): %w", err)
 	}
 
 	// todo prepare the extensions by calling them in the context.
 	// todo prepare the extensions by setting them into the independent.manager.
 
-	if err = independent.manager.Start(); err != nil {
-		err = fmt.Errorf("service.manager.Start: %w", err)
-		goto errOccurred
+	if err := independent.manager.Start(); err != nil {
+		return fmt.Errorf("service.manager.Start: %w", err)
 	}
+	// the manager closes the proxies, the handlers and the context without calling the stop hooks
+	stack.push("manager.Rollback", func() error {
+		return errs.Join(independent.manager.Rollback(), independent.closeResources())
+	}, "ctx.Close", "closeHandlers", "enforcer.Close", "publisher.Close", "closeLanes", "closeMonitor")
 
 	// todo add a manager command that reads the client configuration status GENERATED
 	// todo upon reading it sets it into the independent.Config.Sources
-	if err = independent.ctx.ProxyClient().StartLastProxies(); err != nil {
-		err = fmt.Errorf("ctx.ProxyClient.StartLastProxies: %w", err)
-		goto errOccurred
+	if err := independent.ctx.ProxyClient().StartLastProxies(); err != nil {
+		return fmt.Errorf("ctx.ProxyClient.StartLastProxies: %w", err)
+	}
+	if err := independent.limitProxies(); err != nil {
+		return fmt.Errorf("limitProxies: %w", err)
 	}
 
-	//err = independent.Context.ServiceReady(independent.Logger)
-	//if err != nil {
-	//	goto errOccurred
-	//}
+	if err := independent.handshake(); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
 
-errOccurred:
-	if err != nil {
-		closeErr := independent.ctx.Close()
-		if closeErr != nil {
-			err = fmt.Errorf("%v: ctx.Close: %w", err, closeErr)
+	if independent.upgradeFrom != nil {
+		if err := independent.waitHandoff(); err != nil {
+			return fmt.Errorf("waitHandoff: %w", err)
 		}
+	}
 
-		if independent.manager != nil && independent.manager.Running() {
-			closeErr := independent.manager.Close()
-			if closeErr != nil {
-				err = fmt.Errorf("%v: manager.Close: %w", err, closeErr)
-			}
-		}
+	if err := independent.runHooks(AfterStart); err != nil {
+		return fmt.Errorf("runHooks: %w", err)
 	}
 
-	if err == nil {
-		independent.blocker = &sync.WaitGroup{}
-		independent.blocker.Add(1)
+	if err := independent.signalReady(); err != nil {
+		return fmt.Errorf("signalReady: %w", err)
 	}
 
-	return independent.blocker, err
+	//err = independent.Context.ServiceReady(independent.Logger)
+	//if err != nil {
+	//	return err
+	//}
+
+	return nil
 }
 
 //func (independent *Service) prepareExtensionConfiguration(dep *dev.Dep) error {
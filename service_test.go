@@ -7,19 +7,34 @@ import (
 	serviceConfig "github.com/ahmetson/config-lib/service"
 	"github.com/ahmetson/datatype-lib/data_type/key_value"
 	"github.com/ahmetson/datatype-lib/message"
+	context "github.com/ahmetson/dev-lib"
 	"github.com/ahmetson/handler-lib/base"
 	handlerConfig "github.com/ahmetson/handler-lib/config"
 	"github.com/ahmetson/handler-lib/manager_client"
+	"github.com/ahmetson/handler-lib/replier"
 	"github.com/ahmetson/handler-lib/route"
 	"github.com/ahmetson/handler-lib/sync_replier"
 	"github.com/ahmetson/log-lib"
 	"github.com/ahmetson/os-lib/arg"
 	"github.com/ahmetson/os-lib/path"
 	"github.com/ahmetson/service-lib/flag"
+	"github.com/ahmetson/service-lib/manager"
+	"github.com/ahmetson/service-lib/monitor"
+	"github.com/ahmetson/service-lib/namespace"
+	"github.com/ahmetson/service-lib/orchestra"
+	"github.com/ahmetson/service-lib/priority"
+	"github.com/ahmetson/service-lib/tag"
+	"github.com/ahmetson/service-lib/transport"
 	"github.com/stretchr/testify/suite"
 	"gopkg.in/yaml.v3"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	win "os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -423,12 +438,12 @@ func (test *TestServiceSuite) Test_18_Service_unitsByRouteRule() {
 	// the SetupTest adds "main" category handler with "hello" command
 	test.newService()
 	rule := serviceConfig.NewDestination(test.service.url, test.handlerCategory, test.cmd1)
-	units := test.service.unitsByRouteRule(rule)
+	units := test.service.unitsByRule(rule)
 	s().Len(units, 1)
 
 	// if the rule has a command that doesn't exist in the service, it's skipped
 	rule.Commands = []string{test.cmd1, cmd2}
-	units = test.service.unitsByRouteRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 1)
 
 	// suppose the handler has both commands; then units must return both
@@ -436,7 +451,7 @@ func (test *TestServiceSuite) Test_18_Service_unitsByRouteRule() {
 	s().NoError(err)
 	test.service.SetHandler(test.handlerCategory, test.handler)
 
-	units = test.service.unitsByRouteRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 2)
 
 	// let's say; we have two handlers, in this case search for commands in all categories
@@ -448,7 +463,7 @@ func (test *TestServiceSuite) Test_18_Service_unitsByRouteRule() {
 	test.service.SetHandler(category2, syncReplier)
 	rule.Categories = []string{test.handlerCategory, category2}
 
-	units = test.service.unitsByRouteRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 3)
 
 	// clean out
@@ -465,17 +480,17 @@ func (test *TestServiceSuite) Test_19_Service_unitsByHandlerRule() {
 	// the SetupTest adds "main" category handler with "hello" command
 	test.newService()
 	rule := serviceConfig.NewDestination(test.service.url, test.handlerCategory, test.cmd1)
-	units := test.service.unitsByHandlerRule(rule)
+	units := test.service.unitsByRule(rule)
 	s().Len(units, 1)
 
 	// if the rule has a command that doesn't exist in the service, it's skipped
 	rule.Commands = []string{test.cmd1, cmd2}
-	units = test.service.unitsByHandlerRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 1)
 
 	// The above code is identical too Handler Rule
 	rule = serviceConfig.NewHandlerDestination(test.service.url, test.handlerCategory)
-	units = test.service.unitsByHandlerRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 1)
 
 	// suppose the handler has both commands; then units must return both
@@ -483,7 +498,7 @@ func (test *TestServiceSuite) Test_19_Service_unitsByHandlerRule() {
 	s().NoError(err)
 	test.service.SetHandler(test.handlerCategory, test.handler)
 
-	units = test.service.unitsByHandlerRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 2)
 
 	// let's say; we have two handlers, in this case search for commands in all categories
@@ -496,16 +511,16 @@ func (test *TestServiceSuite) Test_19_Service_unitsByHandlerRule() {
 
 	rule = serviceConfig.NewHandlerDestination(test.service.url, []string{test.handlerCategory, category2})
 
-	units = test.service.unitsByHandlerRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 3)
 
 	// Excluding the command must not return them as a unit
 	rule.ExcludeCommands(test.cmd1)
-	units = test.service.unitsByHandlerRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 1) // the test.cmd1 exists in two handlers, cmd2 from the first handler must be returned
 
 	rule.ExcludeCommands(cmd2)
-	units = test.service.unitsByHandlerRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 0) // all commands are excluded.
 
 	// clean out
@@ -522,7 +537,7 @@ func (test *TestServiceSuite) Test_20_Service_unitsByServiceRule() {
 	// the SetupTest adds "main" category handler with "hello" command
 	test.newService()
 	rule := serviceConfig.NewServiceDestination(test.service.url)
-	units := test.service.unitsByServiceRule(rule)
+	units := test.service.unitsByRule(rule)
 	s().Len(units, 1)
 
 	// suppose the handler has both commands; then units must return both
@@ -530,7 +545,7 @@ func (test *TestServiceSuite) Test_20_Service_unitsByServiceRule() {
 	s().NoError(err)
 	test.service.SetHandler(test.handlerCategory, test.handler)
 
-	units = test.service.unitsByServiceRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 2)
 
 	// let's say; we have two handlers, in this case search for commands in all categories
@@ -541,9 +556,20 @@ func (test *TestServiceSuite) Test_20_Service_unitsByServiceRule() {
 	s().NoError(syncReplier.SetLogger(test.logger))
 	test.service.SetHandler(category2, syncReplier)
 
-	units = test.service.unitsByServiceRule(rule)
+	units = test.service.unitsByRule(rule)
 	s().Len(units, 3)
 
+	// the service rule with the categories must match the handlers of those categories only
+	rule.Categories = []string{category2}
+	units = test.service.unitsByRule(rule)
+	s().Len(units, 1)
+
+	// the excluded commands are not returned by the service rule
+	rule.Categories = nil
+	rule.ExcludeCommands(test.cmd1)
+	units = test.service.unitsByRule(rule)
+	s().Len(units, 1)
+
 	// clean out
 	test.closeService()
 }
@@ -661,6 +687,1091 @@ func (test *TestServiceSuite) Test_22_Start_Close() {
 	time.Sleep(time.Millisecond * 100)
 }
 
+// Test_23_clientPool tests that the handler manager clients are reused by the destination
+func (test *TestServiceSuite) Test_23_clientPool() {
+	s := test.Require
+
+	pool := newClientPool()
+	hConfig := &handlerConfig.Handler{
+		Type:           handlerConfig.SyncReplierType,
+		Category:       "pool",
+		Id:             "pool-1",
+		InstanceAmount: 1,
+	}
+
+	first, err := pool.get(hConfig)
+	s().NoError(err)
+	second, err := pool.get(hConfig)
+	s().NoError(err)
+	s().Equal(first, second)
+	s().Equal(1, pool.len())
+
+	// another destination
+	other := *hConfig
+	other.Id = "pool-2"
+	third, err := pool.get(&other)
+	s().NoError(err)
+	s().NotEqual(first, third)
+	s().Equal(2, pool.len())
+
+	_, err = pool.get(nil)
+	s().Error(err)
+}
+
+// Test_24_unitMatcher tests the matching semantics shared by the route, handler and service rules
+func (test *TestServiceSuite) Test_24_unitMatcher() {
+	s := test.Require
+
+	routed := []string{"cmd_1", "cmd_2", "cmd_3"}
+	tags := tag.NewRegistry()
+	s().NoError(tags.Set("main", tag.Public))
+
+	cases := []struct {
+		name     string
+		rule     *serviceConfig.Rule
+		category string
+		expected []string
+	}{
+		{
+			name:     "service rule matches all commands",
+			rule:     &serviceConfig.Rule{},
+			category: "main",
+			expected: routed,
+		},
+		{
+			name:     "service rule with categories skips the other categories",
+			rule:     &serviceConfig.Rule{Categories: []string{"other"}},
+			category: "main",
+			expected: nil,
+		},
+		{
+			name:     "handler rule matches all commands of the category",
+			rule:     &serviceConfig.Rule{Categories: []string{"main"}},
+			category: "main",
+			expected: routed,
+		},
+		{
+			name:     "route rule matches the routed commands only",
+			rule:     &serviceConfig.Rule{Categories: []string{"main"}, Commands: []string{"cmd_1", "cmd_4"}},
+			category: "main",
+			expected: []string{"cmd_1"},
+		},
+		{
+			name:     "exclusion takes precedence over the listed command",
+			rule:     &serviceConfig.Rule{Categories: []string{"main"}, Commands: []string{"cmd_1", "cmd_2"}, ExcludedCommands: []string{"cmd_1"}},
+			category: "main",
+			expected: []string{"cmd_2"},
+		},
+		{
+			name:     "exclusion applies to the handler rule",
+			rule:     &serviceConfig.Rule{Categories: []string{"main"}, ExcludedCommands: []string{"cmd_2"}},
+			category: "main",
+			expected: []string{"cmd_1", "cmd_3"},
+		},
+		{
+			name:     "handler rule matches the category by its tag",
+			rule:     &serviceConfig.Rule{Categories: []string{tag.Ref(tag.Public)}, ExcludedCommands: []string{"cmd_3"}},
+			category: "main",
+			expected: []string{"cmd_1", "cmd_2"},
+		},
+		{
+			name:     "handler rule skips the category without the tag",
+			rule:     &serviceConfig.Rule{Categories: []string{tag.Ref(tag.Admin)}},
+			category: "main",
+			expected: nil,
+		},
+		{
+			name:     "exclusion applies to the service rule",
+			rule:     &serviceConfig.Rule{ExcludedCommands: []string{"cmd_1", "cmd_2", "cmd_3"}},
+			category: "main",
+			expected: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		matched := newUnitMatcher(c.rule, tags).match(c.category, routed)
+		if len(c.expected) == 0 {
+			s().Empty(matched, c.name)
+			continue
+		}
+		s().Equal(c.expected, matched, c.name)
+	}
+}
+
+// Test_25_portFree tests detecting the bound ports
+func (test *TestServiceSuite) Test_25_portFree() {
+	s := test.Require
+
+	listener, err := net.Listen("tcp", ":0")
+	s().NoError(err)
+	port := uint64(listener.Addr().(*net.TCPAddr).Port)
+
+	s().False(portFree(port))
+	s().NoError(listener.Close())
+	s().True(portFree(port))
+}
+
+// Test_26_teardown tests closing the started phases in the reverse order
+func (test *TestServiceSuite) Test_26_teardown() {
+	s := test.Require
+
+	closed := make([]string, 0)
+	cleanup := func(name string, err error) func() error {
+		return func() error {
+			closed = append(closed, name)
+			return err
+		}
+	}
+
+	stack := &teardown{}
+	stack.push("ctx", cleanup("ctx", nil))
+	stack.push("health", cleanup("health", fmt.Errorf("health failed")))
+	stack.push("handlers", cleanup("handlers", nil))
+	// the manager closes the context and the handlers
+	stack.push("manager", cleanup("manager", fmt.Errorf("manager failed")), "ctx", "handlers")
+
+	err := stack.run()
+	s().Error(err)
+	s().Contains(err.Error(), "health failed")
+	s().Contains(err.Error(), "manager failed")
+	s().Equal([]string{"manager", "health"}, closed)
+
+	// each cleanup runs once
+	s().NoError(stack.run())
+	s().Len(closed, 2)
+}
+
+// Test_27_handlerChanged tests detecting the handler configuration changed in the restarted config engine
+func (test *TestServiceSuite) Test_27_handlerChanged() {
+	s := test.Require
+
+	running := &handlerConfig.Handler{Type: handlerConfig.SyncReplierType, Category: "main", Id: "main_1", Port: 6000, InstanceAmount: 1}
+	synced := *running
+	s().False(handlerChanged(running, &synced))
+
+	synced.Port = 6001
+	s().True(handlerChanged(running, &synced))
+
+	synced = *running
+	synced.InstanceAmount = 2
+	s().True(handlerChanged(running, &synced))
+}
+
+// Test_28_proxyUpdatesPaused tests pausing the proxy updates while the orchestra component is down
+func (test *TestServiceSuite) Test_28_proxyUpdatesPaused() {
+	s := test.Require
+
+	independent := &Service{}
+	s().NoError(independent.proxyUpdatesPaused())
+
+	// the handlers don't depend on the dep manager
+	independent.setDown(DepManagerComponent, fmt.Errorf("not running"))
+	s().NoError(independent.proxyUpdatesPaused())
+	s().Contains(independent.Down(), DepManagerComponent)
+
+	independent.setDown(ProxyHandlerComponent, fmt.Errorf("not running"))
+	s().Error(independent.proxyUpdatesPaused())
+
+	independent.setDown(ProxyHandlerComponent, nil)
+	s().NoError(independent.proxyUpdatesPaused())
+	s().NotContains(independent.Down(), ProxyHandlerComponent)
+}
+
+// Test_29_shutdownOrder tests ordering the extensions by their shutdown priority
+func (test *TestServiceSuite) Test_29_shutdownOrder() {
+	s := test.Require
+
+	independent := &Service{}
+	s().Equal([]string{"a", "b", "c"}, independent.shutdownOrder([]string{"c", "a", "b"}))
+
+	independent.SetShutdownPriority("ingestion", -1)
+	independent.SetShutdownPriority("flush", 10)
+	s().Equal([]string{"ingestion", "cache", "db", "flush"},
+		independent.shutdownOrder([]string{"flush", "db", "ingestion", "cache"}))
+	s().Equal(map[string]int{"ingestion": -1, "flush": 10}, independent.shutdownPriorities())
+}
+
+// Test_30_diffUnits tests the units added to and removed from the units held by the proxy handler
+func (test *TestServiceSuite) Test_30_diffUnits() {
+	s := test.Require
+
+	get := &serviceConfig.Unit{ServiceId: "main", HandlerId: "main_1", Command: "get"}
+	set := &serviceConfig.Unit{ServiceId: "main", HandlerId: "main_1", Command: "set"}
+	scaled := &serviceConfig.Unit{ServiceId: "main", HandlerId: "main_2", Command: "get"}
+
+	added, removed := diffUnits([]*serviceConfig.Unit{get, set}, []*serviceConfig.Unit{get, set})
+	s().Empty(added)
+	s().Empty(removed)
+
+	added, removed = diffUnits([]*serviceConfig.Unit{get, set}, []*serviceConfig.Unit{get, scaled})
+	s().Equal([]*serviceConfig.Unit{scaled}, added)
+	s().Equal([]*serviceConfig.Unit{set}, removed)
+
+	independent := &Service{}
+	rule := serviceConfig.NewServiceDestination("main")
+	_, ok := independent.heldUnits(rule)
+	s().False(ok)
+	independent.holdUnits(rule, []*serviceConfig.Unit{get})
+	held, ok := independent.heldUnits(rule)
+	s().True(ok)
+	s().Len(held, 1)
+	independent.forgetUnits()
+	_, ok = independent.heldUnits(rule)
+	s().False(ok)
+}
+
+// fakeUnitsSetter records the units set per rule, and fails on the rule of the failOn service
+type fakeUnitsSetter struct {
+	set    map[string][]*serviceConfig.Unit
+	failOn string
+}
+
+func (setter *fakeUnitsSetter) SetUnits(rule *serviceConfig.Rule, units []*serviceConfig.Unit) error {
+	if len(setter.failOn) > 0 && ruleKey(rule) == ruleKey(serviceConfig.NewServiceDestination(setter.failOn)) {
+		return fmt.Errorf("proxy handler failed")
+	}
+	setter.set[ruleKey(rule)] = units
+	return nil
+}
+
+// Test_31_setUnitsByRule tests setting the units of all rules
+func (test *TestServiceSuite) Test_31_setUnitsByRule() {
+	s := test.Require
+
+	get := &serviceConfig.Unit{ServiceId: "main", HandlerId: "main_1", Command: "get"}
+	set := &serviceConfig.Unit{ServiceId: "aux", HandlerId: "aux_1", Command: "set"}
+	main := serviceConfig.NewServiceDestination("main")
+	aux := serviceConfig.NewServiceDestination("aux")
+	rules := []*serviceConfig.Rule{main, aux}
+	units := [][]*serviceConfig.Unit{{get}, {set}}
+
+	setter := &fakeUnitsSetter{set: make(map[string][]*serviceConfig.Unit)}
+	amount, err := setUnitsByRule(setter, rules, units)
+	s().NoError(err)
+	s().Equal(2, amount)
+	s().Equal([]*serviceConfig.Unit{get}, setter.set[ruleKey(main)])
+	s().Equal([]*serviceConfig.Unit{set}, setter.set[ruleKey(aux)])
+
+	// the rules set before the failure are counted
+	setter = &fakeUnitsSetter{set: make(map[string][]*serviceConfig.Unit), failOn: "aux"}
+	amount, err = setUnitsByRule(setter, rules, units)
+	s().Error(err)
+	s().Equal(1, amount)
+	s().Len(setter.set, 1)
+
+	// the units must be given for each rule
+	_, err = setUnitsByRule(setter, rules, units[:1])
+	s().Error(err)
+}
+
+// Test_32_patchUnits tests the update of the held units by the difference,
+// and the rules that disappeared from the proxy chains
+func (test *TestServiceSuite) Test_32_patchUnits() {
+	s := test.Require
+
+	get := &serviceConfig.Unit{ServiceId: "main", HandlerId: "main_1", Command: "get"}
+	set := &serviceConfig.Unit{ServiceId: "main", HandlerId: "main_1", Command: "set"}
+	scaled := &serviceConfig.Unit{ServiceId: "main", HandlerId: "main_2", Command: "get"}
+	main := serviceConfig.NewServiceDestination("main")
+
+	// the kept units stay in their order, the added ones are appended
+	setter := &fakeUnitsSetter{set: make(map[string][]*serviceConfig.Unit)}
+	held := []*serviceConfig.Unit{get, set}
+	added, removed := diffUnits(held, []*serviceConfig.Unit{scaled, get})
+	patched, err := patchUnits(setter, main, held, added, removed)
+	s().NoError(err)
+	s().Equal([]*serviceConfig.Unit{get, scaled}, patched)
+	s().Equal(patched, setter.set[ruleKey(main)])
+
+	setter.failOn = "main"
+	_, err = patchUnits(setter, main, held, added, removed)
+	s().Error(err)
+
+	// the held rules that are not in the chains are stale
+	aux := serviceConfig.NewServiceDestination("aux")
+	independent := &Service{}
+	independent.holdUnits(main, patched)
+	independent.holdUnits(aux, []*serviceConfig.Unit{set})
+	s().Empty(independent.staleRules([]*serviceConfig.Rule{main, aux}))
+	stale := independent.staleRules([]*serviceConfig.Rule{main})
+	s().Len(stale, 1)
+	s().Equal(ruleKey(aux), ruleKey(stale[0]))
+
+	independent.dropUnits(aux)
+	_, ok := independent.heldUnits(aux)
+	s().False(ok)
+	s().Empty(independent.staleRules([]*serviceConfig.Rule{main}))
+}
+
+// Test_33_lanes tests the lanes of the handler categories
+func (test *TestServiceSuite) Test_33_lanes() {
+	s := test.Require
+
+	independent := &Service{Handlers: key_value.New()}
+	lanes := priority.New(2)
+	s().Error(independent.SetLanes("main", lanes))
+
+	// the sync replier has no concurrent requests to reorder
+	independent.SetHandler("main", sync_replier.New())
+	s().Error(independent.SetLanes("main", lanes))
+
+	independent.SetHandler("bulk", replier.New())
+	s().NoError(independent.SetLanes("bulk", lanes))
+	s().Error(independent.RoutePriority("main", "get", test.defaultHandleFunc))
+	s().NoError(independent.RoutePriority("bulk", "get", test.defaultHandleFunc))
+
+	s().NoError(independent.startLanes())
+	s().True(lanes.Running())
+	s().NoError(independent.closeLanes())
+	s().False(lanes.Running())
+}
+
+// fakeOrchestra is the orchestra backend with all parts running until closed
+type fakeOrchestra struct {
+	closed bool
+}
+
+func (fake *fakeOrchestra) StartConfig() error          { return nil }
+func (fake *fakeOrchestra) StartDepManager() error      { return nil }
+func (fake *fakeOrchestra) StartProxyHandler() error    { return nil }
+func (fake *fakeOrchestra) IsConfigRunning() bool       { return !fake.closed }
+func (fake *fakeOrchestra) IsDepManagerRunning() bool   { return !fake.closed }
+func (fake *fakeOrchestra) IsProxyHandlerRunning() bool { return false }
+func (fake *fakeOrchestra) Close() error {
+	fake.closed = true
+	return nil
+}
+
+// Test_34_sharedContext tests the context attached to the orchestrator
+func (test *TestServiceSuite) Test_34_sharedContext() {
+	s := test.Require
+
+	token := strings.Repeat("t", orchestra.MinTokenSize)
+	server, err := orchestra.NewServer("tcp://127.0.0.1:0", token, &fakeOrchestra{})
+	s().NoError(err)
+	s().NoError(server.Start())
+
+	// the wrapped context is not used by the orchestra's parts
+	ctx := struct{ context.Interface }{}
+	_, err = NewSharedContext(nil, server.Url(), token, "service_1")
+	s().Error(err)
+	_, err = NewSharedContext(ctx, server.Url(), strings.Repeat("x", orchestra.MinTokenSize), "service_1")
+	s().Error(err)
+
+	shared, err := NewSharedContext(ctx, server.Url(), token, "service_1")
+	s().NoError(err)
+	s().Equal(1, server.Attached("service_1"))
+
+	s().True(shared.IsConfigRunning())
+	s().NoError(shared.StartConfig())
+	s().NoError(shared.StartDepManager())
+	s().False(shared.IsProxyHandlerRunning())
+	s().Error(shared.StartProxyHandler())
+
+	// closing detaches the service, the orchestra keeps running
+	s().NoError(shared.Close())
+	s().Eventually(func() bool { return server.Attached("service_1") == 0 }, time.Second, time.Millisecond)
+	s().False(shared.IsConfigRunning())
+	s().Error(shared.StartConfig())
+
+	s().NoError(server.Close())
+}
+
+// Test_35_monitor tests the endpoints watched by the monitor, and closing the monitor
+func (test *TestServiceSuite) Test_35_monitor() {
+	s := test.Require
+
+	// without the monitor, nothing is watched
+	independent := &Service{Handlers: key_value.New(), Logger: test.logger}
+	independent.watchEndpoint("handler:main", handlerConfig.ReplierType, "mem://service_monitor")
+	independent.unwatchEndpoint("handler:main")
+	s().NoError(independent.closeMonitor())
+
+	memTransport, err := transport.Get("mem")
+	s().NoError(err)
+	bound, err := memTransport.Bind(transport.Rep, "mem://service_monitor")
+	s().NoError(err)
+
+	socketMonitor := monitor.New(test.logger, memTransport)
+	s().NoError(socketMonitor.Start())
+	independent.SetMonitor(socketMonitor)
+
+	// the pusher has no compatible probe
+	independent.watchEndpoint("handler:push", handlerConfig.PusherType, "mem://service_monitor")
+	independent.watchEndpoint("dep:extension", handlerConfig.ReplierType, "mem://service_monitor")
+	s().Eventually(func() bool {
+		return socketMonitor.Metrics()["dep:extension"].Connected == 1
+	}, time.Second, time.Millisecond)
+	_, ok := socketMonitor.Metrics()["handler:push"]
+	s().False(ok)
+
+	// the endpoint that went down is reported
+	s().NoError(bound.Close())
+	s().Eventually(func() bool {
+		return socketMonitor.Metrics()["dep:extension"].Disconnected == 1
+	}, time.Second, time.Millisecond)
+
+	// the unreachable endpoint is logged, not failed
+	independent.watchEndpoint("call:unknown", handlerConfig.SyncReplierType, "mem://service_monitor")
+	independent.unwatchEndpoint("dep:extension")
+
+	s().NoError(independent.closeMonitor())
+	s().False(socketMonitor.Running())
+	s().NoError(independent.closeMonitor())
+}
+
+// Test_36_runHooks tests the hooks run in the order they were added, and the failed hooks
+func (test *TestServiceSuite) Test_36_runHooks() {
+	s := test.Require
+
+	independent := &Service{Logger: test.logger}
+	s().NoError(independent.runHooks(BeforeStart))
+
+	var ran []string
+	hook := func(name string, err error) func() error {
+		return func() error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+
+	// the stages are independent
+	independent.OnBeforeStop("stop", hook("stop", nil), Abort)
+	independent.OnBeforeStart("first", hook("first", nil), Abort)
+	independent.OnBeforeStart("second", hook("second", nil), Warn)
+	independent.OnBeforeStart("third", hook("third", nil), Abort)
+	s().NoError(independent.runHooks(BeforeStart))
+	s().Equal([]string{"first", "second", "third"}, ran)
+
+	// the failed warning hook is logged, the rest are run
+	ran = nil
+	independent.OnAfterStart("warn", hook("warn", fmt.Errorf("cache is cold")), Warn)
+	independent.OnAfterStart("after", hook("after", nil), Abort)
+	s().NoError(independent.runHooks(AfterStart))
+	s().Equal([]string{"warn", "after"}, ran)
+
+	// the failed aborting hook skips the rest
+	ran = nil
+	failed := fmt.Errorf("buffer is not flushed")
+	independent.OnAfterStop("abort", hook("abort", failed), Abort)
+	independent.OnAfterStop("skipped", hook("skipped", nil), Warn)
+	err := independent.runHooks(AfterStop)
+	s().ErrorIs(err, failed)
+	s().ErrorContains(err, "abort")
+	s().Equal([]string{"abort"}, ran)
+}
+
+// Test_37_startHooks tests the aborting hook stopping the Start, and the warning hook not stopping it
+func (test *TestServiceSuite) Test_37_startHooks() {
+	s := test.Require
+
+	var ran []string
+	hook := func(name string, err error) func() error {
+		return func() error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+
+	test.newService()
+	failed := fmt.Errorf("migration failed")
+	test.service.OnBeforeStart("migrate", hook("migrate", failed), Abort)
+	test.service.OnBeforeStart("skipped", hook("skipped", nil), Warn)
+	test.service.OnAfterStart("warm", hook("warm", nil), Abort)
+
+	_, err := test.service.Start()
+	s().ErrorIs(err, failed)
+	s().Equal([]string{"migrate"}, ran)
+
+	// the failed start closed the context
+	test.service = nil
+	win.Args = win.Args[:len(win.Args)-2]
+	time.Sleep(time.Millisecond * 100)
+
+	// the service starts despite the failed warning hook
+	ran = nil
+	test.newService()
+	test.service.OnBeforeStart("migrate", hook("migrate", failed), Warn)
+	test.service.OnBeforeStart("seed", hook("seed", nil), Abort)
+	test.service.OnAfterStart("warm", hook("warm", nil), Abort)
+
+	_, err = test.service.Start()
+	s().NoError(err)
+	s().Equal([]string{"migrate", "seed", "warm"}, ran)
+
+	test.closeService()
+}
+
+// Test_38_syncClient tests the shared client closed once, and refusing the requests after closing
+func (test *TestServiceSuite) Test_38_syncClient() {
+	s := test.Require
+
+	socket, err := clientTo(test.url, &handlerConfig.Handler{Type: handlerConfig.SyncReplierType, Id: "sync", Port: 6001})
+	s().NoError(err)
+	c := newSyncClient(socket)
+
+	// the concurrent closing waits for each other
+	closed := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			closed <- c.Close()
+		}()
+	}
+	s().NoError(<-closed)
+	s().NoError(<-closed)
+
+	_, err = c.Request(&message.Request{Command: test.cmd1, Parameters: key_value.New()})
+	s().ErrorContains(err, "closed")
+	s().ErrorContains(c.Submit(&message.Request{Command: test.cmd1, Parameters: key_value.New()}), "closed")
+}
+
+// Test_39_clientPoolEviction tests that the released and the closed handler manager clients are not reused
+func (test *TestServiceSuite) Test_39_clientPoolEviction() {
+	s := test.Require
+
+	pool := newClientPool()
+	hConfig := &handlerConfig.Handler{
+		Type:           handlerConfig.SyncReplierType,
+		Category:       "pool",
+		Id:             "pool-evicted",
+		InstanceAmount: 1,
+	}
+
+	first, err := pool.get(hConfig)
+	s().NoError(err)
+	s().True(pool.has(hConfig, first))
+	s().False(closed(first))
+
+	// the reloaded handler has another port
+	reloaded := *hConfig
+	reloaded.Port = 6000
+	s().False(pool.has(&reloaded, first))
+
+	// the released client is replaced
+	pool.release(first)
+	s().Equal(0, pool.len())
+	second, err := pool.get(hConfig)
+	s().NoError(err)
+	s().NotSame(first, second)
+
+	// the client of the closed handler is replaced
+	second.(*pooledClient).closed.Store(true)
+	s().True(closed(second))
+	third, err := pool.get(hConfig)
+	s().NoError(err)
+	s().NotSame(second, third)
+	s().Equal(1, pool.len())
+}
+
+// Test_40_namespace tests the service of the tenant with the versioned url
+func (test *TestServiceSuite) Test_40_namespace() {
+	s := test.Suite.Require
+
+	versioned := test.url + "@v1.2.0"
+	win.Args = append(win.Args,
+		arg.NewFlag(flag.IdFlag, test.id),
+		arg.NewFlag(flag.UrlFlag, versioned),
+		arg.NewFlag(flag.NamespaceFlag, "tenant-a"),
+	)
+	defer func() {
+		win.Args = win.Args[:len(win.Args)-3]
+		s().NoError(win.Unsetenv(flag.NamespaceEnv))
+	}()
+
+	independent, err := New()
+	s().NoError(err)
+	s().Equal("tenant-a", independent.Namespace())
+	s().Equal(test.id+namespace.Separator+"tenant-a", independent.Id())
+	s().Equal(versioned+namespace.Separator+"tenant-a", independent.url)
+
+	// the version is kept in the url
+	url, tenant := namespace.Split(independent.url)
+	s().Equal(versioned, url)
+	s().Equal("tenant-a", tenant)
+
+	// the proxies and extensions inherit the namespace, their qualified ids are not qualified again
+	s().Equal("tenant-a", win.Getenv(flag.NamespaceEnv))
+	s().NoError(independent.setNamespace(independent.Id(), independent.url))
+	s().Equal(test.id+namespace.Separator+"tenant-a", independent.Id())
+
+	_, err = independent.generateConfig()
+	s().NoError(err)
+	_, err = independent.ctx.Config().Service(independent.Id())
+	s().NoError(err)
+
+	s().NoError(independent.ctx.Close())
+	test.deleteYaml(test.currentDir, "app")
+
+	// Wait a bit for closing context threads
+	time.Sleep(time.Millisecond * 100)
+}
+
+// Test_41_lazy tests the lazy handler started on the first request instead of the Start
+func (test *TestServiceSuite) Test_41_lazy() {
+	s := test.Require
+
+	test.newService()
+	test.service.SetLazy(test.handlerCategory)
+	s().True(test.service.isLazy(test.handler))
+
+	// the category without the handlers can not be started
+	s().Error(test.service.startLazyHandler("unknown"))
+
+	_, err := test.service.Start()
+	s().NoError(err)
+
+	// wait a bit for thread initialization
+	time.Sleep(time.Millisecond * 100)
+
+	// the Start skips the lazy handler
+	s().True(test.service.isLazy(test.mainHandler()))
+
+	// the proxy signals the manager before forwarding the first request
+	managerClient := &manager.Client{Socket: test.managerClient()}
+	s().NoError(managerClient.StartHandler(test.handlerCategory))
+	s().False(test.service.isLazy(test.mainHandler()))
+
+	externalClient := test.externalClient(test.mainHandler().Config())
+	req := message.Request{
+		Command:    test.cmd1,
+		Parameters: key_value.New(),
+	}
+	reply, err := externalClient.Request(&req)
+	s().NoError(err)
+	s().True(reply.IsOK())
+
+	// the started handler is not started again
+	s().NoError(managerClient.StartHandler(test.handlerCategory))
+	s().NoError(test.service.startLazyHandler(test.handlerCategory))
+	s().NoError(managerClient.Socket.Close())
+
+	s().NoError(test.service.manager.Close())
+	time.Sleep(time.Millisecond * 100)
+
+	// since we closed by manager, the cleaning-out by test suite not necessary.
+	test.service = nil
+	win.Args = win.Args[:len(win.Args)-2]
+	test.deleteYaml(test.currentDir, "app")
+}
+
+// staticElector is the elector with the fixed leadership
+type staticElector struct {
+	leader bool
+}
+
+func (elector *staticElector) Campaign() (bool, error) { return elector.leader, nil }
+func (elector *staticElector) Resign() error           { return nil }
+func (elector *staticElector) IsLeader() bool          { return elector.leader }
+
+// Test_42_serving tests the probe of the lazy handlers and the filtering of the units by the serving handlers
+func (test *TestServiceSuite) Test_42_serving() {
+	s := test.Require
+
+	test.newService()
+	defer test.closeService()
+	test.service.SetHandler(test.handlerCategory, test.handler, Internal)
+
+	// the handler without the configuration is never serving
+	s().False(test.service.probe(sync_replier.New()))
+
+	// the lazy handler not started yet is serving, the proxy starts it on the first request
+	test.service.SetLazy(test.handlerCategory)
+	s().True(test.service.probe(test.handler))
+
+	public := sync_replier.New()
+	publicConfig, err := handlerConfig.NewHandler(handlerConfig.SyncReplierType, "public")
+	s().NoError(err)
+	public.SetConfig(publicConfig)
+	test.service.SetHandler("public", public)
+	test.service.SetLazy("public")
+
+	// the follower serves only the internal handlers
+	test.service.elector = &staticElector{}
+	s().False(test.service.probe(public))
+	s().True(test.service.probe(test.handler))
+
+	test.service.elector = &staticElector{leader: true}
+	s().True(test.service.probe(public))
+
+	// the units of the not serving handlers are filtered out
+	units := []*serviceConfig.Unit{
+		{HandlerId: test.handler.Config().Id},
+		{HandlerId: publicConfig.Id},
+		{HandlerId: "unknown"},
+	}
+	s().True(test.service.refreshServing())
+	s().False(test.service.refreshServing())
+	s().Len(test.service.servingUnits(units), 2)
+
+	test.service.elector = &staticElector{}
+	s().True(test.service.refreshServing())
+	filtered := test.service.servingUnits(units)
+	s().Len(filtered, 1)
+	s().Equal(test.handler.Config().Id, filtered[0].HandlerId)
+
+	test.service.elector = nil
+}
+
+// Test_43_withTimeout tests the phases finished within the timeout and the late phases
+func (test *TestServiceSuite) Test_43_withTimeout() {
+	s := test.Require
+
+	// without the timeout the phase runs in the caller
+	s().NoError(withTimeout("phase", 0, func() error { return nil }))
+	s().Error(withTimeout("phase", 0, func() error { return fmt.Errorf("failed") }))
+
+	// the phase finished in time returns its result, and the late is not called
+	late := make(chan error, 1)
+	onLate := func(err error) {
+		late <- err
+	}
+	s().NoError(withTimeoutThen("phase", time.Second, func() error { return nil }, onLate))
+	s().ErrorContains(withTimeoutThen("phase", time.Second, func() error { return fmt.Errorf("failed") }, onLate), "failed")
+	s().Len(late, 0)
+
+	// the timed out phase passes its result to the late once it finishes
+	release := make(chan struct{})
+	err := withTimeoutThen("phase", time.Millisecond*10, func() error {
+		<-release
+		return fmt.Errorf("late failure")
+	}, onLate)
+	s().ErrorContains(err, "phase not started within")
+	close(release)
+	select {
+	case err := <-late:
+		s().ErrorContains(err, "late failure")
+	case <-time.After(time.Second):
+		s().Fail("late not called")
+	}
+}
+
+// Test_44_startRollback tests that the service failed to start its handlers in time rolls back
+func (test *TestServiceSuite) Test_44_startRollback() {
+	s := test.Require
+
+	test.newService()
+	test.service.SetTimeouts(Timeouts{Handler: time.Nanosecond})
+
+	_, err := test.service.Start()
+	s().ErrorContains(err, "not started within")
+	s().False(test.service.manager.Running())
+
+	// the late handler is closed, so the service starts again
+	time.Sleep(time.Millisecond * 500)
+	test.service = nil
+	win.Args = win.Args[:len(win.Args)-2]
+
+	test.newService()
+	_, err = test.service.Start()
+	s().NoError(err)
+
+	// wait a bit for thread initialization
+	time.Sleep(time.Millisecond * 100)
+
+	externalClient := test.externalClient(test.mainHandler().Config())
+	req := message.Request{
+		Command:    test.cmd1,
+		Parameters: key_value.New(),
+	}
+	reply, err := externalClient.Request(&req)
+	s().NoError(err)
+	s().True(reply.IsOK())
+
+	s().NoError(test.service.manager.Close())
+	time.Sleep(time.Millisecond * 100)
+
+	// since we closed by manager, the cleaning-out by test suite not necessary.
+	test.service = nil
+	win.Args = win.Args[:len(win.Args)-2]
+	test.deleteYaml(test.currentDir, "app")
+}
+
+// Test_45_handlerTypes tests the registration of the third-party handler types
+func (test *TestServiceSuite) Test_45_handlerTypes() {
+	s := test.Require
+
+	name := handlerConfig.HandlerType("test-custom-replier")
+	newHandler := func() base.Interface { return sync_replier.New() }
+	defer func() {
+		handlerTypesMu.Lock()
+		delete(handlerTypes, name)
+		handlerTypesMu.Unlock()
+	}()
+
+	// the incomplete types are rejected
+	s().Error(RegisterHandlerType(HandlerType{Base: handlerConfig.SyncReplierType, New: newHandler}))
+	s().Error(RegisterHandlerType(HandlerType{Name: name, New: newHandler}))
+	s().Error(RegisterHandlerType(HandlerType{Name: name, Base: handlerConfig.SyncReplierType}))
+
+	s().NoError(RegisterHandlerType(HandlerType{Name: name, Base: handlerConfig.SyncReplierType, New: newHandler}))
+	s().Error(RegisterHandlerType(HandlerType{Name: name, Base: handlerConfig.SyncReplierType, New: newHandler}))
+
+	// the base type must be a built-in type
+	s().Error(RegisterHandlerType(HandlerType{Name: "test-nested", Base: name, New: newHandler}))
+	_, ok := registeredType("test-nested")
+	s().False(ok)
+
+	registered, ok := registeredType(name)
+	s().True(ok)
+	s().Equal(handlerConfig.SyncReplierType, registered.Base)
+	names := make([]handlerConfig.HandlerType, 0, 1)
+	for _, handlerType := range registeredTypes() {
+		names = append(names, handlerType.Name)
+	}
+	s().Contains(names, name)
+	s().Equal(handlerConfig.SyncReplierType, baseType(name))
+	s().Equal(handlerConfig.ReplierType, baseType(handlerConfig.ReplierType))
+
+	// the configuration is generated by the base type, then typed by the name
+	test.newService()
+	defer test.closeService()
+
+	generated, err := generateHandler(test.service.ctx, name, "custom", false)
+	s().NoError(err)
+	s().Equal(name, generated.Type)
+	s().NotZero(generated.Port)
+
+	internal, err := generateHandler(test.service.ctx, name, "custom_internal", true)
+	s().NoError(err)
+	s().Equal(name, internal.Type)
+	s().Zero(internal.Port)
+}
+
+// Test_46_overrides tests that the flags overwrite the generated and the stored handler configurations
+func (test *TestServiceSuite) Test_46_overrides() {
+	s := test.Require
+
+	test.newService()
+	defer test.closeService()
+	test.service.SetHandler(test.handlerCategory, test.handler, Internal)
+
+	public := sync_replier.New()
+	s().NoError(public.SetLogger(test.logger))
+	test.service.SetHandler("public", public)
+
+	// the flags of the handlers that are not set are rejected
+	win.Args = append(win.Args, "--handler.unknown.port=6100")
+	s().ErrorContains(test.service.setOverrides(), "'unknown' handler in the flags is not set")
+	win.Args = win.Args[:len(win.Args)-1]
+
+	// the internal handlers have no port
+	win.Args = append(win.Args, "--handler.main.port=6100")
+	s().ErrorContains(test.service.setOverrides(), "'main' handler is internal")
+	win.Args = win.Args[:len(win.Args)-1]
+
+	win.Args = append(win.Args,
+		"--handler.public.port=6101",
+		"--handler.public.instances=3",
+		"--handler.public.restart=always",
+	)
+	s().NoError(test.service.setOverrides())
+	win.Args = win.Args[:len(win.Args)-3]
+	s().NotNil(test.service.tracker("public"))
+	s().Nil(test.service.tracker(test.handlerCategory))
+
+	// the flags overwrite the generated configuration
+	_, err := test.service.generateConfig()
+	s().NoError(err)
+	s().Equal(uint64(6101), public.Config().Port)
+	s().Equal(uint64(3), public.Config().InstanceAmount)
+
+	// the flags overwrite the stored configuration
+	stored := &handlerConfig.Handler{Category: "public", Port: 6200, InstanceAmount: 1}
+	s().True(test.service.applyOverride("public", stored))
+	s().Equal(uint64(6101), stored.Port)
+	s().Equal(uint64(3), stored.InstanceAmount)
+	s().False(test.service.applyOverride("public", stored))
+
+	// the handlers without the flags keep their configuration
+	stored = &handlerConfig.Handler{Category: test.handlerCategory, InstanceAmount: 1}
+	s().False(test.service.applyOverride(test.handlerCategory, stored))
+	s().Zero(stored.Port)
+}
+
+// Test_47_configWatch tests that the changed configuration and routes change the fingerprint watched by the service
+func (test *TestServiceSuite) Test_47_configWatch() {
+	s := test.Require
+
+	test.newService()
+	defer test.closeService()
+
+	_, err := test.service.generateConfig()
+	s().NoError(err)
+	s().NoError(test.service.startOrchestra())
+
+	first, serviceConf, err := test.service.configFingerprint()
+	s().NoError(err)
+	s().Equal(test.service.Id(), serviceConf.Id)
+
+	// the unchanged configuration has the same fingerprint
+	second, _, err := test.service.configFingerprint()
+	s().NoError(err)
+	s().Equal(first, second)
+
+	// the new route is published without restarting the service
+	s().NoError(test.handler.Route("bye", test.defaultHandleFunc))
+	routed, _, err := test.service.configFingerprint()
+	s().NoError(err)
+	s().NotEqual(first, routed)
+
+	// the new handler in the config engine
+	added, err := handlerConfig.NewHandler(handlerConfig.SyncReplierType, "added")
+	s().NoError(err)
+	serviceConf.SetHandler(added)
+	s().NoError(test.service.ctx.Config().SetService(serviceConf))
+	reloaded, _, err := test.service.configFingerprint()
+	s().NoError(err)
+	s().NotEqual(routed, reloaded)
+
+	// the watch stops without the running manager
+	done := make(chan struct{})
+	go func() {
+		test.service.watchConfig()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ConfigWatchInterval * 2):
+		s().Fail("watchConfig not stopped")
+	}
+}
+
+// Test_48_container tests the detection of the container mode and its environment
+func (test *TestServiceSuite) Test_48_container() {
+	s := test.Require
+
+	test.T().Setenv(flag.ContainerEnv, "")
+	s().False(IsContainer())
+	test.T().Setenv(flag.ContainerEnv, "invalid")
+	s().False(IsContainer())
+	test.T().Setenv(flag.ContainerEnv, "true")
+	s().True(IsContainer())
+
+	test.T().Setenv(flag.GracePeriodEnv, "")
+	period, err := gracePeriod()
+	s().NoError(err)
+	s().Equal(GracePeriod, period)
+	test.T().Setenv(flag.GracePeriodEnv, "3s")
+	period, err = gracePeriod()
+	s().NoError(err)
+	s().Equal(time.Second*3, period)
+	test.T().Setenv(flag.GracePeriodEnv, "3")
+	_, err = gracePeriod()
+	s().Error(err)
+
+	test.newService()
+	defer test.closeService()
+	test.service.SetHandler(test.handlerCategory, test.handler, Internal)
+
+	public := sync_replier.New()
+	publicConfig, err := handlerConfig.NewHandler(handlerConfig.SyncReplierType, "public-api")
+	s().NoError(err)
+	public.SetConfig(publicConfig)
+	test.service.SetHandler("public-api", public)
+
+	// only the public handlers get the fixed ports
+	test.T().Setenv(flag.PortEnv("public-api"), "6300")
+	test.T().Setenv(flag.PortEnv(test.handlerCategory), "6301")
+	s().NoError(test.service.setContainerPorts())
+	s().Equal(uint64(6300), publicConfig.Port)
+	s().Zero(test.handler.Config().Port)
+
+	test.T().Setenv(flag.PortEnv("public-api"), "70000")
+	s().Error(test.service.setContainerPorts())
+
+	// the service without the running manager is not healthy
+	recorder := httptest.NewRecorder()
+	test.service.onHealth(recorder, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+	s().Equal(http.StatusServiceUnavailable, recorder.Code)
+	s().Contains(recorder.Body.String(), `"running":false`)
+}
+
+// Test_49_trapTermination tests that the termination signal closes the health endpoint of the service
+func (test *TestServiceSuite) Test_49_trapTermination() {
+	s := test.Require
+
+	test.newService()
+	defer test.closeService()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s().NoError(err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	s().NoError(listener.Close())
+
+	test.T().Setenv(flag.HealthPortEnv, "")
+	s().NoError(test.service.startHealth())
+	s().Nil(test.service.health)
+	s().NoError(test.service.closeHealth(time.Second))
+
+	test.T().Setenv(flag.HealthPortEnv, fmt.Sprintf("%d", port))
+	s().NoError(test.service.startHealth())
+	healthUrl := fmt.Sprintf("http://127.0.0.1:%d%s", port, HealthPath)
+	s().Eventually(func() bool {
+		resp, err := http.Get(healthUrl)
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusServiceUnavailable
+	}, time.Second, time.Millisecond*10)
+
+	// the stopped trap ignores the signals, and stopping it twice is safe
+	untrap := test.service.trapTermination(time.Second)
+	s().NoError(untrap())
+	s().NoError(untrap())
+
+	// the trapped signal closes the service instead of killing the process
+	if runtime.GOOS == "windows" {
+		s().NoError(test.service.closeHealth(time.Second))
+		return
+	}
+	test.service.trapTermination(time.Second * 5)
+	process, err := win.FindProcess(win.Getpid())
+	s().NoError(err)
+	s().NoError(process.Signal(syscall.SIGTERM))
+	s().Eventually(func() bool {
+		resp, err := http.Get(healthUrl)
+		if err != nil {
+			return true
+		}
+		_ = resp.Body.Close()
+		return false
+	}, time.Second*2, time.Millisecond*10)
+}
+
+// Test_50_isPublic tests that the handlers are public unless they have the Internal option
+func (test *TestServiceSuite) Test_50_isPublic() {
+	s := test.Require
+
+	test.newService()
+	defer test.closeService()
+
+	internal := sync_replier.New()
+	internalConfig, err := handlerConfig.NewHandler(handlerConfig.SyncReplierType, "internal-api")
+	s().NoError(err)
+	internalConfig.Port = 6310
+	internal.SetConfig(internalConfig)
+	test.service.SetHandler("internal-api", internal, Internal)
+
+	public := sync_replier.New()
+	publicConfig, err := handlerConfig.NewHandler(handlerConfig.SyncReplierType, "public-api")
+	s().NoError(err)
+	publicConfig.Port = 0
+	public.SetConfig(publicConfig)
+	test.service.SetHandler("public-api", public)
+
+	// the port doesn't decide whether the handler is public
+	s().False(test.service.isPublic(internal))
+	s().True(test.service.isPublic(public))
+
+	// the handler without the configuration is not public
+	s().False(test.service.isPublic(sync_replier.New()))
+
+	// the option is replaced by setting the handler again
+	test.service.SetHandler("internal-api", internal)
+	s().True(test.service.isPublic(internal))
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestService(t *testing.T) {
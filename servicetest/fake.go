@@ -0,0 +1,176 @@
+package servicetest
+
+import (
+	"fmt"
+	clientConfig "github.com/ahmetson/client-lib/config"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	handlerConfig "github.com/ahmetson/handler-lib/config"
+	"github.com/ahmetson/handler-lib/manager_client"
+	"github.com/ahmetson/handler-lib/sync_replier"
+	"github.com/ahmetson/log-lib"
+	"github.com/ahmetson/service-lib/errs"
+	"github.com/ahmetson/service-lib/manager"
+	"sync"
+)
+
+// FakeCategory is the category of the handler of the fake
+const FakeCategory = "main"
+
+// Fake is the proxy or the extension declared in the test code.
+// It runs in the test process instead of the binary:
+// its manager replies to the heartbeat, and its handler replies by the routes set by Route.
+//
+// The configuration of the fake is set into the context of the orchestra before the service starts,
+// so the service finds it running.
+type Fake struct {
+	Id       string
+	Url      string
+	Type     serviceConfig.Type
+	handler  *sync_replier.SyncReplier
+	manager  *sync_replier.SyncReplier
+	conf     *serviceConfig.Service
+	requests []message.RequestInterface
+	mu       sync.Mutex
+	started  bool
+}
+
+// NewFake creates the fake of the service type.
+// The handler and the manager get the generated ports.
+func NewFake(id string, url string, serviceType serviceConfig.Type) (*Fake, error) {
+	logger, err := log.New(id, true)
+	if err != nil {
+		return nil, fmt.Errorf("log.New('%s'): %w", id, err)
+	}
+
+	conf, err := serviceConfig.Empty(id, url, serviceType)
+	if err != nil {
+		return nil, fmt.Errorf("serviceConfig.Empty('%s'): %w", id, err)
+	}
+	hConfig, err := handlerConfig.NewHandler(handlerConfig.SyncReplierType, FakeCategory)
+	if err != nil {
+		return nil, fmt.Errorf("handlerConfig.NewHandler: %w", err)
+	}
+	conf.Handlers = append(conf.Handlers, hConfig)
+
+	handler := sync_replier.New()
+	handler.SetConfig(hConfig)
+	if err := handler.SetLogger(logger); err != nil {
+		return nil, fmt.Errorf("handler.SetLogger: %w", err)
+	}
+
+	managerHandler := sync_replier.New()
+	managerHandler.SetConfig(&handlerConfig.Handler{
+		Type:           handlerConfig.SyncReplierType,
+		Category:       serviceConfig.ManagerCategory,
+		InstanceAmount: 1,
+		Id:             conf.Manager.Id,
+		Port:           conf.Manager.Port,
+	})
+	if err := managerHandler.SetLogger(logger); err != nil {
+		return nil, fmt.Errorf("manager.SetLogger: %w", err)
+	}
+	onHeartbeat := func(req message.RequestInterface) message.ReplyInterface {
+		return req.Ok(key_value.New())
+	}
+	if err := managerHandler.Route(manager.Heartbeat, onHeartbeat); err != nil {
+		return nil, fmt.Errorf("manager.Route('%s'): %w", manager.Heartbeat, err)
+	}
+
+	return &Fake{
+		Id:       id,
+		Url:      url,
+		Type:     serviceType,
+		handler:  handler,
+		manager:  managerHandler,
+		conf:     conf,
+		requests: make([]message.RequestInterface, 0),
+	}, nil
+}
+
+// Route adds the command into the handler of the fake.
+// The requests are recorded before calling the handle, see Requests.
+func (fake *Fake) Route(command string, handle func(message.RequestInterface) message.ReplyInterface) error {
+	record := func(req message.RequestInterface) message.ReplyInterface {
+		fake.mu.Lock()
+		fake.requests = append(fake.requests, req)
+		fake.mu.Unlock()
+		return handle(req)
+	}
+	if err := fake.handler.Route(command, record); err != nil {
+		return fmt.Errorf("handler.Route('%s'): %w", command, err)
+	}
+	return nil
+}
+
+// Requests returns the requests received by the fake in the order of arrival
+func (fake *Fake) Requests() []message.RequestInterface {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	requests := make([]message.RequestInterface, len(fake.requests))
+	copy(requests, fake.requests)
+	return requests
+}
+
+// Proxy returns the fake as the proxy of the proxy chain
+func (fake *Fake) Proxy() *serviceConfig.Proxy {
+	return &serviceConfig.Proxy{Id: fake.Id}
+}
+
+// Config returns the configuration of the fake set into the context
+func (fake *Fake) Config() *serviceConfig.Service {
+	return fake.conf
+}
+
+// Start the manager and the handler of the fake
+func (fake *Fake) Start() error {
+	if err := fake.manager.Start(); err != nil {
+		return fmt.Errorf("manager.Start: %w", err)
+	}
+	if err := fake.handler.Start(); err != nil {
+		return errs.Join(fmt.Errorf("handler.Start: %w", err), closeHandler(fake.manager.Config()))
+	}
+	fake.started = true
+	return nil
+}
+
+// Close the handler and the manager of the started fake
+func (fake *Fake) Close() error {
+	if !fake.started {
+		return nil
+	}
+	fake.started = false
+
+	return errs.Join(
+		errs.Wrap("handler", closeHandler(fake.handler.Config())),
+		errs.Wrap("manager", closeHandler(fake.manager.Config())),
+	)
+}
+
+// Running returns true if the manager of the fake replies to the heartbeat
+func (fake *Fake) Running() bool {
+	managerConfig := clientConfig.New(fake.Url, fake.conf.Manager.Id, fake.conf.Manager.Port, fake.conf.Manager.TargetType)
+	managerConfig.UrlFunc(clientConfig.Url)
+	managerClient, err := manager.NewClient(managerConfig)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = managerClient.Socket.Close()
+	}()
+	return managerClient.Heartbeat() == nil
+}
+
+// The closeHandler closes the running handler by its handler manager
+func closeHandler(c *handlerConfig.Handler) error {
+	handlerClient, err := manager_client.New(c)
+	if err != nil {
+		return fmt.Errorf("manager_client.New('%s'): %w", c.Id, err)
+	}
+	if err := handlerClient.Close(); err != nil {
+		return fmt.Errorf("handlerClient('%s').Close: %w", c.Id, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package servicetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	service "github.com/ahmetson/service-lib"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// UpdateEnv rewrites the golden files by the current values if it's set to "true":
+//
+//	UPDATE_GOLDEN=true go test ./...
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// GoldenDir is the directory of the golden files, relative to the package of the test
+const GoldenDir = "testdata"
+
+// Masked replaces the values of the masked fields
+const Masked = "<masked>"
+
+// Canonical returns the stable JSON of the value.
+// The object keys are sorted, and the values of the masked keys are replaced by Masked at any depth.
+// Mask the values that change on each run, for example the generated ports.
+func Canonical(v interface{}, masks ...string) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	masked := make(map[string]struct{}, len(masks))
+	for _, key := range masks {
+		masked[key] = struct{}{}
+	}
+	generic = mask(generic, masked)
+
+	// maps are encoded with the sorted keys
+	data, err := json.MarshalIndent(generic, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func mask(v interface{}, masked map[string]struct{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, nested := range value {
+			if _, ok := masked[key]; ok {
+				value[key] = Masked
+				continue
+			}
+			value[key] = mask(nested, masked)
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = mask(value[i], masked)
+		}
+	}
+	return v
+}
+
+// Golden compares the canonical form of the value with the golden file testdata/<name>.golden.
+// If UpdateEnv is set, or the golden file doesn't exist, then the file is written instead.
+func Golden(t testing.TB, name string, v interface{}, masks ...string) {
+	t.Helper()
+
+	actual, err := Canonical(v, masks...)
+	if err != nil {
+		t.Fatalf("Canonical: %v", err)
+	}
+
+	filePath := filepath.Join(GoldenDir, name+".golden")
+	expected, err := os.ReadFile(filePath)
+	if os.Getenv(UpdateEnv) == "true" || os.IsNotExist(err) {
+		if err := os.MkdirAll(GoldenDir, 0755); err != nil {
+			t.Fatalf("os.MkdirAll('%s'): %v", GoldenDir, err)
+		}
+		if err := os.WriteFile(filePath, actual, 0644); err != nil {
+			t.Fatalf("os.WriteFile('%s'): %v", filePath, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("os.ReadFile('%s'): %v", filePath, err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%s differs from the golden file, set %s=true to update it\nexpected:\n%s\nactual:\n%s",
+			name, UpdateEnv, expected, actual)
+	}
+}
+
+// GoldenConfig generates the configuration of the orchestra's service, and compares it with the golden file.
+// The ports are masked, as they are allocated on each run.
+func (orchestra *Orchestra) GoldenConfig(t testing.TB, name string, masks ...string) {
+	t.Helper()
+
+	generated, err := service.GenerateConfig(orchestra.Service)
+	if err != nil {
+		t.Fatalf("service.GenerateConfig: %v", err)
+	}
+	Golden(t, name, generated, append(masks, "port")...)
+}
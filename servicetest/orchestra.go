@@ -0,0 +1,273 @@
+// Package servicetest runs the service with its orchestra in the integration tests.
+//
+//	func (test *TestSuite) Test_10_ProxyChain() {
+//		s := test.Require
+//
+//		orchestra, err := servicetest.New("service_1", "github.com/ahmetson/service-lib")
+//		s().NoError(err)
+//		defer orchestra.Close()
+//
+//		proxy, err := orchestra.FakeProxy("proxy_1", "github.com/ahmetson/proxy")
+//		s().NoError(err)
+//		proxyChain.Proxies = []*serviceConfig.Proxy{proxy.Proxy()}
+//
+//		orchestra.Handle("main", mainHandler)
+//		s().NoError(orchestra.Chain(proxyChain))
+//		s().NoError(orchestra.Start())
+//
+//		reply, err := orchestra.Request("main", "hello", key_value.New())
+//		s().NoError(err)
+//		s().True(reply.IsOK())
+//
+//		units, err := orchestra.Units(proxyChain.Destination)
+//		s().NoError(err)
+//		s().Len(units, 1)
+//	}
+//
+// The proxies and the extensions are declared in the test code as the fakes, see Fake.
+// The orchestra uses the app.yml in the current directory, and deletes it on Close.
+package servicetest
+
+import (
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/os-lib/path"
+	service "github.com/ahmetson/service-lib"
+	"github.com/ahmetson/service-lib/capture"
+	"github.com/ahmetson/service-lib/errs"
+	"github.com/ahmetson/service-lib/manager"
+	"sync"
+	"time"
+)
+
+// ReadyTimeout is the time given to the service to reply to the heartbeat after Start
+const ReadyTimeout = time.Second * 10
+
+// readyStep is the pause between the heartbeats while waiting for the service
+const readyStep = time.Millisecond * 50
+
+// Orchestra is the service under the test with its context
+type Orchestra struct {
+	Service *service.Service
+	dir     string
+	blocker *sync.WaitGroup
+	manager *manager.Client
+	fakes   []*Fake
+	started bool
+}
+
+// New creates the service with the id and url.
+// The service is not started, add the handlers, the fakes and the proxy chains first.
+func New(id string, url string) (*Orchestra, error) {
+	dir, err := path.CurrentDir()
+	if err != nil {
+		return nil, fmt.Errorf("path.CurrentDir: %w", err)
+	}
+	if err := service.CreateYaml(dir, "app"); err != nil {
+		return nil, fmt.Errorf("service.CreateYaml('%s'): %w", dir, err)
+	}
+
+	created, err := service.New(service.WithId(id), service.WithUrl(url))
+	if err != nil {
+		_ = service.DeleteYaml(dir, "app")
+		return nil, fmt.Errorf("service.New: %w", err)
+	}
+
+	return &Orchestra{Service: created, dir: dir}, nil
+}
+
+// Handle adds the handler of the category
+func (orchestra *Orchestra) Handle(category string, handler base.Interface) {
+	orchestra.Service.SetHandler(category, handler)
+}
+
+// FakeProxy declares the proxy of the proxy chain, started by Start
+func (orchestra *Orchestra) FakeProxy(id string, url string) (*Fake, error) {
+	return orchestra.fake(id, url, serviceConfig.ProxyType)
+}
+
+// FakeExtension declares the extension that the handlers depend on, started by Start
+func (orchestra *Orchestra) FakeExtension(id string, url string) (*Fake, error) {
+	return orchestra.fake(id, url, serviceConfig.ExtensionType)
+}
+
+func (orchestra *Orchestra) fake(id string, url string, serviceType serviceConfig.Type) (*Fake, error) {
+	if orchestra.started {
+		return nil, fmt.Errorf("already started")
+	}
+	fake, err := NewFake(id, url, serviceType)
+	if err != nil {
+		return nil, fmt.Errorf("NewFake('%s'): %w", id, err)
+	}
+	orchestra.fakes = append(orchestra.fakes, fake)
+	return fake, nil
+}
+
+// Chain sets the proxy chain, see service.Service.SetProxyChain.
+// The proxies are started by the context, then the proxy units of this service are published to them.
+func (orchestra *Orchestra) Chain(params ...interface{}) error {
+	if err := orchestra.Service.SetProxyChain(params...); err != nil {
+		return fmt.Errorf("service.SetProxyChain: %w", err)
+	}
+	return nil
+}
+
+// Start the fakes and the service, and wait until the service manager replies.
+// The configurations of the fakes are set into the context before the service starts.
+func (orchestra *Orchestra) Start() error {
+	if err := orchestra.startFakes(); err != nil {
+		return fmt.Errorf("startFakes: %w", err)
+	}
+
+	blocker, err := orchestra.Service.Start()
+	if err != nil {
+		return fmt.Errorf("service.Start: %w", err)
+	}
+	orchestra.blocker = blocker
+	orchestra.started = true
+
+	socket, err := service.ManagerClient(orchestra.Service)
+	if err != nil {
+		return fmt.Errorf("service.ManagerClient: %w", err)
+	}
+	orchestra.manager = &manager.Client{Socket: socket}
+
+	return orchestra.WaitReady(ReadyTimeout)
+}
+
+// The startFakes starts the declared fakes.
+// The fakes are closed on Close, even if one of them failed to start.
+func (orchestra *Orchestra) startFakes() error {
+	for _, fake := range orchestra.fakes {
+		if err := fake.Start(); err != nil {
+			return fmt.Errorf("fake('%s').Start: %w", fake.Id, err)
+		}
+		if err := service.SetServiceConfig(orchestra.Service, fake.Config()); err != nil {
+			return fmt.Errorf("service.SetServiceConfig('%s'): %w", fake.Id, err)
+		}
+	}
+	return nil
+}
+
+// The closeFakes closes the started fakes
+func (orchestra *Orchestra) closeFakes() error {
+	var err error
+	for _, fake := range orchestra.fakes {
+		err = errs.Join(err, errs.Wrap(fmt.Sprintf("fake('%s').Close", fake.Id), fake.Close()))
+	}
+	return err
+}
+
+// WaitReady waits until the service replies to the heartbeat
+func (orchestra *Orchestra) WaitReady(timeout time.Duration) error {
+	if orchestra.manager == nil {
+		return fmt.Errorf("not started")
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := orchestra.manager.Heartbeat()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service not ready within %s: %w", timeout, err)
+		}
+		time.Sleep(readyStep)
+	}
+}
+
+// Manager returns the client of the service manager
+func (orchestra *Orchestra) Manager() *manager.Client {
+	return orchestra.manager
+}
+
+// Request sends the request to the handler of the category
+func (orchestra *Orchestra) Request(category string, command string, parameters key_value.KeyValue) (message.ReplyInterface, error) {
+	raw, ok := orchestra.Service.Handlers[category]
+	if !ok {
+		return nil, fmt.Errorf("handler of '%s' category not found", category)
+	}
+	handler := raw.(base.Interface)
+
+	handlerClient, err := service.ExternalClient(orchestra.Service.Url(), handler.Config())
+	if err != nil {
+		return nil, fmt.Errorf("service.ExternalClient('%s'): %w", category, err)
+	}
+	defer func() {
+		_ = handlerClient.Close()
+	}()
+
+	req := &message.Request{
+		Command:    command,
+		Parameters: parameters,
+	}
+	reply, err := handlerClient.Request(req)
+	if err != nil {
+		return nil, fmt.Errorf("handlerClient.Request('%s'): %w", command, err)
+	}
+	return reply, nil
+}
+
+// Units returns the proxy units published for the rule
+func (orchestra *Orchestra) Units(rule *serviceConfig.Rule) ([]*serviceConfig.Unit, error) {
+	if orchestra.manager == nil {
+		return nil, fmt.Errorf("not started")
+	}
+	units, err := orchestra.manager.Units(rule)
+	if err != nil {
+		return nil, fmt.Errorf("manager.Units: %w", err)
+	}
+	return units, nil
+}
+
+// Close the service and the fakes, and delete the configuration.
+// The running service is closed by the manager, then the orchestra waits until it's released.
+func (orchestra *Orchestra) Close() error {
+	var err error
+	if orchestra.started {
+		err = orchestra.manager.Close()
+		if err != nil {
+			err = fmt.Errorf("manager.Close: %w", err)
+		} else {
+			done := make(chan struct{})
+			go func() {
+				orchestra.blocker.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(ReadyTimeout):
+				err = fmt.Errorf("service not closed within %s", ReadyTimeout)
+			}
+		}
+		orchestra.started = false
+	} else if closeErr := service.CloseParent(orchestra.Service, orchestra.dir); closeErr != nil {
+		return errs.Join(fmt.Errorf("service.CloseParent: %w", closeErr), orchestra.closeFakes())
+	}
+
+	return errs.Join(err, orchestra.closeFakes(), errs.Wrap("service.DeleteYaml", service.DeleteYaml(orchestra.dir, "app")))
+}
+
+// Replay sends the requests recorded by the proxy in the capture mode to the handler of the category.
+// The failed replies stop the replay, see capture.Replay for the speed.
+func (orchestra *Orchestra) Replay(dir string, category string, speed float64) error {
+	records, err := capture.Read(dir, category)
+	if err != nil {
+		return fmt.Errorf("capture.Read('%s'): %w", category, err)
+	}
+
+	return capture.Replay(records, speed, func(record capture.Record) error {
+		reply, err := orchestra.Request(category, record.Command, key_value.KeyValue(record.Parameters))
+		if err != nil {
+			return fmt.Errorf("orchestra.Request: %w", err)
+		}
+		if !reply.IsOK() {
+			return fmt.Errorf("reply error message: %s", reply.ErrorMessage())
+		}
+		return nil
+	})
+}
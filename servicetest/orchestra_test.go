@@ -0,0 +1,128 @@
+package servicetest
+
+import (
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/datatype-lib/data_type/key_value"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/handler-lib/sync_replier"
+	service "github.com/ahmetson/service-lib"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestOrchestraSuite struct {
+	suite.Suite
+
+	id  string
+	url string
+}
+
+func (test *TestOrchestraSuite) SetupTest() {
+	test.id = "service_1"
+	test.url = "github.com/ahmetson/service-lib"
+}
+
+// Test_10_New tests that the orchestra passes the id and url without the flags
+func (test *TestOrchestraSuite) Test_10_New() {
+	s := test.Require
+
+	args := len(os.Args)
+	orchestra, err := New(test.id, test.url)
+	s().NoError(err)
+	s().Len(os.Args, args)
+	s().Equal(test.url, orchestra.Service.Url())
+
+	// the fakes are declared before the start
+	_, err = orchestra.FakeProxy("proxy_1", "github.com/ahmetson/proxy")
+	s().NoError(err)
+	s().Len(orchestra.fakes, 1)
+
+	// not started orchestra has no manager
+	s().Error(orchestra.WaitReady(readyStep))
+	_, err = orchestra.Units(&serviceConfig.Rule{})
+	s().Error(err)
+
+	s().NoError(orchestra.Close())
+}
+
+// Test_11_Fake tests the fake replying by the routes and recording the requests
+func (test *TestOrchestraSuite) Test_11_Fake() {
+	s := test.Require
+
+	fake, err := NewFake("extension_1", "github.com/ahmetson/extension", serviceConfig.ExtensionType)
+	s().NoError(err)
+	s().Len(fake.Config().Handlers, 1)
+	s().NotNil(fake.Config().Manager)
+	s().Equal(fake.Id, fake.Proxy().Id)
+
+	s().NoError(fake.Route("hello", func(req message.RequestInterface) message.ReplyInterface {
+		return req.Ok(key_value.New().Set("from", fake.Id))
+	}))
+
+	// closing the not started fake is skipped
+	s().NoError(fake.Close())
+
+	s().NoError(fake.Start())
+	s().True(fake.Running())
+
+	hConfig := fake.Config().Handlers[0]
+	handlerClient, err := service.ExternalClient(fake.Url, hConfig)
+	s().NoError(err)
+	reply, err := handlerClient.Request(&message.Request{Command: "hello", Parameters: key_value.New()})
+	s().NoError(err)
+	s().True(reply.IsOK())
+	s().NoError(handlerClient.Close())
+
+	s().Len(fake.Requests(), 1)
+	s().Equal("hello", fake.Requests()[0].CommandName())
+
+	s().NoError(fake.Close())
+}
+
+// Test_12_FakeExtension tests the service calling the fake extension
+func (test *TestOrchestraSuite) Test_12_FakeExtension() {
+	s := test.Require
+
+	orchestra, err := New(test.id, test.url)
+	s().NoError(err)
+
+	extension, err := orchestra.FakeExtension("extension_1", "github.com/ahmetson/extension")
+	s().NoError(err)
+	s().NoError(extension.Route("hello", func(req message.RequestInterface) message.ReplyInterface {
+		return req.Ok(key_value.New().Set("from", extension.Id))
+	}))
+
+	orchestra.Handle("main", sync_replier.New())
+	onHello := func(req message.RequestInterface, deps service.Deps) message.ReplyInterface {
+		reply, err := deps[extension.Id].Request(&message.Request{Command: "hello", Parameters: key_value.New()})
+		if err != nil {
+			return req.Fail(fmt.Sprintf("deps.Request: %v", err))
+		}
+		return reply
+	}
+	s().NoError(orchestra.Service.RouteDeps("main", "hello", []string{extension.Id}, onHello))
+
+	s().NoError(orchestra.Start())
+
+	// the fakes are declared before the start
+	_, err = orchestra.FakeProxy("proxy_1", "github.com/ahmetson/proxy")
+	s().Error(err)
+
+	reply, err := orchestra.Request("main", "hello", key_value.New())
+	s().NoError(err)
+	s().True(reply.IsOK())
+	s().Len(extension.Requests(), 1)
+
+	s().NoError(orchestra.Close())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestOrchestra(t *testing.T) {
+	suite.Run(t, new(TestOrchestraSuite))
+}
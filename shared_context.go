@@ -0,0 +1,106 @@
+package service
+
+import (
+	"fmt"
+	context "github.com/ahmetson/dev-lib"
+	"github.com/ahmetson/service-lib/orchestra"
+)
+
+// SharedContext is the context of the orchestra shared by many services on one host.
+// The config engine, the dependency manager and the proxy handler run in the orchestrator process,
+// see cmd/orchestra. The service never starts nor closes them, so one service's exit doesn't stop the others.
+//
+//	ctx, _ := context.New()
+//	shared, err := service.NewSharedContext(ctx, "tcp://localhost:4000", os.Getenv(orchestra.TokenEnv), "service_1")
+//	independent, err := service.New(service.WithContext(shared))
+//
+// The service is attached to the orchestrator by the authenticated connection, see orchestra.Client.
+// Only the state of the orchestra's parts is queried over it.
+// The requests to the parts are sent by the wrapped context over their own sockets, not by the connection,
+// so the wrapped context must reach the sockets of the orchestra process on this host.
+type SharedContext struct {
+	context.Interface
+	client *orchestra.Client
+}
+
+// NewSharedContext attaches the service to the orchestrator at the url, proving the token.
+// The ctx is the context connected to the orchestra.
+func NewSharedContext(ctx context.Interface, url string, token string, serviceId string) (*SharedContext, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("the 'ctx' parameter is nil")
+	}
+	client, err := orchestra.Dial(url, token, serviceId)
+	if err != nil {
+		return nil, fmt.Errorf("orchestra.Dial('%s'): %w", url, err)
+	}
+	return &SharedContext{Interface: ctx, client: client}, nil
+}
+
+// The status returns the state of the orchestra's parts, or all stopped if the orchestrator is unreachable
+func (shared *SharedContext) status() orchestra.Status {
+	status, err := shared.client.Status()
+	if err != nil {
+		return orchestra.Status{}
+	}
+	return status
+}
+
+// IsConfigRunning returns true if the config engine of the orchestrator is running
+func (shared *SharedContext) IsConfigRunning() bool {
+	return shared.status().Config
+}
+
+// IsDepManagerRunning returns true if the dependency manager of the orchestrator is running
+func (shared *SharedContext) IsDepManagerRunning() bool {
+	return shared.status().DepManager
+}
+
+// IsProxyHandlerRunning returns true if the proxy handler of the orchestrator is running
+func (shared *SharedContext) IsProxyHandlerRunning() bool {
+	return shared.status().ProxyHandler
+}
+
+// StartConfig returns an error if the config engine of the orchestrator is not running
+func (shared *SharedContext) StartConfig() error {
+	status, err := shared.client.Status()
+	if err != nil {
+		return fmt.Errorf("orchestra.Status: %w", err)
+	}
+	if !status.Config {
+		return fmt.Errorf("the config engine of the shared orchestra is not running")
+	}
+	return nil
+}
+
+// StartDepManager returns an error if the dependency manager of the orchestrator is not running
+func (shared *SharedContext) StartDepManager() error {
+	status, err := shared.client.Status()
+	if err != nil {
+		return fmt.Errorf("orchestra.Status: %w", err)
+	}
+	if !status.DepManager {
+		return fmt.Errorf("the dep manager of the shared orchestra is not running")
+	}
+	return nil
+}
+
+// StartProxyHandler returns an error if the proxy handler of the orchestrator is not running
+func (shared *SharedContext) StartProxyHandler() error {
+	status, err := shared.client.Status()
+	if err != nil {
+		return fmt.Errorf("orchestra.Status: %w", err)
+	}
+	if !status.ProxyHandler {
+		return fmt.Errorf("the proxy handler of the shared orchestra is not running")
+	}
+	return nil
+}
+
+// Close detaches the service from the orchestrator.
+// The shared orchestra keeps running for the other services.
+func (shared *SharedContext) Close() error {
+	if err := shared.client.Close(); err != nil {
+		return fmt.Errorf("orchestra.Close: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"github.com/ahmetson/handler-lib/base"
+	"sort"
+)
+
+// SetShutdownPriority sets the shutdown priority of the handler by its category or id,
+// or of the extension or proxy by its id.
+//
+// The lower priority stops first, the default priority is 0.
+// For example, the ingestion handler with the priority -1 stops before the flush worker with the default priority,
+// so the flush worker writes everything that was ingested.
+// The manager honors the order when it notifies the parts about the shutdown and closes them.
+func (independent *Service) SetShutdownPriority(component string, priority int) {
+	if independent.priorities == nil {
+		independent.priorities = make(map[string]int)
+	}
+	independent.priorities[component] = priority
+}
+
+// The shutdownPriorities returns the priorities by the ids known to the manager.
+// The handler categories are replaced by the ids of their handlers.
+func (independent *Service) shutdownPriorities() map[string]int {
+	priorities := make(map[string]int, len(independent.priorities))
+	for component, priority := range independent.priorities {
+		raw, ok := independent.Handlers[component]
+		if !ok {
+			priorities[component] = priority
+			continue
+		}
+		handler := raw.(base.Interface)
+		if handler.Config() != nil {
+			priorities[handler.Config().Id] = priority
+		}
+	}
+	return priorities
+}
+
+// The shutdownOrder returns the ids ordered by the shutdown priority, then by the id
+func (independent *Service) shutdownOrder(ids []string) []string {
+	sort.Slice(ids, func(i, j int) bool {
+		pi, pj := independent.priorities[ids[i]], independent.priorities[ids[j]]
+		if pi != pj {
+			return pi < pj
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
@@ -0,0 +1,126 @@
+// Package sizelimit rejects the oversize messages,
+// so a single huge parameter doesn't exhaust the memory of the receiver.
+//
+// The limits are checked when the message is serialized by Encode,
+// and when it's received by Check, before parsing.
+// The ingest adapter checks the raw messages by Check, and the transport sockets drop the oversize messages
+// before reading them, see transport.WithMaxMessageSize.
+// The handlers and the clients of github.com/ahmetson/handler-lib and github.com/ahmetson/client-lib
+// parse the messages before the proxy receives them, so the proxy checks the request it encodes for forwarding,
+// and the raw reply it receives by CheckSize.
+// The rejected messages have the Code in the error, so the clients distinguish them from the other failures.
+package sizelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ahmetson/service-lib/codec"
+	"strings"
+)
+
+// Code is the prefix of the error message of the rejected oversize messages
+const Code = "message_too_large"
+
+const (
+	DefaultRequest = 4 << 20  // 4 MiB
+	DefaultReply   = 16 << 20 // 16 MiB
+)
+
+// Limits of the JSON encoded messages in bytes.
+// The proxy and the transport count the whole message, Route counts the parameters.
+// Zero means no limit.
+type Limits struct {
+	Request int `json:"request,omitempty" yaml:"request,omitempty"`
+	Reply   int `json:"reply,omitempty" yaml:"reply,omitempty"`
+}
+
+// DefaultLimits returns the default limits
+func DefaultLimits() Limits {
+	return Limits{Request: DefaultRequest, Reply: DefaultReply}
+}
+
+// TooLargeError is returned for the oversize messages
+type TooLargeError struct {
+	Kind  string // request or reply
+	Size  int
+	Limit int
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("%s: %s of %d bytes exceeds %d bytes", Code, e.Kind, e.Size, e.Limit)
+}
+
+// IsTooLarge returns true if the error or the error message of the failed reply is about the oversize message
+func IsTooLarge(err error) bool {
+	var tooLarge *TooLargeError
+	if errors.As(err, &tooLarge) {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), Code+":")
+}
+
+// Check returns TooLargeError if the received data exceeds the limit
+func Check(kind string, data []byte, limit int) error {
+	return CheckSize(kind, len(data), limit)
+}
+
+// CheckSize returns TooLargeError if the size of the message exceeds the limit.
+// Use it for the messages received in multiple frames.
+func CheckSize(kind string, size int, limit int) error {
+	if limit > 0 && size > limit {
+		return &TooLargeError{Kind: kind, Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// Encode serializes the value into JSON.
+// Returns TooLargeError if the serialized value exceeds the limit.
+func Encode(kind string, v interface{}, limit int) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := Check(kind, data, limit); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// CheckValue returns TooLargeError if the JSON of the value exceeds the limit.
+// Unlike Encode, the JSON is not allocated.
+func CheckValue(kind string, v interface{}, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	size, err := codec.Size(v)
+	if err != nil {
+		return fmt.Errorf("codec.Size: %w", err)
+	}
+	if size > limit {
+		return &TooLargeError{Kind: kind, Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// Route wraps the route function, so the oversize requests are not handled, and the oversize replies are not sent.
+// The paramsOf returns the parameters of the request, the replyParamsOf returns the parameters of the reply.
+// The onTooLarge returns the failed reply with the error.
+func Route[Req any, Rep any](
+	limits Limits,
+	paramsOf func(Req) map[string]interface{},
+	replyParamsOf func(Rep) map[string]interface{},
+	onTooLarge func(Req, error) Rep,
+	handle func(Req) Rep,
+) func(Req) Rep {
+	return func(req Req) Rep {
+		if err := CheckValue("request", paramsOf(req), limits.Request); err != nil {
+			return onTooLarge(req, err)
+		}
+		reply := handle(req)
+		if err := CheckValue("reply", replyParamsOf(reply), limits.Reply); err != nil {
+			return onTooLarge(req, err)
+		}
+		return reply
+	}
+}
@@ -0,0 +1,95 @@
+package sizelimit
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestSizeLimitSuite struct {
+	suite.Suite
+}
+
+type request struct {
+	params map[string]interface{}
+}
+
+type reply struct {
+	ok     bool
+	err    string
+	params map[string]interface{}
+}
+
+// Test_10_Route tests the rejection of the oversize requests and replies
+func (test *TestSizeLimitSuite) Test_10_Route() {
+	s := test.Require
+
+	_, err := Encode("request", map[string]interface{}{"logs": strings.Repeat("a", 100)}, 50)
+	s().Error(err)
+	s().True(IsTooLarge(err))
+	s().True(IsTooLarge(fmt.Errorf("reply error message: %s", err.Error())))
+	s().False(IsTooLarge(fmt.Errorf("other")))
+
+	data, err := Encode("request", map[string]interface{}{"a": 1}, 0)
+	s().NoError(err)
+	s().Equal(`{"a":1}`, string(data))
+
+	handled := 0
+	route := Route(Limits{Request: 50, Reply: 50},
+		func(req request) map[string]interface{} { return req.params },
+		func(rep reply) map[string]interface{} { return rep.params },
+		func(req request, err error) reply { return reply{err: err.Error()} },
+		func(req request) reply {
+			handled++
+			return reply{ok: true, params: req.params}
+		},
+	)
+
+	rep := route(request{params: map[string]interface{}{"a": 1}})
+	s().True(rep.ok)
+	s().Equal(1, handled)
+
+	// the request is not handled
+	rep = route(request{params: map[string]interface{}{"logs": strings.Repeat("a", 100)}})
+	s().False(rep.ok)
+	s().Contains(rep.err, Code)
+	s().Equal(1, handled)
+
+	// the reply is replaced
+	route = Route(Limits{Reply: 50},
+		func(req request) map[string]interface{} { return req.params },
+		func(rep reply) map[string]interface{} { return rep.params },
+		func(req request, err error) reply { return reply{err: err.Error()} },
+		func(req request) reply {
+			return reply{ok: true, params: map[string]interface{}{"logs": strings.Repeat("a", 100)}}
+		},
+	)
+	rep = route(request{})
+	s().False(rep.ok)
+	s().Contains(rep.err, "reply of")
+}
+
+// Test_11_CheckSize tests the limit of the messages received in multiple frames
+func (test *TestSizeLimitSuite) Test_11_CheckSize() {
+	s := test.Require
+
+	s().NoError(CheckSize("reply", 50, 50))
+	s().NoError(CheckSize("reply", 100, 0))
+
+	err := CheckSize("reply", 51, 50)
+	s().True(IsTooLarge(err))
+	s().Contains(err.Error(), "reply of 51 bytes exceeds 50 bytes")
+
+	s().NoError(Check("request", []byte(`{"a":1}`), 7))
+	s().True(IsTooLarge(Check("request", []byte(`{"a":10}`), 7)))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSizeLimit(t *testing.T) {
+	suite.Run(t, new(TestSizeLimitSuite))
+}
@@ -0,0 +1,235 @@
+// Package slo tracks the latency and error-rate objectives of the routes over the rolling windows.
+//
+// The requests are observed by the route "category/command", or by "category" for all its commands:
+//
+//	tracker := slo.NewTracker()
+//	_ = tracker.Set(slo.Objective{Route: "main/get", MaxLatency: time.Millisecond * 100, MaxErrorRate: 0.01})
+//	tracker.Observe("main", "get", latency, err != nil)
+//
+// Evaluate returns the routes that started violating their objectives since the last evaluation,
+// so each violation is alerted once until the route recovers.
+package slo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The defaults of the objective fields
+const (
+	DefaultWindow      = time.Minute * 5
+	DefaultPercentile  = 0.99
+	DefaultMinRequests = 10
+)
+
+// Objective of the route
+type Objective struct {
+	Route        string        `json:"route"`          // "category/command", or "category" for all commands of the category
+	MaxLatency   time.Duration `json:"max_latency"`    // the latency at the percentile must not exceed it, zero disables
+	Percentile   float64       `json:"percentile"`     // of the latency, between 0 and 1, DefaultPercentile if zero
+	MaxErrorRate float64       `json:"max_error_rate"` // the failed requests share, between 0 and 1, zero disables
+	Window       time.Duration `json:"window"`         // the rolling window, DefaultWindow if zero
+	MinRequests  int           `json:"min_requests"`   // not evaluated with fewer requests, DefaultMinRequests if zero
+}
+
+// withDefaults returns the objective with the zero fields set to the defaults
+func (objective Objective) withDefaults() Objective {
+	if objective.Percentile == 0 {
+		objective.Percentile = DefaultPercentile
+	}
+	if objective.Window == 0 {
+		objective.Window = DefaultWindow
+	}
+	if objective.MinRequests == 0 {
+		objective.MinRequests = DefaultMinRequests
+	}
+	return objective
+}
+
+// Validate returns an error if the objective is invalid
+func (objective Objective) Validate() error {
+	if len(objective.Route) == 0 {
+		return fmt.Errorf("the route is required")
+	}
+	if strings.Count(objective.Route, "/") > 1 {
+		return fmt.Errorf("the route must be 'category' or 'category/command'")
+	}
+	if objective.MaxLatency <= 0 && objective.MaxErrorRate <= 0 {
+		return fmt.Errorf("set max_latency or max_error_rate")
+	}
+	if objective.MaxLatency < 0 {
+		return fmt.Errorf("max_latency must not be negative")
+	}
+	if objective.MaxErrorRate < 0 || objective.MaxErrorRate > 1 {
+		return fmt.Errorf("max_error_rate must be between 0 and 1")
+	}
+	if objective.Percentile < 0 || objective.Percentile > 1 {
+		return fmt.Errorf("percentile must be between 0 and 1")
+	}
+	if objective.Window < 0 || objective.MinRequests < 0 {
+		return fmt.Errorf("window and min_requests must not be negative")
+	}
+	return nil
+}
+
+// Status of the route in the current window
+type Status struct {
+	Objective Objective     `json:"objective"`
+	Requests  int           `json:"requests"`
+	ErrorRate float64       `json:"error_rate"`
+	Latency   time.Duration `json:"latency"` // at the objective's percentile
+	Violated  bool          `json:"violated"`
+	Reasons   []string      `json:"reasons,omitempty"`
+}
+
+type sample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// Tracker of the objectives
+type Tracker struct {
+	objectives map[string]Objective
+	samples    map[string][]sample
+	violated   map[string]bool // the routes violating the objective at the last evaluation
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// NewTracker returns a tracker without objectives
+func NewTracker() *Tracker {
+	return &Tracker{
+		objectives: make(map[string]Objective),
+		samples:    make(map[string][]sample),
+		violated:   make(map[string]bool),
+		now:        time.Now,
+	}
+}
+
+// Set the objective of the route, replacing the previous one
+func (t *Tracker) Set(objective Objective) error {
+	if err := objective.Validate(); err != nil {
+		return fmt.Errorf("objective('%s').Validate: %w", objective.Route, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.objectives[objective.Route] = objective.withDefaults()
+	return nil
+}
+
+// Observe the request of the command.
+// Only the routes with the objectives keep the samples.
+func (t *Tracker) Observe(category string, command string, latency time.Duration, failed bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := sample{at: t.now(), latency: latency, failed: failed}
+	for _, route := range []string{category, category + "/" + command} {
+		objective, ok := t.objectives[route]
+		if !ok {
+			continue
+		}
+		t.samples[route] = append(t.trim(route, objective), s)
+	}
+}
+
+// The trim removes the samples outside the window of the route. Call it under the lock.
+func (t *Tracker) trim(route string, objective Objective) []sample {
+	samples := t.samples[route]
+	since := t.now().Add(-objective.Window)
+	i := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].at.Before(since)
+	})
+	samples = samples[i:]
+	t.samples[route] = samples
+	return samples
+}
+
+// The status evaluates the route. Call it under the lock.
+func (t *Tracker) status(route string, objective Objective) Status {
+	samples := t.trim(route, objective)
+	status := Status{Objective: objective, Requests: len(samples)}
+	if len(samples) == 0 {
+		return status
+	}
+
+	failed := 0
+	latencies := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		latencies[i] = s.latency
+		if s.failed {
+			failed++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	index := int(math.Ceil(objective.Percentile*float64(len(latencies)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	status.Latency = latencies[index]
+	status.ErrorRate = float64(failed) / float64(len(samples))
+
+	if status.Requests < objective.MinRequests {
+		return status
+	}
+	if objective.MaxLatency > 0 && status.Latency > objective.MaxLatency {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("p%g latency %s exceeds %s",
+			objective.Percentile*100, status.Latency, objective.MaxLatency))
+	}
+	if objective.MaxErrorRate > 0 && status.ErrorRate > objective.MaxErrorRate {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("error rate %.4f exceeds %.4f",
+			status.ErrorRate, objective.MaxErrorRate))
+	}
+	status.Violated = len(status.Reasons) > 0
+	return status
+}
+
+// Status returns the state of the objectives ordered by the route
+func (t *Tracker) Status() []Status {
+	if t == nil {
+		return []Status{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	routes := make([]string, 0, len(t.objectives))
+	for route := range t.objectives {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	statuses := make([]Status, 0, len(routes))
+	for _, route := range routes {
+		statuses = append(statuses, t.status(route, t.objectives[route]))
+	}
+	return statuses
+}
+
+// Evaluate returns the routes that started violating their objectives since the last evaluation.
+// The route violating the objective is returned again only after it recovers.
+func (t *Tracker) Evaluate() []Status {
+	violations := make([]Status, 0)
+	for _, status := range t.Status() {
+		route := status.Objective.Route
+
+		t.mu.Lock()
+		was := t.violated[route]
+		t.violated[route] = status.Violated
+		t.mu.Unlock()
+
+		if status.Violated && !was {
+			violations = append(violations, status)
+		}
+	}
+	return violations
+}
@@ -0,0 +1,84 @@
+package slo
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestSloSuite struct {
+	suite.Suite
+}
+
+// Test_10_Validate tests the invalid objectives
+func (test *TestSloSuite) Test_10_Validate() {
+	s := test.Require
+
+	s().Error(Objective{MaxLatency: time.Second}.Validate())
+	s().Error(Objective{Route: "main"}.Validate())
+	s().Error(Objective{Route: "main/get/more", MaxLatency: time.Second}.Validate())
+	s().Error(Objective{Route: "main", MaxErrorRate: 2}.Validate())
+	s().Error(Objective{Route: "main", MaxLatency: time.Second, Percentile: 1.5}.Validate())
+	s().NoError(Objective{Route: "main/get", MaxLatency: time.Second}.Validate())
+}
+
+// Test_11_Evaluate tests the violations over the rolling window
+func (test *TestSloSuite) Test_11_Evaluate() {
+	s := test.Require
+
+	tracker := NewTracker()
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	s().NoError(tracker.Set(Objective{Route: "main/get", MaxLatency: time.Millisecond * 100, MinRequests: 4, Window: time.Minute}))
+	s().NoError(tracker.Set(Objective{Route: "main", MaxErrorRate: 0.25, MinRequests: 4, Window: time.Minute}))
+
+	// not enough requests
+	tracker.Observe("main", "get", time.Second, true)
+	s().Empty(tracker.Evaluate())
+
+	tracker.Observe("main", "get", time.Millisecond*10, false)
+	tracker.Observe("main", "set", time.Millisecond*10, false)
+	tracker.Observe("main", "get", time.Millisecond*10, false)
+	tracker.Observe("main", "get", time.Millisecond*10, false)
+
+	statuses := tracker.Status()
+	s().Len(statuses, 2)
+	s().Equal("main", statuses[0].Objective.Route)
+	s().Equal(5, statuses[0].Requests)
+	s().InDelta(0.2, statuses[0].ErrorRate, 0.001)
+	s().False(statuses[0].Violated)
+
+	// the p99 of the get is the slow request
+	s().Equal(4, statuses[1].Requests)
+	s().Equal(time.Second, statuses[1].Latency)
+
+	violations := tracker.Evaluate()
+	s().Len(violations, 1)
+	s().Equal("main/get", violations[0].Objective.Route)
+
+	// alerted once until the route recovers
+	s().Empty(tracker.Evaluate())
+
+	// the slow request is out of the window
+	now = now.Add(time.Minute + time.Second)
+	for i := 0; i < 4; i++ {
+		tracker.Observe("main", "get", time.Millisecond*10, false)
+	}
+	s().Empty(tracker.Evaluate())
+	s().False(tracker.Status()[1].Violated)
+
+	tracker.Observe("main", "get", time.Second, true)
+	tracker.Observe("main", "get", time.Second, true)
+	violations = tracker.Evaluate()
+	s().Len(violations, 2)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSlo(t *testing.T) {
+	suite.Run(t, new(TestSloSuite))
+}
@@ -0,0 +1,328 @@
+// Package snapshot archives the state of the service into a gzipped tarball, and restores it.
+//
+// The tarball has the effective configuration, the installed proxy chains,
+// and the files of the state directories, such as the broadcast journal or the outbox:
+//
+//	config.json
+//	proxy_chains.json
+//	state/<name>/<files>
+//
+// Take the snapshot on the old host and restore it on the fresh instance
+// to migrate the service between the hosts.
+//
+// The tarball embeds the configuration with its secret keys,
+// the service writes it by seal.WriteFile, so it's encrypted if the seal.KeyEnv is set.
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The names of the archive entries
+const (
+	ConfigFile      = "config.json"
+	ProxyChainsFile = "proxy_chains.json"
+	StateDir        = "state"
+)
+
+// MaxEntrySize is the largest file in the snapshot, in bytes
+const MaxEntrySize = 256 << 20
+
+// The suffixes of the directories created next to the state directory while restoring
+const (
+	restoreSuffix = ".restore-"
+	backupSuffix  = ".old"
+)
+
+// Contents of the snapshot
+type Contents struct {
+	Config      []byte            // the JSON encoded service configuration
+	ProxyChains []byte            // the JSON encoded proxy chains
+	Dirs        map[string]string // the state directories by their name
+}
+
+// writeFile adds the file entry into the archive
+func writeFile(tw *tar.Writer, name string, mode int64, data []byte) error {
+	header := &tar.Header{Name: name, Mode: mode, Size: int64(len(data)), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("tw.WriteHeader('%s'): %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("tw.Write('%s'): %w", name, err)
+	}
+	return nil
+}
+
+// writeDir adds the regular files of the directory into the archive under the prefix
+func writeDir(tw *tar.Writer, prefix string, dir string) error {
+	return filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return fmt.Errorf("filepath.Rel: %w", err)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("entry.Info: %w", err)
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("os.ReadFile: %w", err)
+		}
+		return writeFile(tw, path.Join(prefix, filepath.ToSlash(rel)), int64(info.Mode().Perm()), data)
+	})
+}
+
+// Write the snapshot as the gzipped tarball.
+// The missing state directories are skipped, as the state may be not created yet.
+func Write(w io.Writer, contents Contents) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeFile(tw, ConfigFile, 0644, contents.Config); err != nil {
+		return err
+	}
+	if err := writeFile(tw, ProxyChainsFile, 0644, contents.ProxyChains); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(contents.Dirs))
+	for name := range contents.Dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dir := contents.Dirs[name]
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		if err := writeDir(tw, path.Join(StateDir, name), dir); err != nil {
+			return fmt.Errorf("writeDir('%s'): %w", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("tw.Close: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("gz.Close: %w", err)
+	}
+	return nil
+}
+
+// target returns the state directory of the entry, and the file's path relative to it.
+// Returns an error if the entry escapes its directory.
+func target(name string, dirs map[string]string) (string, string, error) {
+	rel := strings.TrimPrefix(name, StateDir+"/")
+	parts := strings.SplitN(rel, "/", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("'%s' is not in the state directory", name)
+	}
+	dir, ok := dirs[parts[0]]
+	if !ok {
+		return "", "", fmt.Errorf("the '%s' state directory is not set", parts[0])
+	}
+	cleaned := path.Clean(parts[1])
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", "", fmt.Errorf("'%s' is outside of the state directory", name)
+	}
+	return dir, filepath.FromSlash(cleaned), nil
+}
+
+// extract writes the entry into the file, failing if it's larger than MaxEntrySize
+func extract(r io.Reader, filePath string, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile('%s'): %w", filePath, err)
+	}
+	written, err := io.Copy(file, io.LimitReader(r, MaxEntrySize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("io.Copy('%s'): %w", filePath, err)
+	}
+	if written > MaxEntrySize {
+		return fmt.Errorf("'%s' exceeds %d bytes", filePath, MaxEntrySize)
+	}
+	return nil
+}
+
+// readEntry returns the entry's data, failing if it's larger than MaxEntrySize
+func readEntry(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxEntrySize {
+		return nil, fmt.Errorf("exceeds %d bytes", MaxEntrySize)
+	}
+	return data, nil
+}
+
+// staging keeps the extracted state directories until they are swapped in
+type staging struct {
+	dirs    map[string]string // the temporary directories by the state directory
+	swapped map[string]string // the replaced state directories by their backup
+}
+
+// dir returns the temporary directory of the state directory, creating it next to the state directory.
+// Next to it, the rename doesn't cross the file systems.
+func (stage *staging) dir(dir string) (string, error) {
+	if tmp, ok := stage.dirs[dir]; ok {
+		return tmp, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+restoreSuffix)
+	if err != nil {
+		return "", fmt.Errorf("os.MkdirTemp: %w", err)
+	}
+	stage.dirs[dir] = tmp
+	return tmp, nil
+}
+
+// swap replaces the state directories by the extracted ones.
+// If any directory is not replaced, the already replaced directories are restored back.
+func (stage *staging) swap() error {
+	for dir, tmp := range stage.dirs {
+		backup := tmp + backupSuffix
+		if err := os.Rename(dir, backup); err != nil {
+			if !os.IsNotExist(err) {
+				stage.rollback()
+				return fmt.Errorf("os.Rename('%s'): %w", dir, err)
+			}
+			backup = ""
+		}
+		if err := os.Rename(tmp, dir); err != nil {
+			if len(backup) > 0 {
+				_ = os.Rename(backup, dir)
+			}
+			stage.rollback()
+			return fmt.Errorf("os.Rename('%s'): %w", tmp, err)
+		}
+		delete(stage.dirs, dir)
+		stage.swapped[dir] = backup
+	}
+
+	for _, backup := range stage.swapped {
+		if len(backup) > 0 {
+			_ = os.RemoveAll(backup)
+		}
+	}
+	return nil
+}
+
+// rollback restores the replaced state directories
+func (stage *staging) rollback() {
+	for dir, backup := range stage.swapped {
+		_ = os.RemoveAll(dir)
+		if len(backup) > 0 {
+			_ = os.Rename(backup, dir)
+		}
+	}
+	stage.swapped = map[string]string{}
+}
+
+// clean removes the temporary directories that were not swapped in
+func (stage *staging) clean() {
+	for _, tmp := range stage.dirs {
+		_ = os.RemoveAll(tmp)
+	}
+}
+
+// Read the snapshot from the gzipped tarball.
+// The state files are extracted into the temporary directories first.
+// Only if the whole snapshot is read and the validate returns no error,
+// the state directories of the snapshot replace the existing ones.
+// The validate is optional.
+// The state directories not in the snapshot are kept as they are.
+//
+// The returned contents have the configuration and the proxy chains.
+// Any entry larger than MaxEntrySize is rejected.
+func Read(r io.Reader, dirs map[string]string, validate func(Contents) error) (Contents, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Contents{}, fmt.Errorf("gzip.NewReader: %w", err)
+	}
+	defer func() {
+		_ = gz.Close()
+	}()
+
+	contents := Contents{Dirs: dirs}
+	stage := &staging{dirs: map[string]string{}, swapped: map[string]string{}}
+	defer stage.clean()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Contents{}, fmt.Errorf("tr.Next: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > MaxEntrySize {
+			return Contents{}, fmt.Errorf("'%s' exceeds %d bytes", header.Name, MaxEntrySize)
+		}
+
+		switch header.Name {
+		case ConfigFile:
+			if contents.Config, err = readEntry(tr); err != nil {
+				return Contents{}, fmt.Errorf("readEntry('%s'): %w", header.Name, err)
+			}
+			continue
+		case ProxyChainsFile:
+			if contents.ProxyChains, err = readEntry(tr); err != nil {
+				return Contents{}, fmt.Errorf("readEntry('%s'): %w", header.Name, err)
+			}
+			continue
+		}
+
+		dir, rel, err := target(header.Name, dirs)
+		if err != nil {
+			return Contents{}, fmt.Errorf("target: %w", err)
+		}
+		tmp, err := stage.dir(dir)
+		if err != nil {
+			return Contents{}, fmt.Errorf("stage.dir('%s'): %w", dir, err)
+		}
+		if err := extract(tr, filepath.Join(tmp, rel), fs.FileMode(header.Mode).Perm()); err != nil {
+			return Contents{}, fmt.Errorf("extract: %w", err)
+		}
+	}
+
+	if len(contents.Config) == 0 {
+		return Contents{}, fmt.Errorf("the snapshot has no %s", ConfigFile)
+	}
+	if validate != nil {
+		if err := validate(contents); err != nil {
+			return Contents{}, fmt.Errorf("validate: %w", err)
+		}
+	}
+	if err := stage.swap(); err != nil {
+		return Contents{}, fmt.Errorf("swap: %w", err)
+	}
+	return contents, nil
+}
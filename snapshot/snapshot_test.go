@@ -0,0 +1,163 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestSnapshotSuite struct {
+	suite.Suite
+}
+
+// Test_10_WriteRead tests restoring the snapshot into the fresh directories
+func (test *TestSnapshotSuite) Test_10_WriteRead() {
+	s := test.Require
+
+	journal := test.T().TempDir()
+	s().NoError(os.MkdirAll(filepath.Join(journal, "prices"), 0755))
+	s().NoError(os.WriteFile(filepath.Join(journal, "prices", "1.json"), []byte(`{"seq":1}`), 0600))
+
+	var buf bytes.Buffer
+	s().NoError(Write(&buf, Contents{
+		Config:      []byte(`{"id":"main"}`),
+		ProxyChains: []byte(`[]`),
+		Dirs:        map[string]string{"journal": journal, "outbox": filepath.Join(journal, "missing")},
+	}))
+
+	restored := test.T().TempDir()
+	contents, err := Read(&buf, map[string]string{"journal": restored}, nil)
+	s().NoError(err)
+	s().Equal(`{"id":"main"}`, string(contents.Config))
+	s().Equal(`[]`, string(contents.ProxyChains))
+
+	data, err := os.ReadFile(filepath.Join(restored, "prices", "1.json"))
+	s().NoError(err)
+	s().Equal(`{"seq":1}`, string(data))
+	info, err := os.Stat(filepath.Join(restored, "prices", "1.json"))
+	s().NoError(err)
+	s().Equal(os.FileMode(0600), info.Mode().Perm())
+}
+
+// Test_11_Read tests rejecting the invalid snapshots
+func (test *TestSnapshotSuite) Test_11_Read() {
+	s := test.Require
+
+	archive := func(name string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		s().NoError(writeFile(tw, ConfigFile, 0644, []byte(`{}`)))
+		s().NoError(writeFile(tw, name, 0644, []byte("data")))
+		s().NoError(tw.Close())
+		s().NoError(gz.Close())
+		return &buf
+	}
+	dirs := map[string]string{"journal": test.T().TempDir()}
+
+	_, err := Read(archive("state/journal/../../escape"), dirs, nil)
+	s().Error(err)
+	_, err = Read(archive("state/unknown/file"), dirs, nil)
+	s().Error(err)
+	_, err = Read(archive("other"), dirs, nil)
+	s().Error(err)
+	_, err = Read(archive("state/journal/file"), dirs, nil)
+	s().NoError(err)
+
+	// no configuration
+	var buf bytes.Buffer
+	s().NoError(Write(&buf, Contents{}))
+	_, err = Read(&buf, dirs, nil)
+	s().Error(err)
+	_, err = Read(bytes.NewBufferString("not gzip"), dirs, nil)
+	s().Error(err)
+}
+
+// Test_12_Restore tests that the existing state is replaced only by the valid snapshot
+func (test *TestSnapshotSuite) Test_12_Restore() {
+	s := test.Require
+
+	parent := test.T().TempDir()
+	journal := filepath.Join(parent, "journal")
+	s().NoError(os.MkdirAll(journal, 0755))
+	s().NoError(os.WriteFile(filepath.Join(journal, "old.json"), []byte("old"), 0600))
+	dirs := map[string]string{"journal": journal}
+
+	archive := func(entries map[string]string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		s().NoError(writeFile(tw, ConfigFile, 0644, []byte(`{"id":"main"}`)))
+		for name, data := range entries {
+			s().NoError(writeFile(tw, name, 0644, []byte(data)))
+		}
+		s().NoError(tw.Close())
+		s().NoError(gz.Close())
+		return &buf
+	}
+	unchanged := func() {
+		data, err := os.ReadFile(filepath.Join(journal, "old.json"))
+		s().NoError(err)
+		s().Equal("old", string(data))
+		_, err = os.Stat(filepath.Join(journal, "new.json"))
+		s().True(os.IsNotExist(err))
+
+		// no temporary directories are left
+		entries, err := os.ReadDir(parent)
+		s().NoError(err)
+		s().Len(entries, 1)
+	}
+
+	// the invalid entry after the valid one
+	_, err := Read(archive(map[string]string{"state/journal/new.json": "new", "state/unknown/file": "data"}), dirs, nil)
+	s().Error(err)
+	unchanged()
+
+	// the snapshot rejected by the validation
+	rejected := fmt.Errorf("the other service")
+	_, err = Read(archive(map[string]string{"state/journal/new.json": "new"}), dirs, func(Contents) error {
+		return rejected
+	})
+	s().ErrorIs(err, rejected)
+	unchanged()
+
+	// the entry larger than the limit
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	s().NoError(writeFile(tw, ConfigFile, 0644, []byte(`{"id":"main"}`)))
+	s().NoError(tw.WriteHeader(&tar.Header{Name: "state/journal/new.json", Mode: 0644, Size: MaxEntrySize + 1, Typeflag: tar.TypeReg}))
+	// the data is never written, the header is enough to reject it
+	s().NoError(gz.Close())
+	_, err = Read(&buf, dirs, nil)
+	s().ErrorContains(err, "exceeds")
+	unchanged()
+
+	// the valid snapshot replaces the directory
+	_, err = Read(archive(map[string]string{"state/journal/new.json": "new"}), dirs, func(Contents) error {
+		return nil
+	})
+	s().NoError(err)
+	data, err := os.ReadFile(filepath.Join(journal, "new.json"))
+	s().NoError(err)
+	s().Equal("new", string(data))
+	_, err = os.Stat(filepath.Join(journal, "old.json"))
+	s().True(os.IsNotExist(err))
+	entries, err := os.ReadDir(parent)
+	s().NoError(err)
+	s().Len(entries, 1)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSnapshot(t *testing.T) {
+	suite.Run(t, new(TestSnapshotSuite))
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/service-lib/errs"
+	"time"
+)
+
+// Timeouts of the start phases.
+// Zero timeout means waiting without a limit.
+type Timeouts struct {
+	DepManager   time.Duration // starting the dependency manager in the context
+	ProxyHandler time.Duration // starting the proxy handler in the context
+	Handler      time.Duration // starting each handler, the handlers start in parallel
+}
+
+// DefaultTimeouts returns the timeouts used if SetTimeouts was not called
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		DepManager:   time.Second * 30,
+		ProxyHandler: time.Second * 30,
+		Handler:      time.Second * 10,
+	}
+}
+
+// SetTimeouts sets the timeouts of the start phases.
+// Call it before Start.
+func (independent *Service) SetTimeouts(timeouts Timeouts) {
+	independent.timeouts = timeouts
+}
+
+// withTimeout runs the phase and returns an error if it's not finished within the timeout.
+// The timed out phase keeps running in the background, as the phases can not be interrupted.
+func withTimeout(phase string, timeout time.Duration, run func() error) error {
+	return withTimeoutThen(phase, timeout, run, nil)
+}
+
+// withTimeoutThen runs the phase as withTimeout.
+// If the phase timed out, then the late is called with the result of the phase once it finishes in the background.
+// For example, the handler that started after the timeout is closed by the late.
+func withTimeoutThen(phase string, timeout time.Duration, run func() error, late func(err error)) error {
+	if timeout <= 0 {
+		return run()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		if late != nil {
+			go func() {
+				late(<-done)
+			}()
+		}
+		return fmt.Errorf("%s not started within %s", phase, timeout)
+	}
+}
+
+// startOrchestra starts the dependency manager and the proxy handler of the context.
+// The phases are independent from each other, so they start in parallel.
+func (independent *Service) startOrchestra() error {
+	depErr := make(chan error, 1)
+	go func() {
+		depErr <- withTimeout("dep manager", independent.timeouts.DepManager, func() error {
+			if independent.ctx.IsDepManagerRunning() {
+				return nil
+			}
+			if err := independent.ctx.StartDepManager(); err != nil {
+				return fmt.Errorf("ctx.StartDepManager: %w", err)
+			}
+			return nil
+		})
+	}()
+
+	proxyErr := withTimeout("proxy handler", independent.timeouts.ProxyHandler, func() error {
+		if independent.ctx.IsProxyHandlerRunning() {
+			return nil
+		}
+		if err := independent.ctx.StartProxyHandler(); err != nil {
+			return fmt.Errorf("ctx.StartProxyHandler: %w", err)
+		}
+		return nil
+	})
+
+	return errs.Join(proxyErr, <-depErr)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/service-lib/seal"
+	"github.com/ahmetson/service-lib/snapshot"
+)
+
+// SetStateDir adds the directory with the state of the service into the snapshots.
+// For example, the directory of the broadcast journal or the outbox.
+// The name identifies the directory in the snapshot, so the fresh instance may keep it in another path.
+func (independent *Service) SetStateDir(name string, dir string) {
+	if independent.stateDirs == nil {
+		independent.stateDirs = make(map[string]string)
+	}
+	independent.stateDirs[name] = dir
+}
+
+// The takeSnapshot writes the effective configuration, the proxy chains and the state directories
+// into the tarball at the path, see snapshot.
+// The configuration may have the secret keys, so the tarball is encrypted if the seal.KeyEnv is set.
+func (independent *Service) takeSnapshot(path string) error {
+	serviceConf, err := independent.ctx.Config().Service(independent.id)
+	if err != nil {
+		return fmt.Errorf("ctx.Config().Service('%s'): %w", independent.id, err)
+	}
+	config, err := json.Marshal(serviceConf)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(config): %w", err)
+	}
+	proxyChains, err := independent.ctx.ProxyClient().ProxyChains()
+	if err != nil {
+		return fmt.Errorf("proxyClient.ProxyChains: %w", err)
+	}
+	chains, err := json.Marshal(proxyChains)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(proxyChains): %w", err)
+	}
+
+	var tarball bytes.Buffer
+	contents := snapshot.Contents{Config: config, ProxyChains: chains, Dirs: independent.stateDirs}
+	if err := snapshot.Write(&tarball, contents); err != nil {
+		return fmt.Errorf("snapshot.Write: %w", err)
+	}
+	if err := seal.WriteFile(path, tarball.Bytes(), 0600); err != nil {
+		return fmt.Errorf("seal.WriteFile('%s'): %w", path, err)
+	}
+	return nil
+}
+
+// The restoreSnapshot restores the state directories, the configuration and the proxy chains
+// from the tarball at the path.
+// The snapshot must be of this service.
+// The handlers whose configuration was changed are reloaded, then the proxy units are published again.
+func (independent *Service) restoreSnapshot(path string) error {
+	tarball, err := seal.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("seal.ReadFile('%s'): %w", path, err)
+	}
+
+	// the state of the other service is never swapped in
+	var serviceConf serviceConfig.Service
+	validate := func(contents snapshot.Contents) error {
+		if err := json.Unmarshal(contents.Config, &serviceConf); err != nil {
+			return fmt.Errorf("json.Unmarshal(config): %w", err)
+		}
+		if serviceConf.Id != independent.id {
+			return fmt.Errorf("the snapshot is of the '%s' service, not '%s'", serviceConf.Id, independent.id)
+		}
+		return nil
+	}
+	contents, err := snapshot.Read(bytes.NewReader(tarball), independent.stateDirs, validate)
+	if err != nil {
+		return fmt.Errorf("snapshot.Read: %w", err)
+	}
+	var proxyChains []*serviceConfig.ProxyChain
+	if len(contents.ProxyChains) > 0 {
+		if err := json.Unmarshal(contents.ProxyChains, &proxyChains); err != nil {
+			return fmt.Errorf("json.Unmarshal(proxyChains): %w", err)
+		}
+	}
+
+	if err := independent.ctx.Config().SetService(&serviceConf); err != nil {
+		return fmt.Errorf("ctx.Config().SetService: %w", err)
+	}
+	proxyClient := independent.ctx.ProxyClient()
+	for _, proxyChain := range proxyChains {
+		if err := proxyClient.Set(proxyChain); err != nil {
+			return fmt.Errorf("proxyClient.Set: %w", err)
+		}
+	}
+
+	reloaded, err := independent.reloadChanged(&serviceConf)
+	if err != nil {
+		return fmt.Errorf("reloadChanged: %w", err)
+	}
+	if err := independent.setProxyUnits(); err != nil {
+		return fmt.Errorf("setProxyUnits: %w", err)
+	}
+
+	independent.Logger.Info("snapshot restored", "path", path, "reloaded", reloaded)
+	return nil
+}
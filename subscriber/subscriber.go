@@ -0,0 +1,385 @@
+// Package subscriber receives the broadcasts from the publisher.
+//
+// The Subscriber wraps the Sub socket of the transport, see SetTransport.
+// If the publisher is silent longer than the liveness duration, the subscriber reconnects
+// and subscribes to the topics again.
+// The publisher must broadcast the HeartbeatTopic to keep the subscribers alive when there are no broadcasts.
+// The received broadcasts are validated by frame.Validate before parsing, see SetLimits.
+//
+// The broadcast.ACL is enforced by the publisher, see the publisher package.
+// With the CURVE identities, the subscriber checks the ACL too, see SetCurve and SetACL.
+package subscriber
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/frame"
+	"github.com/ahmetson/service-lib/monitor"
+	"github.com/ahmetson/service-lib/reactor"
+	"github.com/ahmetson/service-lib/transport"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeartbeatTopic is the topic of the publisher's heartbeats.
+	// The heartbeats are not passed to the subscriber's channel.
+	HeartbeatTopic = "heartbeat"
+	// Liveness is the default duration of the publisher's silence, after which the subscriber reconnects.
+	Liveness = time.Second * 20
+	// PollInterval is the default interval to check the incoming broadcasts.
+	PollInterval = time.Millisecond * 100
+	// BufferSize is the default number of the received broadcasts waiting to be read.
+	BufferSize = 256
+)
+
+// The CURVE keys of the connection, z85 encoded
+type curve struct {
+	serverKey string // the public key of the publisher
+	publicKey string
+	secretKey string
+}
+
+// Subscriber receives the broadcasts from the publisher
+type Subscriber struct {
+	url          string
+	topics       []string
+	liveness     time.Duration
+	pollInterval time.Duration
+	broadcasts   chan broadcast.Sequenced
+	errs         chan error
+	transport    transport.Transport
+	reactor      *reactor.Reactor
+	socket       transport.Socket
+	lastSeen     time.Time
+	monitor      *monitor.Monitor // tracks the connection events of the socket, optional
+	limits       frame.Limits     // of the received broadcasts
+	curve        *curve           // the CURVE identities of the publisher and subscriber, optional
+	acl          *broadcast.ACL   // authorizes the topics of the CURVE identities, optional
+	running      bool
+	mu           sync.Mutex
+}
+
+// New returns a subscriber to the publisher at the url.
+// If no topics are given, then it subscribes to all topics.
+func New(url string, topics ...string) (*Subscriber, error) {
+	if len(url) == 0 {
+		return nil, fmt.Errorf("the 'url' parameter is empty")
+	}
+	if len(topics) == 0 {
+		topics = []string{""}
+	}
+
+	return &Subscriber{
+		url:          url,
+		topics:       topics,
+		liveness:     Liveness,
+		pollInterval: PollInterval,
+		limits:       frame.DefaultLimits(),
+		broadcasts:   make(chan broadcast.Sequenced, BufferSize),
+		errs:         make(chan error, 1),
+	}, nil
+}
+
+// SetLiveness sets the duration of the publisher's silence, after which the subscriber reconnects.
+func (sub *Subscriber) SetLiveness(liveness time.Duration) {
+	sub.liveness = liveness
+}
+
+// SetTransport sets the transport of the socket.
+// By default, it's transport.Default.
+// Call it before Start.
+func (sub *Subscriber) SetTransport(t transport.Transport) {
+	sub.transport = t
+}
+
+// SetLimits sets the limits of the received broadcasts.
+// The broadcasts exceeding them are reported as the errors, see frame.Limits.
+func (sub *Subscriber) SetLimits(limits frame.Limits) {
+	sub.limits = limits
+}
+
+// SetCurve connects to the publisher by the CURVE security mechanism.
+// The serverKey is the publisher's public key, the publicKey and secretKey are the subscriber's identity.
+// Call it before Start.
+func (sub *Subscriber) SetCurve(serverKey, publicKey, secretKey string) {
+	sub.curve = &curve{serverKey: serverKey, publicKey: publicKey, secretKey: secretKey}
+}
+
+// SetACL checks the topics by the CURVE identities.
+// It's the defense in depth, the publisher doesn't send the forbidden topics.
+// Start fails if the subscriber's identity may not subscribe to any topic,
+// and the broadcasts of the topics the publisher's identity may not publish are dropped and reported.
+// Without SetCurve, the ACL is not checked.
+// Call it before Start.
+func (sub *Subscriber) SetACL(acl *broadcast.ACL) {
+	sub.acl = acl
+}
+
+// SetMonitor tracks the connection events of the subscriber's socket.
+// The socket is watched under the publisher's url.
+// The monitor must use the same transport as the subscriber.
+// Call it before Start.
+func (sub *Subscriber) SetMonitor(socketMonitor *monitor.Monitor) {
+	sub.monitor = socketMonitor
+}
+
+// SetBufferSize sets the number of the received broadcasts waiting to be read.
+// Call it before Start and Broadcasts.
+func (sub *Subscriber) SetBufferSize(size int) {
+	sub.broadcasts = make(chan broadcast.Sequenced, size)
+}
+
+// Broadcasts returns the channel of the received broadcasts.
+// If the channel is full, the received broadcasts are dropped and reported by Errors,
+// so the slow reader never stops the subscriber.
+// The dropped broadcasts are caught up from the publisher's feed by their sequence numbers.
+func (sub *Subscriber) Broadcasts() <-chan broadcast.Sequenced {
+	return sub.broadcasts
+}
+
+// Errors returns the channel of the errors occurred in the background.
+// The subscriber keeps running after the error.
+// The channel keeps only the last unread error.
+func (sub *Subscriber) Errors() <-chan error {
+	return sub.errs
+}
+
+// Running returns true if the subscriber runs in the background
+func (sub *Subscriber) Running() bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	return sub.running
+}
+
+// connect creates a new Sub socket subscribed to the topics
+func (sub *Subscriber) connect() (transport.Socket, error) {
+	var opts []transport.Option
+	if sub.curve != nil {
+		opts = append(opts, transport.WithCurve(transport.Curve{
+			ServerKey: sub.curve.serverKey,
+			PublicKey: sub.curve.publicKey,
+			SecretKey: sub.curve.secretKey,
+		}))
+	}
+	socket, err := sub.transport.Dial(transport.Sub, sub.url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("transport.Dial('%s'): %w", sub.url, err)
+	}
+
+	// the monitor reports the connection made already
+	if sub.monitor != nil {
+		if err := sub.monitor.Watch(sub.url, socket); err != nil {
+			_ = socket.Close()
+			return nil, fmt.Errorf("monitor.Watch('%s'): %w", sub.url, err)
+		}
+	}
+
+	// subscription to all topics includes the heartbeats
+	topics := append([]string{}, sub.topics...)
+	if topics[0] != "" {
+		topics = append(topics, HeartbeatTopic)
+	}
+	for _, topic := range topics {
+		if err := socket.Subscribe(topic); err != nil {
+			_ = socket.Close()
+			return nil, fmt.Errorf("socket.Subscribe('%s'): %w", topic, err)
+		}
+	}
+
+	return socket, nil
+}
+
+// report passes the error to the errors channel, replacing the unread one.
+func (sub *Subscriber) report(err error) {
+	select {
+	case sub.errs <- err:
+	default:
+		select {
+		case <-sub.errs:
+		default:
+		}
+		select {
+		case sub.errs <- err:
+		default:
+		}
+	}
+}
+
+// ParseStrict is Parse of the frames validated by the limits, for the broadcasts from the untrusted publishers.
+func ParseStrict(frames []string, limits frame.Limits) (broadcast.Sequenced, error) {
+	if len(frames) < 2 {
+		return broadcast.Sequenced{}, fmt.Errorf("expected at least 2 frames, got %d", len(frames))
+	}
+	if err := frame.ValidateFrames(frames[1:], limits); err != nil {
+		return broadcast.Sequenced{}, fmt.Errorf("frame.ValidateFrames: %w", err)
+	}
+	return Parse(frames)
+}
+
+// Parse converts the multipart message into the broadcast.
+// The first frame is the topic, the rest is the JSON encoded broadcast.
+func Parse(frames []string) (broadcast.Sequenced, error) {
+	if len(frames) < 2 {
+		return broadcast.Sequenced{}, fmt.Errorf("expected at least 2 frames, got %d", len(frames))
+	}
+
+	payload := []byte(strings.Join(frames[1:], ""))
+	var sequenced broadcast.Sequenced
+	if err := json.Unmarshal(payload, &sequenced); err != nil {
+		return broadcast.Sequenced{}, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if len(sequenced.Topic) == 0 {
+		sequenced.Topic = frames[0]
+	}
+	// Not sequenced broadcast, the payload is the parameters.
+	if sequenced.Parameters == nil {
+		if err := json.Unmarshal(payload, &sequenced.Parameters); err != nil {
+			return broadcast.Sequenced{}, fmt.Errorf("json.Unmarshal(parameters): %w", err)
+		}
+	}
+
+	return sequenced, nil
+}
+
+// The onMessage is called by the reactor when the socket has a broadcast.
+// The errors are reported, not returned, to keep the reactor running.
+func (sub *Subscriber) onMessage(socket transport.Socket) error {
+	messages, err := socket.Recv()
+	if err != nil {
+		sub.report(fmt.Errorf("socket.Recv: %w", err))
+		return nil
+	}
+	sub.lastSeen = time.Now()
+
+	frames := make([]string, len(messages))
+	for i := range messages {
+		frames[i] = string(messages[i])
+	}
+
+	if len(frames) > 0 && frames[0] == HeartbeatTopic {
+		return nil
+	}
+
+	sequenced, err := ParseStrict(frames, sub.limits)
+	if err != nil {
+		sub.report(fmt.Errorf("ParseStrict: %w", err))
+		return nil
+	}
+	if sub.curve != nil {
+		if err := sub.acl.CanPublish(sub.curve.serverKey, sequenced.Topic); err != nil {
+			sub.report(fmt.Errorf("acl.CanPublish: %w", err))
+			return nil
+		}
+	}
+
+	// the reactor must not wait for the reader, or the liveness is not checked
+	select {
+	case sub.broadcasts <- sequenced:
+	default:
+		sub.report(fmt.Errorf("the broadcasts channel is full, dropped '%s' broadcast #%d", sequenced.Topic, sequenced.Seq))
+	}
+	return nil
+}
+
+// The checkLiveness is called by the reactor periodically.
+// If the publisher is silent longer than the liveness, the socket is re-created.
+func (sub *Subscriber) checkLiveness() error {
+	if time.Since(sub.lastSeen) <= sub.liveness {
+		return nil
+	}
+
+	if sub.socket != nil {
+		if err := sub.reactor.RemoveSocket(sub.socket); err != nil {
+			sub.report(fmt.Errorf("reactor.RemoveSocket: %w", err))
+		}
+		_ = sub.socket.Close()
+		sub.socket = nil
+	}
+
+	socket, err := sub.connect()
+	if err != nil {
+		sub.report(fmt.Errorf("reconnect: %w", err))
+		return nil
+	}
+	if err := sub.reactor.AddSocket(socket, sub.onMessage); err != nil {
+		_ = socket.Close()
+		sub.report(fmt.Errorf("reactor.AddSocket: %w", err))
+		return nil
+	}
+	sub.socket = socket
+	sub.lastSeen = time.Now()
+
+	return nil
+}
+
+// Start receiving the broadcasts in the background.
+func (sub *Subscriber) Start() error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.running {
+		return fmt.Errorf("already running")
+	}
+	if sub.curve != nil {
+		for _, topic := range sub.topics {
+			if err := sub.acl.CanSubscribe(sub.curve.publicKey, topic); err != nil {
+				return fmt.Errorf("acl.CanSubscribe: %w", err)
+			}
+		}
+	}
+
+	if sub.transport == nil {
+		sub.transport = transport.Default()
+	}
+	socket, err := sub.connect()
+	if err != nil {
+		return fmt.Errorf("sub.connect: %w", err)
+	}
+
+	r := reactor.New(sub.transport)
+	r.SetPollInterval(sub.pollInterval)
+	if err := r.AddSocket(socket, sub.onMessage); err != nil {
+		_ = socket.Close()
+		return fmt.Errorf("reactor.AddSocket: %w", err)
+	}
+	r.AddTimer(sub.pollInterval, sub.checkLiveness)
+
+	sub.reactor = r
+	sub.socket = socket
+	sub.lastSeen = time.Now()
+
+	if _, err := r.Run(); err != nil {
+		_ = socket.Close()
+		return fmt.Errorf("reactor.Run: %w", err)
+	}
+	sub.running = true
+
+	return nil
+}
+
+// Close stops receiving the broadcasts
+func (sub *Subscriber) Close() error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if !sub.running {
+		return fmt.Errorf("not running")
+	}
+	sub.running = false
+
+	if err := sub.reactor.Close(); err != nil {
+		return fmt.Errorf("reactor.Close: %w", err)
+	}
+	if sub.socket != nil {
+		if err := sub.socket.Close(); err != nil {
+			return fmt.Errorf("socket.Close: %w", err)
+		}
+		sub.socket = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package subscriber
+
+import (
+	"encoding/json"
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/frame"
+	"github.com/ahmetson/service-lib/transport"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestSubscriberSuite struct {
+	suite.Suite
+
+	transport transport.Transport
+	pub       transport.Socket
+	url       string
+}
+
+func (test *TestSubscriberSuite) SetupTest() {
+	s := test.Require
+
+	t, err := transport.Get("mem")
+	s().NoError(err)
+	test.transport = t
+	test.url = "mem://subscriber_" + test.T().Name()
+	test.pub, err = t.Bind(transport.Pub, test.url)
+	s().NoError(err)
+}
+
+func (test *TestSubscriberSuite) TearDownTest() {
+	_ = test.pub.Close()
+}
+
+// publish sends the sequenced broadcast by the publisher's socket
+func (test *TestSubscriberSuite) publish(topic string, seq uint64) {
+	payload, err := json.Marshal(broadcast.Sequenced{Topic: topic, Seq: seq, Parameters: map[string]interface{}{"seq": seq}})
+	test.Require().NoError(err)
+	test.Require().NoError(test.pub.Send([][]byte{[]byte(topic), payload}))
+}
+
+// newSubscriber returns the started subscriber to the topic
+func (test *TestSubscriberSuite) newSubscriber(bufferSize int) *Subscriber {
+	s := test.Require
+
+	sub, err := New(test.url, "prices")
+	s().NoError(err)
+	sub.SetTransport(test.transport)
+	sub.SetBufferSize(bufferSize)
+	sub.SetLiveness(time.Second)
+	s().NoError(sub.Start())
+	s().True(sub.Running())
+	return sub
+}
+
+// Test_10_Parse tests the conversion of the frames into the broadcast
+func (test *TestSubscriberSuite) Test_10_Parse() {
+	s := test.Require
+
+	sequenced, err := Parse([]string{"prices", `{"topic":"prices","seq":2,"parameters":{"price":1}}`})
+	s().NoError(err)
+	s().Equal(uint64(2), sequenced.Seq)
+	s().Equal("prices", sequenced.Topic)
+
+	// not sequenced broadcast
+	sequenced, err = Parse([]string{"prices", `{"price":1}`})
+	s().NoError(err)
+	s().Equal("prices", sequenced.Topic)
+	s().Equal(float64(1), sequenced.Parameters["price"])
+
+	_, err = Parse([]string{"prices"})
+	s().Error(err)
+	_, err = Parse([]string{"prices", "not json"})
+	s().Error(err)
+	_, err = ParseStrict([]string{"prices"}, frame.DefaultLimits())
+	s().Error(err)
+}
+
+// Test_11_Receive tests the broadcasts of the subscribed topics passed to the channel
+func (test *TestSubscriberSuite) Test_11_Receive() {
+	s := test.Require
+
+	_, err := New("")
+	s().Error(err)
+
+	sub := test.newSubscriber(BufferSize)
+	s().Error(sub.Start())
+
+	// the broadcasts sent before the subscriber is accepted are lost
+	var received broadcast.Sequenced
+	s().Eventually(func() bool {
+		test.publish(HeartbeatTopic, 0)
+		test.publish("orders", 1)
+		test.publish("prices", 1)
+		select {
+		case received = <-sub.Broadcasts():
+			return true
+		case <-time.After(time.Millisecond * 20):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+
+	// neither the heartbeats nor the other topics are passed
+	s().Equal("prices", received.Topic)
+	s().Equal(uint64(1), received.Seq)
+
+	s().NoError(sub.Close())
+	s().False(sub.Running())
+	s().Error(sub.Close())
+}
+
+// Test_12_SlowReader tests that the subscriber keeps receiving while the channel is not read
+func (test *TestSubscriberSuite) Test_12_SlowReader() {
+	s := test.Require
+
+	sub := test.newSubscriber(1)
+	s().Eventually(func() bool {
+		test.publish("prices", 1)
+		select {
+		case <-sub.Broadcasts():
+			return true
+		case <-time.After(time.Millisecond * 20):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+
+	// the channel is full, the rest are dropped and reported
+	for seq := uint64(2); seq <= 5; seq++ {
+		test.publish("prices", seq)
+	}
+	select {
+	case err := <-sub.Errors():
+		s().ErrorContains(err, "full")
+	case <-time.After(time.Second):
+		s().Fail("the dropped broadcast is not reported")
+	}
+	received := <-sub.Broadcasts()
+	s().Equal(uint64(2), received.Seq)
+
+	// the reactor was not blocked by the reader
+	s().Eventually(func() bool {
+		test.publish("prices", 6)
+		select {
+		case received = <-sub.Broadcasts():
+			return received.Seq == 6
+		case <-time.After(time.Millisecond * 20):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+
+	// closing doesn't wait for the reader
+	test.publish("prices", 7)
+	test.publish("prices", 8)
+	done := make(chan error)
+	go func() {
+		done <- sub.Close()
+	}()
+	select {
+	case err := <-done:
+		s().NoError(err)
+	case <-time.After(time.Second):
+		s().Fail("the subscriber is not closed")
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSubscriber(t *testing.T) {
+	suite.Run(t, new(TestSubscriberSuite))
+}
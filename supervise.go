@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/service-lib/restart"
+	"time"
+)
+
+// SetRestartPolicy sets the restart policy of the handler by its category, or of the optional extension by its id.
+// By default, the failed components are not restarted.
+// The handler policy is also set by the flag, see flag.RestartField.
+func (independent *Service) SetRestartPolicy(component string, policy restart.Policy) {
+	independent.restartsMu.Lock()
+	defer independent.restartsMu.Unlock()
+
+	if independent.restarts == nil {
+		independent.restarts = make(map[string]*restart.Tracker)
+	}
+	independent.restarts[component] = restart.NewTracker(policy)
+}
+
+// The tracker returns the restart tracker of the component, or nil if the component has no policy
+func (independent *Service) tracker(component string) *restart.Tracker {
+	independent.restartsMu.Lock()
+	defer independent.restartsMu.Unlock()
+
+	return independent.restarts[component]
+}
+
+// The supervise restarts the handlers that are not serving, following their restart policies.
+// The handlers that serve again have their restart attempts reset.
+func (independent *Service) supervise() {
+	now := time.Now()
+
+	for category, raw := range independent.Handlers {
+		tracker := independent.tracker(category)
+		handler := raw.(base.Interface)
+		if tracker == nil || handler.Config() == nil || independent.skipHandler(handler) {
+			continue
+		}
+
+		independent.servingMu.Lock()
+		serving := independent.serving[handler.Config().Id]
+		independent.servingMu.Unlock()
+
+		if serving {
+			tracker.Reset()
+			continue
+		}
+		if !tracker.Due(now) {
+			continue
+		}
+
+		tracker.Restarted(now)
+		independent.Logger.Warn("restarting the handler", "category", category, "attempt", tracker.Attempts())
+		if err := independent.startHandler(handler); err != nil {
+			independent.Logger.Error("startHandler", "category", category, "error", err)
+		}
+	}
+}
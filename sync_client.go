@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/client-lib"
+	"github.com/ahmetson/datatype-lib/message"
+	"sync"
+)
+
+// SyncClient is the client socket shared by the concurrent callers.
+// The zmq sockets are not thread-safe, so the requests are sent one after another.
+//
+// The client is closed only after the pending request returned,
+// even if its caller stopped waiting for the reply.
+type SyncClient struct {
+	socket *client.Socket
+	closed bool
+	mu     sync.Mutex
+}
+
+// newSyncClient wraps the socket
+func newSyncClient(socket *client.Socket) *SyncClient {
+	return &SyncClient{socket: socket}
+}
+
+// Request sends the request and waits for the reply.
+// Returns an error if the client is closed.
+func (c *SyncClient) Request(req *message.Request) (message.ReplyInterface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, fmt.Errorf("the client is closed")
+	}
+	return c.socket.Request(req)
+}
+
+// Submit sends the request without waiting for the reply.
+// Returns an error if the client is closed.
+func (c *SyncClient) Submit(req *message.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("the client is closed")
+	}
+	return c.socket.Submit(req)
+}
+
+// Close closes the socket after the pending request returned.
+// Closing the closed client does nothing.
+func (c *SyncClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.socket.Close()
+}
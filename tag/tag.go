@@ -0,0 +1,174 @@
+// Package tag groups the handler categories by the tags, such as public, internal or admin.
+//
+// The rules target the tags instead of enumerating the categories.
+// The tag is referenced in the rule categories with the Prefix:
+//
+//	rule := serviceConfig.NewHandlerDestination(url, []string{tag.Ref(tag.Public)})
+//
+// The destination service resolves the reference into the categories tagged by it.
+// A tag set to one category only works as an alias of that category.
+package tag
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Prefix of the tag referenced in the rule categories
+const Prefix = "#"
+
+// The common tags
+const (
+	Public   = "public"
+	Internal = "internal"
+	Admin    = "admin"
+)
+
+// Validate returns an error if the tag can not be used.
+func Validate(tag string) error {
+	if len(tag) == 0 {
+		return fmt.Errorf("tag is empty")
+	}
+	if strings.HasPrefix(tag, Prefix) {
+		return fmt.Errorf("tag '%s' must not start with '%s', use the tag name", tag, Prefix)
+	}
+	if strings.ContainsAny(tag, " \t\n") {
+		return fmt.Errorf("tag '%s' must not have the spaces", tag)
+	}
+	return nil
+}
+
+// Ref returns the reference of the tag used in the rule categories
+func Ref(tag string) string {
+	return Prefix + tag
+}
+
+// FromRef returns the tag referenced by the rule category.
+// Returns false if the category is not a tag reference.
+func FromRef(category string) (string, bool) {
+	if !strings.HasPrefix(category, Prefix) {
+		return "", false
+	}
+	return category[len(Prefix):], true
+}
+
+// Registry keeps the tags of the handler categories
+type Registry struct {
+	tags map[string][]string // the category => tags
+	mu   sync.RWMutex
+}
+
+// NewRegistry returns the registry without the tags
+func NewRegistry() *Registry {
+	return &Registry{tags: make(map[string][]string)}
+}
+
+// Set adds the tags to the category.
+// Returns an error if any tag is invalid, then none is added.
+func (registry *Registry) Set(category string, tags ...string) error {
+	for _, tag := range tags {
+		if err := Validate(tag); err != nil {
+			return err
+		}
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, tag := range tags {
+		if !slices.Contains(registry.tags[category], tag) {
+			registry.tags[category] = append(registry.tags[category], tag)
+		}
+	}
+	return nil
+}
+
+// Remove the tags of the category
+func (registry *Registry) Remove(category string, tags ...string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.tags[category] = slices.DeleteFunc(registry.tags[category], func(tag string) bool {
+		return slices.Contains(tags, tag)
+	})
+	if len(registry.tags[category]) == 0 {
+		delete(registry.tags, category)
+	}
+}
+
+// Tags returns the tags of the category.
+// The nil registry has no tags.
+func (registry *Registry) Tags(category string) []string {
+	if registry == nil {
+		return nil
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return slices.Clone(registry.tags[category])
+}
+
+// Has returns true if the category is tagged by the tag
+func (registry *Registry) Has(category string, tag string) bool {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return slices.Contains(registry.tags[category], tag)
+}
+
+// Categories returns the sorted categories tagged by any of the tags
+func (registry *Registry) Categories(tags ...string) []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	categories := make([]string, 0)
+	for category, categoryTags := range registry.tags {
+		for _, tag := range tags {
+			if slices.Contains(categoryTags, tag) {
+				categories = append(categories, category)
+				break
+			}
+		}
+	}
+	slices.Sort(categories)
+	return categories
+}
+
+// All returns the tags by the category
+func (registry *Registry) All() map[string][]string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	all := make(map[string][]string, len(registry.tags))
+	for category, tags := range registry.tags {
+		all[category] = slices.Clone(tags)
+	}
+	return all
+}
+
+// Match returns true if the rule category selects the category.
+// The rule category is either the category itself, or the reference of its tag, see Ref.
+// The nil registry matches the categories only.
+func (registry *Registry) Match(ruleCategory string, category string) bool {
+	if ruleCategory == category {
+		return true
+	}
+	tag, ok := FromRef(ruleCategory)
+	if !ok || registry == nil {
+		return false
+	}
+	return registry.Has(category, tag)
+}
+
+// MatchAny returns true if any rule category selects the category, see Match.
+func (registry *Registry) MatchAny(ruleCategories []string, category string) bool {
+	for _, ruleCategory := range ruleCategories {
+		if registry.Match(ruleCategory, category) {
+			return true
+		}
+	}
+	return false
+}
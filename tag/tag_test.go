@@ -0,0 +1,72 @@
+package tag
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestTagSuite struct {
+	suite.Suite
+}
+
+// Test_10_Registry tests tagging the categories
+func (test *TestTagSuite) Test_10_Registry() {
+	s := test.Require
+
+	s().Error(Validate(""))
+	s().Error(Validate("#public"))
+	s().Error(Validate("public api"))
+
+	registry := NewRegistry()
+	s().Error(registry.Set("main", Public, "#admin"))
+	s().Empty(registry.Tags("main"))
+
+	s().NoError(registry.Set("main", Public))
+	s().NoError(registry.Set("main", Public))
+	s().NoError(registry.Set("db", Internal))
+	s().NoError(registry.Set("ops", Internal, Admin))
+
+	s().Equal([]string{Public}, registry.Tags("main"))
+	s().True(registry.Has("ops", Admin))
+	s().False(registry.Has("main", Admin))
+	s().Equal([]string{"db", "ops"}, registry.Categories(Internal))
+	s().Equal([]string{"main", "ops"}, registry.Categories(Public, Admin))
+
+	registry.Remove("ops", Internal, Admin)
+	s().Empty(registry.Tags("ops"))
+	s().Len(registry.All(), 2)
+}
+
+// Test_11_Match tests selecting the categories by the rule categories
+func (test *TestTagSuite) Test_11_Match() {
+	s := test.Require
+
+	registry := NewRegistry()
+	s().NoError(registry.Set("main", Public))
+
+	tag, ok := FromRef(Ref(Public))
+	s().True(ok)
+	s().Equal(Public, tag)
+	_, ok = FromRef("main")
+	s().False(ok)
+
+	s().True(registry.Match("main", "main"))
+	s().True(registry.Match(Ref(Public), "main"))
+	s().False(registry.Match(Ref(Internal), "main"))
+	s().False(registry.Match("db", "main"))
+	s().True(registry.MatchAny([]string{"db", Ref(Public)}, "main"))
+
+	// without the registry, only the categories are matched
+	var empty *Registry
+	s().True(empty.Match("main", "main"))
+	s().False(empty.Match(Ref(Public), "main"))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestTag(t *testing.T) {
+	suite.Run(t, new(TestTagSuite))
+}
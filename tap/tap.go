@@ -0,0 +1,204 @@
+// Package tap forwards the copies of the requests and replies of the handler category
+// to the endpoint for the bounded duration, for the live debugging without redeploys.
+//
+// The tap is attached by the manager's tap command. The proxies in front of the handler
+// forward the copies of the requests and replies passing through them.
+// The debugging tool binds the Sub socket on the endpoint, and receives the [category, JSON Frame] messages.
+//
+// The tap is detached when its duration passes. The forwarding never fails or slows down the request:
+// the failed copies are dropped.
+package tap
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/service-lib/transport"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxDuration is the longest time the tap is attached for
+const MaxDuration = time.Hour
+
+// The kinds of the forwarded copies
+const (
+	Request = "request"
+	Reply   = "reply"
+)
+
+// Tap is the endpoint receiving the copies of the handler category
+type Tap struct {
+	Category string    `json:"category"`
+	Endpoint string    `json:"endpoint"`
+	Until    time.Time `json:"until"`
+}
+
+// Params of the manager's tap command.
+// The zero duration detaches the tap.
+type Params struct {
+	Category string        `json:"category"`
+	Endpoint string        `json:"endpoint"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Frame is the forwarded copy of the request or reply
+type Frame struct {
+	Kind       string                 `json:"kind"`
+	Category   string                 `json:"category"`
+	Command    string                 `json:"command"`
+	Parameters map[string]interface{} `json:"parameters"`
+	Time       time.Time              `json:"time"`
+}
+
+// attached tap with its connected socket
+type attached struct {
+	tap    Tap
+	socket transport.Socket
+}
+
+// Registry of the attached taps
+type Registry struct {
+	transport transport.Transport
+	taps      map[string]*attached // by the category and endpoint
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// NewRegistry returns the registry connecting the taps by the transport.
+// If the transport is nil, then transport.Default is used.
+func NewRegistry(t transport.Transport) *Registry {
+	if t == nil {
+		t = transport.Default()
+	}
+	return &Registry{transport: t, taps: make(map[string]*attached), now: time.Now}
+}
+
+func key(category string, endpoint string) string {
+	return category + "|" + endpoint
+}
+
+// Attach the tap to the handler category for the duration.
+// Attaching the same endpoint again prolongs the tap.
+func (r *Registry) Attach(category string, endpoint string, duration time.Duration) (Tap, error) {
+	if len(category) == 0 || len(endpoint) == 0 {
+		return Tap{}, fmt.Errorf("the category and endpoint are required")
+	}
+	if duration <= 0 || duration > MaxDuration {
+		return Tap{}, fmt.Errorf("the duration must be between 0 and %s", MaxDuration)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until := r.now().Add(duration)
+	if existing, ok := r.taps[key(category, endpoint)]; ok {
+		existing.tap.Until = until
+		return existing.tap, nil
+	}
+
+	socket, err := r.transport.Dial(transport.Pub, endpoint)
+	if err != nil {
+		return Tap{}, fmt.Errorf("transport.Dial('%s'): %w", endpoint, err)
+	}
+	tap := Tap{Category: category, Endpoint: endpoint, Until: until}
+	r.taps[key(category, endpoint)] = &attached{tap: tap, socket: socket}
+	return tap, nil
+}
+
+// Detach the tap before its duration passes
+func (r *Registry) Detach(category string, endpoint string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.taps[key(category, endpoint)]
+	if !ok {
+		return fmt.Errorf("no tap of '%s' to '%s'", category, endpoint)
+	}
+	delete(r.taps, key(category, endpoint))
+	return existing.socket.Close()
+}
+
+// The expire detaches the taps whose duration passed. Call it under the lock.
+func (r *Registry) expire() {
+	now := r.now()
+	for k, existing := range r.taps {
+		if now.After(existing.tap.Until) {
+			_ = existing.socket.Close()
+			delete(r.taps, k)
+		}
+	}
+}
+
+// Taps returns the attached taps ordered by the category and endpoint
+func (r *Registry) Taps() []Tap {
+	if r == nil {
+		return []Tap{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expire()
+	taps := make([]Tap, 0, len(r.taps))
+	for _, existing := range r.taps {
+		taps = append(taps, existing.tap)
+	}
+	sort.Slice(taps, func(i, j int) bool {
+		if taps[i].Category != taps[j].Category {
+			return taps[i].Category < taps[j].Category
+		}
+		return taps[i].Endpoint < taps[j].Endpoint
+	})
+	return taps
+}
+
+// Forward the copy to the taps of the category.
+// Returns the number of the taps that received the copy.
+func (r *Registry) Forward(kind string, category string, command string, parameters map[string]interface{}) int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expire()
+	var data []byte
+	sent := 0
+	for _, existing := range r.taps {
+		if existing.tap.Category != category {
+			continue
+		}
+		if data == nil {
+			encoded, err := json.Marshal(Frame{
+				Kind:       kind,
+				Category:   category,
+				Command:    command,
+				Parameters: parameters,
+				Time:       r.now(),
+			})
+			if err != nil {
+				return 0
+			}
+			data = encoded
+		}
+		if err := existing.socket.Send([][]byte{[]byte(category), data}); err == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// Close detaches all taps
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var closeErr error
+	for k, existing := range r.taps {
+		if err := existing.socket.Close(); err != nil && closeErr == nil {
+			closeErr = fmt.Errorf("socket('%s').Close: %w", existing.tap.Endpoint, err)
+		}
+		delete(r.taps, k)
+	}
+	return closeErr
+}
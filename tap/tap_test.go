@@ -0,0 +1,101 @@
+package tap
+
+import (
+	"encoding/json"
+	"github.com/ahmetson/service-lib/transport"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestTapSuite struct {
+	suite.Suite
+	transport transport.Transport
+}
+
+func (test *TestTapSuite) SetupTest() {
+	t, err := transport.Get("tcp")
+	test.Require().NoError(err)
+	test.transport = t
+}
+
+// Test_10_Attach tests the validation and the expiration of the taps
+func (test *TestTapSuite) Test_10_Attach() {
+	s := test.Require
+
+	sub, err := test.transport.Bind(transport.Sub, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = sub.Close() }()
+
+	r := NewRegistry(test.transport)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	_, err = r.Attach("", sub.Endpoint(), time.Minute)
+	s().Error(err)
+	_, err = r.Attach("main", sub.Endpoint(), 0)
+	s().Error(err)
+	_, err = r.Attach("main", sub.Endpoint(), MaxDuration+time.Second)
+	s().Error(err)
+
+	tap, err := r.Attach("main", sub.Endpoint(), time.Minute)
+	s().NoError(err)
+	s().Equal(now.Add(time.Minute), tap.Until)
+
+	// attaching again prolongs the tap
+	tap, err = r.Attach("main", sub.Endpoint(), time.Minute*2)
+	s().NoError(err)
+	s().Equal(now.Add(time.Minute*2), tap.Until)
+	s().Len(r.Taps(), 1)
+
+	now = now.Add(time.Minute * 3)
+	s().Empty(r.Taps())
+	s().Error(r.Detach("main", sub.Endpoint()))
+}
+
+// Test_11_Forward tests receiving the copies of the tapped category
+func (test *TestTapSuite) Test_11_Forward() {
+	s := test.Require
+
+	sub, err := test.transport.Bind(transport.Sub, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = sub.Close() }()
+	s().NoError(sub.Subscribe(""))
+
+	r := NewRegistry(test.transport)
+	_, err = r.Attach("main", sub.Endpoint(), time.Minute)
+	s().NoError(err)
+	// wait for the connection
+	time.Sleep(time.Millisecond * 100)
+
+	s().Zero(r.Forward(Request, "other", "hello", nil))
+	s().Equal(1, r.Forward(Request, "main", "hello", map[string]interface{}{"name": "ahmetson"}))
+
+	readable, err := test.transport.Poll([]transport.Socket{sub}, time.Second)
+	s().NoError(err)
+	s().Len(readable, 1)
+
+	frames, err := sub.Recv()
+	s().NoError(err)
+	s().Len(frames, 2)
+	s().Equal("main", string(frames[0]))
+
+	var frame Frame
+	s().NoError(json.Unmarshal(frames[1], &frame))
+	s().Equal(Request, frame.Kind)
+	s().Equal("hello", frame.Command)
+	s().Equal("ahmetson", frame.Parameters["name"])
+
+	s().NoError(r.Detach("main", sub.Endpoint()))
+	s().Zero(r.Forward(Request, "main", "hello", nil))
+	s().NoError(r.Close())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestTap(t *testing.T) {
+	suite.Run(t, new(TestTapSuite))
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/service-lib/errs"
+	"slices"
+)
+
+// The teardown is the stack of the cleanups of the started phases.
+// If the start fails, the cleanups run in the reverse order, each once.
+type teardown struct {
+	names    []string
+	cleanups []func() error
+}
+
+// The push adds the cleanup of the started phase.
+// The cleanups of the covered phases are removed, as this cleanup closes them too.
+func (t *teardown) push(name string, cleanup func() error, covered ...string) {
+	for i := len(t.names) - 1; i >= 0; i-- {
+		if slices.Contains(covered, t.names[i]) {
+			t.names = slices.Delete(t.names, i, i+1)
+			t.cleanups = slices.Delete(t.cleanups, i, i+1)
+		}
+	}
+
+	t.names = append(t.names, name)
+	t.cleanups = append(t.cleanups, cleanup)
+}
+
+// The run calls the cleanups in the reverse order and empties the stack.
+// The failed cleanup doesn't stop the rest, the errors are aggregated by errs.Join.
+func (t *teardown) run() error {
+	cleanupErrs := make([]error, 0, len(t.cleanups))
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		cleanupErrs = append(cleanupErrs, errs.Wrap(t.names[i], t.cleanups[i]()))
+	}
+
+	t.names = nil
+	t.cleanups = nil
+	return errs.Join(cleanupErrs...)
+}
+
+// The startedHandlers returns the handlers started by the Start.
+// The lazy handlers, and the public handlers of the follower are not started.
+func (independent *Service) startedHandlers() []base.Interface {
+	handlers := make([]base.Interface, 0, len(independent.Handlers))
+	for _, raw := range independent.Handlers {
+		handler := raw.(base.Interface)
+		if handler.Config() != nil && !independent.skipHandler(handler) {
+			handlers = append(handlers, handler)
+		}
+	}
+	return handlers
+}
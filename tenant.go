@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/os-lib/arg"
+	"github.com/ahmetson/service-lib/flag"
+	"github.com/ahmetson/service-lib/namespace"
+	"os"
+)
+
+// The setNamespace qualifies the id and url of the service by its tenant.
+// The tenant is passed by flag.NamespaceFlag or flag.NamespaceEnv.
+// The flag is exported into the environment, so the proxies and extensions started by this service are in the same namespace.
+func (independent *Service) setNamespace(id, url string) error {
+	tenant := os.Getenv(flag.NamespaceEnv)
+	if arg.FlagExist(flag.NamespaceFlag) {
+		tenant = arg.FlagValue(flag.NamespaceFlag)
+	}
+	if err := namespace.Validate(tenant); err != nil {
+		return fmt.Errorf("namespace.Validate: %w", err)
+	}
+
+	if tenant != namespace.Default {
+		if err := os.Setenv(flag.NamespaceEnv, tenant); err != nil {
+			return fmt.Errorf("os.Setenv('%s'): %w", flag.NamespaceEnv, err)
+		}
+	}
+
+	independent.namespace = tenant
+	independent.id = namespace.Qualify(tenant, id)
+	independent.url = namespace.Qualify(tenant, url)
+
+	return nil
+}
+
+// Namespace returns the tenant of the service.
+// The default namespace is empty.
+func (independent *Service) Namespace() string {
+	return independent.namespace
+}
+
+// AllowNamespace lets the proxies and extensions of the source namespace connect to this service.
+// The services of the same namespace are always allowed.
+func (independent *Service) AllowNamespace(source string) {
+	independent.acl.Allow(source, independent.namespace)
+}
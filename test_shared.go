@@ -3,13 +3,13 @@ package service
 import (
 	"github.com/ahmetson/client-lib"
 	clientConfig "github.com/ahmetson/client-lib/config"
+	serviceConfig "github.com/ahmetson/config-lib/service"
 	"github.com/ahmetson/datatype-lib/data_type/key_value"
 	"github.com/ahmetson/handler-lib/base"
 	handlerConfig "github.com/ahmetson/handler-lib/config"
 	"github.com/ahmetson/os-lib/arg"
 	"github.com/ahmetson/os-lib/path"
 	"github.com/ahmetson/service-lib/flag"
-	"github.com/pebbe/zmq4"
 	"gopkg.in/yaml.v3"
 	win "os"
 	"path/filepath"
@@ -18,7 +18,7 @@ import (
 // ParentConfig returns parent config as a struct and string
 func ParentConfig(parentId string, parentUrl string, port uint64) (*clientConfig.Client, string, error) {
 	// Creating a proxy with the valid flags must succeed
-	parentClient := clientConfig.New(parentUrl, parentId, port, zmq4.REP)
+	parentClient := clientConfig.New(parentUrl, parentId, port, handlerConfig.SocketType(handlerConfig.SyncReplierType))
 	parentKv, err := key_value.NewFromInterface(parentClient)
 	if err != nil {
 		return nil, "", err
@@ -120,3 +120,15 @@ func ManagerClient(s *Service) (*client.Socket, error) {
 	managerClient, err := client.New(managerConfig)
 	return managerClient, err
 }
+
+// GenerateConfig generates the configuration of the service and its handlers.
+// Used by the golden config tests, see servicetest.Golden.
+func GenerateConfig(s *Service) (*serviceConfig.Service, error) {
+	return s.generateConfig()
+}
+
+// SetServiceConfig sets the configuration of another service into the context of the service.
+// Used by the fake proxies and extensions, see servicetest.Fake.
+func SetServiceConfig(s *Service, conf *serviceConfig.Service) error {
+	return s.ctx.Config().SetService(conf)
+}
@@ -0,0 +1,198 @@
+// Package timestamp stamps the messages with the monotonic send and receive times,
+// and estimates the clock skew between the services.
+//
+// The timestamps are the request and reply parameters in unix nanoseconds:
+// the client sets SentParam, the route wrapped by Route replies with ReceivedParam and RepliedParam.
+// The four times of the round trip give the clock offset of the peer, like in NTP.
+package timestamp
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	SentParam     = "sent_at"     // the request parameter, when the request was sent
+	ReceivedParam = "received_at" // the reply parameter, when the request was received
+	RepliedParam  = "replied_at"  // the reply parameter, when the reply was sent
+)
+
+// DefaultSamples is the amount of the last round trips kept per peer
+const DefaultSamples = 8
+
+// the wall clock at the process start, the monotonic time is added to it
+var start = time.Now()
+
+// Now returns the timestamp in unix nanoseconds.
+// It's the wall clock at the process start plus the monotonic time since then,
+// so unlike the wall clock it never goes backward when the system clock is adjusted.
+func Now() int64 {
+	return start.UnixNano() + int64(time.Since(start))
+}
+
+// Stamp sets the SentParam of the request parameters
+func Stamp(parameters map[string]interface{}) {
+	parameters[SentParam] = Now()
+}
+
+// Value returns the timestamp parameter.
+// Returns false if the parameter is not set or not a number.
+func Value(parameters map[string]interface{}, name string) (int64, bool) {
+	switch value := parameters[name].(type) {
+	case int64:
+		return value, true
+	case uint64:
+		return int64(value), true
+	case int:
+		return int64(value), true
+	case float64:
+		return int64(value), true
+	case json.Number:
+		converted, err := value.Int64()
+		return converted, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// Latency returns the one-way latency of the received request by the SentParam.
+// It includes the clock offset between the services, see Estimator.
+func Latency(parameters map[string]interface{}) (time.Duration, bool) {
+	sent, ok := Value(parameters, SentParam)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(Now() - sent), true
+}
+
+// Route wraps the route function, so its replies have the ReceivedParam and RepliedParam.
+// The replyParamsOf returns the parameters of the reply, the reply without the parameters is not stamped.
+func Route[Req any, Rep any](replyParamsOf func(Rep) map[string]interface{}, handle func(Req) Rep) func(Req) Rep {
+	return func(req Req) Rep {
+		received := Now()
+		reply := handle(req)
+		if parameters := replyParamsOf(reply); parameters != nil {
+			parameters[ReceivedParam] = received
+			parameters[RepliedParam] = Now()
+		}
+		return reply
+	}
+}
+
+// Sample of the round trip in unix nanoseconds.
+// The Sent and Done are measured by the client, the Received and Replied by the peer.
+type Sample struct {
+	Sent     int64 `json:"sent"`
+	Received int64 `json:"received"`
+	Replied  int64 `json:"replied"`
+	Done     int64 `json:"done"`
+}
+
+// NewSample returns the sample of the round trip started at sent, by the reply parameters.
+// Returns false if the reply is not stamped.
+func NewSample(sent int64, replyParameters map[string]interface{}) (Sample, bool) {
+	received, ok := Value(replyParameters, ReceivedParam)
+	if !ok {
+		return Sample{}, false
+	}
+	replied, ok := Value(replyParameters, RepliedParam)
+	if !ok {
+		return Sample{}, false
+	}
+	sample := Sample{Sent: sent, Received: received, Replied: replied, Done: Now()}
+	return sample, sample.Valid()
+}
+
+// Valid returns true if the times of the sample are in order on each side
+func (sample Sample) Valid() bool {
+	return sample.Done >= sample.Sent && sample.Replied >= sample.Received
+}
+
+// Offset returns how much the peer's clock is ahead of this clock
+func (sample Sample) Offset() time.Duration {
+	return time.Duration(((sample.Received - sample.Sent) + (sample.Replied - sample.Done)) / 2)
+}
+
+// Delay returns the round trip time without the time spent by the peer
+func (sample Sample) Delay() time.Duration {
+	return time.Duration((sample.Done - sample.Sent) - (sample.Replied - sample.Received))
+}
+
+// Estimator keeps the last samples of the peers, and estimates their clock offsets.
+// The sample with the lowest delay is the most accurate, as the network had the least asymmetry.
+type Estimator struct {
+	samples map[string][]Sample
+	size    int
+	mu      sync.Mutex
+}
+
+// NewEstimator returns the estimator keeping the last size samples per peer.
+// If the size is not positive, then DefaultSamples are kept.
+func NewEstimator(size int) *Estimator {
+	if size <= 0 {
+		size = DefaultSamples
+	}
+	return &Estimator{samples: make(map[string][]Sample), size: size}
+}
+
+// Add the sample of the peer.
+// The invalid samples are ignored.
+func (estimator *Estimator) Add(peer string, sample Sample) {
+	if !sample.Valid() {
+		return
+	}
+
+	estimator.mu.Lock()
+	defer estimator.mu.Unlock()
+
+	samples := append(estimator.samples[peer], sample)
+	if len(samples) > estimator.size {
+		samples = samples[len(samples)-estimator.size:]
+	}
+	estimator.samples[peer] = samples
+}
+
+// Offset returns the estimated clock offset of the peer.
+// Returns false if there are no samples of the peer.
+// The nil estimator has no samples.
+func (estimator *Estimator) Offset(peer string) (time.Duration, bool) {
+	if estimator == nil {
+		return 0, false
+	}
+
+	estimator.mu.Lock()
+	defer estimator.mu.Unlock()
+
+	samples := estimator.samples[peer]
+	if len(samples) == 0 {
+		return 0, false
+	}
+	best := slices.MinFunc(samples, func(a, b Sample) int {
+		return int(a.Delay() - b.Delay())
+	})
+	return best.Offset(), true
+}
+
+// Offsets returns the estimated clock offsets of all peers
+func (estimator *Estimator) Offsets() map[string]time.Duration {
+	offsets := make(map[string]time.Duration)
+	if estimator == nil {
+		return offsets
+	}
+
+	estimator.mu.Lock()
+	peers := make([]string, 0, len(estimator.samples))
+	for peer := range estimator.samples {
+		peers = append(peers, peer)
+	}
+	estimator.mu.Unlock()
+
+	for _, peer := range peers {
+		if offset, ok := estimator.Offset(peer); ok {
+			offsets[peer] = offset
+		}
+	}
+	return offsets
+}
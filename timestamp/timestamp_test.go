@@ -0,0 +1,109 @@
+package timestamp
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestTimestampSuite struct {
+	suite.Suite
+}
+
+// Test_10_Now tests the monotonic timestamps
+func (test *TestTimestampSuite) Test_10_Now() {
+	s := test.Require
+
+	first := Now()
+	second := Now()
+	s().GreaterOrEqual(second, first)
+	s().InDelta(time.Now().UnixNano(), first, float64(time.Second))
+
+	parameters := map[string]interface{}{}
+	Stamp(parameters)
+	sent, ok := Value(parameters, SentParam)
+	s().True(ok)
+	s().GreaterOrEqual(sent, second)
+
+	// the parameters decoded from json
+	parameters = map[string]interface{}{SentParam: float64(sent), ReceivedParam: json.Number("42"), RepliedParam: "now"}
+	_, ok = Value(parameters, SentParam)
+	s().True(ok)
+	received, ok := Value(parameters, ReceivedParam)
+	s().True(ok)
+	s().Equal(int64(42), received)
+	_, ok = Value(parameters, RepliedParam)
+	s().False(ok)
+
+	latency, ok := Latency(map[string]interface{}{SentParam: Now() - int64(time.Second)})
+	s().True(ok)
+	s().GreaterOrEqual(latency, time.Second)
+}
+
+// Test_11_Route tests the stamped replies
+func (test *TestTimestampSuite) Test_11_Route() {
+	s := test.Require
+
+	sent := Now()
+	route := Route(func(reply map[string]interface{}) map[string]interface{} {
+		return reply
+	}, func(req string) map[string]interface{} {
+		return map[string]interface{}{"echo": req}
+	})
+	reply := route("hello")
+	s().Equal("hello", reply["echo"])
+
+	sample, ok := NewSample(sent, reply)
+	s().True(ok)
+	s().True(sample.Valid())
+
+	_, ok = NewSample(sent, map[string]interface{}{})
+	s().False(ok)
+}
+
+// Test_12_Estimator tests the offset by the sample with the lowest delay
+func (test *TestTimestampSuite) Test_12_Estimator() {
+	s := test.Require
+
+	ms := int64(time.Millisecond)
+	// the peer is 100ms ahead
+	precise := Sample{Sent: 0, Received: 101 * ms, Replied: 102 * ms, Done: 3 * ms}
+	s().Equal(time.Millisecond*100, precise.Offset())
+	s().Equal(time.Millisecond*2, precise.Delay())
+
+	// the slow request path inflates the offset
+	slow := Sample{Sent: 0, Received: 150 * ms, Replied: 151 * ms, Done: 53 * ms}
+
+	estimator := NewEstimator(2)
+	_, ok := estimator.Offset("peer")
+	s().False(ok)
+
+	estimator.Add("peer", slow)
+	estimator.Add("peer", precise)
+	estimator.Add("peer", Sample{Sent: 10, Done: 5})
+	offset, ok := estimator.Offset("peer")
+	s().True(ok)
+	s().Equal(time.Millisecond*100, offset)
+
+	// only the last samples are kept
+	estimator.Add("peer", slow)
+	estimator.Add("peer", slow)
+	offset, _ = estimator.Offset("peer")
+	s().Equal(slow.Offset(), offset)
+	s().Len(estimator.Offsets(), 1)
+
+	var empty *Estimator
+	_, ok = empty.Offset("peer")
+	s().False(ok)
+	s().Empty(empty.Offsets())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestTimestamp(t *testing.T) {
+	suite.Run(t, new(TestTimestampSuite))
+}
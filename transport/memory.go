@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// The "mem" transport connects the sockets of the same process by the channels.
+// The endpoints are "mem://<name>", the name is any unique string.
+//
+// Use it in the unit tests instead of binding the inproc or tcp endpoints.
+func init() {
+	Register("mem", NewNet("mem", memListen, memDial))
+}
+
+var (
+	memListeners   = make(map[string]*memListener)
+	memListenersMu sync.Mutex
+)
+
+type memAddr string
+
+func (addr memAddr) Network() string {
+	return "mem"
+}
+
+func (addr memAddr) String() string {
+	return string(addr)
+}
+
+// memListener accepts the connections dialed by memDial
+type memListener struct {
+	name   string
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (listener *memListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-listener.conns:
+		return conn, nil
+	case <-listener.closed:
+		return nil, fmt.Errorf("listener closed")
+	}
+}
+
+func (listener *memListener) Close() error {
+	listener.once.Do(func() {
+		close(listener.closed)
+
+		memListenersMu.Lock()
+		delete(memListeners, listener.name)
+		memListenersMu.Unlock()
+	})
+	return nil
+}
+
+func (listener *memListener) Addr() net.Addr {
+	return memAddr(listener.name)
+}
+
+func memListen(name string) (net.Listener, error) {
+	memListenersMu.Lock()
+	defer memListenersMu.Unlock()
+
+	if _, ok := memListeners[name]; ok {
+		return nil, fmt.Errorf("'%s' is bound already", name)
+	}
+	listener := &memListener{name: name, conns: make(chan net.Conn), closed: make(chan struct{})}
+	memListeners[name] = listener
+	return listener, nil
+}
+
+// memDial connects to the listener by the in-memory pipe
+func memDial(name string) (net.Conn, error) {
+	memListenersMu.Lock()
+	listener, ok := memListeners[name]
+	memListenersMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not bound", name)
+	}
+
+	client, server := net.Pipe()
+	select {
+	case listener.conns <- server:
+		return client, nil
+	case <-listener.closed:
+		return nil, fmt.Errorf("'%s' is closed", name)
+	}
+}
@@ -0,0 +1,687 @@
+package transport
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFrames and maxMessageSize protect the receiver from the corrupted streams
+const (
+	maxFrames      = 1024
+	maxMessageSize = 64 << 20
+	inboxSize      = 1024
+	eventsSize     = 64
+	// sendHighWater is the amount of the messages queued per subscriber, like the ZeroMQ SNDHWM.
+	// The messages to the subscriber with the full queue are dropped.
+	sendHighWater = 1000
+)
+
+func init() {
+	Register("tcp", NewNet("tcp", tcpListen, tcpDial))
+}
+
+// ListenFunc and DialFunc open the connections of the net transport by the address without the scheme
+type (
+	ListenFunc = func(address string) (net.Listener, error)
+	DialFunc   = func(address string) (net.Conn, error)
+)
+
+func tcpListen(address string) (net.Listener, error) {
+	return net.Listen("tcp", address)
+}
+
+func tcpDial(address string) (net.Conn, error) {
+	return net.Dial("tcp", address)
+}
+
+// Net is the pure Go transport over the net.Conn.
+// The messages are sent by the ZMTP 3.0 wire protocol of ZeroMQ with the NULL mechanism,
+// so the "tcp" sockets are connected to the ZeroMQ sockets of the other processes that don't use CURVE.
+//
+// The Pub socket filters the messages by the subscriptions of each subscriber,
+// and queues them per subscriber, so the slow subscriber doesn't block the publisher.
+// The messages are dropped when the subscriber's queue is full.
+//
+// The received message is at most 64MB.
+// The dialed sockets don't reconnect, the socket must be created again after the peer restart.
+// The CURVE security and the subscription filter are not supported, use the ZeroMQ transport.
+type Net struct {
+	scheme string
+	listen ListenFunc
+	dial   DialFunc
+}
+
+// NewNet returns the transport over the connections of the listen and dial functions
+func NewNet(scheme string, listen ListenFunc, dial DialFunc) *Net {
+	return &Net{scheme: scheme, listen: listen, dial: dial}
+}
+
+// address returns the endpoint without the scheme
+func (t *Net) address(endpoint string) (string, error) {
+	prefix := t.scheme + "://"
+	if !strings.HasPrefix(endpoint, prefix) {
+		return "", fmt.Errorf("'%s' endpoint must start with %s", endpoint, prefix)
+	}
+	address := strings.TrimPrefix(endpoint, prefix)
+	return strings.Replace(address, "*:", ":", 1), nil
+}
+
+// The options returns an error if the socket is created with the options that the transport doesn't support.
+func (t *Net) options(kind Kind, opts []Option) error {
+	if _, ok := socketTypes[kind]; !ok {
+		return fmt.Errorf("'%s' kind is not supported", kind)
+	}
+	options := NewOptions(opts...)
+	if options.Curve != nil {
+		return fmt.Errorf("the %s transport doesn't support CURVE", t.scheme)
+	}
+	if options.Filter != nil {
+		return fmt.Errorf("the %s transport doesn't support the subscription filter", t.scheme)
+	}
+	return nil
+}
+
+// Bind the socket to the endpoint and accept the connections in the background
+func (t *Net) Bind(kind Kind, endpoint string, opts ...Option) (Socket, error) {
+	if err := t.options(kind, opts); err != nil {
+		return nil, err
+	}
+	address, err := t.address(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := t.listen(address)
+	if err != nil {
+		return nil, fmt.Errorf("listen('%s'): %w", address, err)
+	}
+
+	socket := newNetSocket(kind, t.scheme+"://"+listener.Addr().String())
+	socket.listener = listener
+	socket.setMaxSize(NewOptions(opts...).MaxMessageSize)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go socket.accept(conn)
+		}
+	}()
+
+	return socket, nil
+}
+
+// Dial the endpoint
+func (t *Net) Dial(kind Kind, endpoint string, opts ...Option) (Socket, error) {
+	if err := t.options(kind, opts); err != nil {
+		return nil, err
+	}
+	address, err := t.address(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := t.dial(address)
+	if err != nil {
+		return nil, fmt.Errorf("dial('%s'): %w", address, err)
+	}
+
+	p := newPeer(conn)
+	if err := handshake(conn, p.reader, p.writer, kind); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("handshake('%s'): %w", address, err)
+	}
+	socket := newNetSocket(kind, endpoint)
+	socket.setMaxSize(NewOptions(opts...).MaxMessageSize)
+	socket.addPeer(p)
+	return socket, nil
+}
+
+// Poll waits until any socket has a message in its inbox.
+// The sockets wake the poll when the message is delivered into the inbox.
+func (t *Net) Poll(sockets []Socket, timeout time.Duration) ([]Socket, error) {
+	netSockets := make([]*netSocket, len(sockets))
+	for i, s := range sockets {
+		socket, ok := s.(*netSocket)
+		if !ok {
+			return nil, fmt.Errorf("the socket is not created by the %s transport", t.scheme)
+		}
+		netSockets[i] = socket
+	}
+
+	// the waker is added before checking the inboxes, so no delivery is missed
+	wake := make(chan struct{}, 1)
+	for _, socket := range netSockets {
+		socket.addWaker(wake)
+	}
+	defer func() {
+		for _, socket := range netSockets {
+			socket.removeWaker(wake)
+		}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		readable := make([]Socket, 0, len(sockets))
+		for i, socket := range netSockets {
+			if socket.readable() {
+				readable = append(readable, sockets[i])
+			}
+		}
+		if len(readable) > 0 {
+			return readable, nil
+		}
+		select {
+		case <-wake:
+		case <-timer.C:
+			return readable, nil
+		}
+	}
+}
+
+type peer struct {
+	conn          net.Conn
+	reader        *bufio.Reader
+	writer        *bufio.Writer
+	queue         chan [][]byte // the messages to the subscriber, only for the peers of the Pub socket
+	subscriptions []string      // the topics of the subscriber, only for the peers of the Pub socket
+	done          chan struct{}
+	once          sync.Once
+	mu            sync.Mutex // serializes the writes
+	subMu         sync.Mutex // guards the subscriptions, so the write to the slow subscriber doesn't block the publisher
+}
+
+func newPeer(conn net.Conn) *peer {
+	return &peer{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+		done:   make(chan struct{}),
+	}
+}
+
+// write the frames of the message
+func (p *peer) write(frames [][]byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, frame := range frames {
+		flags := byte(0)
+		if i < len(frames)-1 {
+			flags = flagMore
+		}
+		if err := writeFrame(p.writer, flags, frame); err != nil {
+			return err
+		}
+	}
+	return p.writer.Flush()
+}
+
+// close the connection once
+func (p *peer) close() {
+	p.once.Do(func() {
+		close(p.done)
+		_ = p.conn.Close()
+	})
+}
+
+// subscribe or unsubscribe the subscriber by the ZMTP 3.0 subscription message.
+// Returns false if the message is not a subscription.
+func (p *peer) subscribe(frames [][]byte) bool {
+	if len(frames) != 1 || len(frames[0]) == 0 || frames[0][0] > 1 {
+		return false
+	}
+	topic := string(frames[0][1:])
+
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	if frames[0][0] == 1 {
+		p.subscriptions = append(p.subscriptions, topic)
+		return true
+	}
+	for i, subscription := range p.subscriptions {
+		if subscription == topic {
+			p.subscriptions = append(p.subscriptions[:i], p.subscriptions[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// subscribed returns true if the subscriber's topics match the message
+func (p *peer) subscribed(frames [][]byte) bool {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	return matches(p.subscriptions, frames)
+}
+
+// matches returns true if the first frame of the message starts with any topic
+func matches(topics []string, frames [][]byte) bool {
+	topic := ""
+	if len(frames) > 0 {
+		topic = string(frames[0])
+	}
+	for _, subscription := range topics {
+		if strings.HasPrefix(topic, subscription) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscription returns the ZMTP 3.0 subscription message of the topic
+func subscription(topic string) [][]byte {
+	return [][]byte{append([]byte{1}, topic...)}
+}
+
+type received struct {
+	frames   [][]byte
+	from     *peer
+	envelope [][]byte // the routing frames of the request, the reply is sent with them
+}
+
+type netSocket struct {
+	kind          Kind
+	endpoint      string
+	listener      net.Listener
+	peers         []*peer
+	next          int      // the peer of the next request
+	replyTo       *peer    // the peer of the last request
+	envelope      [][]byte // the routing frames of the last request
+	subscriptions []string
+	inbox         chan received
+	closed        chan struct{}
+	wakers        map[chan struct{}]struct{} // the polls waiting for the inbox
+	monitors      []*netSocket               // the Event sockets
+	maxSize       int                        // of the received messages
+	mu            sync.Mutex
+}
+
+func newNetSocket(kind Kind, endpoint string) *netSocket {
+	size := inboxSize
+	if kind == Event {
+		size = eventsSize
+	}
+	return &netSocket{
+		kind:     kind,
+		endpoint: endpoint,
+		peers:    make([]*peer, 0, 1),
+		inbox:    make(chan received, size),
+		closed:   make(chan struct{}),
+		wakers:   make(map[chan struct{}]struct{}),
+		maxSize:  maxMessageSize,
+	}
+}
+
+// setMaxSize sets the limit of the received messages.
+// Zero keeps the default, the limit above the default is lowered to it.
+func (socket *netSocket) setMaxSize(size int) {
+	if size > 0 && size < maxMessageSize {
+		socket.maxSize = size
+	}
+}
+
+func (socket *netSocket) addWaker(wake chan struct{}) {
+	socket.mu.Lock()
+	socket.wakers[wake] = struct{}{}
+	socket.mu.Unlock()
+}
+
+func (socket *netSocket) removeWaker(wake chan struct{}) {
+	socket.mu.Lock()
+	delete(socket.wakers, wake)
+	socket.mu.Unlock()
+}
+
+// wake the polls waiting for this socket
+func (socket *netSocket) wake() {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+
+	for wake := range socket.wakers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// deliver the message into the inbox.
+// Returns false if the socket is closed.
+func (socket *netSocket) deliver(msg received) bool {
+	select {
+	case socket.inbox <- msg:
+	case <-socket.closed:
+		return false
+	}
+	socket.wake()
+	return true
+}
+
+// Monitor returns the Event socket of this socket.
+// The peers connected already are reported first.
+func (socket *netSocket) Monitor() (Socket, error) {
+	if socket.kind == Event {
+		return nil, fmt.Errorf("%s socket can not be monitored", socket.kind)
+	}
+	events := newNetSocket(Event, socket.endpoint)
+
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+
+	select {
+	case <-socket.closed:
+		return nil, fmt.Errorf("socket closed")
+	default:
+	}
+	for _, p := range socket.peers {
+		if len(events.inbox) == cap(events.inbox) {
+			break
+		}
+		events.inbox <- received{frames: socket.event(p)}
+	}
+	socket.monitors = append(socket.monitors, events)
+	return events, nil
+}
+
+// peerAddress returns the remote address of the accepted peer, or the endpoint of the dialed peer
+func (socket *netSocket) peerAddress(p *peer) []byte {
+	if socket.listener != nil {
+		return []byte(p.conn.RemoteAddr().String())
+	}
+	return []byte(socket.endpoint)
+}
+
+// event returns the frames of the peer connection event
+func (socket *netSocket) event(p *peer) [][]byte {
+	if socket.listener != nil {
+		return [][]byte{[]byte(Accepted), socket.peerAddress(p)}
+	}
+	return [][]byte{[]byte(Connected), socket.peerAddress(p)}
+}
+
+// emit the event to the monitors, the socket must be locked.
+// The event is dropped if the monitor is not read, the connections are never blocked by the monitors.
+func (socket *netSocket) emit(frames [][]byte) {
+	monitors := socket.monitors[:0]
+	for _, events := range socket.monitors {
+		select {
+		case <-events.closed:
+			continue
+		default:
+		}
+		monitors = append(monitors, events)
+		select {
+		case events.inbox <- received{frames: frames}:
+			events.wake()
+		default:
+		}
+	}
+	socket.monitors = monitors
+}
+
+func (socket *netSocket) Kind() Kind {
+	return socket.kind
+}
+
+func (socket *netSocket) Endpoint() string {
+	return socket.endpoint
+}
+
+// accept the connection of the bound socket after the handshake
+func (socket *netSocket) accept(conn net.Conn) {
+	p := newPeer(conn)
+	if err := handshake(conn, p.reader, p.writer, socket.kind); err != nil {
+		_ = conn.Close()
+		socket.mu.Lock()
+		socket.emit([][]byte{[]byte(Failed), []byte(conn.RemoteAddr().String())})
+		socket.mu.Unlock()
+		return
+	}
+	socket.addPeer(p)
+}
+
+// addPeer starts reading the messages of the connection into the inbox.
+// The subscriptions are sent to the publisher, and the subscriber gets its queue.
+func (socket *netSocket) addPeer(p *peer) {
+	socket.mu.Lock()
+	select {
+	case <-socket.closed:
+		socket.mu.Unlock()
+		p.close()
+		return
+	default:
+	}
+	socket.peers = append(socket.peers, p)
+	socket.emit(socket.event(p))
+	subscriptions := append([]string{}, socket.subscriptions...)
+	socket.mu.Unlock()
+
+	switch socket.kind {
+	case Pub:
+		p.queue = make(chan [][]byte, sendHighWater)
+		go socket.send(p)
+	case Sub:
+		for _, topic := range subscriptions {
+			if err := p.write(subscription(topic)); err != nil {
+				socket.removePeer(p)
+				return
+			}
+		}
+	}
+
+	go func() {
+		for {
+			frames, err := readMessage(p.reader, socket.maxSize)
+			if err != nil {
+				socket.removePeer(p)
+				return
+			}
+			msg, ok := socket.unwrap(p, frames)
+			if !ok {
+				continue
+			}
+			if !socket.deliver(msg) {
+				return
+			}
+		}
+	}()
+}
+
+// unwrap returns the message to deliver without the routing frames.
+// Returns false if the message is not delivered, like the subscriptions or the invalid requests.
+func (socket *netSocket) unwrap(p *peer, frames [][]byte) (received, bool) {
+	switch socket.kind {
+	case Pub:
+		p.subscribe(frames)
+		return received{}, false
+	case Sub:
+		return received{frames: frames, from: p}, socket.subscribed(frames)
+	case Req:
+		// the reply starts with the empty delimiter
+		if len(frames) < 2 || len(frames[0]) != 0 {
+			return received{}, false
+		}
+		return received{frames: frames[1:], from: p}, true
+	case Rep:
+		// the routing frames end with the empty delimiter
+		for i, frame := range frames {
+			if len(frame) == 0 {
+				return received{frames: frames[i+1:], from: p, envelope: frames[:i+1]}, i+1 < len(frames)
+			}
+		}
+		return received{}, false
+	}
+	return received{}, false
+}
+
+// send the queued messages to the subscriber until it's removed
+func (socket *netSocket) send(p *peer) {
+	for {
+		select {
+		case frames := <-p.queue:
+			if err := p.write(frames); err != nil {
+				socket.removePeer(p)
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (socket *netSocket) removePeer(p *peer) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+
+	for i := range socket.peers {
+		if socket.peers[i] == p {
+			socket.peers = append(socket.peers[:i], socket.peers[i+1:]...)
+			socket.emit([][]byte{[]byte(Disconnected), socket.peerAddress(p)})
+			break
+		}
+	}
+	p.close()
+}
+
+func (socket *netSocket) readable() bool {
+	return len(socket.inbox) > 0
+}
+
+// Send the message.
+// The Req socket sends it to the peers in turn, the Rep socket replies to the last request.
+// The Pub socket queues it for the subscribers of its topic, and never blocks.
+func (socket *netSocket) Send(frames [][]byte) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to send")
+	}
+
+	socket.mu.Lock()
+	var target *peer
+	switch socket.kind {
+	case Req:
+		if len(socket.peers) > 0 {
+			socket.next = (socket.next + 1) % len(socket.peers)
+			target = socket.peers[socket.next]
+			frames = append([][]byte{{}}, frames...)
+		}
+	case Rep:
+		if socket.replyTo != nil {
+			target = socket.replyTo
+			frames = append(append([][]byte{}, socket.envelope...), frames...)
+			socket.replyTo = nil
+			socket.envelope = nil
+		}
+	case Pub:
+		peers := append([]*peer{}, socket.peers...)
+		socket.mu.Unlock()
+		socket.publish(peers, frames)
+		return nil
+	default:
+		socket.mu.Unlock()
+		return fmt.Errorf("%s socket can not send", socket.kind)
+	}
+	socket.mu.Unlock()
+
+	if target == nil {
+		return fmt.Errorf("no peer to send")
+	}
+	if err := target.write(frames); err != nil {
+		socket.removePeer(target)
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+// publish queues the message for the subscribers of its topic.
+// The frames are copied, as they are sent after Send returns.
+func (socket *netSocket) publish(peers []*peer, frames [][]byte) {
+	var copied [][]byte
+	for _, p := range peers {
+		if !p.subscribed(frames) {
+			continue
+		}
+		if copied == nil {
+			copied = make([][]byte, len(frames))
+			for i, frame := range frames {
+				copied[i] = append([]byte{}, frame...)
+			}
+		}
+		select {
+		case p.queue <- copied:
+		default:
+			// the slow subscriber misses the message, like the ZeroMQ publisher at the high water mark
+		}
+	}
+}
+
+// subscribed returns true if the message matches any subscription
+func (socket *netSocket) subscribed(frames [][]byte) bool {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+
+	return matches(socket.subscriptions, frames)
+}
+
+func (socket *netSocket) Recv() ([][]byte, error) {
+	if socket.kind == Pub {
+		return nil, fmt.Errorf("%s socket can not receive", socket.kind)
+	}
+	select {
+	case <-socket.closed:
+		return nil, fmt.Errorf("socket closed")
+	case msg := <-socket.inbox:
+		if socket.kind == Rep {
+			socket.mu.Lock()
+			socket.replyTo = msg.from
+			socket.envelope = msg.envelope
+			socket.mu.Unlock()
+		}
+		return msg.frames, nil
+	}
+}
+
+func (socket *netSocket) Subscribe(topic string) error {
+	if socket.kind != Sub {
+		return fmt.Errorf("%s socket can not subscribe", socket.kind)
+	}
+	socket.mu.Lock()
+	socket.subscriptions = append(socket.subscriptions, topic)
+	peers := append([]*peer{}, socket.peers...)
+	socket.mu.Unlock()
+
+	// the publishers send only the subscribed topics
+	for _, p := range peers {
+		if err := p.write(subscription(topic)); err != nil {
+			socket.removePeer(p)
+		}
+	}
+	return nil
+}
+
+func (socket *netSocket) Close() error {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+
+	select {
+	case <-socket.closed:
+		return fmt.Errorf("socket closed already")
+	default:
+	}
+	close(socket.closed)
+
+	if socket.listener != nil {
+		_ = socket.listener.Close()
+	}
+	for _, p := range socket.peers {
+		p.close()
+	}
+	socket.peers = nil
+	return nil
+}
@@ -0,0 +1,180 @@
+// Package transport abstracts the sockets, so the library is not bound to ZeroMQ.
+//
+// The transports are registered by name, like the database/sql drivers.
+// The pure Go "tcp" and the in-memory "mem" transports are always registered.
+// The ZeroMQ transport is registered by importing the transport/zmq package, it requires cgo.
+//
+//	import _ "github.com/ahmetson/service-lib/transport/zmq"
+//
+//	t, _ := transport.Get("zmq")
+//	socket, _ := t.Dial(transport.Req, "tcp://localhost:4000")
+//
+// The "tcp" transport speaks the ZMTP 3.0 wire protocol of ZeroMQ with the NULL mechanism,
+// so it's connected to the ZeroMQ sockets without CURVE, see Net.
+// The CURVE sockets must use the "zmq" transport on both sides.
+//
+// The packages built on the transport, like reactor, publisher, subscriber and monitor, build without cgo.
+// The handlers and the clients of github.com/ahmetson/handler-lib and github.com/ahmetson/client-lib
+// still use github.com/pebbe/zmq4, so the service package itself requires cgo until they move to the transport.
+package transport
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind of the socket
+type Kind string
+
+const (
+	Req Kind = "req" // sends the request, then receives the reply
+	Rep Kind = "rep" // receives the request, then sends the reply
+	Pub Kind = "pub" // sends the message to all subscribers
+	Sub Kind = "sub" // receives the messages of the subscribed topics
+	// Event receives the connection events of the monitored socket, see Monitorable
+	Event Kind = "event"
+)
+
+// The connection events received by the Event socket as [event, address] frames
+const (
+	Connected    = "connected"
+	Disconnected = "disconnected"
+	Retried      = "retried"
+	Accepted     = "accepted"
+	Failed       = "failed" // bind, accept or close failures
+)
+
+// Monitorable socket reports its connection events.
+type Monitorable interface {
+	// Monitor returns the Event socket receiving the connection events of this socket.
+	// The Event socket is polled by the same transport, the caller must close it.
+	Monitor() (Socket, error)
+}
+
+// Curve are the z85 encoded keys of the CURVE security mechanism
+type Curve struct {
+	ServerKey string // the public key of the bound socket, set by the dialing socket only
+	PublicKey string
+	SecretKey string
+}
+
+// SubscriptionFilter returns true if the identity may subscribe to the topic.
+// The identity is the z85 encoded CURVE public key of the subscriber.
+type SubscriptionFilter = func(identity string, topic string) bool
+
+// Options of the socket set by the Option functions on Dial or Bind
+type Options struct {
+	Curve          *Curve
+	Filter         SubscriptionFilter
+	MaxMessageSize int // in bytes of all frames, zero is the default of the transport
+}
+
+// Option of the socket
+type Option func(options *Options)
+
+// WithCurve secures the socket by the CURVE keys.
+// The dialing socket sets the server key, the bound socket authenticates any client with the keys.
+func WithCurve(curve Curve) Option {
+	return func(options *Options) {
+		options.Curve = &curve
+	}
+}
+
+// WithSubscriptionFilter authorizes the subscriptions on the bound Pub socket secured by WithCurve.
+// The publisher doesn't send the topics to the subscriber that the filter denied.
+func WithSubscriptionFilter(filter SubscriptionFilter) Option {
+	return func(options *Options) {
+		options.Filter = filter
+	}
+}
+
+// WithMaxMessageSize disconnects the peers that send the messages larger than the size.
+// The message is rejected by its size before it's read, so the receiver never parses it.
+func WithMaxMessageSize(size int) Option {
+	return func(options *Options) {
+		options.MaxMessageSize = size
+	}
+}
+
+// NewOptions returns the options set by the functions
+func NewOptions(opts ...Option) Options {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// Socket sends and receives the multipart messages
+type Socket interface {
+	Kind() Kind
+	// Endpoint returns the bound or dialed endpoint.
+	// If the socket was bound to the port 0, then the endpoint has the chosen port.
+	Endpoint() string
+	Send(frames [][]byte) error
+	Recv() ([][]byte, error)
+	// Subscribe to the messages which first frame starts with the topic.
+	// The empty topic subscribes to all messages. Only for Sub sockets.
+	Subscribe(topic string) error
+	Close() error
+}
+
+// Transport creates the sockets
+type Transport interface {
+	Dial(kind Kind, endpoint string, opts ...Option) (Socket, error)
+	Bind(kind Kind, endpoint string, opts ...Option) (Socket, error)
+	// Poll returns the sockets that have a message to receive.
+	// It waits until any socket is readable or the timeout passes.
+	// The sockets must be created by this transport.
+	Poll(sockets []Socket, timeout time.Duration) ([]Socket, error)
+}
+
+var (
+	transports   = make(map[string]Transport)
+	transportsMu sync.RWMutex
+)
+
+// Register the transport by the name.
+// If the name is registered already, then it's replaced.
+func Register(name string, t Transport) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	transports[name] = t
+}
+
+// Get the transport by the name
+func Get(name string) (Transport, error) {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+
+	t, ok := transports[name]
+	if !ok {
+		return nil, fmt.Errorf("'%s' transport not registered", name)
+	}
+	return t, nil
+}
+
+// Names returns the registered transports
+func Names() []string {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+
+	names := make([]string, 0, len(transports))
+	for name := range transports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Default returns the ZeroMQ transport if it's registered, otherwise the pure Go tcp transport.
+func Default() Transport {
+	if t, err := Get("zmq"); err == nil {
+		return t
+	}
+	t, _ := Get("tcp")
+	return t
+}
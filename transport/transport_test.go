@@ -0,0 +1,349 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestTransportSuite struct {
+	suite.Suite
+	transport Transport
+}
+
+func (test *TestTransportSuite) SetupTest() {
+	t, err := Get("tcp")
+	test.Require().NoError(err)
+	test.transport = t
+}
+
+// Test_10_ReqRep tests the request and reply over the tcp transport
+func (test *TestTransportSuite) Test_10_ReqRep() {
+	s := test.Require
+
+	rep, err := test.transport.Bind(Rep, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = rep.Close() }()
+
+	req, err := test.transport.Dial(Req, rep.Endpoint())
+	s().NoError(err)
+	defer func() { _ = req.Close() }()
+
+	readable, err := test.transport.Poll([]Socket{rep}, time.Millisecond*10)
+	s().NoError(err)
+	s().Empty(readable)
+
+	s().NoError(req.Send([][]byte{[]byte("hello"), []byte("world")}))
+
+	readable, err = test.transport.Poll([]Socket{rep}, time.Second)
+	s().NoError(err)
+	s().Len(readable, 1)
+
+	frames, err := rep.Recv()
+	s().NoError(err)
+	s().Equal([][]byte{[]byte("hello"), []byte("world")}, frames)
+
+	s().NoError(rep.Send([][]byte{[]byte("ok")}))
+	frames, err = req.Recv()
+	s().NoError(err)
+	s().Equal([][]byte{[]byte("ok")}, frames)
+
+	// no request to reply
+	s().Error(rep.Send([][]byte{[]byte("ok")}))
+}
+
+// Test_11_PubSub tests the topic filtering
+func (test *TestTransportSuite) Test_11_PubSub() {
+	s := test.Require
+
+	pub, err := test.transport.Bind(Pub, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = pub.Close() }()
+
+	sub, err := test.transport.Dial(Sub, pub.Endpoint())
+	s().NoError(err)
+	defer func() { _ = sub.Close() }()
+	s().NoError(sub.Subscribe("logs"))
+	s().Error(pub.Subscribe("logs"))
+
+	// wait until the subscriber is accepted
+	time.Sleep(time.Millisecond * 50)
+
+	s().NoError(pub.Send([][]byte{[]byte("blocks"), []byte("1")}))
+	s().NoError(pub.Send([][]byte{[]byte("logs"), []byte("2")}))
+
+	frames, err := sub.Recv()
+	s().NoError(err)
+	s().Equal("2", string(frames[1]))
+
+	_, err = pub.Recv()
+	s().Error(err)
+}
+
+// Test_12_Memory tests the in-memory transport
+func (test *TestTransportSuite) Test_12_Memory() {
+	s := test.Require
+
+	t, err := Get("mem")
+	s().NoError(err)
+
+	rep, err := t.Bind(Rep, "mem://manager")
+	s().NoError(err)
+	s().Equal("mem://manager", rep.Endpoint())
+
+	_, err = t.Bind(Rep, "mem://manager")
+	s().Error(err)
+	_, err = t.Dial(Req, "mem://unknown")
+	s().Error(err)
+
+	req, err := t.Dial(Req, "mem://manager")
+	s().NoError(err)
+
+	s().NoError(req.Send([][]byte{[]byte("heartbeat")}))
+	frames, err := rep.Recv()
+	s().NoError(err)
+	s().Equal("heartbeat", string(frames[0]))
+	s().NoError(rep.Send([][]byte{[]byte("ok")}))
+	frames, err = req.Recv()
+	s().NoError(err)
+	s().Equal("ok", string(frames[0]))
+
+	s().NoError(req.Close())
+	s().NoError(rep.Close())
+
+	// the name is released
+	rep, err = t.Bind(Rep, "mem://manager")
+	s().NoError(err)
+	s().NoError(rep.Close())
+}
+
+// Test_13_PollWakeup tests that the poll returns as soon as the message is delivered
+func (test *TestTransportSuite) Test_13_PollWakeup() {
+	s := test.Require
+
+	rep, err := test.transport.Bind(Rep, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = rep.Close() }()
+	req, err := test.transport.Dial(Req, rep.Endpoint())
+	s().NoError(err)
+	defer func() { _ = req.Close() }()
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_ = req.Send([][]byte{[]byte("hello")})
+	}()
+
+	start := time.Now()
+	readable, err := test.transport.Poll([]Socket{rep}, time.Second*5)
+	s().NoError(err)
+	s().Len(readable, 1)
+	s().Less(time.Since(start), time.Second)
+
+	// the options not supported by the transport
+	_, err = test.transport.Bind(Pub, "tcp://127.0.0.1:0", WithCurve(Curve{}))
+	s().Error(err)
+	_, err = test.transport.Bind(Pub, "tcp://127.0.0.1:0", WithSubscriptionFilter(func(string, string) bool { return true }))
+	s().Error(err)
+}
+
+// Test_14_Monitor tests the connection events
+func (test *TestTransportSuite) Test_14_Monitor() {
+	s := test.Require
+
+	rep, err := test.transport.Bind(Rep, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = rep.Close() }()
+
+	monitorable, ok := rep.(Monitorable)
+	s().True(ok)
+	events, err := monitorable.Monitor()
+	s().NoError(err)
+	defer func() { _ = events.Close() }()
+	s().Equal(Event, events.Kind())
+
+	req, err := test.transport.Dial(Req, rep.Endpoint())
+	s().NoError(err)
+
+	readable, err := test.transport.Poll([]Socket{events}, time.Second)
+	s().NoError(err)
+	s().Len(readable, 1)
+	frames, err := events.Recv()
+	s().NoError(err)
+	s().Equal(Accepted, string(frames[0]))
+
+	s().NoError(req.Close())
+	frames, err = events.Recv()
+	s().NoError(err)
+	s().Equal(Disconnected, string(frames[0]))
+
+	// the dialed socket reports the connected peer that was connected before monitoring
+	req, err = test.transport.Dial(Req, rep.Endpoint())
+	s().NoError(err)
+	defer func() { _ = req.Close() }()
+	reqEvents, err := req.(Monitorable).Monitor()
+	s().NoError(err)
+	defer func() { _ = reqEvents.Close() }()
+	frames, err = reqEvents.Recv()
+	s().NoError(err)
+	s().Equal([][]byte{[]byte(Connected), []byte(rep.Endpoint())}, frames)
+
+	_, err = events.(Monitorable).Monitor()
+	s().Error(err)
+}
+
+// rawPeer connects to the socket by the tcp connection speaking ZMTP 3.0 as the socket type.
+// The bytes are written as in the specification, without the transport's helpers.
+func (test *TestTransportSuite) rawPeer(endpoint string, socketType string) net.Conn {
+	s := test.Require
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(endpoint, "tcp://"))
+	s().NoError(err)
+	s().NoError(conn.SetDeadline(time.Now().Add(time.Second * 5)))
+
+	greeting := append([]byte{0xFF, 0, 0, 0, 0, 0, 0, 0, 0, 0x7F, 3, 0}, []byte("NULL")...)
+	greeting = append(greeting, make([]byte, 64-len(greeting))...)
+	ready := append([]byte{5}, "READY"...)
+	ready = append(ready, 11)
+	ready = append(ready, "Socket-Type"...)
+	ready = append(ready, 0, 0, 0, byte(len(socketType)))
+	ready = append(ready, socketType...)
+	_, err = conn.Write(append(append(greeting, 0x04, byte(len(ready))), ready...))
+	s().NoError(err)
+
+	peerGreeting := make([]byte, 64)
+	_, err = io.ReadFull(conn, peerGreeting)
+	s().NoError(err)
+	s().Equal(byte(0xFF), peerGreeting[0])
+	s().Equal(byte(0x7F), peerGreeting[9])
+	s().Equal("NULL", string(bytes.TrimRight(peerGreeting[12:32], "\x00")))
+
+	header := make([]byte, 2)
+	_, err = io.ReadFull(conn, header)
+	s().NoError(err)
+	s().Equal(byte(0x04), header[0])
+	peerReady := make([]byte, header[1])
+	_, err = io.ReadFull(conn, peerReady)
+	s().NoError(err)
+	s().Equal("READY", string(peerReady[1:6]))
+	return conn
+}
+
+// Test_15_Wire tests the ZMTP 3.0 messages of the tcp transport
+func (test *TestTransportSuite) Test_15_Wire() {
+	s := test.Require
+
+	rep, err := test.transport.Bind(Rep, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = rep.Close() }()
+
+	// the REQ peer sends the empty delimiter, then the request
+	conn := test.rawPeer(rep.Endpoint(), "REQ")
+	defer func() { _ = conn.Close() }()
+	_, err = conn.Write([]byte{0x01, 0, 0x00, 5, 'h', 'e', 'l', 'l', 'o'})
+	s().NoError(err)
+
+	frames, err := rep.Recv()
+	s().NoError(err)
+	s().Equal([][]byte{[]byte("hello")}, frames)
+	s().NoError(rep.Send([][]byte{[]byte("ok")}))
+
+	reply := make([]byte, 6)
+	_, err = io.ReadFull(conn, reply)
+	s().NoError(err)
+	s().Equal([]byte{0x01, 0, 0x00, 2, 'o', 'k'}, reply)
+
+	// the incompatible socket type is refused
+	_, err = test.transport.Dial(Pub, rep.Endpoint())
+	s().Error(err)
+	_, err = test.transport.Dial(Event, rep.Endpoint())
+	s().Error(err)
+}
+
+// Test_16_Limits tests dropping the peer announcing the frame larger than the limit
+func (test *TestTransportSuite) Test_16_Limits() {
+	s := test.Require
+
+	rep, err := test.transport.Bind(Rep, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = rep.Close() }()
+
+	// the long frame of 1TB, the size is never allocated
+	conn := test.rawPeer(rep.Endpoint(), "REQ")
+	defer func() { _ = conn.Close() }()
+	_, err = conn.Write([]byte{0x02, 0, 0, 1, 0, 0, 0, 0, 0})
+	s().NoError(err)
+
+	_, err = conn.Read(make([]byte, 1))
+	s().Error(err)
+
+	// the frame is read as it arrives
+	var buf bytes.Buffer
+	buf.Write([]byte{0x02, 0, 0, 0, 0, 0, 0, 0x10, 0})
+	buf.WriteString("short")
+	_, _, err = readFrame(bufio.NewReader(&buf), maxMessageSize)
+	s().ErrorIs(err, io.EOF)
+
+	// the message above the limit of the socket drops the peer before it's read
+	limited, err := test.transport.Bind(Rep, "tcp://127.0.0.1:0", WithMaxMessageSize(16))
+	s().NoError(err)
+	defer func() { _ = limited.Close() }()
+
+	limitedConn := test.rawPeer(limited.Endpoint(), "REQ")
+	defer func() { _ = limitedConn.Close() }()
+	_, err = limitedConn.Write(append([]byte{0x01, 0, 0x00, 32}, bytes.Repeat([]byte("a"), 32)...))
+	s().NoError(err)
+
+	_, err = limitedConn.Read(make([]byte, 1))
+	s().Error(err)
+}
+
+// Test_17_SlowSubscriber tests that the subscriber not reading the messages doesn't block the publisher
+func (test *TestTransportSuite) Test_17_SlowSubscriber() {
+	s := test.Require
+
+	pub, err := test.transport.Bind(Pub, "tcp://127.0.0.1:0")
+	s().NoError(err)
+	defer func() { _ = pub.Close() }()
+
+	// the subscriber to all topics never reads
+	conn := test.rawPeer(pub.Endpoint(), "SUB")
+	defer func() { _ = conn.Close() }()
+	_, err = conn.Write([]byte{0x00, 1, 0x01})
+	s().NoError(err)
+
+	sub, err := test.transport.Dial(Sub, pub.Endpoint())
+	s().NoError(err)
+	defer func() { _ = sub.Close() }()
+	s().NoError(sub.Subscribe("logs"))
+	time.Sleep(time.Millisecond * 50)
+
+	payload := bytes.Repeat([]byte("x"), 64<<10)
+	start := time.Now()
+	for i := 0; i < sendHighWater*4; i++ {
+		s().NoError(pub.Send([][]byte{[]byte("blocks"), payload}))
+	}
+	s().Less(time.Since(start), time.Second*5)
+
+	// the other subscriber keeps receiving
+	s().NoError(pub.Send([][]byte{[]byte("logs"), []byte("1")}))
+	readable, err := test.transport.Poll([]Socket{sub}, time.Second*5)
+	s().NoError(err)
+	s().Len(readable, 1)
+	frames, err := sub.Recv()
+	s().NoError(err)
+	s().Equal("logs", string(frames[0]))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestTransport(t *testing.T) {
+	suite.Run(t, new(TestTransportSuite))
+}
@@ -0,0 +1,293 @@
+// Package zmq registers the ZeroMQ transport.
+// Import it for the side effect:
+//
+//	import _ "github.com/ahmetson/service-lib/transport/zmq"
+package zmq
+
+import (
+	"fmt"
+	"github.com/ahmetson/service-lib/transport"
+	zmq "github.com/pebbe/zmq4"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+func init() {
+	transport.Register("zmq", &Transport{})
+}
+
+// socketTypes maps the transport kinds to the ZeroMQ socket types
+var socketTypes = map[transport.Kind]zmq.Type{
+	transport.Req: zmq.REQ,
+	transport.Rep: zmq.REP,
+	transport.Pub: zmq.PUB,
+	transport.Sub: zmq.SUB,
+}
+
+// eventNames maps the ZeroMQ socket events to the transport events.
+// The other events are received by the ZeroMQ name.
+var eventNames = map[zmq.Event]string{
+	zmq.EVENT_CONNECTED:       transport.Connected,
+	zmq.EVENT_DISCONNECTED:    transport.Disconnected,
+	zmq.EVENT_CONNECT_RETRIED: transport.Retried,
+	zmq.EVENT_ACCEPTED:        transport.Accepted,
+	zmq.EVENT_BIND_FAILED:     transport.Failed,
+	zmq.EVENT_ACCEPT_FAILED:   transport.Failed,
+	zmq.EVENT_CLOSE_FAILED:    transport.Failed,
+}
+
+var (
+	authOnce sync.Once
+	authErr  error
+	monitors uint64 // the counter of the monitor endpoints
+)
+
+// startAuth starts the ZAP handler once per process.
+// The authenticated client's public key is set as the User-Id of the received messages.
+func startAuth() error {
+	authOnce.Do(func() {
+		if err := zmq.AuthStart(); err != nil {
+			authErr = fmt.Errorf("zmq.AuthStart: %w", err)
+			return
+		}
+		zmq.AuthSetMetadataHandler(func(version, requestId, domain, address, identity, mechanism string, credentials ...string) map[string]string {
+			if mechanism != "CURVE" || len(credentials) == 0 {
+				return map[string]string{}
+			}
+			return map[string]string{"User-Id": zmq.Z85encode(credentials[0])}
+		})
+	})
+	return authErr
+}
+
+// Transport over the pebbe/zmq4
+type Transport struct{}
+
+// Socket is the ZeroMQ socket
+type Socket struct {
+	*zmq.Socket
+	kind     transport.Kind
+	endpoint string
+	filter   transport.SubscriptionFilter // the Pub socket with the filter is XPUB
+}
+
+func newSocket(kind transport.Kind, options transport.Options) (*Socket, error) {
+	socketType, ok := socketTypes[kind]
+	if !ok {
+		return nil, fmt.Errorf("'%s' kind is not supported", kind)
+	}
+	if options.Filter != nil {
+		if kind != transport.Pub {
+			return nil, fmt.Errorf("%s socket can not filter the subscriptions", kind)
+		}
+		socketType = zmq.XPUB
+	}
+	socket, err := zmq.NewSocket(socketType)
+	if err != nil {
+		return nil, fmt.Errorf("zmq.NewSocket: %w", err)
+	}
+	if err := socket.SetLinger(0); err != nil {
+		_ = socket.Close()
+		return nil, fmt.Errorf("socket.SetLinger: %w", err)
+	}
+	if options.MaxMessageSize > 0 {
+		if err := socket.SetMaxmsgsize(int64(options.MaxMessageSize)); err != nil {
+			_ = socket.Close()
+			return nil, fmt.Errorf("socket.SetMaxmsgsize: %w", err)
+		}
+	}
+	if options.Filter != nil {
+		if err := socket.SetXpubManual(1); err != nil {
+			_ = socket.Close()
+			return nil, fmt.Errorf("socket.SetXpubManual: %w", err)
+		}
+	}
+	return &Socket{Socket: socket, kind: kind, filter: options.Filter}, nil
+}
+
+// Dial connects the socket to the endpoint.
+// The CURVE option requires the server key.
+func (t *Transport) Dial(kind transport.Kind, endpoint string, opts ...transport.Option) (transport.Socket, error) {
+	options := transport.NewOptions(opts...)
+	if options.Filter != nil {
+		return nil, fmt.Errorf("the dialed socket can not filter the subscriptions")
+	}
+	socket, err := newSocket(kind, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Curve != nil {
+		curve := options.Curve
+		if err := socket.ClientAuthCurve(curve.ServerKey, curve.PublicKey, curve.SecretKey); err != nil {
+			_ = socket.Socket.Close()
+			return nil, fmt.Errorf("socket.ClientAuthCurve: %w", err)
+		}
+	}
+	if err := socket.Connect(endpoint); err != nil {
+		_ = socket.Socket.Close()
+		return nil, fmt.Errorf("socket.Connect('%s'): %w", endpoint, err)
+	}
+	socket.endpoint = endpoint
+	return socket, nil
+}
+
+// Bind the socket to the endpoint.
+// The CURVE option accepts any client that has the keys, the endpoint is the ZAP domain.
+// The subscription filter requires CURVE, it authorizes the subscriber by its public key.
+func (t *Transport) Bind(kind transport.Kind, endpoint string, opts ...transport.Option) (transport.Socket, error) {
+	options := transport.NewOptions(opts...)
+	if options.Filter != nil && options.Curve == nil {
+		return nil, fmt.Errorf("the subscription filter requires CURVE to identify the subscribers")
+	}
+	socket, err := newSocket(kind, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Curve != nil {
+		if err := startAuth(); err != nil {
+			_ = socket.Socket.Close()
+			return nil, err
+		}
+		zmq.AuthCurveAdd(endpoint, zmq.CURVE_ALLOW_ANY)
+		if err := socket.ServerAuthCurve(endpoint, options.Curve.SecretKey); err != nil {
+			_ = socket.Socket.Close()
+			return nil, fmt.Errorf("socket.ServerAuthCurve('%s'): %w", endpoint, err)
+		}
+	}
+	if err := socket.Socket.Bind(endpoint); err != nil {
+		_ = socket.Socket.Close()
+		return nil, fmt.Errorf("socket.Bind('%s'): %w", endpoint, err)
+	}
+	socket.endpoint, err = socket.GetLastEndpoint()
+	if err != nil {
+		socket.endpoint = endpoint
+	}
+	return socket, nil
+}
+
+// Poll the sockets by the zmq.Poller
+func (t *Transport) Poll(sockets []transport.Socket, timeout time.Duration) ([]transport.Socket, error) {
+	poller := zmq.NewPoller()
+	bySocket := make(map[*zmq.Socket]transport.Socket, len(sockets))
+	for _, s := range sockets {
+		socket, ok := s.(*Socket)
+		if !ok {
+			return nil, fmt.Errorf("the socket is not created by the zmq transport")
+		}
+		poller.Add(socket.Socket, zmq.POLLIN)
+		bySocket[socket.Socket] = s
+	}
+
+	polled, err := poller.Poll(timeout)
+	if err != nil {
+		return nil, fmt.Errorf("poller.Poll: %w", err)
+	}
+	readable := make([]transport.Socket, 0, len(polled))
+	for _, p := range polled {
+		readable = append(readable, bySocket[p.Socket])
+	}
+	return readable, nil
+}
+
+func (socket *Socket) Kind() transport.Kind {
+	return socket.kind
+}
+
+func (socket *Socket) Endpoint() string {
+	return socket.endpoint
+}
+
+// Monitor returns the Event socket receiving the events of this socket by the inproc PAIR
+func (socket *Socket) Monitor() (transport.Socket, error) {
+	if socket.kind == transport.Event {
+		return nil, fmt.Errorf("%s socket can not be monitored", socket.kind)
+	}
+	endpoint := fmt.Sprintf("inproc://transport.monitor.%d", atomic.AddUint64(&monitors, 1))
+	if err := socket.Socket.Monitor(endpoint, zmq.EVENT_ALL); err != nil {
+		return nil, fmt.Errorf("socket.Monitor('%s'): %w", endpoint, err)
+	}
+	pair, err := zmq.NewSocket(zmq.PAIR)
+	if err != nil {
+		return nil, fmt.Errorf("zmq.NewSocket(PAIR): %w", err)
+	}
+	if err := pair.Connect(endpoint); err != nil {
+		_ = pair.Close()
+		return nil, fmt.Errorf("pair.Connect('%s'): %w", endpoint, err)
+	}
+	return &Socket{Socket: pair, kind: transport.Event, endpoint: socket.endpoint}, nil
+}
+
+// The subscribe applies the pending subscriptions of the XPUB socket allowed by the filter
+func (socket *Socket) subscribe() error {
+	for {
+		frames, metadata, err := socket.RecvMessageWithMetadata(zmq.DONTWAIT, "User-Id")
+		if err != nil {
+			if zmq.AsErrno(err) == zmq.Errno(syscall.EAGAIN) {
+				return nil
+			}
+			return fmt.Errorf("socket.RecvMessageWithMetadata: %w", err)
+		}
+		if len(frames) == 0 || len(frames[0]) == 0 {
+			continue
+		}
+		subscribe, topic := frames[0][0] == 1, frames[0][1:]
+		if !subscribe {
+			err = socket.SetUnsubscribe(topic)
+		} else if socket.filter(metadata["User-Id"], topic) {
+			err = socket.SetSubscribe(topic)
+		}
+		if err != nil {
+			return fmt.Errorf("socket.SetSubscribe('%s'): %w", topic, err)
+		}
+	}
+}
+
+func (socket *Socket) Send(frames [][]byte) error {
+	if socket.kind == transport.Event {
+		return fmt.Errorf("%s socket can not send", socket.kind)
+	}
+	if socket.filter != nil {
+		if err := socket.subscribe(); err != nil {
+			return fmt.Errorf("socket.subscribe: %w", err)
+		}
+	}
+	if _, err := socket.SendMessage(frames); err != nil {
+		return fmt.Errorf("socket.SendMessage: %w", err)
+	}
+	return nil
+}
+
+func (socket *Socket) Recv() ([][]byte, error) {
+	if socket.kind == transport.Event {
+		event, address, _, err := socket.RecvEvent(0)
+		if err != nil {
+			return nil, fmt.Errorf("socket.RecvEvent: %w", err)
+		}
+		name, ok := eventNames[event]
+		if !ok {
+			name = event.String()
+		}
+		return [][]byte{[]byte(name), []byte(address)}, nil
+	}
+	if socket.filter != nil {
+		return nil, fmt.Errorf("%s socket can not receive", socket.kind)
+	}
+	frames, err := socket.RecvMessageBytes(0)
+	if err != nil {
+		return nil, fmt.Errorf("socket.RecvMessageBytes: %w", err)
+	}
+	return frames, nil
+}
+
+func (socket *Socket) Subscribe(topic string) error {
+	if socket.kind != transport.Sub {
+		return fmt.Errorf("%s socket can not subscribe", socket.kind)
+	}
+	return socket.SetSubscribe(topic)
+}
+
+func (socket *Socket) Close() error {
+	return socket.Socket.Close()
+}
@@ -0,0 +1,232 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// The ZMTP 3.0 wire protocol of ZeroMQ, see https://rfc.zeromq.org/spec/23/.
+// Only the NULL security mechanism is supported.
+const (
+	flagMore    byte = 0x01
+	flagLong    byte = 0x02
+	flagCommand byte = 0x04
+
+	greetingSize   = 64
+	nullMechanism  = "NULL"
+	readyCommand   = "READY"
+	socketTypeProp = "Socket-Type"
+	maxCommandSize = 64 << 10
+
+	handshakeTimeout = time.Second * 10
+)
+
+// socketTypes are the ZeroMQ socket types of the kinds
+var socketTypes = map[Kind]string{
+	Req: "REQ",
+	Rep: "REP",
+	Pub: "PUB",
+	Sub: "SUB",
+}
+
+// peerTypes are the socket types the kinds can be connected to
+var peerTypes = map[Kind]string{
+	Req: "REP",
+	Rep: "REQ",
+	Pub: "SUB",
+	Sub: "PUB",
+}
+
+// greeting returns the greeting of the NULL mechanism
+func greeting() []byte {
+	g := make([]byte, greetingSize)
+	g[0] = 0xFF
+	g[9] = 0x7F
+	g[10] = 3 // major version
+	copy(g[12:32], nullMechanism)
+	return g
+}
+
+// checkGreeting returns an error if the peer is not the ZMTP 3 peer with the NULL mechanism
+func checkGreeting(g []byte) error {
+	if g[0] != 0xFF || g[9] != 0x7F {
+		return fmt.Errorf("not a ZMTP greeting")
+	}
+	if g[10] < 3 {
+		return fmt.Errorf("ZMTP %d.%d is not supported", g[10], g[11])
+	}
+	mechanism := string(bytes.TrimRight(g[12:32], "\x00"))
+	if mechanism != nullMechanism {
+		return fmt.Errorf("the %s mechanism is not supported", mechanism)
+	}
+	return nil
+}
+
+// ready returns the body of the READY command announcing the socket type
+func ready(socketType string) []byte {
+	body := make([]byte, 0, 1+len(readyCommand)+1+len(socketTypeProp)+4+len(socketType))
+	body = append(body, byte(len(readyCommand)))
+	body = append(body, readyCommand...)
+	body = append(body, byte(len(socketTypeProp)))
+	body = append(body, socketTypeProp...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(socketType)))
+	return append(body, socketType...)
+}
+
+// parseReady returns the socket type announced by the READY command
+func parseReady(body []byte) (string, error) {
+	if len(body) < 1 || len(body) < 1+int(body[0]) || string(body[1:1+body[0]]) != readyCommand {
+		return "", fmt.Errorf("not a READY command")
+	}
+	rest := body[1+body[0]:]
+	for len(rest) > 0 {
+		nameSize := int(rest[0])
+		if len(rest) < 1+nameSize+4 {
+			return "", fmt.Errorf("truncated property")
+		}
+		name := string(rest[1 : 1+nameSize])
+		valueSize := binary.BigEndian.Uint32(rest[1+nameSize:])
+		rest = rest[1+nameSize+4:]
+		if uint64(len(rest)) < uint64(valueSize) {
+			return "", fmt.Errorf("truncated '%s' property", name)
+		}
+		if name == socketTypeProp {
+			return string(rest[:valueSize]), nil
+		}
+		rest = rest[valueSize:]
+	}
+	return "", fmt.Errorf("no %s property", socketTypeProp)
+}
+
+// writeFrame writes the frame with the short or the long size
+func writeFrame(writer *bufio.Writer, flags byte, body []byte) error {
+	var header [9]byte
+	size := 2
+	header[0] = flags
+	if len(body) > 255 {
+		header[0] |= flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+		size = 9
+	} else {
+		header[1] = byte(len(body))
+	}
+	if _, err := writer.Write(header[:size]); err != nil {
+		return err
+	}
+	_, err := writer.Write(body)
+	return err
+}
+
+// readFrame reads the frame that is at most limit bytes.
+// The body is read incrementally, so the size announced by the peer is never allocated upfront.
+func readFrame(reader *bufio.Reader, limit int) (byte, []byte, error) {
+	flags, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		var header [8]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(header[:])
+	} else {
+		short, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(short)
+	}
+	if size > uint64(limit) {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the limit", size)
+	}
+
+	var body bytes.Buffer
+	if _, err := io.CopyN(&body, reader, int64(size)); err != nil {
+		return 0, nil, err
+	}
+	return flags, body.Bytes(), nil
+}
+
+// readMessage reads the frames of the next message that is at most limit bytes.
+// The commands between the messages, like the heartbeats, are skipped.
+func readMessage(reader *bufio.Reader, limit int) ([][]byte, error) {
+	frames := make([][]byte, 0, 2)
+	total := 0
+	for {
+		flags, body, err := readFrame(reader, limit-total)
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			if len(frames) > 0 {
+				return nil, fmt.Errorf("command inside the message")
+			}
+			continue
+		}
+		if len(frames) == maxFrames {
+			return nil, fmt.Errorf("more than %d frames", maxFrames)
+		}
+		frames = append(frames, body)
+		total += len(body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// handshake exchanges the greetings and the READY commands with the peer.
+// Returns an error if the peer's socket type can't be connected to the kind.
+// The greeting is sent while the peer's greeting is read, as the in-memory connections are not buffered.
+func handshake(conn net.Conn, reader *bufio.Reader, writer *bufio.Writer, kind Kind) error {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	written := make(chan error, 1)
+	go func() {
+		if _, err := writer.Write(greeting()); err != nil {
+			written <- err
+			return
+		}
+		if err := writeFrame(writer, flagCommand, ready(socketTypes[kind])); err != nil {
+			written <- err
+			return
+		}
+		written <- writer.Flush()
+	}()
+
+	g := make([]byte, greetingSize)
+	if _, err := io.ReadFull(reader, g); err != nil {
+		return fmt.Errorf("read greeting: %w", err)
+	}
+	if err := checkGreeting(g); err != nil {
+		return err
+	}
+	flags, body, err := readFrame(reader, maxCommandSize)
+	if err != nil {
+		return fmt.Errorf("read READY: %w", err)
+	}
+	if flags&flagCommand == 0 {
+		return fmt.Errorf("expected the READY command")
+	}
+	socketType, err := parseReady(body)
+	if err != nil {
+		return fmt.Errorf("parseReady: %w", err)
+	}
+	if socketType != peerTypes[kind] {
+		return fmt.Errorf("%s socket can not be connected to %s", socketTypes[kind], socketType)
+	}
+
+	if err := <-written; err != nil {
+		return fmt.Errorf("write greeting: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"github.com/ahmetson/handler-lib/base"
+	"github.com/ahmetson/service-lib/tag"
+	"slices"
+)
+
+// unitMatcher selects the units of the service's handlers by the rule.
+// The route, handler and service rules share the same semantics:
+//
+//   - The excluded commands are never matched. The exclusion takes precedence over the listed commands.
+//   - If the rule has categories, only the handlers of those categories are matched.
+//     The rule category may reference a tag, then the handlers of the tagged categories are matched, see tag.Ref.
+//     The rule without categories matches all handlers.
+//   - If the rule has commands, only the listed commands that the handler routes are matched.
+//     The rule without commands matches all routed commands of the handler.
+type unitMatcher struct {
+	rule *serviceConfig.Rule
+	tags *tag.Registry
+}
+
+// newUnitMatcher returns the matcher of the rule.
+// The tags resolve the tag references in the rule categories, they are optional.
+func newUnitMatcher(rule *serviceConfig.Rule, tags *tag.Registry) *unitMatcher {
+	return &unitMatcher{rule: rule, tags: tags}
+}
+
+// matchCategory returns true if the handler of the category is selected by the rule
+func (m *unitMatcher) matchCategory(category string) bool {
+	return len(m.rule.Categories) == 0 || m.tags.MatchAny(m.rule.Categories, category)
+}
+
+// matchCommand returns true if the command is selected by the rule
+func (m *unitMatcher) matchCommand(command string) bool {
+	if slices.Contains(m.rule.ExcludedCommands, command) {
+		return false
+	}
+	return len(m.rule.Commands) == 0 || slices.Contains(m.rule.Commands, command)
+}
+
+// match returns the commands of the handler selected by the rule.
+// The routed commands are the commands that the handler of the category has.
+func (m *unitMatcher) match(category string, routed []string) []string {
+	if !m.matchCategory(category) {
+		return nil
+	}
+
+	commands := make([]string, 0, len(routed))
+	for _, command := range routed {
+		if m.matchCommand(command) {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// unitsByRule returns the list of units for the route, handler or service rule.
+// See unitMatcher for the matching semantics. The internal handlers are skipped.
+func (independent *Service) unitsByRule(rule *serviceConfig.Rule) []*serviceConfig.Unit {
+	matcher := newUnitMatcher(rule, independent.tags)
+	units := make([]*serviceConfig.Unit, 0, len(independent.Handlers))
+
+	for key, raw := range independent.Handlers {
+		// the internal handlers are never published as the units
+		if independent.isInternal(key) {
+			continue
+		}
+		handlerInterface := raw.(base.Interface)
+		hConfig := handlerInterface.Config()
+
+		for _, command := range matcher.match(hConfig.Category, handlerInterface.RouteCommands()) {
+			unit := &serviceConfig.Unit{
+				ServiceId: independent.id,
+				HandlerId: hConfig.Id,
+				Command:   command,
+			}
+
+			units = append(units, unit)
+		}
+	}
+
+	return units
+}
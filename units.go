@@ -0,0 +1,229 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	serviceConfig "github.com/ahmetson/config-lib/service"
+	"sync"
+)
+
+// unitsSetter sets the units of the rule in the proxy handler.
+// It's the proxy client of the context, the fakes are used in the tests.
+type unitsSetter interface {
+	SetUnits(rule *serviceConfig.Rule, units []*serviceConfig.Unit) error
+}
+
+// setUnitsByRule sets the units of all rules, the units[i] are of the rules[i].
+// The proxy handler sets the units per rule, so each rule is one request over the same socket.
+//
+// Stops at the first failed rule, and returns the number of the rules set before it.
+func setUnitsByRule(setter unitsSetter, rules []*serviceConfig.Rule, units [][]*serviceConfig.Unit) (int, error) {
+	if len(rules) != len(units) {
+		return 0, fmt.Errorf("%d rules have %d unit lists", len(rules), len(units))
+	}
+	for i, rule := range rules {
+		if err := setter.SetUnits(rule, units[i]); err != nil {
+			return i, fmt.Errorf("setter.SetUnits(rule='%v'): %w", rule, err)
+		}
+	}
+	return len(rules), nil
+}
+
+// heldRule is the rule with its units held by the proxy handler
+type heldRule struct {
+	rule  *serviceConfig.Rule
+	units []*serviceConfig.Unit
+}
+
+// patchUnits updates the held units of the rule by the difference.
+// The removed units are withdrawn, and the added ones are appended after the kept units,
+// so the kept units stay in their order and keep routing during the update.
+// Returns the units held by the proxy handler after the patch.
+func patchUnits(setter unitsSetter, rule *serviceConfig.Rule, held []*serviceConfig.Unit, added []*serviceConfig.Unit, removed []*serviceConfig.Unit) ([]*serviceConfig.Unit, error) {
+	removedKeys := make(map[string]bool, len(removed))
+	for _, unit := range removed {
+		removedKeys[unitKey(unit)] = true
+	}
+	patched := make([]*serviceConfig.Unit, 0, len(held)+len(added)-len(removed))
+	for _, unit := range held {
+		if !removedKeys[unitKey(unit)] {
+			patched = append(patched, unit)
+		}
+	}
+	patched = append(patched, added...)
+
+	if err := setter.SetUnits(rule, patched); err != nil {
+		return nil, fmt.Errorf("setter.SetUnits(rule='%v'): %w", rule, err)
+	}
+	return patched, nil
+}
+
+// unitKey identifies the unit
+func unitKey(unit *serviceConfig.Unit) string {
+	return unit.ServiceId + "/" + unit.HandlerId + "/" + unit.Command
+}
+
+// ruleKey identifies the rule by its fields
+func ruleKey(rule *serviceConfig.Rule) string {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Sprintf("%v", *rule)
+	}
+	return string(data)
+}
+
+// diffUnits returns the units added to the held ones, and the held units that were removed
+func diffUnits(held []*serviceConfig.Unit, units []*serviceConfig.Unit) ([]*serviceConfig.Unit, []*serviceConfig.Unit) {
+	heldKeys := make(map[string]bool, len(held))
+	for _, unit := range held {
+		heldKeys[unitKey(unit)] = true
+	}
+	keys := make(map[string]bool, len(units))
+	added := make([]*serviceConfig.Unit, 0)
+	for _, unit := range units {
+		keys[unitKey(unit)] = true
+		if !heldKeys[unitKey(unit)] {
+			added = append(added, unit)
+		}
+	}
+	removed := make([]*serviceConfig.Unit, 0)
+	for _, unit := range held {
+		if !keys[unitKey(unit)] {
+			removed = append(removed, unit)
+		}
+	}
+	return added, removed
+}
+
+// The heldUnits returns the units of the rule the proxy handler holds from this service
+func (independent *Service) heldUnits(rule *serviceConfig.Rule) ([]*serviceConfig.Unit, bool) {
+	independent.heldMu.Lock()
+	defer independent.heldMu.Unlock()
+
+	held, ok := independent.held[ruleKey(rule)]
+	return held.units, ok
+}
+
+// The holdUnits remembers the units of the rule sent to the proxy handler
+func (independent *Service) holdUnits(rule *serviceConfig.Rule, units []*serviceConfig.Unit) {
+	independent.heldMu.Lock()
+	defer independent.heldMu.Unlock()
+
+	if independent.held == nil {
+		independent.held = make(map[string]heldRule)
+	}
+	independent.held[ruleKey(rule)] = heldRule{rule: rule, units: units}
+}
+
+// The dropUnits forgets the units of the rule withdrawn from the proxy handler
+func (independent *Service) dropUnits(rule *serviceConfig.Rule) {
+	independent.heldMu.Lock()
+	defer independent.heldMu.Unlock()
+
+	delete(independent.held, ruleKey(rule))
+}
+
+// The staleRules returns the held rules that are not in the rules
+func (independent *Service) staleRules(rules []*serviceConfig.Rule) []*serviceConfig.Rule {
+	keys := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		keys[ruleKey(rule)] = true
+	}
+
+	independent.heldMu.Lock()
+	defer independent.heldMu.Unlock()
+
+	stale := make([]*serviceConfig.Rule, 0)
+	for key, held := range independent.held {
+		if !keys[key] {
+			stale = append(stale, held.rule)
+		}
+	}
+	return stale
+}
+
+// The withdrawStaleUnits removes the units of the rules that disappeared from the proxy chains.
+// The rules are all rules of the proxy chains, the units of the rest held rules are withdrawn.
+func (independent *Service) withdrawStaleUnits(rules []*serviceConfig.Rule) error {
+	proxyClient := independent.ctx.ProxyClient()
+	for _, rule := range independent.staleRules(rules) {
+		if err := proxyClient.SetUnits(rule, []*serviceConfig.Unit{}); err != nil {
+			return fmt.Errorf("proxyClient.SetUnits(rule='%v', withdrawn): %w", rule, err)
+		}
+		independent.dropUnits(rule)
+	}
+	return nil
+}
+
+// The forgetUnits forgets the units held by the proxy handler, so the next update sends them in full.
+// Call it when the proxy handler or the config engine restarted.
+func (independent *Service) forgetUnits() {
+	independent.heldMu.Lock()
+	defer independent.heldMu.Unlock()
+
+	independent.held = nil
+}
+
+// publishable returns true if the rule's units are published by this service
+func publishable(rule *serviceConfig.Rule) bool {
+	return rule.IsRoute() || rule.IsHandler() || rule.IsService()
+}
+
+// The resolveAllUnits returns the serving units of each rule.
+// The rules are matched in parallel, as the services with many chains have many rules.
+func (independent *Service) resolveAllUnits(rules []*serviceConfig.Rule) [][]*serviceConfig.Unit {
+	units := make([][]*serviceConfig.Unit, len(rules))
+
+	var wg sync.WaitGroup
+	wg.Add(len(rules))
+	for i, rule := range rules {
+		go func(i int, rule *serviceConfig.Rule) {
+			defer wg.Done()
+			units[i] = independent.servingUnits(independent.unitsByRule(rule))
+		}(i, rule)
+	}
+	wg.Wait()
+
+	return units
+}
+
+// The setAllUnits sends the units of all rules to the proxy handler.
+//
+// The rules already held by the proxy handler are updated by the difference only, see patchUnits,
+// so the unchanged units keep routing during the update. The unchanged rules are not sent at all.
+// The rest are set in full, see setUnitsByRule.
+// If setting fails, the rules set before the failure are held, so the retry sends the rest only.
+func (independent *Service) setAllUnits(rules []*serviceConfig.Rule, units [][]*serviceConfig.Unit) error {
+	proxyClient := independent.ctx.ProxyClient()
+
+	fullRules := make([]*serviceConfig.Rule, 0, len(rules))
+	fullUnits := make([][]*serviceConfig.Unit, 0, len(rules))
+	for i, rule := range rules {
+		if held, ok := independent.heldUnits(rule); ok {
+			added, removed := diffUnits(held, units[i])
+			if len(added) == 0 && len(removed) == 0 {
+				continue
+			}
+			patched, err := patchUnits(proxyClient, rule, held, added, removed)
+			if err != nil {
+				return fmt.Errorf("patchUnits: %w", err)
+			}
+			independent.holdUnits(rule, patched)
+			continue
+		}
+		fullRules = append(fullRules, rule)
+		fullUnits = append(fullUnits, units[i])
+	}
+	if len(fullRules) == 0 {
+		return nil
+	}
+
+	set, err := setUnitsByRule(proxyClient, fullRules, fullUnits)
+	for i := 0; i < set; i++ {
+		independent.holdUnits(fullRules[i], fullUnits[i])
+	}
+	if err != nil {
+		return fmt.Errorf("setUnitsByRule: %w", err)
+	}
+	return nil
+}
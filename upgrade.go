@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	clientConfig "github.com/ahmetson/client-lib/config"
+	"github.com/ahmetson/service-lib/manager"
+	"github.com/ahmetson/service-lib/seal"
+	"github.com/ahmetson/service-lib/snapshot"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The upgrade in place, started by flag.UpgradeFlag:
+//
+//  1. The new instance takes the snapshot of the running one, and restores its state directories.
+//  2. The new instance binds the new ports for its handlers and manager, then starts the handlers.
+//  3. When the handlers serve, the new instance sends the handoff. The old instance stops updating the proxy units.
+//  4. The new instance publishes its proxy units, so the proxies switch to it.
+//  5. The old instance finishes the requests within the drain period, and closes keeping the proxies.
+
+// The prepareUpgrade keeps the manager of the running instance to hand off,
+// and restores the state directories from its snapshot, see SetStateDir.
+func (independent *Service) prepareUpgrade(old *clientConfig.Client) error {
+	independent.upgradeFrom = old
+	if len(independent.stateDirs) == 0 {
+		return nil
+	}
+
+	managerClient, err := manager.NewClient(old)
+	if err != nil {
+		return fmt.Errorf("manager.NewClient: %w", err)
+	}
+	defer func() {
+		_ = managerClient.Socket.Close()
+	}()
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("%s-upgrade-%d.tar.gz", independent.id, time.Now().UnixNano()))
+	if err := managerClient.Snapshot(path); err != nil {
+		return fmt.Errorf("managerClient.Snapshot: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(path)
+	}()
+
+	tarball, err := seal.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("seal.ReadFile('%s'): %w", path, err)
+	}
+	if _, err := snapshot.Read(bytes.NewReader(tarball), independent.stateDirs, nil); err != nil {
+		return fmt.Errorf("snapshot.Read: %w", err)
+	}
+
+	independent.Logger.Info("upgrading the running service", "id", independent.id, "state", len(independent.stateDirs))
+	return nil
+}
+
+// The resolveManagerPort replaces the manager port bound by the old instance during the upgrade.
+// The new port is saved in the service configuration.
+func (independent *Service) resolveManagerPort() error {
+	serviceConf, err := independent.ctx.Config().Service(independent.id)
+	if err != nil {
+		return fmt.Errorf("ctx.Config().Service('%s'): %w", independent.id, err)
+	}
+	if serviceConf.Manager == nil || portFree(serviceConf.Manager.Port) {
+		return nil
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return fmt.Errorf("freePort: %w", err)
+	}
+	serviceConf.Manager.Port = port
+	if err := independent.ctx.Config().SetService(serviceConf); err != nil {
+		return fmt.Errorf("ctx.Config().SetService: %w", err)
+	}
+	return nil
+}
+
+// The requestHandoff tells the old instance that the handlers of this instance serve.
+// The old instance stops updating the proxy units, so this instance publishes its own.
+func (independent *Service) requestHandoff() error {
+	managerClient, err := manager.NewClient(independent.upgradeFrom)
+	if err != nil {
+		return fmt.Errorf("manager.NewClient: %w", err)
+	}
+	defer func() {
+		_ = managerClient.Socket.Close()
+	}()
+
+	if err := managerClient.Handoff(); err != nil {
+		return fmt.Errorf("managerClient.Handoff: %w", err)
+	}
+	return nil
+}
+
+// The waitHandoff waits until the old instance drains and closes
+func (independent *Service) waitHandoff() error {
+	deadline := time.Now().Add(manager.DrainPeriod + DuplicateTimeout)
+	for running(independent.upgradeFrom) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the old instance of the '%s' service is not closed", independent.id)
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	independent.Logger.Info("upgraded the service", "id", independent.id)
+	independent.upgradeFrom = nil
+	return nil
+}
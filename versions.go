@@ -0,0 +1,47 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ahmetson/datatype-lib/message"
+	"github.com/ahmetson/service-lib/deprecation"
+	"time"
+)
+
+// RouteVersion adds the route with the version and deprecation metadata into the handlers of the category.
+// The metadata is returned by the manager.Describe command.
+//
+// The requests to the deprecated route are logged as the warnings.
+// After the sunset date, the route replies with the deprecation.SunsetError.
+func (independent *Service) RouteVersion(category string, info deprecation.Info, handle func(message.RequestInterface) message.ReplyInterface) error {
+	handlers := independent.HandlersByCategory(category)
+	if len(handlers) == 0 {
+		return fmt.Errorf("the '%s' handler is not set", category)
+	}
+
+	info.Category = category
+	if err := independent.routes.Set(info); err != nil {
+		return fmt.Errorf("routes.Set: %w", err)
+	}
+
+	versioned := deprecation.Route(independent.routes, category, func(req message.RequestInterface) string {
+		return req.CommandName()
+	}, func(req message.RequestInterface, warning string) {
+		independent.Logger.Warn(warning, "category", category)
+	}, func(req message.RequestInterface, err error) message.ReplyInterface {
+		return req.Fail(err.Error())
+	}, handle)
+
+	for _, handler := range handlers {
+		if err := handler.Route(info.Command, versioned); err != nil {
+			return fmt.Errorf("handler('%s').Route('%s'): %w", category, info.Command, err)
+		}
+	}
+
+	return nil
+}
+
+// SetSunset deprecates the route of the handler, and rejects it after the sunset date.
+// The route doesn't have to be added by RouteVersion.
+func (independent *Service) SetSunset(category, command string, sunset time.Time) {
+	independent.routes.SetSunset(category, command, sunset)
+}
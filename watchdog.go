@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// WatchdogInterval is how often the orchestra components are checked
+const WatchdogInterval = time.Second * 2
+
+// The orchestra components watched by the watchdog.
+// Set their restart policy by SetRestartPolicy to limit the restarts.
+const (
+	ConfigComponent       = "config"
+	DepManagerComponent   = "dep-manager"
+	ProxyHandlerComponent = "proxy-handler"
+)
+
+// component of the orchestra run by the context
+type component struct {
+	name    string
+	running func() bool
+	start   func() error
+}
+
+// The components returns the orchestra components in the order they are checked
+func (independent *Service) components() []component {
+	return []component{
+		{name: ConfigComponent, running: independent.ctx.IsConfigRunning, start: independent.ctx.StartConfig},
+		{name: DepManagerComponent, running: independent.ctx.IsDepManagerRunning, start: independent.ctx.StartDepManager},
+		{name: ProxyHandlerComponent, running: independent.ctx.IsProxyHandlerRunning, start: independent.ctx.StartProxyHandler},
+	}
+}
+
+// setDown marks the orchestra component as crashed, or recovered if the err is nil
+func (independent *Service) setDown(name string, err error) {
+	independent.downMu.Lock()
+	defer independent.downMu.Unlock()
+
+	if err == nil {
+		delete(independent.down, name)
+		return
+	}
+	if independent.down == nil {
+		independent.down = make(map[string]error)
+	}
+	independent.down[name] = err
+}
+
+// Down returns the crashed orchestra components with their errors.
+// While the config engine or the proxy handler is down, the proxy units are not updated.
+func (independent *Service) Down() map[string]string {
+	independent.downMu.Lock()
+	defer independent.downMu.Unlock()
+
+	down := make(map[string]string, len(independent.down))
+	for name, err := range independent.down {
+		down[name] = err.Error()
+	}
+	return down
+}
+
+// The proxyUpdatesPaused returns an error if the proxy units can not be updated,
+// because the config engine or the proxy handler is down.
+// The draining service doesn't update them either, as the upgraded instance may have replaced them.
+func (independent *Service) proxyUpdatesPaused() error {
+	if independent.manager != nil && independent.manager.Draining() {
+		return fmt.Errorf("the proxy updates are paused, the service is draining")
+	}
+
+	independent.downMu.Lock()
+	defer independent.downMu.Unlock()
+
+	for _, name := range []string{ConfigComponent, ProxyHandlerComponent} {
+		if err, ok := independent.down[name]; ok {
+			return fmt.Errorf("the proxy updates are paused, the '%s' is down: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// The restartComponent starts the crashed component, following its restart policy.
+// Returns true if the component runs again.
+func (independent *Service) restartComponent(c component, now time.Time) bool {
+	tracker := independent.tracker(c.name)
+	if tracker != nil {
+		if !tracker.Due(now) {
+			return false
+		}
+		tracker.Restarted(now)
+	}
+
+	independent.Logger.Warn("restarting the orchestra component", "component", c.name)
+	if err := c.start(); err != nil {
+		independent.setDown(c.name, fmt.Errorf("start: %w", err))
+		return false
+	}
+	if tracker != nil {
+		tracker.Reset()
+	}
+	// the restarted proxy handler holds no units
+	if c.name == ProxyHandlerComponent {
+		independent.forgetUnits()
+	}
+	return true
+}
+
+// The watchdog checks the orchestra components of the context, and restarts the crashed ones.
+// While the component is down, the service degrades gracefully: the handlers keep serving,
+// only the proxy updates are paused. The proxy units are published again when the component recovers.
+// It stops when the manager is closed.
+func (independent *Service) watchdog() {
+	for {
+		time.Sleep(WatchdogInterval)
+
+		if independent.manager == nil || !independent.manager.Running() {
+			return
+		}
+
+		now := time.Now()
+		recovered := false
+		for _, c := range independent.components() {
+			if c.running() {
+				continue
+			}
+			if _, down := independent.Down()[c.name]; !down {
+				independent.Logger.Error("orchestra component is down", "component", c.name)
+				independent.setDown(c.name, fmt.Errorf("not running"))
+			}
+			if !independent.restartComponent(c, now) {
+				continue
+			}
+			independent.setDown(c.name, nil)
+			independent.Logger.Info("orchestra component recovered", "component", c.name)
+			recovered = true
+		}
+
+		if recovered {
+			if err := independent.setProxyUnits(); err != nil {
+				independent.Logger.Warn("setProxyUnits", "error", err)
+			}
+		}
+	}
+}
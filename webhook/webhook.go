@@ -0,0 +1,285 @@
+// Package webhook posts the service broadcasts to the external urls.
+//
+// The notifier receives the broadcasts from the subscriber:
+//
+//	sub, _ := subscriber.New(publisherUrl, "categorized")
+//	notifier := webhook.New(webhook.Target{Url: "https://example.com/hook", Secret: "secret"})
+//	sub.Start()
+//	notifier.Start(sub.Broadcasts())
+//
+// Each broadcast is posted as JSON, signed by HMAC-SHA256 of the target's secret.
+// The receiver verifies the SignatureHeader by Verify.
+//
+// The targets are posted concurrently, each within its own timeout.
+// The started notifier keeps a queue per target, so a slow target doesn't delay the others.
+// When the queue of the target is full, its broadcasts are dropped and reported by Errors.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/ahmetson/service-lib/errs"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Signature" // "sha256=" followed by the hex encoded HMAC of the body
+	TopicHeader     = "X-Topic"
+	Retries         = 3
+	Backoff         = time.Millisecond * 500 // doubled after each failed attempt
+	Timeout         = time.Second * 10       // of each attempt, if the target has no timeout
+	QueueSize       = 64                     // the broadcasts waiting to be posted to the target
+)
+
+// Target of the webhooks
+type Target struct {
+	Url     string        `json:"url" yaml:"url"`
+	Secret  string        `json:"secret,omitempty" yaml:"secret,omitempty"`   // signs the body, optional
+	Topics  []string      `json:"topics,omitempty" yaml:"topics,omitempty"`   // the topics to post, all topics if empty
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"` // of each attempt, Timeout if zero
+}
+
+// timeout returns the timeout of the attempt
+func (target Target) timeout() time.Duration {
+	if target.Timeout > 0 {
+		return target.Timeout
+	}
+	return Timeout
+}
+
+// accepts returns true if the topic must be posted to the target
+func (target Target) accepts(topic string) bool {
+	return len(target.Topics) == 0 || slices.Contains(target.Topics, topic)
+}
+
+// Sign returns the signature of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true if the signature matches the body
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Notifier posts the broadcasts to the targets
+type Notifier struct {
+	targets []Target
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	errors  chan error
+	stop    chan struct{}
+	running bool
+	mu      sync.Mutex
+}
+
+// New notifier for the targets
+func New(targets ...Target) *Notifier {
+	return &Notifier{
+		targets: targets,
+		client:  &http.Client{},
+		retries: Retries,
+		backoff: Backoff,
+		errors:  make(chan error, 16),
+	}
+}
+
+// SetRetries sets the amount of the attempts after the first failure, and the initial pause between them.
+func (notifier *Notifier) SetRetries(retries int, backoff time.Duration) {
+	notifier.retries = retries
+	notifier.backoff = backoff
+}
+
+// Errors returns the broadcasts that were not delivered after all retries.
+// If the channel is full, the errors are dropped.
+func (notifier *Notifier) Errors() <-chan error {
+	return notifier.errors
+}
+
+// post sends the body to the target with retries
+func (notifier *Notifier) post(target Target, topic string, body []byte) error {
+	var err error
+	backoff := notifier.backoff
+
+	for attempt := 0; attempt <= notifier.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var status int
+		status, err = notifier.attempt(target, topic, body)
+		if err != nil {
+			continue
+		}
+
+		// the client errors are not retried, as the same request fails again.
+		if status >= 200 && status < 300 {
+			return nil
+		}
+		err = fmt.Errorf("'%s' replied with %d status", target.Url, status)
+		if status >= 400 && status < 500 {
+			return err
+		}
+	}
+
+	return err
+}
+
+// attempt posts the body once within the timeout of the target, and returns the status of the reply
+func (notifier *Notifier) attempt(target Target, topic string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), target.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("http.NewRequest('%s'): %w", target.Url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TopicHeader, topic)
+	if len(target.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(target.Secret, body))
+	}
+
+	resp, err := notifier.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("client.Do('%s'): %w", target.Url, err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// report sends the error to Errors, or drops it if the channel is full
+func (notifier *Notifier) report(err error) {
+	select {
+	case notifier.errors <- err:
+	default:
+	}
+}
+
+// delivery is the broadcast queued for the target
+type delivery struct {
+	sequenced broadcast.Sequenced
+	body      []byte
+}
+
+// Notify posts the broadcast to all targets that accept its topic concurrently.
+// Returns the errors of all failed targets.
+func (notifier *Notifier) Notify(sequenced broadcast.Sequenced) error {
+	body, err := json.Marshal(sequenced)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	notifyErrs := make([]error, len(notifier.targets))
+	var wg sync.WaitGroup
+	for i, target := range notifier.targets {
+		if !target.accepts(sequenced.Topic) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			notifyErrs[i] = notifier.post(target, sequenced.Topic, body)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return errs.Join(notifyErrs...)
+}
+
+// Start posting the broadcasts in the background.
+// Each target is posted from its own queue, in the order of the broadcasts.
+func (notifier *Notifier) Start(broadcasts <-chan broadcast.Sequenced) error {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	if notifier.running {
+		return fmt.Errorf("already running")
+	}
+	notifier.running = true
+	notifier.stop = make(chan struct{})
+
+	queues := make([]chan delivery, len(notifier.targets))
+	for i, target := range notifier.targets {
+		queues[i] = make(chan delivery, QueueSize)
+		go notifier.deliver(target, queues[i], notifier.stop)
+	}
+
+	go func(stop chan struct{}) {
+		for {
+			select {
+			case <-stop:
+				return
+			case sequenced, ok := <-broadcasts:
+				if !ok {
+					return
+				}
+				notifier.enqueue(queues, sequenced)
+			}
+		}
+	}(notifier.stop)
+
+	return nil
+}
+
+// enqueue adds the broadcast into the queues of the targets that accept its topic.
+// The broadcast is dropped for the targets with the full queue.
+func (notifier *Notifier) enqueue(queues []chan delivery, sequenced broadcast.Sequenced) {
+	body, err := json.Marshal(sequenced)
+	if err != nil {
+		notifier.report(fmt.Errorf("json.Marshal(topic='%s', seq=%d): %w", sequenced.Topic, sequenced.Seq, err))
+		return
+	}
+
+	for i, target := range notifier.targets {
+		if !target.accepts(sequenced.Topic) {
+			continue
+		}
+		select {
+		case queues[i] <- delivery{sequenced: sequenced, body: body}:
+		default:
+			notifier.report(fmt.Errorf("the queue of '%s' is full, dropped topic='%s', seq=%d", target.Url, sequenced.Topic, sequenced.Seq))
+		}
+	}
+}
+
+// deliver posts the queued broadcasts to the target until the notifier is closed
+func (notifier *Notifier) deliver(target Target, queue <-chan delivery, stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case queued := <-queue:
+			if err := notifier.post(target, queued.sequenced.Topic, queued.body); err != nil {
+				notifier.report(fmt.Errorf("post(topic='%s', seq=%d): %w", queued.sequenced.Topic, queued.sequenced.Seq, err))
+			}
+		}
+	}
+}
+
+// Close stops posting the broadcasts
+func (notifier *Notifier) Close() error {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	if !notifier.running {
+		return fmt.Errorf("not running")
+	}
+	close(notifier.stop)
+	notifier.running = false
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"github.com/ahmetson/service-lib/broadcast"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing orchestra
+type TestWebhookSuite struct {
+	suite.Suite
+}
+
+// Test_10_Notify tests the signature, topic filter and retries
+func (test *TestWebhookSuite) Test_10_Notify() {
+	s := test.Require
+
+	var calls atomic.Int32
+	var verified atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verified.Store(Verify("secret", body, r.Header.Get(SignatureHeader)))
+
+		// the first attempt fails
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier := New(Target{Url: server.URL, Secret: "secret", Topics: []string{"categorized"}})
+	notifier.SetRetries(2, time.Millisecond)
+
+	sequenced := broadcast.Sequenced{Topic: "categorized", Seq: 1, Parameters: map[string]interface{}{"id": "a"}}
+	s().NoError(notifier.Notify(sequenced))
+	s().Equal(int32(2), calls.Load())
+	s().True(verified.Load())
+
+	// the topic is not accepted by the target
+	s().NoError(notifier.Notify(broadcast.Sequenced{Topic: "other", Seq: 1}))
+	s().Equal(int32(2), calls.Load())
+
+	// the client errors are not retried
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	notifier = New(Target{Url: rejecting.URL})
+	notifier.SetRetries(2, time.Millisecond)
+	s().Error(notifier.Notify(sequenced))
+	s().Equal(int32(3), calls.Load())
+}
+
+// Test_11_Timeout tests that the slow target times out by its own timeout without delaying the others
+func (test *TestWebhookSuite) Test_11_Timeout() {
+	s := test.Require
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	var calls atomic.Int32
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+
+	notifier := New(
+		Target{Url: slow.URL, Timeout: time.Millisecond * 200},
+		Target{Url: fast.URL, Timeout: time.Millisecond * 200},
+	)
+	notifier.SetRetries(0, time.Millisecond)
+
+	started := time.Now()
+	err := notifier.Notify(broadcast.Sequenced{Topic: "categorized", Seq: 1})
+	s().Error(err)
+	s().Contains(err.Error(), slow.URL)
+	s().NotContains(err.Error(), fast.URL)
+	s().Equal(int32(1), calls.Load())
+
+	// the targets are posted concurrently, and the slow target is cut by its timeout
+	s().Less(time.Since(started), time.Second)
+}
+
+// Test_12_Start tests that the queued broadcasts of the fast target are not delayed by the slow target
+func (test *TestWebhookSuite) Test_12_Start() {
+	s := test.Require
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	received := make(chan string, 2)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(TopicHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+
+	notifier := New(Target{Url: slow.URL}, Target{Url: fast.URL})
+	notifier.SetRetries(0, time.Millisecond)
+
+	broadcasts := make(chan broadcast.Sequenced)
+	s().NoError(notifier.Start(broadcasts))
+	s().Error(notifier.Start(broadcasts))
+
+	broadcasts <- broadcast.Sequenced{Topic: "first", Seq: 1}
+	broadcasts <- broadcast.Sequenced{Topic: "second", Seq: 2}
+
+	for _, topic := range []string{"first", "second"} {
+		select {
+		case got := <-received:
+			s().Equal(topic, got)
+		case <-time.After(time.Second * 2):
+			s().Fail("the fast target is delayed", topic)
+		}
+	}
+
+	s().NoError(notifier.Close())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestWebhook(t *testing.T) {
+	suite.Run(t, new(TestWebhookSuite))
+}